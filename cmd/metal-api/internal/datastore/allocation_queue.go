@@ -0,0 +1,81 @@
+package datastore
+
+import (
+	"github.com/metal-stack/metal-api/cmd/metal-api/internal/metal"
+	r "gopkg.in/rethinkdb/rethinkdb-go.v6"
+)
+
+// PendingAllocationSearchQuery can be used to search pending allocations.
+type PendingAllocationSearchQuery struct {
+	ProjectID   *string `json:"projectid" optional:"true"`
+	PartitionID *string `json:"partitionid" optional:"true"`
+	SizeID      *string `json:"sizeid" optional:"true"`
+	State       *string `json:"state" optional:"true"`
+}
+
+func (p *PendingAllocationSearchQuery) generateTerm(rs *RethinkStore) *r.Term {
+	q := *rs.pendingAllocationTable()
+
+	if p.ProjectID != nil {
+		q = q.Filter(func(row r.Term) r.Term {
+			return row.Field("projectid").Eq(*p.ProjectID)
+		})
+	}
+
+	if p.PartitionID != nil {
+		q = q.Filter(func(row r.Term) r.Term {
+			return row.Field("partitionid").Eq(*p.PartitionID)
+		})
+	}
+
+	if p.SizeID != nil {
+		q = q.Filter(func(row r.Term) r.Term {
+			return row.Field("sizeid").Eq(*p.SizeID)
+		})
+	}
+
+	if p.State != nil {
+		q = q.Filter(func(row r.Term) r.Term {
+			return row.Field("state").Eq(*p.State)
+		})
+	}
+
+	return &q
+}
+
+// FindPendingAllocation returns the pending allocation for the given id.
+func (rs *RethinkStore) FindPendingAllocation(id string) (*metal.PendingAllocation, error) {
+	var a metal.PendingAllocation
+	err := rs.findEntityByID(rs.pendingAllocationTable(), &a, id)
+	if err != nil {
+		return nil, err
+	}
+	return &a, nil
+}
+
+// SearchPendingAllocations returns the result of the pending allocations search request query.
+func (rs *RethinkStore) SearchPendingAllocations(q *PendingAllocationSearchQuery, as *metal.PendingAllocations) error {
+	return rs.searchEntities(q.generateTerm(rs), as)
+}
+
+// ListPendingAllocations returns all pending allocations.
+func (rs *RethinkStore) ListPendingAllocations() (metal.PendingAllocations, error) {
+	as := make(metal.PendingAllocations, 0)
+	err := rs.listEntities(rs.pendingAllocationTable(), &as)
+	return as, err
+}
+
+// CreatePendingAllocation creates a new pending allocation.
+func (rs *RethinkStore) CreatePendingAllocation(a *metal.PendingAllocation) error {
+	return rs.createEntity(rs.pendingAllocationTable(), a)
+}
+
+// DeletePendingAllocation deletes a pending allocation.
+func (rs *RethinkStore) DeletePendingAllocation(a *metal.PendingAllocation) error {
+	return rs.deleteEntity(rs.pendingAllocationTable(), a)
+}
+
+// UpdatePendingAllocation updates a pending allocation, it fails with a conflict if the allocation was changed in the meantime.
+func (rs *RethinkStore) UpdatePendingAllocation(oldAllocation *metal.PendingAllocation, newAllocation *metal.PendingAllocation) error {
+	return rs.updateEntity(rs.pendingAllocationTable(), newAllocation, oldAllocation)
+}
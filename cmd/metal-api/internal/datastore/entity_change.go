@@ -0,0 +1,81 @@
+package datastore
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/metal-stack/metal-api/cmd/metal-api/internal/metal"
+	r "gopkg.in/rethinkdb/rethinkdb-go.v6"
+)
+
+// entityChangesOfEpoch returns the entries of the given epoch with a sequence greater than after, ordered by their sequence.
+func (rs *RethinkStore) entityChangesOfEpoch(epoch string, after uint64) r.Term {
+	return rs.entityChangeTable().Between(
+		metal.EntityChangeLogEntryID(epoch, after+1),
+		metal.EntityChangeLogEntryID(epoch, math.MaxUint64),
+		r.BetweenOpts{RightBound: "closed"},
+	)
+}
+
+// FindEntityChangeLogWriter returns the current writer of the entity change log.
+func (rs *RethinkStore) FindEntityChangeLogWriter() (*metal.EntityChangeLogWriter, error) {
+	var w metal.EntityChangeLogWriter
+	err := rs.findEntityByID(rs.entityChangeWriterTable(), &w, metal.EntityChangeLogWriterID)
+	if err != nil {
+		return nil, err
+	}
+	return &w, nil
+}
+
+// CreateEntityChangeLogWriter creates the writer of the entity change log, it fails with a conflict if there is already a writer.
+func (rs *RethinkStore) CreateEntityChangeLogWriter(w *metal.EntityChangeLogWriter) error {
+	w.ID = metal.EntityChangeLogWriterID
+	return rs.createEntity(rs.entityChangeWriterTable(), w)
+}
+
+// UpdateEntityChangeLogWriter updates the writer of the entity change log, it fails with a conflict if it was changed in the meantime.
+func (rs *RethinkStore) UpdateEntityChangeLogWriter(oldWriter *metal.EntityChangeLogWriter, newWriter *metal.EntityChangeLogWriter) error {
+	return rs.updateEntity(rs.entityChangeWriterTable(), newWriter, oldWriter)
+}
+
+// ListEntityChanges returns at most limit entries of the given epoch following the given sequence in the order of their sequence.
+func (rs *RethinkStore) ListEntityChanges(epoch string, after uint64, limit int) (metal.EntityChangeLogEntries, error) {
+	q := rs.entityChangesOfEpoch(epoch, after).OrderBy(r.OrderByOpts{Index: "id"}).Limit(limit)
+
+	es := make(metal.EntityChangeLogEntries, 0)
+	err := rs.searchEntities(&q, &es)
+	if err != nil {
+		return nil, err
+	}
+	return es, nil
+}
+
+// FindLatestEntityChange returns the entry of the given epoch with the highest sequence.
+func (rs *RethinkStore) FindLatestEntityChange(epoch string) (*metal.EntityChangeLogEntry, error) {
+	q := rs.entityChangesOfEpoch(epoch, 0).OrderBy(r.OrderByOpts{Index: r.Desc("id")}).Limit(1)
+
+	var e metal.EntityChangeLogEntry
+	err := rs.findEntity(&q, &e)
+	if err != nil {
+		return nil, err
+	}
+	return &e, nil
+}
+
+// CreateEntityChange appends an entry to the entity change log.
+func (rs *RethinkStore) CreateEntityChange(e *metal.EntityChangeLogEntry) error {
+	e.ID = metal.EntityChangeLogEntryID(e.Epoch, e.Sequence)
+	return rs.createEntity(rs.entityChangeTable(), e)
+}
+
+// DeleteEntityChangesBefore removes all entries of other epochs and the entries of the given epoch with a lower sequence
+// and returns the amount of removed entries.
+func (rs *RethinkStore) DeleteEntityChangesBefore(epoch string, sequence uint64) (int, error) {
+	res, err := rs.entityChangeTable().Filter(func(row r.Term) r.Term {
+		return row.Field("epoch").Ne(epoch).Or(row.Field("sequence").Lt(sequence))
+	}).Delete().RunWrite(rs.session)
+	if err != nil {
+		return 0, fmt.Errorf("cannot delete entity changes: %w", err)
+	}
+	return res.Deleted, nil
+}
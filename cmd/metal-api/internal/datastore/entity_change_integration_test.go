@@ -0,0 +1,22 @@
+//go:build integration
+
+package datastore
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestStore_EntityChangeLog(t *testing.T) {
+	forEachStore(t, func(t *testing.T) {
+		defer func() {
+			err := wipeTable(sharedDS, "entitychange")
+			require.NoError(t, err)
+			err = wipeTable(sharedDS, "entitychangewriter")
+			require.NoError(t, err)
+		}()
+
+		testEntityChangeLog(t, sharedDS)
+	})
+}
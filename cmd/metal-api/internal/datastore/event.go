@@ -6,6 +6,7 @@ import (
 
 	"github.com/metal-stack/metal-api/cmd/metal-api/internal/fsm"
 	"github.com/metal-stack/metal-api/cmd/metal-api/internal/metal"
+	r "gopkg.in/rethinkdb/rethinkdb-go.v6"
 )
 
 // ListProvisioningEventContainers returns all machine provisioning event containers.
@@ -25,6 +26,18 @@ func (rs *RethinkStore) FindProvisioningEventContainer(id string) (*metal.Provis
 	return &e, nil
 }
 
+// FindProvisioningEventContainers returns the provisioning event containers of the given machine ids,
+// machines without an event container are skipped.
+func (rs *RethinkStore) FindProvisioningEventContainers(ids []string) (metal.ProvisioningEventContainers, error) {
+	es := make(metal.ProvisioningEventContainers, 0)
+	if len(ids) == 0 {
+		return es, nil
+	}
+	q := rs.eventTable().GetAll(r.Args(ids))
+	err := rs.searchEntities(&q, &es)
+	return es, err
+}
+
 // UpdateProvisioningEventContainer updates a provisioning event container.
 func (rs *RethinkStore) UpdateProvisioningEventContainer(old *metal.ProvisioningEventContainer, new *metal.ProvisioningEventContainer) error {
 	return rs.updateEntity(rs.eventTable(), new, old)
@@ -40,8 +53,16 @@ func (rs *RethinkStore) UpsertProvisioningEventContainer(ec *metal.ProvisioningE
 	return rs.upsertEntity(rs.eventTable(), ec)
 }
 
+// ProvisioningEventForMachine applies the given provisioning event to the event container of the machine.
 func (rs *RethinkStore) ProvisioningEventForMachine(ctx context.Context, log *slog.Logger, event *metal.ProvisioningEvent, machineID string) (*metal.ProvisioningEventContainer, error) {
-	ec, err := rs.FindProvisioningEventContainer(machineID)
+	return provisioningEventForMachine(ctx, log, rs, event, machineID)
+}
+
+func provisioningEventForMachine(ctx context.Context, log *slog.Logger, ds interface {
+	ProvisioningEventStore
+	ProvisioningEventLogStore
+}, event *metal.ProvisioningEvent, machineID string) (*metal.ProvisioningEventContainer, error) {
+	ec, err := ds.FindProvisioningEventContainer(machineID)
 	if err != nil && !metal.IsNotFound(err) {
 		return nil, err
 	}
@@ -66,6 +87,18 @@ func (rs *RethinkStore) ProvisioningEventForMachine(ctx context.Context, log *sl
 
 	newEC.TrimEvents(100)
 
-	err = rs.UpsertProvisioningEventContainer(newEC)
-	return newEC, err
+	err = ds.UpsertProvisioningEventContainer(newEC)
+	if err != nil {
+		return nil, err
+	}
+
+	// alive events are heartbeats which only update the liveliness, all others are kept in the event log beyond the trimmed container
+	if event.Event != metal.ProvisioningEventAlive {
+		err = ds.CreateProvisioningEventLogEntry(metal.NewProvisioningEventLogEntry(machineID, event))
+		if err != nil {
+			log.Error("unable to append provisioning event to event log", "machineID", machineID, "error", err)
+		}
+	}
+
+	return newEC, nil
 }
@@ -0,0 +1,63 @@
+package datastore
+
+import (
+	"github.com/metal-stack/metal-api/cmd/metal-api/internal/metal"
+	r "gopkg.in/rethinkdb/rethinkdb-go.v6"
+)
+
+// FirewallRuleRevisionSearchQuery can be used to search firewall rule revisions.
+type FirewallRuleRevisionSearchQuery struct {
+	MachineID      *string `json:"machineid" optional:"true"`
+	AllocationUUID *string `json:"allocationuuid" optional:"true"`
+	Revision       *int    `json:"revision" optional:"true"`
+}
+
+func (p *FirewallRuleRevisionSearchQuery) generateTerm(rs *RethinkStore) *r.Term {
+	q := *rs.firewallRuleRevisionTable()
+
+	if p.MachineID != nil {
+		q = q.Filter(func(row r.Term) r.Term {
+			return row.Field("machineid").Eq(*p.MachineID)
+		})
+	}
+
+	if p.AllocationUUID != nil {
+		q = q.Filter(func(row r.Term) r.Term {
+			return row.Field("allocationuuid").Eq(*p.AllocationUUID)
+		})
+	}
+
+	if p.Revision != nil {
+		q = q.Filter(func(row r.Term) r.Term {
+			return row.Field("revision").Eq(*p.Revision)
+		})
+	}
+
+	return &q
+}
+
+// FindFirewallRuleRevision returns the given revision of the firewall rules of a firewall allocation.
+func (rs *RethinkStore) FindFirewallRuleRevision(allocationUUID string, revision int) (*metal.FirewallRuleRevision, error) {
+	var rev metal.FirewallRuleRevision
+	err := rs.findEntityByID(rs.firewallRuleRevisionTable(), &rev, metal.FirewallRuleRevisionID(allocationUUID, revision))
+	if err != nil {
+		return nil, err
+	}
+	return &rev, nil
+}
+
+// SearchFirewallRuleRevisions returns the result of the firewall rule revisions search request query.
+func (rs *RethinkStore) SearchFirewallRuleRevisions(q *FirewallRuleRevisionSearchQuery, revs *metal.FirewallRuleRevisions) error {
+	return rs.searchEntities(q.generateTerm(rs), revs)
+}
+
+// CreateFirewallRuleRevision creates a new firewall rule revision, it fails if the revision already exists.
+func (rs *RethinkStore) CreateFirewallRuleRevision(rev *metal.FirewallRuleRevision) error {
+	rev.ID = metal.FirewallRuleRevisionID(rev.AllocationUUID, rev.Revision)
+	return rs.createEntity(rs.firewallRuleRevisionTable(), rev)
+}
+
+// DeleteFirewallRuleRevision deletes a firewall rule revision.
+func (rs *RethinkStore) DeleteFirewallRuleRevision(rev *metal.FirewallRuleRevision) error {
+	return rs.deleteEntity(rs.firewallRuleRevisionTable(), rev)
+}
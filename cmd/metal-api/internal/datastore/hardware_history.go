@@ -0,0 +1,72 @@
+package datastore
+
+import (
+	"github.com/metal-stack/metal-api/cmd/metal-api/internal/metal"
+	r "gopkg.in/rethinkdb/rethinkdb-go.v6"
+)
+
+// HardwareSnapshotSearchQuery can be used to search hardware snapshots.
+type HardwareSnapshotSearchQuery struct {
+	MachineID *string `json:"machineid" optional:"true"`
+	Revision  *int    `json:"revision" optional:"true"`
+}
+
+func (p *HardwareSnapshotSearchQuery) generateTerm(rs *RethinkStore) *r.Term {
+	q := *rs.hardwareSnapshotTable()
+
+	if p.MachineID != nil {
+		q = q.Filter(func(row r.Term) r.Term {
+			return row.Field("machineid").Eq(*p.MachineID)
+		})
+	}
+
+	if p.Revision != nil {
+		q = q.Filter(func(row r.Term) r.Term {
+			return row.Field("revision").Eq(*p.Revision)
+		})
+	}
+
+	return &q
+}
+
+// FindHardwareSnapshot returns the given revision of the hardware snapshots of a machine.
+func (rs *RethinkStore) FindHardwareSnapshot(machineID string, revision int) (*metal.HardwareSnapshot, error) {
+	var s metal.HardwareSnapshot
+	err := rs.findEntityByID(rs.hardwareSnapshotTable(), &s, metal.HardwareSnapshotID(machineID, revision))
+	if err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+// SearchHardwareSnapshots returns the result of the hardware snapshots search request query.
+func (rs *RethinkStore) SearchHardwareSnapshots(q *HardwareSnapshotSearchQuery, snapshots *metal.HardwareSnapshots) error {
+	return rs.searchEntities(q.generateTerm(rs), snapshots)
+}
+
+// ListLatestHardwareSnapshots returns the latest hardware snapshot of every machine.
+func (rs *RethinkStore) ListLatestHardwareSnapshots() (metal.HardwareSnapshots, error) {
+	q := rs.hardwareSnapshotTable().Group("machineid").Max("revision").Ungroup().Map(func(row r.Term) r.Term {
+		return row.Field("reduction")
+	})
+
+	snapshots := make(metal.HardwareSnapshots, 0)
+	err := rs.searchEntities(&q, &snapshots)
+	return snapshots, err
+}
+
+// CreateHardwareSnapshot creates a new hardware snapshot, it fails if the revision already exists.
+func (rs *RethinkStore) CreateHardwareSnapshot(s *metal.HardwareSnapshot) error {
+	s.ID = metal.HardwareSnapshotID(s.MachineID, s.Revision)
+	return rs.createEntity(rs.hardwareSnapshotTable(), s)
+}
+
+// UpdateHardwareSnapshot updates a hardware snapshot, it fails with a conflict if it was changed in the meantime.
+func (rs *RethinkStore) UpdateHardwareSnapshot(oldSnapshot *metal.HardwareSnapshot, newSnapshot *metal.HardwareSnapshot) error {
+	return rs.updateEntity(rs.hardwareSnapshotTable(), newSnapshot, oldSnapshot)
+}
+
+// DeleteHardwareSnapshot deletes a hardware snapshot.
+func (rs *RethinkStore) DeleteHardwareSnapshot(s *metal.HardwareSnapshot) error {
+	return rs.deleteEntity(rs.hardwareSnapshotTable(), s)
+}
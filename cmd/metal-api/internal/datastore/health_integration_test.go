@@ -10,9 +10,13 @@ import (
 	"github.com/stretchr/testify/require"
 )
 
-func TestRethinkStore_Health(t *testing.T) {
-	result, err := sharedDS.Check(context.Background())
-	require.NoError(t, err)
-	require.Equal(t, healthstatus.HealthStatusHealthy, result.Status)
-	require.Contains(t, result.Message, "connected to rethinkdb version: rethinkdb")
+func TestStore_Health(t *testing.T) {
+	forEachStore(t, func(t *testing.T) {
+		result, err := sharedDS.Check(context.Background())
+		require.NoError(t, err)
+		require.Equal(t, healthstatus.HealthStatusHealthy, result.Status)
+		if _, ok := sharedDS.(*RethinkStore); ok {
+			require.Contains(t, result.Message, "connected to rethinkdb version: rethinkdb")
+		}
+	})
 }
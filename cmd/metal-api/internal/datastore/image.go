@@ -77,7 +77,11 @@ func (rs *RethinkStore) GetImage(id string) (*metal.Image, error) {
 
 // FindImages returns all images for the given image id.
 func (rs *RethinkStore) FindImages(id string) ([]metal.Image, error) {
-	allImages, err := rs.ListImages()
+	return findImages(rs, id)
+}
+
+func findImages(ds ImageStore, id string) ([]metal.Image, error) {
+	allImages, err := ds.ListImages()
 	if err != nil {
 		return nil, err
 	}
@@ -91,11 +95,15 @@ func (rs *RethinkStore) FindImages(id string) ([]metal.Image, error) {
 
 // FindImage returns an image for the given image id.
 func (rs *RethinkStore) FindImage(id string) (*metal.Image, error) {
-	allImages, err := rs.ListImages()
+	return findImage(rs, id)
+}
+
+func findImage(ds ImageStore, id string) (*metal.Image, error) {
+	allImages, err := ds.ListImages()
 	if err != nil {
 		return nil, err
 	}
-	i, err := rs.getMostRecentImageFor(id, allImages)
+	i, err := getMostRecentImageFor(id, allImages)
 	if err != nil {
 		return nil, metal.NotFound("no image for id:%s found:%v", id, err)
 	}
@@ -136,15 +144,19 @@ func (rs *RethinkStore) SearchImages(q *ImageSearchQuery, images *metal.Images)
 // Always at least one image per OS is kept even if no longer valid and not allocated.
 // This ensures to have always at least a usable image left.
 func (rs *RethinkStore) DeleteOrphanImages(images metal.Images, machines metal.Machines) (metal.Images, error) {
+	return deleteOrphanImages(rs, images, machines)
+}
+
+func deleteOrphanImages(ds Store, images metal.Images, machines metal.Machines) (metal.Images, error) {
 	if images == nil {
-		is, err := rs.ListImages()
+		is, err := ds.ListImages()
 		if err != nil {
 			return nil, err
 		}
 		images = is
 	}
 	if machines == nil {
-		ms, err := rs.ListMachines()
+		ms, err := ds.ListMachines()
 		if err != nil {
 			return nil, err
 		}
@@ -164,7 +176,7 @@ func (rs *RethinkStore) DeleteOrphanImages(images metal.Images, machines metal.M
 		}
 
 		if isOrphanImage(image, machines) {
-			err := rs.DeleteImage(&image)
+			err := ds.DeleteImage(&image)
 			if err != nil {
 				return nil, fmt.Errorf("unable to delete image:%s err:%w", image.ID, err)
 			}
@@ -200,7 +212,7 @@ func isOrphanImage(image metal.Image, machines metal.Machines) bool {
 // If version is not fully specified, e.g. ubuntu-19.10 or ubuntu-19.10
 // then the most recent ubuntu image (ubuntu-19.10.20200407) is returned
 // If patch is specified e.g. ubuntu-20.04.20200502 then this exact image is searched.
-func (rs *RethinkStore) getMostRecentImageFor(id string, images metal.Images) (*metal.Image, error) {
+func getMostRecentImageFor(id string, images metal.Images) (*metal.Image, error) {
 	os, sv, err := metalcommon.GetOsAndSemverFromImage(id)
 	if err != nil {
 		return nil, err
@@ -12,7 +12,7 @@ import (
 type imageTestable struct{}
 
 func (_ *imageTestable) wipe() error {
-	_, err := sharedDS.imageTable().Delete().RunWrite(sharedDS.session)
+	err := wipeTable(sharedDS, "image")
 	return err
 }
 
@@ -56,293 +56,305 @@ func (_ *imageTestable) search(q *ImageSearchQuery) ([]*metal.Image, error) { //
 	return derefSlice(res), nil
 }
 
-func TestRethinkStore_FindImage(t *testing.T) {
-	tt := &imageTestable{}
-	defer func() {
-		require.NoError(t, tt.wipe())
-	}()
+func TestStore_FindImage(t *testing.T) {
+	forEachStore(t, func(t *testing.T) {
+		tt := &imageTestable{}
+		defer func() {
+			require.NoError(t, tt.wipe())
+		}()
 
-	tests := []findTest[*metal.Image, *ImageSearchQuery]{
-		{
-			name: "find",
-			id:   "2",
+		tests := []findTest[*metal.Image, *ImageSearchQuery]{
+			{
+				name: "find",
+				id:   "2",
 
-			mock: []*metal.Image{
-				{Base: metal.Base{ID: "1"}},
-				{Base: metal.Base{ID: "2"}},
-				{Base: metal.Base{ID: "3"}},
-			},
-			want: &metal.Image{
-				Base: metal.Base{ID: "2"},
-			},
-			wantErr: nil,
-		},
-		{
-			name:    "not found",
-			id:      "4",
-			want:    nil,
-			wantErr: metal.NotFound(`no image with id "4" found`),
-		},
-	}
-	for i := range tests {
-		tests[i].run(t, tt)
-	}
+				mock: []*metal.Image{
+					{Base: metal.Base{ID: "1"}},
+					{Base: metal.Base{ID: "2"}},
+					{Base: metal.Base{ID: "3"}},
+				},
+				want: &metal.Image{
+					Base: metal.Base{ID: "2"},
+				},
+				wantErr: nil,
+			},
+			{
+				name:    "not found",
+				id:      "4",
+				want:    nil,
+				wantErr: metal.NotFound(`no image with id "4" found`),
+			},
+		}
+		for i := range tests {
+			tests[i].run(t, tt)
+		}
+	})
 }
 
-func TestRethinkStore_SearchImages(t *testing.T) {
-	tt := &imageTestable{}
-	defer func() {
-		require.NoError(t, tt.wipe())
-	}()
+func TestStore_SearchImages(t *testing.T) {
+	forEachStore(t, func(t *testing.T) {
+		tt := &imageTestable{}
+		defer func() {
+			require.NoError(t, tt.wipe())
+		}()
 
-	tests := []searchTest[*metal.Image, *ImageSearchQuery]{
-		{
-			name: "empty result",
-			q: &ImageSearchQuery{
-				ID: new("2"),
-			},
-			mock: []*metal.Image{
-				{Base: metal.Base{ID: "1"}},
-			},
-			want:    nil,
-			wantErr: nil,
-		},
-		{
-			name: "search by id",
-			q: &ImageSearchQuery{
-				ID: new("2"),
-			},
-			mock: []*metal.Image{
-				{Base: metal.Base{ID: "1"}},
-				{Base: metal.Base{ID: "2"}},
-				{Base: metal.Base{ID: "3"}},
-			},
-			want: []*metal.Image{
-				{Base: metal.Base{ID: "2"}},
-			},
-			wantErr: nil,
-		},
-		{
-			name: "search by name",
-			q: &ImageSearchQuery{
-				Name: new("b"),
-			},
-			mock: []*metal.Image{
-				{Base: metal.Base{ID: "1", Name: "a"}},
-				{Base: metal.Base{ID: "2", Name: "b"}},
-				{Base: metal.Base{ID: "3", Name: "c"}},
-			},
-			want: []*metal.Image{
-				{Base: metal.Base{ID: "2", Name: "b"}},
-			},
-			wantErr: nil,
-		},
-		{
-			name: "search by feature",
-			q: &ImageSearchQuery{
-				Features: []string{"firewall"},
-			},
-			mock: []*metal.Image{
-				{Base: metal.Base{ID: "1"}, Features: map[metal.ImageFeatureType]bool{"machine": true}},
-				{Base: metal.Base{ID: "2"}, Features: map[metal.ImageFeatureType]bool{"firewall": true}},
-				{Base: metal.Base{ID: "3"}, Features: map[metal.ImageFeatureType]bool{"firewall": true}},
-			},
-			want: []*metal.Image{
-				{Base: metal.Base{ID: "2"}, Features: map[metal.ImageFeatureType]bool{"firewall": true}},
-				{Base: metal.Base{ID: "3"}, Features: map[metal.ImageFeatureType]bool{"firewall": true}},
-			},
-			wantErr: nil,
-		},
-		{
-			name: "search by multiple features",
-			q: &ImageSearchQuery{
-				Features: []string{"machine", "firewall"},
-			},
-			mock: []*metal.Image{
-				{Base: metal.Base{ID: "1"}, Features: map[metal.ImageFeatureType]bool{"machine": true}},
-				{Base: metal.Base{ID: "2"}, Features: map[metal.ImageFeatureType]bool{"machine": true, "firewall": true}},
-				{Base: metal.Base{ID: "3"}, Features: map[metal.ImageFeatureType]bool{"firewall": true}},
-			},
-			want: []*metal.Image{
-				{Base: metal.Base{ID: "2"}, Features: map[metal.ImageFeatureType]bool{"machine": true, "firewall": true}},
-			},
-			wantErr: nil,
-		},
-		{
-			name: "search by os",
-			q: &ImageSearchQuery{
-				OS: new("ubuntu"),
-			},
-			mock: []*metal.Image{
-				{Base: metal.Base{ID: "1"}, OS: "debian"},
-				{Base: metal.Base{ID: "2"}, OS: "ubuntu"},
-				{Base: metal.Base{ID: "3"}, OS: "debian"},
-			},
-			want: []*metal.Image{
-				{Base: metal.Base{ID: "2"}, OS: "ubuntu"},
-			},
-			wantErr: nil,
-		},
-		{
-			name: "search by version",
-			q: &ImageSearchQuery{
-				Version: new("v2"),
-			},
-			mock: []*metal.Image{
-				{Base: metal.Base{ID: "1"}, Version: "v2"},
-				{Base: metal.Base{ID: "2"}, Version: "v1"},
-				{Base: metal.Base{ID: "3"}, Version: "v2"},
-			},
-			want: []*metal.Image{
-				{Base: metal.Base{ID: "1"}, Version: "v2"},
-				{Base: metal.Base{ID: "3"}, Version: "v2"},
-			},
-			wantErr: nil,
-		},
-		{
-			name: "search by classification",
-			q: &ImageSearchQuery{
-				Classification: new(string(metal.ClassificationPreview)),
-			},
-			mock: []*metal.Image{
-				{Base: metal.Base{ID: "1"}, Classification: metal.ClassificationPreview},
-				{Base: metal.Base{ID: "2"}, Classification: metal.ClassificationSupported},
-				{Base: metal.Base{ID: "3"}, Classification: metal.ClassificationPreview},
-				{Base: metal.Base{ID: "4"}, Classification: metal.ClassificationDeprecated},
-			},
-			want: []*metal.Image{
-				{Base: metal.Base{ID: "1"}, Classification: metal.ClassificationPreview},
-				{Base: metal.Base{ID: "3"}, Classification: metal.ClassificationPreview},
-			},
-			wantErr: nil,
-		},
-	}
+		tests := []searchTest[*metal.Image, *ImageSearchQuery]{
+			{
+				name: "empty result",
+				q: &ImageSearchQuery{
+					ID: new("2"),
+				},
+				mock: []*metal.Image{
+					{Base: metal.Base{ID: "1"}},
+				},
+				want:    nil,
+				wantErr: nil,
+			},
+			{
+				name: "search by id",
+				q: &ImageSearchQuery{
+					ID: new("2"),
+				},
+				mock: []*metal.Image{
+					{Base: metal.Base{ID: "1"}},
+					{Base: metal.Base{ID: "2"}},
+					{Base: metal.Base{ID: "3"}},
+				},
+				want: []*metal.Image{
+					{Base: metal.Base{ID: "2"}},
+				},
+				wantErr: nil,
+			},
+			{
+				name: "search by name",
+				q: &ImageSearchQuery{
+					Name: new("b"),
+				},
+				mock: []*metal.Image{
+					{Base: metal.Base{ID: "1", Name: "a"}},
+					{Base: metal.Base{ID: "2", Name: "b"}},
+					{Base: metal.Base{ID: "3", Name: "c"}},
+				},
+				want: []*metal.Image{
+					{Base: metal.Base{ID: "2", Name: "b"}},
+				},
+				wantErr: nil,
+			},
+			{
+				name: "search by feature",
+				q: &ImageSearchQuery{
+					Features: []string{"firewall"},
+				},
+				mock: []*metal.Image{
+					{Base: metal.Base{ID: "1"}, Features: map[metal.ImageFeatureType]bool{"machine": true}},
+					{Base: metal.Base{ID: "2"}, Features: map[metal.ImageFeatureType]bool{"firewall": true}},
+					{Base: metal.Base{ID: "3"}, Features: map[metal.ImageFeatureType]bool{"firewall": true}},
+				},
+				want: []*metal.Image{
+					{Base: metal.Base{ID: "2"}, Features: map[metal.ImageFeatureType]bool{"firewall": true}},
+					{Base: metal.Base{ID: "3"}, Features: map[metal.ImageFeatureType]bool{"firewall": true}},
+				},
+				wantErr: nil,
+			},
+			{
+				name: "search by multiple features",
+				q: &ImageSearchQuery{
+					Features: []string{"machine", "firewall"},
+				},
+				mock: []*metal.Image{
+					{Base: metal.Base{ID: "1"}, Features: map[metal.ImageFeatureType]bool{"machine": true}},
+					{Base: metal.Base{ID: "2"}, Features: map[metal.ImageFeatureType]bool{"machine": true, "firewall": true}},
+					{Base: metal.Base{ID: "3"}, Features: map[metal.ImageFeatureType]bool{"firewall": true}},
+				},
+				want: []*metal.Image{
+					{Base: metal.Base{ID: "2"}, Features: map[metal.ImageFeatureType]bool{"machine": true, "firewall": true}},
+				},
+				wantErr: nil,
+			},
+			{
+				name: "search by os",
+				q: &ImageSearchQuery{
+					OS: new("ubuntu"),
+				},
+				mock: []*metal.Image{
+					{Base: metal.Base{ID: "1"}, OS: "debian"},
+					{Base: metal.Base{ID: "2"}, OS: "ubuntu"},
+					{Base: metal.Base{ID: "3"}, OS: "debian"},
+				},
+				want: []*metal.Image{
+					{Base: metal.Base{ID: "2"}, OS: "ubuntu"},
+				},
+				wantErr: nil,
+			},
+			{
+				name: "search by version",
+				q: &ImageSearchQuery{
+					Version: new("v2"),
+				},
+				mock: []*metal.Image{
+					{Base: metal.Base{ID: "1"}, Version: "v2"},
+					{Base: metal.Base{ID: "2"}, Version: "v1"},
+					{Base: metal.Base{ID: "3"}, Version: "v2"},
+				},
+				want: []*metal.Image{
+					{Base: metal.Base{ID: "1"}, Version: "v2"},
+					{Base: metal.Base{ID: "3"}, Version: "v2"},
+				},
+				wantErr: nil,
+			},
+			{
+				name: "search by classification",
+				q: &ImageSearchQuery{
+					Classification: new(string(metal.ClassificationPreview)),
+				},
+				mock: []*metal.Image{
+					{Base: metal.Base{ID: "1"}, Classification: metal.ClassificationPreview},
+					{Base: metal.Base{ID: "2"}, Classification: metal.ClassificationSupported},
+					{Base: metal.Base{ID: "3"}, Classification: metal.ClassificationPreview},
+					{Base: metal.Base{ID: "4"}, Classification: metal.ClassificationDeprecated},
+				},
+				want: []*metal.Image{
+					{Base: metal.Base{ID: "1"}, Classification: metal.ClassificationPreview},
+					{Base: metal.Base{ID: "3"}, Classification: metal.ClassificationPreview},
+				},
+				wantErr: nil,
+			},
+		}
 
-	for i := range tests {
-		tests[i].run(t, tt)
-	}
+		for i := range tests {
+			tests[i].run(t, tt)
+		}
+	})
 }
 
-func TestRethinkStore_ListImages(t *testing.T) {
-	tt := &imageTestable{}
-	defer func() {
-		require.NoError(t, tt.wipe())
-	}()
+func TestStore_ListImages(t *testing.T) {
+	forEachStore(t, func(t *testing.T) {
+		tt := &imageTestable{}
+		defer func() {
+			require.NoError(t, tt.wipe())
+		}()
 
-	tests := []listTest[*metal.Image, *ImageSearchQuery]{
-		{
-			name: "list",
-			mock: []*metal.Image{
-				{Base: metal.Base{ID: "1"}},
-				{Base: metal.Base{ID: "2"}},
-				{Base: metal.Base{ID: "3"}},
-			},
-			want: []*metal.Image{
-				{Base: metal.Base{ID: "1"}},
-				{Base: metal.Base{ID: "2"}},
-				{Base: metal.Base{ID: "3"}},
-			},
-		},
-	}
-	for i := range tests {
-		tests[i].run(t, tt)
-	}
+		tests := []listTest[*metal.Image, *ImageSearchQuery]{
+			{
+				name: "list",
+				mock: []*metal.Image{
+					{Base: metal.Base{ID: "1"}},
+					{Base: metal.Base{ID: "2"}},
+					{Base: metal.Base{ID: "3"}},
+				},
+				want: []*metal.Image{
+					{Base: metal.Base{ID: "1"}},
+					{Base: metal.Base{ID: "2"}},
+					{Base: metal.Base{ID: "3"}},
+				},
+			},
+		}
+		for i := range tests {
+			tests[i].run(t, tt)
+		}
+	})
 }
 
-func TestRethinkStore_CreateImage(t *testing.T) {
-	tt := &imageTestable{}
-	defer func() {
-		require.NoError(t, tt.wipe())
-	}()
+func TestStore_CreateImage(t *testing.T) {
+	forEachStore(t, func(t *testing.T) {
+		tt := &imageTestable{}
+		defer func() {
+			require.NoError(t, tt.wipe())
+		}()
 
-	tests := []createTest[*metal.Image, *ImageSearchQuery]{
-		{
-			name: "create",
-			want: &metal.Image{
-				Base: metal.Base{ID: "1"},
-			},
-			wantErr: nil,
-		},
-		{
-			name: "already exists",
-			mock: []*metal.Image{
-				{Base: metal.Base{ID: "1"}},
-			},
-			want: &metal.Image{
-				Base: metal.Base{ID: "1"},
-			},
-			wantErr: metal.Conflict(`cannot create image in database, entity already exists: 1`),
-		},
-	}
-	for i := range tests {
-		tests[i].run(t, tt)
-	}
+		tests := []createTest[*metal.Image, *ImageSearchQuery]{
+			{
+				name: "create",
+				want: &metal.Image{
+					Base: metal.Base{ID: "1"},
+				},
+				wantErr: nil,
+			},
+			{
+				name: "already exists",
+				mock: []*metal.Image{
+					{Base: metal.Base{ID: "1"}},
+				},
+				want: &metal.Image{
+					Base: metal.Base{ID: "1"},
+				},
+				wantErr: metal.Conflict(`cannot create image in database, entity already exists: 1`),
+			},
+		}
+		for i := range tests {
+			tests[i].run(t, tt)
+		}
+	})
 }
 
-func TestRethinkStore_DeleteImage(t *testing.T) {
-	tt := &imageTestable{}
-	defer func() {
-		require.NoError(t, tt.wipe())
-	}()
+func TestStore_DeleteImage(t *testing.T) {
+	forEachStore(t, func(t *testing.T) {
+		tt := &imageTestable{}
+		defer func() {
+			require.NoError(t, tt.wipe())
+		}()
 
-	tests := []deleteTest[*metal.Image, *ImageSearchQuery]{
-		{
-			name: "delete",
-			id:   "2",
-			mock: []*metal.Image{
-				{Base: metal.Base{ID: "1"}},
-				{Base: metal.Base{ID: "2"}},
-				{Base: metal.Base{ID: "3"}},
-			},
-			want: []*metal.Image{
-				{Base: metal.Base{ID: "1"}},
-				{Base: metal.Base{ID: "3"}},
-			},
-		},
-		{
-			name: "not exists results in noop",
-			id:   "abc",
-			mock: []*metal.Image{
-				{Base: metal.Base{ID: "1"}},
-				{Base: metal.Base{ID: "2"}},
-				{Base: metal.Base{ID: "3"}},
-			},
-			want: []*metal.Image{
-				{Base: metal.Base{ID: "1"}},
-				{Base: metal.Base{ID: "2"}},
-				{Base: metal.Base{ID: "3"}},
-			},
-		},
-	}
-	for i := range tests {
-		tests[i].run(t, tt)
-	}
+		tests := []deleteTest[*metal.Image, *ImageSearchQuery]{
+			{
+				name: "delete",
+				id:   "2",
+				mock: []*metal.Image{
+					{Base: metal.Base{ID: "1"}},
+					{Base: metal.Base{ID: "2"}},
+					{Base: metal.Base{ID: "3"}},
+				},
+				want: []*metal.Image{
+					{Base: metal.Base{ID: "1"}},
+					{Base: metal.Base{ID: "3"}},
+				},
+			},
+			{
+				name: "not exists results in noop",
+				id:   "abc",
+				mock: []*metal.Image{
+					{Base: metal.Base{ID: "1"}},
+					{Base: metal.Base{ID: "2"}},
+					{Base: metal.Base{ID: "3"}},
+				},
+				want: []*metal.Image{
+					{Base: metal.Base{ID: "1"}},
+					{Base: metal.Base{ID: "2"}},
+					{Base: metal.Base{ID: "3"}},
+				},
+			},
+		}
+		for i := range tests {
+			tests[i].run(t, tt)
+		}
+	})
 }
 
-func TestRethinkStore_UpdateImage(t *testing.T) {
-	tt := &imageTestable{}
-	defer func() {
-		require.NoError(t, tt.wipe())
-	}()
+func TestStore_UpdateImage(t *testing.T) {
+	forEachStore(t, func(t *testing.T) {
+		tt := &imageTestable{}
+		defer func() {
+			require.NoError(t, tt.wipe())
+		}()
 
-	tests := []updateTest[*metal.Image, *ImageSearchQuery]{
-		{
-			name: "update",
-			mock: []*metal.Image{
-				{Base: metal.Base{ID: "1"}},
-				{Base: metal.Base{ID: "2"}},
-				{Base: metal.Base{ID: "3"}},
-			},
-			mutateFn: func(s *metal.Image) {
-				s.URL = "url"
-			},
-			want: &metal.Image{
-				Base: metal.Base{ID: "1"},
-				URL:  "url",
-			},
-		},
-	}
-	for i := range tests {
-		tests[i].run(t, tt)
-	}
+		tests := []updateTest[*metal.Image, *ImageSearchQuery]{
+			{
+				name: "update",
+				mock: []*metal.Image{
+					{Base: metal.Base{ID: "1"}},
+					{Base: metal.Base{ID: "2"}},
+					{Base: metal.Base{ID: "3"}},
+				},
+				mutateFn: func(s *metal.Image) {
+					s.URL = "url"
+				},
+				want: &metal.Image{
+					Base: metal.Base{ID: "1"},
+					URL:  "url",
+				},
+			},
+		}
+		for i := range tests {
+			tests[i].run(t, tt)
+		}
+	})
 }
@@ -82,12 +82,10 @@ func Test_getMostRecentImageFor(t *testing.T) {
 			wantErr: false,
 		},
 	}
-	rs := &RethinkStore{}
-
 	for i := range tests {
 		tt := tests[i]
 		t.Run(tt.name, func(t *testing.T) {
-			got, err := rs.getMostRecentImageFor(tt.id, tt.images)
+			got, err := getMostRecentImageFor(tt.id, tt.images)
 			if (err != nil) != tt.wantErr {
 				t.Errorf("getMostRecentImageFor() error = %v, wantErr %v", err, tt.wantErr)
 				return
@@ -125,12 +123,10 @@ func Test_getMostRecentImageForFirewall(t *testing.T) {
 			wantErr: false,
 		},
 	}
-	rs := &RethinkStore{}
-
 	for i := range tests {
 		tt := tests[i]
 		t.Run(tt.name, func(t *testing.T) {
-			got, err := rs.getMostRecentImageFor(tt.id, tt.images)
+			got, err := getMostRecentImageFor(tt.id, tt.images)
 			if (err != nil) != tt.wantErr {
 				t.Errorf("getMostRecentImageFor() error = %v, wantErr %v", err, tt.wantErr)
 				return
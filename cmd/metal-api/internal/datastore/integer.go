@@ -59,7 +59,17 @@ type integerinfo struct {
 	IsInitialized bool   `rethinkdb:"isInitialized" json:"isInitialized"`
 }
 
-func (rs *RethinkStore) GetVRFPool() *IntegerPool {
+// GetVRFPool returns the pool of unique integers used for vrfs.
+func (rs *RethinkStore) GetVRFPool() IntegerPooler {
+	return rs.vrfPool()
+}
+
+// GetASNPool returns the pool of unique integers used for asns.
+func (rs *RethinkStore) GetASNPool() IntegerPooler {
+	return rs.asnPool()
+}
+
+func (rs *RethinkStore) vrfPool() *IntegerPool {
 	return &IntegerPool{
 		poolType:  VRFIntegerPool,
 		session:   rs.session,
@@ -70,7 +80,7 @@ func (rs *RethinkStore) GetVRFPool() *IntegerPool {
 	}
 }
 
-func (rs *RethinkStore) GetASNPool() *IntegerPool {
+func (rs *RethinkStore) asnPool() *IntegerPool {
 	return &IntegerPool{
 		poolType:  ASNIntegerPool,
 		session:   rs.session,
@@ -194,6 +204,28 @@ func (ip *IntegerPool) ReleaseUniqueInteger(id uint) error {
 	return nil
 }
 
+// Usage returns the amount of free and used integers of the pool.
+func (ip *IntegerPool) Usage() (*IntegerPoolUsage, error) {
+	res, err := ip.poolTable.Count().Run(ip.session)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Close()
+
+	var free uint
+	err = res.One(&free)
+	if err != nil {
+		return nil, err
+	}
+
+	size := ip.max - ip.min + 1
+
+	return &IntegerPoolUsage{
+		Free: free,
+		Used: size - min(free, size),
+	}, nil
+}
+
 func (ip *IntegerPool) genericAcquire(term *r.Term) (uint, error) {
 	res, err := term.Delete(r.DeleteOpts{ReturnChanges: true}).RunWrite(ip.session)
 	if err != nil {
@@ -17,97 +17,76 @@ import (
 	"github.com/stretchr/testify/require"
 )
 
-func TestRethinkStore_AcquireRandomUniqueIntegerIntegration(t *testing.T) {
-	container, c, err := test.StartRethink(t)
-	require.NoError(t, err)
-	defer func() {
-		_ = container.Terminate(context.Background())
-	}()
-
-	log := slog.New(slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelError}))
-
-	rs := New(log, c.IP+":"+c.Port, c.DB, c.User, c.Password)
-	rs.VRFPoolRangeMin = 10000
-	rs.VRFPoolRangeMax = 10010
-	rs.ASNPoolRangeMin = 10000
-	rs.ASNPoolRangeMax = 10010
-
-	err = rs.Connect()
-	require.NoError(t, err)
-	err = rs.Initialize()
-	require.NoError(t, err)
-
-	pool := rs.GetVRFPool()
-	got, err := pool.AcquireRandomUniqueInteger()
-	require.NoError(t, err)
-	assert.GreaterOrEqual(t, got, uint(rs.VRFPoolRangeMin))
-	assert.LessOrEqual(t, got, uint(rs.VRFPoolRangeMax))
+func TestStore_AcquireRandomUniqueIntegerIntegration(t *testing.T) {
+	forEachFreshStore(t, func(t *testing.T, ds Store) {
+		pool := ds.GetVRFPool()
+		got, err := pool.AcquireRandomUniqueInteger()
+		require.NoError(t, err)
+		assert.GreaterOrEqual(t, got, uint(10000))
+		assert.LessOrEqual(t, got, uint(10010))
+	})
 }
 
-func TestRethinkStore_AcquireUniqueIntegerTwiceIntegration(t *testing.T) {
-	container, c, err := test.StartRethink(t)
-	require.NoError(t, err)
-	defer func() {
-		_ = container.Terminate(context.Background())
-	}()
-	log := slog.New(slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelError}))
-
-	rs := New(log, c.IP+":"+c.Port, c.DB, c.User, c.Password)
-	rs.VRFPoolRangeMin = 10000
-	rs.VRFPoolRangeMax = 10010
-	rs.ASNPoolRangeMin = 10000
-	rs.ASNPoolRangeMax = 10010
-
-	err = rs.Connect()
-	require.NoError(t, err)
-	err = rs.Initialize()
-	require.NoError(t, err)
-
-	pool := rs.GetVRFPool()
-	got, err := pool.AcquireUniqueInteger(10000)
-	require.NoError(t, err)
-	assert.Equal(t, uint(10000), got)
-
-	_, err = pool.AcquireUniqueInteger(10000)
-	assert.True(t, metal.IsConflict(err))
+func TestStore_AcquireUniqueIntegerTwiceIntegration(t *testing.T) {
+	forEachFreshStore(t, func(t *testing.T, ds Store) {
+		pool := ds.GetVRFPool()
+		got, err := pool.AcquireUniqueInteger(10000)
+		require.NoError(t, err)
+		assert.Equal(t, uint(10000), got)
+
+		_, err = pool.AcquireUniqueInteger(10000)
+		assert.True(t, metal.IsConflict(err))
+	})
+}
+
+func TestStore_AcquireUniqueIntegerPoolExhaustionIntegration(t *testing.T) {
+	forEachFreshStore(t, func(t *testing.T, ds Store) {
+		pool := ds.GetVRFPool()
+		var wg sync.WaitGroup
+
+		for range 11 {
+			wg.Go(func() {
+				got, err := pool.AcquireRandomUniqueInteger()
+				if err != nil {
+					t.Fail()
+				}
+				assert.GreaterOrEqual(t, got, uint(10000))
+				assert.LessOrEqual(t, got, uint(10010))
+			})
+		}
+
+		wg.Wait()
+
+		_, err := pool.AcquireRandomUniqueInteger()
+		assert.True(t, metal.IsInternal(err))
+	})
 }
 
-func TestRethinkStore_AcquireUniqueIntegerPoolExhaustionIntegration(t *testing.T) {
-	container, c, err := test.StartRethink(t)
-	require.NoError(t, err)
-	defer func() {
-		_ = container.Terminate(context.Background())
-	}()
-
-	log := slog.New(slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelError}))
-
-	rs := New(log, c.IP+":"+c.Port, c.DB, c.User, c.Password)
-	rs.VRFPoolRangeMin = 10000
-	rs.VRFPoolRangeMax = 10010
-	rs.ASNPoolRangeMin = 10000
-	rs.ASNPoolRangeMax = 10010
-
-	err = rs.Connect()
-	require.NoError(t, err)
-	err = rs.Initialize()
-	require.NoError(t, err)
-
-	pool := rs.GetVRFPool()
-	var wg sync.WaitGroup
-
-	for i := rs.VRFPoolRangeMin; i <= rs.VRFPoolRangeMax; i++ {
-		wg.Go(func() {
-			got, err := pool.AcquireRandomUniqueInteger()
-			if err != nil {
-				t.Fail()
-			}
-			assert.GreaterOrEqual(t, got, uint(rs.VRFPoolRangeMin))
-			assert.LessOrEqual(t, got, uint(rs.VRFPoolRangeMax))
-		})
-	}
-
-	wg.Wait()
-
-	_, err = pool.AcquireRandomUniqueInteger()
-	assert.True(t, metal.IsInternal(err))
+// forEachFreshStore runs the given test against newly initialized stores, such that the integer pools are not shared with other tests.
+func forEachFreshStore(t *testing.T, fn func(t *testing.T, ds Store)) {
+	t.Run("rethinkdb", func(t *testing.T) {
+		container, c, err := test.StartRethink(t)
+		require.NoError(t, err)
+		defer func() {
+			_ = container.Terminate(context.Background())
+		}()
+
+		log := slog.New(slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelError}))
+
+		rs := New(log, c.IP+":"+c.Port, c.DB, c.User, c.Password)
+		rs.VRFPoolRangeMin = 10000
+		rs.VRFPoolRangeMax = 10010
+		rs.ASNPoolRangeMin = 10000
+		rs.ASNPoolRangeMax = 10010
+
+		err = rs.Connect()
+		require.NoError(t, err)
+		err = rs.Initialize()
+		require.NoError(t, err)
+
+		fn(t, rs)
+	})
+	t.Run("memory", func(t *testing.T) {
+		fn(t, startMemoryInitialized())
+	})
 }
@@ -217,7 +217,7 @@ func TestRethinkStore_genericAcquire(t *testing.T) {
 		tt := tests[i]
 		t.Run(tt.name, func(t *testing.T) {
 			rs, mock := InitMockDB(t)
-			ip := rs.GetVRFPool()
+			ip := rs.vrfPool()
 
 			term := ip.poolTable.Get(tt.value)
 			if tt.requiresMock {
@@ -21,6 +21,19 @@ type IPSearchQuery struct {
 	Type             *string  `json:"type" description:"the type of the ip address, ephemeral or static" optional:"true"`
 	MachineID        *string  `json:"machineid" description:"the machine an ip address is associated to" optional:"true"`
 	AddressFamily    *string  `json:"addressfamily" optional:"true" enum:"IPv4|IPv6"`
+
+	Paging
+}
+
+// Validate checks the paging of the query.
+func (p *IPSearchQuery) Validate() error {
+	return validatePaging(&p.Paging, ipSortKeys)
+}
+
+// NextCursor returns the cursor of the page following the given ips which were found with this query,
+// it is empty if there are no further pages.
+func (p *IPSearchQuery) NextCursor(ips metal.IPs) string {
+	return nextCursor(&p.Paging, ipSortKeys, ips)
 }
 
 // GenerateTerm generates the project search query term.
@@ -118,7 +131,11 @@ func (rs *RethinkStore) SearchIPs(q *IPSearchQuery, ips *metal.IPs) error {
 	if err != nil {
 		return err
 	}
-	return rs.searchEntities(term, ips)
+	paged, err := pageTerm(*term, &q.Paging, ipSortKeys)
+	if err != nil {
+		return err
+	}
+	return rs.searchEntities(&paged, ips)
 }
 
 // ListIPs returns all ips.
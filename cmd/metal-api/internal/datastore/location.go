@@ -0,0 +1,105 @@
+package datastore
+
+import (
+	"slices"
+
+	"github.com/metal-stack/metal-api/cmd/metal-api/internal/metal"
+	"github.com/metal-stack/metal-lib/pkg/pointer"
+	r "gopkg.in/rethinkdb/rethinkdb-go.v6"
+)
+
+// LocationSearchQuery can be used to search locations.
+type LocationSearchQuery struct {
+	ID          *string `json:"id" optional:"true"`
+	Level       *string `json:"level" optional:"true"`
+	ParentID    *string `json:"parentid" optional:"true"`
+	PowerFeedID *string `json:"powerfeedid" optional:"true"`
+}
+
+func (p *LocationSearchQuery) generateTerm(rs *RethinkStore) *r.Term {
+	q := *rs.locationTable()
+
+	if p.ID != nil {
+		q = q.Filter(func(row r.Term) r.Term {
+			return row.Field("id").Eq(*p.ID)
+		})
+	}
+
+	if p.Level != nil {
+		q = q.Filter(func(row r.Term) r.Term {
+			return row.Field("level").Eq(*p.Level)
+		})
+	}
+
+	if p.ParentID != nil {
+		q = q.Filter(func(row r.Term) r.Term {
+			return row.Field("parentid").Eq(*p.ParentID)
+		})
+	}
+
+	if p.PowerFeedID != nil {
+		q = q.Filter(func(row r.Term) r.Term {
+			return row.Field("powerfeedid").Eq(*p.PowerFeedID)
+		})
+	}
+
+	return &q
+}
+
+// FindLocation returns the location for the given id.
+func (rs *RethinkStore) FindLocation(id string) (*metal.Location, error) {
+	var l metal.Location
+	err := rs.findEntityByID(rs.locationTable(), &l, id)
+	if err != nil {
+		return nil, err
+	}
+	return &l, nil
+}
+
+// SearchLocations returns the result of the locations search request query.
+func (rs *RethinkStore) SearchLocations(q *LocationSearchQuery, ls *metal.Locations) error {
+	return rs.searchEntities(q.generateTerm(rs), ls)
+}
+
+// ListLocations returns all locations.
+func (rs *RethinkStore) ListLocations() (metal.Locations, error) {
+	ls := make(metal.Locations, 0)
+	err := rs.listEntities(rs.locationTable(), &ls)
+	return ls, err
+}
+
+// CreateLocation creates a new location.
+func (rs *RethinkStore) CreateLocation(l *metal.Location) error {
+	return rs.createEntity(rs.locationTable(), l)
+}
+
+// DeleteLocation deletes a location.
+func (rs *RethinkStore) DeleteLocation(l *metal.Location) error {
+	return rs.deleteEntity(rs.locationTable(), l)
+}
+
+// UpdateLocation updates a location.
+func (rs *RethinkStore) UpdateLocation(oldLocation *metal.Location, newLocation *metal.Location) error {
+	return rs.updateEntity(rs.locationTable(), newLocation, oldLocation)
+}
+
+// UnknownRack returns true if racks are modeled as locations but none of them has the given rack id.
+// Machines and switches of such a rack are not attributed to any failure domain above the rack level.
+func UnknownRack(ds LocationStore, rackID string) (bool, error) {
+	if rackID == "" {
+		return false, nil
+	}
+
+	var racks metal.Locations
+	err := ds.SearchLocations(&LocationSearchQuery{Level: pointer.Pointer(string(metal.LocationLevelRack))}, &racks)
+	if err != nil {
+		return false, err
+	}
+	if len(racks) == 0 {
+		return false, nil
+	}
+
+	return !slices.ContainsFunc(racks, func(l metal.Location) bool {
+		return l.ID == rackID
+	}), nil
+}
@@ -0,0 +1,38 @@
+package datastore
+
+import (
+	"log/slog"
+	"testing"
+
+	"github.com/metal-stack/metal-api/cmd/metal-api/internal/metal"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUnknownRack(t *testing.T) {
+	ds := NewMemory(slog.Default())
+
+	// as long as no racks are modeled, every rack is fine
+	unknown, err := UnknownRack(ds, "rack-1")
+	require.NoError(t, err)
+	require.False(t, unknown)
+
+	require.NoError(t, ds.CreateLocation(&metal.Location{Base: metal.Base{ID: "fra1"}, Level: metal.LocationLevelDatacenter}))
+	require.NoError(t, ds.CreateLocation(&metal.Location{Base: metal.Base{ID: "rack-1"}, Level: metal.LocationLevelRack, ParentID: "fra1"}))
+
+	tests := []struct {
+		rackID string
+		want   bool
+	}{
+		{rackID: "rack-1", want: false},
+		{rackID: "rack-2", want: true},
+		{rackID: "fra1", want: true},
+		{rackID: "", want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.rackID, func(t *testing.T) {
+			unknown, err := UnknownRack(ds, tt.rackID)
+			require.NoError(t, err)
+			require.Equal(t, tt.want, unknown)
+		})
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"log/slog"
 	"math"
 	"math/rand/v2"
 	"time"
@@ -13,6 +14,16 @@ import (
 	r "gopkg.in/rethinkdb/rethinkdb-go.v6"
 )
 
+// ErrNoMachineAvailable is returned if no waiting machine can be elected for an allocation.
+var ErrNoMachineAvailable = errors.New("no machine available")
+
+// ErrNoMachineAvailableForProject is returned if there are waiting machines, but none of them can be elected for the project
+// of the allocation because they are reserved for other projects by size reservations.
+var ErrNoMachineAvailableForProject = fmt.Errorf("%w for the project, the remaining machines are reserved for other projects", ErrNoMachineAvailable)
+
+// ErrTooManyParallelAllocations is returned if the allocation could not acquire the allocation lock of the partition in time.
+var ErrTooManyParallelAllocations = errors.New("too many parallel machine allocations taking place, try again later")
+
 // MachineSearchQuery can be used to search machines.
 type MachineSearchQuery struct {
 	ID          *string  `json:"id" optional:"true"`
@@ -30,6 +41,10 @@ type MachineSearchQuery struct {
 	AllocationRole      *metal.Role `json:"allocation_role" optional:"true"`
 	AllocationSucceeded *bool       `json:"allocation_succeeded" optional:"true"`
 
+	// lease
+	AllocationLeased             *bool      `json:"allocation_leased" optional:"true"`
+	AllocationLeaseExpiresBefore *time.Time `json:"allocation_lease_expires_before" optional:"true"`
+
 	// network
 	NetworkIDs                 []string `json:"network_ids" optional:"true"`
 	NetworkPrefixes            []string `json:"network_prefixes" optional:"true"`
@@ -71,6 +86,19 @@ type MachineSearchQuery struct {
 	FruProductManufacturer *string `json:"fru_product_manufacturer" optional:"true"`
 	FruProductPartNumber   *string `json:"fru_product_part_number" optional:"true"`
 	FruProductSerial       *string `json:"fru_product_serial" optional:"true"`
+
+	Paging
+}
+
+// Validate checks the paging of the query.
+func (p *MachineSearchQuery) Validate() error {
+	return validatePaging(&p.Paging, machineSortKeys)
+}
+
+// NextCursor returns the cursor of the page following the given machines which were found with this query,
+// it is empty if there are no further pages.
+func (p *MachineSearchQuery) NextCursor(ms metal.Machines) string {
+	return nextCursor(&p.Paging, machineSortKeys, ms)
 }
 
 // GenerateTerm generates the project search query term.
@@ -149,6 +177,19 @@ func (p *MachineSearchQuery) generateTerm(rs *RethinkStore) *r.Term {
 		})
 	}
 
+	if p.AllocationLeased != nil {
+		q = q.Filter(func(row r.Term) r.Term {
+			// allocations from before leases were introduced do not contain the lease field
+			return row.Field("allocation").Ne(nil).And(row.Field("allocation").Field("lease").Default(nil).Ne(nil).Eq(*p.AllocationLeased))
+		})
+	}
+
+	if p.AllocationLeaseExpiresBefore != nil {
+		q = q.Filter(func(row r.Term) r.Term {
+			return row.Field("allocation").Field("lease").Field("expires").Lt(*p.AllocationLeaseExpiresBefore)
+		})
+	}
+
 	for _, id := range p.NetworkIDs {
 		q = q.Filter(func(row r.Term) r.Term {
 			return row.Field("allocation").Field("networks").Map(func(nw r.Term) r.Term {
@@ -371,7 +412,11 @@ func (rs *RethinkStore) FindMachine(q *MachineSearchQuery, ms *metal.Machine) er
 
 // SearchMachines returns the result of the machines search request query.
 func (rs *RethinkStore) SearchMachines(q *MachineSearchQuery, ms *metal.Machines) error {
-	return rs.searchEntities(q.generateTerm(rs), ms)
+	term, err := pageTerm(*q.generateTerm(rs), &q.Paging, machineSortKeys)
+	if err != nil {
+		return err
+	}
+	return rs.searchEntities(&term, ms)
 }
 
 // ListMachines returns all machines.
@@ -407,7 +452,7 @@ func (rs *RethinkStore) UpdateMachine(oldMachine *metal.Machine, newMachine *met
 // FindWaitingMachine returns an available, not allocated, waiting and alive machine of given size within the given partition.
 // TODO: the algorithm can be optimized / shortened by using a rethinkdb join command and then using .Sample(1)
 // but current implementation should have a slightly better readability.
-func (rs *RethinkStore) FindWaitingMachine(ctx context.Context, projectid, partitionid string, size metal.Size, placementTags []string, role metal.Role) (*metal.Machine, error) {
+func (rs *RethinkStore) FindWaitingMachine(ctx context.Context, projectid, partitionid string, size metal.Size, placement metal.Placement, role metal.Role) (*metal.Machine, error) {
 	q := *rs.machineTable()
 	q = q.Filter(map[string]any{
 		"allocation":  nil,
@@ -421,7 +466,7 @@ func (rs *RethinkStore) FindWaitingMachine(ctx context.Context, projectid, parti
 	})
 
 	if err := rs.sharedMutex.lock(ctx, partitionid, 10*time.Second); err != nil {
-		return nil, fmt.Errorf("too many parallel machine allocations taking place, try again later")
+		return nil, ErrTooManyParallelAllocations
 	}
 	defer rs.sharedMutex.unlock(ctx, partitionid)
 
@@ -431,41 +476,179 @@ func (rs *RethinkStore) FindWaitingMachine(ctx context.Context, projectid, parti
 		return nil, err
 	}
 
-	ecs, err := rs.ListProvisioningEventContainers()
+	return preallocateWaitingMachine(rs.log, rs, candidates, projectid, partitionid, size, placement, role)
+}
+
+// preallocateWaitingMachine elects one of the given waiting candidates for the allocation and marks it as preallocated.
+// candidates are expected to be available, not allocated, waiting and not yet preallocated machines of the given size
+// within the given partition. the caller is responsible for preventing parallel allocations in the partition.
+func preallocateWaitingMachine(log *slog.Logger, ds Store, candidates metal.Machines, projectid, partitionid string, size metal.Size, placement metal.Placement, role metal.Role) (*metal.Machine, error) {
+	var stages MachineCandidateStages
+	electable, err := electWaitingMachines(log, ds, candidates, projectid, partitionid, size, placement, role, &stages)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(electable) == 0 {
+		if stages.dropped(sizeReservationsStage) {
+			return nil, ErrNoMachineAvailableForProject
+		}
+		return nil, ErrNoMachineAvailable
+	}
+
+	oldMachine := electable[randomIndex(len(electable))]
+	newMachine := oldMachine
+	newMachine.PreAllocated = true
+
+	err = ds.UpdateMachine(&oldMachine, &newMachine)
+	if err != nil {
+		return nil, err
+	}
+
+	return &newMachine, nil
+}
+
+// sizeReservationsStage is the filter stage of the machine candidate election which drops the candidates that are reserved
+// for other projects.
+const sizeReservationsStage = "size-reservations"
+
+// MachineCandidateStage is the result of a filter stage of the machine candidate election.
+type MachineCandidateStage struct {
+	Name      string
+	Remaining int
+	Dropped   []DroppedMachineCandidate
+}
+
+// DroppedMachineCandidate is a machine which was dropped by a filter stage of the machine candidate election.
+type DroppedMachineCandidate struct {
+	MachineID string
+	Reason    string
+}
+
+// MachineCandidateStages records the filter stages of the machine candidate election.
+type MachineCandidateStages []MachineCandidateStage
+
+// dropped returns true if the stage with the given name dropped any machine candidates.
+func (ss MachineCandidateStages) dropped(name string) bool {
+	for _, s := range ss {
+		if s.Name == name && len(s.Dropped) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// filter returns the machines which are kept by the given function, the stage is only recorded for non-nil stages.
+func (ss *MachineCandidateStages) filter(name string, ms metal.Machines, keep func(m *metal.Machine) (bool, string)) metal.Machines {
+	var (
+		res   metal.Machines
+		stage = MachineCandidateStage{Name: name}
+	)
+
+	for i := range ms {
+		ok, reason := keep(&ms[i])
+		if !ok {
+			stage.Dropped = append(stage.Dropped, DroppedMachineCandidate{MachineID: ms[i].ID, Reason: reason})
+			continue
+		}
+		res = append(res, ms[i])
+	}
+
+	if ss != nil {
+		stage.Remaining = len(res)
+		*ss = append(*ss, stage)
+	}
+
+	return res
+}
+
+// ExplainWaitingMachine runs the machine candidate election for a machine of the given size in the given partition
+// without preallocating a machine. it returns the filter stages and the machines which could be elected.
+func ExplainWaitingMachine(log *slog.Logger, ds Store, projectid, partitionid string, size metal.Size, placement metal.Placement, role metal.Role) (MachineCandidateStages, metal.Machines, error) {
+	var machines metal.Machines
+	err := ds.SearchMachines(&MachineSearchQuery{
+		PartitionID: &partitionid,
+		SizeID:      &size.ID,
+	}, &machines)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	stages := MachineCandidateStages{{Name: "partition-and-size", Remaining: len(machines)}}
+
+	candidates := stages.filter("unallocated", machines, func(m *metal.Machine) (bool, string) {
+		if m.Allocation != nil {
+			return false, fmt.Sprintf("machine is allocated by project %s", m.Allocation.Project)
+		}
+		return true, ""
+	})
+	candidates = stages.filter("state", candidates, func(m *metal.Machine) (bool, string) {
+		if m.State.Value != metal.AvailableState {
+			return false, fmt.Sprintf("machine state is %s: %s", m.State.Value, m.State.Description)
+		}
+		return true, ""
+	})
+	candidates = stages.filter("waiting", candidates, func(m *metal.Machine) (bool, string) {
+		return m.Waiting, "machine is not waiting for an allocation"
+	})
+	candidates = stages.filter("not-preallocated", candidates, func(m *metal.Machine) (bool, string) {
+		return !m.PreAllocated, "machine is preallocated by another allocation"
+	})
+
+	electable, err := electWaitingMachines(log, ds, candidates, projectid, partitionid, size, placement, role, &stages)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return stages, electable, nil
+}
+
+// electWaitingMachines returns the candidates which can be elected for the allocation, the filter stages are recorded
+// in the given stages if they are not nil.
+func electWaitingMachines(log *slog.Logger, ds Store, candidates metal.Machines, projectid, partitionid string, size metal.Size, placement metal.Placement, role metal.Role, stages *MachineCandidateStages) (metal.Machines, error) {
+	ecs, err := ds.ListProvisioningEventContainers()
 	if err != nil {
 		return nil, err
 	}
 	ecMap := ecs.ByID()
 
-	var available metal.Machines
-	for _, m := range candidates {
+	available := stages.filter("liveliness", candidates, func(m *metal.Machine) (bool, string) {
 		ec, ok := ecMap[m.ID]
 		if !ok {
-			rs.log.Error("cannot find machine provisioning event container", "machine", m, "error", err)
+			log.Error("cannot find machine provisioning event container", "machine", m)
 			// fall through, so the rest of the machines is getting evaluated
-			continue
+			return false, "machine has no provisioning event container"
 		}
 		if ec.Liveliness != metal.MachineLivelinessAlive {
-			continue
+			return false, fmt.Sprintf("machine liveliness is %s", ec.Liveliness)
 		}
-		available = append(available, m)
-	}
+		return true, ""
+	})
 
 	if len(available) == 0 {
-		return nil, errors.New("no machine available")
+		return nil, nil
 	}
 
+	partition, err := ds.FindPartition(partitionid)
+	if err != nil && !metal.IsNotFound(err) {
+		return nil, err
+	}
+	powerBudgetLimited := partition != nil && partition.RackPowerBudget.Limited()
+
+	// the rack power budget accounts for the machines of all sizes, otherwise only the machines with the size of the allocation are needed
+	q := &MachineSearchQuery{PartitionID: &partitionid}
+	if !powerBudgetLimited {
+		q.SizeID = &size.ID
+	}
 	var partitionMachines metal.Machines
-	err = rs.SearchMachines(&MachineSearchQuery{
-		PartitionID: &partitionid,
-		SizeID:      &size.ID,
-	}, &partitionMachines)
+	err = ds.SearchMachines(q, &partitionMachines)
 	if err != nil {
 		return nil, err
 	}
+	sizeMachines := partitionMachines.WithSize(size.ID)
 
 	var reservations metal.SizeReservations
-	err = rs.SearchSizeReservations(&SizeReservationSearchQuery{
+	err = ds.SearchSizeReservations(&SizeReservationSearchQuery{
 		Partition: &partitionid,
 		SizeID:    &size.ID,
 	}, &reservations)
@@ -473,33 +656,109 @@ func (rs *RethinkStore) FindWaitingMachine(ctx context.Context, projectid, parti
 		return nil, err
 	}
 
-	ok := checkSizeReservations(available, projectid, partitionMachines.ByProjectID(), reservations)
-	if !ok {
-		return nil, errors.New("no machine available")
-	}
+	reservable := checkSizeReservations(available, projectid, sizeMachines.ByProjectID(), reservations, time.Now())
+	available = stages.filter(sizeReservationsStage, available, func(m *metal.Machine) (bool, string) {
+		return reservable, "the remaining machines are reserved for other projects by size reservations"
+	})
 
-	projectMachines := partitionMachines.WithRole(role).ByProjectID()[projectid]
+	if len(available) == 0 {
+		return nil, nil
+	}
 
-	spreadCandidates := spreadAcrossRacks(available, projectMachines, placementTags)
-	if len(spreadCandidates) == 0 {
-		return nil, errors.New("no machine available")
+	if powerBudgetLimited {
+		available, err = filterRackPowerBudget(ds, available, partitionMachines, partition, size, stages)
+		if err != nil {
+			return nil, err
+		}
 	}
 
-	oldMachine := spreadCandidates[randomIndex(len(spreadCandidates))]
-	newMachine := oldMachine
-	newMachine.PreAllocated = true
+	if len(available) == 0 {
+		return nil, nil
+	}
 
-	err = rs.updateEntity(rs.machineTable(), &newMachine, &oldMachine)
+	strategyType := placement.StrategyOf(partition)
+	strategy, err := NewPlacementStrategy(strategyType)
 	if err != nil {
 		return nil, err
 	}
 
-	return &newMachine, nil
+	pc := &PlacementContext{
+		ProjectID:         projectid,
+		Role:              role,
+		Placement:         placement,
+		SpreadLevel:       placement.SpreadLevelOf(partition),
+		PartitionMachines: sizeMachines,
+		EventContainers:   ecMap,
+	}
+	if strategyType == metal.PlacementStrategySwitchAffinity {
+		err = ds.SearchSwitches(&SwitchSearchQuery{PartitionID: &partitionid}, &pc.Switches)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if pc.SpreadLevel != metal.LocationLevelRack {
+		locations, err := ds.ListLocations()
+		if err != nil {
+			return nil, err
+		}
+		pc.Locations = locations.ByID()
+	}
+
+	return stages.filter(string(strategyType), available, strategy.Prefer(available, pc)), nil
+}
+
+// filterRackPowerBudget drops the candidates in racks whose estimated power consumption would exceed the rack power budget
+// of the partition if the candidate was allocated. partitionMachines must contain the machines of all sizes in the partition.
+func filterRackPowerBudget(ds Store, candidates, partitionMachines metal.Machines, p *metal.Partition, size metal.Size, stages *MachineCandidateStages) (metal.Machines, error) {
+	racks := map[string]bool{}
+	for _, m := range candidates {
+		racks[m.RackID] = true
+	}
+
+	var machines metal.Machines
+	sizes := metal.SizeMap{size.ID: size}
+	for _, m := range partitionMachines {
+		if !racks[m.RackID] {
+			continue
+		}
+		machines = append(machines, m)
+
+		if _, ok := sizes[m.SizeID]; ok {
+			continue
+		}
+		s, err := ds.FindSize(m.SizeID)
+		switch {
+		case err == nil:
+			sizes[s.ID] = *s
+		case metal.IsNotFound(err):
+			// machines of unknown sizes do not account for an expected power draw
+			sizes[m.SizeID] = metal.Size{}
+		default:
+			return nil, err
+		}
+	}
+
+	consumption := metal.RackPowerConsumption(machines, sizes)
+
+	return stages.filter("rack-power-budget", candidates, func(m *metal.Machine) (bool, string) {
+		budget, ok := p.RackPowerBudget.Watts(m.RackID)
+		if !ok {
+			return true, ""
+		}
+
+		estimated := consumption[m.RackID] + m.AllocationPowerDraw(size.ExpectedPowerWatts)
+		if estimated > float64(budget) {
+			return false, fmt.Sprintf("rack %q would consume %.0fW which exceeds its power budget of %dW", m.RackID, estimated, budget)
+		}
+		return true, ""
+	}), nil
 }
 
 // checkSizeReservations returns true when an allocation is possible and
-// false when size reservations prevent the allocation for the given project in the given partition
-func checkSizeReservations(available metal.Machines, projectid string, machinesByProject map[string]metal.Machines, reservations metal.SizeReservations) bool {
+// false when size reservations prevent the allocation for the given project in the given partition.
+// only the reservations which are active at the given point in time are honored.
+func checkSizeReservations(available metal.Machines, projectid string, machinesByProject map[string]metal.Machines, reservations metal.SizeReservations, now time.Time) bool {
+	reservations = reservations.Active(now)
 	if len(reservations) == 0 {
 		return true
 	}
@@ -527,14 +786,19 @@ func checkSizeReservations(available metal.Machines, projectid string, machinesB
 }
 
 func spreadAcrossRacks(allMachines, projectMachines metal.Machines, tags []string) metal.Machines {
+	return spreadAcrossDomains(allMachines, projectMachines, tags, rackOf)
+}
+
+// spreadAcrossDomains returns the machines within the failure domains which are least occupied by the project and the tags.
+func spreadAcrossDomains(allMachines, projectMachines metal.Machines, tags []string, domain func(m *metal.Machine) string) metal.Machines {
 	var (
-		allRacks = groupByRack(allMachines)
+		allRacks = groupBy(allMachines, domain)
 
-		projectRacks                = groupByRack(projectMachines)
+		projectRacks                = groupBy(projectMachines, domain)
 		leastOccupiedByProjectRacks = electRacks(allRacks, projectRacks)
 
 		taggedMachines           = groupByTags(projectMachines).filter(tags...).getMachines()
-		taggedRacks              = groupByRack(taggedMachines)
+		taggedRacks              = groupBy(taggedMachines, domain)
 		leastOccupiedByTagsRacks = electRacks(allRacks, taggedRacks)
 
 		intersection = intersect(leastOccupiedByTagsRacks, leastOccupiedByProjectRacks)
@@ -573,13 +837,22 @@ func (g groupedMachines) filter(keys ...string) groupedMachines {
 }
 
 func groupByRack(machines metal.Machines) groupedMachines {
-	racks := make(groupedMachines)
+	return groupBy(machines, rackOf)
+}
 
-	for _, m := range machines {
-		racks[m.RackID] = append(racks[m.RackID], m)
+func rackOf(m *metal.Machine) string {
+	return m.RackID
+}
+
+func groupBy(machines metal.Machines, key func(m *metal.Machine) string) groupedMachines {
+	groups := make(groupedMachines)
+
+	for i := range machines {
+		k := key(&machines[i])
+		groups[k] = append(groups[k], machines[i])
 	}
 
-	return racks
+	return groups
 }
 
 // electRacks returns the least occupied racks from all racks
@@ -0,0 +1,67 @@
+package datastore
+
+import (
+	"github.com/metal-stack/metal-api/cmd/metal-api/internal/metal"
+	r "gopkg.in/rethinkdb/rethinkdb-go.v6"
+)
+
+// MachineCommandExecutionSearchQuery can be used to search machine command executions.
+type MachineCommandExecutionSearchQuery struct {
+	ID        *string `json:"id" optional:"true"`
+	MachineID *string `json:"machineid" optional:"true"`
+	Status    *string `json:"status" optional:"true"`
+}
+
+func (p *MachineCommandExecutionSearchQuery) generateTerm(rs *RethinkStore) *r.Term {
+	q := *rs.machineCommandTable()
+
+	if p.ID != nil {
+		q = q.Filter(func(row r.Term) r.Term {
+			return row.Field("id").Eq(*p.ID)
+		})
+	}
+
+	if p.MachineID != nil {
+		q = q.Filter(func(row r.Term) r.Term {
+			return row.Field("machineid").Eq(*p.MachineID)
+		})
+	}
+
+	if p.Status != nil {
+		q = q.Filter(func(row r.Term) r.Term {
+			return row.Field("status").Eq(*p.Status)
+		})
+	}
+
+	return &q
+}
+
+// FindMachineCommandExecution returns the machine command execution for the given id.
+func (rs *RethinkStore) FindMachineCommandExecution(id string) (*metal.MachineCommandExecution, error) {
+	var e metal.MachineCommandExecution
+	err := rs.findEntityByID(rs.machineCommandTable(), &e, id)
+	if err != nil {
+		return nil, err
+	}
+	return &e, nil
+}
+
+// SearchMachineCommandExecutions returns the result of the machine command executions search request query.
+func (rs *RethinkStore) SearchMachineCommandExecutions(q *MachineCommandExecutionSearchQuery, es *metal.MachineCommandExecutions) error {
+	return rs.searchEntities(q.generateTerm(rs), es)
+}
+
+// CreateMachineCommandExecution creates a new machine command execution.
+func (rs *RethinkStore) CreateMachineCommandExecution(e *metal.MachineCommandExecution) error {
+	return rs.createEntity(rs.machineCommandTable(), e)
+}
+
+// DeleteMachineCommandExecution deletes a machine command execution.
+func (rs *RethinkStore) DeleteMachineCommandExecution(e *metal.MachineCommandExecution) error {
+	return rs.deleteEntity(rs.machineCommandTable(), e)
+}
+
+// UpdateMachineCommandExecution updates a machine command execution, it fails with a conflict if it was changed in the meantime.
+func (rs *RethinkStore) UpdateMachineCommandExecution(oldExecution *metal.MachineCommandExecution, newExecution *metal.MachineCommandExecution) error {
+	return rs.updateEntity(rs.machineCommandTable(), newExecution, oldExecution)
+}
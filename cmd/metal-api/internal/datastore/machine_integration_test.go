@@ -16,19 +16,18 @@ import (
 	"github.com/metal-stack/metal-api/cmd/metal-api/internal/metal"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
-	r "gopkg.in/rethinkdb/rethinkdb-go.v6"
 )
 
 type machineTestable struct{}
 
 func (_ *machineTestable) wipe() error {
-	_, err := sharedDS.machineTable().Delete().RunWrite(sharedDS.session)
+	err := wipeTable(sharedDS, "machine")
 	return err
 }
 
 func (_ *machineTestable) create(m *metal.Machine) error { // nolint:unused
 	if m.Allocation != nil {
-		return sharedDS.createEntity(sharedDS.machineTable(), m)
+		return createEntity(sharedDS, "machine", m)
 	}
 	return sharedDS.CreateMachine(m)
 }
@@ -131,1044 +130,1106 @@ func (_ *machineTestable) defaultBody(m *metal.Machine) *metal.Machine {
 	return m
 }
 
-func TestRethinkStore_FindMachine(t *testing.T) {
-	tt := &machineTestable{}
-	defer func() {
-		require.NoError(t, tt.wipe())
-	}()
+func TestStore_FindMachine(t *testing.T) {
+	forEachStore(t, func(t *testing.T) {
+		tt := &machineTestable{}
+		defer func() {
+			require.NoError(t, tt.wipe())
+		}()
 
-	tests := []findTest[*metal.Machine, *MachineSearchQuery]{
-		{
-			name: "find",
-			id:   "2",
+		tests := []findTest[*metal.Machine, *MachineSearchQuery]{
+			{
+				name: "find",
+				id:   "2",
 
-			mock: []*metal.Machine{
-				{Base: metal.Base{ID: "1"}},
-				{Base: metal.Base{ID: "2"}},
-				{Base: metal.Base{ID: "3"}},
+				mock: []*metal.Machine{
+					{Base: metal.Base{ID: "1"}},
+					{Base: metal.Base{ID: "2"}},
+					{Base: metal.Base{ID: "3"}},
+				},
+				want:    tt.defaultBody(&metal.Machine{Base: metal.Base{ID: "2"}}),
+				wantErr: nil,
 			},
-			want:    tt.defaultBody(&metal.Machine{Base: metal.Base{ID: "2"}}),
-			wantErr: nil,
-		},
-		{
-			name:    "not found",
-			id:      "4",
-			want:    nil,
-			wantErr: metal.NotFound(`no machine with id "4" found`),
-		},
-	}
-	for i := range tests {
-		tests[i].run(t, tt)
-	}
+			{
+				name:    "not found",
+				id:      "4",
+				want:    nil,
+				wantErr: metal.NotFound(`no machine with id "4" found`),
+			},
+		}
+		for i := range tests {
+			tests[i].run(t, tt)
+		}
+	})
 }
 
-func TestRethinkStore_SearchMachines(t *testing.T) {
-	tt := &machineTestable{}
-	defer func() {
-		require.NoError(t, tt.wipe())
-	}()
+func TestStore_SearchMachines(t *testing.T) {
+	forEachStore(t, func(t *testing.T) {
+		tt := &machineTestable{}
+		defer func() {
+			require.NoError(t, tt.wipe())
+		}()
 
-	tests := []searchTest[*metal.Machine, *MachineSearchQuery]{
-		{
-			name: "empty result",
-			q: &MachineSearchQuery{
-				ID: new("2"),
-			},
-			mock: []*metal.Machine{
-				{Base: metal.Base{ID: "1"}},
-			},
-			want:    nil,
-			wantErr: nil,
-		},
-		{
-			name: "search by id",
-			q: &MachineSearchQuery{
-				ID: new("2"),
-			},
-			mock: []*metal.Machine{
-				{Base: metal.Base{ID: "1"}},
-				{Base: metal.Base{ID: "2"}},
-				{Base: metal.Base{ID: "3"}},
-			},
-			want: []*metal.Machine{
-				tt.defaultBody(&metal.Machine{Base: metal.Base{ID: "2"}}),
-			},
-			wantErr: nil,
-		},
-		{
-			name: "search by name",
-			q: &MachineSearchQuery{
-				Name: new("b"),
-			},
-			mock: []*metal.Machine{
-				{Base: metal.Base{ID: "1", Name: "a"}},
-				{Base: metal.Base{ID: "2", Name: "b"}},
-				{Base: metal.Base{ID: "3", Name: "c"}},
-			},
-			want: []*metal.Machine{
-				tt.defaultBody(&metal.Machine{Base: metal.Base{ID: "2", Name: "b"}}),
-			},
-			wantErr: nil,
-		},
-		{
-			name: "search by partition",
-			q: &MachineSearchQuery{
-				PartitionID: new("b"),
-			},
-			mock: []*metal.Machine{
-				{Base: metal.Base{ID: "1"}, PartitionID: "a"},
-				{Base: metal.Base{ID: "2"}, PartitionID: "b"},
-				{Base: metal.Base{ID: "3"}, PartitionID: "c"},
-			},
-			want: []*metal.Machine{
-				tt.defaultBody(&metal.Machine{Base: metal.Base{ID: "2"}, PartitionID: "b"}),
-			},
-			wantErr: nil,
-		},
-		{
-			name: "search by size",
-			q: &MachineSearchQuery{
-				SizeID: new("b"),
-			},
-			mock: []*metal.Machine{
-				{Base: metal.Base{ID: "1"}, SizeID: "a"},
-				{Base: metal.Base{ID: "2"}, SizeID: "b"},
-				{Base: metal.Base{ID: "3"}, SizeID: "b"},
-			},
-			want: []*metal.Machine{
-				tt.defaultBody(&metal.Machine{Base: metal.Base{ID: "2"}, SizeID: "b"}),
-				tt.defaultBody(&metal.Machine{Base: metal.Base{ID: "3"}, SizeID: "b"}),
-			},
-			wantErr: nil,
-		},
-		{
-			name: "search by rack",
-			q: &MachineSearchQuery{
-				RackID: new("b"),
-			},
-			mock: []*metal.Machine{
-				{Base: metal.Base{ID: "1"}, RackID: "a"},
-				{Base: metal.Base{ID: "2"}, RackID: "b"},
-				{Base: metal.Base{ID: "3"}, RackID: "b"},
-			},
-			want: []*metal.Machine{
-				tt.defaultBody(&metal.Machine{Base: metal.Base{ID: "2"}, RackID: "b"}),
-				tt.defaultBody(&metal.Machine{Base: metal.Base{ID: "3"}, RackID: "b"}),
-			},
-			wantErr: nil,
-		},
-		{
-			name: "search by tags",
-			q: &MachineSearchQuery{
-				Tags: []string{"a=b"},
-			},
-			mock: []*metal.Machine{
-				{Base: metal.Base{ID: "1"}, Tags: []string{}},
-				{Base: metal.Base{ID: "2"}, Tags: []string{"a=b"}},
-				{Base: metal.Base{ID: "3"}, Tags: []string{"b=c"}},
-			},
-			want: []*metal.Machine{
-				tt.defaultBody(&metal.Machine{Base: metal.Base{ID: "2"}, Tags: []string{"a=b"}}),
-			},
-			wantErr: nil,
-		},
-		{
-			name: "search by allocation name",
-			q: &MachineSearchQuery{
-				AllocationName: new("b-name"),
-			},
-			mock: []*metal.Machine{
-				{Base: metal.Base{ID: "1"}, Allocation: &metal.MachineAllocation{Name: "a-name"}},
-				{Base: metal.Base{ID: "2"}, Allocation: &metal.MachineAllocation{Name: "b-name"}},
-				{Base: metal.Base{ID: "3"}, Allocation: &metal.MachineAllocation{Name: "c-name"}},
-			},
-			want: []*metal.Machine{
-				tt.defaultBody(&metal.Machine{Base: metal.Base{ID: "2"}, Allocation: &metal.MachineAllocation{Name: "b-name"}}),
-			},
-			wantErr: nil,
-		},
-		{
-			name: "search by allocation project",
-			q: &MachineSearchQuery{
-				AllocationProject: new("a-project"),
-			},
-			mock: []*metal.Machine{
-				{Base: metal.Base{ID: "1"}, Allocation: &metal.MachineAllocation{Project: "a-project"}},
-				{Base: metal.Base{ID: "2"}, Allocation: &metal.MachineAllocation{Project: "b-project"}},
-				{Base: metal.Base{ID: "3"}, Allocation: &metal.MachineAllocation{Project: "c-project"}},
-			},
-			want: []*metal.Machine{
-				tt.defaultBody(&metal.Machine{Base: metal.Base{ID: "1"}, Allocation: &metal.MachineAllocation{Project: "a-project"}}),
-			},
-			wantErr: nil,
-		},
-		{
-			name: "search by allocation image id",
-			q: &MachineSearchQuery{
-				AllocationImageID: new("ubuntu"),
-			},
-			mock: []*metal.Machine{
-				{Base: metal.Base{ID: "1"}, Allocation: &metal.MachineAllocation{ImageID: "ubuntu"}},
-				{Base: metal.Base{ID: "2"}, Allocation: &metal.MachineAllocation{ImageID: "debian"}},
-				{Base: metal.Base{ID: "3"}, Allocation: &metal.MachineAllocation{ImageID: "ubuntu"}},
-			},
-			want: []*metal.Machine{
-				tt.defaultBody(&metal.Machine{Base: metal.Base{ID: "1"}, Allocation: &metal.MachineAllocation{ImageID: "ubuntu"}}),
-				tt.defaultBody(&metal.Machine{Base: metal.Base{ID: "3"}, Allocation: &metal.MachineAllocation{ImageID: "ubuntu"}}),
-			},
-			wantErr: nil,
-		},
-		{
-			name: "search by allocation hostname",
-			q: &MachineSearchQuery{
-				AllocationHostname: new("host-c"),
-			},
-			mock: []*metal.Machine{
-				{Base: metal.Base{ID: "1"}, Allocation: &metal.MachineAllocation{Hostname: "host-a"}},
-				{Base: metal.Base{ID: "2"}, Allocation: &metal.MachineAllocation{Hostname: "host-b"}},
-				{Base: metal.Base{ID: "3"}, Allocation: &metal.MachineAllocation{Hostname: "host-c"}},
-			},
-			want: []*metal.Machine{
-				tt.defaultBody(&metal.Machine{Base: metal.Base{ID: "3"}, Allocation: &metal.MachineAllocation{Hostname: "host-c"}}),
-			},
-			wantErr: nil,
-		},
-		{
-			name: "search by allocation role",
-			q: &MachineSearchQuery{
-				AllocationRole: new(metal.RoleMachine),
-			},
-			mock: []*metal.Machine{
-				{Base: metal.Base{ID: "1"}, Allocation: &metal.MachineAllocation{Role: metal.RoleFirewall}},
-				{Base: metal.Base{ID: "2"}, Allocation: &metal.MachineAllocation{Role: metal.RoleMachine}},
-				{Base: metal.Base{ID: "3"}},
-				{Base: metal.Base{ID: "4"}, Allocation: &metal.MachineAllocation{Role: metal.RoleFirewall}},
-				{Base: metal.Base{ID: "5"}, Allocation: &metal.MachineAllocation{Role: metal.RoleMachine}},
-			},
-			want: []*metal.Machine{
-				tt.defaultBody(&metal.Machine{Base: metal.Base{ID: "2"}, Allocation: &metal.MachineAllocation{Role: metal.RoleMachine}}),
-				tt.defaultBody(&metal.Machine{Base: metal.Base{ID: "5"}, Allocation: &metal.MachineAllocation{Role: metal.RoleMachine}}),
-			},
-			wantErr: nil,
-		},
-		{
-			name: "search by allocation succeeded",
-			q: &MachineSearchQuery{
-				AllocationSucceeded: new(true),
-			},
-			mock: []*metal.Machine{
-				{Base: metal.Base{ID: "1"}, Allocation: &metal.MachineAllocation{Succeeded: false}},
-				{Base: metal.Base{ID: "2"}, Allocation: &metal.MachineAllocation{Succeeded: true}},
-				{Base: metal.Base{ID: "3"}},
-			},
-			want: []*metal.Machine{
-				tt.defaultBody(&metal.Machine{Base: metal.Base{ID: "2"}, Allocation: &metal.MachineAllocation{Succeeded: true}}),
-			},
-			wantErr: nil,
-		},
-		{
-			name: "search by network ids",
-			q: &MachineSearchQuery{
-				NetworkIDs: []string{"internet", "private-tenant-a"},
-			},
-			mock: []*metal.Machine{
-				{Base: metal.Base{ID: "1"}},
-				{Base: metal.Base{ID: "2"}, Allocation: &metal.MachineAllocation{MachineNetworks: []*metal.MachineNetwork{{NetworkID: "private-tenant-a"}, {NetworkID: "internet"}}}},
-				{Base: metal.Base{ID: "3"}, Allocation: &metal.MachineAllocation{MachineNetworks: []*metal.MachineNetwork{{NetworkID: "internet"}}}},
-			},
-			want: []*metal.Machine{
-				tt.defaultBody(&metal.Machine{Base: metal.Base{ID: "2"}, Allocation: &metal.MachineAllocation{MachineNetworks: []*metal.MachineNetwork{{NetworkID: "private-tenant-a"}, {NetworkID: "internet"}}}}),
-			},
-			wantErr: nil,
-		},
-		{
-			name: "search by network prefixes",
-			q: &MachineSearchQuery{
-				NetworkPrefixes: []string{"192.168.1.0/24"},
-			},
-			mock: []*metal.Machine{
-				{Base: metal.Base{ID: "1"}},
-				{Base: metal.Base{ID: "2"}, Allocation: &metal.MachineAllocation{MachineNetworks: []*metal.MachineNetwork{{Prefixes: []string{"100.64.0.0/28"}}}}},
-				{Base: metal.Base{ID: "3"}, Allocation: &metal.MachineAllocation{MachineNetworks: []*metal.MachineNetwork{{Prefixes: []string{"100.64.0.0/28"}}, {Prefixes: []string{"192.168.1.0/24"}}}}},
-			},
-			want: []*metal.Machine{
-				tt.defaultBody(&metal.Machine{Base: metal.Base{ID: "3"}, Allocation: &metal.MachineAllocation{MachineNetworks: []*metal.MachineNetwork{{Prefixes: []string{"100.64.0.0/28"}}, {Prefixes: []string{"192.168.1.0/24"}}}}}),
-			},
-			wantErr: nil,
-		},
-		{
-			name: "search by network prefixes 2",
-			q: &MachineSearchQuery{
-				NetworkPrefixes: []string{"100.64.0.0/28"},
-			},
-			mock: []*metal.Machine{
-				{Base: metal.Base{ID: "1"}},
-				{Base: metal.Base{ID: "2"}, Allocation: &metal.MachineAllocation{MachineNetworks: []*metal.MachineNetwork{{Prefixes: []string{"100.64.0.0/28"}}}}},
-				{Base: metal.Base{ID: "3"}, Allocation: &metal.MachineAllocation{MachineNetworks: []*metal.MachineNetwork{{Prefixes: []string{"100.64.0.0/28"}}, {Prefixes: []string{"192.168.1.0/24"}}}}},
-			},
-			want: []*metal.Machine{
-				tt.defaultBody(&metal.Machine{Base: metal.Base{ID: "2"}, Allocation: &metal.MachineAllocation{MachineNetworks: []*metal.MachineNetwork{{Prefixes: []string{"100.64.0.0/28"}}}}}),
-				tt.defaultBody(&metal.Machine{Base: metal.Base{ID: "3"}, Allocation: &metal.MachineAllocation{MachineNetworks: []*metal.MachineNetwork{{Prefixes: []string{"100.64.0.0/28"}}, {Prefixes: []string{"192.168.1.0/24"}}}}}),
-			},
-			wantErr: nil,
-		},
-		{
-			name: "search by network ips",
-			q: &MachineSearchQuery{
-				NetworkIPs: []string{"192.168.1.3"},
-			},
-			mock: []*metal.Machine{
-				{Base: metal.Base{ID: "1"}},
-				{Base: metal.Base{ID: "2"}, Allocation: &metal.MachineAllocation{MachineNetworks: []*metal.MachineNetwork{{IPs: []string{"192.168.1.0"}}}}},
-				{Base: metal.Base{ID: "3"}, Allocation: &metal.MachineAllocation{MachineNetworks: []*metal.MachineNetwork{{IPs: []string{"192.168.1.1"}}, {IPs: []string{"192.168.1.2", "192.168.1.3"}}}}},
-			},
-			want: []*metal.Machine{
-				tt.defaultBody(&metal.Machine{Base: metal.Base{ID: "3"}, Allocation: &metal.MachineAllocation{MachineNetworks: []*metal.MachineNetwork{{IPs: []string{"192.168.1.1"}}, {IPs: []string{"192.168.1.2", "192.168.1.3"}}}}}),
-			},
-			wantErr: nil,
-		},
-		{
-			name: "search by network destination prefixes",
-			q: &MachineSearchQuery{
-				NetworkDestinationPrefixes: []string{"0.0.0.0/0"},
-			},
-			mock: []*metal.Machine{
-				{Base: metal.Base{ID: "1"}},
-				{Base: metal.Base{ID: "2"}, Allocation: &metal.MachineAllocation{MachineNetworks: []*metal.MachineNetwork{{DestinationPrefixes: []string{"192.168.1.0/24"}}}}},
-				{Base: metal.Base{ID: "3"}, Allocation: &metal.MachineAllocation{MachineNetworks: []*metal.MachineNetwork{{DestinationPrefixes: []string{"0.0.0.0/0"}}, {DestinationPrefixes: []string{"192.168.1.0/24", "0.0.0.0/0"}}}}},
-			},
-			want: []*metal.Machine{
-				tt.defaultBody(&metal.Machine{Base: metal.Base{ID: "3"}, Allocation: &metal.MachineAllocation{MachineNetworks: []*metal.MachineNetwork{{DestinationPrefixes: []string{"0.0.0.0/0"}}, {DestinationPrefixes: []string{"192.168.1.0/24", "0.0.0.0/0"}}}}}),
-			},
-			wantErr: nil,
-		},
-		{
-			name: "search by network vrf",
-			q: &MachineSearchQuery{
-				NetworkVrfs: []int64{2},
-			},
-			mock: []*metal.Machine{
-				{Base: metal.Base{ID: "1"}},
-				{Base: metal.Base{ID: "2"}, Allocation: &metal.MachineAllocation{MachineNetworks: []*metal.MachineNetwork{{Vrf: 0}}}},
-				{Base: metal.Base{ID: "3"}, Allocation: &metal.MachineAllocation{MachineNetworks: []*metal.MachineNetwork{{Vrf: 1}, {Vrf: 2}}}},
-			},
-			want: []*metal.Machine{
-				tt.defaultBody(&metal.Machine{Base: metal.Base{ID: "3"}, Allocation: &metal.MachineAllocation{MachineNetworks: []*metal.MachineNetwork{{Vrf: 1}, {Vrf: 2}}}}),
-			},
-			wantErr: nil,
-		},
-		{
-			name: "search by network asn",
-			q: &MachineSearchQuery{
-				NetworkASNs: []int64{42000, 42001},
-			},
-			mock: []*metal.Machine{
-				{Base: metal.Base{ID: "1"}},
-				{Base: metal.Base{ID: "2"}, Allocation: &metal.MachineAllocation{MachineNetworks: []*metal.MachineNetwork{{ASN: 42000}}}},
-				{Base: metal.Base{ID: "3"}, Allocation: &metal.MachineAllocation{MachineNetworks: []*metal.MachineNetwork{{ASN: 42000}, {ASN: 42001}}}},
-			},
-			want: []*metal.Machine{
-				tt.defaultBody(&metal.Machine{Base: metal.Base{ID: "3"}, Allocation: &metal.MachineAllocation{MachineNetworks: []*metal.MachineNetwork{{ASN: 42000}, {ASN: 42001}}}}),
-			},
-			wantErr: nil,
-		},
-		{
-			name: "search by hardware memory",
-			q: &MachineSearchQuery{
-				HardwareMemory: new(int64(1000)),
-			},
-			mock: []*metal.Machine{
-				{Base: metal.Base{ID: "1"}, Hardware: metal.MachineHardware{Memory: 1000}},
-				{Base: metal.Base{ID: "2"}, Hardware: metal.MachineHardware{Memory: 5000}},
-				{Base: metal.Base{ID: "3"}, Hardware: metal.MachineHardware{Memory: 1000}},
-			},
-			want: []*metal.Machine{
-				tt.defaultBody(&metal.Machine{Base: metal.Base{ID: "1"}, Hardware: metal.MachineHardware{Memory: 1000}}),
-				tt.defaultBody(&metal.Machine{Base: metal.Base{ID: "3"}, Hardware: metal.MachineHardware{Memory: 1000}}),
-			},
-			wantErr: nil,
-		},
-		{
-			name: "search by nic mac address",
-			q: &MachineSearchQuery{
-				NicsMacAddresses: []string{"mac-c"},
-			},
-			mock: []*metal.Machine{
-				{Base: metal.Base{ID: "1"}, Hardware: metal.MachineHardware{Nics: metal.Nics{{MacAddress: "mac-a"}}}},
-				{Base: metal.Base{ID: "2"}, Hardware: metal.MachineHardware{Nics: metal.Nics{{MacAddress: "mac-b"}, {MacAddress: "mac-c"}}}},
-				{Base: metal.Base{ID: "3"}, Hardware: metal.MachineHardware{Nics: metal.Nics{{MacAddress: "mac-d"}}}},
-			},
-			want: []*metal.Machine{
-				tt.defaultBody(&metal.Machine{Base: metal.Base{ID: "2"}, Hardware: metal.MachineHardware{Nics: metal.Nics{{MacAddress: "mac-b"}, {MacAddress: "mac-c"}}}}),
-			},
-			wantErr: nil,
-		},
-		{
-			name: "search by nic name",
-			q: &MachineSearchQuery{
-				NicsNames: []string{"nic-2"},
-			},
-			mock: []*metal.Machine{
-				{Base: metal.Base{ID: "1"}, Hardware: metal.MachineHardware{Nics: metal.Nics{{Name: "nic-1"}}}},
-				{Base: metal.Base{ID: "2"}, Hardware: metal.MachineHardware{Nics: metal.Nics{{Name: "nic-2"}, {Name: "nic-3"}}}},
-				{Base: metal.Base{ID: "3"}, Hardware: metal.MachineHardware{Nics: metal.Nics{{Name: "nic-4"}}}},
-			},
-			want: []*metal.Machine{
-				tt.defaultBody(&metal.Machine{Base: metal.Base{ID: "2"}, Hardware: metal.MachineHardware{Nics: metal.Nics{{Name: "nic-2"}, {Name: "nic-3"}}}}),
-			},
-			wantErr: nil,
-		},
-		{
-			name: "search by nic vrf",
-			q: &MachineSearchQuery{
-				NicsVrfs: []string{"vrf10"},
-			},
-			mock: []*metal.Machine{
-				{Base: metal.Base{ID: "1"}, Hardware: metal.MachineHardware{Nics: metal.Nics{{Vrf: "vrf1"}}}},
-				{Base: metal.Base{ID: "2"}, Hardware: metal.MachineHardware{Nics: metal.Nics{{Vrf: "vrf10"}, {Name: "vrf11"}}}},
-				{Base: metal.Base{ID: "3"}, Hardware: metal.MachineHardware{Nics: metal.Nics{{Vrf: ""}}}},
-			},
-			want: []*metal.Machine{
-				tt.defaultBody(&metal.Machine{Base: metal.Base{ID: "2"}, Hardware: metal.MachineHardware{Nics: metal.Nics{{Vrf: "vrf10"}, {Name: "vrf11"}}}}),
-			},
-			wantErr: nil,
-		},
-		{
-			name: "search by nic neighbor mac address",
-			q: &MachineSearchQuery{
-				NicsNeighborMacAddresses: []string{"mac-c"},
-			},
-			mock: []*metal.Machine{
-				{Base: metal.Base{ID: "1"}, Hardware: metal.MachineHardware{Nics: metal.Nics{{Neighbors: metal.Nics{{MacAddress: "mac-a"}}}}}},
-				{Base: metal.Base{ID: "2"}, Hardware: metal.MachineHardware{Nics: metal.Nics{{Neighbors: metal.Nics{{MacAddress: "mac-b"}, {MacAddress: "mac-c"}}}}}},
-				{Base: metal.Base{ID: "3"}, Hardware: metal.MachineHardware{Nics: metal.Nics{{Neighbors: metal.Nics{{MacAddress: "mac-d"}}}}}},
-			},
-			want: []*metal.Machine{
-				tt.defaultBody(&metal.Machine{Base: metal.Base{ID: "2"}, Hardware: metal.MachineHardware{Nics: metal.Nics{{Neighbors: metal.Nics{{MacAddress: "mac-b"}, {MacAddress: "mac-c"}}}}}}),
-			},
-			wantErr: nil,
-		},
-		{
-			name: "search by nic neighbor name",
-			q: &MachineSearchQuery{
-				NicsNeighborNames: []string{"nic-2"},
-			},
-			mock: []*metal.Machine{
-				{Base: metal.Base{ID: "1"}, Hardware: metal.MachineHardware{Nics: metal.Nics{{Neighbors: metal.Nics{{Name: "nic-1"}}}}}},
-				{Base: metal.Base{ID: "2"}, Hardware: metal.MachineHardware{Nics: metal.Nics{{Neighbors: metal.Nics{{Name: "nic-2"}, {Name: "nic-3"}}}}}},
-				{Base: metal.Base{ID: "3"}, Hardware: metal.MachineHardware{Nics: metal.Nics{{Neighbors: metal.Nics{{Name: "nic-4"}}}}}},
-			},
-			want: []*metal.Machine{
-				tt.defaultBody(&metal.Machine{Base: metal.Base{ID: "2"}, Hardware: metal.MachineHardware{Nics: metal.Nics{{Neighbors: metal.Nics{{Name: "nic-2"}, {Name: "nic-3"}}}}}}),
-			},
-			wantErr: nil,
-		},
-		{
-			name: "search by nic neighbor vrf",
-			q: &MachineSearchQuery{
-				NicsNeighborVrfs: []string{"vrf10"},
-			},
-			mock: []*metal.Machine{
-				{Base: metal.Base{ID: "1"}, Hardware: metal.MachineHardware{Nics: metal.Nics{{Neighbors: metal.Nics{{Vrf: "vrf1"}}}}}},
-				{Base: metal.Base{ID: "2"}, Hardware: metal.MachineHardware{Nics: metal.Nics{{Neighbors: metal.Nics{{Vrf: "vrf10"}, {Vrf: "vrf11"}}}}}},
-				{Base: metal.Base{ID: "3"}},
-			},
-			want: []*metal.Machine{
-				tt.defaultBody(&metal.Machine{Base: metal.Base{ID: "2"}, Hardware: metal.MachineHardware{Nics: metal.Nics{{Neighbors: metal.Nics{{Vrf: "vrf10"}, {Vrf: "vrf11"}}}}}}),
-			},
-			wantErr: nil,
-		},
-		{
-			name: "search by disk name",
-			q: &MachineSearchQuery{
-				DiskNames: []string{"/dev/nvme0n1"},
-			},
-			mock: []*metal.Machine{
-				{Base: metal.Base{ID: "1"}, Hardware: metal.MachineHardware{Disks: []metal.BlockDevice{{Name: "/dev/sda"}}}},
-				{Base: metal.Base{ID: "2"}, Hardware: metal.MachineHardware{Disks: []metal.BlockDevice{{Name: "/dev/nvme0n1"}, {Name: "/dev/nvme0n2"}}}},
-				{Base: metal.Base{ID: "3"}, Hardware: metal.MachineHardware{Disks: []metal.BlockDevice{{Name: "/dev/nvme0n1"}, {Name: "/dev/nvme0n2"}}}},
-			},
-			want: []*metal.Machine{
-				tt.defaultBody(&metal.Machine{Base: metal.Base{ID: "2"}, Hardware: metal.MachineHardware{Disks: []metal.BlockDevice{{Name: "/dev/nvme0n1"}, {Name: "/dev/nvme0n2"}}}}),
-				tt.defaultBody(&metal.Machine{Base: metal.Base{ID: "3"}, Hardware: metal.MachineHardware{Disks: []metal.BlockDevice{{Name: "/dev/nvme0n1"}, {Name: "/dev/nvme0n2"}}}}),
-			},
-			wantErr: nil,
-		},
-		{
-			name: "search by disk size",
-			q: &MachineSearchQuery{
-				DiskSizes: []int64{1000},
-			},
-			mock: []*metal.Machine{
-				{Base: metal.Base{ID: "1"}, Hardware: metal.MachineHardware{Disks: []metal.BlockDevice{{Size: 500}}}},
-				{Base: metal.Base{ID: "2"}, Hardware: metal.MachineHardware{Disks: []metal.BlockDevice{{Size: 500}, {Size: 1000}}}},
-				{Base: metal.Base{ID: "3"}, Hardware: metal.MachineHardware{Disks: []metal.BlockDevice{{Size: 500}, {Size: 1000}}}},
-			},
-			want: []*metal.Machine{
-				tt.defaultBody(&metal.Machine{Base: metal.Base{ID: "2"}, Hardware: metal.MachineHardware{Disks: []metal.BlockDevice{{Size: 500}, {Size: 1000}}}}),
-				tt.defaultBody(&metal.Machine{Base: metal.Base{ID: "3"}, Hardware: metal.MachineHardware{Disks: []metal.BlockDevice{{Size: 500}, {Size: 1000}}}}),
+		tests := []searchTest[*metal.Machine, *MachineSearchQuery]{
+			{
+				name: "empty result",
+				q: &MachineSearchQuery{
+					ID: new("2"),
+				},
+				mock: []*metal.Machine{
+					{Base: metal.Base{ID: "1"}},
+				},
+				want:    nil,
+				wantErr: nil,
 			},
-			wantErr: nil,
-		},
-		{
-			name: "search by state value tainted",
-			q: &MachineSearchQuery{
-				StateValue: new(string(metal.TaintedState)),
+			{
+				name: "search by id",
+				q: &MachineSearchQuery{
+					ID: new("2"),
+				},
+				mock: []*metal.Machine{
+					{Base: metal.Base{ID: "1"}},
+					{Base: metal.Base{ID: "2"}},
+					{Base: metal.Base{ID: "3"}},
+				},
+				want: []*metal.Machine{
+					tt.defaultBody(&metal.Machine{Base: metal.Base{ID: "2"}}),
+				},
+				wantErr: nil,
 			},
-			mock: []*metal.Machine{
-				{Base: metal.Base{ID: "1"}, State: metal.MachineState{Value: metal.AvailableState}},
-				{Base: metal.Base{ID: "2"}, State: metal.MachineState{Value: metal.TaintedState}},
-				{Base: metal.Base{ID: "3"}, State: metal.MachineState{Value: metal.AvailableState}},
-				{Base: metal.Base{ID: "4"}, State: metal.MachineState{Value: metal.LockedState}},
+			{
+				name: "search by name",
+				q: &MachineSearchQuery{
+					Name: new("b"),
+				},
+				mock: []*metal.Machine{
+					{Base: metal.Base{ID: "1", Name: "a"}},
+					{Base: metal.Base{ID: "2", Name: "b"}},
+					{Base: metal.Base{ID: "3", Name: "c"}},
+				},
+				want: []*metal.Machine{
+					tt.defaultBody(&metal.Machine{Base: metal.Base{ID: "2", Name: "b"}}),
+				},
+				wantErr: nil,
 			},
-			want: []*metal.Machine{
-				tt.defaultBody(&metal.Machine{Base: metal.Base{ID: "2"}, State: metal.MachineState{Value: metal.TaintedState}}),
+			{
+				name: "search by partition",
+				q: &MachineSearchQuery{
+					PartitionID: new("b"),
+				},
+				mock: []*metal.Machine{
+					{Base: metal.Base{ID: "1"}, PartitionID: "a"},
+					{Base: metal.Base{ID: "2"}, PartitionID: "b"},
+					{Base: metal.Base{ID: "3"}, PartitionID: "c"},
+				},
+				want: []*metal.Machine{
+					tt.defaultBody(&metal.Machine{Base: metal.Base{ID: "2"}, PartitionID: "b"}),
+				},
+				wantErr: nil,
 			},
-			wantErr: nil,
-		},
-		{
-			name: "search by state value available",
-			q: &MachineSearchQuery{
-				StateValue: new(string(metal.AvailableState)),
+			{
+				name: "search by size",
+				q: &MachineSearchQuery{
+					SizeID: new("b"),
+				},
+				mock: []*metal.Machine{
+					{Base: metal.Base{ID: "1"}, SizeID: "a"},
+					{Base: metal.Base{ID: "2"}, SizeID: "b"},
+					{Base: metal.Base{ID: "3"}, SizeID: "b"},
+				},
+				want: []*metal.Machine{
+					tt.defaultBody(&metal.Machine{Base: metal.Base{ID: "2"}, SizeID: "b"}),
+					tt.defaultBody(&metal.Machine{Base: metal.Base{ID: "3"}, SizeID: "b"}),
+				},
+				wantErr: nil,
 			},
-			mock: []*metal.Machine{
-				{Base: metal.Base{ID: "1"}, State: metal.MachineState{Value: metal.AvailableState}},
-				{Base: metal.Base{ID: "2"}, State: metal.MachineState{Value: metal.TaintedState}},
-				{Base: metal.Base{ID: "3"}, State: metal.MachineState{Value: metal.AvailableState}},
-				{Base: metal.Base{ID: "4"}, State: metal.MachineState{Value: metal.LockedState}},
+			{
+				name: "search by rack",
+				q: &MachineSearchQuery{
+					RackID: new("b"),
+				},
+				mock: []*metal.Machine{
+					{Base: metal.Base{ID: "1"}, RackID: "a"},
+					{Base: metal.Base{ID: "2"}, RackID: "b"},
+					{Base: metal.Base{ID: "3"}, RackID: "b"},
+				},
+				want: []*metal.Machine{
+					tt.defaultBody(&metal.Machine{Base: metal.Base{ID: "2"}, RackID: "b"}),
+					tt.defaultBody(&metal.Machine{Base: metal.Base{ID: "3"}, RackID: "b"}),
+				},
+				wantErr: nil,
 			},
-			want: []*metal.Machine{
-				tt.defaultBody(&metal.Machine{Base: metal.Base{ID: "1"}, State: metal.MachineState{Value: metal.AvailableState}}),
-				tt.defaultBody(&metal.Machine{Base: metal.Base{ID: "3"}, State: metal.MachineState{Value: metal.AvailableState}}),
+			{
+				name: "search by tags",
+				q: &MachineSearchQuery{
+					Tags: []string{"a=b"},
+				},
+				mock: []*metal.Machine{
+					{Base: metal.Base{ID: "1"}, Tags: []string{}},
+					{Base: metal.Base{ID: "2"}, Tags: []string{"a=b"}},
+					{Base: metal.Base{ID: "3"}, Tags: []string{"b=c"}},
+				},
+				want: []*metal.Machine{
+					tt.defaultBody(&metal.Machine{Base: metal.Base{ID: "2"}, Tags: []string{"a=b"}}),
+				},
+				wantErr: nil,
 			},
-			wantErr: nil,
-		},
-		{
-			name: "search by ipmi address",
-			q: &MachineSearchQuery{
-				IpmiAddress: new("1.1.1.2"),
+			{
+				name: "search by allocation name",
+				q: &MachineSearchQuery{
+					AllocationName: new("b-name"),
+				},
+				mock: []*metal.Machine{
+					{Base: metal.Base{ID: "1"}, Allocation: &metal.MachineAllocation{Name: "a-name"}},
+					{Base: metal.Base{ID: "2"}, Allocation: &metal.MachineAllocation{Name: "b-name"}},
+					{Base: metal.Base{ID: "3"}, Allocation: &metal.MachineAllocation{Name: "c-name"}},
+				},
+				want: []*metal.Machine{
+					tt.defaultBody(&metal.Machine{Base: metal.Base{ID: "2"}, Allocation: &metal.MachineAllocation{Name: "b-name"}}),
+				},
+				wantErr: nil,
 			},
-			mock: []*metal.Machine{
-				{Base: metal.Base{ID: "1"}, IPMI: metal.IPMI{Address: "1.1.1.1"}},
-				{Base: metal.Base{ID: "2"}, IPMI: metal.IPMI{Address: "1.1.1.2"}},
-				{Base: metal.Base{ID: "3"}, IPMI: metal.IPMI{Address: "1.1.1.3"}},
+			{
+				name: "search by allocation project",
+				q: &MachineSearchQuery{
+					AllocationProject: new("a-project"),
+				},
+				mock: []*metal.Machine{
+					{Base: metal.Base{ID: "1"}, Allocation: &metal.MachineAllocation{Project: "a-project"}},
+					{Base: metal.Base{ID: "2"}, Allocation: &metal.MachineAllocation{Project: "b-project"}},
+					{Base: metal.Base{ID: "3"}, Allocation: &metal.MachineAllocation{Project: "c-project"}},
+				},
+				want: []*metal.Machine{
+					tt.defaultBody(&metal.Machine{Base: metal.Base{ID: "1"}, Allocation: &metal.MachineAllocation{Project: "a-project"}}),
+				},
+				wantErr: nil,
 			},
-			want: []*metal.Machine{
-				tt.defaultBody(&metal.Machine{Base: metal.Base{ID: "2"}, IPMI: metal.IPMI{Address: "1.1.1.2"}}),
+			{
+				name: "search by allocation image id",
+				q: &MachineSearchQuery{
+					AllocationImageID: new("ubuntu"),
+				},
+				mock: []*metal.Machine{
+					{Base: metal.Base{ID: "1"}, Allocation: &metal.MachineAllocation{ImageID: "ubuntu"}},
+					{Base: metal.Base{ID: "2"}, Allocation: &metal.MachineAllocation{ImageID: "debian"}},
+					{Base: metal.Base{ID: "3"}, Allocation: &metal.MachineAllocation{ImageID: "ubuntu"}},
+				},
+				want: []*metal.Machine{
+					tt.defaultBody(&metal.Machine{Base: metal.Base{ID: "1"}, Allocation: &metal.MachineAllocation{ImageID: "ubuntu"}}),
+					tt.defaultBody(&metal.Machine{Base: metal.Base{ID: "3"}, Allocation: &metal.MachineAllocation{ImageID: "ubuntu"}}),
+				},
+				wantErr: nil,
 			},
-			wantErr: nil,
-		},
-		{
-			name: "search by ipmi mac",
-			q: &MachineSearchQuery{
-				IpmiMacAddress: new("mac-b"),
+			{
+				name: "search by allocation hostname",
+				q: &MachineSearchQuery{
+					AllocationHostname: new("host-c"),
+				},
+				mock: []*metal.Machine{
+					{Base: metal.Base{ID: "1"}, Allocation: &metal.MachineAllocation{Hostname: "host-a"}},
+					{Base: metal.Base{ID: "2"}, Allocation: &metal.MachineAllocation{Hostname: "host-b"}},
+					{Base: metal.Base{ID: "3"}, Allocation: &metal.MachineAllocation{Hostname: "host-c"}},
+				},
+				want: []*metal.Machine{
+					tt.defaultBody(&metal.Machine{Base: metal.Base{ID: "3"}, Allocation: &metal.MachineAllocation{Hostname: "host-c"}}),
+				},
+				wantErr: nil,
 			},
-			mock: []*metal.Machine{
-				{Base: metal.Base{ID: "1"}, IPMI: metal.IPMI{MacAddress: "mac-a"}},
-				{Base: metal.Base{ID: "2"}, IPMI: metal.IPMI{MacAddress: "mac-b"}},
-				{Base: metal.Base{ID: "3"}, IPMI: metal.IPMI{MacAddress: "mac-c"}},
+			{
+				name: "search by allocation role",
+				q: &MachineSearchQuery{
+					AllocationRole: new(metal.RoleMachine),
+				},
+				mock: []*metal.Machine{
+					{Base: metal.Base{ID: "1"}, Allocation: &metal.MachineAllocation{Role: metal.RoleFirewall}},
+					{Base: metal.Base{ID: "2"}, Allocation: &metal.MachineAllocation{Role: metal.RoleMachine}},
+					{Base: metal.Base{ID: "3"}},
+					{Base: metal.Base{ID: "4"}, Allocation: &metal.MachineAllocation{Role: metal.RoleFirewall}},
+					{Base: metal.Base{ID: "5"}, Allocation: &metal.MachineAllocation{Role: metal.RoleMachine}},
+				},
+				want: []*metal.Machine{
+					tt.defaultBody(&metal.Machine{Base: metal.Base{ID: "2"}, Allocation: &metal.MachineAllocation{Role: metal.RoleMachine}}),
+					tt.defaultBody(&metal.Machine{Base: metal.Base{ID: "5"}, Allocation: &metal.MachineAllocation{Role: metal.RoleMachine}}),
+				},
+				wantErr: nil,
 			},
-			want: []*metal.Machine{
-				tt.defaultBody(&metal.Machine{Base: metal.Base{ID: "2"}, IPMI: metal.IPMI{MacAddress: "mac-b"}}),
+			{
+				name: "search by allocation succeeded",
+				q: &MachineSearchQuery{
+					AllocationSucceeded: new(true),
+				},
+				mock: []*metal.Machine{
+					{Base: metal.Base{ID: "1"}, Allocation: &metal.MachineAllocation{Succeeded: false}},
+					{Base: metal.Base{ID: "2"}, Allocation: &metal.MachineAllocation{Succeeded: true}},
+					{Base: metal.Base{ID: "3"}},
+				},
+				want: []*metal.Machine{
+					tt.defaultBody(&metal.Machine{Base: metal.Base{ID: "2"}, Allocation: &metal.MachineAllocation{Succeeded: true}}),
+				},
+				wantErr: nil,
 			},
-			wantErr: nil,
-		},
-		{
-			name: "search by ipmi user",
-			q: &MachineSearchQuery{
-				IpmiUser: new("metal"),
+			{
+				name: "search by network ids",
+				q: &MachineSearchQuery{
+					NetworkIDs: []string{"internet", "private-tenant-a"},
+				},
+				mock: []*metal.Machine{
+					{Base: metal.Base{ID: "1"}},
+					{Base: metal.Base{ID: "2"}, Allocation: &metal.MachineAllocation{MachineNetworks: []*metal.MachineNetwork{{NetworkID: "private-tenant-a"}, {NetworkID: "internet"}}}},
+					{Base: metal.Base{ID: "3"}, Allocation: &metal.MachineAllocation{MachineNetworks: []*metal.MachineNetwork{{NetworkID: "internet"}}}},
+				},
+				want: []*metal.Machine{
+					tt.defaultBody(&metal.Machine{Base: metal.Base{ID: "2"}, Allocation: &metal.MachineAllocation{MachineNetworks: []*metal.MachineNetwork{{NetworkID: "private-tenant-a"}, {NetworkID: "internet"}}}}),
+				},
+				wantErr: nil,
 			},
-			mock: []*metal.Machine{
-				{Base: metal.Base{ID: "1"}, IPMI: metal.IPMI{User: "metal"}},
-				{Base: metal.Base{ID: "2"}, IPMI: metal.IPMI{User: ""}},
-				{Base: metal.Base{ID: "3"}, IPMI: metal.IPMI{User: "metal"}},
+			{
+				name: "search by network prefixes",
+				q: &MachineSearchQuery{
+					NetworkPrefixes: []string{"192.168.1.0/24"},
+				},
+				mock: []*metal.Machine{
+					{Base: metal.Base{ID: "1"}},
+					{Base: metal.Base{ID: "2"}, Allocation: &metal.MachineAllocation{MachineNetworks: []*metal.MachineNetwork{{Prefixes: []string{"100.64.0.0/28"}}}}},
+					{Base: metal.Base{ID: "3"}, Allocation: &metal.MachineAllocation{MachineNetworks: []*metal.MachineNetwork{{Prefixes: []string{"100.64.0.0/28"}}, {Prefixes: []string{"192.168.1.0/24"}}}}},
+				},
+				want: []*metal.Machine{
+					tt.defaultBody(&metal.Machine{Base: metal.Base{ID: "3"}, Allocation: &metal.MachineAllocation{MachineNetworks: []*metal.MachineNetwork{{Prefixes: []string{"100.64.0.0/28"}}, {Prefixes: []string{"192.168.1.0/24"}}}}}),
+				},
+				wantErr: nil,
 			},
-			want: []*metal.Machine{
-				tt.defaultBody(&metal.Machine{Base: metal.Base{ID: "1"}, IPMI: metal.IPMI{User: "metal"}}),
-				tt.defaultBody(&metal.Machine{Base: metal.Base{ID: "3"}, IPMI: metal.IPMI{User: "metal"}}),
+			{
+				name: "search by network prefixes 2",
+				q: &MachineSearchQuery{
+					NetworkPrefixes: []string{"100.64.0.0/28"},
+				},
+				mock: []*metal.Machine{
+					{Base: metal.Base{ID: "1"}},
+					{Base: metal.Base{ID: "2"}, Allocation: &metal.MachineAllocation{MachineNetworks: []*metal.MachineNetwork{{Prefixes: []string{"100.64.0.0/28"}}}}},
+					{Base: metal.Base{ID: "3"}, Allocation: &metal.MachineAllocation{MachineNetworks: []*metal.MachineNetwork{{Prefixes: []string{"100.64.0.0/28"}}, {Prefixes: []string{"192.168.1.0/24"}}}}},
+				},
+				want: []*metal.Machine{
+					tt.defaultBody(&metal.Machine{Base: metal.Base{ID: "2"}, Allocation: &metal.MachineAllocation{MachineNetworks: []*metal.MachineNetwork{{Prefixes: []string{"100.64.0.0/28"}}}}}),
+					tt.defaultBody(&metal.Machine{Base: metal.Base{ID: "3"}, Allocation: &metal.MachineAllocation{MachineNetworks: []*metal.MachineNetwork{{Prefixes: []string{"100.64.0.0/28"}}, {Prefixes: []string{"192.168.1.0/24"}}}}}),
+				},
+				wantErr: nil,
 			},
-			wantErr: nil,
-		},
-		{
-			name: "search by ipmi interface",
-			q: &MachineSearchQuery{
-				IpmiInterface: new("lanplus"),
+			{
+				name: "search by network ips",
+				q: &MachineSearchQuery{
+					NetworkIPs: []string{"192.168.1.3"},
+				},
+				mock: []*metal.Machine{
+					{Base: metal.Base{ID: "1"}},
+					{Base: metal.Base{ID: "2"}, Allocation: &metal.MachineAllocation{MachineNetworks: []*metal.MachineNetwork{{IPs: []string{"192.168.1.0"}}}}},
+					{Base: metal.Base{ID: "3"}, Allocation: &metal.MachineAllocation{MachineNetworks: []*metal.MachineNetwork{{IPs: []string{"192.168.1.1"}}, {IPs: []string{"192.168.1.2", "192.168.1.3"}}}}},
+				},
+				want: []*metal.Machine{
+					tt.defaultBody(&metal.Machine{Base: metal.Base{ID: "3"}, Allocation: &metal.MachineAllocation{MachineNetworks: []*metal.MachineNetwork{{IPs: []string{"192.168.1.1"}}, {IPs: []string{"192.168.1.2", "192.168.1.3"}}}}}),
+				},
+				wantErr: nil,
 			},
-			mock: []*metal.Machine{
-				{Base: metal.Base{ID: "1"}, IPMI: metal.IPMI{Interface: "lanplus"}},
-				{Base: metal.Base{ID: "2"}, IPMI: metal.IPMI{Interface: "lanplus"}},
-				{Base: metal.Base{ID: "3"}, IPMI: metal.IPMI{Interface: ""}},
+			{
+				name: "search by network destination prefixes",
+				q: &MachineSearchQuery{
+					NetworkDestinationPrefixes: []string{"0.0.0.0/0"},
+				},
+				mock: []*metal.Machine{
+					{Base: metal.Base{ID: "1"}},
+					{Base: metal.Base{ID: "2"}, Allocation: &metal.MachineAllocation{MachineNetworks: []*metal.MachineNetwork{{DestinationPrefixes: []string{"192.168.1.0/24"}}}}},
+					{Base: metal.Base{ID: "3"}, Allocation: &metal.MachineAllocation{MachineNetworks: []*metal.MachineNetwork{{DestinationPrefixes: []string{"0.0.0.0/0"}}, {DestinationPrefixes: []string{"192.168.1.0/24", "0.0.0.0/0"}}}}},
+				},
+				want: []*metal.Machine{
+					tt.defaultBody(&metal.Machine{Base: metal.Base{ID: "3"}, Allocation: &metal.MachineAllocation{MachineNetworks: []*metal.MachineNetwork{{DestinationPrefixes: []string{"0.0.0.0/0"}}, {DestinationPrefixes: []string{"192.168.1.0/24", "0.0.0.0/0"}}}}}),
+				},
+				wantErr: nil,
 			},
-			want: []*metal.Machine{
-				tt.defaultBody(&metal.Machine{Base: metal.Base{ID: "1"}, IPMI: metal.IPMI{Interface: "lanplus"}}),
-				tt.defaultBody(&metal.Machine{Base: metal.Base{ID: "2"}, IPMI: metal.IPMI{Interface: "lanplus"}}),
+			{
+				name: "search by network vrf",
+				q: &MachineSearchQuery{
+					NetworkVrfs: []int64{2},
+				},
+				mock: []*metal.Machine{
+					{Base: metal.Base{ID: "1"}},
+					{Base: metal.Base{ID: "2"}, Allocation: &metal.MachineAllocation{MachineNetworks: []*metal.MachineNetwork{{Vrf: 0}}}},
+					{Base: metal.Base{ID: "3"}, Allocation: &metal.MachineAllocation{MachineNetworks: []*metal.MachineNetwork{{Vrf: 1}, {Vrf: 2}}}},
+				},
+				want: []*metal.Machine{
+					tt.defaultBody(&metal.Machine{Base: metal.Base{ID: "3"}, Allocation: &metal.MachineAllocation{MachineNetworks: []*metal.MachineNetwork{{Vrf: 1}, {Vrf: 2}}}}),
+				},
+				wantErr: nil,
 			},
-			wantErr: nil,
-		},
-		{
-			name: "search by fru chassis part number",
-			q: &MachineSearchQuery{
-				FruChassisPartNumber: new("b-number"),
+			{
+				name: "search by network asn",
+				q: &MachineSearchQuery{
+					NetworkASNs: []int64{42000, 42001},
+				},
+				mock: []*metal.Machine{
+					{Base: metal.Base{ID: "1"}},
+					{Base: metal.Base{ID: "2"}, Allocation: &metal.MachineAllocation{MachineNetworks: []*metal.MachineNetwork{{ASN: 42000}}}},
+					{Base: metal.Base{ID: "3"}, Allocation: &metal.MachineAllocation{MachineNetworks: []*metal.MachineNetwork{{ASN: 42000}, {ASN: 42001}}}},
+				},
+				want: []*metal.Machine{
+					tt.defaultBody(&metal.Machine{Base: metal.Base{ID: "3"}, Allocation: &metal.MachineAllocation{MachineNetworks: []*metal.MachineNetwork{{ASN: 42000}, {ASN: 42001}}}}),
+				},
+				wantErr: nil,
 			},
-			mock: []*metal.Machine{
-				{Base: metal.Base{ID: "1"}, IPMI: metal.IPMI{Fru: metal.Fru{ChassisPartNumber: "a-number"}}},
-				{Base: metal.Base{ID: "2"}, IPMI: metal.IPMI{Fru: metal.Fru{ChassisPartNumber: "b-number"}}},
-				{Base: metal.Base{ID: "3"}, IPMI: metal.IPMI{Fru: metal.Fru{ChassisPartNumber: "c-number"}}},
+			{
+				name: "search by hardware memory",
+				q: &MachineSearchQuery{
+					HardwareMemory: new(int64(1000)),
+				},
+				mock: []*metal.Machine{
+					{Base: metal.Base{ID: "1"}, Hardware: metal.MachineHardware{Memory: 1000}},
+					{Base: metal.Base{ID: "2"}, Hardware: metal.MachineHardware{Memory: 5000}},
+					{Base: metal.Base{ID: "3"}, Hardware: metal.MachineHardware{Memory: 1000}},
+				},
+				want: []*metal.Machine{
+					tt.defaultBody(&metal.Machine{Base: metal.Base{ID: "1"}, Hardware: metal.MachineHardware{Memory: 1000}}),
+					tt.defaultBody(&metal.Machine{Base: metal.Base{ID: "3"}, Hardware: metal.MachineHardware{Memory: 1000}}),
+				},
+				wantErr: nil,
 			},
-			want: []*metal.Machine{
-				tt.defaultBody(&metal.Machine{Base: metal.Base{ID: "2"}, IPMI: metal.IPMI{Fru: metal.Fru{ChassisPartNumber: "b-number"}}}),
+			{
+				name: "search by nic mac address",
+				q: &MachineSearchQuery{
+					NicsMacAddresses: []string{"mac-c"},
+				},
+				mock: []*metal.Machine{
+					{Base: metal.Base{ID: "1"}, Hardware: metal.MachineHardware{Nics: metal.Nics{{MacAddress: "mac-a"}}}},
+					{Base: metal.Base{ID: "2"}, Hardware: metal.MachineHardware{Nics: metal.Nics{{MacAddress: "mac-b"}, {MacAddress: "mac-c"}}}},
+					{Base: metal.Base{ID: "3"}, Hardware: metal.MachineHardware{Nics: metal.Nics{{MacAddress: "mac-d"}}}},
+				},
+				want: []*metal.Machine{
+					tt.defaultBody(&metal.Machine{Base: metal.Base{ID: "2"}, Hardware: metal.MachineHardware{Nics: metal.Nics{{MacAddress: "mac-b"}, {MacAddress: "mac-c"}}}}),
+				},
+				wantErr: nil,
 			},
-			wantErr: nil,
-		},
-		{
-			name: "search by fru chassis part serial",
-			q: &MachineSearchQuery{
-				FruChassisPartSerial: new("b-serial"),
+			{
+				name: "search by nic name",
+				q: &MachineSearchQuery{
+					NicsNames: []string{"nic-2"},
+				},
+				mock: []*metal.Machine{
+					{Base: metal.Base{ID: "1"}, Hardware: metal.MachineHardware{Nics: metal.Nics{{Name: "nic-1"}}}},
+					{Base: metal.Base{ID: "2"}, Hardware: metal.MachineHardware{Nics: metal.Nics{{Name: "nic-2"}, {Name: "nic-3"}}}},
+					{Base: metal.Base{ID: "3"}, Hardware: metal.MachineHardware{Nics: metal.Nics{{Name: "nic-4"}}}},
+				},
+				want: []*metal.Machine{
+					tt.defaultBody(&metal.Machine{Base: metal.Base{ID: "2"}, Hardware: metal.MachineHardware{Nics: metal.Nics{{Name: "nic-2"}, {Name: "nic-3"}}}}),
+				},
+				wantErr: nil,
 			},
-			mock: []*metal.Machine{
-				{Base: metal.Base{ID: "1"}, IPMI: metal.IPMI{Fru: metal.Fru{ChassisPartSerial: "a-serial"}}},
-				{Base: metal.Base{ID: "2"}, IPMI: metal.IPMI{Fru: metal.Fru{ChassisPartSerial: "b-serial"}}},
-				{Base: metal.Base{ID: "3"}, IPMI: metal.IPMI{Fru: metal.Fru{ChassisPartSerial: "c-serial"}}},
+			{
+				name: "search by nic vrf",
+				q: &MachineSearchQuery{
+					NicsVrfs: []string{"vrf10"},
+				},
+				mock: []*metal.Machine{
+					{Base: metal.Base{ID: "1"}, Hardware: metal.MachineHardware{Nics: metal.Nics{{Vrf: "vrf1"}}}},
+					{Base: metal.Base{ID: "2"}, Hardware: metal.MachineHardware{Nics: metal.Nics{{Vrf: "vrf10"}, {Name: "vrf11"}}}},
+					{Base: metal.Base{ID: "3"}, Hardware: metal.MachineHardware{Nics: metal.Nics{{Vrf: ""}}}},
+				},
+				want: []*metal.Machine{
+					tt.defaultBody(&metal.Machine{Base: metal.Base{ID: "2"}, Hardware: metal.MachineHardware{Nics: metal.Nics{{Vrf: "vrf10"}, {Name: "vrf11"}}}}),
+				},
+				wantErr: nil,
 			},
-			want: []*metal.Machine{
-				tt.defaultBody(&metal.Machine{Base: metal.Base{ID: "2"}, IPMI: metal.IPMI{Fru: metal.Fru{ChassisPartSerial: "b-serial"}}}),
+			{
+				name: "search by nic neighbor mac address",
+				q: &MachineSearchQuery{
+					NicsNeighborMacAddresses: []string{"mac-c"},
+				},
+				mock: []*metal.Machine{
+					{Base: metal.Base{ID: "1"}, Hardware: metal.MachineHardware{Nics: metal.Nics{{Neighbors: metal.Nics{{MacAddress: "mac-a"}}}}}},
+					{Base: metal.Base{ID: "2"}, Hardware: metal.MachineHardware{Nics: metal.Nics{{Neighbors: metal.Nics{{MacAddress: "mac-b"}, {MacAddress: "mac-c"}}}}}},
+					{Base: metal.Base{ID: "3"}, Hardware: metal.MachineHardware{Nics: metal.Nics{{Neighbors: metal.Nics{{MacAddress: "mac-d"}}}}}},
+				},
+				want: []*metal.Machine{
+					tt.defaultBody(&metal.Machine{Base: metal.Base{ID: "2"}, Hardware: metal.MachineHardware{Nics: metal.Nics{{Neighbors: metal.Nics{{MacAddress: "mac-b"}, {MacAddress: "mac-c"}}}}}}),
+				},
+				wantErr: nil,
 			},
-			wantErr: nil,
-		},
-		{
-			name: "search by fru board mfg",
-			q: &MachineSearchQuery{
-				FruBoardMfg: new("b"),
+			{
+				name: "search by nic neighbor name",
+				q: &MachineSearchQuery{
+					NicsNeighborNames: []string{"nic-2"},
+				},
+				mock: []*metal.Machine{
+					{Base: metal.Base{ID: "1"}, Hardware: metal.MachineHardware{Nics: metal.Nics{{Neighbors: metal.Nics{{Name: "nic-1"}}}}}},
+					{Base: metal.Base{ID: "2"}, Hardware: metal.MachineHardware{Nics: metal.Nics{{Neighbors: metal.Nics{{Name: "nic-2"}, {Name: "nic-3"}}}}}},
+					{Base: metal.Base{ID: "3"}, Hardware: metal.MachineHardware{Nics: metal.Nics{{Neighbors: metal.Nics{{Name: "nic-4"}}}}}},
+				},
+				want: []*metal.Machine{
+					tt.defaultBody(&metal.Machine{Base: metal.Base{ID: "2"}, Hardware: metal.MachineHardware{Nics: metal.Nics{{Neighbors: metal.Nics{{Name: "nic-2"}, {Name: "nic-3"}}}}}}),
+				},
+				wantErr: nil,
 			},
-			mock: []*metal.Machine{
-				{Base: metal.Base{ID: "1"}, IPMI: metal.IPMI{Fru: metal.Fru{BoardMfg: "a"}}},
-				{Base: metal.Base{ID: "2"}, IPMI: metal.IPMI{Fru: metal.Fru{BoardMfg: "b"}}},
-				{Base: metal.Base{ID: "3"}, IPMI: metal.IPMI{Fru: metal.Fru{BoardMfg: "c"}}},
+			{
+				name: "search by nic neighbor vrf",
+				q: &MachineSearchQuery{
+					NicsNeighborVrfs: []string{"vrf10"},
+				},
+				mock: []*metal.Machine{
+					{Base: metal.Base{ID: "1"}, Hardware: metal.MachineHardware{Nics: metal.Nics{{Neighbors: metal.Nics{{Vrf: "vrf1"}}}}}},
+					{Base: metal.Base{ID: "2"}, Hardware: metal.MachineHardware{Nics: metal.Nics{{Neighbors: metal.Nics{{Vrf: "vrf10"}, {Vrf: "vrf11"}}}}}},
+					{Base: metal.Base{ID: "3"}},
+				},
+				want: []*metal.Machine{
+					tt.defaultBody(&metal.Machine{Base: metal.Base{ID: "2"}, Hardware: metal.MachineHardware{Nics: metal.Nics{{Neighbors: metal.Nics{{Vrf: "vrf10"}, {Vrf: "vrf11"}}}}}}),
+				},
+				wantErr: nil,
 			},
-			want: []*metal.Machine{
-				tt.defaultBody(&metal.Machine{Base: metal.Base{ID: "2"}, IPMI: metal.IPMI{Fru: metal.Fru{BoardMfg: "b"}}}),
+			{
+				name: "search by disk name",
+				q: &MachineSearchQuery{
+					DiskNames: []string{"/dev/nvme0n1"},
+				},
+				mock: []*metal.Machine{
+					{Base: metal.Base{ID: "1"}, Hardware: metal.MachineHardware{Disks: []metal.BlockDevice{{Name: "/dev/sda"}}}},
+					{Base: metal.Base{ID: "2"}, Hardware: metal.MachineHardware{Disks: []metal.BlockDevice{{Name: "/dev/nvme0n1"}, {Name: "/dev/nvme0n2"}}}},
+					{Base: metal.Base{ID: "3"}, Hardware: metal.MachineHardware{Disks: []metal.BlockDevice{{Name: "/dev/nvme0n1"}, {Name: "/dev/nvme0n2"}}}},
+				},
+				want: []*metal.Machine{
+					tt.defaultBody(&metal.Machine{Base: metal.Base{ID: "2"}, Hardware: metal.MachineHardware{Disks: []metal.BlockDevice{{Name: "/dev/nvme0n1"}, {Name: "/dev/nvme0n2"}}}}),
+					tt.defaultBody(&metal.Machine{Base: metal.Base{ID: "3"}, Hardware: metal.MachineHardware{Disks: []metal.BlockDevice{{Name: "/dev/nvme0n1"}, {Name: "/dev/nvme0n2"}}}}),
+				},
+				wantErr: nil,
 			},
-			wantErr: nil,
-		},
-		{
-			name: "search by fru board mfg serial",
-			q: &MachineSearchQuery{
-				FruBoardMfgSerial: new("b"),
+			{
+				name: "search by disk size",
+				q: &MachineSearchQuery{
+					DiskSizes: []int64{1000},
+				},
+				mock: []*metal.Machine{
+					{Base: metal.Base{ID: "1"}, Hardware: metal.MachineHardware{Disks: []metal.BlockDevice{{Size: 500}}}},
+					{Base: metal.Base{ID: "2"}, Hardware: metal.MachineHardware{Disks: []metal.BlockDevice{{Size: 500}, {Size: 1000}}}},
+					{Base: metal.Base{ID: "3"}, Hardware: metal.MachineHardware{Disks: []metal.BlockDevice{{Size: 500}, {Size: 1000}}}},
+				},
+				want: []*metal.Machine{
+					tt.defaultBody(&metal.Machine{Base: metal.Base{ID: "2"}, Hardware: metal.MachineHardware{Disks: []metal.BlockDevice{{Size: 500}, {Size: 1000}}}}),
+					tt.defaultBody(&metal.Machine{Base: metal.Base{ID: "3"}, Hardware: metal.MachineHardware{Disks: []metal.BlockDevice{{Size: 500}, {Size: 1000}}}}),
+				},
+				wantErr: nil,
 			},
-			mock: []*metal.Machine{
-				{Base: metal.Base{ID: "1"}, IPMI: metal.IPMI{Fru: metal.Fru{BoardMfgSerial: "a"}}},
-				{Base: metal.Base{ID: "2"}, IPMI: metal.IPMI{Fru: metal.Fru{BoardMfgSerial: "b"}}},
-				{Base: metal.Base{ID: "3"}, IPMI: metal.IPMI{Fru: metal.Fru{BoardMfgSerial: "c"}}},
+			{
+				name: "search by state value tainted",
+				q: &MachineSearchQuery{
+					StateValue: new(string(metal.TaintedState)),
+				},
+				mock: []*metal.Machine{
+					{Base: metal.Base{ID: "1"}, State: metal.MachineState{Value: metal.AvailableState}},
+					{Base: metal.Base{ID: "2"}, State: metal.MachineState{Value: metal.TaintedState}},
+					{Base: metal.Base{ID: "3"}, State: metal.MachineState{Value: metal.AvailableState}},
+					{Base: metal.Base{ID: "4"}, State: metal.MachineState{Value: metal.LockedState}},
+				},
+				want: []*metal.Machine{
+					tt.defaultBody(&metal.Machine{Base: metal.Base{ID: "2"}, State: metal.MachineState{Value: metal.TaintedState}}),
+				},
+				wantErr: nil,
 			},
-			want: []*metal.Machine{
-				tt.defaultBody(&metal.Machine{Base: metal.Base{ID: "2"}, IPMI: metal.IPMI{Fru: metal.Fru{BoardMfgSerial: "b"}}}),
+			{
+				name: "search by state value available",
+				q: &MachineSearchQuery{
+					StateValue: new(string(metal.AvailableState)),
+				},
+				mock: []*metal.Machine{
+					{Base: metal.Base{ID: "1"}, State: metal.MachineState{Value: metal.AvailableState}},
+					{Base: metal.Base{ID: "2"}, State: metal.MachineState{Value: metal.TaintedState}},
+					{Base: metal.Base{ID: "3"}, State: metal.MachineState{Value: metal.AvailableState}},
+					{Base: metal.Base{ID: "4"}, State: metal.MachineState{Value: metal.LockedState}},
+				},
+				want: []*metal.Machine{
+					tt.defaultBody(&metal.Machine{Base: metal.Base{ID: "1"}, State: metal.MachineState{Value: metal.AvailableState}}),
+					tt.defaultBody(&metal.Machine{Base: metal.Base{ID: "3"}, State: metal.MachineState{Value: metal.AvailableState}}),
+				},
+				wantErr: nil,
 			},
-			wantErr: nil,
-		},
-		{
-			name: "search by fru board part number",
-			q: &MachineSearchQuery{
-				FruBoardPartNumber: new("b-number"),
+			{
+				name: "search by ipmi address",
+				q: &MachineSearchQuery{
+					IpmiAddress: new("1.1.1.2"),
+				},
+				mock: []*metal.Machine{
+					{Base: metal.Base{ID: "1"}, IPMI: metal.IPMI{Address: "1.1.1.1"}},
+					{Base: metal.Base{ID: "2"}, IPMI: metal.IPMI{Address: "1.1.1.2"}},
+					{Base: metal.Base{ID: "3"}, IPMI: metal.IPMI{Address: "1.1.1.3"}},
+				},
+				want: []*metal.Machine{
+					tt.defaultBody(&metal.Machine{Base: metal.Base{ID: "2"}, IPMI: metal.IPMI{Address: "1.1.1.2"}}),
+				},
+				wantErr: nil,
 			},
-			mock: []*metal.Machine{
-				{Base: metal.Base{ID: "1"}, IPMI: metal.IPMI{Fru: metal.Fru{BoardPartNumber: "a-number"}}},
-				{Base: metal.Base{ID: "2"}, IPMI: metal.IPMI{Fru: metal.Fru{BoardPartNumber: "b-number"}}},
-				{Base: metal.Base{ID: "3"}, IPMI: metal.IPMI{Fru: metal.Fru{BoardPartNumber: "c-number"}}},
+			{
+				name: "search by ipmi mac",
+				q: &MachineSearchQuery{
+					IpmiMacAddress: new("mac-b"),
+				},
+				mock: []*metal.Machine{
+					{Base: metal.Base{ID: "1"}, IPMI: metal.IPMI{MacAddress: "mac-a"}},
+					{Base: metal.Base{ID: "2"}, IPMI: metal.IPMI{MacAddress: "mac-b"}},
+					{Base: metal.Base{ID: "3"}, IPMI: metal.IPMI{MacAddress: "mac-c"}},
+				},
+				want: []*metal.Machine{
+					tt.defaultBody(&metal.Machine{Base: metal.Base{ID: "2"}, IPMI: metal.IPMI{MacAddress: "mac-b"}}),
+				},
+				wantErr: nil,
 			},
-			want: []*metal.Machine{
-				tt.defaultBody(&metal.Machine{Base: metal.Base{ID: "2"}, IPMI: metal.IPMI{Fru: metal.Fru{BoardPartNumber: "b-number"}}}),
+			{
+				name: "search by ipmi user",
+				q: &MachineSearchQuery{
+					IpmiUser: new("metal"),
+				},
+				mock: []*metal.Machine{
+					{Base: metal.Base{ID: "1"}, IPMI: metal.IPMI{User: "metal"}},
+					{Base: metal.Base{ID: "2"}, IPMI: metal.IPMI{User: ""}},
+					{Base: metal.Base{ID: "3"}, IPMI: metal.IPMI{User: "metal"}},
+				},
+				want: []*metal.Machine{
+					tt.defaultBody(&metal.Machine{Base: metal.Base{ID: "1"}, IPMI: metal.IPMI{User: "metal"}}),
+					tt.defaultBody(&metal.Machine{Base: metal.Base{ID: "3"}, IPMI: metal.IPMI{User: "metal"}}),
+				},
+				wantErr: nil,
 			},
-			wantErr: nil,
-		},
-		{
-			name: "search by fru product manufacturer",
-			q: &MachineSearchQuery{
-				FruProductManufacturer: new("b"),
+			{
+				name: "search by ipmi interface",
+				q: &MachineSearchQuery{
+					IpmiInterface: new("lanplus"),
+				},
+				mock: []*metal.Machine{
+					{Base: metal.Base{ID: "1"}, IPMI: metal.IPMI{Interface: "lanplus"}},
+					{Base: metal.Base{ID: "2"}, IPMI: metal.IPMI{Interface: "lanplus"}},
+					{Base: metal.Base{ID: "3"}, IPMI: metal.IPMI{Interface: ""}},
+				},
+				want: []*metal.Machine{
+					tt.defaultBody(&metal.Machine{Base: metal.Base{ID: "1"}, IPMI: metal.IPMI{Interface: "lanplus"}}),
+					tt.defaultBody(&metal.Machine{Base: metal.Base{ID: "2"}, IPMI: metal.IPMI{Interface: "lanplus"}}),
+				},
+				wantErr: nil,
 			},
-			mock: []*metal.Machine{
-				{Base: metal.Base{ID: "1"}, IPMI: metal.IPMI{Fru: metal.Fru{ProductManufacturer: "a"}}},
-				{Base: metal.Base{ID: "2"}, IPMI: metal.IPMI{Fru: metal.Fru{ProductManufacturer: "b"}}},
-				{Base: metal.Base{ID: "3"}, IPMI: metal.IPMI{Fru: metal.Fru{ProductManufacturer: "c"}}},
+			{
+				name: "search by fru chassis part number",
+				q: &MachineSearchQuery{
+					FruChassisPartNumber: new("b-number"),
+				},
+				mock: []*metal.Machine{
+					{Base: metal.Base{ID: "1"}, IPMI: metal.IPMI{Fru: metal.Fru{ChassisPartNumber: "a-number"}}},
+					{Base: metal.Base{ID: "2"}, IPMI: metal.IPMI{Fru: metal.Fru{ChassisPartNumber: "b-number"}}},
+					{Base: metal.Base{ID: "3"}, IPMI: metal.IPMI{Fru: metal.Fru{ChassisPartNumber: "c-number"}}},
+				},
+				want: []*metal.Machine{
+					tt.defaultBody(&metal.Machine{Base: metal.Base{ID: "2"}, IPMI: metal.IPMI{Fru: metal.Fru{ChassisPartNumber: "b-number"}}}),
+				},
+				wantErr: nil,
 			},
-			want: []*metal.Machine{
-				tt.defaultBody(&metal.Machine{Base: metal.Base{ID: "2"}, IPMI: metal.IPMI{Fru: metal.Fru{ProductManufacturer: "b"}}}),
+			{
+				name: "search by fru chassis part serial",
+				q: &MachineSearchQuery{
+					FruChassisPartSerial: new("b-serial"),
+				},
+				mock: []*metal.Machine{
+					{Base: metal.Base{ID: "1"}, IPMI: metal.IPMI{Fru: metal.Fru{ChassisPartSerial: "a-serial"}}},
+					{Base: metal.Base{ID: "2"}, IPMI: metal.IPMI{Fru: metal.Fru{ChassisPartSerial: "b-serial"}}},
+					{Base: metal.Base{ID: "3"}, IPMI: metal.IPMI{Fru: metal.Fru{ChassisPartSerial: "c-serial"}}},
+				},
+				want: []*metal.Machine{
+					tt.defaultBody(&metal.Machine{Base: metal.Base{ID: "2"}, IPMI: metal.IPMI{Fru: metal.Fru{ChassisPartSerial: "b-serial"}}}),
+				},
+				wantErr: nil,
 			},
-			wantErr: nil,
-		},
-		{
-			name: "search by fru product part number",
-			q: &MachineSearchQuery{
-				FruProductPartNumber: new("b-number"),
+			{
+				name: "search by fru board mfg",
+				q: &MachineSearchQuery{
+					FruBoardMfg: new("b"),
+				},
+				mock: []*metal.Machine{
+					{Base: metal.Base{ID: "1"}, IPMI: metal.IPMI{Fru: metal.Fru{BoardMfg: "a"}}},
+					{Base: metal.Base{ID: "2"}, IPMI: metal.IPMI{Fru: metal.Fru{BoardMfg: "b"}}},
+					{Base: metal.Base{ID: "3"}, IPMI: metal.IPMI{Fru: metal.Fru{BoardMfg: "c"}}},
+				},
+				want: []*metal.Machine{
+					tt.defaultBody(&metal.Machine{Base: metal.Base{ID: "2"}, IPMI: metal.IPMI{Fru: metal.Fru{BoardMfg: "b"}}}),
+				},
+				wantErr: nil,
 			},
-			mock: []*metal.Machine{
-				{Base: metal.Base{ID: "1"}, IPMI: metal.IPMI{Fru: metal.Fru{ProductPartNumber: "a-number"}}},
-				{Base: metal.Base{ID: "2"}, IPMI: metal.IPMI{Fru: metal.Fru{ProductPartNumber: "b-number"}}},
-				{Base: metal.Base{ID: "3"}, IPMI: metal.IPMI{Fru: metal.Fru{ProductPartNumber: "c-number"}}},
+			{
+				name: "search by fru board mfg serial",
+				q: &MachineSearchQuery{
+					FruBoardMfgSerial: new("b"),
+				},
+				mock: []*metal.Machine{
+					{Base: metal.Base{ID: "1"}, IPMI: metal.IPMI{Fru: metal.Fru{BoardMfgSerial: "a"}}},
+					{Base: metal.Base{ID: "2"}, IPMI: metal.IPMI{Fru: metal.Fru{BoardMfgSerial: "b"}}},
+					{Base: metal.Base{ID: "3"}, IPMI: metal.IPMI{Fru: metal.Fru{BoardMfgSerial: "c"}}},
+				},
+				want: []*metal.Machine{
+					tt.defaultBody(&metal.Machine{Base: metal.Base{ID: "2"}, IPMI: metal.IPMI{Fru: metal.Fru{BoardMfgSerial: "b"}}}),
+				},
+				wantErr: nil,
 			},
-			want: []*metal.Machine{
-				tt.defaultBody(&metal.Machine{Base: metal.Base{ID: "2"}, IPMI: metal.IPMI{Fru: metal.Fru{ProductPartNumber: "b-number"}}}),
+			{
+				name: "search by fru board part number",
+				q: &MachineSearchQuery{
+					FruBoardPartNumber: new("b-number"),
+				},
+				mock: []*metal.Machine{
+					{Base: metal.Base{ID: "1"}, IPMI: metal.IPMI{Fru: metal.Fru{BoardPartNumber: "a-number"}}},
+					{Base: metal.Base{ID: "2"}, IPMI: metal.IPMI{Fru: metal.Fru{BoardPartNumber: "b-number"}}},
+					{Base: metal.Base{ID: "3"}, IPMI: metal.IPMI{Fru: metal.Fru{BoardPartNumber: "c-number"}}},
+				},
+				want: []*metal.Machine{
+					tt.defaultBody(&metal.Machine{Base: metal.Base{ID: "2"}, IPMI: metal.IPMI{Fru: metal.Fru{BoardPartNumber: "b-number"}}}),
+				},
+				wantErr: nil,
 			},
-			wantErr: nil,
-		},
-		{
-			name: "search by fru product part serial",
-			q: &MachineSearchQuery{
-				FruProductSerial: new("b-serial"),
+			{
+				name: "search by fru product manufacturer",
+				q: &MachineSearchQuery{
+					FruProductManufacturer: new("b"),
+				},
+				mock: []*metal.Machine{
+					{Base: metal.Base{ID: "1"}, IPMI: metal.IPMI{Fru: metal.Fru{ProductManufacturer: "a"}}},
+					{Base: metal.Base{ID: "2"}, IPMI: metal.IPMI{Fru: metal.Fru{ProductManufacturer: "b"}}},
+					{Base: metal.Base{ID: "3"}, IPMI: metal.IPMI{Fru: metal.Fru{ProductManufacturer: "c"}}},
+				},
+				want: []*metal.Machine{
+					tt.defaultBody(&metal.Machine{Base: metal.Base{ID: "2"}, IPMI: metal.IPMI{Fru: metal.Fru{ProductManufacturer: "b"}}}),
+				},
+				wantErr: nil,
 			},
-			mock: []*metal.Machine{
-				{Base: metal.Base{ID: "1"}, IPMI: metal.IPMI{Fru: metal.Fru{ProductSerial: "a-serial"}}},
-				{Base: metal.Base{ID: "2"}, IPMI: metal.IPMI{Fru: metal.Fru{ProductSerial: "b-serial"}}},
-				{Base: metal.Base{ID: "3"}, IPMI: metal.IPMI{Fru: metal.Fru{ProductSerial: "c-serial"}}},
+			{
+				name: "search by fru product part number",
+				q: &MachineSearchQuery{
+					FruProductPartNumber: new("b-number"),
+				},
+				mock: []*metal.Machine{
+					{Base: metal.Base{ID: "1"}, IPMI: metal.IPMI{Fru: metal.Fru{ProductPartNumber: "a-number"}}},
+					{Base: metal.Base{ID: "2"}, IPMI: metal.IPMI{Fru: metal.Fru{ProductPartNumber: "b-number"}}},
+					{Base: metal.Base{ID: "3"}, IPMI: metal.IPMI{Fru: metal.Fru{ProductPartNumber: "c-number"}}},
+				},
+				want: []*metal.Machine{
+					tt.defaultBody(&metal.Machine{Base: metal.Base{ID: "2"}, IPMI: metal.IPMI{Fru: metal.Fru{ProductPartNumber: "b-number"}}}),
+				},
+				wantErr: nil,
 			},
-			want: []*metal.Machine{
-				tt.defaultBody(&metal.Machine{Base: metal.Base{ID: "2"}, IPMI: metal.IPMI{Fru: metal.Fru{ProductSerial: "b-serial"}}}),
+			{
+				name: "search by fru product part serial",
+				q: &MachineSearchQuery{
+					FruProductSerial: new("b-serial"),
+				},
+				mock: []*metal.Machine{
+					{Base: metal.Base{ID: "1"}, IPMI: metal.IPMI{Fru: metal.Fru{ProductSerial: "a-serial"}}},
+					{Base: metal.Base{ID: "2"}, IPMI: metal.IPMI{Fru: metal.Fru{ProductSerial: "b-serial"}}},
+					{Base: metal.Base{ID: "3"}, IPMI: metal.IPMI{Fru: metal.Fru{ProductSerial: "c-serial"}}},
+				},
+				want: []*metal.Machine{
+					tt.defaultBody(&metal.Machine{Base: metal.Base{ID: "2"}, IPMI: metal.IPMI{Fru: metal.Fru{ProductSerial: "b-serial"}}}),
+				},
+				wantErr: nil,
 			},
-			wantErr: nil,
-		},
-	}
+		}
 
-	for i := range tests {
-		tests[i].run(t, tt)
-	}
+		for i := range tests {
+			tests[i].run(t, tt)
+		}
+	})
 }
 
-func TestRethinkStore_ListMachines(t *testing.T) {
-	tt := &machineTestable{}
-	defer func() {
+func TestStore_SearchMachinesPaged(t *testing.T) {
+	forEachStore(t, func(t *testing.T) {
+		tt := &machineTestable{}
+		defer func() {
+			require.NoError(t, tt.wipe())
+		}()
 		require.NoError(t, tt.wipe())
-	}()
-
-	tests := []listTest[*metal.Machine, *MachineSearchQuery]{
-		{
-			name: "list",
-			mock: []*metal.Machine{
-				{Base: metal.Base{ID: "1"}},
-				{Base: metal.Base{ID: "2"}},
-				{Base: metal.Base{ID: "3"}},
-			},
-			want: []*metal.Machine{
-				tt.defaultBody(&metal.Machine{Base: metal.Base{ID: "1"}}),
-				tt.defaultBody(&metal.Machine{Base: metal.Base{ID: "2"}}),
-				tt.defaultBody(&metal.Machine{Base: metal.Base{ID: "3"}}),
-			},
-		},
-	}
-	for i := range tests {
-		tests[i].run(t, tt)
-	}
+
+		for _, m := range []*metal.Machine{
+			{Base: metal.Base{ID: "1"}, RackID: "rack-2"},
+			{Base: metal.Base{ID: "2"}, RackID: "rack-1"},
+			{Base: metal.Base{ID: "3"}, RackID: "rack-2"},
+			{Base: metal.Base{ID: "4"}, RackID: "rack-1"},
+			{Base: metal.Base{ID: "5"}, RackID: "rack-3", Allocation: &metal.MachineAllocation{Project: "p"}},
+		} {
+			require.NoError(t, tt.create(m))
+		}
+
+		search := func(q *MachineSearchQuery) []string {
+			var ms metal.Machines
+			require.NoError(t, sharedDS.SearchMachines(q, &ms))
+			var ids []string
+			for _, m := range ms {
+				ids = append(ids, m.ID)
+			}
+			next := q.NextCursor(ms)
+			q.Cursor = &next
+			return ids
+		}
+
+		q := &MachineSearchQuery{Paging: Paging{Limit: new(uint64(2)), SortBy: new("rack")}}
+		assert.Equal(t, []string{"2", "4"}, search(q))
+		assert.Equal(t, []string{"1", "3"}, search(q))
+		assert.Equal(t, []string{"5"}, search(q))
+		assert.Empty(t, *q.Cursor)
+
+		// machines without allocation are sorted like an empty project
+		q = &MachineSearchQuery{Paging: Paging{Limit: new(uint64(3)), SortBy: new("project"), SortDescending: new(true)}}
+		assert.Equal(t, []string{"5", "4", "3"}, search(q))
+		assert.Equal(t, []string{"2", "1"}, search(q))
+
+		q = &MachineSearchQuery{RackID: new("rack-2"), Paging: Paging{Offset: new(uint64(1))}}
+		assert.Equal(t, []string{"3"}, search(q))
+	})
 }
 
-func TestRethinkStore_CreateMachine(t *testing.T) {
-	tt := &machineTestable{}
-	defer func() {
-		require.NoError(t, tt.wipe())
-	}()
-
-	tests := []createTest[*metal.Machine, *MachineSearchQuery]{
-		{
-			name:    "create",
-			want:    tt.defaultBody(&metal.Machine{Base: metal.Base{ID: "1"}}),
-			wantErr: nil,
-		},
-		{
-			name: "already exists",
-			mock: []*metal.Machine{
-				{Base: metal.Base{ID: "1"}},
+func TestStore_ListMachines(t *testing.T) {
+	forEachStore(t, func(t *testing.T) {
+		tt := &machineTestable{}
+		defer func() {
+			require.NoError(t, tt.wipe())
+		}()
+
+		tests := []listTest[*metal.Machine, *MachineSearchQuery]{
+			{
+				name: "list",
+				mock: []*metal.Machine{
+					{Base: metal.Base{ID: "1"}},
+					{Base: metal.Base{ID: "2"}},
+					{Base: metal.Base{ID: "3"}},
+				},
+				want: []*metal.Machine{
+					tt.defaultBody(&metal.Machine{Base: metal.Base{ID: "1"}}),
+					tt.defaultBody(&metal.Machine{Base: metal.Base{ID: "2"}}),
+					tt.defaultBody(&metal.Machine{Base: metal.Base{ID: "3"}}),
+				},
 			},
-			want:    tt.defaultBody(&metal.Machine{Base: metal.Base{ID: "1"}}),
-			wantErr: metal.Conflict(`cannot create machine in database, entity already exists: 1`),
-		},
-	}
-	for i := range tests {
-		tests[i].run(t, tt)
-	}
+		}
+		for i := range tests {
+			tests[i].run(t, tt)
+		}
+	})
 }
 
-func TestRethinkStore_DeleteMachine(t *testing.T) {
-	tt := &machineTestable{}
-	defer func() {
-		require.NoError(t, tt.wipe())
-	}()
-
-	tests := []deleteTest[*metal.Machine, *MachineSearchQuery]{
-		{
-			name: "delete",
-			id:   "2",
-			mock: []*metal.Machine{
-				{Base: metal.Base{ID: "1"}},
-				{Base: metal.Base{ID: "2"}},
-				{Base: metal.Base{ID: "3"}},
-			},
-			want: []*metal.Machine{
-				tt.defaultBody(&metal.Machine{Base: metal.Base{ID: "1"}}),
-				tt.defaultBody(&metal.Machine{Base: metal.Base{ID: "3"}}),
-			},
-		},
-		{
-			name: "not exists results in noop",
-			id:   "abc",
-			mock: []*metal.Machine{
-				{Base: metal.Base{ID: "1"}},
-				{Base: metal.Base{ID: "2"}},
-				{Base: metal.Base{ID: "3"}},
-			},
-			want: []*metal.Machine{
-				tt.defaultBody(&metal.Machine{Base: metal.Base{ID: "1"}}),
-				tt.defaultBody(&metal.Machine{Base: metal.Base{ID: "2"}}),
-				tt.defaultBody(&metal.Machine{Base: metal.Base{ID: "3"}}),
+func TestStore_CreateMachine(t *testing.T) {
+	forEachStore(t, func(t *testing.T) {
+		tt := &machineTestable{}
+		defer func() {
+			require.NoError(t, tt.wipe())
+		}()
+
+		tests := []createTest[*metal.Machine, *MachineSearchQuery]{
+			{
+				name:    "create",
+				want:    tt.defaultBody(&metal.Machine{Base: metal.Base{ID: "1"}}),
+				wantErr: nil,
+			},
+			{
+				name: "already exists",
+				mock: []*metal.Machine{
+					{Base: metal.Base{ID: "1"}},
+				},
+				want:    tt.defaultBody(&metal.Machine{Base: metal.Base{ID: "1"}}),
+				wantErr: metal.Conflict(`cannot create machine in database, entity already exists: 1`),
 			},
-		},
-	}
-	for i := range tests {
-		tests[i].run(t, tt)
-	}
+		}
+		for i := range tests {
+			tests[i].run(t, tt)
+		}
+	})
 }
 
-func TestRethinkStore_UpdateMachine(t *testing.T) {
-	tt := &machineTestable{}
-	defer func() {
-		require.NoError(t, tt.wipe())
-	}()
-
-	tests := []updateTest[*metal.Machine, *MachineSearchQuery]{
-		{
-			name: "update",
-			mock: []*metal.Machine{
-				{Base: metal.Base{ID: "1"}},
-				{Base: metal.Base{ID: "2"}},
-				{Base: metal.Base{ID: "3"}},
+func TestStore_DeleteMachine(t *testing.T) {
+	forEachStore(t, func(t *testing.T) {
+		tt := &machineTestable{}
+		defer func() {
+			require.NoError(t, tt.wipe())
+		}()
+
+		tests := []deleteTest[*metal.Machine, *MachineSearchQuery]{
+			{
+				name: "delete",
+				id:   "2",
+				mock: []*metal.Machine{
+					{Base: metal.Base{ID: "1"}},
+					{Base: metal.Base{ID: "2"}},
+					{Base: metal.Base{ID: "3"}},
+				},
+				want: []*metal.Machine{
+					tt.defaultBody(&metal.Machine{Base: metal.Base{ID: "1"}}),
+					tt.defaultBody(&metal.Machine{Base: metal.Base{ID: "3"}}),
+				},
 			},
-			mutateFn: func(s *metal.Machine) {
-				s.Tags = []string{"a=b"}
+			{
+				name: "not exists results in noop",
+				id:   "abc",
+				mock: []*metal.Machine{
+					{Base: metal.Base{ID: "1"}},
+					{Base: metal.Base{ID: "2"}},
+					{Base: metal.Base{ID: "3"}},
+				},
+				want: []*metal.Machine{
+					tt.defaultBody(&metal.Machine{Base: metal.Base{ID: "1"}}),
+					tt.defaultBody(&metal.Machine{Base: metal.Base{ID: "2"}}),
+					tt.defaultBody(&metal.Machine{Base: metal.Base{ID: "3"}}),
+				},
 			},
-			want: &metal.Machine{
-				Base:     metal.Base{ID: "1"},
-				Hardware: metal.MachineHardware{Nics: metal.Nics{}, Disks: []metal.BlockDevice{}, MetalCPUs: []metal.MetalCPU{}, MetalGPUs: []metal.MetalGPU{}},
-				IPMI:     metal.IPMI{PowerSupplies: metal.PowerSupplies{}},
-				Tags:     []string{"a=b"},
+		}
+		for i := range tests {
+			tests[i].run(t, tt)
+		}
+	})
+}
+
+func TestStore_UpdateMachine(t *testing.T) {
+	forEachStore(t, func(t *testing.T) {
+		tt := &machineTestable{}
+		defer func() {
+			require.NoError(t, tt.wipe())
+		}()
+
+		tests := []updateTest[*metal.Machine, *MachineSearchQuery]{
+			{
+				name: "update",
+				mock: []*metal.Machine{
+					{Base: metal.Base{ID: "1"}},
+					{Base: metal.Base{ID: "2"}},
+					{Base: metal.Base{ID: "3"}},
+				},
+				mutateFn: func(s *metal.Machine) {
+					s.Tags = []string{"a=b"}
+				},
+				want: &metal.Machine{
+					Base:     metal.Base{ID: "1"},
+					Hardware: metal.MachineHardware{Nics: metal.Nics{}, Disks: []metal.BlockDevice{}, MetalCPUs: []metal.MetalCPU{}, MetalGPUs: []metal.MetalGPU{}},
+					IPMI:     metal.IPMI{PowerSupplies: metal.PowerSupplies{}},
+					Tags:     []string{"a=b"},
+				},
 			},
-		},
-	}
-	for i := range tests {
-		tests[i].run(t, tt)
-	}
+		}
+		for i := range tests {
+			tests[i].run(t, tt)
+		}
+	})
 }
 
 func Test_FindWaitingMachine_NoConcurrentModificationErrors(t *testing.T) {
+	forEachStore(t, func(t *testing.T) {
 
-	var (
-		root  = slog.New(slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelError}))
-		wg    sync.WaitGroup
-		size  = metal.Size{Base: metal.Base{ID: "1"}}
-		count int
-	)
-
-	for _, initEntity := range []struct {
-		entity metal.Entity
-		table  *r.Term
-	}{
-		{
-			table: sharedDS.machineTable(),
-			entity: &metal.Machine{
-				Base: metal.Base{
-					ID: "1",
-				},
-				PartitionID: "partition",
-				SizeID:      size.ID,
-				State: metal.MachineState{
-					Value: metal.AvailableState,
+		var (
+			root  = slog.New(slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelError}))
+			wg    sync.WaitGroup
+			size  = metal.Size{Base: metal.Base{ID: "1"}}
+			count int
+		)
+
+		for _, initEntity := range []struct {
+			entity metal.Entity
+			table  string
+		}{
+			{
+				table: "machine",
+				entity: &metal.Machine{
+					Base: metal.Base{
+						ID: "1",
+					},
+					PartitionID: "partition",
+					SizeID:      size.ID,
+					State: metal.MachineState{
+						Value: metal.AvailableState,
+					},
+					Waiting:      true,
+					PreAllocated: false,
 				},
-				Waiting:      true,
-				PreAllocated: false,
 			},
-		},
-		{
-			table: sharedDS.eventTable(),
-			entity: &metal.ProvisioningEventContainer{
-				Base: metal.Base{
-					ID: "1",
+			{
+				table: "event",
+				entity: &metal.ProvisioningEventContainer{
+					Base: metal.Base{
+						ID: "1",
+					},
+					Liveliness: metal.MachineLivelinessAlive,
 				},
-				Liveliness: metal.MachineLivelinessAlive,
 			},
-		},
-	} {
-		err := sharedDS.createEntity(initEntity.table, initEntity.entity)
-		require.NoError(t, err)
-
-		defer func() {
-			_, err := initEntity.table.Delete().RunWrite(sharedDS.session)
+		} {
+			err := createEntity(sharedDS, initEntity.table, initEntity.entity)
 			require.NoError(t, err)
-		}()
-	}
 
-	for i := range 100 {
-		log := root.With("worker", i)
+			defer func() {
+				err := wipeTable(sharedDS, initEntity.table)
+				require.NoError(t, err)
+			}()
+		}
+
+		for i := range 100 {
+			log := root.With("worker", i)
 
-		wg.Go(func() {
+			wg.Go(func() {
 
-			for {
-				machine, err := sharedDS.FindWaitingMachine(context.Background(), "project", "partition", size, nil, metal.RoleMachine)
-				if err != nil {
-					if metal.IsConflict(err) {
-						t.Errorf("concurrent modification occurred, shared mutex is not working")
-						break
-					}
+				for {
+					machine, err := sharedDS.FindWaitingMachine(context.Background(), "project", "partition", size, metal.Placement{}, metal.RoleMachine)
+					if err != nil {
+						if metal.IsConflict(err) {
+							t.Errorf("concurrent modification occurred, shared mutex is not working")
+							break
+						}
+
+						if strings.Contains(err.Error(), "no machine available") {
+							continue
+						}
 
-					if strings.Contains(err.Error(), "no machine available") {
+						if strings.Contains(err.Error(), "too many parallel") {
+							time.Sleep(10 * time.Millisecond)
+							continue
+						}
+
+						t.Errorf("unexpected error occurred: %s", err)
 						continue
 					}
 
-					if strings.Contains(err.Error(), "too many parallel") {
-						time.Sleep(10 * time.Millisecond)
-						continue
+					log.Debug("waiting machine found")
+
+					newMachine := *machine
+					newMachine.PreAllocated = false
+					if newMachine.Name == "" {
+						newMachine.Name = strconv.Itoa(0)
 					}
 
-					t.Errorf("unexpected error occurred: %s", err)
-					continue
-				}
+					assert.Equal(t, strconv.Itoa(count), newMachine.Name, "concurrency occurred")
+					count++
+					newMachine.Name = strconv.Itoa(count)
 
-				log.Debug("waiting machine found")
+					err = sharedDS.UpdateMachine(machine, &newMachine)
+					if err != nil {
+						log.Error("unable to toggle back pre-allocation flag", "error", err)
+						t.Fail()
+					}
 
-				newMachine := *machine
-				newMachine.PreAllocated = false
-				if newMachine.Name == "" {
-					newMachine.Name = strconv.Itoa(0)
+					return
 				}
+			})
+		}
 
-				assert.Equal(t, strconv.Itoa(count), newMachine.Name, "concurrency occurred")
-				count++
-				newMachine.Name = strconv.Itoa(count)
+		wg.Wait()
 
-				err = sharedDS.updateEntity(sharedDS.machineTable(), &newMachine, machine)
-				if err != nil {
-					log.Error("unable to toggle back pre-allocation flag", "error", err)
-					t.Fail()
-				}
+		assert.Equal(t, 100, count)
+	})
+}
 
-				return
+func Test_FindWaitingMachine_RackSpreadingDistribution(t *testing.T) {
+	forEachStore(t, func(t *testing.T) {
+		var (
+			partitionID = "partition"
+			projectID   = "project"
+			size1       = metal.Size{Base: metal.Base{ID: "1"}}
+			fiveRacks   = func(i int) string {
+				return "rack-" + strconv.FormatInt(int64((i%5)+1), 10)
 			}
-		})
-	}
+		)
 
-	wg.Wait()
+		defer func() {
+			err := wipeTable(sharedDS, "machine")
+			require.NoError(t, err)
+			err = wipeTable(sharedDS, "event")
+			require.NoError(t, err)
+		}()
 
-	assert.Equal(t, 100, count)
-}
+		for i := range 200 {
+			err := createEntity(sharedDS, "machine", &metal.Machine{
+				Base: metal.Base{
+					ID: strconv.Itoa(i),
+				},
+				PartitionID: partitionID,
+				SizeID:      size1.ID,
+				State: metal.MachineState{
+					Value: metal.AvailableState,
+				},
+				Waiting:      true,
+				PreAllocated: false,
+				RackID:       fiveRacks(i),
+			})
+			require.NoError(t, err)
 
-func Test_FindWaitingMachine_RackSpreadingDistribution(t *testing.T) {
-	var (
-		partitionID = "partition"
-		projectID   = "project"
-		size1       = metal.Size{Base: metal.Base{ID: "1"}}
-		fiveRacks   = func(i int) string {
-			return "rack-" + strconv.FormatInt(int64((i%5)+1), 10)
+			err = createEntity(sharedDS, "event", &metal.ProvisioningEventContainer{
+				Base: metal.Base{
+					ID: strconv.Itoa(i),
+				},
+				Liveliness: metal.MachineLivelinessAlive,
+			})
+			require.NoError(t, err)
 		}
-	)
 
-	defer func() {
-		_, err := sharedDS.machineTable().Delete().RunWrite(sharedDS.session)
-		require.NoError(t, err)
-		_, err = sharedDS.eventTable().Delete().RunWrite(sharedDS.session)
-		require.NoError(t, err)
-	}()
+		// just allocate some machines with different specs that should not influence later allocs
+		for i, spec := range []struct {
+			role metal.Role
+			size string
+		}{
+			{role: metal.RoleFirewall, size: "firewall"},
+			{role: metal.RoleFirewall, size: "firewall"},
+			{role: metal.RoleMachine, size: "machine"},
+			{role: metal.RoleMachine, size: "machine"},
+			// just to prove that it affects the algorithm:
+			// {role: metal.RoleMachine, size: size1.ID},
+		} {
+			err := createEntity(sharedDS, "machine", &metal.Machine{
+				Base: metal.Base{
+					ID: "allocated-" + strconv.Itoa(i),
+				},
+				PartitionID: partitionID,
+				SizeID:      spec.size,
+				State: metal.MachineState{
+					Value: metal.AvailableState,
+				},
+				Allocation: &metal.MachineAllocation{
+					Project: projectID,
+					Role:    spec.role,
+				},
+				RackID: fiveRacks(i),
+			})
+			require.NoError(t, err)
 
-	for i := range 200 {
-		err := sharedDS.createEntity(sharedDS.machineTable(), &metal.Machine{
-			Base: metal.Base{
-				ID: strconv.Itoa(i),
-			},
-			PartitionID: partitionID,
-			SizeID:      size1.ID,
-			State: metal.MachineState{
-				Value: metal.AvailableState,
-			},
-			Waiting:      true,
-			PreAllocated: false,
-			RackID:       fiveRacks(i),
-		})
-		require.NoError(t, err)
+			err = createEntity(sharedDS, "event", &metal.ProvisioningEventContainer{
+				Base: metal.Base{
+					ID: "allocated-" + strconv.Itoa(i),
+				},
+				Liveliness: metal.MachineLivelinessAlive,
+			})
+			require.NoError(t, err)
+		}
 
-		err = sharedDS.createEntity(sharedDS.eventTable(), &metal.ProvisioningEventContainer{
-			Base: metal.Base{
-				ID: strconv.Itoa(i),
-			},
-			Liveliness: metal.MachineLivelinessAlive,
-		})
-		require.NoError(t, err)
-	}
+		for range 100 {
+			machine, err := sharedDS.FindWaitingMachine(context.Background(), projectID, partitionID, size1, metal.Placement{}, metal.RoleMachine)
+			require.NoError(t, err)
 
-	// just allocate some machines with different specs that should not influence later allocs
-	for i, spec := range []struct {
-		role metal.Role
-		size string
-	}{
-		{role: metal.RoleFirewall, size: "firewall"},
-		{role: metal.RoleFirewall, size: "firewall"},
-		{role: metal.RoleMachine, size: "machine"},
-		{role: metal.RoleMachine, size: "machine"},
-		// just to prove that it affects the algorithm:
-		// {role: metal.RoleMachine, size: size1.ID},
-	} {
-		err := sharedDS.createEntity(sharedDS.machineTable(), &metal.Machine{
-			Base: metal.Base{
-				ID: "allocated-" + strconv.Itoa(i),
-			},
-			PartitionID: partitionID,
-			SizeID:      spec.size,
-			State: metal.MachineState{
-				Value: metal.AvailableState,
-			},
-			Allocation: &metal.MachineAllocation{
+			newMachine := *machine
+			newMachine.PreAllocated = false
+			newMachine.Allocation = &metal.MachineAllocation{
 				Project: projectID,
-				Role:    spec.role,
-			},
-			RackID: fiveRacks(i),
-		})
-		require.NoError(t, err)
-
-		err = sharedDS.createEntity(sharedDS.eventTable(), &metal.ProvisioningEventContainer{
-			Base: metal.Base{
-				ID: "allocated-" + strconv.Itoa(i),
-			},
-			Liveliness: metal.MachineLivelinessAlive,
-		})
-		require.NoError(t, err)
-	}
-
-	for range 100 {
-		machine, err := sharedDS.FindWaitingMachine(context.Background(), projectID, partitionID, size1, nil, metal.RoleMachine)
-		require.NoError(t, err)
+			}
+			newMachine.Allocation.Role = metal.RoleMachine
+			newMachine.SizeID = size1.ID
 
-		newMachine := *machine
-		newMachine.PreAllocated = false
-		newMachine.Allocation = &metal.MachineAllocation{
-			Project: projectID,
-		}
-		newMachine.Allocation.Role = metal.RoleMachine
-		newMachine.SizeID = size1.ID
+			err = sharedDS.UpdateMachine(machine, &newMachine)
+			if err != nil {
+				t.Errorf("unable to update machine: %s", err)
+			}
 
-		err = sharedDS.updateEntity(sharedDS.machineTable(), &newMachine, machine)
-		if err != nil {
-			t.Errorf("unable to update machine: %s", err)
+			t.Logf("machine %s allocated in %s", newMachine.ID, newMachine.RackID)
 		}
 
-		t.Logf("machine %s allocated in %s", newMachine.ID, newMachine.RackID)
-	}
-
-	var ms metal.Machines
-	err := sharedDS.SearchMachines(&MachineSearchQuery{AllocationProject: &projectID, SizeID: &size1.ID, PartitionID: &partitionID}, &ms)
-	require.NoError(t, err)
+		var ms metal.Machines
+		err := sharedDS.SearchMachines(&MachineSearchQuery{AllocationProject: &projectID, SizeID: &size1.ID, PartitionID: &partitionID}, &ms)
+		require.NoError(t, err)
 
-	require.Len(t, ms, 100)
+		require.Len(t, ms, 100)
 
-	machinesByRack := map[string]int{}
-	for _, m := range ms {
-		machinesByRack[m.RackID]++
-	}
+		machinesByRack := map[string]int{}
+		for _, m := range ms {
+			machinesByRack[m.RackID]++
+		}
 
-	for id, count := range machinesByRack {
-		assert.Equal(t, 100/5, count, "uneven machine distribution in %s", id)
-	}
+		for id, count := range machinesByRack {
+			assert.Equal(t, 100/5, count, "uneven machine distribution in %s", id)
+		}
 
-	fmt.Println(machinesByRack)
+		fmt.Println(machinesByRack)
+	})
 }
@@ -5,6 +5,7 @@ import (
 	"sort"
 	"testing"
 	"testing/quick"
+	"time"
 
 	"github.com/google/go-cmp/cmp"
 	"github.com/metal-stack/metal-api/cmd/metal-api/internal/metal"
@@ -735,6 +736,8 @@ func Test_checkSizeReservations(t *testing.T) {
 		p1         = "1"
 		p2         = "2"
 
+		now = time.Now()
+
 		reservations = metal.SizeReservations{
 			{
 				SizeID:       "c1-xlarge-x86",
@@ -761,7 +764,7 @@ func Test_checkSizeReservations(t *testing.T) {
 	)
 
 	// 5 available, 3 reserved, project 0 can allocate
-	ok := checkSizeReservations(available, p0, projectMachines, reservations)
+	ok := checkSizeReservations(available, p0, projectMachines, reservations, now)
 	require.True(t, ok)
 	allocate(available[0].ID, p0)
 
@@ -778,7 +781,7 @@ func Test_checkSizeReservations(t *testing.T) {
 	}, projectMachines)
 
 	// 4 available, 3 reserved, project 2 can allocate
-	ok = checkSizeReservations(available, p2, projectMachines, reservations)
+	ok = checkSizeReservations(available, p2, projectMachines, reservations, now)
 	require.True(t, ok)
 	allocate(available[0].ID, p2)
 
@@ -797,7 +800,7 @@ func Test_checkSizeReservations(t *testing.T) {
 	}, projectMachines)
 
 	// 3 available, 3 reserved (1 used), project 0 can allocate
-	ok = checkSizeReservations(available, p0, projectMachines, reservations)
+	ok = checkSizeReservations(available, p0, projectMachines, reservations, now)
 	require.True(t, ok)
 	allocate(available[0].ID, p0)
 
@@ -816,11 +819,11 @@ func Test_checkSizeReservations(t *testing.T) {
 	}, projectMachines)
 
 	// 2 available, 3 reserved (1 used), project 0 cannot allocate anymore
-	ok = checkSizeReservations(available, p0, projectMachines, reservations)
+	ok = checkSizeReservations(available, p0, projectMachines, reservations, now)
 	require.False(t, ok)
 
 	// 2 available, 3 reserved (1 used), project 2 can allocate
-	ok = checkSizeReservations(available, p2, projectMachines, reservations)
+	ok = checkSizeReservations(available, p2, projectMachines, reservations, now)
 	require.True(t, ok)
 	allocate(available[0].ID, p2)
 
@@ -839,13 +842,13 @@ func Test_checkSizeReservations(t *testing.T) {
 	}, projectMachines)
 
 	// 1 available, 3 reserved (2 used), project 0 and 2 cannot allocate anymore
-	ok = checkSizeReservations(available, p0, projectMachines, reservations)
+	ok = checkSizeReservations(available, p0, projectMachines, reservations, now)
 	require.False(t, ok)
-	ok = checkSizeReservations(available, p2, projectMachines, reservations)
+	ok = checkSizeReservations(available, p2, projectMachines, reservations, now)
 	require.False(t, ok)
 
 	// 1 available, 3 reserved (2 used), project 1 can allocate
-	ok = checkSizeReservations(available, p1, projectMachines, reservations)
+	ok = checkSizeReservations(available, p1, projectMachines, reservations, now)
 	require.True(t, ok)
 	allocate(available[0].ID, p1)
 
@@ -864,3 +867,31 @@ func Test_checkSizeReservations(t *testing.T) {
 		},
 	}, projectMachines)
 }
+
+func Test_checkSizeReservationsWindow(t *testing.T) {
+	var (
+		now       = time.Date(2026, 11, 10, 12, 0, 0, 0, time.UTC)
+		available = metal.Machines{
+			{Base: metal.Base{ID: "1"}},
+			{Base: metal.Base{ID: "2"}},
+		}
+		reservations = metal.SizeReservations{
+			{
+				SizeID:       "c1-xlarge-x86",
+				Amount:       2,
+				ProjectID:    "1",
+				PartitionIDs: []string{"a"},
+				Start:        new(now.Add(24 * time.Hour)),
+				End:          new(now.Add(48 * time.Hour)),
+			},
+		}
+	)
+
+	// the reservation is not honored before it starts
+	require.True(t, checkSizeReservations(available, "0", nil, reservations, now))
+	// the reservation is honored inside its window
+	require.False(t, checkSizeReservations(available, "0", nil, reservations, now.Add(36*time.Hour)))
+	require.True(t, checkSizeReservations(available, "1", nil, reservations, now.Add(36*time.Hour)))
+	// the reservation is not honored after it ended
+	require.True(t, checkSizeReservations(available, "0", nil, reservations, now.Add(48*time.Hour)))
+}
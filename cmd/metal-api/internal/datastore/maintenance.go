@@ -0,0 +1,88 @@
+package datastore
+
+import (
+	"github.com/metal-stack/metal-api/cmd/metal-api/internal/metal"
+	r "gopkg.in/rethinkdb/rethinkdb-go.v6"
+)
+
+// MaintenanceWindowSearchQuery can be used to search maintenance windows.
+type MaintenanceWindowSearchQuery struct {
+	ID          *string `json:"id" optional:"true"`
+	Scope       *string `json:"scope" optional:"true"`
+	PartitionID *string `json:"partitionid" optional:"true"`
+	RackID      *string `json:"rackid" optional:"true"`
+	MachineID   *string `json:"machineid" optional:"true"`
+}
+
+func (p *MaintenanceWindowSearchQuery) generateTerm(rs *RethinkStore) *r.Term {
+	q := *rs.maintenanceWindowTable()
+
+	if p.ID != nil {
+		q = q.Filter(func(row r.Term) r.Term {
+			return row.Field("id").Eq(*p.ID)
+		})
+	}
+
+	if p.Scope != nil {
+		q = q.Filter(func(row r.Term) r.Term {
+			return row.Field("scope").Eq(*p.Scope)
+		})
+	}
+
+	if p.PartitionID != nil {
+		q = q.Filter(func(row r.Term) r.Term {
+			return row.Field("partitionid").Eq(*p.PartitionID)
+		})
+	}
+
+	if p.RackID != nil {
+		q = q.Filter(func(row r.Term) r.Term {
+			return row.Field("rackid").Eq(*p.RackID)
+		})
+	}
+
+	if p.MachineID != nil {
+		q = q.Filter(func(row r.Term) r.Term {
+			return row.Field("machineid").Eq(*p.MachineID)
+		})
+	}
+
+	return &q
+}
+
+// FindMaintenanceWindow returns the maintenance window for the given id.
+func (rs *RethinkStore) FindMaintenanceWindow(id string) (*metal.MaintenanceWindow, error) {
+	var w metal.MaintenanceWindow
+	err := rs.findEntityByID(rs.maintenanceWindowTable(), &w, id)
+	if err != nil {
+		return nil, err
+	}
+	return &w, nil
+}
+
+// SearchMaintenanceWindows returns the result of the maintenance windows search request query.
+func (rs *RethinkStore) SearchMaintenanceWindows(q *MaintenanceWindowSearchQuery, ws *metal.MaintenanceWindows) error {
+	return rs.searchEntities(q.generateTerm(rs), ws)
+}
+
+// ListMaintenanceWindows returns all maintenance windows.
+func (rs *RethinkStore) ListMaintenanceWindows() (metal.MaintenanceWindows, error) {
+	ws := make(metal.MaintenanceWindows, 0)
+	err := rs.listEntities(rs.maintenanceWindowTable(), &ws)
+	return ws, err
+}
+
+// CreateMaintenanceWindow creates a new maintenance window.
+func (rs *RethinkStore) CreateMaintenanceWindow(w *metal.MaintenanceWindow) error {
+	return rs.createEntity(rs.maintenanceWindowTable(), w)
+}
+
+// DeleteMaintenanceWindow deletes a maintenance window.
+func (rs *RethinkStore) DeleteMaintenanceWindow(w *metal.MaintenanceWindow) error {
+	return rs.deleteEntity(rs.maintenanceWindowTable(), w)
+}
+
+// UpdateMaintenanceWindow updates a maintenance window.
+func (rs *RethinkStore) UpdateMaintenanceWindow(oldWindow *metal.MaintenanceWindow, newWindow *metal.MaintenanceWindow) error {
+	return rs.updateEntity(rs.maintenanceWindowTable(), newWindow, oldWindow)
+}
@@ -0,0 +1,165 @@
+//go:build integration
+
+package datastore
+
+import (
+	"testing"
+
+	"github.com/metal-stack/metal-api/cmd/metal-api/internal/metal"
+	"github.com/stretchr/testify/require"
+)
+
+type maintenanceWindowTestable struct{}
+
+func (_ *maintenanceWindowTestable) wipe() error {
+	err := wipeTable(sharedDS, "maintenancewindow")
+	return err
+}
+
+func (_ *maintenanceWindowTestable) create(w *metal.MaintenanceWindow) error { // nolint:unused
+	return sharedDS.CreateMaintenanceWindow(w)
+}
+
+func (_ *maintenanceWindowTestable) delete(id string) error { // nolint:unused
+	return sharedDS.DeleteMaintenanceWindow(&metal.MaintenanceWindow{Base: metal.Base{ID: id}})
+}
+
+func (_ *maintenanceWindowTestable) update(old *metal.MaintenanceWindow, mutateFn func(w *metal.MaintenanceWindow)) error { // nolint:unused
+	mod := *old
+	if mutateFn != nil {
+		mutateFn(&mod)
+	}
+
+	return sharedDS.UpdateMaintenanceWindow(old, &mod)
+}
+
+func (_ *maintenanceWindowTestable) find(id string) (*metal.MaintenanceWindow, error) { // nolint:unused
+	return sharedDS.FindMaintenanceWindow(id)
+}
+
+func (_ *maintenanceWindowTestable) list() ([]*metal.MaintenanceWindow, error) { // nolint:unused
+	res, err := sharedDS.ListMaintenanceWindows()
+	if err != nil {
+		return nil, err
+	}
+
+	return derefSlice(res), nil
+}
+
+func (_ *maintenanceWindowTestable) search(q *MaintenanceWindowSearchQuery) ([]*metal.MaintenanceWindow, error) { // nolint:unused
+	var res metal.MaintenanceWindows
+	err := sharedDS.SearchMaintenanceWindows(q, &res)
+	if err != nil {
+		return nil, err
+	}
+
+	return derefSlice(res), nil
+}
+
+func (_ *maintenanceWindowTestable) defaultBody(w *metal.MaintenanceWindow) *metal.MaintenanceWindow {
+	return w
+}
+
+func TestStore_FindMaintenanceWindow(t *testing.T) {
+	forEachStore(t, func(t *testing.T) {
+		tt := &maintenanceWindowTestable{}
+		defer func() {
+			require.NoError(t, tt.wipe())
+		}()
+
+		tests := []findTest[*metal.MaintenanceWindow, *MaintenanceWindowSearchQuery]{
+			{
+				name: "find",
+				id:   "2",
+
+				mock: []*metal.MaintenanceWindow{
+					{Base: metal.Base{ID: "1"}},
+					{Base: metal.Base{ID: "2"}},
+					{Base: metal.Base{ID: "3"}},
+				},
+				want:    tt.defaultBody(&metal.MaintenanceWindow{Base: metal.Base{ID: "2"}}),
+				wantErr: nil,
+			},
+			{
+				name:    "not found",
+				id:      "4",
+				want:    nil,
+				wantErr: metal.NotFound(`no maintenancewindow with id "4" found`),
+			},
+		}
+		for i := range tests {
+			tests[i].run(t, tt)
+		}
+	})
+}
+
+func TestStore_SearchMaintenanceWindows(t *testing.T) {
+	forEachStore(t, func(t *testing.T) {
+		tt := &maintenanceWindowTestable{}
+		defer func() {
+			require.NoError(t, tt.wipe())
+		}()
+
+		tests := []searchTest[*metal.MaintenanceWindow, *MaintenanceWindowSearchQuery]{
+			{
+				name: "empty result",
+				q: &MaintenanceWindowSearchQuery{
+					ID: new("2"),
+				},
+				mock: []*metal.MaintenanceWindow{
+					{Base: metal.Base{ID: "1"}},
+				},
+				want:    nil,
+				wantErr: nil,
+			},
+			{
+				name: "search by scope",
+				q: &MaintenanceWindowSearchQuery{
+					Scope: new(string(metal.MaintenanceScopeRack)),
+				},
+				mock: []*metal.MaintenanceWindow{
+					{Base: metal.Base{ID: "1"}, Scope: metal.MaintenanceScopeMachine},
+					{Base: metal.Base{ID: "2"}, Scope: metal.MaintenanceScopeRack},
+				},
+				want: []*metal.MaintenanceWindow{
+					tt.defaultBody(&metal.MaintenanceWindow{Base: metal.Base{ID: "2"}, Scope: metal.MaintenanceScopeRack}),
+				},
+				wantErr: nil,
+			},
+			{
+				name: "search by partition and rack",
+				q: &MaintenanceWindowSearchQuery{
+					PartitionID: new("p1"),
+					RackID:      new("r1"),
+				},
+				mock: []*metal.MaintenanceWindow{
+					{Base: metal.Base{ID: "1"}, PartitionID: "p1", RackID: "r1"},
+					{Base: metal.Base{ID: "2"}, PartitionID: "p1", RackID: "r2"},
+					{Base: metal.Base{ID: "3"}, PartitionID: "p2", RackID: "r1"},
+				},
+				want: []*metal.MaintenanceWindow{
+					tt.defaultBody(&metal.MaintenanceWindow{Base: metal.Base{ID: "1"}, PartitionID: "p1", RackID: "r1"}),
+				},
+				wantErr: nil,
+			},
+			{
+				name: "search by machine",
+				q: &MaintenanceWindowSearchQuery{
+					MachineID: new("m1"),
+				},
+				mock: []*metal.MaintenanceWindow{
+					{Base: metal.Base{ID: "1"}, MachineID: "m1"},
+					{Base: metal.Base{ID: "2"}, MachineID: "m2"},
+				},
+				want: []*metal.MaintenanceWindow{
+					tt.defaultBody(&metal.MaintenanceWindow{Base: metal.Base{ID: "1"}, MachineID: "m1"}),
+				},
+				wantErr: nil,
+			},
+		}
+
+		for i := range tests {
+			tests[i].run(t, tt)
+		}
+	})
+}
@@ -0,0 +1,1508 @@
+package datastore
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"math/rand/v2"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/metal-stack/metal-api/cmd/metal-api/internal/metal"
+	"github.com/metal-stack/metal-lib/pkg/healthstatus"
+	"gopkg.in/rethinkdb/rethinkdb-go.v6/encoding"
+)
+
+// A MemoryStore is a database access layer which keeps all entities in memory.
+//
+// It does not require a running rethinkdb and is intended for single binary deployments
+// (e.g. lab partitions) and for testing. Entities are stored in the same document
+// representation as in the RethinkStore, so callers never share memory with the store.
+// Data is lost when the process terminates.
+type MemoryStore struct {
+	log *slog.Logger
+
+	mtx    sync.RWMutex
+	tables map[string]map[string]any
+
+	// allocationMtx prevents parallel machine allocations, it replaces the shared mutex of the RethinkStore
+	allocationMtx sync.Mutex
+
+	VRFPoolRangeMin uint
+	VRFPoolRangeMax uint
+	ASNPoolRangeMin uint
+	ASNPoolRangeMax uint
+
+	poolMtx sync.Mutex
+	pools   map[IntegerPoolType]*memoryIntegerPool
+
+	watchMtx sync.Mutex
+	watchers map[*memoryWatcher]bool
+}
+
+// NewMemory creates a new memory store.
+func NewMemory(log *slog.Logger) *MemoryStore {
+	return &MemoryStore{
+		log:      log,
+		tables:   map[string]map[string]any{},
+		pools:    map[IntegerPoolType]*memoryIntegerPool{},
+		watchers: map[*memoryWatcher]bool{},
+
+		VRFPoolRangeMin: DefaultVRFPoolRangeMin,
+		VRFPoolRangeMax: DefaultVRFPoolRangeMax,
+		ASNPoolRangeMin: DefaultASNPoolRangeMin,
+		ASNPoolRangeMax: DefaultASNPoolRangeMax,
+	}
+}
+
+func (ms *MemoryStore) ServiceName() string {
+	return "memory"
+}
+
+// Check implements the health interface, the memory store is always healthy.
+func (ms *MemoryStore) Check(ctx context.Context) (healthstatus.HealthResult, error) {
+	return healthstatus.HealthResult{
+		Status:  healthstatus.HealthStatusHealthy,
+		Message: "using in-memory datastore",
+	}, nil
+}
+
+// Connect does nothing as there is no database to connect to.
+func (ms *MemoryStore) Connect() error {
+	ms.log.Info("memory store connected")
+	return nil
+}
+
+// Initialize initializes the integer pools.
+func (ms *MemoryStore) Initialize() error {
+	ms.GetVRFPool()
+	ms.GetASNPool()
+	ms.log.Info("memory store init complete")
+	return nil
+}
+
+// Demote does nothing as there are no database users.
+func (ms *MemoryStore) Demote() error {
+	return nil
+}
+
+// Migrate does nothing as the data of the memory store never outlives the running version.
+func (ms *MemoryStore) Migrate(targetVersion *int, dry bool) error {
+	ms.log.Info("memory store does not require migrations")
+	return nil
+}
+
+// Close does nothing, the data is kept until the process terminates.
+func (ms *MemoryStore) Close() error {
+	ms.log.Info("memory store closed")
+	return nil
+}
+
+// GetVRFPool returns the pool of unique integers used for vrfs.
+func (ms *MemoryStore) GetVRFPool() IntegerPooler {
+	return ms.pool(VRFIntegerPool, ms.VRFPoolRangeMin, ms.VRFPoolRangeMax)
+}
+
+// GetASNPool returns the pool of unique integers used for asns.
+func (ms *MemoryStore) GetASNPool() IntegerPooler {
+	return ms.pool(ASNIntegerPool, ms.ASNPoolRangeMin, ms.ASNPoolRangeMax)
+}
+
+func (ms *MemoryStore) pool(poolType IntegerPoolType, min, max uint) *memoryIntegerPool {
+	ms.poolMtx.Lock()
+	defer ms.poolMtx.Unlock()
+
+	p, ok := ms.pools[poolType]
+	if !ok {
+		p = &memoryIntegerPool{
+			poolType: poolType,
+			min:      min,
+			max:      max,
+			acquired: map[uint]bool{},
+		}
+		ms.pools[poolType] = p
+	}
+
+	return p
+}
+
+// FindMachineByID returns a machine for a given id.
+func (ms *MemoryStore) FindMachineByID(id string) (*metal.Machine, error) {
+	var m metal.Machine
+	err := ms.findEntityByID("machine", &m, id)
+	if err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+// FindMachine returns a machine by the given query, fails if there is no record or multiple records found.
+func (ms *MemoryStore) FindMachine(q *MachineSearchQuery, m *metal.Machine) error {
+	var machines metal.Machines
+	err := ms.SearchMachines(q, &machines)
+	if err != nil {
+		return err
+	}
+	return findOne(machines, m)
+}
+
+// SearchMachines returns the result of the machines search request query.
+func (ms *MemoryStore) SearchMachines(q *MachineSearchQuery, machines *metal.Machines) error {
+	all, err := ms.ListMachines()
+	if err != nil {
+		return err
+	}
+	*machines, err = page(filterEntities(all, q.matches), &q.Paging, machineSortKeys)
+	return err
+}
+
+// ListMachines returns all machines.
+func (ms *MemoryStore) ListMachines() (metal.Machines, error) {
+	machines := make(metal.Machines, 0)
+	err := ms.listEntities("machine", &machines)
+	return machines, err
+}
+
+// CreateMachine creates a new machine, allocated machines cannot be created.
+func (ms *MemoryStore) CreateMachine(m *metal.Machine) error {
+	if m.Allocation != nil {
+		return fmt.Errorf("a machine cannot be created when it is allocated: %q: %+v", m.ID, *m.Allocation)
+	}
+	return ms.createEntity("machine", m)
+}
+
+// DeleteMachine removes a machine.
+func (ms *MemoryStore) DeleteMachine(m *metal.Machine) error {
+	return ms.deleteEntity("machine", m)
+}
+
+// UpdateMachine replaces a machine if the 'changed' field of the old value equals the 'changed' field of the stored machine.
+func (ms *MemoryStore) UpdateMachine(oldMachine *metal.Machine, newMachine *metal.Machine) error {
+	return ms.updateEntity("machine", newMachine, oldMachine)
+}
+
+// FindWaitingMachine returns an available, not allocated, waiting and alive machine of given size within the given partition.
+func (ms *MemoryStore) FindWaitingMachine(ctx context.Context, projectid, partitionid string, size metal.Size, placement metal.Placement, role metal.Role) (*metal.Machine, error) {
+	ms.allocationMtx.Lock()
+	defer ms.allocationMtx.Unlock()
+
+	all, err := ms.ListMachines()
+	if err != nil {
+		return nil, err
+	}
+
+	candidates := filterEntities(all, func(m *metal.Machine) bool {
+		return m.Allocation == nil &&
+			m.PartitionID == partitionid &&
+			m.SizeID == size.ID &&
+			m.State.Value == metal.AvailableState &&
+			m.Waiting &&
+			!m.PreAllocated
+	})
+
+	return preallocateWaitingMachine(ms.log, ms, candidates, projectid, partitionid, size, placement, role)
+}
+
+// FindSwitch returns a switch for a given id.
+func (ms *MemoryStore) FindSwitch(id string) (*metal.Switch, error) {
+	var s metal.Switch
+	err := ms.findEntityByID("switch", &s, id)
+	if err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+// ListSwitches returns all known switches.
+func (ms *MemoryStore) ListSwitches() (metal.Switches, error) {
+	ss := make(metal.Switches, 0)
+	err := ms.listEntities("switch", &ss)
+	return ss, err
+}
+
+// CreateSwitch creates a new switch.
+func (ms *MemoryStore) CreateSwitch(s *metal.Switch) error {
+	return ms.createEntity("switch", s)
+}
+
+// DeleteSwitch deletes a switch.
+func (ms *MemoryStore) DeleteSwitch(s *metal.Switch) error {
+	return ms.deleteEntity("switch", s)
+}
+
+// UpdateSwitch updates a switch.
+func (ms *MemoryStore) UpdateSwitch(oldSwitch *metal.Switch, newSwitch *metal.Switch) error {
+	return ms.updateEntity("switch", newSwitch, oldSwitch)
+}
+
+// SearchSwitches searches for switches by the given parameters.
+func (ms *MemoryStore) SearchSwitches(q *SwitchSearchQuery, ss *metal.Switches) error {
+	all, err := ms.ListSwitches()
+	if err != nil {
+		return err
+	}
+	*ss = filterEntities(all, q.matches)
+	return nil
+}
+
+// SearchSwitchesConnectedToMachine searches switches that are connected to the given machine in the machine's rack.
+func (ms *MemoryStore) SearchSwitchesConnectedToMachine(m *metal.Machine) (metal.Switches, error) {
+	return ms.SearchSwitchesConnectedToMachineInRack(m, &m.RackID)
+}
+
+// SearchSwitchesConnectedToMachineInRack search for machine connections in a specific rack or in all racks if rack == nil.
+func (ms *MemoryStore) SearchSwitchesConnectedToMachineInRack(m *metal.Machine, rack *string) (metal.Switches, error) {
+	return searchSwitchesConnectedToMachineInRack(ms, m, rack)
+}
+
+// SetVrfAtSwitches finds the switches connected to the given machine and puts the switch ports into the given vrf.
+func (ms *MemoryStore) SetVrfAtSwitches(m *metal.Machine, vrf string) (metal.Switches, error) {
+	return setVrfAtSwitches(ms, m, vrf)
+}
+
+// ConnectMachineWithSwitches connects the machine with the two leaf switches it is cabled to and detects its rack.
+func (ms *MemoryStore) ConnectMachineWithSwitches(m *metal.Machine) error {
+	return connectMachineWithSwitches(ms, m)
+}
+
+// GetSwitchStatus get SwitchStatus for a given switch id
+func (ms *MemoryStore) GetSwitchStatus(id string) (*metal.SwitchStatus, error) {
+	var ss metal.SwitchStatus
+	err := ms.findEntityByID("switchstatus", &ss, id)
+	if err != nil {
+		return nil, err
+	}
+	return &ss, nil
+}
+
+// SetSwitchStatus create or update the switch status.
+func (ms *MemoryStore) SetSwitchStatus(state *metal.SwitchStatus) error {
+	return ms.upsertEntity("switchstatus", state)
+}
+
+// DeleteSwitchStatus delete SwitchStatus
+func (ms *MemoryStore) DeleteSwitchStatus(status *metal.SwitchStatus) error {
+	return ms.deleteEntity("switchstatus", status)
+}
+
+// FindNetworkByID returns an network of a given id.
+func (ms *MemoryStore) FindNetworkByID(id string) (*metal.Network, error) {
+	var nw metal.Network
+	err := ms.findEntityByID("network", &nw, id)
+	if err != nil {
+		return nil, err
+	}
+	return &nw, nil
+}
+
+// FindNetwork returns a network by the given query, fails if there is no record or multiple records found.
+func (ms *MemoryStore) FindNetwork(q *NetworkSearchQuery, n *metal.Network) error {
+	var nws metal.Networks
+	err := ms.SearchNetworks(q, &nws)
+	if err != nil {
+		return err
+	}
+	return findOne(nws, n)
+}
+
+// SearchNetworks returns the networks that match the given properties
+func (ms *MemoryStore) SearchNetworks(q *NetworkSearchQuery, ns *metal.Networks) error {
+	match, err := q.matcher()
+	if err != nil {
+		return err
+	}
+	all, err := ms.ListNetworks()
+	if err != nil {
+		return err
+	}
+	*ns, err = page(filterEntities(all, match), &q.Paging, networkSortKeys)
+	return err
+}
+
+// ListNetworks returns all networks.
+func (ms *MemoryStore) ListNetworks() (metal.Networks, error) {
+	nws := make(metal.Networks, 0)
+	err := ms.listEntities("network", &nws)
+	return nws, err
+}
+
+// CreateNetwork creates a new network.
+func (ms *MemoryStore) CreateNetwork(nw *metal.Network) error {
+	return ms.createEntity("network", nw)
+}
+
+// DeleteNetwork deletes an network.
+func (ms *MemoryStore) DeleteNetwork(nw *metal.Network) error {
+	return ms.deleteEntity("network", nw)
+}
+
+// UpdateNetwork updates an network.
+func (ms *MemoryStore) UpdateNetwork(oldNetwork *metal.Network, newNetwork *metal.Network) error {
+	return ms.updateEntity("network", newNetwork, oldNetwork)
+}
+
+// FindIPByID returns an ip of a given id.
+func (ms *MemoryStore) FindIPByID(id string) (*metal.IP, error) {
+	var ip metal.IP
+	err := ms.findEntityByID("ip", &ip, id)
+	if err != nil {
+		return nil, err
+	}
+	return &ip, nil
+}
+
+// SearchIPs returns the result of the ips search request query.
+func (ms *MemoryStore) SearchIPs(q *IPSearchQuery, ips *metal.IPs) error {
+	match, err := q.matcher()
+	if err != nil {
+		return err
+	}
+	all, err := ms.ListIPs()
+	if err != nil {
+		return err
+	}
+	*ips, err = page(filterEntities(all, match), &q.Paging, ipSortKeys)
+	return err
+}
+
+// ListIPs returns all ips.
+func (ms *MemoryStore) ListIPs() (metal.IPs, error) {
+	ips := make(metal.IPs, 0)
+	err := ms.listEntities("ip", &ips)
+	return ips, err
+}
+
+// CreateIP creates a new ip.
+func (ms *MemoryStore) CreateIP(ip *metal.IP) error {
+	if ip.AllocationUUID == "" {
+		u, err := uuid.NewRandom()
+		if err != nil {
+			return fmt.Errorf("unable to create uuid for IP allocation: %w", err)
+		}
+		ip.AllocationUUID = u.String()
+	}
+	return ms.createEntity("ip", ip)
+}
+
+// DeleteIP deletes an ip.
+func (ms *MemoryStore) DeleteIP(ip *metal.IP) error {
+	return ms.deleteEntity("ip", ip)
+}
+
+// UpdateIP updates an ip.
+func (ms *MemoryStore) UpdateIP(oldIP *metal.IP, newIP *metal.IP) error {
+	return ms.updateEntity("ip", newIP, oldIP)
+}
+
+// FindSize return a size for a given id.
+func (ms *MemoryStore) FindSize(id string) (*metal.Size, error) {
+	var s metal.Size
+	err := ms.findEntityByID("size", &s, id)
+	if err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+// SearchSizes returns the result of the sizes search request query.
+func (ms *MemoryStore) SearchSizes(q *SizeSearchQuery, sizes *metal.Sizes) error {
+	all, err := ms.ListSizes()
+	if err != nil {
+		return err
+	}
+	*sizes = filterEntities(all, q.matches)
+	return nil
+}
+
+// ListSizes returns all sizes.
+func (ms *MemoryStore) ListSizes() (metal.Sizes, error) {
+	szs := make(metal.Sizes, 0)
+	err := ms.listEntities("size", &szs)
+	return szs, err
+}
+
+// CreateSize creates a new size.
+func (ms *MemoryStore) CreateSize(size *metal.Size) error {
+	return ms.createEntity("size", size)
+}
+
+// DeleteSize deletes a size.
+func (ms *MemoryStore) DeleteSize(size *metal.Size) error {
+	return ms.deleteEntity("size", size)
+}
+
+// UpdateSize updates a size.
+func (ms *MemoryStore) UpdateSize(oldSize *metal.Size, newSize *metal.Size) error {
+	return ms.updateEntity("size", newSize, oldSize)
+}
+
+// FromHardware tries to find a size which matches the given hardware specs.
+func (ms *MemoryStore) FromHardware(hw metal.MachineHardware) (*metal.Size, error) {
+	return fromHardware(ms.log, ms, hw)
+}
+
+// GetImage return a image for a given id without semver matching.
+func (ms *MemoryStore) GetImage(id string) (*metal.Image, error) {
+	var i metal.Image
+	err := ms.findEntityByID("image", &i, id)
+	if err != nil {
+		return nil, err
+	}
+	return &i, nil
+}
+
+// FindImages returns all images for the given image id.
+func (ms *MemoryStore) FindImages(id string) ([]metal.Image, error) {
+	return findImages(ms, id)
+}
+
+// FindImage returns an image for the given image id.
+func (ms *MemoryStore) FindImage(id string) (*metal.Image, error) {
+	return findImage(ms, id)
+}
+
+// ListImages returns all images.
+func (ms *MemoryStore) ListImages() (metal.Images, error) {
+	imgs := make(metal.Images, 0)
+	err := ms.listEntities("image", &imgs)
+	return imgs, err
+}
+
+// CreateImage creates a new image.
+func (ms *MemoryStore) CreateImage(i *metal.Image) error {
+	return ms.createEntity("image", i)
+}
+
+// DeleteImage deletes an image.
+func (ms *MemoryStore) DeleteImage(i *metal.Image) error {
+	return ms.deleteEntity("image", i)
+}
+
+// UpdateImage updates an image.
+func (ms *MemoryStore) UpdateImage(oldImage *metal.Image, newImage *metal.Image) error {
+	return ms.updateEntity("image", newImage, oldImage)
+}
+
+// SearchImages searches for images by the given parameters.
+func (ms *MemoryStore) SearchImages(q *ImageSearchQuery, images *metal.Images) error {
+	all, err := ms.ListImages()
+	if err != nil {
+		return err
+	}
+	*images = filterEntities(all, q.matches)
+	return nil
+}
+
+// DeleteOrphanImages deletes Images which are no longer allocated by a machine and older than allowed.
+func (ms *MemoryStore) DeleteOrphanImages(images metal.Images, machines metal.Machines) (metal.Images, error) {
+	return deleteOrphanImages(ms, images, machines)
+}
+
+// FindPartition return a partition for the given id.
+func (ms *MemoryStore) FindPartition(id string) (*metal.Partition, error) {
+	var p metal.Partition
+	err := ms.findEntityByID("partition", &p, id)
+	if err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// ListPartitions returns all partition.
+func (ms *MemoryStore) ListPartitions() (metal.Partitions, error) {
+	ps := make(metal.Partitions, 0)
+	err := ms.listEntities("partition", &ps)
+	return ps, err
+}
+
+// CreatePartition creates a new partition.
+func (ms *MemoryStore) CreatePartition(p *metal.Partition) error {
+	return ms.createEntity("partition", p)
+}
+
+// DeletePartition deletes a partition.
+func (ms *MemoryStore) DeletePartition(p *metal.Partition) error {
+	return ms.deleteEntity("partition", p)
+}
+
+// UpdatePartition updates a partition.
+func (ms *MemoryStore) UpdatePartition(oldPartition *metal.Partition, newPartition *metal.Partition) error {
+	return ms.updateEntity("partition", newPartition, oldPartition)
+}
+
+// FindFilesystemLayout return a filesystemlayout for a given id.
+func (ms *MemoryStore) FindFilesystemLayout(id string) (*metal.FilesystemLayout, error) {
+	var fl metal.FilesystemLayout
+	err := ms.findEntityByID("filesystemlayout", &fl, id)
+	if err != nil {
+		return nil, err
+	}
+	return &fl, nil
+}
+
+// ListFilesystemLayouts returns all filesystemlayouts.
+func (ms *MemoryStore) ListFilesystemLayouts() (metal.FilesystemLayouts, error) {
+	fls := make(metal.FilesystemLayouts, 0)
+	err := ms.listEntities("filesystemlayout", &fls)
+	return fls, err
+}
+
+// CreateFilesystemLayout creates a new filesystemlayout.
+func (ms *MemoryStore) CreateFilesystemLayout(fl *metal.FilesystemLayout) error {
+	return ms.createEntity("filesystemlayout", fl)
+}
+
+// DeleteFilesystemLayout deletes a filesystemlayout.
+func (ms *MemoryStore) DeleteFilesystemLayout(fl *metal.FilesystemLayout) error {
+	return ms.deleteEntity("filesystemlayout", fl)
+}
+
+// UpdateFilesystemLayout updates a filesystemlayout.
+func (ms *MemoryStore) UpdateFilesystemLayout(oldFilesystemLayout *metal.FilesystemLayout, newFilesystemLayout *metal.FilesystemLayout) error {
+	return ms.updateEntity("filesystemlayout", newFilesystemLayout, oldFilesystemLayout)
+}
+
+// FindSizeImageConstraint return a SizeImageConstraint for a given size.
+func (ms *MemoryStore) FindSizeImageConstraint(sizeID string) (*metal.SizeImageConstraint, error) {
+	var ic metal.SizeImageConstraint
+	err := ms.findEntityByID("sizeimageconstraint", &ic, sizeID)
+	if err != nil {
+		return nil, err
+	}
+	return &ic, nil
+}
+
+// ListSizeImageConstraints returns all SizeImageConstraints.
+func (ms *MemoryStore) ListSizeImageConstraints() (metal.SizeImageConstraints, error) {
+	ics := make(metal.SizeImageConstraints, 0)
+	err := ms.listEntities("sizeimageconstraint", &ics)
+	return ics, err
+}
+
+// CreateSizeImageConstraint creates a new SizeImageConstraint.
+func (ms *MemoryStore) CreateSizeImageConstraint(ic *metal.SizeImageConstraint) error {
+	return ms.createEntity("sizeimageconstraint", ic)
+}
+
+// DeleteSizeImageConstraint deletes a SizeImageConstraint.
+func (ms *MemoryStore) DeleteSizeImageConstraint(ic *metal.SizeImageConstraint) error {
+	return ms.deleteEntity("sizeimageconstraint", ic)
+}
+
+// UpdateSizeImageConstraint updates a SizeImageConstraint.
+func (ms *MemoryStore) UpdateSizeImageConstraint(oldSizeImageConstraint *metal.SizeImageConstraint, newSizeImageConstraint *metal.SizeImageConstraint) error {
+	return ms.updateEntity("sizeimageconstraint", newSizeImageConstraint, oldSizeImageConstraint)
+}
+
+func (ms *MemoryStore) FindSizeReservation(id string) (*metal.SizeReservation, error) {
+	var rv metal.SizeReservation
+	err := ms.findEntityByID("sizereservation", &rv, id)
+	if err != nil {
+		return nil, err
+	}
+	return &rv, nil
+}
+
+func (ms *MemoryStore) SearchSizeReservations(q *SizeReservationSearchQuery, rvs *metal.SizeReservations) error {
+	all, err := ms.ListSizeReservations()
+	if err != nil {
+		return err
+	}
+	*rvs = filterEntities(all, q.matches)
+	return nil
+}
+
+func (ms *MemoryStore) ListSizeReservations() (metal.SizeReservations, error) {
+	rvs := make(metal.SizeReservations, 0)
+	err := ms.listEntities("sizereservation", &rvs)
+	return rvs, err
+}
+
+func (ms *MemoryStore) CreateSizeReservation(rv *metal.SizeReservation) error {
+	return ms.createEntity("sizereservation", rv)
+}
+
+func (ms *MemoryStore) DeleteSizeReservation(rv *metal.SizeReservation) error {
+	return ms.deleteEntity("sizereservation", rv)
+}
+
+func (ms *MemoryStore) UpdateSizeReservation(oldRv *metal.SizeReservation, newRv *metal.SizeReservation) error {
+	return ms.updateEntity("sizereservation", newRv, oldRv)
+}
+
+// FindPendingAllocation returns the pending allocation for the given id.
+func (ms *MemoryStore) FindPendingAllocation(id string) (*metal.PendingAllocation, error) {
+	var a metal.PendingAllocation
+	err := ms.findEntityByID("pendingallocation", &a, id)
+	if err != nil {
+		return nil, err
+	}
+	return &a, nil
+}
+
+// SearchPendingAllocations returns the result of the pending allocations search request query.
+func (ms *MemoryStore) SearchPendingAllocations(q *PendingAllocationSearchQuery, as *metal.PendingAllocations) error {
+	all, err := ms.ListPendingAllocations()
+	if err != nil {
+		return err
+	}
+	*as = filterEntities(all, q.matches)
+	return nil
+}
+
+// ListPendingAllocations returns all pending allocations.
+func (ms *MemoryStore) ListPendingAllocations() (metal.PendingAllocations, error) {
+	as := make(metal.PendingAllocations, 0)
+	err := ms.listEntities("pendingallocation", &as)
+	return as, err
+}
+
+// CreatePendingAllocation creates a new pending allocation.
+func (ms *MemoryStore) CreatePendingAllocation(a *metal.PendingAllocation) error {
+	return ms.createEntity("pendingallocation", a)
+}
+
+// DeletePendingAllocation deletes a pending allocation.
+func (ms *MemoryStore) DeletePendingAllocation(a *metal.PendingAllocation) error {
+	return ms.deleteEntity("pendingallocation", a)
+}
+
+// UpdatePendingAllocation updates a pending allocation, it fails with a conflict if the allocation was changed in the meantime.
+func (ms *MemoryStore) UpdatePendingAllocation(oldAllocation *metal.PendingAllocation, newAllocation *metal.PendingAllocation) error {
+	return ms.updateEntity("pendingallocation", newAllocation, oldAllocation)
+}
+
+// FindMachineRemediation returns the remediations of the machine with the given id.
+func (ms *MemoryStore) FindMachineRemediation(id string) (*metal.MachineRemediation, error) {
+	var mr metal.MachineRemediation
+	err := ms.findEntityByID("remediation", &mr, id)
+	if err != nil {
+		return nil, err
+	}
+	return &mr, nil
+}
+
+// ListMachineRemediations returns the remediations of all machines.
+func (ms *MemoryStore) ListMachineRemediations() (metal.MachineRemediations, error) {
+	mrs := make(metal.MachineRemediations, 0)
+	err := ms.listEntities("remediation", &mrs)
+	return mrs, err
+}
+
+// CreateMachineRemediation creates the remediations of a machine.
+func (ms *MemoryStore) CreateMachineRemediation(mr *metal.MachineRemediation) error {
+	return ms.createEntity("remediation", mr)
+}
+
+// DeleteMachineRemediation deletes the remediations of a machine.
+func (ms *MemoryStore) DeleteMachineRemediation(mr *metal.MachineRemediation) error {
+	return ms.deleteEntity("remediation", mr)
+}
+
+// UpdateMachineRemediation updates the remediations of a machine, it fails with a conflict if they were changed in the meantime.
+func (ms *MemoryStore) UpdateMachineRemediation(oldRemediation *metal.MachineRemediation, newRemediation *metal.MachineRemediation) error {
+	return ms.updateEntity("remediation", newRemediation, oldRemediation)
+}
+
+// FindMaintenanceWindow returns the maintenance window for the given id.
+func (ms *MemoryStore) FindMaintenanceWindow(id string) (*metal.MaintenanceWindow, error) {
+	var w metal.MaintenanceWindow
+	err := ms.findEntityByID("maintenancewindow", &w, id)
+	if err != nil {
+		return nil, err
+	}
+	return &w, nil
+}
+
+// SearchMaintenanceWindows returns the result of the maintenance windows search request query.
+func (ms *MemoryStore) SearchMaintenanceWindows(q *MaintenanceWindowSearchQuery, ws *metal.MaintenanceWindows) error {
+	all, err := ms.ListMaintenanceWindows()
+	if err != nil {
+		return err
+	}
+	*ws = filterEntities(all, q.matches)
+	return nil
+}
+
+// ListMaintenanceWindows returns all maintenance windows.
+func (ms *MemoryStore) ListMaintenanceWindows() (metal.MaintenanceWindows, error) {
+	ws := make(metal.MaintenanceWindows, 0)
+	err := ms.listEntities("maintenancewindow", &ws)
+	return ws, err
+}
+
+// CreateMaintenanceWindow creates a new maintenance window.
+func (ms *MemoryStore) CreateMaintenanceWindow(w *metal.MaintenanceWindow) error {
+	return ms.createEntity("maintenancewindow", w)
+}
+
+// DeleteMaintenanceWindow deletes a maintenance window.
+func (ms *MemoryStore) DeleteMaintenanceWindow(w *metal.MaintenanceWindow) error {
+	return ms.deleteEntity("maintenancewindow", w)
+}
+
+// UpdateMaintenanceWindow updates a maintenance window.
+func (ms *MemoryStore) UpdateMaintenanceWindow(oldWindow *metal.MaintenanceWindow, newWindow *metal.MaintenanceWindow) error {
+	return ms.updateEntity("maintenancewindow", newWindow, oldWindow)
+}
+
+// FindLocation returns the location for the given id.
+func (ms *MemoryStore) FindLocation(id string) (*metal.Location, error) {
+	var l metal.Location
+	err := ms.findEntityByID("location", &l, id)
+	if err != nil {
+		return nil, err
+	}
+	return &l, nil
+}
+
+// SearchLocations returns the result of the locations search request query.
+func (ms *MemoryStore) SearchLocations(q *LocationSearchQuery, ls *metal.Locations) error {
+	all, err := ms.ListLocations()
+	if err != nil {
+		return err
+	}
+	*ls = filterEntities(all, q.matches)
+	return nil
+}
+
+// ListLocations returns all locations.
+func (ms *MemoryStore) ListLocations() (metal.Locations, error) {
+	ls := make(metal.Locations, 0)
+	err := ms.listEntities("location", &ls)
+	return ls, err
+}
+
+// CreateLocation creates a new location.
+func (ms *MemoryStore) CreateLocation(l *metal.Location) error {
+	return ms.createEntity("location", l)
+}
+
+// DeleteLocation deletes a location.
+func (ms *MemoryStore) DeleteLocation(l *metal.Location) error {
+	return ms.deleteEntity("location", l)
+}
+
+// UpdateLocation updates a location.
+func (ms *MemoryStore) UpdateLocation(oldLocation *metal.Location, newLocation *metal.Location) error {
+	return ms.updateEntity("location", newLocation, oldLocation)
+}
+
+// FindOutboxEvent returns the outbox event for the given id.
+func (ms *MemoryStore) FindOutboxEvent(id string) (*metal.OutboxEvent, error) {
+	var e metal.OutboxEvent
+	err := ms.findEntityByID("outbox", &e, id)
+	if err != nil {
+		return nil, err
+	}
+	return &e, nil
+}
+
+// SearchOutboxEvents returns the result of the outbox events search request query.
+func (ms *MemoryStore) SearchOutboxEvents(q *OutboxEventSearchQuery, es *metal.OutboxEvents) error {
+	all := make(metal.OutboxEvents, 0)
+	err := ms.listEntities("outbox", &all)
+	if err != nil {
+		return err
+	}
+	*es = filterEntities(all, q.matches)
+	return nil
+}
+
+// CreateOutboxEvent creates a new outbox event.
+func (ms *MemoryStore) CreateOutboxEvent(e *metal.OutboxEvent) error {
+	return ms.createEntity("outbox", e)
+}
+
+// DeleteOutboxEvent deletes an outbox event.
+func (ms *MemoryStore) DeleteOutboxEvent(e *metal.OutboxEvent) error {
+	return ms.deleteEntity("outbox", e)
+}
+
+// UpdateOutboxEvent updates an outbox event, it fails with a conflict if it was changed in the meantime.
+func (ms *MemoryStore) UpdateOutboxEvent(oldEvent *metal.OutboxEvent, newEvent *metal.OutboxEvent) error {
+	return ms.updateEntity("outbox", newEvent, oldEvent)
+}
+
+// FindMachineCommandExecution returns the machine command execution for the given id.
+func (ms *MemoryStore) FindMachineCommandExecution(id string) (*metal.MachineCommandExecution, error) {
+	var e metal.MachineCommandExecution
+	err := ms.findEntityByID("machinecommand", &e, id)
+	if err != nil {
+		return nil, err
+	}
+	return &e, nil
+}
+
+// SearchMachineCommandExecutions returns the result of the machine command executions search request query.
+func (ms *MemoryStore) SearchMachineCommandExecutions(q *MachineCommandExecutionSearchQuery, es *metal.MachineCommandExecutions) error {
+	all := make(metal.MachineCommandExecutions, 0)
+	err := ms.listEntities("machinecommand", &all)
+	if err != nil {
+		return err
+	}
+	*es = filterEntities(all, q.matches)
+	return nil
+}
+
+// CreateMachineCommandExecution creates a new machine command execution.
+func (ms *MemoryStore) CreateMachineCommandExecution(e *metal.MachineCommandExecution) error {
+	return ms.createEntity("machinecommand", e)
+}
+
+// DeleteMachineCommandExecution deletes a machine command execution.
+func (ms *MemoryStore) DeleteMachineCommandExecution(e *metal.MachineCommandExecution) error {
+	return ms.deleteEntity("machinecommand", e)
+}
+
+// UpdateMachineCommandExecution updates a machine command execution, it fails with a conflict if it was changed in the meantime.
+func (ms *MemoryStore) UpdateMachineCommandExecution(oldExecution *metal.MachineCommandExecution, newExecution *metal.MachineCommandExecution) error {
+	return ms.updateEntity("machinecommand", newExecution, oldExecution)
+}
+
+// FindPowerSample returns the power sample for the given id.
+func (ms *MemoryStore) FindPowerSample(id string) (*metal.PowerSample, error) {
+	var s metal.PowerSample
+	err := ms.findEntityByID("powersample", &s, id)
+	if err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+// SearchPowerSamples returns the result of the power samples search request query.
+func (ms *MemoryStore) SearchPowerSamples(q *PowerSampleSearchQuery, ss *metal.PowerSamples) error {
+	all := make(metal.PowerSamples, 0)
+	err := ms.listEntities("powersample", &all)
+	if err != nil {
+		return err
+	}
+	*ss = filterEntities(all, q.matches)
+	return nil
+}
+
+// CreatePowerSample creates a new power sample.
+func (ms *MemoryStore) CreatePowerSample(s *metal.PowerSample) error {
+	return ms.createEntity("powersample", s)
+}
+
+// UpdatePowerSample updates a power sample, it fails with a conflict if it was changed in the meantime.
+func (ms *MemoryStore) UpdatePowerSample(oldSample *metal.PowerSample, newSample *metal.PowerSample) error {
+	return ms.updateEntity("powersample", newSample, oldSample)
+}
+
+// DeletePowerSample deletes a power sample, it returns a not found error if the sample does not exist anymore.
+// Like this, the deletion can be used to claim a sample when several metal-api instances process the same samples.
+func (ms *MemoryStore) DeletePowerSample(s *metal.PowerSample) error {
+	ms.mtx.Lock()
+	defer ms.mtx.Unlock()
+
+	old, ok := ms.tables["powersample"][s.ID]
+	if !ok {
+		return metal.NotFound("no power sample with id %q found", s.ID)
+	}
+
+	delete(ms.tables["powersample"], s.ID)
+	ms.notify("powersample", old, nil)
+
+	return nil
+}
+
+// DeletePowerSamplesBefore removes all power samples older than the given time and returns the amount of removed samples.
+func (ms *MemoryStore) DeletePowerSamplesBefore(t time.Time) (int, error) {
+	all := make(metal.PowerSamples, 0)
+	err := ms.listEntities("powersample", &all)
+	if err != nil {
+		return 0, err
+	}
+
+	deleted := 0
+	for _, s := range all {
+		if !s.Time.Before(t) {
+			continue
+		}
+		err := ms.deleteEntity("powersample", &s)
+		if err != nil {
+			return deleted, err
+		}
+		deleted++
+	}
+	return deleted, nil
+}
+
+// FindFirewallRuleRevision returns the given revision of the firewall rules of a firewall allocation.
+func (ms *MemoryStore) FindFirewallRuleRevision(allocationUUID string, revision int) (*metal.FirewallRuleRevision, error) {
+	var rev metal.FirewallRuleRevision
+	err := ms.findEntityByID("firewallrulerevision", &rev, metal.FirewallRuleRevisionID(allocationUUID, revision))
+	if err != nil {
+		return nil, err
+	}
+	return &rev, nil
+}
+
+// SearchFirewallRuleRevisions returns the result of the firewall rule revisions search request query.
+func (ms *MemoryStore) SearchFirewallRuleRevisions(q *FirewallRuleRevisionSearchQuery, revs *metal.FirewallRuleRevisions) error {
+	all := make(metal.FirewallRuleRevisions, 0)
+	err := ms.listEntities("firewallrulerevision", &all)
+	if err != nil {
+		return err
+	}
+	*revs = filterEntities(all, q.matches)
+	return nil
+}
+
+// CreateFirewallRuleRevision creates a new firewall rule revision, it fails if the revision already exists.
+func (ms *MemoryStore) CreateFirewallRuleRevision(rev *metal.FirewallRuleRevision) error {
+	rev.ID = metal.FirewallRuleRevisionID(rev.AllocationUUID, rev.Revision)
+	return ms.createEntity("firewallrulerevision", rev)
+}
+
+// DeleteFirewallRuleRevision deletes a firewall rule revision.
+func (ms *MemoryStore) DeleteFirewallRuleRevision(rev *metal.FirewallRuleRevision) error {
+	return ms.deleteEntity("firewallrulerevision", rev)
+}
+
+// FindHardwareSnapshot returns the given revision of the hardware snapshots of a machine.
+func (ms *MemoryStore) FindHardwareSnapshot(machineID string, revision int) (*metal.HardwareSnapshot, error) {
+	var s metal.HardwareSnapshot
+	err := ms.findEntityByID("hardwaresnapshot", &s, metal.HardwareSnapshotID(machineID, revision))
+	if err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+// SearchHardwareSnapshots returns the result of the hardware snapshots search request query.
+func (ms *MemoryStore) SearchHardwareSnapshots(q *HardwareSnapshotSearchQuery, snapshots *metal.HardwareSnapshots) error {
+	all := make(metal.HardwareSnapshots, 0)
+	err := ms.listEntities("hardwaresnapshot", &all)
+	if err != nil {
+		return err
+	}
+	*snapshots = filterEntities(all, q.matches)
+	return nil
+}
+
+// ListLatestHardwareSnapshots returns the latest hardware snapshot of every machine.
+func (ms *MemoryStore) ListLatestHardwareSnapshots() (metal.HardwareSnapshots, error) {
+	all := make(metal.HardwareSnapshots, 0)
+	err := ms.listEntities("hardwaresnapshot", &all)
+	if err != nil {
+		return nil, err
+	}
+
+	latest := make(metal.HardwareSnapshots, 0)
+	for _, s := range all.ByMachineID() {
+		latest = append(latest, s)
+	}
+	return latest, nil
+}
+
+// CreateHardwareSnapshot creates a new hardware snapshot, it fails if the revision already exists.
+func (ms *MemoryStore) CreateHardwareSnapshot(s *metal.HardwareSnapshot) error {
+	s.ID = metal.HardwareSnapshotID(s.MachineID, s.Revision)
+	return ms.createEntity("hardwaresnapshot", s)
+}
+
+// UpdateHardwareSnapshot updates a hardware snapshot, it fails with a conflict if it was changed in the meantime.
+func (ms *MemoryStore) UpdateHardwareSnapshot(oldSnapshot *metal.HardwareSnapshot, newSnapshot *metal.HardwareSnapshot) error {
+	return ms.updateEntity("hardwaresnapshot", newSnapshot, oldSnapshot)
+}
+
+// DeleteHardwareSnapshot deletes a hardware snapshot.
+func (ms *MemoryStore) DeleteHardwareSnapshot(s *metal.HardwareSnapshot) error {
+	return ms.deleteEntity("hardwaresnapshot", s)
+}
+
+// SearchProvisioningEventLog returns a page of the provisioning event log entries matching the query
+// and the cursor of the next page, which is nil on the last page.
+func (ms *MemoryStore) SearchProvisioningEventLog(q *ProvisioningEventLogSearchQuery) (metal.ProvisioningEventLogEntries, *string, error) {
+	match, err := q.matcher()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	all := make(metal.ProvisioningEventLogEntries, 0)
+	err = ms.listEntities("provisioningeventlog", &all)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	entries := filterEntities(all, match)
+	slices.SortFunc(entries, func(a, b metal.ProvisioningEventLogEntry) int {
+		if c := a.Time.Compare(b.Time); c != 0 {
+			return c
+		}
+		return strings.Compare(a.ID, b.ID)
+	})
+
+	entries, next := q.paginate(entries)
+	return entries, next, nil
+}
+
+// CreateProvisioningEventLogEntry appends an entry to the provisioning event log.
+func (ms *MemoryStore) CreateProvisioningEventLogEntry(e *metal.ProvisioningEventLogEntry) error {
+	return ms.createEntity("provisioningeventlog", e)
+}
+
+// DeleteProvisioningEventLogBefore removes all provisioning event log entries older than the given time and returns the amount of removed entries.
+func (ms *MemoryStore) DeleteProvisioningEventLogBefore(t time.Time) (int, error) {
+	all := make(metal.ProvisioningEventLogEntries, 0)
+	err := ms.listEntities("provisioningeventlog", &all)
+	if err != nil {
+		return 0, err
+	}
+
+	deleted := 0
+	for _, e := range all {
+		if !e.Time.Before(t) {
+			continue
+		}
+		err := ms.deleteEntity("provisioningeventlog", &e)
+		if err != nil {
+			return deleted, err
+		}
+		deleted++
+	}
+	return deleted, nil
+}
+
+// FindEntityChangeLogWriter returns the current writer of the entity change log.
+func (ms *MemoryStore) FindEntityChangeLogWriter() (*metal.EntityChangeLogWriter, error) {
+	var w metal.EntityChangeLogWriter
+	err := ms.findEntityByID("entitychangewriter", &w, metal.EntityChangeLogWriterID)
+	if err != nil {
+		return nil, err
+	}
+	return &w, nil
+}
+
+// CreateEntityChangeLogWriter creates the writer of the entity change log, it fails with a conflict if there is already a writer.
+func (ms *MemoryStore) CreateEntityChangeLogWriter(w *metal.EntityChangeLogWriter) error {
+	w.ID = metal.EntityChangeLogWriterID
+	return ms.createEntity("entitychangewriter", w)
+}
+
+// UpdateEntityChangeLogWriter updates the writer of the entity change log, it fails with a conflict if it was changed in the meantime.
+func (ms *MemoryStore) UpdateEntityChangeLogWriter(oldWriter *metal.EntityChangeLogWriter, newWriter *metal.EntityChangeLogWriter) error {
+	return ms.updateEntity("entitychangewriter", newWriter, oldWriter)
+}
+
+// ListEntityChanges returns at most limit entries of the given epoch following the given sequence in the order of their sequence.
+func (ms *MemoryStore) ListEntityChanges(epoch string, after uint64, limit int) (metal.EntityChangeLogEntries, error) {
+	all := make(metal.EntityChangeLogEntries, 0)
+	err := ms.listEntities("entitychange", &all)
+	if err != nil {
+		return nil, err
+	}
+
+	// the entities are listed in the order of their ids, which is the order of the sequence within an epoch
+	es := filterEntities(all, func(e *metal.EntityChangeLogEntry) bool {
+		return e.Epoch == epoch && e.Sequence > after
+	})
+	if len(es) > limit {
+		es = es[:limit]
+	}
+	return es, nil
+}
+
+// FindLatestEntityChange returns the entry of the given epoch with the highest sequence.
+func (ms *MemoryStore) FindLatestEntityChange(epoch string) (*metal.EntityChangeLogEntry, error) {
+	es, err := ms.ListEntityChanges(epoch, 0, math.MaxInt)
+	if err != nil {
+		return nil, err
+	}
+	if len(es) == 0 {
+		return nil, metal.NotFound("no entity change of epoch %q found", epoch)
+	}
+	return &es[len(es)-1], nil
+}
+
+// CreateEntityChange appends an entry to the entity change log.
+func (ms *MemoryStore) CreateEntityChange(e *metal.EntityChangeLogEntry) error {
+	e.ID = metal.EntityChangeLogEntryID(e.Epoch, e.Sequence)
+	return ms.createEntity("entitychange", e)
+}
+
+// DeleteEntityChangesBefore removes all entries of other epochs and the entries of the given epoch with a lower sequence
+// and returns the amount of removed entries.
+func (ms *MemoryStore) DeleteEntityChangesBefore(epoch string, sequence uint64) (int, error) {
+	all := make(metal.EntityChangeLogEntries, 0)
+	err := ms.listEntities("entitychange", &all)
+	if err != nil {
+		return 0, err
+	}
+
+	deleted := 0
+	for _, e := range all {
+		if e.Epoch == epoch && e.Sequence >= sequence {
+			continue
+		}
+		err := ms.deleteEntity("entitychange", &e)
+		if err != nil {
+			return deleted, err
+		}
+		deleted++
+	}
+	return deleted, nil
+}
+
+// ListProvisioningEventContainers returns all machine provisioning event containers.
+func (ms *MemoryStore) ListProvisioningEventContainers() (metal.ProvisioningEventContainers, error) {
+	es := make(metal.ProvisioningEventContainers, 0)
+	err := ms.listEntities("event", &es)
+	return es, err
+}
+
+// FindProvisioningEventContainer finds a provisioning event container to a given machine id.
+func (ms *MemoryStore) FindProvisioningEventContainer(id string) (*metal.ProvisioningEventContainer, error) {
+	var e metal.ProvisioningEventContainer
+	err := ms.findEntityByID("event", &e, id)
+	if err != nil {
+		return nil, err
+	}
+	return &e, nil
+}
+
+// FindProvisioningEventContainers returns the provisioning event containers of the given machine ids,
+// machines without an event container are skipped.
+func (ms *MemoryStore) FindProvisioningEventContainers(ids []string) (metal.ProvisioningEventContainers, error) {
+	es := make(metal.ProvisioningEventContainers, 0)
+	for _, id := range ids {
+		var e metal.ProvisioningEventContainer
+		err := ms.findEntityByID("event", &e, id)
+		if err != nil {
+			if metal.IsNotFound(err) {
+				continue
+			}
+			return nil, err
+		}
+		es = append(es, e)
+	}
+	return es, nil
+}
+
+// UpdateProvisioningEventContainer updates a provisioning event container.
+func (ms *MemoryStore) UpdateProvisioningEventContainer(old *metal.ProvisioningEventContainer, new *metal.ProvisioningEventContainer) error {
+	return ms.updateEntity("event", new, old)
+}
+
+// CreateProvisioningEventContainer creates a new provisioning event container.
+func (ms *MemoryStore) CreateProvisioningEventContainer(ec *metal.ProvisioningEventContainer) error {
+	return ms.createEntity("event", ec)
+}
+
+// UpsertProvisioningEventContainer inserts a machine's event container.
+func (ms *MemoryStore) UpsertProvisioningEventContainer(ec *metal.ProvisioningEventContainer) error {
+	return ms.upsertEntity("event", ec)
+}
+
+// ProvisioningEventForMachine applies the given provisioning event to the event container of the machine.
+func (ms *MemoryStore) ProvisioningEventForMachine(ctx context.Context, log *slog.Logger, event *metal.ProvisioningEvent, machineID string) (*metal.ProvisioningEventContainer, error) {
+	return provisioningEventForMachine(ctx, log, ms, event, machineID)
+}
+
+func (ms *MemoryStore) findEntityByID(table string, entity any, id string) error {
+	ms.mtx.RLock()
+	defer ms.mtx.RUnlock()
+
+	doc, ok := ms.tables[table][id]
+	if !ok {
+		return metal.NotFound("no %v with id %q found", getEntityName(entity), id)
+	}
+
+	return decodeDocument(doc, entity)
+}
+
+func (ms *MemoryStore) listEntities(table string, entity any) error {
+	ms.mtx.RLock()
+	defer ms.mtx.RUnlock()
+
+	ids := make([]string, 0, len(ms.tables[table]))
+	for id := range ms.tables[table] {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	docs := make([]any, 0, len(ids))
+	for _, id := range ids {
+		docs = append(docs, ms.tables[table][id])
+	}
+
+	err := decodeDocument(docs, entity)
+	if err != nil {
+		return fmt.Errorf("cannot fetch all entities: %w", err)
+	}
+	return nil
+}
+
+func (ms *MemoryStore) createEntity(table string, entity metal.Entity) error {
+	ms.mtx.Lock()
+	defer ms.mtx.Unlock()
+
+	if entity.GetID() == "" {
+		entity.SetID(uuid.NewString())
+	}
+
+	if _, ok := ms.tables[table][entity.GetID()]; ok {
+		return metal.Conflict("cannot create %v in database, entity already exists: %s", getEntityName(entity), entity.GetID())
+	}
+
+	now := time.Now()
+	entity.SetCreated(now)
+	entity.SetChanged(now)
+
+	return ms.put(table, entity)
+}
+
+func (ms *MemoryStore) upsertEntity(table string, entity metal.Entity) error {
+	ms.mtx.Lock()
+	defer ms.mtx.Unlock()
+
+	if entity.GetID() == "" {
+		entity.SetID(uuid.NewString())
+	}
+
+	now := time.Now()
+	if entity.GetCreated().IsZero() {
+		entity.SetCreated(now)
+	}
+	entity.SetChanged(now)
+
+	return ms.put(table, entity)
+}
+
+func (ms *MemoryStore) deleteEntity(table string, entity metal.Entity) error {
+	ms.mtx.Lock()
+	defer ms.mtx.Unlock()
+
+	old, ok := ms.tables[table][entity.GetID()]
+	if !ok {
+		return nil
+	}
+
+	delete(ms.tables[table], entity.GetID())
+	ms.notify(table, old, nil)
+
+	return nil
+}
+
+func (ms *MemoryStore) updateEntity(table string, newEntity metal.Entity, oldEntity metal.Entity) error {
+	ms.mtx.Lock()
+	defer ms.mtx.Unlock()
+
+	doc, ok := ms.tables[table][oldEntity.GetID()]
+	if !ok {
+		return metal.NotFound("cannot update %v (%s): entity does not exist", getEntityName(newEntity), oldEntity.GetID())
+	}
+
+	var stored metal.Base
+	err := decodeDocument(doc, &stored)
+	if err != nil {
+		return fmt.Errorf("cannot update %v (%s): %w", getEntityName(newEntity), oldEntity.GetID(), err)
+	}
+
+	// stored timestamps only have the precision of a float epoch, so they are compared with a small tolerance
+	if d := stored.Changed.Sub(oldEntity.GetChanged()).Abs(); d > time.Microsecond {
+		return metal.Conflict("cannot update %v (%s): %s", getEntityName(newEntity), oldEntity.GetID(), entityAlreadyModifiedErrorMessage)
+	}
+
+	newEntity.SetChanged(time.Now())
+
+	return ms.put(table, newEntity)
+}
+
+// put stores the document representation of the given entity, the caller must hold the write lock.
+func (ms *MemoryStore) put(table string, entity metal.Entity) error {
+	doc, err := encoding.Encode(entity)
+	if err != nil {
+		return fmt.Errorf("cannot store %v (%s): %w", getEntityName(entity), entity.GetID(), err)
+	}
+
+	if _, ok := ms.tables[table]; !ok {
+		ms.tables[table] = map[string]any{}
+	}
+	old := ms.tables[table][entity.GetID()]
+	ms.tables[table][entity.GetID()] = doc
+	ms.notify(table, old, doc)
+
+	return nil
+}
+
+// decodeDocument decodes a stored document into the given entity in the same way the rethinkdb driver does.
+func decodeDocument(doc any, entity any) error {
+	converted, err := convertPseudoTypes(doc)
+	if err != nil {
+		return err
+	}
+	return encoding.Decode(entity, converted)
+}
+
+// convertPseudoTypes converts rethinkdb pseudo types contained in an encoded document back into native types.
+func convertPseudoTypes(doc any) (any, error) {
+	switch d := doc.(type) {
+	case map[string]any:
+		switch d["$reql_type$"] {
+		case "TIME":
+			epoch, ok := d["epoch_time"].(float64)
+			if !ok {
+				return nil, fmt.Errorf("invalid time pseudo type: %v", d)
+			}
+			return epochToTime(epoch), nil
+		case "BINARY":
+			data, ok := d["data"].(string)
+			if !ok {
+				return nil, fmt.Errorf("invalid binary pseudo type: %v", d)
+			}
+			return base64.StdEncoding.DecodeString(data)
+		}
+
+		res := make(map[string]any, len(d))
+		for k, v := range d {
+			converted, err := convertPseudoTypes(v)
+			if err != nil {
+				return nil, err
+			}
+			res[k] = converted
+		}
+		return res, nil
+	case []any:
+		res := make([]any, 0, len(d))
+		for _, v := range d {
+			converted, err := convertPseudoTypes(v)
+			if err != nil {
+				return nil, err
+			}
+			res = append(res, converted)
+		}
+		return res, nil
+	default:
+		return doc, nil
+	}
+}
+
+// epochToTime converts the epoch of a time pseudo type with microsecond precision, seconds and fraction are
+// converted separately because times before 1678 (e.g. the zero time) do not fit into nanoseconds since epoch.
+func epochToTime(epoch float64) time.Time {
+	sec, frac := math.Modf(epoch)
+	return time.Unix(int64(sec), int64(math.Round(frac*1e6))*int64(time.Microsecond)).UTC()
+}
+
+func filterEntities[E any](entities []E, match func(*E) bool) []E {
+	res := make([]E, 0)
+	for i := range entities {
+		if match(&entities[i]) {
+			res = append(res, entities[i])
+		}
+	}
+	return res
+}
+
+func findOne[E any](entities []E, entity *E) error {
+	switch len(entities) {
+	case 0:
+		return metal.NotFound("no %v found", getEntityName(entity))
+	case 1:
+		*entity = entities[0]
+		return nil
+	default:
+		return fmt.Errorf("more than one %v exists", getEntityName(entity))
+	}
+}
+
+// memoryIntegerPool manages unique integers in memory.
+type memoryIntegerPool struct {
+	poolType IntegerPoolType
+	min      uint
+	max      uint
+
+	mtx      sync.Mutex
+	acquired map[uint]bool
+}
+
+func (p *memoryIntegerPool) String() string {
+	return p.poolType.String()
+}
+
+// AcquireRandomUniqueInteger returns a random unique integer from the pool.
+func (p *memoryIntegerPool) AcquireRandomUniqueInteger() (uint, error) {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+
+	size := p.max - p.min + 1
+	if uint(len(p.acquired)) >= size {
+		return 0, metal.Internal("acquisition of a value failed for exhausted pool")
+	}
+
+	// start at a random position and take the next free integer
+	offset := rand.N(size) //nolint:gosec
+	for i := range size {
+		value := p.min + (offset+i)%size
+		if !p.acquired[value] {
+			p.acquired[value] = true
+			return value, nil
+		}
+	}
+
+	return 0, errors.New("unable to find a free integer in pool")
+}
+
+// AcquireUniqueInteger returns a unique integer from the pool.
+func (p *memoryIntegerPool) AcquireUniqueInteger(value uint) (uint, error) {
+	err := p.verifyRange(value)
+	if err != nil {
+		return 0, err
+	}
+
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+
+	if p.acquired[value] {
+		return 0, metal.Conflict("integer is already acquired by another")
+	}
+	p.acquired[value] = true
+
+	return value, nil
+}
+
+// ReleaseUniqueInteger returns a unique integer to the pool.
+func (p *memoryIntegerPool) ReleaseUniqueInteger(value uint) error {
+	err := p.verifyRange(value)
+	if err != nil {
+		return err
+	}
+
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+
+	delete(p.acquired, value)
+
+	return nil
+}
+
+// Usage returns the amount of free and used integers of the pool.
+func (p *memoryIntegerPool) Usage() (*IntegerPoolUsage, error) {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+
+	used := uint(len(p.acquired))
+
+	return &IntegerPoolUsage{
+		Free: p.max - p.min + 1 - used,
+		Used: used,
+	}, nil
+}
+
+func (p *memoryIntegerPool) verifyRange(value uint) error {
+	if value < p.min || value > p.max {
+		return fmt.Errorf("value '%d' is outside of the allowed range '%d - %d'", value, p.min, p.max)
+	}
+	return nil
+}
@@ -0,0 +1,621 @@
+package datastore
+
+import (
+	"fmt"
+	"net/netip"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/metal-stack/metal-api/cmd/metal-api/internal/metal"
+	"github.com/metal-stack/metal-lib/pkg/tag"
+)
+
+// the matchers in this file are the in-memory counterparts of the generateTerm functions
+// of the search queries, they must be kept in sync with the rethinkdb filters.
+
+func (p *MachineSearchQuery) matches(m *metal.Machine) bool {
+	if p.ID != nil && m.ID != *p.ID {
+		return false
+	}
+	if p.Name != nil && m.Name != *p.Name {
+		return false
+	}
+	if p.PartitionID != nil && m.PartitionID != *p.PartitionID {
+		return false
+	}
+	if p.SizeID != nil && m.SizeID != *p.SizeID {
+		return false
+	}
+	if p.RackID != nil && m.RackID != *p.RackID {
+		return false
+	}
+	for _, t := range p.Tags {
+		if !slices.Contains(m.Tags, t) {
+			return false
+		}
+	}
+
+	if !p.matchesAllocation(m.Allocation) {
+		return false
+	}
+
+	if p.HardwareMemory != nil && int64(m.Hardware.Memory) != *p.HardwareMemory { //nolint:gosec
+		return false
+	}
+
+	for _, mac := range p.NicsMacAddresses {
+		if !slices.ContainsFunc(m.Hardware.Nics, func(nic metal.Nic) bool { return string(nic.MacAddress) == mac }) {
+			return false
+		}
+	}
+	for _, name := range p.NicsNames {
+		if !slices.ContainsFunc(m.Hardware.Nics, func(nic metal.Nic) bool { return nic.Name == name }) {
+			return false
+		}
+	}
+	for _, vrf := range p.NicsVrfs {
+		if !slices.ContainsFunc(m.Hardware.Nics, func(nic metal.Nic) bool { return nic.Vrf == vrf }) {
+			return false
+		}
+	}
+	for _, mac := range p.NicsNeighborMacAddresses {
+		if !containsNeighbor(m.Hardware.Nics, func(neigh metal.Nic) bool { return string(neigh.MacAddress) == mac }) {
+			return false
+		}
+	}
+	for _, name := range p.NicsNeighborNames {
+		if !containsNeighbor(m.Hardware.Nics, func(neigh metal.Nic) bool { return neigh.Name == name }) {
+			return false
+		}
+	}
+	for _, vrf := range p.NicsNeighborVrfs {
+		if !containsNeighbor(m.Hardware.Nics, func(neigh metal.Nic) bool { return neigh.Vrf == vrf }) {
+			return false
+		}
+	}
+
+	for _, name := range p.DiskNames {
+		if !slices.ContainsFunc(m.Hardware.Disks, func(bd metal.BlockDevice) bool { return bd.Name == name }) {
+			return false
+		}
+	}
+	for _, size := range p.DiskSizes {
+		if !slices.ContainsFunc(m.Hardware.Disks, func(bd metal.BlockDevice) bool { return int64(bd.Size) == size }) { //nolint:gosec
+			return false
+		}
+	}
+
+	if p.StateValue != nil && string(m.State.Value) != *p.StateValue {
+		return false
+	}
+
+	if p.IpmiAddress != nil && m.IPMI.Address != *p.IpmiAddress {
+		return false
+	}
+	if p.IpmiMacAddress != nil && m.IPMI.MacAddress != *p.IpmiMacAddress {
+		return false
+	}
+	if p.IpmiUser != nil && m.IPMI.User != *p.IpmiUser {
+		return false
+	}
+	if p.IpmiInterface != nil && m.IPMI.Interface != *p.IpmiInterface {
+		return false
+	}
+
+	fru := m.IPMI.Fru
+	for _, f := range []struct {
+		want *string
+		got  string
+	}{
+		{want: p.FruChassisPartNumber, got: fru.ChassisPartNumber},
+		{want: p.FruChassisPartSerial, got: fru.ChassisPartSerial},
+		{want: p.FruBoardMfg, got: fru.BoardMfg},
+		{want: p.FruBoardMfgSerial, got: fru.BoardMfgSerial},
+		{want: p.FruBoardPartNumber, got: fru.BoardPartNumber},
+		{want: p.FruProductManufacturer, got: fru.ProductManufacturer},
+		{want: p.FruProductPartNumber, got: fru.ProductPartNumber},
+		{want: p.FruProductSerial, got: fru.ProductSerial},
+	} {
+		if f.want != nil && f.got != *f.want {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (p *MachineSearchQuery) matchesAllocation(alloc *metal.MachineAllocation) bool {
+	filtersAllocation := p.AllocationName != nil || p.AllocationProject != nil || p.AllocationImageID != nil ||
+		p.AllocationHostname != nil || p.AllocationRole != nil || p.AllocationSucceeded != nil ||
+		p.AllocationLeased != nil || p.AllocationLeaseExpiresBefore != nil ||
+		len(p.NetworkIDs) > 0 || len(p.NetworkPrefixes) > 0 || len(p.NetworkIPs) > 0 ||
+		len(p.NetworkDestinationPrefixes) > 0 || len(p.NetworkVrfs) > 0 || len(p.NetworkASNs) > 0
+
+	if !filtersAllocation {
+		return true
+	}
+	if alloc == nil {
+		// rethinkdb does not match fields of a non-existing allocation
+		return false
+	}
+
+	if p.AllocationName != nil && alloc.Name != *p.AllocationName {
+		return false
+	}
+	if p.AllocationProject != nil && alloc.Project != *p.AllocationProject {
+		return false
+	}
+	if p.AllocationImageID != nil && alloc.ImageID != *p.AllocationImageID {
+		return false
+	}
+	if p.AllocationHostname != nil && alloc.Hostname != *p.AllocationHostname {
+		return false
+	}
+	if p.AllocationRole != nil && alloc.Role != *p.AllocationRole {
+		return false
+	}
+	if p.AllocationSucceeded != nil && alloc.Succeeded != *p.AllocationSucceeded {
+		return false
+	}
+	if p.AllocationLeased != nil && (alloc.Lease != nil) != *p.AllocationLeased {
+		return false
+	}
+	if p.AllocationLeaseExpiresBefore != nil && (alloc.Lease == nil || !alloc.Lease.Expires.Before(*p.AllocationLeaseExpiresBefore)) {
+		return false
+	}
+
+	containsNetwork := func(match func(nw *metal.MachineNetwork) bool) bool {
+		return slices.ContainsFunc(alloc.MachineNetworks, func(nw *metal.MachineNetwork) bool {
+			return nw != nil && match(nw)
+		})
+	}
+
+	for _, id := range p.NetworkIDs {
+		if !containsNetwork(func(nw *metal.MachineNetwork) bool { return nw.NetworkID == id }) {
+			return false
+		}
+	}
+	for _, prefix := range p.NetworkPrefixes {
+		if !containsNetwork(func(nw *metal.MachineNetwork) bool { return slices.Contains(nw.Prefixes, prefix) }) {
+			return false
+		}
+	}
+	for _, ip := range p.NetworkIPs {
+		if !containsNetwork(func(nw *metal.MachineNetwork) bool { return slices.Contains(nw.IPs, ip) }) {
+			return false
+		}
+	}
+	for _, destPrefix := range p.NetworkDestinationPrefixes {
+		if !containsNetwork(func(nw *metal.MachineNetwork) bool { return slices.Contains(nw.DestinationPrefixes, destPrefix) }) {
+			return false
+		}
+	}
+	for _, vrf := range p.NetworkVrfs {
+		if !containsNetwork(func(nw *metal.MachineNetwork) bool { return int64(nw.Vrf) == vrf }) { //nolint:gosec
+			return false
+		}
+	}
+	for _, asn := range p.NetworkASNs {
+		if !containsNetwork(func(nw *metal.MachineNetwork) bool { return int64(nw.ASN) == asn }) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func containsNeighbor(nics metal.Nics, match func(neigh metal.Nic) bool) bool {
+	return slices.ContainsFunc(nics, func(nic metal.Nic) bool {
+		return slices.ContainsFunc(nic.Neighbors, match)
+	})
+}
+
+func (p *NetworkSearchQuery) matcher() (func(*metal.Network) bool, error) {
+	type prefixMatch struct {
+		ip     string
+		length string
+	}
+
+	parsePrefixes := func(prefixes []string) ([]prefixMatch, error) {
+		var res []prefixMatch
+		for _, prefix := range prefixes {
+			pfx, err := netip.ParsePrefix(prefix)
+			if err != nil {
+				return nil, fmt.Errorf("unable to parse prefix %w", err)
+			}
+			res = append(res, prefixMatch{ip: pfx.Addr().String(), length: strconv.Itoa(pfx.Bits())})
+		}
+		return res, nil
+	}
+
+	prefixes, err := parsePrefixes(p.Prefixes)
+	if err != nil {
+		return nil, err
+	}
+	destPrefixes, err := parsePrefixes(p.DestinationPrefixes)
+	if err != nil {
+		return nil, err
+	}
+
+	var separator string
+	if p.AddressFamily != nil {
+		af, err := metal.ToAddressFamily(*p.AddressFamily)
+		if err != nil {
+			return nil, err
+		}
+		switch af {
+		case metal.IPv4AddressFamily:
+			separator = "."
+		case metal.IPv6AddressFamily:
+			separator = ":"
+		case metal.InvalidAddressFamily:
+			return nil, fmt.Errorf("given addressfamily is invalid:%s", af)
+		}
+	}
+
+	// like the rethinkdb query, ip and length of a prefix are matched independently of each other
+	containsPrefix := func(ps metal.Prefixes, want prefixMatch) bool {
+		return slices.ContainsFunc(ps, func(p metal.Prefix) bool { return p.IP == want.ip }) &&
+			slices.ContainsFunc(ps, func(p metal.Prefix) bool { return p.Length == want.length })
+	}
+
+	return func(nw *metal.Network) bool {
+		if p.ID != nil && nw.ID != *p.ID {
+			return false
+		}
+		if p.ProjectID != nil && nw.ProjectID != *p.ProjectID {
+			return false
+		}
+		if p.PartitionID != nil && nw.PartitionID != *p.PartitionID {
+			return false
+		}
+		if p.ParentNetworkID != nil && nw.ParentNetworkID != *p.ParentNetworkID {
+			return false
+		}
+		if p.Name != nil && nw.Name != *p.Name {
+			return false
+		}
+		if p.Vrf != nil && int64(nw.Vrf) != *p.Vrf { //nolint:gosec
+			return false
+		}
+		if p.Nat != nil && nw.Nat != *p.Nat {
+			return false
+		}
+		if p.PrivateSuper != nil && nw.PrivateSuper != *p.PrivateSuper {
+			return false
+		}
+		if p.Underlay != nil && nw.Underlay != *p.Underlay {
+			return false
+		}
+		if !matchesLabels(nw.Labels, p.Labels) {
+			return false
+		}
+		for _, want := range prefixes {
+			if !containsPrefix(nw.Prefixes, want) {
+				return false
+			}
+		}
+		for _, want := range destPrefixes {
+			if !containsPrefix(nw.DestinationPrefixes, want) {
+				return false
+			}
+		}
+		if separator != "" && !slices.ContainsFunc(nw.Prefixes, func(p metal.Prefix) bool { return strings.Contains(p.IP, separator) }) {
+			return false
+		}
+		return true
+	}, nil
+}
+
+func (p *IPSearchQuery) matcher() (func(*metal.IP) bool, error) {
+	tags := slices.Clone(p.Tags)
+	if p.MachineID != nil {
+		tags = append(tags, metal.IpTag(tag.MachineID, *p.MachineID))
+	}
+
+	var separator string
+	if p.AddressFamily != nil {
+		af, err := metal.ToAddressFamily(*p.AddressFamily)
+		if err != nil {
+			return nil, err
+		}
+		switch af {
+		case metal.IPv4AddressFamily:
+			separator = "."
+		case metal.IPv6AddressFamily:
+			separator = ":"
+		case metal.InvalidAddressFamily:
+			return nil, fmt.Errorf("given addressfamily is invalid:%s", af)
+		}
+	}
+
+	return func(ip *metal.IP) bool {
+		if p.IPAddress != nil && ip.IPAddress != *p.IPAddress {
+			return false
+		}
+		if p.AllocationUUID != nil && ip.AllocationUUID != *p.AllocationUUID {
+			return false
+		}
+		if p.Name != nil && ip.Name != *p.Name {
+			return false
+		}
+		if p.ProjectID != nil && ip.ProjectID != *p.ProjectID {
+			return false
+		}
+		if p.NetworkID != nil && ip.NetworkID != *p.NetworkID {
+			return false
+		}
+		if p.ParentPrefixCidr != nil && ip.ParentPrefixCidr != *p.ParentPrefixCidr {
+			return false
+		}
+		for _, t := range tags {
+			if !slices.Contains(ip.Tags, t) {
+				return false
+			}
+		}
+		if p.Type != nil && string(ip.Type) != *p.Type {
+			return false
+		}
+		if separator != "" && !strings.Contains(ip.IPAddress, separator) {
+			return false
+		}
+		return true
+	}, nil
+}
+
+func (s *SizeSearchQuery) matches(size *metal.Size) bool {
+	if s.ID != nil && size.ID != *s.ID {
+		return false
+	}
+	if s.Name != nil && size.Name != *s.Name {
+		return false
+	}
+	if !matchesLabels(size.Labels, s.Labels) {
+		return false
+	}
+	if s.Reservation.Project != nil || s.Reservation.Partition != nil {
+		// reservations are not stored inside sizes anymore, so the rethinkdb query never matches as well
+		return false
+	}
+	return true
+}
+
+func (s *SizeReservationSearchQuery) matches(rv *metal.SizeReservation) bool {
+	if s.ID != nil && rv.ID != *s.ID {
+		return false
+	}
+	if s.SizeID != nil && rv.SizeID != *s.SizeID {
+		return false
+	}
+	if s.Name != nil && rv.Name != *s.Name {
+		return false
+	}
+	if !matchesLabels(rv.Labels, s.Labels) {
+		return false
+	}
+	if s.Project != nil && rv.ProjectID != *s.Project {
+		return false
+	}
+	if s.Partition != nil && !slices.Contains(rv.PartitionIDs, *s.Partition) {
+		return false
+	}
+	return true
+}
+
+func (p *FirewallRuleRevisionSearchQuery) matches(rev *metal.FirewallRuleRevision) bool {
+	if p.MachineID != nil && rev.MachineID != *p.MachineID {
+		return false
+	}
+	if p.AllocationUUID != nil && rev.AllocationUUID != *p.AllocationUUID {
+		return false
+	}
+	if p.Revision != nil && rev.Revision != *p.Revision {
+		return false
+	}
+	return true
+}
+
+func (p *HardwareSnapshotSearchQuery) matches(s *metal.HardwareSnapshot) bool {
+	if p.MachineID != nil && s.MachineID != *p.MachineID {
+		return false
+	}
+	if p.Revision != nil && s.Revision != *p.Revision {
+		return false
+	}
+	return true
+}
+
+func (p *ProvisioningEventLogSearchQuery) matcher() (func(*metal.ProvisioningEventLogEntry) bool, error) {
+	cursor, err := p.cursor()
+	if err != nil {
+		return nil, err
+	}
+	_, err = p.limit()
+	if err != nil {
+		return nil, err
+	}
+
+	return func(e *metal.ProvisioningEventLogEntry) bool {
+		if p.MachineID != nil && e.MachineID != *p.MachineID {
+			return false
+		}
+		if p.From != nil && e.Time.Before(*p.From) {
+			return false
+		}
+		if p.To != nil && !e.Time.Before(*p.To) {
+			return false
+		}
+		if p.Event != nil && string(e.Event) != *p.Event {
+			return false
+		}
+		if p.Message != nil && !strings.Contains(e.Message, *p.Message) {
+			return false
+		}
+		if cursor != nil && (e.Time.Before(cursor.time) || (e.Time.Equal(cursor.time) && e.ID <= cursor.id)) {
+			return false
+		}
+		return true
+	}, nil
+}
+
+func (p *PendingAllocationSearchQuery) matches(a *metal.PendingAllocation) bool {
+	if p.ProjectID != nil && a.ProjectID != *p.ProjectID {
+		return false
+	}
+	if p.PartitionID != nil && a.PartitionID != *p.PartitionID {
+		return false
+	}
+	if p.SizeID != nil && a.SizeID != *p.SizeID {
+		return false
+	}
+	if p.State != nil && string(a.State) != *p.State {
+		return false
+	}
+	return true
+}
+
+func (p *MaintenanceWindowSearchQuery) matches(w *metal.MaintenanceWindow) bool {
+	if p.ID != nil && w.ID != *p.ID {
+		return false
+	}
+	if p.Scope != nil && string(w.Scope) != *p.Scope {
+		return false
+	}
+	if p.PartitionID != nil && w.PartitionID != *p.PartitionID {
+		return false
+	}
+	if p.RackID != nil && w.RackID != *p.RackID {
+		return false
+	}
+	if p.MachineID != nil && w.MachineID != *p.MachineID {
+		return false
+	}
+	return true
+}
+
+func (p *LocationSearchQuery) matches(l *metal.Location) bool {
+	if p.ID != nil && l.ID != *p.ID {
+		return false
+	}
+	if p.Level != nil && string(l.Level) != *p.Level {
+		return false
+	}
+	if p.ParentID != nil && l.ParentID != *p.ParentID {
+		return false
+	}
+	if p.PowerFeedID != nil && l.PowerFeedID != *p.PowerFeedID {
+		return false
+	}
+	return true
+}
+
+func (p *OutboxEventSearchQuery) matches(e *metal.OutboxEvent) bool {
+	if p.ID != nil && e.ID != *p.ID {
+		return false
+	}
+	if p.Topic != nil && e.Topic != *p.Topic {
+		return false
+	}
+	if p.State != nil && string(e.State) != *p.State {
+		return false
+	}
+	return true
+}
+
+func (p *MachineCommandExecutionSearchQuery) matches(e *metal.MachineCommandExecution) bool {
+	if p.ID != nil && e.ID != *p.ID {
+		return false
+	}
+	if p.MachineID != nil && e.MachineID != *p.MachineID {
+		return false
+	}
+	if p.Status != nil && string(e.Status) != *p.Status {
+		return false
+	}
+	return true
+}
+
+func (p *PowerSampleSearchQuery) matches(s *metal.PowerSample) bool {
+	if p.MachineID != nil && s.MachineID != *p.MachineID {
+		return false
+	}
+	if p.PartitionID != nil && s.PartitionID != *p.PartitionID {
+		return false
+	}
+	if p.RackID != nil && s.RackID != *p.RackID {
+		return false
+	}
+	if p.SizeID != nil && s.SizeID != *p.SizeID {
+		return false
+	}
+	if p.ProjectID != nil && s.ProjectID != *p.ProjectID {
+		return false
+	}
+	if p.Resolution != nil && s.Resolution != *p.Resolution {
+		return false
+	}
+	if p.From != nil && s.Time.Before(*p.From) {
+		return false
+	}
+	if p.To != nil && !s.Time.Before(*p.To) {
+		return false
+	}
+	return true
+}
+
+func (p *ImageSearchQuery) matches(i *metal.Image) bool {
+	if p.ID != nil && i.ID != *p.ID {
+		return false
+	}
+	if p.Name != nil && i.Name != *p.Name {
+		return false
+	}
+	if p.OS != nil && i.OS != *p.OS {
+		return false
+	}
+	if p.Version != nil && i.Version != *p.Version {
+		return false
+	}
+	if p.Classification != nil && string(i.Classification) != *p.Classification {
+		return false
+	}
+	for _, f := range p.Features {
+		if _, ok := i.Features[metal.ImageFeatureType(f)]; !ok {
+			return false
+		}
+	}
+	return true
+}
+
+func (p *SwitchSearchQuery) matches(s *metal.Switch) bool {
+	if p.ID != nil && s.ID != *p.ID {
+		return false
+	}
+	if p.Name != nil && s.Name != *p.Name {
+		return false
+	}
+	if p.PartitionID != nil && s.PartitionID != *p.PartitionID {
+		return false
+	}
+	if p.RackID != nil && s.RackID != *p.RackID {
+		return false
+	}
+	if p.OSVendor != nil && (s.OS == nil || string(s.OS.Vendor) != *p.OSVendor) {
+		return false
+	}
+	if p.OSVersion != nil && (s.OS == nil || s.OS.Version != *p.OSVersion) {
+		return false
+	}
+	return true
+}
+
+func matchesLabels(labels, want map[string]string) bool {
+	for k, v := range want {
+		got, ok := labels[k]
+		if !ok || got != v {
+			return false
+		}
+	}
+	return true
+}
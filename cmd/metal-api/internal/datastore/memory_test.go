@@ -0,0 +1,697 @@
+package datastore
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/metal-stack/metal-api/cmd/metal-api/internal/metal"
+	"github.com/metal-stack/metal-lib/pkg/testcommon"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestMemoryStore(t *testing.T) *MemoryStore {
+	ms := NewMemory(slog.New(slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelError})))
+	require.NoError(t, ms.Initialize())
+	return ms
+}
+
+func TestMemoryStore_CRUD(t *testing.T) {
+	ms := newTestMemoryStore(t)
+
+	m := &metal.Machine{Base: metal.Base{ID: "1", Name: "a"}, Tags: []string{"x"}}
+	require.NoError(t, ms.CreateMachine(m))
+	assert.False(t, m.Created.IsZero())
+
+	err := ms.CreateMachine(&metal.Machine{Base: metal.Base{ID: "1"}})
+	require.True(t, metal.IsConflict(err), "expected conflict, got %v", err)
+
+	err = ms.CreateMachine(&metal.Machine{Base: metal.Base{ID: "2"}, Allocation: &metal.MachineAllocation{}})
+	require.Error(t, err)
+
+	got, err := ms.FindMachineByID("1")
+	require.NoError(t, err)
+	assert.Equal(t, "a", got.Name)
+
+	// the store must not share memory with its callers
+	got.Tags[0] = "y"
+	again, err := ms.FindMachineByID("1")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"x"}, again.Tags)
+
+	updated := *again
+	updated.Name = "b"
+	require.NoError(t, ms.UpdateMachine(again, &updated))
+
+	// the old entity is outdated now
+	stale := *again
+	stale.Name = "c"
+	err = ms.UpdateMachine(again, &stale)
+	require.True(t, metal.IsConflict(err), "expected conflict, got %v", err)
+
+	// an entity can be updated again with the result of the previous update
+	next := updated
+	next.Name = "d"
+	require.NoError(t, ms.UpdateMachine(&updated, &next))
+
+	machines, err := ms.ListMachines()
+	require.NoError(t, err)
+	require.Len(t, machines, 1)
+	assert.Equal(t, "d", machines[0].Name)
+
+	require.NoError(t, ms.DeleteMachine(&next))
+
+	_, err = ms.FindMachineByID("1")
+	if diff := cmp.Diff(metal.NotFound(`no machine with id "1" found`), err, testcommon.ErrorStringComparer()); diff != "" {
+		t.Errorf("error diff (-want +got):\n%s", diff)
+	}
+}
+
+func TestMemoryStore_CreateGeneratesID(t *testing.T) {
+	ms := newTestMemoryStore(t)
+
+	ip := &metal.IP{IPAddress: "1.2.3.4"}
+	require.NoError(t, ms.CreateIP(ip))
+	assert.NotEmpty(t, ip.AllocationUUID)
+
+	fl := &metal.SizeReservation{SizeID: "c1"}
+	require.NoError(t, ms.CreateSizeReservation(fl))
+	assert.NotEmpty(t, fl.ID)
+}
+
+func TestMemoryStore_SearchMachines(t *testing.T) {
+	ms := newTestMemoryStore(t)
+
+	for _, m := range []*metal.Machine{
+		{Base: metal.Base{ID: "1"}, PartitionID: "a", Tags: []string{"t1", "t2"}},
+		{Base: metal.Base{ID: "2"}, PartitionID: "b", Tags: []string{"t1"}},
+		{Base: metal.Base{ID: "3"}, PartitionID: "b", Hardware: metal.MachineHardware{Nics: metal.Nics{{Name: "lan0", Neighbors: metal.Nics{{MacAddress: "aa:bb"}}}}}},
+	} {
+		require.NoError(t, ms.CreateMachine(m))
+	}
+
+	allocated, err := ms.FindMachineByID("3")
+	require.NoError(t, err)
+	newMachine := *allocated
+	newMachine.Allocation = &metal.MachineAllocation{
+		Project:         "p1",
+		MachineNetworks: []*metal.MachineNetwork{{NetworkID: "n1", IPs: []string{"10.0.0.1"}}},
+		Lease:           &metal.MachineLease{Expires: time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)},
+	}
+	require.NoError(t, ms.UpdateMachine(allocated, &newMachine))
+
+	tests := []struct {
+		name string
+		q    *MachineSearchQuery
+		want []string
+	}{
+		{
+			name: "empty query",
+			q:    &MachineSearchQuery{},
+			want: []string{"1", "2", "3"},
+		},
+		{
+			name: "by partition",
+			q:    &MachineSearchQuery{PartitionID: new("b")},
+			want: []string{"2", "3"},
+		},
+		{
+			name: "by tags",
+			q:    &MachineSearchQuery{Tags: []string{"t1", "t2"}},
+			want: []string{"1"},
+		},
+		{
+			name: "by allocation project",
+			q:    &MachineSearchQuery{AllocationProject: new("p1")},
+			want: []string{"3"},
+		},
+		{
+			name: "by network ip",
+			q:    &MachineSearchQuery{NetworkIPs: []string{"10.0.0.1"}},
+			want: []string{"3"},
+		},
+		{
+			name: "by neighbor mac",
+			q:    &MachineSearchQuery{NicsNeighborMacAddresses: []string{"aa:bb"}},
+			want: []string{"3"},
+		},
+		{
+			name: "by lease expiry",
+			q:    &MachineSearchQuery{AllocationLeased: new(true), AllocationLeaseExpiresBefore: new(time.Date(2030, 1, 2, 0, 0, 0, 0, time.UTC))},
+			want: []string{"3"},
+		},
+		{
+			name: "unleased allocations",
+			q:    &MachineSearchQuery{AllocationLeased: new(false)},
+			want: []string{},
+		},
+		{
+			name: "no match",
+			q:    &MachineSearchQuery{PartitionID: new("a"), AllocationProject: new("p1")},
+			want: []string{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got metal.Machines
+			err := ms.SearchMachines(tt.q, &got)
+			require.NoError(t, err)
+
+			ids := []string{}
+			for _, m := range got {
+				ids = append(ids, m.ID)
+			}
+			assert.Equal(t, tt.want, ids)
+		})
+	}
+
+	var m metal.Machine
+	err = ms.FindMachine(&MachineSearchQuery{PartitionID: new("b")}, &m)
+	require.EqualError(t, err, "more than one machine exists")
+
+	err = ms.FindMachine(&MachineSearchQuery{PartitionID: new("a")}, &m)
+	require.NoError(t, err)
+	assert.Equal(t, "1", m.ID)
+}
+
+func TestMemoryStore_SearchNetworksAndIPs(t *testing.T) {
+	ms := newTestMemoryStore(t)
+
+	require.NoError(t, ms.CreateNetwork(&metal.Network{Base: metal.Base{ID: "v4"}, Prefixes: metal.Prefixes{{IP: "10.0.0.0", Length: "8"}}, Labels: map[string]string{"a": "b"}}))
+	require.NoError(t, ms.CreateNetwork(&metal.Network{Base: metal.Base{ID: "v6"}, Prefixes: metal.Prefixes{{IP: "2001::", Length: "64"}}}))
+	require.NoError(t, ms.CreateIP(&metal.IP{IPAddress: "10.0.0.1", NetworkID: "v4", Tags: []string{"machine.metal-stack.io/id=m1"}}))
+	require.NoError(t, ms.CreateIP(&metal.IP{IPAddress: "2001::1", NetworkID: "v6"}))
+
+	var nws metal.Networks
+	require.NoError(t, ms.SearchNetworks(&NetworkSearchQuery{Prefixes: []string{"10.0.0.0/8"}}, &nws))
+	require.Len(t, nws, 1)
+	assert.Equal(t, "v4", nws[0].ID)
+
+	require.NoError(t, ms.SearchNetworks(&NetworkSearchQuery{AddressFamily: new(string(metal.IPv6AddressFamily))}, &nws))
+	require.Len(t, nws, 1)
+	assert.Equal(t, "v6", nws[0].ID)
+
+	require.NoError(t, ms.SearchNetworks(&NetworkSearchQuery{Labels: map[string]string{"a": "c"}}, &nws))
+	assert.Empty(t, nws)
+
+	require.Error(t, ms.SearchNetworks(&NetworkSearchQuery{Prefixes: []string{"invalid"}}, &nws))
+
+	var ips metal.IPs
+	require.NoError(t, ms.SearchIPs(&IPSearchQuery{MachineID: new("m1")}, &ips))
+	require.Len(t, ips, 1)
+	assert.Equal(t, "10.0.0.1", ips[0].IPAddress)
+
+	require.NoError(t, ms.SearchIPs(&IPSearchQuery{AddressFamily: new(string(metal.IPv6AddressFamily))}, &ips))
+	require.Len(t, ips, 1)
+	assert.Equal(t, "2001::1", ips[0].IPAddress)
+
+	q := &IPSearchQuery{Paging: Paging{Limit: new(uint64(1)), SortBy: new("network"), SortDescending: new(true)}}
+	require.NoError(t, ms.SearchIPs(q, &ips))
+	require.Len(t, ips, 1)
+	assert.Equal(t, "2001::1", ips[0].IPAddress)
+	q.Cursor = new(q.NextCursor(ips))
+	require.NoError(t, ms.SearchIPs(q, &ips))
+	require.Len(t, ips, 1)
+	assert.Equal(t, "10.0.0.1", ips[0].IPAddress)
+
+	require.NoError(t, ms.SearchNetworks(&NetworkSearchQuery{Paging: Paging{Offset: new(uint64(1))}}, &nws))
+	require.Len(t, nws, 1)
+	assert.Equal(t, "v6", nws[0].ID)
+	require.Error(t, (&NetworkSearchQuery{Paging: Paging{SortBy: new("vrf")}}).Validate())
+}
+
+func TestMemoryStore_IntegerPool(t *testing.T) {
+	ms := newTestMemoryStore(t)
+	ms.VRFPoolRangeMin = 10
+	ms.VRFPoolRangeMax = 12
+	ms.pools = map[IntegerPoolType]*memoryIntegerPool{}
+
+	pool := ms.GetVRFPool()
+
+	got, err := pool.AcquireUniqueInteger(11)
+	require.NoError(t, err)
+	assert.Equal(t, uint(11), got)
+
+	_, err = pool.AcquireUniqueInteger(11)
+	require.True(t, metal.IsConflict(err), "expected conflict, got %v", err)
+
+	_, err = pool.AcquireUniqueInteger(13)
+	require.EqualError(t, err, "value '13' is outside of the allowed range '10 - 12'")
+
+	seen := map[uint]bool{11: true}
+	for range 2 {
+		got, err := pool.AcquireRandomUniqueInteger()
+		require.NoError(t, err)
+		assert.False(t, seen[got], "integer %d acquired twice", got)
+		seen[got] = true
+	}
+
+	_, err = pool.AcquireRandomUniqueInteger()
+	require.True(t, metal.IsInternal(err), "expected exhausted pool, got %v", err)
+
+	usage, err := pool.Usage()
+	require.NoError(t, err)
+	assert.Equal(t, &IntegerPoolUsage{Free: 0, Used: 3}, usage)
+
+	require.NoError(t, pool.ReleaseUniqueInteger(11))
+
+	usage, err = pool.Usage()
+	require.NoError(t, err)
+	assert.Equal(t, &IntegerPoolUsage{Free: 1, Used: 2}, usage)
+
+	got, err = pool.AcquireRandomUniqueInteger()
+	require.NoError(t, err)
+	assert.Equal(t, uint(11), got)
+}
+
+func TestMemoryStore_FindWaitingMachine(t *testing.T) {
+	ms := newTestMemoryStore(t)
+
+	size := metal.Size{Base: metal.Base{ID: "c1"}}
+
+	for _, id := range []string{"1", "2", "3"} {
+		require.NoError(t, ms.CreateMachine(&metal.Machine{
+			Base:        metal.Base{ID: id},
+			PartitionID: "partition",
+			SizeID:      size.ID,
+			Waiting:     true,
+		}))
+		require.NoError(t, ms.CreateProvisioningEventContainer(&metal.ProvisioningEventContainer{
+			Base:       metal.Base{ID: id},
+			Liveliness: metal.MachineLivelinessAlive,
+		}))
+	}
+
+	// a dead machine must never be chosen
+	ec, err := ms.FindProvisioningEventContainer("3")
+	require.NoError(t, err)
+	dead := *ec
+	dead.Liveliness = metal.MachineLivelinessDead
+	require.NoError(t, ms.UpdateProvisioningEventContainer(ec, &dead))
+
+	var (
+		wg     sync.WaitGroup
+		mtx    sync.Mutex
+		chosen = map[string]bool{}
+	)
+	for range 3 {
+		wg.Go(func() {
+			m, err := ms.FindWaitingMachine(context.Background(), "project", "partition", size, metal.Placement{}, metal.RoleMachine)
+			if err != nil {
+				assert.EqualError(t, err, "no machine available")
+				return
+			}
+			assert.True(t, m.PreAllocated)
+
+			mtx.Lock()
+			defer mtx.Unlock()
+			assert.False(t, chosen[m.ID], "machine %s was chosen twice", m.ID)
+			chosen[m.ID] = true
+		})
+	}
+	wg.Wait()
+
+	assert.Equal(t, map[string]bool{"1": true, "2": true}, chosen)
+}
+
+func TestExplainWaitingMachine(t *testing.T) {
+	ms := newTestMemoryStore(t)
+
+	size := metal.Size{Base: metal.Base{ID: "c1"}}
+
+	machines := []metal.Machine{
+		{Base: metal.Base{ID: "1"}, RackID: "rack-1", Waiting: true},
+		{Base: metal.Base{ID: "2"}, RackID: "rack-2", Waiting: true},
+		{Base: metal.Base{ID: "3"}, RackID: "rack-1", Waiting: true, State: metal.MachineState{Value: metal.LockedState, Description: "broken disk"}},
+		{Base: metal.Base{ID: "4"}, RackID: "rack-1"},
+		{Base: metal.Base{ID: "5"}, RackID: "rack-1", Waiting: true},
+		{Base: metal.Base{ID: "6"}, RackID: "rack-2", Waiting: true},
+	}
+	for _, m := range machines {
+		m.PartitionID = "partition"
+		m.SizeID = size.ID
+		require.NoError(t, ms.CreateMachine(&m))
+		require.NoError(t, ms.CreateProvisioningEventContainer(&metal.ProvisioningEventContainer{
+			Base:       metal.Base{ID: m.ID},
+			Liveliness: metal.MachineLivelinessAlive,
+		}))
+	}
+
+	ec, err := ms.FindProvisioningEventContainer("5")
+	require.NoError(t, err)
+	dead := *ec
+	dead.Liveliness = metal.MachineLivelinessDead
+	require.NoError(t, ms.UpdateProvisioningEventContainer(ec, &dead))
+
+	// the project already has a machine in rack-2, so rack-1 is preferred
+	m, err := ms.FindMachineByID("6")
+	require.NoError(t, err)
+	allocated := *m
+	allocated.Allocation = &metal.MachineAllocation{Project: "project", Role: metal.RoleMachine}
+	require.NoError(t, ms.UpdateMachine(m, &allocated))
+
+	stages, candidates, err := ExplainWaitingMachine(ms.log, ms, "project", "partition", size, metal.Placement{}, metal.RoleMachine)
+	require.NoError(t, err)
+
+	require.Equal(t, MachineCandidateStages{
+		{Name: "partition-and-size", Remaining: 6},
+		{Name: "unallocated", Remaining: 5, Dropped: []DroppedMachineCandidate{{MachineID: "6", Reason: "machine is allocated by project project"}}},
+		{Name: "state", Remaining: 4, Dropped: []DroppedMachineCandidate{{MachineID: "3", Reason: "machine state is LOCKED: broken disk"}}},
+		{Name: "waiting", Remaining: 3, Dropped: []DroppedMachineCandidate{{MachineID: "4", Reason: "machine is not waiting for an allocation"}}},
+		{Name: "not-preallocated", Remaining: 3},
+		{Name: "liveliness", Remaining: 2, Dropped: []DroppedMachineCandidate{{MachineID: "5", Reason: "machine liveliness is Dead"}}},
+		{Name: "size-reservations", Remaining: 2},
+		{Name: "rack-spreading", Remaining: 1, Dropped: []DroppedMachineCandidate{{MachineID: "2", Reason: `rack "rack-2" is not among the least occupied racks of the project and placement tags`}}},
+	}, stages)
+	require.Len(t, candidates, 1)
+	require.Equal(t, "1", candidates[0].ID)
+
+	// nothing was preallocated
+	m, err = ms.FindMachineByID("1")
+	require.NoError(t, err)
+	require.False(t, m.PreAllocated)
+
+	require.NoError(t, ms.CreateSizeReservation(&metal.SizeReservation{
+		Base:         metal.Base{ID: "r1"},
+		SizeID:       size.ID,
+		Amount:       2,
+		ProjectID:    "other-project",
+		PartitionIDs: []string{"partition"},
+	}))
+
+	stages, candidates, err = ExplainWaitingMachine(ms.log, ms, "project", "partition", size, metal.Placement{}, metal.RoleMachine)
+	require.NoError(t, err)
+	require.Empty(t, candidates)
+	last := stages[len(stages)-1]
+	require.Equal(t, "size-reservations", last.Name)
+	require.Equal(t, 0, last.Remaining)
+	require.Len(t, last.Dropped, 2)
+}
+
+func TestExplainWaitingMachine_RackPowerBudget(t *testing.T) {
+	ms := newTestMemoryStore(t)
+
+	size := metal.Size{Base: metal.Base{ID: "c1"}, ExpectedPowerWatts: 400}
+	require.NoError(t, ms.CreateSize(&size))
+	other := metal.Size{Base: metal.Base{ID: "c2"}, ExpectedPowerWatts: 700}
+	require.NoError(t, ms.CreateSize(&other))
+	require.NoError(t, ms.CreatePartition(&metal.Partition{
+		Base:            metal.Base{ID: "partition"},
+		RackPowerBudget: &metal.RackPowerBudget{DefaultWatts: 1000, RackWatts: map[string]uint{"rack-3": 0}},
+	}))
+
+	machines := []metal.Machine{
+		{Base: metal.Base{ID: "1"}, RackID: "rack-1", Waiting: true, IPMI: metal.IPMI{PowerState: "ON", PowerMetric: &metal.PowerMetric{AverageConsumedWatts: 100}}},
+		{Base: metal.Base{ID: "2"}, RackID: "rack-2", Waiting: true, IPMI: metal.IPMI{PowerState: "ON", PowerMetric: &metal.PowerMetric{AverageConsumedWatts: 100}}},
+		{Base: metal.Base{ID: "3"}, RackID: "rack-3", Waiting: true},
+		// allocated machines which are powered off account for the expected power draw of their size
+		{Base: metal.Base{ID: "4"}, RackID: "rack-1", IPMI: metal.IPMI{PowerState: "OFF"}, Allocation: &metal.MachineAllocation{Project: "other"}},
+		{Base: metal.Base{ID: "5"}, RackID: "rack-1", IPMI: metal.IPMI{PowerState: "ON", PowerMetric: &metal.PowerMetric{AverageConsumedWatts: 350}}, Allocation: &metal.MachineAllocation{Project: "other"}},
+		{Base: metal.Base{ID: "6"}, RackID: "rack-3", Allocation: &metal.MachineAllocation{Project: "other"}},
+		{Base: metal.Base{ID: "7"}, RackID: "rack-3", Allocation: &metal.MachineAllocation{Project: "other"}},
+		{Base: metal.Base{ID: "8"}, RackID: "rack-3", Allocation: &metal.MachineAllocation{Project: "other"}},
+		// machines of other sizes account for the expected power draw of their size as well
+		{Base: metal.Base{ID: "9"}, SizeID: other.ID, RackID: "rack-2", IPMI: metal.IPMI{PowerState: "OFF"}, Allocation: &metal.MachineAllocation{Project: "other"}},
+	}
+	for _, m := range machines {
+		m.PartitionID = "partition"
+		if m.SizeID == "" {
+			m.SizeID = size.ID
+		}
+		allocation := m.Allocation
+		m.Allocation = nil
+		require.NoError(t, ms.CreateMachine(&m))
+		require.NoError(t, ms.CreateProvisioningEventContainer(&metal.ProvisioningEventContainer{
+			Base:       metal.Base{ID: m.ID},
+			Liveliness: metal.MachineLivelinessAlive,
+		}))
+
+		if allocation != nil {
+			allocated := m
+			allocated.Allocation = allocation
+			require.NoError(t, ms.UpdateMachine(&m, &allocated))
+		}
+	}
+
+	stages, candidates, err := ExplainWaitingMachine(ms.log, ms, "project", "partition", size, metal.Placement{}, metal.RoleMachine)
+	require.NoError(t, err)
+
+	// rack-1 consumes 100W + 400W + 400W, the allocation would add another 300W
+	// rack-2 consumes 100W + 700W of the machine with the other size
+	require.Contains(t, stages, MachineCandidateStage{Name: "rack-power-budget", Remaining: 1, Dropped: []DroppedMachineCandidate{
+		{MachineID: "1", Reason: `rack "rack-1" would consume 1200W which exceeds its power budget of 1000W`},
+		{MachineID: "2", Reason: `rack "rack-2" would consume 1100W which exceeds its power budget of 1000W`},
+	}})
+	require.Len(t, candidates, 1)
+
+	m, err := ms.FindWaitingMachine(context.Background(), "project", "partition", size, metal.Placement{}, metal.RoleMachine)
+	require.NoError(t, err)
+	require.Equal(t, "3", m.ID)
+}
+
+func TestMemoryStore_Watch(t *testing.T) {
+	ds := newTestMemoryStore(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	changes, err := ds.Watch(ctx, "machine")
+	require.NoError(t, err)
+
+	_, err = ds.Watch(ctx, "image")
+	require.EqualError(t, err, `table "image" cannot be watched`)
+
+	m := &metal.Machine{Base: metal.Base{ID: "m1", Name: "a"}}
+	require.NoError(t, ds.CreateMachine(m))
+
+	updated := *m
+	updated.Name = "b"
+	require.NoError(t, ds.UpdateMachine(m, &updated))
+	require.NoError(t, ds.DeleteMachine(&updated))
+
+	// not watched
+	require.NoError(t, ds.CreateNetwork(&metal.Network{Base: metal.Base{ID: "n1"}}))
+
+	for _, want := range []struct {
+		typ     metal.EventType
+		oldName *string
+		newName *string
+	}{
+		{typ: metal.CREATE, newName: new("a")},
+		{typ: metal.UPDATE, oldName: new("a"), newName: new("b")},
+		{typ: metal.DELETE, oldName: new("b")},
+	} {
+		change := <-changes
+		require.Equal(t, "machine", change.Table)
+		require.Equal(t, want.typ, change.Type)
+		require.Equal(t, "m1", change.ID())
+		if want.oldName != nil {
+			require.Equal(t, *want.oldName, change.Old.(*metal.Machine).Name)
+		} else {
+			require.Nil(t, change.Old)
+		}
+		if want.newName != nil {
+			require.Equal(t, *want.newName, change.New.(*metal.Machine).Name)
+		} else {
+			require.Nil(t, change.New)
+		}
+	}
+
+	cancel()
+	for range changes {
+		// drain until the channel is closed
+	}
+}
+
+func TestMemoryStore_ProvisioningEventLog(t *testing.T) {
+	testProvisioningEventLog(t, newTestMemoryStore(t))
+}
+
+// testProvisioningEventLog is shared with the rethinkdb integration test.
+func testProvisioningEventLog(t *testing.T, ds Store) {
+	var (
+		ctx   = context.Background()
+		log   = slog.New(slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelError}))
+		start = time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	)
+
+	for i, ev := range []metal.ProvisioningEventType{
+		metal.ProvisioningEventPXEBooting,
+		metal.ProvisioningEventPreparing,
+		metal.ProvisioningEventAlive,
+		metal.ProvisioningEventRegistering,
+		metal.ProvisioningEventWaiting,
+	} {
+		for _, machineID := range []string{"m1", "m2"} {
+			_, err := ds.ProvisioningEventForMachine(ctx, log, &metal.ProvisioningEvent{
+				Time:    start.Add(time.Duration(i) * time.Minute),
+				Event:   ev,
+				Message: "event of " + machineID,
+			}, machineID)
+			require.NoError(t, err)
+		}
+	}
+
+	entries, next, err := ds.SearchProvisioningEventLog(&ProvisioningEventLogSearchQuery{MachineID: new("m1")})
+	require.NoError(t, err)
+	require.Nil(t, next)
+	require.Len(t, entries, 4, "alive events must not be logged")
+	require.Equal(t, metal.ProvisioningEventPXEBooting, entries[0].Event)
+	require.Equal(t, metal.ProvisioningEventWaiting, entries[3].Event)
+
+	var (
+		all    metal.ProvisioningEventLogEntries
+		cursor *string
+	)
+	for {
+		page, next, err := ds.SearchProvisioningEventLog(&ProvisioningEventLogSearchQuery{Limit: new(3), Cursor: cursor})
+		require.NoError(t, err)
+		all = append(all, page...)
+		if next == nil {
+			break
+		}
+		require.Len(t, page, 3)
+		cursor = next
+	}
+	require.Len(t, all, 8)
+	for i := 1; i < len(all); i++ {
+		require.False(t, all[i].Time.Before(all[i-1].Time), "entries must be in chronological order")
+		require.NotEqual(t, all[i].ID, all[i-1].ID)
+	}
+
+	entries, _, err = ds.SearchProvisioningEventLog(&ProvisioningEventLogSearchQuery{
+		From:    new(start.Add(time.Minute)),
+		To:      new(start.Add(4 * time.Minute)),
+		Event:   new(string(metal.ProvisioningEventRegistering)),
+		Message: new("of m2"),
+	})
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	require.Equal(t, "m2", entries[0].MachineID)
+
+	_, _, err = ds.SearchProvisioningEventLog(&ProvisioningEventLogSearchQuery{Cursor: new("not-a-cursor")})
+	require.Error(t, err)
+	_, _, err = ds.SearchProvisioningEventLog(&ProvisioningEventLogSearchQuery{Limit: new(0)})
+	require.EqualError(t, err, "limit must be between 1 and 1000")
+
+	deleted, err := ds.DeleteProvisioningEventLogBefore(start.Add(2 * time.Minute))
+	require.NoError(t, err)
+	require.Equal(t, 4, deleted)
+
+	entries, _, err = ds.SearchProvisioningEventLog(&ProvisioningEventLogSearchQuery{})
+	require.NoError(t, err)
+	require.Len(t, entries, 4)
+}
+
+func TestMemoryStore_EntityChangeLog(t *testing.T) {
+	testEntityChangeLog(t, newTestMemoryStore(t))
+}
+
+// testEntityChangeLog is shared with the rethinkdb integration test.
+func testEntityChangeLog(t *testing.T, ds Store) {
+	_, err := ds.FindEntityChangeLogWriter()
+	require.True(t, metal.IsNotFound(err))
+
+	w := &metal.EntityChangeLogWriter{Instance: "a", Epoch: "e1", Expires: time.Now().Add(time.Minute)}
+	require.NoError(t, ds.CreateEntityChangeLogWriter(w))
+	err = ds.CreateEntityChangeLogWriter(&metal.EntityChangeLogWriter{Instance: "b", Epoch: "e2"})
+	require.True(t, metal.IsConflict(err))
+
+	current, err := ds.FindEntityChangeLogWriter()
+	require.NoError(t, err)
+	require.Equal(t, "a", current.Instance)
+
+	taken := *current
+	taken.Instance = "b"
+	require.NoError(t, ds.UpdateEntityChangeLogWriter(current, &taken))
+	err = ds.UpdateEntityChangeLogWriter(current, &metal.EntityChangeLogWriter{Base: current.Base, Instance: "c"})
+	require.True(t, metal.IsConflict(err), "the writer must only be claimed once")
+
+	_, err = ds.FindLatestEntityChange("e1")
+	require.True(t, metal.IsNotFound(err))
+
+	for _, e := range []metal.EntityChangeLogEntry{
+		{Epoch: "e1", Sequence: 9, Kind: "machine", EntityID: "m1"},
+		{Epoch: "e1", Sequence: 10, Kind: "machine", EntityID: "m2"},
+		{Epoch: "e1", Sequence: 11, Kind: "ip", EntityID: "ip1"},
+		{Epoch: "e2", Sequence: 1, Kind: "machine", EntityID: "m3"},
+	} {
+		require.NoError(t, ds.CreateEntityChange(&e))
+	}
+
+	entries, err := ds.ListEntityChanges("e1", 9, 100)
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+	require.Equal(t, uint64(10), entries[0].Sequence, "entries must be ordered by their sequence")
+	require.Equal(t, uint64(11), entries[1].Sequence)
+
+	entries, err = ds.ListEntityChanges("e1", 0, 1)
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	require.Equal(t, "m1", entries[0].EntityID)
+
+	latest, err := ds.FindLatestEntityChange("e1")
+	require.NoError(t, err)
+	require.Equal(t, uint64(11), latest.Sequence)
+
+	deleted, err := ds.DeleteEntityChangesBefore("e1", 11)
+	require.NoError(t, err)
+	require.Equal(t, 3, deleted)
+
+	entries, err = ds.ListEntityChanges("e1", 0, 100)
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	require.Equal(t, "ip1", entries[0].EntityID)
+}
+
+func TestExplainWaitingMachine_PlacementStrategy(t *testing.T) {
+	ms := newTestMemoryStore(t)
+
+	size := metal.Size{Base: metal.Base{ID: "c1"}}
+	require.NoError(t, ms.CreatePartition(&metal.Partition{
+		Base:              metal.Base{ID: "partition"},
+		PlacementStrategy: metal.PlacementStrategyBinPacking,
+	}))
+
+	machines := []metal.Machine{
+		{Base: metal.Base{ID: "1"}, RackID: "rack-1", Waiting: true},
+		{Base: metal.Base{ID: "2"}, RackID: "rack-2", Waiting: true},
+		{Base: metal.Base{ID: "3"}, RackID: "rack-2"},
+	}
+	for _, m := range machines {
+		m.PartitionID = "partition"
+		m.SizeID = size.ID
+		require.NoError(t, ms.CreateMachine(&m))
+		require.NoError(t, ms.CreateProvisioningEventContainer(&metal.ProvisioningEventContainer{
+			Base:       metal.Base{ID: m.ID},
+			Liveliness: metal.MachineLivelinessAlive,
+		}))
+	}
+
+	m, err := ms.FindMachineByID("3")
+	require.NoError(t, err)
+	allocated := *m
+	allocated.Allocation = &metal.MachineAllocation{Project: "project", Role: metal.RoleMachine}
+	require.NoError(t, ms.UpdateMachine(m, &allocated))
+
+	// the partition packs the machines of the project into rack-2
+	stages, candidates, err := ExplainWaitingMachine(ms.log, ms, "project", "partition", size, metal.Placement{}, metal.RoleMachine)
+	require.NoError(t, err)
+	require.Equal(t, MachineCandidateStage{Name: "bin-packing", Remaining: 1, Dropped: []DroppedMachineCandidate{
+		{MachineID: "1", Reason: `rack "rack-1" is not among the most occupied racks of the project`},
+	}}, stages[len(stages)-1])
+	require.Len(t, candidates, 1)
+	require.Equal(t, "2", candidates[0].ID)
+
+	// the strategy of the allocation takes precedence over the one of the partition
+	stages, candidates, err = ExplainWaitingMachine(ms.log, ms, "project", "partition", size, metal.Placement{Strategy: metal.PlacementStrategyRackSpreading}, metal.RoleMachine)
+	require.NoError(t, err)
+	require.Equal(t, "rack-spreading", stages[len(stages)-1].Name)
+	require.Len(t, candidates, 1)
+	require.Equal(t, "1", candidates[0].ID)
+}
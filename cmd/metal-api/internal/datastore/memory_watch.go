@@ -0,0 +1,127 @@
+package datastore
+
+import (
+	"context"
+	"sync"
+)
+
+// memoryWatcher queues the changes for a single watch of the memory store.
+//
+// Changes are queued without limit because they are recorded while the store holds its write lock,
+// a slow consumer must never block writes to the store.
+type memoryWatcher struct {
+	tables map[string]bool
+
+	mtx    sync.Mutex
+	queue  []EntityChange
+	signal chan struct{}
+}
+
+// Watch streams the changes of entities in the given tables until the context is canceled.
+func (ms *MemoryStore) Watch(ctx context.Context, tables ...string) (<-chan EntityChange, error) {
+	err := verifyWatchableTables(tables)
+	if err != nil {
+		return nil, err
+	}
+
+	w := &memoryWatcher{
+		tables: map[string]bool{},
+		signal: make(chan struct{}, 1),
+	}
+	for _, t := range tables {
+		w.tables[t] = true
+	}
+
+	ms.watchMtx.Lock()
+	ms.watchers[w] = true
+	ms.watchMtx.Unlock()
+
+	res := make(chan EntityChange)
+
+	go func() {
+		defer func() {
+			ms.watchMtx.Lock()
+			delete(ms.watchers, w)
+			ms.watchMtx.Unlock()
+			close(res)
+		}()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-w.signal:
+			}
+
+			w.mtx.Lock()
+			changes := w.queue
+			w.queue = nil
+			w.mtx.Unlock()
+
+			for _, change := range changes {
+				select {
+				case res <- change:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+
+	return res, nil
+}
+
+// notify passes the change of a document to all watchers of the table, the caller must hold the write lock.
+func (ms *MemoryStore) notify(table string, oldDoc, newDoc any) {
+	if _, ok := watchableTables[table]; !ok {
+		return
+	}
+
+	ms.watchMtx.Lock()
+	defer ms.watchMtx.Unlock()
+
+	var change *EntityChange
+	for w := range ms.watchers {
+		if !w.tables[table] {
+			continue
+		}
+
+		if change == nil {
+			var err error
+			change, err = ms.entityChange(table, oldDoc, newDoc)
+			if err != nil {
+				ms.log.Error("unable to notify watchers about change", "table", table, "error", err)
+				return
+			}
+			if change == nil {
+				return
+			}
+		}
+
+		w.mtx.Lock()
+		w.queue = append(w.queue, *change)
+		w.mtx.Unlock()
+
+		select {
+		case w.signal <- struct{}{}:
+		default:
+		}
+	}
+}
+
+func (ms *MemoryStore) entityChange(table string, oldDoc, newDoc any) (*EntityChange, error) {
+	var err error
+	if oldDoc != nil {
+		oldDoc, err = convertPseudoTypes(oldDoc)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if newDoc != nil {
+		newDoc, err = convertPseudoTypes(newDoc)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return newEntityChange(table, oldDoc, newDoc)
+}
@@ -0,0 +1,35 @@
+package migrations
+
+import (
+	r "gopkg.in/rethinkdb/rethinkdb-go.v6"
+
+	"github.com/metal-stack/metal-api/cmd/metal-api/internal/datastore"
+	"github.com/metal-stack/metal-api/cmd/metal-api/internal/metal"
+)
+
+func init() {
+	datastore.MustRegisterMigration(datastore.Migration{
+		Name:    "store the firewall rules given at allocation time as first firewall rule revision",
+		Version: 11,
+		Up: func(db *r.Term, session r.QueryExecutor, rs *datastore.RethinkStore) error {
+			ms, err := rs.ListMachines()
+			if err != nil {
+				return err
+			}
+
+			for i := range ms {
+				m := ms[i]
+
+				if !m.IsFirewall() || m.Allocation.UUID == "" {
+					continue
+				}
+
+				err = rs.CreateFirewallRuleRevision(metal.InitialFirewallRuleRevision(&m))
+				if err != nil && !metal.IsConflict(err) {
+					return err
+				}
+			}
+			return nil
+		},
+	})
+}
@@ -25,6 +25,8 @@ type NetworkSearchQuery struct {
 	ParentNetworkID     *string           `json:"parentnetworkid" optional:"true"`
 	Labels              map[string]string `json:"labels" optional:"true"`
 	AddressFamily       *string           `json:"addressfamily" optional:"true" enum:"IPv4|IPv6"`
+
+	Paging
 }
 
 func (p *NetworkSearchQuery) Validate() error {
@@ -41,12 +43,21 @@ func (p *NetworkSearchQuery) Validate() error {
 			errs = append(errs, err)
 		}
 	}
+	if err := validatePaging(&p.Paging, networkSortKeys); err != nil {
+		errs = append(errs, err)
+	}
 	if len(errs) == 0 {
 		return nil
 	}
 	return errors.Join(errs...)
 }
 
+// NextCursor returns the cursor of the page following the given networks which were found with this query,
+// it is empty if there are no further pages.
+func (p *NetworkSearchQuery) NextCursor(nws metal.Networks) string {
+	return nextCursor(&p.Paging, networkSortKeys, nws)
+}
+
 // GenerateTerm generates the project search query term.
 func (p *NetworkSearchQuery) generateTerm(rs *RethinkStore) (*r.Term, error) {
 	q := *rs.networkTable()
@@ -204,7 +215,11 @@ func (rs *RethinkStore) SearchNetworks(q *NetworkSearchQuery, ns *metal.Networks
 	if err != nil {
 		return err
 	}
-	return rs.searchEntities(term, ns)
+	paged, err := pageTerm(*term, &q.Paging, networkSortKeys)
+	if err != nil {
+		return err
+	}
+	return rs.searchEntities(&paged, ns)
 }
 
 // ListNetworks returns all networks.
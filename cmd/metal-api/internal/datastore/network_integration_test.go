@@ -12,7 +12,7 @@ import (
 type networkTestable struct{}
 
 func (_ *networkTestable) wipe() error {
-	_, err := sharedDS.networkTable().Delete().RunWrite(sharedDS.session)
+	err := wipeTable(sharedDS, "network")
 	return err
 }
 
@@ -69,387 +69,399 @@ func (_ *networkTestable) defaultBody(n *metal.Network) *metal.Network {
 	return n
 }
 
-func TestRethinkStore_FindNetwork(t *testing.T) {
-	tt := &networkTestable{}
-	defer func() {
-		require.NoError(t, tt.wipe())
-	}()
+func TestStore_FindNetwork(t *testing.T) {
+	forEachStore(t, func(t *testing.T) {
+		tt := &networkTestable{}
+		defer func() {
+			require.NoError(t, tt.wipe())
+		}()
 
-	tests := []findTest[*metal.Network, *NetworkSearchQuery]{
-		{
-			name: "find",
-			id:   "2",
+		tests := []findTest[*metal.Network, *NetworkSearchQuery]{
+			{
+				name: "find",
+				id:   "2",
 
-			mock: []*metal.Network{
-				{Base: metal.Base{ID: "1"}},
-				{Base: metal.Base{ID: "2"}},
-				{Base: metal.Base{ID: "3"}},
-			},
-			want:    tt.defaultBody(&metal.Network{Base: metal.Base{ID: "2"}}),
-			wantErr: nil,
-		},
-		{
-			name:    "not found",
-			id:      "4",
-			want:    nil,
-			wantErr: metal.NotFound(`no network with id "4" found`),
-		},
-	}
-	for i := range tests {
-		tests[i].run(t, tt)
-	}
+				mock: []*metal.Network{
+					{Base: metal.Base{ID: "1"}},
+					{Base: metal.Base{ID: "2"}},
+					{Base: metal.Base{ID: "3"}},
+				},
+				want:    tt.defaultBody(&metal.Network{Base: metal.Base{ID: "2"}}),
+				wantErr: nil,
+			},
+			{
+				name:    "not found",
+				id:      "4",
+				want:    nil,
+				wantErr: metal.NotFound(`no network with id "4" found`),
+			},
+		}
+		for i := range tests {
+			tests[i].run(t, tt)
+		}
+	})
 }
 
-func TestRethinkStore_SearchNetworks(t *testing.T) {
-	tt := &networkTestable{}
-	defer func() {
-		require.NoError(t, tt.wipe())
-	}()
+func TestStore_SearchNetworks(t *testing.T) {
+	forEachStore(t, func(t *testing.T) {
+		tt := &networkTestable{}
+		defer func() {
+			require.NoError(t, tt.wipe())
+		}()
 
-	tests := []searchTest[*metal.Network, *NetworkSearchQuery]{
-		{
-			name: "empty result",
-			q: &NetworkSearchQuery{
-				ID: new("2"),
-			},
-			mock: []*metal.Network{
-				{Base: metal.Base{ID: "1"}},
-			},
-			want:    nil,
-			wantErr: nil,
-		},
-		{
-			name: "search by id",
-			q: &NetworkSearchQuery{
-				ID: new("2"),
-			},
-			mock: []*metal.Network{
-				{Base: metal.Base{ID: "1"}},
-				{Base: metal.Base{ID: "2"}},
-				{Base: metal.Base{ID: "3"}},
-			},
-			want: []*metal.Network{
-				tt.defaultBody(&metal.Network{Base: metal.Base{ID: "2"}}),
-			},
-			wantErr: nil,
-		},
-		{
-			name: "search by name",
-			q: &NetworkSearchQuery{
-				Name: new("b"),
-			},
-			mock: []*metal.Network{
-				{Base: metal.Base{ID: "1", Name: "a"}},
-				{Base: metal.Base{ID: "2", Name: "b"}},
-				{Base: metal.Base{ID: "3", Name: "c"}},
-			},
-			want: []*metal.Network{
-				tt.defaultBody(&metal.Network{Base: metal.Base{ID: "2", Name: "b"}}),
-			},
-			wantErr: nil,
-		},
-		{
-			name: "search by partition",
-			q: &NetworkSearchQuery{
-				PartitionID: new("b"),
-			},
-			mock: []*metal.Network{
-				{Base: metal.Base{ID: "1"}, PartitionID: "a"},
-				{Base: metal.Base{ID: "2"}, PartitionID: "b"},
-				{Base: metal.Base{ID: "3"}, PartitionID: "c"},
-			},
-			want: []*metal.Network{
-				tt.defaultBody(&metal.Network{Base: metal.Base{ID: "2"}, PartitionID: "b"}),
-			},
-			wantErr: nil,
-		},
-		{
-			name: "search by project",
-			q: &NetworkSearchQuery{
-				ProjectID: new("b"),
-			},
-			mock: []*metal.Network{
-				{Base: metal.Base{ID: "1"}, ProjectID: "a"},
-				{Base: metal.Base{ID: "2"}, ProjectID: "b"},
-				{Base: metal.Base{ID: "3"}, ProjectID: "c"},
-			},
-			want: []*metal.Network{
-				tt.defaultBody(&metal.Network{Base: metal.Base{ID: "2"}, ProjectID: "b"}),
-			},
-			wantErr: nil,
-		},
-		{
-			name: "search by prefix",
-			q: &NetworkSearchQuery{
-				Prefixes: []string{"1.2.3.4/32", "3.4.5.6/32"},
-			},
-			mock: []*metal.Network{
-				{Base: metal.Base{ID: "1"}, Prefixes: metal.Prefixes{{IP: "1.2.3.4", Length: "32"}}},
-				{Base: metal.Base{ID: "2"}, Prefixes: metal.Prefixes{{IP: "1.2.3.4", Length: "32"}, {IP: "3.4.5.6", Length: "32"}}},
-				{Base: metal.Base{ID: "3"}, Prefixes: metal.Prefixes{{IP: "255.255.255.0", Length: "24"}}},
-			},
-			want: []*metal.Network{
-				tt.defaultBody(&metal.Network{Base: metal.Base{ID: "2"}, Prefixes: metal.Prefixes{{IP: "1.2.3.4", Length: "32"}, {IP: "3.4.5.6", Length: "32"}}}),
-			},
-			wantErr: nil,
-		},
-		{
-			name: "search by destination prefix",
-			q: &NetworkSearchQuery{
-				DestinationPrefixes: []string{"1.2.3.4/32", "3.4.5.6/32"},
-			},
-			mock: []*metal.Network{
-				{Base: metal.Base{ID: "1"}, DestinationPrefixes: metal.Prefixes{{IP: "1.2.3.4", Length: "32"}}},
-				{Base: metal.Base{ID: "2"}, DestinationPrefixes: metal.Prefixes{{IP: "1.2.3.4", Length: "32"}, {IP: "3.4.5.6", Length: "32"}}},
-				{Base: metal.Base{ID: "3"}, DestinationPrefixes: metal.Prefixes{{IP: "255.255.255.0", Length: "24"}}},
-			},
-			want: []*metal.Network{
-				tt.defaultBody(&metal.Network{Base: metal.Base{ID: "2"}, DestinationPrefixes: metal.Prefixes{{IP: "1.2.3.4", Length: "32"}, {IP: "3.4.5.6", Length: "32"}}}),
-			},
-			wantErr: nil,
-		},
-		{
-			name: "search by nat",
-			q: &NetworkSearchQuery{
-				Nat: new(true),
-			},
-			mock: []*metal.Network{
-				{Base: metal.Base{ID: "1"}, Nat: false},
-				{Base: metal.Base{ID: "2"}, Nat: true},
-				{Base: metal.Base{ID: "3"}, Nat: false},
-			},
-			want: []*metal.Network{
-				tt.defaultBody(&metal.Network{Base: metal.Base{ID: "2"}, Nat: true}),
-			},
-			wantErr: nil,
-		},
-		{
-			name: "search by private super",
-			q: &NetworkSearchQuery{
-				PrivateSuper: new(true),
-			},
-			mock: []*metal.Network{
-				{Base: metal.Base{ID: "1"}, PrivateSuper: false},
-				{Base: metal.Base{ID: "2"}, PrivateSuper: true},
-				{Base: metal.Base{ID: "3"}, PrivateSuper: false},
-			},
-			want: []*metal.Network{
-				tt.defaultBody(&metal.Network{Base: metal.Base{ID: "2"}, PrivateSuper: true}),
-			},
-			wantErr: nil,
-		},
-		{
-			name: "search by underlay",
-			q: &NetworkSearchQuery{
-				Underlay: new(false),
-			},
-			mock: []*metal.Network{
-				{Base: metal.Base{ID: "1"}, Underlay: false},
-				{Base: metal.Base{ID: "2"}, Underlay: true},
-				{Base: metal.Base{ID: "3"}, Underlay: false},
-			},
-			want: []*metal.Network{
-				tt.defaultBody(&metal.Network{Base: metal.Base{ID: "1"}, Underlay: false}),
-				tt.defaultBody(&metal.Network{Base: metal.Base{ID: "3"}, Underlay: false}),
-			},
-			wantErr: nil,
-		},
-		{
-			name: "search by vrf",
-			q: &NetworkSearchQuery{
-				Vrf: new(int64(1)),
-			},
-			mock: []*metal.Network{
-				{Base: metal.Base{ID: "1"}, Vrf: 0},
-				{Base: metal.Base{ID: "2"}, Vrf: 1},
-				{Base: metal.Base{ID: "3"}, Vrf: 2},
-			},
-			want: []*metal.Network{
-				tt.defaultBody(&metal.Network{Base: metal.Base{ID: "2"}, Vrf: 1}),
-			},
-			wantErr: nil,
-		},
-		{
-			name: "search by parent network id",
-			q: &NetworkSearchQuery{
-				ParentNetworkID: new("parent"),
-			},
-			mock: []*metal.Network{
-				{Base: metal.Base{ID: "1"}, ParentNetworkID: "0"},
-				{Base: metal.Base{ID: "2"}, ParentNetworkID: "parent"},
-				{Base: metal.Base{ID: "3"}, ParentNetworkID: "1"},
-			},
-			want: []*metal.Network{
-				tt.defaultBody(&metal.Network{Base: metal.Base{ID: "2"}, ParentNetworkID: "parent"}),
-			},
-			wantErr: nil,
-		},
-		{
-			name: "search by labels",
-			q: &NetworkSearchQuery{
-				Labels: map[string]string{"a": "b"},
-			},
-			mock: []*metal.Network{
-				{Base: metal.Base{ID: "1"}, Labels: nil},
-				{Base: metal.Base{ID: "2"}, Labels: map[string]string{"a": "b", "c": "d"}},
-				{Base: metal.Base{ID: "3"}, Labels: map[string]string{"c": "d"}},
-			},
-			want: []*metal.Network{
-				tt.defaultBody(&metal.Network{Base: metal.Base{ID: "2"}, Labels: map[string]string{"a": "b", "c": "d"}}),
-			},
-			wantErr: nil,
-		},
-		{
-			name: "search by ipv4 addressfamily",
-			q: &NetworkSearchQuery{
-				AddressFamily: new(string(metal.IPv4AddressFamily)),
-			},
-			mock: []*metal.Network{
-				{Base: metal.Base{ID: "1"}, Prefixes: metal.Prefixes{{IP: "1.2.3.4", Length: "32"}}},
-				{Base: metal.Base{ID: "2"}, Prefixes: metal.Prefixes{{IP: "fe80::", Length: "64"}}},
-			},
-			want: []*metal.Network{
-				tt.defaultBody(&metal.Network{Base: metal.Base{ID: "1"}, Prefixes: metal.Prefixes{{IP: "1.2.3.4", Length: "32"}}}),
-			},
-			wantErr: nil,
-		},
-		{
-			name: "search by ipv6 addressfamily",
-			q: &NetworkSearchQuery{
-				AddressFamily: new(string(metal.IPv6AddressFamily)),
-			},
-			mock: []*metal.Network{
-				{Base: metal.Base{ID: "1"}, Prefixes: metal.Prefixes{{IP: "1.2.3.4", Length: "32"}}},
-				{Base: metal.Base{ID: "2"}, Prefixes: metal.Prefixes{{IP: "fe80::", Length: "64"}}},
-			},
-			want: []*metal.Network{
-				tt.defaultBody(&metal.Network{Base: metal.Base{ID: "2"}, Prefixes: metal.Prefixes{{IP: "fe80::", Length: "64"}}}),
-			},
-			wantErr: nil,
-		},
-	}
+		tests := []searchTest[*metal.Network, *NetworkSearchQuery]{
+			{
+				name: "empty result",
+				q: &NetworkSearchQuery{
+					ID: new("2"),
+				},
+				mock: []*metal.Network{
+					{Base: metal.Base{ID: "1"}},
+				},
+				want:    nil,
+				wantErr: nil,
+			},
+			{
+				name: "search by id",
+				q: &NetworkSearchQuery{
+					ID: new("2"),
+				},
+				mock: []*metal.Network{
+					{Base: metal.Base{ID: "1"}},
+					{Base: metal.Base{ID: "2"}},
+					{Base: metal.Base{ID: "3"}},
+				},
+				want: []*metal.Network{
+					tt.defaultBody(&metal.Network{Base: metal.Base{ID: "2"}}),
+				},
+				wantErr: nil,
+			},
+			{
+				name: "search by name",
+				q: &NetworkSearchQuery{
+					Name: new("b"),
+				},
+				mock: []*metal.Network{
+					{Base: metal.Base{ID: "1", Name: "a"}},
+					{Base: metal.Base{ID: "2", Name: "b"}},
+					{Base: metal.Base{ID: "3", Name: "c"}},
+				},
+				want: []*metal.Network{
+					tt.defaultBody(&metal.Network{Base: metal.Base{ID: "2", Name: "b"}}),
+				},
+				wantErr: nil,
+			},
+			{
+				name: "search by partition",
+				q: &NetworkSearchQuery{
+					PartitionID: new("b"),
+				},
+				mock: []*metal.Network{
+					{Base: metal.Base{ID: "1"}, PartitionID: "a"},
+					{Base: metal.Base{ID: "2"}, PartitionID: "b"},
+					{Base: metal.Base{ID: "3"}, PartitionID: "c"},
+				},
+				want: []*metal.Network{
+					tt.defaultBody(&metal.Network{Base: metal.Base{ID: "2"}, PartitionID: "b"}),
+				},
+				wantErr: nil,
+			},
+			{
+				name: "search by project",
+				q: &NetworkSearchQuery{
+					ProjectID: new("b"),
+				},
+				mock: []*metal.Network{
+					{Base: metal.Base{ID: "1"}, ProjectID: "a"},
+					{Base: metal.Base{ID: "2"}, ProjectID: "b"},
+					{Base: metal.Base{ID: "3"}, ProjectID: "c"},
+				},
+				want: []*metal.Network{
+					tt.defaultBody(&metal.Network{Base: metal.Base{ID: "2"}, ProjectID: "b"}),
+				},
+				wantErr: nil,
+			},
+			{
+				name: "search by prefix",
+				q: &NetworkSearchQuery{
+					Prefixes: []string{"1.2.3.4/32", "3.4.5.6/32"},
+				},
+				mock: []*metal.Network{
+					{Base: metal.Base{ID: "1"}, Prefixes: metal.Prefixes{{IP: "1.2.3.4", Length: "32"}}},
+					{Base: metal.Base{ID: "2"}, Prefixes: metal.Prefixes{{IP: "1.2.3.4", Length: "32"}, {IP: "3.4.5.6", Length: "32"}}},
+					{Base: metal.Base{ID: "3"}, Prefixes: metal.Prefixes{{IP: "255.255.255.0", Length: "24"}}},
+				},
+				want: []*metal.Network{
+					tt.defaultBody(&metal.Network{Base: metal.Base{ID: "2"}, Prefixes: metal.Prefixes{{IP: "1.2.3.4", Length: "32"}, {IP: "3.4.5.6", Length: "32"}}}),
+				},
+				wantErr: nil,
+			},
+			{
+				name: "search by destination prefix",
+				q: &NetworkSearchQuery{
+					DestinationPrefixes: []string{"1.2.3.4/32", "3.4.5.6/32"},
+				},
+				mock: []*metal.Network{
+					{Base: metal.Base{ID: "1"}, DestinationPrefixes: metal.Prefixes{{IP: "1.2.3.4", Length: "32"}}},
+					{Base: metal.Base{ID: "2"}, DestinationPrefixes: metal.Prefixes{{IP: "1.2.3.4", Length: "32"}, {IP: "3.4.5.6", Length: "32"}}},
+					{Base: metal.Base{ID: "3"}, DestinationPrefixes: metal.Prefixes{{IP: "255.255.255.0", Length: "24"}}},
+				},
+				want: []*metal.Network{
+					tt.defaultBody(&metal.Network{Base: metal.Base{ID: "2"}, DestinationPrefixes: metal.Prefixes{{IP: "1.2.3.4", Length: "32"}, {IP: "3.4.5.6", Length: "32"}}}),
+				},
+				wantErr: nil,
+			},
+			{
+				name: "search by nat",
+				q: &NetworkSearchQuery{
+					Nat: new(true),
+				},
+				mock: []*metal.Network{
+					{Base: metal.Base{ID: "1"}, Nat: false},
+					{Base: metal.Base{ID: "2"}, Nat: true},
+					{Base: metal.Base{ID: "3"}, Nat: false},
+				},
+				want: []*metal.Network{
+					tt.defaultBody(&metal.Network{Base: metal.Base{ID: "2"}, Nat: true}),
+				},
+				wantErr: nil,
+			},
+			{
+				name: "search by private super",
+				q: &NetworkSearchQuery{
+					PrivateSuper: new(true),
+				},
+				mock: []*metal.Network{
+					{Base: metal.Base{ID: "1"}, PrivateSuper: false},
+					{Base: metal.Base{ID: "2"}, PrivateSuper: true},
+					{Base: metal.Base{ID: "3"}, PrivateSuper: false},
+				},
+				want: []*metal.Network{
+					tt.defaultBody(&metal.Network{Base: metal.Base{ID: "2"}, PrivateSuper: true}),
+				},
+				wantErr: nil,
+			},
+			{
+				name: "search by underlay",
+				q: &NetworkSearchQuery{
+					Underlay: new(false),
+				},
+				mock: []*metal.Network{
+					{Base: metal.Base{ID: "1"}, Underlay: false},
+					{Base: metal.Base{ID: "2"}, Underlay: true},
+					{Base: metal.Base{ID: "3"}, Underlay: false},
+				},
+				want: []*metal.Network{
+					tt.defaultBody(&metal.Network{Base: metal.Base{ID: "1"}, Underlay: false}),
+					tt.defaultBody(&metal.Network{Base: metal.Base{ID: "3"}, Underlay: false}),
+				},
+				wantErr: nil,
+			},
+			{
+				name: "search by vrf",
+				q: &NetworkSearchQuery{
+					Vrf: new(int64(1)),
+				},
+				mock: []*metal.Network{
+					{Base: metal.Base{ID: "1"}, Vrf: 0},
+					{Base: metal.Base{ID: "2"}, Vrf: 1},
+					{Base: metal.Base{ID: "3"}, Vrf: 2},
+				},
+				want: []*metal.Network{
+					tt.defaultBody(&metal.Network{Base: metal.Base{ID: "2"}, Vrf: 1}),
+				},
+				wantErr: nil,
+			},
+			{
+				name: "search by parent network id",
+				q: &NetworkSearchQuery{
+					ParentNetworkID: new("parent"),
+				},
+				mock: []*metal.Network{
+					{Base: metal.Base{ID: "1"}, ParentNetworkID: "0"},
+					{Base: metal.Base{ID: "2"}, ParentNetworkID: "parent"},
+					{Base: metal.Base{ID: "3"}, ParentNetworkID: "1"},
+				},
+				want: []*metal.Network{
+					tt.defaultBody(&metal.Network{Base: metal.Base{ID: "2"}, ParentNetworkID: "parent"}),
+				},
+				wantErr: nil,
+			},
+			{
+				name: "search by labels",
+				q: &NetworkSearchQuery{
+					Labels: map[string]string{"a": "b"},
+				},
+				mock: []*metal.Network{
+					{Base: metal.Base{ID: "1"}, Labels: nil},
+					{Base: metal.Base{ID: "2"}, Labels: map[string]string{"a": "b", "c": "d"}},
+					{Base: metal.Base{ID: "3"}, Labels: map[string]string{"c": "d"}},
+				},
+				want: []*metal.Network{
+					tt.defaultBody(&metal.Network{Base: metal.Base{ID: "2"}, Labels: map[string]string{"a": "b", "c": "d"}}),
+				},
+				wantErr: nil,
+			},
+			{
+				name: "search by ipv4 addressfamily",
+				q: &NetworkSearchQuery{
+					AddressFamily: new(string(metal.IPv4AddressFamily)),
+				},
+				mock: []*metal.Network{
+					{Base: metal.Base{ID: "1"}, Prefixes: metal.Prefixes{{IP: "1.2.3.4", Length: "32"}}},
+					{Base: metal.Base{ID: "2"}, Prefixes: metal.Prefixes{{IP: "fe80::", Length: "64"}}},
+				},
+				want: []*metal.Network{
+					tt.defaultBody(&metal.Network{Base: metal.Base{ID: "1"}, Prefixes: metal.Prefixes{{IP: "1.2.3.4", Length: "32"}}}),
+				},
+				wantErr: nil,
+			},
+			{
+				name: "search by ipv6 addressfamily",
+				q: &NetworkSearchQuery{
+					AddressFamily: new(string(metal.IPv6AddressFamily)),
+				},
+				mock: []*metal.Network{
+					{Base: metal.Base{ID: "1"}, Prefixes: metal.Prefixes{{IP: "1.2.3.4", Length: "32"}}},
+					{Base: metal.Base{ID: "2"}, Prefixes: metal.Prefixes{{IP: "fe80::", Length: "64"}}},
+				},
+				want: []*metal.Network{
+					tt.defaultBody(&metal.Network{Base: metal.Base{ID: "2"}, Prefixes: metal.Prefixes{{IP: "fe80::", Length: "64"}}}),
+				},
+				wantErr: nil,
+			},
+		}
 
-	for i := range tests {
-		tests[i].run(t, tt)
-	}
+		for i := range tests {
+			tests[i].run(t, tt)
+		}
+	})
 }
 
-func TestRethinkStore_ListNetworks(t *testing.T) {
-	tt := &networkTestable{}
-	defer func() {
-		require.NoError(t, tt.wipe())
-	}()
+func TestStore_ListNetworks(t *testing.T) {
+	forEachStore(t, func(t *testing.T) {
+		tt := &networkTestable{}
+		defer func() {
+			require.NoError(t, tt.wipe())
+		}()
 
-	tests := []listTest[*metal.Network, *NetworkSearchQuery]{
-		{
-			name: "list",
-			mock: []*metal.Network{
-				{Base: metal.Base{ID: "1"}},
-				{Base: metal.Base{ID: "2"}},
-				{Base: metal.Base{ID: "3"}},
-			},
-			want: []*metal.Network{
-				tt.defaultBody(&metal.Network{Base: metal.Base{ID: "1"}}),
-				tt.defaultBody(&metal.Network{Base: metal.Base{ID: "2"}}),
-				tt.defaultBody(&metal.Network{Base: metal.Base{ID: "3"}}),
-			},
-		},
-	}
-	for i := range tests {
-		tests[i].run(t, tt)
-	}
+		tests := []listTest[*metal.Network, *NetworkSearchQuery]{
+			{
+				name: "list",
+				mock: []*metal.Network{
+					{Base: metal.Base{ID: "1"}},
+					{Base: metal.Base{ID: "2"}},
+					{Base: metal.Base{ID: "3"}},
+				},
+				want: []*metal.Network{
+					tt.defaultBody(&metal.Network{Base: metal.Base{ID: "1"}}),
+					tt.defaultBody(&metal.Network{Base: metal.Base{ID: "2"}}),
+					tt.defaultBody(&metal.Network{Base: metal.Base{ID: "3"}}),
+				},
+			},
+		}
+		for i := range tests {
+			tests[i].run(t, tt)
+		}
+	})
 }
 
-func TestRethinkStore_CreateNetwork(t *testing.T) {
-	tt := &networkTestable{}
-	defer func() {
-		require.NoError(t, tt.wipe())
-	}()
+func TestStore_CreateNetwork(t *testing.T) {
+	forEachStore(t, func(t *testing.T) {
+		tt := &networkTestable{}
+		defer func() {
+			require.NoError(t, tt.wipe())
+		}()
 
-	tests := []createTest[*metal.Network, *NetworkSearchQuery]{
-		{
-			name:    "create",
-			want:    tt.defaultBody(&metal.Network{Base: metal.Base{ID: "1"}}),
-			wantErr: nil,
-		},
-		{
-			name: "already exists",
-			mock: []*metal.Network{
-				{Base: metal.Base{ID: "1"}},
-			},
-			want:    tt.defaultBody(&metal.Network{Base: metal.Base{ID: "1"}}),
-			wantErr: metal.Conflict(`cannot create network in database, entity already exists: 1`),
-		},
-	}
-	for i := range tests {
-		tests[i].run(t, tt)
-	}
+		tests := []createTest[*metal.Network, *NetworkSearchQuery]{
+			{
+				name:    "create",
+				want:    tt.defaultBody(&metal.Network{Base: metal.Base{ID: "1"}}),
+				wantErr: nil,
+			},
+			{
+				name: "already exists",
+				mock: []*metal.Network{
+					{Base: metal.Base{ID: "1"}},
+				},
+				want:    tt.defaultBody(&metal.Network{Base: metal.Base{ID: "1"}}),
+				wantErr: metal.Conflict(`cannot create network in database, entity already exists: 1`),
+			},
+		}
+		for i := range tests {
+			tests[i].run(t, tt)
+		}
+	})
 }
 
-func TestRethinkStore_DeleteNetwork(t *testing.T) {
-	tt := &networkTestable{}
-	defer func() {
-		require.NoError(t, tt.wipe())
-	}()
+func TestStore_DeleteNetwork(t *testing.T) {
+	forEachStore(t, func(t *testing.T) {
+		tt := &networkTestable{}
+		defer func() {
+			require.NoError(t, tt.wipe())
+		}()
 
-	tests := []deleteTest[*metal.Network, *NetworkSearchQuery]{
-		{
-			name: "delete",
-			id:   "2",
-			mock: []*metal.Network{
-				{Base: metal.Base{ID: "1"}},
-				{Base: metal.Base{ID: "2"}},
-				{Base: metal.Base{ID: "3"}},
-			},
-			want: []*metal.Network{
-				tt.defaultBody(&metal.Network{Base: metal.Base{ID: "1"}}),
-				tt.defaultBody(&metal.Network{Base: metal.Base{ID: "3"}}),
-			},
-		},
-		{
-			name: "not exists results in noop",
-			id:   "abc",
-			mock: []*metal.Network{
-				{Base: metal.Base{ID: "1"}},
-				{Base: metal.Base{ID: "2"}},
-				{Base: metal.Base{ID: "3"}},
-			},
-			want: []*metal.Network{
-				tt.defaultBody(&metal.Network{Base: metal.Base{ID: "1"}}),
-				tt.defaultBody(&metal.Network{Base: metal.Base{ID: "2"}}),
-				tt.defaultBody(&metal.Network{Base: metal.Base{ID: "3"}}),
-			},
-		},
-	}
-	for i := range tests {
-		tests[i].run(t, tt)
-	}
+		tests := []deleteTest[*metal.Network, *NetworkSearchQuery]{
+			{
+				name: "delete",
+				id:   "2",
+				mock: []*metal.Network{
+					{Base: metal.Base{ID: "1"}},
+					{Base: metal.Base{ID: "2"}},
+					{Base: metal.Base{ID: "3"}},
+				},
+				want: []*metal.Network{
+					tt.defaultBody(&metal.Network{Base: metal.Base{ID: "1"}}),
+					tt.defaultBody(&metal.Network{Base: metal.Base{ID: "3"}}),
+				},
+			},
+			{
+				name: "not exists results in noop",
+				id:   "abc",
+				mock: []*metal.Network{
+					{Base: metal.Base{ID: "1"}},
+					{Base: metal.Base{ID: "2"}},
+					{Base: metal.Base{ID: "3"}},
+				},
+				want: []*metal.Network{
+					tt.defaultBody(&metal.Network{Base: metal.Base{ID: "1"}}),
+					tt.defaultBody(&metal.Network{Base: metal.Base{ID: "2"}}),
+					tt.defaultBody(&metal.Network{Base: metal.Base{ID: "3"}}),
+				},
+			},
+		}
+		for i := range tests {
+			tests[i].run(t, tt)
+		}
+	})
 }
 
-func TestRethinkStore_UpdateNetwork(t *testing.T) {
-	tt := &networkTestable{}
-	defer func() {
-		require.NoError(t, tt.wipe())
-	}()
+func TestStore_UpdateNetwork(t *testing.T) {
+	forEachStore(t, func(t *testing.T) {
+		tt := &networkTestable{}
+		defer func() {
+			require.NoError(t, tt.wipe())
+		}()
 
-	tests := []updateTest[*metal.Network, *NetworkSearchQuery]{
-		{
-			name: "update",
-			mock: []*metal.Network{
-				{Base: metal.Base{ID: "1"}},
-				{Base: metal.Base{ID: "2"}},
-				{Base: metal.Base{ID: "3"}},
-			},
-			mutateFn: func(s *metal.Network) {
-				s.Labels = map[string]string{"a": "b"}
-			},
-			want: tt.defaultBody(&metal.Network{
-				Base:   metal.Base{ID: "1"},
-				Labels: map[string]string{"a": "b"},
-			}),
-		},
-	}
-	for i := range tests {
-		tests[i].run(t, tt)
-	}
+		tests := []updateTest[*metal.Network, *NetworkSearchQuery]{
+			{
+				name: "update",
+				mock: []*metal.Network{
+					{Base: metal.Base{ID: "1"}},
+					{Base: metal.Base{ID: "2"}},
+					{Base: metal.Base{ID: "3"}},
+				},
+				mutateFn: func(s *metal.Network) {
+					s.Labels = map[string]string{"a": "b"}
+				},
+				want: tt.defaultBody(&metal.Network{
+					Base:   metal.Base{ID: "1"},
+					Labels: map[string]string{"a": "b"},
+				}),
+			},
+		}
+		for i := range tests {
+			tests[i].run(t, tt)
+		}
+	})
 }
@@ -0,0 +1,67 @@
+package datastore
+
+import (
+	"github.com/metal-stack/metal-api/cmd/metal-api/internal/metal"
+	r "gopkg.in/rethinkdb/rethinkdb-go.v6"
+)
+
+// OutboxEventSearchQuery can be used to search outbox events.
+type OutboxEventSearchQuery struct {
+	ID    *string `json:"id" optional:"true"`
+	Topic *string `json:"topic" optional:"true"`
+	State *string `json:"state" optional:"true"`
+}
+
+func (p *OutboxEventSearchQuery) generateTerm(rs *RethinkStore) *r.Term {
+	q := *rs.outboxTable()
+
+	if p.ID != nil {
+		q = q.Filter(func(row r.Term) r.Term {
+			return row.Field("id").Eq(*p.ID)
+		})
+	}
+
+	if p.Topic != nil {
+		q = q.Filter(func(row r.Term) r.Term {
+			return row.Field("topic").Eq(*p.Topic)
+		})
+	}
+
+	if p.State != nil {
+		q = q.Filter(func(row r.Term) r.Term {
+			return row.Field("state").Eq(*p.State)
+		})
+	}
+
+	return &q
+}
+
+// FindOutboxEvent returns the outbox event for the given id.
+func (rs *RethinkStore) FindOutboxEvent(id string) (*metal.OutboxEvent, error) {
+	var e metal.OutboxEvent
+	err := rs.findEntityByID(rs.outboxTable(), &e, id)
+	if err != nil {
+		return nil, err
+	}
+	return &e, nil
+}
+
+// SearchOutboxEvents returns the result of the outbox events search request query.
+func (rs *RethinkStore) SearchOutboxEvents(q *OutboxEventSearchQuery, es *metal.OutboxEvents) error {
+	return rs.searchEntities(q.generateTerm(rs), es)
+}
+
+// CreateOutboxEvent creates a new outbox event.
+func (rs *RethinkStore) CreateOutboxEvent(e *metal.OutboxEvent) error {
+	return rs.createEntity(rs.outboxTable(), e)
+}
+
+// DeleteOutboxEvent deletes an outbox event.
+func (rs *RethinkStore) DeleteOutboxEvent(e *metal.OutboxEvent) error {
+	return rs.deleteEntity(rs.outboxTable(), e)
+}
+
+// UpdateOutboxEvent updates an outbox event, it fails with a conflict if it was changed in the meantime.
+func (rs *RethinkStore) UpdateOutboxEvent(oldEvent *metal.OutboxEvent, newEvent *metal.OutboxEvent) error {
+	return rs.updateEntity(rs.outboxTable(), newEvent, oldEvent)
+}
@@ -0,0 +1,246 @@
+package datastore
+
+import (
+	"cmp"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"sort"
+
+	"github.com/metal-stack/metal-api/cmd/metal-api/internal/metal"
+	r "gopkg.in/rethinkdb/rethinkdb-go.v6"
+)
+
+// Paging restricts the result of a search query to a single page.
+//
+// Results are ordered by the sort key and the id as tie breaker, so the order is stable. A page can be
+// continued with the cursor of its last entity, which in contrast to an offset is not affected by entities
+// which are created or deleted in the meantime.
+type Paging struct {
+	Limit          *uint64 `json:"limit" description:"the maximum number of entities to return, the result is not limited if not set" optional:"true"`
+	Offset         *uint64 `json:"offset" description:"the number of entities to skip, prefer the cursor to iterate over pages" optional:"true"`
+	SortBy         *string `json:"sort_by" description:"the key to sort the result by, the id is used if not set" optional:"true"`
+	SortDescending *bool   `json:"sort_descending" description:"sort the result in descending order" optional:"true"`
+	Cursor         *string `json:"cursor" description:"continue the search after the entity this cursor points to, the cursor of the next page is returned in the X-Next-Cursor response header" optional:"true"`
+}
+
+// cursor points to the position of an entity in the sort order of a search.
+type cursor struct {
+	Value string `json:"v"`
+	ID    string `json:"id"`
+}
+
+// sortKey is a key a search result can be sorted by, it is defined for the rethinkdb and the memory store.
+type sortKey[E any] struct {
+	term  func(row r.Term) r.Term
+	value func(e *E) string
+}
+
+type sortKeys[E any] map[string]sortKey[E]
+
+func field(path ...string) func(row r.Term) r.Term {
+	return func(row r.Term) r.Term {
+		for _, f := range path {
+			row = row.Field(f)
+		}
+		return row
+	}
+}
+
+var machineSortKeys = sortKeys[metal.Machine]{
+	"id":        {term: field("id"), value: func(m *metal.Machine) string { return m.ID }},
+	"name":      {term: field("name"), value: func(m *metal.Machine) string { return m.Name }},
+	"partition": {term: field("partitionid"), value: func(m *metal.Machine) string { return m.PartitionID }},
+	"size":      {term: field("sizeid"), value: func(m *metal.Machine) string { return m.SizeID }},
+	"rack":      {term: field("rackid"), value: func(m *metal.Machine) string { return m.RackID }},
+	"state":     {term: field("state", "value"), value: func(m *metal.Machine) string { return string(m.State.Value) }},
+	"project": {term: field("allocation", "project"), value: func(m *metal.Machine) string {
+		if m.Allocation == nil {
+			return ""
+		}
+		return m.Allocation.Project
+	}},
+	"hostname": {term: field("allocation", "hostname"), value: func(m *metal.Machine) string {
+		if m.Allocation == nil {
+			return ""
+		}
+		return m.Allocation.Hostname
+	}},
+}
+
+var ipSortKeys = sortKeys[metal.IP]{
+	"id":        {term: field("id"), value: func(ip *metal.IP) string { return ip.IPAddress }},
+	"ipaddress": {term: field("id"), value: func(ip *metal.IP) string { return ip.IPAddress }},
+	"name":      {term: field("name"), value: func(ip *metal.IP) string { return ip.Name }},
+	"project":   {term: field("projectid"), value: func(ip *metal.IP) string { return ip.ProjectID }},
+	"network":   {term: field("networkid"), value: func(ip *metal.IP) string { return ip.NetworkID }},
+	"type":      {term: field("type"), value: func(ip *metal.IP) string { return string(ip.Type) }},
+}
+
+var networkSortKeys = sortKeys[metal.Network]{
+	"id":            {term: field("id"), value: func(n *metal.Network) string { return n.ID }},
+	"name":          {term: field("name"), value: func(n *metal.Network) string { return n.Name }},
+	"partition":     {term: field("partitionid"), value: func(n *metal.Network) string { return n.PartitionID }},
+	"project":       {term: field("projectid"), value: func(n *metal.Network) string { return n.ProjectID }},
+	"parentnetwork": {term: field("parentnetworkid"), value: func(n *metal.Network) string { return n.ParentNetworkID }},
+}
+
+func (p *Paging) descending() bool {
+	return p.SortDescending != nil && *p.SortDescending
+}
+
+func (p *Paging) cursor() (*cursor, error) {
+	if p.Cursor == nil {
+		return nil, nil
+	}
+	raw, err := base64.RawURLEncoding.DecodeString(*p.Cursor)
+	if err != nil {
+		return nil, fmt.Errorf("invalid cursor: %w", err)
+	}
+	var c cursor
+	err = json.Unmarshal(raw, &c)
+	if err != nil {
+		return nil, fmt.Errorf("invalid cursor: %w", err)
+	}
+	return &c, nil
+}
+
+func (c *cursor) String() string {
+	raw, _ := json.Marshal(c) //nolint:errchkjson
+	return base64.RawURLEncoding.EncodeToString(raw)
+}
+
+func sortKeyOf[E any](p *Paging, keys sortKeys[E]) (sortKey[E], error) {
+	name := "id"
+	if p.SortBy != nil {
+		name = *p.SortBy
+	}
+	key, ok := keys[name]
+	if !ok {
+		names := make([]string, 0, len(keys))
+		for k := range keys {
+			names = append(names, k)
+		}
+		sort.Strings(names)
+		return sortKey[E]{}, fmt.Errorf("unknown sort key %q, must be one of %v", name, names)
+	}
+	return key, nil
+}
+
+// validatePaging checks the sort key and the cursor of the paging.
+func validatePaging[E any](p *Paging, keys sortKeys[E]) error {
+	_, err := sortKeyOf(p, keys)
+	if err != nil {
+		return err
+	}
+	_, err = p.cursor()
+	return err
+}
+
+// pageTerm restricts the given query to the requested page.
+func pageTerm[E any](q r.Term, p *Paging, keys sortKeys[E]) (r.Term, error) {
+	if *p == (Paging{}) {
+		return q, nil
+	}
+
+	key, err := sortKeyOf(p, keys)
+	if err != nil {
+		return q, err
+	}
+	c, err := p.cursor()
+	if err != nil {
+		return q, err
+	}
+
+	// missing fields, e.g. of machines without allocation, are sorted like empty values as in the memory store
+	value := func(row r.Term) r.Term {
+		return key.term(row).Default("")
+	}
+
+	if c != nil {
+		q = q.Filter(func(row r.Term) r.Term {
+			v := value(row)
+			if p.descending() {
+				return v.Lt(c.Value).Or(v.Eq(c.Value).And(row.Field("id").Lt(c.ID)))
+			}
+			return v.Gt(c.Value).Or(v.Eq(c.Value).And(row.Field("id").Gt(c.ID)))
+		})
+	}
+
+	if p.descending() {
+		q = q.OrderBy(r.Desc(value), r.Desc("id"))
+	} else {
+		q = q.OrderBy(r.Asc(value), r.Asc("id"))
+	}
+
+	if p.Offset != nil {
+		q = q.Skip(*p.Offset)
+	}
+	if p.Limit != nil {
+		q = q.Limit(*p.Limit)
+	}
+
+	return q, nil
+}
+
+// page is the in-memory counterpart of pageTerm.
+func page[E any](entities []E, p *Paging, keys sortKeys[E]) ([]E, error) {
+	if *p == (Paging{}) {
+		return entities, nil
+	}
+
+	key, err := sortKeyOf(p, keys)
+	if err != nil {
+		return nil, err
+	}
+	c, err := p.cursor()
+	if err != nil {
+		return nil, err
+	}
+	id := keys["id"].value
+
+	compare := func(a, b *E) int {
+		res := cmp.Or(cmp.Compare(key.value(a), key.value(b)), cmp.Compare(id(a), id(b)))
+		if p.descending() {
+			return -res
+		}
+		return res
+	}
+
+	res := slices.Clone(entities)
+	slices.SortFunc(res, func(a, b E) int { return compare(&a, &b) })
+
+	if c != nil {
+		// drop everything up to and including the entity the cursor points to
+		res = slices.DeleteFunc(res, func(e E) bool {
+			d := cmp.Or(cmp.Compare(key.value(&e), c.Value), cmp.Compare(id(&e), c.ID))
+			if p.descending() {
+				return d >= 0
+			}
+			return d <= 0
+		})
+	}
+
+	if p.Offset != nil {
+		res = res[min(*p.Offset, uint64(len(res))):]
+	}
+	if p.Limit != nil {
+		res = res[:min(*p.Limit, uint64(len(res)))]
+	}
+
+	return res, nil
+}
+
+// nextCursor returns the cursor of the page following the given one, it is empty if there is no further page.
+func nextCursor[E any](p *Paging, keys sortKeys[E], entities []E) string {
+	if p.Limit == nil || *p.Limit == 0 || uint64(len(entities)) < *p.Limit {
+		return ""
+	}
+	key, err := sortKeyOf(p, keys)
+	if err != nil {
+		return ""
+	}
+	last := &entities[len(entities)-1]
+	return (&cursor{Value: key.value(last), ID: keys["id"].value(last)}).String()
+}
@@ -0,0 +1,69 @@
+package datastore
+
+import (
+	"log/slog"
+	"testing"
+
+	"github.com/metal-stack/metal-api/cmd/metal-api/internal/metal"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemoryStore_SearchMachinesPaged(t *testing.T) {
+	ms := NewMemory(slog.Default())
+	for _, m := range []metal.Machine{
+		{Base: metal.Base{ID: "1"}, RackID: "rack-2"},
+		{Base: metal.Base{ID: "2"}, RackID: "rack-1"},
+		{Base: metal.Base{ID: "3"}, RackID: "rack-2"},
+		{Base: metal.Base{ID: "4"}, RackID: "rack-1"},
+		{Base: metal.Base{ID: "5"}, RackID: "rack-3"},
+	} {
+		require.NoError(t, ms.CreateMachine(&m))
+	}
+
+	ids := func(machines metal.Machines) []string {
+		var res []string
+		for _, m := range machines {
+			res = append(res, m.ID)
+		}
+		return res
+	}
+
+	// iterate over all pages with the cursor
+	q := &MachineSearchQuery{Paging: Paging{Limit: new(uint64(2)), SortBy: new("rack")}}
+	var pages [][]string
+	for {
+		var machines metal.Machines
+		require.NoError(t, ms.SearchMachines(q, &machines))
+		pages = append(pages, ids(machines))
+
+		next := q.NextCursor(machines)
+		if next == "" {
+			break
+		}
+		q.Cursor = &next
+	}
+	require.Equal(t, [][]string{{"2", "4"}, {"1", "3"}, {"5"}}, pages)
+
+	// the cursor is not affected by machines which are deleted in the meantime
+	q = &MachineSearchQuery{Paging: Paging{Limit: new(uint64(2)), SortBy: new("rack"), SortDescending: new(true)}}
+	var machines metal.Machines
+	require.NoError(t, ms.SearchMachines(q, &machines))
+	require.Equal(t, []string{"5", "3"}, ids(machines))
+	require.NoError(t, ms.DeleteMachine(&machines[0]))
+	q.Cursor = new(q.NextCursor(machines))
+	require.NoError(t, ms.SearchMachines(q, &machines))
+	require.Equal(t, []string{"1", "4"}, ids(machines))
+
+	// offset and filters are applied as well
+	q = &MachineSearchQuery{RackID: new("rack-2"), Paging: Paging{Offset: new(uint64(1))}}
+	require.NoError(t, ms.SearchMachines(q, &machines))
+	require.Equal(t, []string{"3"}, ids(machines))
+	require.Empty(t, q.NextCursor(machines))
+
+	q = &MachineSearchQuery{Paging: Paging{SortBy: new("color")}}
+	require.EqualError(t, q.Validate(), `unknown sort key "color", must be one of [hostname id name partition project rack size state]`)
+	require.Error(t, ms.SearchMachines(q, &machines))
+
+	q = &MachineSearchQuery{Paging: Paging{Cursor: new("invalid!")}}
+	require.Error(t, q.Validate())
+}
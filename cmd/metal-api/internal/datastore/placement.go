@@ -0,0 +1,198 @@
+package datastore
+
+import (
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/metal-stack/metal-api/cmd/metal-api/internal/metal"
+)
+
+// PlacementContext contains everything a placement strategy can base its decision on.
+type PlacementContext struct {
+	ProjectID string
+	Role      metal.Role
+	Placement metal.Placement
+	// PartitionMachines are all machines of the partition with the size of the allocation
+	PartitionMachines metal.Machines
+	// EventContainers are the provisioning event containers of the machines by machine id
+	EventContainers metal.ProvisioningEventContainerMap
+	// Switches are the switches of the partition, they are only provided for the switch affinity strategy
+	Switches metal.Switches
+	// SpreadLevel is the level of the failure domains the machines are spread or packed across
+	SpreadLevel metal.LocationLevel
+	// Locations are all locations by id, they are only provided if the spread level is not rack level
+	Locations metal.LocationMap
+}
+
+// domain returns the failure domain of the machine at the spread level.
+func (c *PlacementContext) domain(m *metal.Machine) string {
+	return c.Locations.Domain(m.RackID, c.SpreadLevel)
+}
+
+func (c *PlacementContext) level() metal.LocationLevel {
+	if c.SpreadLevel == "" {
+		return metal.LocationLevelRack
+	}
+	return c.SpreadLevel
+}
+
+// A PlacementStrategy decides which of the electable candidates are preferred for an allocation,
+// the machine is elected randomly among the preferred candidates. Strategies do not access the datastore,
+// everything they need is provided by the placement context.
+type PlacementStrategy interface {
+	// Prefer returns a function which returns whether a candidate is preferred and the reason if it is not.
+	Prefer(candidates metal.Machines, c *PlacementContext) func(m *metal.Machine) (bool, string)
+}
+
+// NewPlacementStrategy returns the placement strategy of the given type.
+func NewPlacementStrategy(t metal.PlacementStrategyType) (PlacementStrategy, error) {
+	switch t {
+	case metal.PlacementStrategyRackSpreading:
+		return rackSpreading{}, nil
+	case metal.PlacementStrategyBinPacking:
+		return binPacking{}, nil
+	case metal.PlacementStrategySwitchAffinity:
+		return switchAffinity{}, nil
+	case metal.PlacementStrategyLeastRecentlyReinstalled:
+		return leastRecentlyReinstalled{}, nil
+	default:
+		return nil, fmt.Errorf("unknown placement strategy: %q", t)
+	}
+}
+
+// rackSpreading prefers the racks which are least occupied by the machines of the project and the placement tags.
+type rackSpreading struct{}
+
+func (rackSpreading) Prefer(candidates metal.Machines, c *PlacementContext) func(m *metal.Machine) (bool, string) {
+	projectMachines := c.PartitionMachines.WithRole(c.Role).ByProjectID()[c.ProjectID]
+
+	spreadDomains := groupBy(spreadAcrossDomains(candidates, projectMachines, c.Placement.Tags, c.domain), c.domain)
+	return func(m *metal.Machine) (bool, string) {
+		if _, ok := spreadDomains[c.domain(m)]; !ok {
+			return false, fmt.Sprintf("%s %q is not among the least occupied %ss of the project and placement tags", c.level(), c.domain(m), c.level())
+		}
+		return true, ""
+	}
+}
+
+// binPacking prefers the racks which are most occupied by the machines of the project, such that the project ends up in the fewest racks.
+// if the project has no machines in the racks of the candidates yet, the racks which are most occupied by all allocations are preferred.
+type binPacking struct{}
+
+func (binPacking) Prefer(candidates metal.Machines, c *PlacementContext) func(m *metal.Machine) (bool, string) {
+	var (
+		allocated        = c.PartitionMachines.WithRole(c.Role)
+		projectMachines  = allocated.ByProjectID()[c.ProjectID]
+		candidateDomains = groupBy(candidates, c.domain)
+	)
+
+	domains := mostOccupiedRacks(candidateDomains, groupBy(projectMachines, c.domain))
+	scope := "project"
+	if len(domains) == 0 {
+		domains = mostOccupiedRacks(candidateDomains, groupBy(allocated, c.domain))
+		scope = "partition"
+	}
+
+	return func(m *metal.Machine) (bool, string) {
+		if len(domains) > 0 && !slices.Contains(domains, c.domain(m)) {
+			return false, fmt.Sprintf("%s %q is not among the most occupied %ss of the %s", c.level(), c.domain(m), c.level(), scope)
+		}
+		return true, ""
+	}
+}
+
+// mostOccupiedRacks returns the candidate racks or failure domains with the most occupied machines, it returns nil if none of them is occupied.
+func mostOccupiedRacks(candidateRacks, occupiedRacks groupedMachines) []string {
+	var (
+		winners []string
+		most    = 0
+	)
+
+	for id := range candidateRacks {
+		occupied := len(occupiedRacks[id])
+		switch {
+		case occupied == 0:
+		case occupied > most:
+			most = occupied
+			winners = []string{id}
+		case occupied == most:
+			winners = append(winners, id)
+		}
+	}
+
+	return winners
+}
+
+// switchAffinity only allows the candidates which are connected to all switches of the placement.
+type switchAffinity struct{}
+
+func (switchAffinity) Prefer(candidates metal.Machines, c *PlacementContext) func(m *metal.Machine) (bool, string) {
+	connections := map[string]int{}
+	for _, s := range c.Switches {
+		if !slices.Contains(c.Placement.Switches, s.ID) {
+			continue
+		}
+		for machineID := range s.MachineConnections {
+			connections[machineID]++
+		}
+	}
+
+	return func(m *metal.Machine) (bool, string) {
+		if len(c.Placement.Switches) == 0 || connections[m.ID] < len(c.Placement.Switches) {
+			return false, fmt.Sprintf("machine is not connected to the switches %v", c.Placement.Switches)
+		}
+		return true, ""
+	}
+}
+
+// leastRecentlyReinstalled prefers the candidates whose last installation is the longest ago, machines which were
+// never installed first. among candidates which were installed at the same time, the oldest hardware is preferred.
+type leastRecentlyReinstalled struct{}
+
+func (leastRecentlyReinstalled) Prefer(candidates metal.Machines, c *PlacementContext) func(m *metal.Machine) (bool, string) {
+	var preferred *metal.Machine
+	for i := range candidates {
+		m := &candidates[i]
+		if preferred == nil {
+			preferred = m
+			continue
+		}
+
+		installed, preferredInstalled := lastInstallation(c.EventContainers, m.ID), lastInstallation(c.EventContainers, preferred.ID)
+		switch {
+		case installed.Before(preferredInstalled):
+			preferred = m
+		case installed.Equal(preferredInstalled) && m.Created.Before(preferred.Created):
+			preferred = m
+		}
+	}
+
+	return func(m *metal.Machine) (bool, string) {
+		if preferred == nil || m.ID == preferred.ID {
+			return true, ""
+		}
+		if installed := lastInstallation(c.EventContainers, m.ID); !installed.IsZero() {
+			return false, fmt.Sprintf("machine was installed at %s, other machines were installed less recently", installed.Format(time.RFC3339))
+		}
+		return false, "machine was registered more recently than other machines which were never installed"
+	}
+}
+
+// lastInstallation returns the time of the latest installing event of the machine, it is zero if the machine was never installed.
+func lastInstallation(ecs metal.ProvisioningEventContainerMap, machineID string) time.Time {
+	var last time.Time
+
+	ec, ok := ecs[machineID]
+	if !ok {
+		return last
+	}
+
+	for _, e := range ec.Events {
+		if e.Event == metal.ProvisioningEventInstalling && e.Time.After(last) {
+			last = e.Time
+		}
+	}
+
+	return last
+}
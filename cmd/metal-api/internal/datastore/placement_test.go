@@ -0,0 +1,153 @@
+package datastore
+
+import (
+	"testing"
+	"time"
+
+	"github.com/metal-stack/metal-api/cmd/metal-api/internal/metal"
+	"github.com/stretchr/testify/require"
+)
+
+func preferred(t *testing.T, strategy metal.PlacementStrategyType, candidates metal.Machines, c *PlacementContext) []string {
+	s, err := NewPlacementStrategy(strategy)
+	require.NoError(t, err)
+
+	prefer := s.Prefer(candidates, c)
+
+	var ids []string
+	for i := range candidates {
+		ok, reason := prefer(&candidates[i])
+		if ok {
+			ids = append(ids, candidates[i].ID)
+			continue
+		}
+		require.NotEmpty(t, reason)
+	}
+	return ids
+}
+
+func TestPlacementStrategies(t *testing.T) {
+	var (
+		now        = time.Now()
+		allocation = func(project string) *metal.MachineAllocation {
+			return &metal.MachineAllocation{Project: project, Role: metal.RoleMachine}
+		}
+		candidates = metal.Machines{
+			{Base: metal.Base{ID: "1", Created: now.Add(-48 * time.Hour)}, RackID: "rack-1"},
+			{Base: metal.Base{ID: "2", Created: now.Add(-72 * time.Hour)}, RackID: "rack-2"},
+			{Base: metal.Base{ID: "3", Created: now.Add(-24 * time.Hour)}, RackID: "rack-3"},
+		}
+		partitionMachines = append(metal.Machines{
+			{Base: metal.Base{ID: "4"}, RackID: "rack-1", Allocation: allocation("project")},
+			{Base: metal.Base{ID: "5"}, RackID: "rack-2", Allocation: allocation("other")},
+			{Base: metal.Base{ID: "6"}, RackID: "rack-2", Allocation: allocation("other")},
+		}, candidates...)
+		installed = func(ts ...time.Time) metal.ProvisioningEventContainer {
+			var ec metal.ProvisioningEventContainer
+			for _, t := range ts {
+				ec.Events = append(ec.Events, metal.ProvisioningEvent{Time: t, Event: metal.ProvisioningEventInstalling})
+			}
+			return ec
+		}
+	)
+
+	tests := []struct {
+		name     string
+		strategy metal.PlacementStrategyType
+		context  *PlacementContext
+		want     []string
+	}{
+		{
+			name:     "rack spreading avoids the racks of the project",
+			strategy: metal.PlacementStrategyRackSpreading,
+			context:  &PlacementContext{ProjectID: "project", Role: metal.RoleMachine, PartitionMachines: partitionMachines},
+			want:     []string{"2", "3"},
+		},
+		{
+			name:     "rack spreading across power feeds",
+			strategy: metal.PlacementStrategyRackSpreading,
+			context: &PlacementContext{
+				ProjectID:         "project",
+				Role:              metal.RoleMachine,
+				PartitionMachines: partitionMachines,
+				SpreadLevel:       metal.LocationLevelPowerFeed,
+				Locations: metal.Locations{
+					{Base: metal.Base{ID: "rack-1"}, Level: metal.LocationLevelRack, PowerFeedID: "feed-a"},
+					{Base: metal.Base{ID: "rack-2"}, Level: metal.LocationLevelRack, PowerFeedID: "feed-a"},
+					{Base: metal.Base{ID: "rack-3"}, Level: metal.LocationLevelRack, PowerFeedID: "feed-b"},
+				}.ByID(),
+			},
+			want: []string{"3"},
+		},
+		{
+			name:     "bin packing prefers the racks of the project",
+			strategy: metal.PlacementStrategyBinPacking,
+			context:  &PlacementContext{ProjectID: "project", Role: metal.RoleMachine, PartitionMachines: partitionMachines},
+			want:     []string{"1"},
+		},
+		{
+			name:     "bin packing prefers the most occupied racks for new projects",
+			strategy: metal.PlacementStrategyBinPacking,
+			context:  &PlacementContext{ProjectID: "new", Role: metal.RoleMachine, PartitionMachines: partitionMachines},
+			want:     []string{"2"},
+		},
+		{
+			name:     "bin packing in an empty partition",
+			strategy: metal.PlacementStrategyBinPacking,
+			context:  &PlacementContext{ProjectID: "new", Role: metal.RoleMachine, PartitionMachines: candidates},
+			want:     []string{"1", "2", "3"},
+		},
+		{
+			name:     "switch affinity requires connections to all switches",
+			strategy: metal.PlacementStrategySwitchAffinity,
+			context: &PlacementContext{
+				Placement: metal.Placement{Switches: []string{"leaf01", "leaf02"}},
+				Switches: metal.Switches{
+					{Base: metal.Base{ID: "leaf01"}, MachineConnections: metal.ConnectionMap{"1": nil, "2": nil}},
+					{Base: metal.Base{ID: "leaf02"}, MachineConnections: metal.ConnectionMap{"1": nil}},
+					{Base: metal.Base{ID: "leaf03"}, MachineConnections: metal.ConnectionMap{"2": nil, "3": nil}},
+				},
+			},
+			want: []string{"1"},
+		},
+		{
+			name:     "switch affinity without switches",
+			strategy: metal.PlacementStrategySwitchAffinity,
+			context:  &PlacementContext{},
+			want:     nil,
+		},
+		{
+			name:     "least recently reinstalled prefers machines which were never installed",
+			strategy: metal.PlacementStrategyLeastRecentlyReinstalled,
+			context: &PlacementContext{EventContainers: metal.ProvisioningEventContainerMap{
+				"1": installed(now.Add(-time.Hour)),
+				"2": installed(now.Add(-3*time.Hour), now.Add(-2*time.Minute)),
+			}},
+			want: []string{"3"},
+		},
+		{
+			name:     "least recently reinstalled considers the last installation",
+			strategy: metal.PlacementStrategyLeastRecentlyReinstalled,
+			context: &PlacementContext{EventContainers: metal.ProvisioningEventContainerMap{
+				"1": installed(now.Add(-time.Hour)),
+				"2": installed(now.Add(-3*time.Hour), now.Add(-2*time.Minute)),
+				"3": installed(now.Add(-10 * time.Minute)),
+			}},
+			want: []string{"1"},
+		},
+		{
+			name:     "least recently reinstalled prefers the oldest hardware",
+			strategy: metal.PlacementStrategyLeastRecentlyReinstalled,
+			context:  &PlacementContext{},
+			want:     []string{"2"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.ElementsMatch(t, tt.want, preferred(t, tt.strategy, candidates, tt.context))
+		})
+	}
+
+	_, err := NewPlacementStrategy("random")
+	require.Error(t, err)
+}
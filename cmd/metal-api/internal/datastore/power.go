@@ -0,0 +1,124 @@
+package datastore
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/metal-stack/metal-api/cmd/metal-api/internal/metal"
+	r "gopkg.in/rethinkdb/rethinkdb-go.v6"
+)
+
+// PowerSampleSearchQuery can be used to search power samples.
+type PowerSampleSearchQuery struct {
+	MachineID   *string        `json:"machineid" optional:"true"`
+	PartitionID *string        `json:"partitionid" optional:"true"`
+	RackID      *string        `json:"rackid" optional:"true"`
+	SizeID      *string        `json:"sizeid" optional:"true"`
+	ProjectID   *string        `json:"projectid" optional:"true"`
+	Resolution  *time.Duration `json:"resolution" optional:"true"`
+	From        *time.Time     `json:"from" description:"only samples at or after this time" optional:"true"`
+	To          *time.Time     `json:"to" description:"only samples before this time" optional:"true"`
+}
+
+func (p *PowerSampleSearchQuery) generateTerm(rs *RethinkStore) *r.Term {
+	q := *rs.powerSampleTable()
+
+	if p.MachineID != nil {
+		q = q.Filter(func(row r.Term) r.Term {
+			return row.Field("machineid").Eq(*p.MachineID)
+		})
+	}
+
+	if p.PartitionID != nil {
+		q = q.Filter(func(row r.Term) r.Term {
+			return row.Field("partitionid").Eq(*p.PartitionID)
+		})
+	}
+
+	if p.RackID != nil {
+		q = q.Filter(func(row r.Term) r.Term {
+			return row.Field("rackid").Eq(*p.RackID)
+		})
+	}
+
+	if p.SizeID != nil {
+		q = q.Filter(func(row r.Term) r.Term {
+			return row.Field("sizeid").Eq(*p.SizeID)
+		})
+	}
+
+	if p.ProjectID != nil {
+		q = q.Filter(func(row r.Term) r.Term {
+			return row.Field("projectid").Eq(*p.ProjectID)
+		})
+	}
+
+	if p.Resolution != nil {
+		q = q.Filter(func(row r.Term) r.Term {
+			return row.Field("resolution").Eq(int64(*p.Resolution))
+		})
+	}
+
+	if p.From != nil {
+		q = q.Filter(func(row r.Term) r.Term {
+			return row.Field("time").Ge(*p.From)
+		})
+	}
+
+	if p.To != nil {
+		q = q.Filter(func(row r.Term) r.Term {
+			return row.Field("time").Lt(*p.To)
+		})
+	}
+
+	return &q
+}
+
+// FindPowerSample returns the power sample for the given id.
+func (rs *RethinkStore) FindPowerSample(id string) (*metal.PowerSample, error) {
+	var s metal.PowerSample
+	err := rs.findEntityByID(rs.powerSampleTable(), &s, id)
+	if err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+// SearchPowerSamples returns the result of the power samples search request query.
+func (rs *RethinkStore) SearchPowerSamples(q *PowerSampleSearchQuery, ss *metal.PowerSamples) error {
+	return rs.searchEntities(q.generateTerm(rs), ss)
+}
+
+// CreatePowerSample creates a new power sample.
+func (rs *RethinkStore) CreatePowerSample(s *metal.PowerSample) error {
+	return rs.createEntity(rs.powerSampleTable(), s)
+}
+
+// UpdatePowerSample updates a power sample, it fails with a conflict if it was changed in the meantime.
+func (rs *RethinkStore) UpdatePowerSample(oldSample *metal.PowerSample, newSample *metal.PowerSample) error {
+	return rs.updateEntity(rs.powerSampleTable(), newSample, oldSample)
+}
+
+// DeletePowerSample deletes a power sample, it returns a not found error if the sample does not exist anymore.
+// Like this, the deletion can be used to claim a sample when several metal-api instances process the same samples.
+func (rs *RethinkStore) DeletePowerSample(s *metal.PowerSample) error {
+	res, err := rs.powerSampleTable().Get(s.ID).Delete().RunWrite(rs.session)
+	if err != nil {
+		return fmt.Errorf("cannot delete power sample with id %q from database: %w", s.ID, err)
+	}
+	if res.Deleted == 0 {
+		return metal.NotFound("no power sample with id %q found", s.ID)
+	}
+	return nil
+}
+
+// DeletePowerSamplesBefore removes all power samples older than the given time and returns the amount of removed samples.
+func (rs *RethinkStore) DeletePowerSamplesBefore(t time.Time) (int, error) {
+	res, err := rs.powerSampleTable().Filter(func(row r.Term) r.Term {
+		return row.Field("time").Lt(t)
+	}).Delete().RunWrite(rs.session)
+	if err != nil {
+		return 0, fmt.Errorf("cannot delete power samples: %w", err)
+	}
+	return res.Deleted, nil
+}
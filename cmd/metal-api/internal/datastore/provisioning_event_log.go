@@ -0,0 +1,175 @@
+package datastore
+
+import (
+	"encoding/base64"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/metal-stack/metal-api/cmd/metal-api/internal/metal"
+	r "gopkg.in/rethinkdb/rethinkdb-go.v6"
+)
+
+const (
+	// DefaultProvisioningEventLogLimit is the page size of a provisioning event log search if no limit is given.
+	DefaultProvisioningEventLogLimit = 100
+	// MaxProvisioningEventLogLimit is the maximum page size of a provisioning event log search.
+	MaxProvisioningEventLogLimit = 1000
+
+	// provisioningEventLogIndex orders the provisioning event log chronologically, the id breaks ties between entries of the same time
+	provisioningEventLogIndex = "time_id"
+)
+
+// ProvisioningEventLogSearchQuery can be used to search the provisioning event log.
+// The entries are returned in chronological order, the next page is requested by passing the cursor of the previous page.
+type ProvisioningEventLogSearchQuery struct {
+	MachineID *string    `json:"machineid" optional:"true"`
+	From      *time.Time `json:"from" description:"only entries at or after this time" optional:"true"`
+	To        *time.Time `json:"to" description:"only entries before this time" optional:"true"`
+	Event     *string    `json:"event" optional:"true"`
+	Message   *string    `json:"message" description:"only entries whose message contains this text" optional:"true"`
+	Cursor    *string    `json:"cursor" description:"the cursor returned with the previous page" optional:"true"`
+	Limit     *int       `json:"limit" description:"the maximum amount of entries per page, defaults to 100" optional:"true" minimum:"1" maximum:"1000"`
+}
+
+type provisioningEventLogCursor struct {
+	time time.Time
+	id   string
+}
+
+func (p *ProvisioningEventLogSearchQuery) limit() (int, error) {
+	if p.Limit == nil {
+		return DefaultProvisioningEventLogLimit, nil
+	}
+	if *p.Limit < 1 || *p.Limit > MaxProvisioningEventLogLimit {
+		return 0, fmt.Errorf("limit must be between 1 and %d", MaxProvisioningEventLogLimit)
+	}
+	return *p.Limit, nil
+}
+
+func (p *ProvisioningEventLogSearchQuery) cursor() (*provisioningEventLogCursor, error) {
+	if p.Cursor == nil || *p.Cursor == "" {
+		return nil, nil
+	}
+
+	raw, err := base64.RawURLEncoding.DecodeString(*p.Cursor)
+	if err != nil {
+		return nil, fmt.Errorf("invalid cursor: %w", err)
+	}
+	nanos, id, ok := strings.Cut(string(raw), "/")
+	if !ok {
+		return nil, fmt.Errorf("invalid cursor %q", *p.Cursor)
+	}
+	n, err := strconv.ParseInt(nanos, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid cursor: %w", err)
+	}
+
+	return &provisioningEventLogCursor{time: time.Unix(0, n), id: id}, nil
+}
+
+func provisioningEventLogNextCursor(e *metal.ProvisioningEventLogEntry) *string {
+	cursor := base64.RawURLEncoding.EncodeToString(fmt.Appendf(nil, "%d/%s", e.Time.UnixNano(), e.ID))
+	return &cursor
+}
+
+func (p *ProvisioningEventLogSearchQuery) generateTerm(rs *RethinkStore) (*r.Term, error) {
+	q := rs.provisioningEventLogTable().OrderBy(r.OrderByOpts{Index: provisioningEventLogIndex})
+
+	if p.MachineID != nil {
+		q = q.Filter(func(row r.Term) r.Term {
+			return row.Field("machineid").Eq(*p.MachineID)
+		})
+	}
+
+	if p.From != nil {
+		q = q.Filter(func(row r.Term) r.Term {
+			return row.Field("time").Ge(*p.From)
+		})
+	}
+
+	if p.To != nil {
+		q = q.Filter(func(row r.Term) r.Term {
+			return row.Field("time").Lt(*p.To)
+		})
+	}
+
+	if p.Event != nil {
+		q = q.Filter(func(row r.Term) r.Term {
+			return row.Field("event").Eq(*p.Event)
+		})
+	}
+
+	if p.Message != nil {
+		q = q.Filter(func(row r.Term) r.Term {
+			return row.Field("message").Match(regexp.QuoteMeta(*p.Message))
+		})
+	}
+
+	cursor, err := p.cursor()
+	if err != nil {
+		return nil, err
+	}
+	if cursor != nil {
+		q = q.Filter(func(row r.Term) r.Term {
+			return row.Field("time").Gt(cursor.time).Or(row.Field("time").Eq(cursor.time).And(row.Field("id").Gt(cursor.id)))
+		})
+	}
+
+	limit, err := p.limit()
+	if err != nil {
+		return nil, err
+	}
+
+	// one more entry is fetched to find out whether there is a next page
+	q = q.Limit(limit + 1)
+
+	return &q, nil
+}
+
+// paginate cuts the entries to the limit of the query and returns the cursor of the next page if there are more entries.
+func (p *ProvisioningEventLogSearchQuery) paginate(entries metal.ProvisioningEventLogEntries) (metal.ProvisioningEventLogEntries, *string) {
+	limit, _ := p.limit()
+	if len(entries) <= limit {
+		return entries, nil
+	}
+
+	entries = entries[:limit]
+	return entries, provisioningEventLogNextCursor(&entries[limit-1])
+}
+
+// SearchProvisioningEventLog returns a page of the provisioning event log entries matching the query
+// and the cursor of the next page, which is nil on the last page.
+func (rs *RethinkStore) SearchProvisioningEventLog(q *ProvisioningEventLogSearchQuery) (metal.ProvisioningEventLogEntries, *string, error) {
+	term, err := q.generateTerm(rs)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	entries := make(metal.ProvisioningEventLogEntries, 0)
+	err = rs.searchEntities(term, &entries)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	entries, next := q.paginate(entries)
+	return entries, next, nil
+}
+
+// CreateProvisioningEventLogEntry appends an entry to the provisioning event log.
+func (rs *RethinkStore) CreateProvisioningEventLogEntry(e *metal.ProvisioningEventLogEntry) error {
+	return rs.createEntity(rs.provisioningEventLogTable(), e)
+}
+
+// DeleteProvisioningEventLogBefore removes all provisioning event log entries older than the given time and returns the amount of removed entries.
+func (rs *RethinkStore) DeleteProvisioningEventLogBefore(t time.Time) (int, error) {
+	res, err := rs.provisioningEventLogTable().Filter(func(row r.Term) r.Term {
+		return row.Field("time").Lt(t)
+	}).Delete().RunWrite(rs.session)
+	if err != nil {
+		return 0, fmt.Errorf("cannot delete provisioning event log entries: %w", err)
+	}
+	return res.Deleted, nil
+}
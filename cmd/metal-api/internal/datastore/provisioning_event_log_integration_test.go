@@ -0,0 +1,22 @@
+//go:build integration
+
+package datastore
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestStore_ProvisioningEventLog(t *testing.T) {
+	forEachStore(t, func(t *testing.T) {
+		defer func() {
+			err := wipeTable(sharedDS, "provisioningeventlog")
+			require.NoError(t, err)
+			err = wipeTable(sharedDS, "event")
+			require.NoError(t, err)
+		}()
+
+		testProvisioningEventLog(t, sharedDS)
+	})
+}
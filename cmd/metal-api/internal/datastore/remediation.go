@@ -0,0 +1,37 @@
+package datastore
+
+import (
+	"github.com/metal-stack/metal-api/cmd/metal-api/internal/metal"
+)
+
+// FindMachineRemediation returns the remediations of the machine with the given id.
+func (rs *RethinkStore) FindMachineRemediation(id string) (*metal.MachineRemediation, error) {
+	var mr metal.MachineRemediation
+	err := rs.findEntityByID(rs.remediationTable(), &mr, id)
+	if err != nil {
+		return nil, err
+	}
+	return &mr, nil
+}
+
+// ListMachineRemediations returns the remediations of all machines.
+func (rs *RethinkStore) ListMachineRemediations() (metal.MachineRemediations, error) {
+	mrs := make(metal.MachineRemediations, 0)
+	err := rs.listEntities(rs.remediationTable(), &mrs)
+	return mrs, err
+}
+
+// CreateMachineRemediation creates the remediations of a machine.
+func (rs *RethinkStore) CreateMachineRemediation(mr *metal.MachineRemediation) error {
+	return rs.createEntity(rs.remediationTable(), mr)
+}
+
+// DeleteMachineRemediation deletes the remediations of a machine.
+func (rs *RethinkStore) DeleteMachineRemediation(mr *metal.MachineRemediation) error {
+	return rs.deleteEntity(rs.remediationTable(), mr)
+}
+
+// UpdateMachineRemediation updates the remediations of a machine, it fails with a conflict if they were changed in the meantime.
+func (rs *RethinkStore) UpdateMachineRemediation(oldRemediation *metal.MachineRemediation, newRemediation *metal.MachineRemediation) error {
+	return rs.updateEntity(rs.remediationTable(), newRemediation, oldRemediation)
+}
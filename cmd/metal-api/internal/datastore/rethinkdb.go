@@ -20,14 +20,26 @@ const (
 
 var tables = []string{
 	ASNIntegerPool.String(), ASNIntegerPool.String() + "info",
+	"entitychange",
+	"entitychangewriter",
 	"event",
 	"filesystemlayout",
+	"firewallrulerevision",
+	"hardwaresnapshot",
 	"image",
 	"ip",
+	"location",
 	"machine",
+	"machinecommand",
+	"maintenancewindow",
 	"migration",
 	"network",
+	"outbox",
 	"partition",
+	"pendingallocation",
+	"powersample",
+	"provisioningeventlog",
+	"remediation",
 	"sharedmutex",
 	"size",
 	"sizeimageconstraint",
@@ -121,6 +133,11 @@ func (rs *RethinkStore) initializeTables(opts r.TableCreateOpts) error {
 		db.Table("machine").IndexList().Contains("project").Do(func(i r.Term) r.Term {
 			return r.Branch(i, nil, db.Table("machine").IndexCreate("project"))
 		}),
+		db.Table("provisioningeventlog").IndexList().Contains(provisioningEventLogIndex).Do(func(i r.Term) r.Term {
+			return r.Branch(i, nil, db.Table("provisioningeventlog").IndexCreateFunc(provisioningEventLogIndex, func(row r.Term) r.Term {
+				return r.Expr([]any{row.Field("time"), row.Field("id")})
+			}))
+		}),
 	)
 	if err != nil {
 		return err
@@ -152,12 +169,12 @@ func (rs *RethinkStore) initializeTables(opts r.TableCreateOpts) error {
 	}
 
 	// integer pools
-	err = rs.GetVRFPool().initIntegerPool(rs.log)
+	err = rs.vrfPool().initIntegerPool(rs.log)
 	if err != nil {
 		return err
 	}
 
-	err = rs.GetASNPool().initIntegerPool(rs.log)
+	err = rs.asnPool().initIntegerPool(rs.log)
 	if err != nil {
 		return err
 	}
@@ -225,6 +242,66 @@ func (rs *RethinkStore) sizeReservationTable() *r.Term {
 	return &res
 }
 
+func (rs *RethinkStore) firewallRuleRevisionTable() *r.Term {
+	res := r.DB(rs.dbname).Table("firewallrulerevision")
+	return &res
+}
+
+func (rs *RethinkStore) entityChangeTable() *r.Term {
+	res := r.DB(rs.dbname).Table("entitychange")
+	return &res
+}
+
+func (rs *RethinkStore) entityChangeWriterTable() *r.Term {
+	res := r.DB(rs.dbname).Table("entitychangewriter")
+	return &res
+}
+
+func (rs *RethinkStore) hardwareSnapshotTable() *r.Term {
+	res := r.DB(rs.dbname).Table("hardwaresnapshot")
+	return &res
+}
+
+func (rs *RethinkStore) pendingAllocationTable() *r.Term {
+	res := r.DB(rs.dbname).Table("pendingallocation")
+	return &res
+}
+
+func (rs *RethinkStore) provisioningEventLogTable() *r.Term {
+	res := r.DB(rs.dbname).Table("provisioningeventlog")
+	return &res
+}
+
+func (rs *RethinkStore) machineCommandTable() *r.Term {
+	res := r.DB(rs.dbname).Table("machinecommand")
+	return &res
+}
+
+func (rs *RethinkStore) powerSampleTable() *r.Term {
+	res := r.DB(rs.dbname).Table("powersample")
+	return &res
+}
+
+func (rs *RethinkStore) outboxTable() *r.Term {
+	res := r.DB(rs.dbname).Table("outbox")
+	return &res
+}
+
+func (rs *RethinkStore) remediationTable() *r.Term {
+	res := r.DB(rs.dbname).Table("remediation")
+	return &res
+}
+
+func (rs *RethinkStore) locationTable() *r.Term {
+	res := r.DB(rs.dbname).Table("location")
+	return &res
+}
+
+func (rs *RethinkStore) maintenanceWindowTable() *r.Term {
+	res := r.DB(rs.dbname).Table("maintenancewindow")
+	return &res
+}
+
 func (rs *RethinkStore) asnTable() *r.Term {
 	res := r.DB(rs.dbname).Table(ASNIntegerPool.String())
 	return &res
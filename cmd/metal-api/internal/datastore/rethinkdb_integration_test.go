@@ -4,6 +4,7 @@ package datastore
 
 import (
 	"context"
+	"fmt"
 	"log/slog"
 	"os"
 	"sort"
@@ -20,25 +21,51 @@ import (
 	"testing"
 )
 
-// sharedDS is started before running the tests of this package (only once because it saves a lot of time then).
-// it can be used for integration testing with a real rethinkdb.
+// sharedStores are the store implementations every integration test is run against. the rethinkdb is started
+// before running the tests of this package (only once because it saves a lot of time then).
 //
 // please make sure that after every test you clean up the data of the test in order not to have to deal with side-effects across
 // the tests.
-var sharedDS *RethinkStore
+var sharedStores []sharedStore
+
+// sharedDS is the store the current test is run against, it is set by forEachStore.
+var sharedDS Store
+
+// sharedRethinkDS is the rethinkdb store of sharedStores for tests of rethinkdb internals.
+var sharedRethinkDS *RethinkStore
+
+type sharedStore struct {
+	name string
+	ds   Store
+}
 
 func TestMain(m *testing.M) {
-	var container testcontainers.Container
-	container, sharedDS = startRethinkInitialized()
+	container, rs := startRethinkInitialized()
+	sharedRethinkDS = rs
 	defer func() {
 		err := container.Terminate(context.Background())
 		panic(err)
 	}()
 
+	sharedStores = []sharedStore{
+		{name: "rethinkdb", ds: rs},
+		{name: "memory", ds: startMemoryInitialized()},
+	}
+
 	code := m.Run()
 	os.Exit(code)
 }
 
+// forEachStore runs the given test once for every store implementation, the store is available through sharedDS.
+func forEachStore(t *testing.T, fn func(t *testing.T)) {
+	for _, s := range sharedStores {
+		t.Run(s.name, func(t *testing.T) {
+			sharedDS = s.ds
+			fn(t)
+		})
+	}
+}
+
 func startRethinkInitialized() (container testcontainers.Container, ds *RethinkStore) {
 	container, c, err := test.StartRethink(nil)
 	if err != nil {
@@ -66,6 +93,51 @@ func startRethinkInitialized() (container testcontainers.Container, ds *RethinkS
 	return container, rs
 }
 
+func startMemoryInitialized() *MemoryStore {
+	ms := NewMemory(slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelError})))
+
+	ms.VRFPoolRangeMin = 10000
+	ms.VRFPoolRangeMax = 10010
+	ms.ASNPoolRangeMin = 10000
+	ms.ASNPoolRangeMax = 10010
+
+	err := ms.Initialize()
+	if err != nil {
+		panic(err)
+	}
+
+	return ms
+}
+
+// wipeTable removes all entities of the given table from the store.
+func wipeTable(ds Store, table string) error {
+	switch s := ds.(type) {
+	case *RethinkStore:
+		_, err := s.db().Table(table).Delete().RunWrite(s.session)
+		return err
+	case *MemoryStore:
+		s.mtx.Lock()
+		defer s.mtx.Unlock()
+		delete(s.tables, table)
+		return nil
+	default:
+		return fmt.Errorf("unsupported store %T", ds)
+	}
+}
+
+// createEntity stores the given entity without the validations of the store, e.g. for creating allocated machines.
+func createEntity(ds Store, table string, entity metal.Entity) error {
+	switch s := ds.(type) {
+	case *RethinkStore:
+		term := s.db().Table(table)
+		return s.createEntity(&term, entity)
+	case *MemoryStore:
+		return s.createEntity(table, entity)
+	default:
+		return fmt.Errorf("unsupported store %T", ds)
+	}
+}
+
 func ignoreTimestamps() cmp.Option {
 	return cmpopts.IgnoreFields(metal.Base{}, "Created", "Changed")
 }
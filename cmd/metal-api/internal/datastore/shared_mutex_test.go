@@ -24,27 +24,27 @@ func Test_sharedMutex_reallyLocking(t *testing.T) {
 	ctx := t.Context()
 	expiration := 10 * time.Second
 
-	err := sharedDS.sharedMutex.lock(ctx, "test", expiration, newLockOptAcquireTimeout(10*time.Millisecond))
+	err := sharedRethinkDS.sharedMutex.lock(ctx, "test", expiration, newLockOptAcquireTimeout(10*time.Millisecond))
 	require.NoError(t, err)
 
-	err = sharedDS.sharedMutex.lock(ctx, "test", expiration, newLockOptAcquireTimeout(5*time.Millisecond))
+	err = sharedRethinkDS.sharedMutex.lock(ctx, "test", expiration, newLockOptAcquireTimeout(5*time.Millisecond))
 	require.Error(t, err)
 	require.ErrorContains(t, err, "unable to acquire mutex")
 
-	err = sharedDS.sharedMutex.lock(ctx, "test2", expiration, newLockOptAcquireTimeout(10*time.Millisecond))
+	err = sharedRethinkDS.sharedMutex.lock(ctx, "test2", expiration, newLockOptAcquireTimeout(10*time.Millisecond))
 	require.NoError(t, err)
 
-	err = sharedDS.sharedMutex.lock(ctx, "test", expiration, newLockOptAcquireTimeout(10*time.Millisecond))
+	err = sharedRethinkDS.sharedMutex.lock(ctx, "test", expiration, newLockOptAcquireTimeout(10*time.Millisecond))
 	require.Error(t, err)
 	require.ErrorContains(t, err, "unable to acquire mutex")
 
-	sharedDS.sharedMutex.unlock(ctx, "test")
+	sharedRethinkDS.sharedMutex.unlock(ctx, "test")
 
-	err = sharedDS.sharedMutex.lock(ctx, "test2", expiration, newLockOptAcquireTimeout(10*time.Millisecond))
+	err = sharedRethinkDS.sharedMutex.lock(ctx, "test2", expiration, newLockOptAcquireTimeout(10*time.Millisecond))
 	require.Error(t, err)
 	require.ErrorContains(t, err, "unable to acquire mutex")
 
-	err = sharedDS.sharedMutex.lock(ctx, "test", expiration, newLockOptAcquireTimeout(10*time.Millisecond))
+	err = sharedRethinkDS.sharedMutex.lock(ctx, "test", expiration, newLockOptAcquireTimeout(10*time.Millisecond))
 	require.NoError(t, err)
 }
 
@@ -52,19 +52,19 @@ func Test_sharedMutex_acquireAfterRelease(t *testing.T) {
 	defer mutexCleanup(t)
 	ctx := t.Context()
 
-	err := sharedDS.sharedMutex.lock(ctx, "test", 3*time.Second, newLockOptAcquireTimeout(10*time.Millisecond))
+	err := sharedRethinkDS.sharedMutex.lock(ctx, "test", 3*time.Second, newLockOptAcquireTimeout(10*time.Millisecond))
 	require.NoError(t, err)
 
 	var wg sync.WaitGroup
 	wg.Go(func() {
 
-		err = sharedDS.sharedMutex.lock(ctx, "test", 1*time.Second, newLockOptAcquireTimeout(3*time.Second))
+		err = sharedRethinkDS.sharedMutex.lock(ctx, "test", 1*time.Second, newLockOptAcquireTimeout(3*time.Second))
 		assert.NoError(t, err)
 	})
 
 	time.Sleep(1 * time.Second)
 
-	sharedDS.sharedMutex.unlock(ctx, "test")
+	sharedRethinkDS.sharedMutex.unlock(ctx, "test")
 
 	wg.Wait()
 }
@@ -73,23 +73,23 @@ func Test_sharedMutex_expires(t *testing.T) {
 	defer mutexCleanup(t)
 	ctx := t.Context()
 
-	err := sharedDS.sharedMutex.lock(ctx, "test", 2*time.Second, newLockOptAcquireTimeout(10*time.Millisecond))
+	err := sharedRethinkDS.sharedMutex.lock(ctx, "test", 2*time.Second, newLockOptAcquireTimeout(10*time.Millisecond))
 	require.NoError(t, err)
 
-	err = sharedDS.sharedMutex.lock(ctx, "test", 2*time.Second, newLockOptAcquireTimeout(10*time.Millisecond))
+	err = sharedRethinkDS.sharedMutex.lock(ctx, "test", 2*time.Second, newLockOptAcquireTimeout(10*time.Millisecond))
 	require.Error(t, err)
 	require.ErrorContains(t, err, "unable to acquire mutex")
 
 	done := make(chan bool)
 	go func() {
-		err = sharedDS.sharedMutex.lock(ctx, "test", 2*time.Second, newLockOptAcquireTimeout(2*sharedDS.sharedMutex.checkinterval))
+		err = sharedRethinkDS.sharedMutex.lock(ctx, "test", 2*time.Second, newLockOptAcquireTimeout(2*sharedRethinkDS.sharedMutex.checkinterval))
 		if err != nil {
 			t.Errorf("mutex was not acquired: %s", err)
 		}
 		done <- true
 	}()
 
-	timeoutCtx, cancel := context.WithTimeout(context.Background(), 2*sharedDS.sharedMutex.checkinterval)
+	timeoutCtx, cancel := context.WithTimeout(context.Background(), 2*sharedRethinkDS.sharedMutex.checkinterval)
 	defer cancel()
 
 	select {
@@ -103,7 +103,7 @@ func Test_sharedMutex_stop(t *testing.T) {
 	defer mutexCleanup(t)
 	ctx, cancel := context.WithCancel(t.Context())
 
-	mutex, err := newSharedMutex(context.Background(), slog.Default(), sharedDS.dbsession)
+	mutex, err := newSharedMutex(context.Background(), slog.Default(), sharedRethinkDS.dbsession)
 	require.NoError(t, err)
 
 	done := make(chan bool)
@@ -126,6 +126,6 @@ func Test_sharedMutex_stop(t *testing.T) {
 }
 
 func mutexCleanup(t *testing.T) {
-	_, err := r.Table("sharedmutex").Delete().RunWrite(sharedDS.dbsession)
+	_, err := r.Table("sharedmutex").Delete().RunWrite(sharedRethinkDS.dbsession)
 	require.NoError(t, err)
 }
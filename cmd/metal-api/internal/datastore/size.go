@@ -2,6 +2,7 @@ package datastore
 
 import (
 	"errors"
+	"log/slog"
 
 	"github.com/metal-stack/metal-api/cmd/metal-api/internal/metal"
 	r "gopkg.in/rethinkdb/rethinkdb-go.v6"
@@ -100,7 +101,11 @@ func (rs *RethinkStore) UpdateSize(oldSize *metal.Size, newSize *metal.Size) err
 
 // FromHardware tries to find a size which matches the given hardware specs.
 func (rs *RethinkStore) FromHardware(hw metal.MachineHardware) (*metal.Size, error) {
-	sz, err := rs.ListSizes()
+	return fromHardware(rs.log, rs, hw)
+}
+
+func fromHardware(log *slog.Logger, ds SizeStore, hw metal.MachineHardware) (*metal.Size, error) {
+	sz, err := ds.ListSizes()
 	if err != nil {
 		return nil, err
 	}
@@ -111,7 +116,7 @@ func (rs *RethinkStore) FromHardware(hw metal.MachineHardware) (*metal.Size, err
 	var sizes metal.Sizes
 	for _, s := range sz {
 		if len(s.Constraints) < 1 {
-			rs.log.Error("missing constraints", "size", s)
+			log.Error("missing constraints", "size", s)
 			continue
 		}
 		sizes = append(sizes, s)
@@ -12,7 +12,7 @@ import (
 type sizeTestable struct{}
 
 func (_ *sizeTestable) wipe() error {
-	_, err := sharedDS.sizeTable().Delete().RunWrite(sharedDS.session)
+	err := wipeTable(sharedDS, "size")
 	return err
 }
 
@@ -63,208 +63,220 @@ func (_ *sizeTestable) defaultBody(s *metal.Size) *metal.Size {
 	return s
 }
 
-func TestRethinkStore_FindSize(t *testing.T) {
-	tt := &sizeTestable{}
-	defer func() {
-		require.NoError(t, tt.wipe())
-	}()
+func TestStore_FindSize(t *testing.T) {
+	forEachStore(t, func(t *testing.T) {
+		tt := &sizeTestable{}
+		defer func() {
+			require.NoError(t, tt.wipe())
+		}()
 
-	tests := []findTest[*metal.Size, *SizeSearchQuery]{
-		{
-			name: "find",
-			id:   "2",
+		tests := []findTest[*metal.Size, *SizeSearchQuery]{
+			{
+				name: "find",
+				id:   "2",
 
-			mock: []*metal.Size{
-				{Base: metal.Base{ID: "1"}},
-				{Base: metal.Base{ID: "2"}},
-				{Base: metal.Base{ID: "3"}},
+				mock: []*metal.Size{
+					{Base: metal.Base{ID: "1"}},
+					{Base: metal.Base{ID: "2"}},
+					{Base: metal.Base{ID: "3"}},
+				},
+				want:    tt.defaultBody(&metal.Size{Base: metal.Base{ID: "2"}}),
+				wantErr: nil,
 			},
-			want:    tt.defaultBody(&metal.Size{Base: metal.Base{ID: "2"}}),
-			wantErr: nil,
-		},
-		{
-			name:    "not found",
-			id:      "4",
-			want:    nil,
-			wantErr: metal.NotFound(`no size with id "4" found`),
-		},
-	}
-	for i := range tests {
-		tests[i].run(t, tt)
-	}
+			{
+				name:    "not found",
+				id:      "4",
+				want:    nil,
+				wantErr: metal.NotFound(`no size with id "4" found`),
+			},
+		}
+		for i := range tests {
+			tests[i].run(t, tt)
+		}
+	})
 }
 
-func TestRethinkStore_SearchSizes(t *testing.T) {
-	tt := &sizeTestable{}
-	defer func() {
-		require.NoError(t, tt.wipe())
-	}()
+func TestStore_SearchSizes(t *testing.T) {
+	forEachStore(t, func(t *testing.T) {
+		tt := &sizeTestable{}
+		defer func() {
+			require.NoError(t, tt.wipe())
+		}()
 
-	tests := []searchTest[*metal.Size, *SizeSearchQuery]{
-		{
-			name: "empty result",
-			q: &SizeSearchQuery{
-				ID: new("2"),
-			},
-			mock: []*metal.Size{
-				{Base: metal.Base{ID: "1"}},
-			},
-			want:    nil,
-			wantErr: nil,
-		},
-		{
-			name: "search by id",
-			q: &SizeSearchQuery{
-				ID: new("2"),
-			},
-			mock: []*metal.Size{
-				{Base: metal.Base{ID: "1"}},
-				{Base: metal.Base{ID: "2"}},
-				{Base: metal.Base{ID: "3"}},
+		tests := []searchTest[*metal.Size, *SizeSearchQuery]{
+			{
+				name: "empty result",
+				q: &SizeSearchQuery{
+					ID: new("2"),
+				},
+				mock: []*metal.Size{
+					{Base: metal.Base{ID: "1"}},
+				},
+				want:    nil,
+				wantErr: nil,
 			},
-			want: []*metal.Size{
-				tt.defaultBody(&metal.Size{Base: metal.Base{ID: "2"}}),
+			{
+				name: "search by id",
+				q: &SizeSearchQuery{
+					ID: new("2"),
+				},
+				mock: []*metal.Size{
+					{Base: metal.Base{ID: "1"}},
+					{Base: metal.Base{ID: "2"}},
+					{Base: metal.Base{ID: "3"}},
+				},
+				want: []*metal.Size{
+					tt.defaultBody(&metal.Size{Base: metal.Base{ID: "2"}}),
+				},
+				wantErr: nil,
 			},
-			wantErr: nil,
-		},
-		{
-			name: "search by name",
-			q: &SizeSearchQuery{
-				Name: new("b"),
+			{
+				name: "search by name",
+				q: &SizeSearchQuery{
+					Name: new("b"),
+				},
+				mock: []*metal.Size{
+					{Base: metal.Base{ID: "1", Name: "a"}},
+					{Base: metal.Base{ID: "2", Name: "b"}},
+					{Base: metal.Base{ID: "3", Name: "c"}},
+				},
+				want: []*metal.Size{
+					tt.defaultBody(&metal.Size{Base: metal.Base{ID: "2", Name: "b"}}),
+				},
+				wantErr: nil,
 			},
-			mock: []*metal.Size{
-				{Base: metal.Base{ID: "1", Name: "a"}},
-				{Base: metal.Base{ID: "2", Name: "b"}},
-				{Base: metal.Base{ID: "3", Name: "c"}},
-			},
-			want: []*metal.Size{
-				tt.defaultBody(&metal.Size{Base: metal.Base{ID: "2", Name: "b"}}),
-			},
-			wantErr: nil,
-		},
-	}
+		}
 
-	for i := range tests {
-		tests[i].run(t, tt)
-	}
+		for i := range tests {
+			tests[i].run(t, tt)
+		}
+	})
 }
 
-func TestRethinkStore_ListSizes(t *testing.T) {
-	tt := &sizeTestable{}
-	defer func() {
-		require.NoError(t, tt.wipe())
-	}()
+func TestStore_ListSizes(t *testing.T) {
+	forEachStore(t, func(t *testing.T) {
+		tt := &sizeTestable{}
+		defer func() {
+			require.NoError(t, tt.wipe())
+		}()
 
-	tests := []listTest[*metal.Size, *SizeSearchQuery]{
-		{
-			name: "list",
-			mock: []*metal.Size{
-				{Base: metal.Base{ID: "1"}},
-				{Base: metal.Base{ID: "2"}},
-				{Base: metal.Base{ID: "3"}},
-			},
-			want: []*metal.Size{
-				tt.defaultBody(&metal.Size{Base: metal.Base{ID: "1"}}),
-				tt.defaultBody(&metal.Size{Base: metal.Base{ID: "2"}}),
-				tt.defaultBody(&metal.Size{Base: metal.Base{ID: "3"}}),
+		tests := []listTest[*metal.Size, *SizeSearchQuery]{
+			{
+				name: "list",
+				mock: []*metal.Size{
+					{Base: metal.Base{ID: "1"}},
+					{Base: metal.Base{ID: "2"}},
+					{Base: metal.Base{ID: "3"}},
+				},
+				want: []*metal.Size{
+					tt.defaultBody(&metal.Size{Base: metal.Base{ID: "1"}}),
+					tt.defaultBody(&metal.Size{Base: metal.Base{ID: "2"}}),
+					tt.defaultBody(&metal.Size{Base: metal.Base{ID: "3"}}),
+				},
 			},
-		},
-	}
-	for i := range tests {
-		tests[i].run(t, tt)
-	}
+		}
+		for i := range tests {
+			tests[i].run(t, tt)
+		}
+	})
 }
 
-func TestRethinkStore_CreateSize(t *testing.T) {
-	tt := &sizeTestable{}
-	defer func() {
-		require.NoError(t, tt.wipe())
-	}()
+func TestStore_CreateSize(t *testing.T) {
+	forEachStore(t, func(t *testing.T) {
+		tt := &sizeTestable{}
+		defer func() {
+			require.NoError(t, tt.wipe())
+		}()
 
-	tests := []createTest[*metal.Size, *SizeSearchQuery]{
-		{
-			name:    "create",
-			want:    tt.defaultBody(&metal.Size{Base: metal.Base{ID: "1"}}),
-			wantErr: nil,
-		},
-		{
-			name: "already exists",
-			mock: []*metal.Size{
-				{Base: metal.Base{ID: "1"}},
+		tests := []createTest[*metal.Size, *SizeSearchQuery]{
+			{
+				name:    "create",
+				want:    tt.defaultBody(&metal.Size{Base: metal.Base{ID: "1"}}),
+				wantErr: nil,
 			},
-			want:    tt.defaultBody(&metal.Size{Base: metal.Base{ID: "1"}}),
-			wantErr: metal.Conflict(`cannot create size in database, entity already exists: 1`),
-		},
-	}
-	for i := range tests {
-		tests[i].run(t, tt)
-	}
+			{
+				name: "already exists",
+				mock: []*metal.Size{
+					{Base: metal.Base{ID: "1"}},
+				},
+				want:    tt.defaultBody(&metal.Size{Base: metal.Base{ID: "1"}}),
+				wantErr: metal.Conflict(`cannot create size in database, entity already exists: 1`),
+			},
+		}
+		for i := range tests {
+			tests[i].run(t, tt)
+		}
+	})
 }
 
-func TestRethinkStore_DeleteSize(t *testing.T) {
-	tt := &sizeTestable{}
-	defer func() {
-		require.NoError(t, tt.wipe())
-	}()
+func TestStore_DeleteSize(t *testing.T) {
+	forEachStore(t, func(t *testing.T) {
+		tt := &sizeTestable{}
+		defer func() {
+			require.NoError(t, tt.wipe())
+		}()
 
-	tests := []deleteTest[*metal.Size, *SizeSearchQuery]{
-		{
-			name: "delete",
-			id:   "2",
-			mock: []*metal.Size{
-				{Base: metal.Base{ID: "1"}},
-				{Base: metal.Base{ID: "2"}},
-				{Base: metal.Base{ID: "3"}},
-			},
-			want: []*metal.Size{
-				tt.defaultBody(&metal.Size{Base: metal.Base{ID: "1"}}),
-				tt.defaultBody(&metal.Size{Base: metal.Base{ID: "3"}}),
-			},
-		},
-		{
-			name: "not exists results in noop",
-			id:   "abc",
-			mock: []*metal.Size{
-				{Base: metal.Base{ID: "1"}},
-				{Base: metal.Base{ID: "2"}},
-				{Base: metal.Base{ID: "3"}},
+		tests := []deleteTest[*metal.Size, *SizeSearchQuery]{
+			{
+				name: "delete",
+				id:   "2",
+				mock: []*metal.Size{
+					{Base: metal.Base{ID: "1"}},
+					{Base: metal.Base{ID: "2"}},
+					{Base: metal.Base{ID: "3"}},
+				},
+				want: []*metal.Size{
+					tt.defaultBody(&metal.Size{Base: metal.Base{ID: "1"}}),
+					tt.defaultBody(&metal.Size{Base: metal.Base{ID: "3"}}),
+				},
 			},
-			want: []*metal.Size{
-				tt.defaultBody(&metal.Size{Base: metal.Base{ID: "1"}}),
-				tt.defaultBody(&metal.Size{Base: metal.Base{ID: "2"}}),
-				tt.defaultBody(&metal.Size{Base: metal.Base{ID: "3"}}),
+			{
+				name: "not exists results in noop",
+				id:   "abc",
+				mock: []*metal.Size{
+					{Base: metal.Base{ID: "1"}},
+					{Base: metal.Base{ID: "2"}},
+					{Base: metal.Base{ID: "3"}},
+				},
+				want: []*metal.Size{
+					tt.defaultBody(&metal.Size{Base: metal.Base{ID: "1"}}),
+					tt.defaultBody(&metal.Size{Base: metal.Base{ID: "2"}}),
+					tt.defaultBody(&metal.Size{Base: metal.Base{ID: "3"}}),
+				},
 			},
-		},
-	}
-	for i := range tests {
-		tests[i].run(t, tt)
-	}
+		}
+		for i := range tests {
+			tests[i].run(t, tt)
+		}
+	})
 }
 
-func TestRethinkStore_UpdateSize(t *testing.T) {
-	tt := &sizeTestable{}
-	defer func() {
-		require.NoError(t, tt.wipe())
-	}()
+func TestStore_UpdateSize(t *testing.T) {
+	forEachStore(t, func(t *testing.T) {
+		tt := &sizeTestable{}
+		defer func() {
+			require.NoError(t, tt.wipe())
+		}()
 
-	tests := []updateTest[*metal.Size, *SizeSearchQuery]{
-		{
-			name: "update",
-			mock: []*metal.Size{
-				{Base: metal.Base{ID: "1"}},
-				{Base: metal.Base{ID: "2"}},
-				{Base: metal.Base{ID: "3"}},
-			},
-			mutateFn: func(s *metal.Size) {
-				s.Labels = map[string]string{"a": "b"}
+		tests := []updateTest[*metal.Size, *SizeSearchQuery]{
+			{
+				name: "update",
+				mock: []*metal.Size{
+					{Base: metal.Base{ID: "1"}},
+					{Base: metal.Base{ID: "2"}},
+					{Base: metal.Base{ID: "3"}},
+				},
+				mutateFn: func(s *metal.Size) {
+					s.Labels = map[string]string{"a": "b"}
+				},
+				want: tt.defaultBody(&metal.Size{
+					Base:   metal.Base{ID: "1"},
+					Labels: map[string]string{"a": "b"},
+				}),
 			},
-			want: tt.defaultBody(&metal.Size{
-				Base:   metal.Base{ID: "1"},
-				Labels: map[string]string{"a": "b"},
-			}),
-		},
-	}
-	for i := range tests {
-		tests[i].run(t, tt)
-	}
+		}
+		for i := range tests {
+			tests[i].run(t, tt)
+		}
+	})
 }
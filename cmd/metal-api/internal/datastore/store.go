@@ -0,0 +1,317 @@
+package datastore
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/metal-stack/metal-api/cmd/metal-api/internal/metal"
+	"github.com/metal-stack/metal-lib/pkg/healthstatus"
+)
+
+// Store is the interface of the database access layer, it is implemented by the
+// RethinkStore and the MemoryStore.
+type Store interface {
+	healthstatus.HealthCheck
+
+	// Connect connects to the underlying database.
+	Connect() error
+	// Initialize ensures that tables, pools and permissions are properly initialized.
+	Initialize() error
+	// Demote switches to a runtime user with restricted permissions.
+	Demote() error
+	// Migrate migrates the database to the given target version, or the latest one if nil.
+	Migrate(targetVersion *int, dry bool) error
+	// Close closes the connection to the underlying database.
+	Close() error
+
+	MachineStore
+	SwitchStore
+	NetworkStore
+	IPStore
+	SizeStore
+	ImageStore
+	PartitionStore
+	FilesystemLayoutStore
+	SizeImageConstraintStore
+	SizeReservationStore
+	MaintenanceWindowStore
+	LocationStore
+	OutboxStore
+	MachineCommandExecutionStore
+	PowerSampleStore
+	PendingAllocationStore
+	MachineRemediationStore
+	FirewallRuleRevisionStore
+	HardwareSnapshotStore
+	ProvisioningEventStore
+	ProvisioningEventLogStore
+	EntityChangeLogStore
+	IntegerPoolStore
+	ChangeWatcher
+}
+
+// MachineStore contains the datastore operations for machines.
+type MachineStore interface {
+	FindMachineByID(id string) (*metal.Machine, error)
+	FindMachine(q *MachineSearchQuery, ms *metal.Machine) error
+	SearchMachines(q *MachineSearchQuery, ms *metal.Machines) error
+	ListMachines() (metal.Machines, error)
+	CreateMachine(m *metal.Machine) error
+	DeleteMachine(m *metal.Machine) error
+	UpdateMachine(oldMachine *metal.Machine, newMachine *metal.Machine) error
+	FindWaitingMachine(ctx context.Context, projectid, partitionid string, size metal.Size, placement metal.Placement, role metal.Role) (*metal.Machine, error)
+}
+
+// SwitchStore contains the datastore operations for switches and their status.
+type SwitchStore interface {
+	FindSwitch(id string) (*metal.Switch, error)
+	ListSwitches() (metal.Switches, error)
+	CreateSwitch(s *metal.Switch) error
+	DeleteSwitch(s *metal.Switch) error
+	UpdateSwitch(oldSwitch *metal.Switch, newSwitch *metal.Switch) error
+	SearchSwitches(q *SwitchSearchQuery, ss *metal.Switches) error
+	SearchSwitchesConnectedToMachine(m *metal.Machine) (metal.Switches, error)
+	SearchSwitchesConnectedToMachineInRack(m *metal.Machine, rack *string) (metal.Switches, error)
+	SetVrfAtSwitches(m *metal.Machine, vrf string) (metal.Switches, error)
+	ConnectMachineWithSwitches(m *metal.Machine) error
+	GetSwitchStatus(id string) (*metal.SwitchStatus, error)
+	SetSwitchStatus(state *metal.SwitchStatus) error
+	DeleteSwitchStatus(status *metal.SwitchStatus) error
+}
+
+// NetworkStore contains the datastore operations for networks.
+type NetworkStore interface {
+	FindNetworkByID(id string) (*metal.Network, error)
+	FindNetwork(q *NetworkSearchQuery, n *metal.Network) error
+	SearchNetworks(q *NetworkSearchQuery, ns *metal.Networks) error
+	ListNetworks() (metal.Networks, error)
+	CreateNetwork(nw *metal.Network) error
+	DeleteNetwork(nw *metal.Network) error
+	UpdateNetwork(oldNetwork *metal.Network, newNetwork *metal.Network) error
+}
+
+// IPStore contains the datastore operations for ips.
+type IPStore interface {
+	FindIPByID(id string) (*metal.IP, error)
+	SearchIPs(q *IPSearchQuery, ips *metal.IPs) error
+	ListIPs() (metal.IPs, error)
+	CreateIP(ip *metal.IP) error
+	DeleteIP(ip *metal.IP) error
+	UpdateIP(oldIP *metal.IP, newIP *metal.IP) error
+}
+
+// SizeStore contains the datastore operations for sizes.
+type SizeStore interface {
+	FindSize(id string) (*metal.Size, error)
+	SearchSizes(q *SizeSearchQuery, sizes *metal.Sizes) error
+	ListSizes() (metal.Sizes, error)
+	CreateSize(size *metal.Size) error
+	DeleteSize(size *metal.Size) error
+	UpdateSize(oldSize *metal.Size, newSize *metal.Size) error
+	FromHardware(hw metal.MachineHardware) (*metal.Size, error)
+}
+
+// ImageStore contains the datastore operations for images.
+type ImageStore interface {
+	GetImage(id string) (*metal.Image, error)
+	FindImages(id string) ([]metal.Image, error)
+	FindImage(id string) (*metal.Image, error)
+	ListImages() (metal.Images, error)
+	CreateImage(i *metal.Image) error
+	DeleteImage(i *metal.Image) error
+	UpdateImage(oldImage *metal.Image, newImage *metal.Image) error
+	SearchImages(q *ImageSearchQuery, images *metal.Images) error
+	DeleteOrphanImages(images metal.Images, machines metal.Machines) (metal.Images, error)
+}
+
+// PartitionStore contains the datastore operations for partitions.
+type PartitionStore interface {
+	FindPartition(id string) (*metal.Partition, error)
+	ListPartitions() (metal.Partitions, error)
+	CreatePartition(p *metal.Partition) error
+	DeletePartition(p *metal.Partition) error
+	UpdatePartition(oldPartition *metal.Partition, newPartition *metal.Partition) error
+}
+
+// FilesystemLayoutStore contains the datastore operations for filesystem layouts.
+type FilesystemLayoutStore interface {
+	FindFilesystemLayout(id string) (*metal.FilesystemLayout, error)
+	ListFilesystemLayouts() (metal.FilesystemLayouts, error)
+	CreateFilesystemLayout(fl *metal.FilesystemLayout) error
+	DeleteFilesystemLayout(fl *metal.FilesystemLayout) error
+	UpdateFilesystemLayout(oldFilesystemLayout *metal.FilesystemLayout, newFilesystemLayout *metal.FilesystemLayout) error
+}
+
+// SizeImageConstraintStore contains the datastore operations for size image constraints.
+type SizeImageConstraintStore interface {
+	FindSizeImageConstraint(sizeID string) (*metal.SizeImageConstraint, error)
+	ListSizeImageConstraints() (metal.SizeImageConstraints, error)
+	CreateSizeImageConstraint(ic *metal.SizeImageConstraint) error
+	DeleteSizeImageConstraint(ic *metal.SizeImageConstraint) error
+	UpdateSizeImageConstraint(oldSizeImageConstraint *metal.SizeImageConstraint, newSizeImageConstraint *metal.SizeImageConstraint) error
+}
+
+// SizeReservationStore contains the datastore operations for size reservations.
+type SizeReservationStore interface {
+	FindSizeReservation(id string) (*metal.SizeReservation, error)
+	SearchSizeReservations(q *SizeReservationSearchQuery, rvs *metal.SizeReservations) error
+	ListSizeReservations() (metal.SizeReservations, error)
+	CreateSizeReservation(rv *metal.SizeReservation) error
+	DeleteSizeReservation(rv *metal.SizeReservation) error
+	UpdateSizeReservation(oldRv *metal.SizeReservation, newRv *metal.SizeReservation) error
+}
+
+// MaintenanceWindowStore contains the datastore operations for maintenance windows.
+type MaintenanceWindowStore interface {
+	FindMaintenanceWindow(id string) (*metal.MaintenanceWindow, error)
+	SearchMaintenanceWindows(q *MaintenanceWindowSearchQuery, ws *metal.MaintenanceWindows) error
+	ListMaintenanceWindows() (metal.MaintenanceWindows, error)
+	CreateMaintenanceWindow(w *metal.MaintenanceWindow) error
+	DeleteMaintenanceWindow(w *metal.MaintenanceWindow) error
+	UpdateMaintenanceWindow(oldWindow *metal.MaintenanceWindow, newWindow *metal.MaintenanceWindow) error
+}
+
+// LocationStore contains the datastore operations for locations.
+type LocationStore interface {
+	FindLocation(id string) (*metal.Location, error)
+	SearchLocations(q *LocationSearchQuery, ls *metal.Locations) error
+	ListLocations() (metal.Locations, error)
+	CreateLocation(l *metal.Location) error
+	DeleteLocation(l *metal.Location) error
+	UpdateLocation(oldLocation *metal.Location, newLocation *metal.Location) error
+}
+
+// OutboxStore contains the datastore operations for events which are published to the event bus through the outbox.
+type OutboxStore interface {
+	FindOutboxEvent(id string) (*metal.OutboxEvent, error)
+	SearchOutboxEvents(q *OutboxEventSearchQuery, es *metal.OutboxEvents) error
+	CreateOutboxEvent(e *metal.OutboxEvent) error
+	DeleteOutboxEvent(e *metal.OutboxEvent) error
+	UpdateOutboxEvent(oldEvent *metal.OutboxEvent, newEvent *metal.OutboxEvent) error
+}
+
+// MachineCommandExecutionStore contains the datastore operations for tracking the execution of machine commands.
+type MachineCommandExecutionStore interface {
+	FindMachineCommandExecution(id string) (*metal.MachineCommandExecution, error)
+	SearchMachineCommandExecutions(q *MachineCommandExecutionSearchQuery, es *metal.MachineCommandExecutions) error
+	CreateMachineCommandExecution(e *metal.MachineCommandExecution) error
+	DeleteMachineCommandExecution(e *metal.MachineCommandExecution) error
+	UpdateMachineCommandExecution(oldExecution *metal.MachineCommandExecution, newExecution *metal.MachineCommandExecution) error
+}
+
+// PowerSampleStore contains the datastore operations for the power consumption history of machines.
+type PowerSampleStore interface {
+	FindPowerSample(id string) (*metal.PowerSample, error)
+	SearchPowerSamples(q *PowerSampleSearchQuery, ss *metal.PowerSamples) error
+	CreatePowerSample(s *metal.PowerSample) error
+	UpdatePowerSample(oldSample *metal.PowerSample, newSample *metal.PowerSample) error
+	DeletePowerSample(s *metal.PowerSample) error
+	DeletePowerSamplesBefore(t time.Time) (int, error)
+}
+
+// PendingAllocationStore contains the datastore operations for queued machine allocations.
+type PendingAllocationStore interface {
+	FindPendingAllocation(id string) (*metal.PendingAllocation, error)
+	SearchPendingAllocations(q *PendingAllocationSearchQuery, as *metal.PendingAllocations) error
+	ListPendingAllocations() (metal.PendingAllocations, error)
+	CreatePendingAllocation(a *metal.PendingAllocation) error
+	DeletePendingAllocation(a *metal.PendingAllocation) error
+	UpdatePendingAllocation(oldAllocation *metal.PendingAllocation, newAllocation *metal.PendingAllocation) error
+}
+
+// MachineRemediationStore contains the datastore operations for automated machine remediations.
+type MachineRemediationStore interface {
+	FindMachineRemediation(id string) (*metal.MachineRemediation, error)
+	ListMachineRemediations() (metal.MachineRemediations, error)
+	CreateMachineRemediation(mr *metal.MachineRemediation) error
+	DeleteMachineRemediation(mr *metal.MachineRemediation) error
+	UpdateMachineRemediation(oldRemediation *metal.MachineRemediation, newRemediation *metal.MachineRemediation) error
+}
+
+// HardwareSnapshotStore contains the datastore operations for the hardware history of machines.
+type HardwareSnapshotStore interface {
+	FindHardwareSnapshot(machineID string, revision int) (*metal.HardwareSnapshot, error)
+	SearchHardwareSnapshots(q *HardwareSnapshotSearchQuery, snapshots *metal.HardwareSnapshots) error
+	ListLatestHardwareSnapshots() (metal.HardwareSnapshots, error)
+	CreateHardwareSnapshot(s *metal.HardwareSnapshot) error
+	UpdateHardwareSnapshot(oldSnapshot *metal.HardwareSnapshot, newSnapshot *metal.HardwareSnapshot) error
+	DeleteHardwareSnapshot(s *metal.HardwareSnapshot) error
+}
+
+// FirewallRuleRevisionStore contains the datastore operations for firewall rule revisions.
+type FirewallRuleRevisionStore interface {
+	FindFirewallRuleRevision(allocationUUID string, revision int) (*metal.FirewallRuleRevision, error)
+	SearchFirewallRuleRevisions(q *FirewallRuleRevisionSearchQuery, revs *metal.FirewallRuleRevisions) error
+	CreateFirewallRuleRevision(rev *metal.FirewallRuleRevision) error
+	DeleteFirewallRuleRevision(rev *metal.FirewallRuleRevision) error
+}
+
+// ProvisioningEventStore contains the datastore operations for provisioning event containers.
+type ProvisioningEventStore interface {
+	ListProvisioningEventContainers() (metal.ProvisioningEventContainers, error)
+	FindProvisioningEventContainer(id string) (*metal.ProvisioningEventContainer, error)
+	FindProvisioningEventContainers(ids []string) (metal.ProvisioningEventContainers, error)
+	UpdateProvisioningEventContainer(old *metal.ProvisioningEventContainer, new *metal.ProvisioningEventContainer) error
+	CreateProvisioningEventContainer(ec *metal.ProvisioningEventContainer) error
+	UpsertProvisioningEventContainer(ec *metal.ProvisioningEventContainer) error
+	ProvisioningEventForMachine(ctx context.Context, log *slog.Logger, event *metal.ProvisioningEvent, machineID string) (*metal.ProvisioningEventContainer, error)
+}
+
+// ProvisioningEventLogStore contains the datastore operations for the append-only provisioning event log.
+type ProvisioningEventLogStore interface {
+	SearchProvisioningEventLog(q *ProvisioningEventLogSearchQuery) (metal.ProvisioningEventLogEntries, *string, error)
+	CreateProvisioningEventLogEntry(e *metal.ProvisioningEventLogEntry) error
+	DeleteProvisioningEventLogBefore(t time.Time) (int, error)
+}
+
+// EntityChangeLogStore contains the datastore operations for the entity change log shared by all metal-api instances.
+type EntityChangeLogStore interface {
+	FindEntityChangeLogWriter() (*metal.EntityChangeLogWriter, error)
+	CreateEntityChangeLogWriter(w *metal.EntityChangeLogWriter) error
+	UpdateEntityChangeLogWriter(oldWriter *metal.EntityChangeLogWriter, newWriter *metal.EntityChangeLogWriter) error
+	ListEntityChanges(epoch string, after uint64, limit int) (metal.EntityChangeLogEntries, error)
+	FindLatestEntityChange(epoch string) (*metal.EntityChangeLogEntry, error)
+	CreateEntityChange(e *metal.EntityChangeLogEntry) error
+	DeleteEntityChangesBefore(epoch string, sequence uint64) (int, error)
+}
+
+// IntegerPoolStore provides access to the integer pools.
+type IntegerPoolStore interface {
+	GetVRFPool() IntegerPooler
+	GetASNPool() IntegerPooler
+}
+
+// ChangeWatcher streams changes of entities.
+type ChangeWatcher interface {
+	// Watch streams the changes of entities in the given tables until the context is canceled.
+	// Only the tables machine, network, ip, switch and entitychange can be watched.
+	Watch(ctx context.Context, tables ...string) (<-chan EntityChange, error)
+}
+
+// IntegerPooler manages unique integers.
+type IntegerPooler interface {
+	// String returns the name of the pool.
+	String() string
+	// AcquireRandomUniqueInteger returns a random unique integer from the pool.
+	AcquireRandomUniqueInteger() (uint, error)
+	// AcquireUniqueInteger returns a unique integer from the pool.
+	AcquireUniqueInteger(value uint) (uint, error)
+	// ReleaseUniqueInteger returns a unique integer to the pool.
+	ReleaseUniqueInteger(value uint) error
+	// Usage returns the amount of free and used integers of the pool.
+	Usage() (*IntegerPoolUsage, error)
+}
+
+// IntegerPoolUsage contains the amount of free and used integers of a pool.
+type IntegerPoolUsage struct {
+	Free uint
+	Used uint
+}
+
+var (
+	_ Store         = &RethinkStore{}
+	_ Store         = &MemoryStore{}
+	_ IntegerPooler = &IntegerPool{}
+	_ IntegerPooler = &memoryIntegerPool{}
+)
@@ -104,9 +104,13 @@ func (rs *RethinkStore) SearchSwitchesConnectedToMachine(m *metal.Machine) (meta
 
 // SearchSwitchesConnectedToMachineInRack search for machine connections in a specific rack or in all racks if rack == nil.
 func (rs *RethinkStore) SearchSwitchesConnectedToMachineInRack(m *metal.Machine, rack *string) (metal.Switches, error) {
+	return searchSwitchesConnectedToMachineInRack(rs, m, rack)
+}
+
+func searchSwitchesConnectedToMachineInRack(ds SwitchStore, m *metal.Machine, rack *string) (metal.Switches, error) {
 	switches := metal.Switches{}
 
-	err := rs.SearchSwitches(&SwitchSearchQuery{RackID: rack}, &switches)
+	err := ds.SearchSwitches(&SwitchSearchQuery{RackID: rack}, &switches)
 	if err != nil {
 		return nil, err
 	}
@@ -124,7 +128,11 @@ func (rs *RethinkStore) SearchSwitchesConnectedToMachineInRack(m *metal.Machine,
 // SetVrfAtSwitches finds the switches connected to the given machine and puts the switch ports into the given vrf.
 // Returns the updated switches.
 func (rs *RethinkStore) SetVrfAtSwitches(m *metal.Machine, vrf string) (metal.Switches, error) {
-	switches, err := rs.SearchSwitchesConnectedToMachine(m)
+	return setVrfAtSwitches(rs, m, vrf)
+}
+
+func setVrfAtSwitches(ds SwitchStore, m *metal.Machine, vrf string) (metal.Switches, error) {
+	switches, err := ds.SearchSwitchesConnectedToMachine(m)
 	if err != nil {
 		return nil, err
 	}
@@ -133,7 +141,7 @@ func (rs *RethinkStore) SetVrfAtSwitches(m *metal.Machine, vrf string) (metal.Sw
 		sw := switches[i]
 		oldSwitch := sw
 		sw.SetVrfOfMachine(m, vrf)
-		err := rs.UpdateSwitch(&oldSwitch, &sw)
+		err := ds.UpdateSwitch(&oldSwitch, &sw)
 		if err != nil {
 			return nil, err
 		}
@@ -142,12 +150,17 @@ func (rs *RethinkStore) SetVrfAtSwitches(m *metal.Machine, vrf string) (metal.Sw
 	return newSwitches, nil
 }
 
+// ConnectMachineWithSwitches connects the machine with the two leaf switches it is cabled to and detects its rack.
 func (rs *RethinkStore) ConnectMachineWithSwitches(m *metal.Machine) error {
+	return connectMachineWithSwitches(rs, m)
+}
+
+func connectMachineWithSwitches(ds SwitchStore, m *metal.Machine) error {
 	if m.PartitionID == "" {
 		return fmt.Errorf("partitionID is empty in machine:%s", m.ID)
 	}
 	var switches metal.Switches
-	err := rs.SearchSwitches(&SwitchSearchQuery{PartitionID: &m.PartitionID}, &switches)
+	err := ds.SearchSwitches(&SwitchSearchQuery{PartitionID: &m.PartitionID}, &switches)
 	if err != nil {
 		return err
 	}
@@ -213,7 +226,7 @@ func (rs *RethinkStore) ConnectMachineWithSwitches(m *metal.Machine) error {
 	}
 
 	for i := range oldSwitches {
-		err = rs.UpdateSwitch(&oldSwitches[i], &newSwitches[i])
+		err = ds.UpdateSwitch(&oldSwitches[i], &newSwitches[i])
 		if err != nil {
 			return err
 		}
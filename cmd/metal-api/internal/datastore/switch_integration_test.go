@@ -12,7 +12,7 @@ import (
 type switchTestable struct{}
 
 func (_ *switchTestable) wipe() error {
-	_, err := sharedDS.switchTable().Delete().RunWrite(sharedDS.session)
+	err := wipeTable(sharedDS, "switch")
 	return err
 }
 
@@ -61,259 +61,271 @@ func (_ *switchTestable) defaultBody(m *metal.Switch) *metal.Switch {
 	return m
 }
 
-func TestRethinkStore_FindSwitch(t *testing.T) {
-	tt := &switchTestable{}
-	defer func() {
-		require.NoError(t, tt.wipe())
-	}()
+func TestStore_FindSwitch(t *testing.T) {
+	forEachStore(t, func(t *testing.T) {
+		tt := &switchTestable{}
+		defer func() {
+			require.NoError(t, tt.wipe())
+		}()
 
-	tests := []findTest[*metal.Switch, *SwitchSearchQuery]{
-		{
-			name: "find",
-			id:   "2",
+		tests := []findTest[*metal.Switch, *SwitchSearchQuery]{
+			{
+				name: "find",
+				id:   "2",
 
-			mock: []*metal.Switch{
-				{Base: metal.Base{ID: "1"}},
-				{Base: metal.Base{ID: "2"}},
-				{Base: metal.Base{ID: "3"}},
+				mock: []*metal.Switch{
+					{Base: metal.Base{ID: "1"}},
+					{Base: metal.Base{ID: "2"}},
+					{Base: metal.Base{ID: "3"}},
+				},
+				want:    tt.defaultBody(&metal.Switch{Base: metal.Base{ID: "2"}}),
+				wantErr: nil,
 			},
-			want:    tt.defaultBody(&metal.Switch{Base: metal.Base{ID: "2"}}),
-			wantErr: nil,
-		},
-		{
-			name:    "not found",
-			id:      "4",
-			want:    nil,
-			wantErr: metal.NotFound(`no switch with id "4" found`),
-		},
-	}
-	for i := range tests {
-		tests[i].run(t, tt)
-	}
+			{
+				name:    "not found",
+				id:      "4",
+				want:    nil,
+				wantErr: metal.NotFound(`no switch with id "4" found`),
+			},
+		}
+		for i := range tests {
+			tests[i].run(t, tt)
+		}
+	})
 }
 
-func TestRethinkStore_SearchSwitches(t *testing.T) {
-	tt := &switchTestable{}
-	defer func() {
-		require.NoError(t, tt.wipe())
-	}()
+func TestStore_SearchSwitches(t *testing.T) {
+	forEachStore(t, func(t *testing.T) {
+		tt := &switchTestable{}
+		defer func() {
+			require.NoError(t, tt.wipe())
+		}()
 
-	tests := []searchTest[*metal.Switch, *SwitchSearchQuery]{
-		{
-			name: "empty result",
-			q: &SwitchSearchQuery{
-				ID: new("2"),
-			},
-			mock: []*metal.Switch{
-				{Base: metal.Base{ID: "1"}},
-			},
-			want:    nil,
-			wantErr: nil,
-		},
-		{
-			name: "search by id",
-			q: &SwitchSearchQuery{
-				ID: new("2"),
-			},
-			mock: []*metal.Switch{
-				{Base: metal.Base{ID: "1"}},
-				{Base: metal.Base{ID: "2"}},
-				{Base: metal.Base{ID: "3"}},
-			},
-			want: []*metal.Switch{
-				tt.defaultBody(&metal.Switch{Base: metal.Base{ID: "2"}}),
-			},
-			wantErr: nil,
-		},
-		{
-			name: "search by partition",
-			q: &SwitchSearchQuery{
-				PartitionID: new("b"),
+		tests := []searchTest[*metal.Switch, *SwitchSearchQuery]{
+			{
+				name: "empty result",
+				q: &SwitchSearchQuery{
+					ID: new("2"),
+				},
+				mock: []*metal.Switch{
+					{Base: metal.Base{ID: "1"}},
+				},
+				want:    nil,
+				wantErr: nil,
 			},
-			mock: []*metal.Switch{
-				{Base: metal.Base{ID: "1"}, PartitionID: "a"},
-				{Base: metal.Base{ID: "2"}, PartitionID: "b"},
-				{Base: metal.Base{ID: "3"}, PartitionID: "c"},
-				{Base: metal.Base{ID: "4"}, PartitionID: "b"},
+			{
+				name: "search by id",
+				q: &SwitchSearchQuery{
+					ID: new("2"),
+				},
+				mock: []*metal.Switch{
+					{Base: metal.Base{ID: "1"}},
+					{Base: metal.Base{ID: "2"}},
+					{Base: metal.Base{ID: "3"}},
+				},
+				want: []*metal.Switch{
+					tt.defaultBody(&metal.Switch{Base: metal.Base{ID: "2"}}),
+				},
+				wantErr: nil,
 			},
-			want: []*metal.Switch{
-				tt.defaultBody(&metal.Switch{Base: metal.Base{ID: "2"}, PartitionID: "b"}),
-				tt.defaultBody(&metal.Switch{Base: metal.Base{ID: "4"}, PartitionID: "b"}),
+			{
+				name: "search by partition",
+				q: &SwitchSearchQuery{
+					PartitionID: new("b"),
+				},
+				mock: []*metal.Switch{
+					{Base: metal.Base{ID: "1"}, PartitionID: "a"},
+					{Base: metal.Base{ID: "2"}, PartitionID: "b"},
+					{Base: metal.Base{ID: "3"}, PartitionID: "c"},
+					{Base: metal.Base{ID: "4"}, PartitionID: "b"},
+				},
+				want: []*metal.Switch{
+					tt.defaultBody(&metal.Switch{Base: metal.Base{ID: "2"}, PartitionID: "b"}),
+					tt.defaultBody(&metal.Switch{Base: metal.Base{ID: "4"}, PartitionID: "b"}),
+				},
+				wantErr: nil,
 			},
-			wantErr: nil,
-		},
-		{
-			name: "search by rack",
-			q: &SwitchSearchQuery{
-				RackID: new("b"),
+			{
+				name: "search by rack",
+				q: &SwitchSearchQuery{
+					RackID: new("b"),
+				},
+				mock: []*metal.Switch{
+					{Base: metal.Base{ID: "1"}, RackID: "a"},
+					{Base: metal.Base{ID: "2"}, RackID: "b"},
+					{Base: metal.Base{ID: "3"}, RackID: "c"},
+				},
+				want: []*metal.Switch{
+					tt.defaultBody(&metal.Switch{Base: metal.Base{ID: "2"}, RackID: "b"}),
+				},
+				wantErr: nil,
 			},
-			mock: []*metal.Switch{
-				{Base: metal.Base{ID: "1"}, RackID: "a"},
-				{Base: metal.Base{ID: "2"}, RackID: "b"},
-				{Base: metal.Base{ID: "3"}, RackID: "c"},
+			{
+				name: "search by os vendor",
+				q: &SwitchSearchQuery{
+					OSVendor: new("sonic"),
+				},
+				mock: []*metal.Switch{
+					{Base: metal.Base{ID: "1"}, OS: &metal.SwitchOS{Vendor: "cumulus"}},
+					{Base: metal.Base{ID: "2"}, OS: &metal.SwitchOS{Vendor: "sonic"}},
+					{Base: metal.Base{ID: "3"}, OS: &metal.SwitchOS{Vendor: "sonic"}},
+				},
+				want: []*metal.Switch{
+					tt.defaultBody(&metal.Switch{Base: metal.Base{ID: "2"}, OS: &metal.SwitchOS{Vendor: "sonic"}}),
+					tt.defaultBody(&metal.Switch{Base: metal.Base{ID: "3"}, OS: &metal.SwitchOS{Vendor: "sonic"}}),
+				},
+				wantErr: nil,
 			},
-			want: []*metal.Switch{
-				tt.defaultBody(&metal.Switch{Base: metal.Base{ID: "2"}, RackID: "b"}),
+			{
+				name: "search by os version",
+				q: &SwitchSearchQuery{
+					OSVersion: new("1.2.3"),
+				},
+				mock: []*metal.Switch{
+					{Base: metal.Base{ID: "1"}, OS: &metal.SwitchOS{Version: "1.2.1"}},
+					{Base: metal.Base{ID: "2"}, OS: &metal.SwitchOS{Version: "1.2.2"}},
+					{Base: metal.Base{ID: "3"}, OS: &metal.SwitchOS{Version: "1.2.3"}},
+				},
+				want: []*metal.Switch{
+					tt.defaultBody(&metal.Switch{Base: metal.Base{ID: "3"}, OS: &metal.SwitchOS{Version: "1.2.3"}}),
+				},
+				wantErr: nil,
 			},
-			wantErr: nil,
-		},
-		{
-			name: "search by os vendor",
-			q: &SwitchSearchQuery{
-				OSVendor: new("sonic"),
-			},
-			mock: []*metal.Switch{
-				{Base: metal.Base{ID: "1"}, OS: &metal.SwitchOS{Vendor: "cumulus"}},
-				{Base: metal.Base{ID: "2"}, OS: &metal.SwitchOS{Vendor: "sonic"}},
-				{Base: metal.Base{ID: "3"}, OS: &metal.SwitchOS{Vendor: "sonic"}},
-			},
-			want: []*metal.Switch{
-				tt.defaultBody(&metal.Switch{Base: metal.Base{ID: "2"}, OS: &metal.SwitchOS{Vendor: "sonic"}}),
-				tt.defaultBody(&metal.Switch{Base: metal.Base{ID: "3"}, OS: &metal.SwitchOS{Vendor: "sonic"}}),
-			},
-			wantErr: nil,
-		},
-		{
-			name: "search by os version",
-			q: &SwitchSearchQuery{
-				OSVersion: new("1.2.3"),
-			},
-			mock: []*metal.Switch{
-				{Base: metal.Base{ID: "1"}, OS: &metal.SwitchOS{Version: "1.2.1"}},
-				{Base: metal.Base{ID: "2"}, OS: &metal.SwitchOS{Version: "1.2.2"}},
-				{Base: metal.Base{ID: "3"}, OS: &metal.SwitchOS{Version: "1.2.3"}},
-			},
-			want: []*metal.Switch{
-				tt.defaultBody(&metal.Switch{Base: metal.Base{ID: "3"}, OS: &metal.SwitchOS{Version: "1.2.3"}}),
-			},
-			wantErr: nil,
-		},
-	}
+		}
 
-	for i := range tests {
-		tests[i].run(t, tt)
-	}
+		for i := range tests {
+			tests[i].run(t, tt)
+		}
+	})
 }
 
-func TestRethinkStore_ListSwitches(t *testing.T) {
-	tt := &switchTestable{}
-	defer func() {
-		require.NoError(t, tt.wipe())
-	}()
+func TestStore_ListSwitches(t *testing.T) {
+	forEachStore(t, func(t *testing.T) {
+		tt := &switchTestable{}
+		defer func() {
+			require.NoError(t, tt.wipe())
+		}()
 
-	tests := []listTest[*metal.Switch, *SwitchSearchQuery]{
-		{
-			name: "list",
-			mock: []*metal.Switch{
-				{Base: metal.Base{ID: "1"}},
-				{Base: metal.Base{ID: "2"}},
-				{Base: metal.Base{ID: "3"}},
-			},
-			want: []*metal.Switch{
-				tt.defaultBody(&metal.Switch{Base: metal.Base{ID: "1"}}),
-				tt.defaultBody(&metal.Switch{Base: metal.Base{ID: "2"}}),
-				tt.defaultBody(&metal.Switch{Base: metal.Base{ID: "3"}}),
+		tests := []listTest[*metal.Switch, *SwitchSearchQuery]{
+			{
+				name: "list",
+				mock: []*metal.Switch{
+					{Base: metal.Base{ID: "1"}},
+					{Base: metal.Base{ID: "2"}},
+					{Base: metal.Base{ID: "3"}},
+				},
+				want: []*metal.Switch{
+					tt.defaultBody(&metal.Switch{Base: metal.Base{ID: "1"}}),
+					tt.defaultBody(&metal.Switch{Base: metal.Base{ID: "2"}}),
+					tt.defaultBody(&metal.Switch{Base: metal.Base{ID: "3"}}),
+				},
 			},
-		},
-	}
-	for i := range tests {
-		tests[i].run(t, tt)
-	}
+		}
+		for i := range tests {
+			tests[i].run(t, tt)
+		}
+	})
 }
 
-func TestRethinkStore_CreateSwitch(t *testing.T) {
-	tt := &switchTestable{}
-	defer func() {
-		require.NoError(t, tt.wipe())
-	}()
+func TestStore_CreateSwitch(t *testing.T) {
+	forEachStore(t, func(t *testing.T) {
+		tt := &switchTestable{}
+		defer func() {
+			require.NoError(t, tt.wipe())
+		}()
 
-	tests := []createTest[*metal.Switch, *SwitchSearchQuery]{
-		{
-			name: "create",
-			want: &metal.Switch{
-				Base: metal.Base{ID: "1"}, Nics: metal.Nics{},
+		tests := []createTest[*metal.Switch, *SwitchSearchQuery]{
+			{
+				name: "create",
+				want: &metal.Switch{
+					Base: metal.Base{ID: "1"}, Nics: metal.Nics{},
+				},
+				wantErr: nil,
 			},
-			wantErr: nil,
-		},
-		{
-			name: "already exists",
-			mock: []*metal.Switch{
-				{Base: metal.Base{ID: "1"}},
+			{
+				name: "already exists",
+				mock: []*metal.Switch{
+					{Base: metal.Base{ID: "1"}},
+				},
+				want:    tt.defaultBody(&metal.Switch{Base: metal.Base{ID: "1"}}),
+				wantErr: metal.Conflict(`cannot create switch in database, entity already exists: 1`),
 			},
-			want:    tt.defaultBody(&metal.Switch{Base: metal.Base{ID: "1"}}),
-			wantErr: metal.Conflict(`cannot create switch in database, entity already exists: 1`),
-		},
-	}
-	for i := range tests {
-		tests[i].run(t, tt)
-	}
+		}
+		for i := range tests {
+			tests[i].run(t, tt)
+		}
+	})
 }
 
-func TestRethinkStore_DeleteSwitch(t *testing.T) {
-	tt := &switchTestable{}
-	defer func() {
-		require.NoError(t, tt.wipe())
-	}()
+func TestStore_DeleteSwitch(t *testing.T) {
+	forEachStore(t, func(t *testing.T) {
+		tt := &switchTestable{}
+		defer func() {
+			require.NoError(t, tt.wipe())
+		}()
 
-	tests := []deleteTest[*metal.Switch, *SwitchSearchQuery]{
-		{
-			name: "delete",
-			id:   "2",
-			mock: []*metal.Switch{
-				{Base: metal.Base{ID: "1"}},
-				{Base: metal.Base{ID: "2"}},
-				{Base: metal.Base{ID: "3"}},
-			},
-			want: []*metal.Switch{
-				{Base: metal.Base{ID: "1"}, Nics: metal.Nics{}},
-				{Base: metal.Base{ID: "3"}, Nics: metal.Nics{}},
+		tests := []deleteTest[*metal.Switch, *SwitchSearchQuery]{
+			{
+				name: "delete",
+				id:   "2",
+				mock: []*metal.Switch{
+					{Base: metal.Base{ID: "1"}},
+					{Base: metal.Base{ID: "2"}},
+					{Base: metal.Base{ID: "3"}},
+				},
+				want: []*metal.Switch{
+					{Base: metal.Base{ID: "1"}, Nics: metal.Nics{}},
+					{Base: metal.Base{ID: "3"}, Nics: metal.Nics{}},
+				},
 			},
-		},
-		{
-			name: "not exists results in noop",
-			id:   "abc",
-			mock: []*metal.Switch{
-				{Base: metal.Base{ID: "1"}},
-				{Base: metal.Base{ID: "2"}},
-				{Base: metal.Base{ID: "3"}},
+			{
+				name: "not exists results in noop",
+				id:   "abc",
+				mock: []*metal.Switch{
+					{Base: metal.Base{ID: "1"}},
+					{Base: metal.Base{ID: "2"}},
+					{Base: metal.Base{ID: "3"}},
+				},
+				want: []*metal.Switch{
+					tt.defaultBody(&metal.Switch{Base: metal.Base{ID: "1"}}),
+					tt.defaultBody(&metal.Switch{Base: metal.Base{ID: "2"}}),
+					tt.defaultBody(&metal.Switch{Base: metal.Base{ID: "3"}}),
+				},
 			},
-			want: []*metal.Switch{
-				tt.defaultBody(&metal.Switch{Base: metal.Base{ID: "1"}}),
-				tt.defaultBody(&metal.Switch{Base: metal.Base{ID: "2"}}),
-				tt.defaultBody(&metal.Switch{Base: metal.Base{ID: "3"}}),
-			},
-		},
-	}
-	for i := range tests {
-		tests[i].run(t, tt)
-	}
+		}
+		for i := range tests {
+			tests[i].run(t, tt)
+		}
+	})
 }
 
-func TestRethinkStore_UpdateSwitch(t *testing.T) {
-	tt := &switchTestable{}
-	defer func() {
-		require.NoError(t, tt.wipe())
-	}()
+func TestStore_UpdateSwitch(t *testing.T) {
+	forEachStore(t, func(t *testing.T) {
+		tt := &switchTestable{}
+		defer func() {
+			require.NoError(t, tt.wipe())
+		}()
 
-	tests := []updateTest[*metal.Switch, *SwitchSearchQuery]{
-		{
-			name: "update",
-			mock: []*metal.Switch{
-				{Base: metal.Base{ID: "1"}},
-				{Base: metal.Base{ID: "2"}},
-				{Base: metal.Base{ID: "3"}},
-			},
-			mutateFn: func(s *metal.Switch) {
-				s.RackID = "abc"
+		tests := []updateTest[*metal.Switch, *SwitchSearchQuery]{
+			{
+				name: "update",
+				mock: []*metal.Switch{
+					{Base: metal.Base{ID: "1"}},
+					{Base: metal.Base{ID: "2"}},
+					{Base: metal.Base{ID: "3"}},
+				},
+				mutateFn: func(s *metal.Switch) {
+					s.RackID = "abc"
+				},
+				want: &metal.Switch{
+					Base:   metal.Base{ID: "1"},
+					Nics:   metal.Nics{},
+					RackID: "abc",
+				},
 			},
-			want: &metal.Switch{
-				Base:   metal.Base{ID: "1"},
-				Nics:   metal.Nics{},
-				RackID: "abc",
-			},
-		},
-	}
-	for i := range tests {
-		tests[i].run(t, tt)
-	}
+		}
+		for i := range tests {
+			tests[i].run(t, tt)
+		}
+	})
 }
@@ -0,0 +1,174 @@
+package datastore
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/metal-stack/metal-api/cmd/metal-api/internal/metal"
+	r "gopkg.in/rethinkdb/rethinkdb-go.v6"
+	"gopkg.in/rethinkdb/rethinkdb-go.v6/encoding"
+)
+
+// An EntityChange describes the creation, modification or deletion of an entity in the database.
+type EntityChange struct {
+	// Table is the name of the table which contains the entity
+	Table string
+	// Type is one of metal.CREATE, metal.UPDATE or metal.DELETE
+	Type metal.EventType
+	// Old is the entity before the change, it is nil for created entities
+	Old metal.Entity
+	// New is the entity after the change, it is nil for deleted entities
+	New metal.Entity
+}
+
+// ID returns the id of the changed entity.
+func (c EntityChange) ID() string {
+	if c.New != nil {
+		return c.New.GetID()
+	}
+	if c.Old != nil {
+		return c.Old.GetID()
+	}
+	return ""
+}
+
+// watchableTables contains the tables which can be watched for changes along with a constructor for their entities.
+var watchableTables = map[string]func() metal.Entity{
+	"entitychange": func() metal.Entity { return &metal.EntityChangeLogEntry{} },
+	"ip":           func() metal.Entity { return &metal.IP{} },
+	"machine":      func() metal.Entity { return &metal.Machine{} },
+	"network":      func() metal.Entity { return &metal.Network{} },
+	"switch":       func() metal.Entity { return &metal.Switch{} },
+}
+
+func verifyWatchableTables(tables []string) error {
+	if len(tables) == 0 {
+		return fmt.Errorf("at least one table must be given to watch")
+	}
+	for _, t := range tables {
+		if _, ok := watchableTables[t]; !ok {
+			return fmt.Errorf("table %q cannot be watched", t)
+		}
+	}
+	return nil
+}
+
+func newEntityChange(table string, oldDoc, newDoc any) (*EntityChange, error) {
+	change := &EntityChange{
+		Table: table,
+	}
+
+	switch {
+	case oldDoc == nil && newDoc != nil:
+		change.Type = metal.CREATE
+	case oldDoc != nil && newDoc != nil:
+		change.Type = metal.UPDATE
+	case oldDoc != nil && newDoc == nil:
+		change.Type = metal.DELETE
+	default:
+		return nil, nil
+	}
+
+	if oldDoc != nil {
+		change.Old = watchableTables[table]()
+		err := encoding.Decode(change.Old, oldDoc)
+		if err != nil {
+			return nil, fmt.Errorf("cannot decode old value of change in table %s: %w", table, err)
+		}
+	}
+	if newDoc != nil {
+		change.New = watchableTables[table]()
+		err := encoding.Decode(change.New, newDoc)
+		if err != nil {
+			return nil, fmt.Errorf("cannot decode new value of change in table %s: %w", table, err)
+		}
+	}
+
+	return change, nil
+}
+
+// rethinkChange is a single result of a rethinkdb changefeed.
+type rethinkChange struct {
+	OldVal any `rethinkdb:"old_val"`
+	NewVal any `rethinkdb:"new_val"`
+}
+
+// Watch streams the changes of entities in the given tables using rethinkdb changefeeds.
+// The returned channel is closed when the context is canceled or one of the changefeeds failed,
+// callers have to start a new watch in the latter case and have to expect that changes got lost.
+func (rs *RethinkStore) Watch(ctx context.Context, tables ...string) (<-chan EntityChange, error) {
+	err := verifyWatchableTables(tables)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+
+	var cursors []*r.Cursor
+	for _, table := range tables {
+		cursor, err := rs.db().Table(table).Changes().Run(rs.session, r.RunOpts{Context: ctx})
+		if err != nil {
+			cancel()
+			for _, c := range cursors {
+				_ = c.Close()
+			}
+			return nil, fmt.Errorf("cannot watch table %s: %w", table, err)
+		}
+		cursors = append(cursors, cursor)
+	}
+
+	var (
+		res = make(chan EntityChange)
+		wg  sync.WaitGroup
+	)
+
+	for i, cursor := range cursors {
+		table := tables[i]
+		wg.Go(func() {
+			// stop all other changefeeds if one of them ends
+			defer cancel()
+
+			for {
+				var row rethinkChange
+				if !cursor.Next(&row) {
+					break
+				}
+
+				change, err := newEntityChange(table, row.OldVal, row.NewVal)
+				if err != nil {
+					rs.log.Error("skipping change of entity", "table", table, "error", err)
+					continue
+				}
+				if change == nil {
+					continue
+				}
+
+				select {
+				case res <- *change:
+				case <-ctx.Done():
+					return
+				}
+			}
+
+			if err := cursor.Err(); err != nil && ctx.Err() == nil {
+				rs.log.Error("changefeed terminated", "table", table, "error", err)
+			}
+		})
+	}
+
+	go func() {
+		<-ctx.Done()
+		for _, c := range cursors {
+			_ = c.Close()
+		}
+	}()
+
+	go func() {
+		wg.Wait()
+		cancel()
+		close(res)
+	}()
+
+	return res, nil
+}
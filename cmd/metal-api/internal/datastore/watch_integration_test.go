@@ -0,0 +1,46 @@
+//go:build integration
+
+package datastore
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/metal-stack/metal-api/cmd/metal-api/internal/metal"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStore_Watch(t *testing.T) {
+	forEachStore(t, func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		changes, err := sharedDS.Watch(ctx, "switch")
+		require.NoError(t, err)
+
+		s := &metal.Switch{Base: metal.Base{ID: "watched-switch", Name: "a"}}
+		require.NoError(t, sharedDS.CreateSwitch(s))
+
+		updated := *s
+		updated.Name = "b"
+		require.NoError(t, sharedDS.UpdateSwitch(s, &updated))
+		require.NoError(t, sharedDS.DeleteSwitch(&updated))
+
+		for _, want := range []metal.EventType{metal.CREATE, metal.UPDATE, metal.DELETE} {
+			select {
+			case change := <-changes:
+				require.Equal(t, "switch", change.Table)
+				require.Equal(t, want, change.Type)
+				require.Equal(t, "watched-switch", change.ID())
+			case <-time.After(10 * time.Second):
+				t.Fatalf("no %s change received", want)
+			}
+		}
+
+		cancel()
+		for range changes {
+			// drain until the channel is closed
+		}
+	})
+}
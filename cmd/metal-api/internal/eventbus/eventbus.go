@@ -0,0 +1,100 @@
+package eventbus
+
+import (
+	"log/slog"
+	"time"
+
+	"github.com/metal-stack/metal-api/cmd/metal-api/internal/metal"
+	"github.com/metal-stack/metal-lib/bus"
+)
+
+const (
+	// BackendNSQ is the event bus backend which uses nsqd and nsqlookupd.
+	BackendNSQ = "nsq"
+	// BackendMemory is the in-process event bus backend, messages are not shared with other processes.
+	BackendMemory = "memory"
+)
+
+// Backends contains all available event bus backends.
+var Backends = []string{BackendNSQ, BackendMemory}
+
+// Bus is an event bus which covers topic creation, publishing and consuming of messages.
+//
+// Every backend follows the semantics of nsq: a message published to a topic is delivered to every channel
+// of the topic, within a channel every message is only delivered to one of its consumers.
+type Bus interface {
+	bus.Publisher
+	Consumer
+
+	// WaitForTopicsCreated blocks until the given topics are created, partition agnostic topics are created for every partition.
+	WaitForTopicsCreated(partitions metal.Partitions, topics []metal.NSQTopic)
+	// Endpoints returns the endpoints of functions which are invoked through the event bus.
+	Endpoints() *bus.Endpoints
+}
+
+// Consumer consumes messages of a topic.
+type Consumer interface {
+	// Consume registers the receiver for the messages of the topic in the given channel and starts the given amount of concurrent handlers.
+	// The receiver is called with a pointer to a value of the type of paramProto, which is unmarshalled from the message.
+	Consume(topic, channel string, paramProto any, recv bus.Receiver, concurrent int, opts ...ConsumeOption) error
+}
+
+// ConsumeOption configures the consumption of messages.
+type ConsumeOption func(*consumeConfig)
+
+type consumeConfig struct {
+	timeout   time.Duration
+	onTimeout bus.OnTimeout
+	ttl       time.Duration
+}
+
+// Timeout guards the receiver with a timeout, timeout 0 means no timeout.
+// The optional onTimeout function is called in the case of timeout while handling the message.
+func Timeout(timeout time.Duration, onTimeout bus.OnTimeout) ConsumeOption {
+	return func(c *consumeConfig) {
+		c.timeout = timeout
+		c.onTimeout = onTimeout
+	}
+}
+
+// TTL specifies the maximum age of messages to accept, older messages are dropped.
+func TTL(ttl time.Duration) ConsumeOption {
+	return func(c *consumeConfig) {
+		c.ttl = ttl
+	}
+}
+
+func newConsumeConfig(opts ...ConsumeOption) *consumeConfig {
+	c := &consumeConfig{}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// createTopics creates the given topics, partition agnostic topics are created for every partition.
+func createTopics(log *slog.Logger, tc interface{ CreateTopic(string) error }, partitions metal.Partitions, topics []metal.NSQTopic) error {
+	for _, topic := range topics {
+		if topic.PartitionAgnostic {
+			continue
+		}
+		if err := tc.CreateTopic(topic.Name); err != nil {
+			log.Error("cannot create topic", "topic", topic.Name)
+			return err
+		}
+	}
+
+	for _, partition := range partitions {
+		for _, topic := range topics {
+			if !topic.PartitionAgnostic {
+				continue
+			}
+			topicFQN := topic.GetFQN(partition.GetID())
+			if err := tc.CreateTopic(topicFQN); err != nil {
+				log.Error("cannot create topic", "topic", topicFQN, "partition", partition.GetID())
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,232 @@
+package eventbus
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/metal-stack/metal-api/cmd/metal-api/internal/metal"
+	"github.com/metal-stack/metal-lib/bus"
+)
+
+var _ Bus = &Memory{}
+
+// Memory is an in-process event bus backend for single-binary deployments and tests.
+//
+// Messages are only delivered to consumers of the same process. Like with nsq, messages are delivered to every
+// channel of a topic and within a channel to only one of its consumers. Unlike nsq, messages are not persisted,
+// messages published to a topic without channels are dropped and messages whose receiver returns an error are not requeued.
+// Functions of the endpoints are invoked directly.
+type Memory struct {
+	log    *slog.Logger
+	mu     sync.Mutex
+	topics map[string]map[string]*memoryChannel
+	closed bool
+}
+
+type memoryMessage struct {
+	body      []byte
+	timestamp time.Time
+}
+
+type memoryChannel struct {
+	mu       sync.Mutex
+	cond     *sync.Cond
+	messages []memoryMessage
+	closed   bool
+}
+
+// NewMemory returns a new in-process event bus.
+func NewMemory(log *slog.Logger) *Memory {
+	return &Memory{
+		log:    log,
+		topics: map[string]map[string]*memoryChannel{},
+	}
+}
+
+// CreateTopic creates a topic with the given name, creating an existing topic is a no-op.
+func (m *Memory) CreateTopic(name string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.closed {
+		return fmt.Errorf("event bus is stopped")
+	}
+
+	if _, ok := m.topics[name]; !ok {
+		m.topics[name] = map[string]*memoryChannel{}
+		m.log.Info("topic created", "topic", name)
+	}
+	return nil
+}
+
+// WaitForTopicsCreated creates the given topics, partition agnostic topics are created for every partition.
+func (m *Memory) WaitForTopicsCreated(partitions metal.Partitions, topics []metal.NSQTopic) {
+	if err := createTopics(m.log, m, partitions, topics); err != nil {
+		m.log.Error("cannot create topics", "error", err)
+	}
+}
+
+// Publish publishes the given data as json to all channels of the topic, the topic is created implicitly.
+func (m *Memory) Publish(topic string, data any) error {
+	b, err := json.Marshal(data)
+	if err != nil {
+		return fmt.Errorf("cannot marshal data to json: %w", err)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.closed {
+		return fmt.Errorf("event bus is stopped")
+	}
+
+	channels, ok := m.topics[topic]
+	if !ok {
+		m.topics[topic] = map[string]*memoryChannel{}
+		return nil
+	}
+
+	msg := memoryMessage{body: b, timestamp: time.Now()}
+	for _, c := range channels {
+		c.put(msg)
+	}
+	return nil
+}
+
+// Consume registers the receiver for the messages of the topic in the given channel and starts the given amount of concurrent handlers.
+func (m *Memory) Consume(topic, channel string, paramProto any, recv bus.Receiver, concurrent int, opts ...ConsumeOption) error {
+	if concurrent < 1 {
+		return fmt.Errorf("at least one concurrent handler is required")
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.closed {
+		return fmt.Errorf("event bus is stopped")
+	}
+
+	channels, ok := m.topics[topic]
+	if !ok {
+		channels = map[string]*memoryChannel{}
+		m.topics[topic] = channels
+	}
+	c, ok := channels[channel]
+	if !ok {
+		c = newMemoryChannel()
+		channels[channel] = c
+	}
+
+	cfg := newConsumeConfig(opts...)
+	msgType := reflect.TypeOf(paramProto)
+	for range concurrent {
+		go func() {
+			for {
+				msg, ok := c.next()
+				if !ok {
+					return
+				}
+				if err := m.handle(cfg, msgType, recv, msg); err != nil {
+					m.log.Error("unable to handle message", "topic", topic, "channel", channel, "error", err)
+				}
+			}
+		}()
+	}
+
+	return nil
+}
+
+// Endpoints returns endpoints which invoke functions directly within this process.
+func (m *Memory) Endpoints() *bus.Endpoints {
+	return bus.DirectEndpoints()
+}
+
+// Stop stops all consumers, messages which were not handled yet are dropped.
+func (m *Memory) Stop() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.closed = true
+	for _, channels := range m.topics {
+		for _, c := range channels {
+			c.close()
+		}
+	}
+}
+
+func (m *Memory) handle(cfg *consumeConfig, msgType reflect.Type, recv bus.Receiver, msg memoryMessage) error {
+	if cfg.ttl > 0 {
+		if age := time.Since(msg.timestamp); age > cfg.ttl {
+			m.log.Warn("dropped message", "age", age)
+			return nil
+		}
+	}
+
+	nv := reflect.New(msgType).Interface()
+	if err := json.Unmarshal(msg.body, nv); err != nil {
+		return err
+	}
+
+	// timeout 0 means synchronous call without timeout
+	if cfg.timeout == 0 {
+		return recv(nv)
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- recv(nv)
+	}()
+
+	select {
+	case err := <-done:
+		return err
+	case <-time.After(cfg.timeout):
+		if cfg.onTimeout != nil {
+			return cfg.onTimeout(bus.TimeoutError{})
+		}
+		return nil
+	}
+}
+
+func newMemoryChannel() *memoryChannel {
+	c := &memoryChannel{}
+	c.cond = sync.NewCond(&c.mu)
+	return c
+}
+
+func (c *memoryChannel) put(msg memoryMessage) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.messages = append(c.messages, msg)
+	c.cond.Signal()
+}
+
+// next blocks until a message is available, it returns false if the channel was closed.
+func (c *memoryChannel) next() (memoryMessage, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for len(c.messages) == 0 && !c.closed {
+		c.cond.Wait()
+	}
+	if c.closed {
+		return memoryMessage{}, false
+	}
+
+	msg := c.messages[0]
+	c.messages = c.messages[1:]
+	return msg, true
+}
+
+func (c *memoryChannel) close() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.closed = true
+	c.cond.Broadcast()
+}
@@ -0,0 +1,103 @@
+package eventbus
+
+import (
+	"log/slog"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/metal-stack/metal-api/cmd/metal-api/internal/metal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemory_WaitForTopicsCreated(t *testing.T) {
+	m := NewMemory(slog.Default())
+	defer m.Stop()
+
+	m.WaitForTopicsCreated(metal.Partitions{{Base: metal.Base{ID: "p1"}}, {Base: metal.Base{ID: "p2"}}}, metal.Topics)
+
+	var topics []string
+	for topic := range m.topics {
+		topics = append(topics, topic)
+	}
+	slices.Sort(topics)
+
+	assert.Equal(t, []string{"allocation", "machine-lease", "p1-machine", "p2-machine", "project"}, topics)
+}
+
+func TestMemory_PublishConsume(t *testing.T) {
+	m := NewMemory(slog.Default())
+	defer m.Stop()
+
+	var (
+		mu       sync.Mutex
+		received = map[string][]string{}
+		wg       sync.WaitGroup
+	)
+	receiver := func(channel string) func(any) error {
+		return func(message any) error {
+			defer wg.Done()
+			evt := message.(*metal.AllocationEvent)
+			mu.Lock()
+			defer mu.Unlock()
+			received[channel] = append(received[channel], evt.MachineID)
+			return nil
+		}
+	}
+
+	// the first channel is consumed concurrently, every message must still only be delivered once within the channel
+	require.NoError(t, m.Consume(metal.TopicAllocation.Name, "a", metal.AllocationEvent{}, receiver("a"), 3))
+	require.NoError(t, m.Consume(metal.TopicAllocation.Name, "b", metal.AllocationEvent{}, receiver("b"), 1))
+
+	// messages of other topics must not be delivered
+	require.NoError(t, m.Publish(metal.TopicProject.Name, metal.ProjectEvent{ProjectID: "p"}))
+
+	wg.Add(6)
+	for _, id := range []string{"m1", "m2", "m3"} {
+		require.NoError(t, m.Publish(metal.TopicAllocation.Name, metal.AllocationEvent{MachineID: id}))
+	}
+	wg.Wait()
+
+	slices.Sort(received["a"])
+	assert.Equal(t, []string{"m1", "m2", "m3"}, received["a"])
+	assert.Equal(t, []string{"m1", "m2", "m3"}, received["b"])
+}
+
+func TestMemory_ConsumeWithTTL(t *testing.T) {
+	m := NewMemory(slog.Default())
+	defer m.Stop()
+
+	received := make(chan string, 2)
+	block := make(chan struct{})
+
+	require.NoError(t, m.Consume(metal.TopicAllocation.Name, "c", metal.AllocationEvent{}, func(message any) error {
+		evt := message.(*metal.AllocationEvent)
+		if evt.MachineID == "blocking" {
+			<-block
+		}
+		received <- evt.MachineID
+		return nil
+	}, 1, TTL(50*time.Millisecond)))
+
+	require.NoError(t, m.Publish(metal.TopicAllocation.Name, metal.AllocationEvent{MachineID: "blocking"}))
+	require.NoError(t, m.Publish(metal.TopicAllocation.Name, metal.AllocationEvent{MachineID: "expired"}))
+
+	// the second message waits in the channel until it is older than the ttl
+	time.Sleep(100 * time.Millisecond)
+	close(block)
+	require.NoError(t, m.Publish(metal.TopicAllocation.Name, metal.AllocationEvent{MachineID: "fresh"}))
+
+	assert.Equal(t, "blocking", <-received)
+	assert.Equal(t, "fresh", <-received)
+}
+
+func TestMemory_Stop(t *testing.T) {
+	m := NewMemory(slog.Default())
+	m.Stop()
+
+	require.Error(t, m.Publish(metal.TopicAllocation.Name, metal.AllocationEvent{}))
+	require.Error(t, m.CreateTopic(metal.TopicAllocation.Name))
+	require.Error(t, m.Consume(metal.TopicAllocation.Name, "c", metal.AllocationEvent{}, func(any) error { return nil }, 1))
+}
@@ -12,15 +12,18 @@ import (
 // nsqdRetryDelay represents the delay that is used for retries in blocking calls.
 const nsqdRetryDelay = 3 * time.Second
 
+var _ Bus = &NSQClient{}
+
 type PublisherProvider func(*slog.Logger, *bus.PublisherConfig) (bus.Publisher, error)
 
-// NSQClient is a type to request NSQ related tasks such as creation of topics.
+// NSQClient is the event bus backend which uses nsqd for publishing and nsqlookupd for consuming messages.
 type NSQClient struct {
 	logger            *slog.Logger
 	config            *bus.PublisherConfig
 	publisherProvider PublisherProvider
 	Publisher         bus.Publisher
-	Endpoints         *bus.Endpoints
+	Consumer          *bus.Consumer
+	endpoints         *bus.Endpoints
 }
 
 // NewNSQ create a new NSQClient.
@@ -47,6 +50,7 @@ func (n *NSQClient) WaitForPublisher() {
 	}
 }
 
+// CreateEndpoints creates the consumer and the function endpoints which use the given nsqlookupds.
 func (n *NSQClient) CreateEndpoints(lookupds ...string) error {
 	c, err := bus.NewConsumer(n.logger, n.config.TLS, lookupds...)
 	if err != nil {
@@ -54,14 +58,20 @@ func (n *NSQClient) CreateEndpoints(lookupds ...string) error {
 	}
 	// change loglevel to warning, because nsq is very noisy
 	c.With(bus.LogLevel(bus.Warning))
-	n.Endpoints = bus.NewEndpoints(c, n.Publisher)
+	n.Consumer = c
+	n.endpoints = bus.NewEndpoints(c, n.Publisher)
 	return nil
 }
 
+// Endpoints returns the function endpoints, which are created with CreateEndpoints.
+func (n *NSQClient) Endpoints() *bus.Endpoints {
+	return n.endpoints
+}
+
 // WaitForTopicsCreated blocks until the topices are created within the given partitions.
 func (n *NSQClient) WaitForTopicsCreated(partitions metal.Partitions, topics []metal.NSQTopic) {
 	for {
-		if err := n.createTopics(partitions, topics); err != nil {
+		if err := createTopics(n.logger, n, partitions, topics); err != nil {
 			n.logger.Error("cannot create topics", "error", err)
 			n.delay()
 			continue
@@ -80,30 +90,25 @@ func (n *NSQClient) CreateTopic(name string) error {
 	return nil
 }
 
-func (n *NSQClient) createTopics(partitions metal.Partitions, topics []metal.NSQTopic) error {
-	for _, topic := range topics {
-		if topic.PartitionAgnostic {
-			continue
-		}
-		if err := n.CreateTopic(topic.Name); err != nil {
-			n.logger.Error("cannot create topic", "topic", topic.Name)
-			return err
-		}
-	}
+// Publish publishes the given data to the topic.
+func (n *NSQClient) Publish(topic string, data any) error {
+	return n.Publisher.Publish(topic, data)
+}
 
-	for _, partition := range partitions {
-		for _, topic := range topics {
-			if !topic.PartitionAgnostic {
-				continue
-			}
-			topicFQN := topic.GetFQN(partition.GetID())
-			if err := n.CreateTopic(topicFQN); err != nil {
-				n.logger.Error("cannot create topic", "topic", topicFQN, "partition", partition.GetID())
-				return err
-			}
-		}
+// Stop stops the publisher.
+func (n *NSQClient) Stop() {
+	n.Publisher.Stop()
+}
+
+// Consume registers the receiver for the messages of the topic in the given channel.
+func (n *NSQClient) Consume(topic, channel string, paramProto any, recv bus.Receiver, concurrent int, opts ...ConsumeOption) error {
+	cr, err := n.Consumer.Register(topic, channel)
+	if err != nil {
+		return err
 	}
-	return nil
+
+	cfg := newConsumeConfig(opts...)
+	return cr.Consume(paramProto, recv, concurrent, bus.Timeout(cfg.timeout, cfg.onTimeout), bus.TTL(cfg.ttl))
 }
 
 func (n *NSQClient) delay() {
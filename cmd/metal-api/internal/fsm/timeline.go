@@ -0,0 +1,57 @@
+package fsm
+
+import (
+	"slices"
+
+	"github.com/metal-stack/metal-api/cmd/metal-api/internal/fsm/states"
+	"github.com/metal-stack/metal-api/cmd/metal-api/internal/metal"
+)
+
+// ProvisioningTimeline replays the events of the given provisioning event container along the transitions of the state machine
+// and returns the phases the machine went through in chronological order.
+//
+// Events which transition a state to itself, e.g. subsequent PXE booting events, extend the current phase. Phases which were entered
+// by an event that is not expected in the previous state are marked as unexpected, the state machine continues from the destination
+// of such an event just like HandleProvisioningEvent does.
+func ProvisioningTimeline(ec *metal.ProvisioningEventContainer) metal.ProvisioningTimeline {
+	var (
+		timeline metal.ProvisioningTimeline
+		state    = states.Initial.String()
+	)
+
+	if ec == nil {
+		return timeline
+	}
+
+	// events are stored with the latest event first
+	for _, event := range slices.Backward(ec.Events) {
+		dst, ok := transitionDestination(state, event.Event.String())
+		if ok && dst == SelfTransitionState && len(timeline) > 0 {
+			continue
+		}
+
+		if len(timeline) > 0 {
+			timeline[len(timeline)-1].End = &event.Time
+		}
+
+		timeline = append(timeline, metal.ProvisioningPhase{
+			Event:      event.Event,
+			Start:      event.Time,
+			Unexpected: !ok,
+		})
+
+		state = getEventDestination(event.Event.String())
+	}
+
+	return timeline
+}
+
+func transitionDestination(src, event string) (string, bool) {
+	for _, e := range Events() {
+		if e.Name == event && slices.Contains(e.Src, src) {
+			return e.Dst, true
+		}
+	}
+
+	return "", false
+}
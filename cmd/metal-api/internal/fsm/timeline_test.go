@@ -0,0 +1,68 @@
+package fsm
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/metal-stack/metal-api/cmd/metal-api/internal/metal"
+)
+
+func TestProvisioningTimeline(t *testing.T) {
+	start := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	at := func(minutes int) time.Time {
+		return start.Add(time.Duration(minutes) * time.Minute)
+	}
+
+	tests := []struct {
+		name      string
+		container *metal.ProvisioningEventContainer
+		want      metal.ProvisioningTimeline
+	}{
+		{
+			name:      "no container",
+			container: nil,
+			want:      nil,
+		},
+		{
+			name: "repeated pxe booting extends the phase",
+			container: &metal.ProvisioningEventContainer{
+				Events: metal.ProvisioningEvents{
+					{Time: at(5), Event: metal.ProvisioningEventPreparing},
+					{Time: at(2), Event: metal.ProvisioningEventPXEBooting},
+					{Time: at(0), Event: metal.ProvisioningEventPXEBooting},
+				},
+			},
+			want: metal.ProvisioningTimeline{
+				{Event: metal.ProvisioningEventPXEBooting, Start: at(0), End: new(at(5))},
+				{Event: metal.ProvisioningEventPreparing, Start: at(5)},
+			},
+		},
+		{
+			name: "unexpected transition is marked",
+			container: &metal.ProvisioningEventContainer{
+				Events: metal.ProvisioningEvents{
+					{Time: at(10), Event: metal.ProvisioningEventWaiting},
+					{Time: at(7), Event: metal.ProvisioningEventRegistering},
+					{Time: at(5), Event: metal.ProvisioningEventInstalling},
+					{Time: at(0), Event: metal.ProvisioningEventPreparing},
+				},
+			},
+			want: metal.ProvisioningTimeline{
+				{Event: metal.ProvisioningEventPreparing, Start: at(0), End: new(at(5))},
+				{Event: metal.ProvisioningEventInstalling, Start: at(5), End: new(at(7)), Unexpected: true},
+				{Event: metal.ProvisioningEventRegistering, Start: at(7), End: new(at(10)), Unexpected: true},
+				{Event: metal.ProvisioningEventWaiting, Start: at(10)},
+			},
+		},
+	}
+	for i := range tests {
+		tt := tests[i]
+		t.Run(tt.name, func(t *testing.T) {
+			got := ProvisioningTimeline(tt.container)
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Errorf("ProvisioningTimeline() diff = %s", diff)
+			}
+		})
+	}
+}
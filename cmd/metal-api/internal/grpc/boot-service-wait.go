@@ -5,6 +5,7 @@ import (
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/metal-stack/metal-api/cmd/metal-api/internal/eventbus"
 	"github.com/metal-stack/metal-api/cmd/metal-api/internal/metal"
 	v1 "github.com/metal-stack/metal-api/pkg/api/v1"
 	"github.com/metal-stack/metal-lib/bus"
@@ -95,14 +96,12 @@ func (b *BootService) Wait(req *v1.BootServiceWaitRequest, srv v1.BootService_Wa
 
 func (b *BootService) initWaitEndpoint() error {
 	channel := fmt.Sprintf("alloc-%s#ephemeral", uuid.NewString())
-	return b.consumer.With(bus.LogLevel(bus.Warning)).
-		MustRegister(metal.TopicAllocation.Name, channel).
-		Consume(metal.AllocationEvent{}, func(message any) error {
-			evt := message.(*metal.AllocationEvent)
-			b.log.Debug("got message", "topic", metal.TopicAllocation.Name, "channel", channel, "machineID", evt.MachineID)
-			b.handleAllocation(evt.MachineID)
-			return nil
-		}, 5, bus.Timeout(receiverHandlerTimeout, b.timeoutHandler), bus.TTL(allocationTopicTTL))
+	return b.consumer.Consume(metal.TopicAllocation.Name, channel, metal.AllocationEvent{}, func(message any) error {
+		evt := message.(*metal.AllocationEvent)
+		b.log.Debug("got message", "topic", metal.TopicAllocation.Name, "channel", channel, "machineID", evt.MachineID)
+		b.handleAllocation(evt.MachineID)
+		return nil
+	}, 5, eventbus.Timeout(receiverHandlerTimeout, b.timeoutHandler), eventbus.TTL(allocationTopicTTL))
 }
 
 func (b *BootService) timeoutHandler(err bus.TimeoutError) error {
@@ -15,6 +15,7 @@ import (
 
 	"github.com/avast/retry-go/v4"
 	"github.com/metal-stack/metal-api/cmd/metal-api/internal/datastore"
+	"github.com/metal-stack/metal-api/cmd/metal-api/internal/eventbus"
 	"github.com/metal-stack/metal-api/cmd/metal-api/internal/metal"
 	v1 "github.com/metal-stack/metal-api/pkg/api/v1"
 	v1grpc "github.com/metal-stack/metal-api/pkg/grpc"
@@ -39,7 +40,7 @@ type (
 
 		t *testing.T
 
-		ds        *datastore.RethinkStore
+		ds        datastore.Store
 		publisher bus.Publisher
 		consumer  *bus.Consumer
 
@@ -68,18 +69,32 @@ func TestWaitServer(t *testing.T) {
 		ctx = context.Background()
 	)
 
-	// starting a rethinkdb rethinkContainer
-	rethinkContainer, c, err := integrationtest.StartRethink(t)
-	require.NoError(t, err)
-	defer func() {
-		_ = rethinkContainer.Terminate(ctx)
-	}()
+	t.Run("rethinkdb", func(t *testing.T) {
+		// starting a rethinkdb rethinkContainer
+		rethinkContainer, c, err := integrationtest.StartRethink(t)
+		require.NoError(t, err)
+		defer func() {
+			_ = rethinkContainer.Terminate(ctx)
+		}()
 
-	ds := datastore.New(log, c.IP+":"+c.Port, c.DB, c.User, c.Password)
-	ds.VRFPoolRangeMax = 1000
-	ds.ASNPoolRangeMax = 1000
+		ds := datastore.New(log, c.IP+":"+c.Port, c.DB, c.User, c.Password)
+		ds.VRFPoolRangeMax = 1000
+		ds.ASNPoolRangeMax = 1000
+
+		testWaitServer(ctx, t, log, ds)
+	})
+
+	t.Run("memory", func(t *testing.T) {
+		ds := datastore.NewMemory(log)
+		ds.VRFPoolRangeMax = 1000
+		ds.ASNPoolRangeMax = 1000
+
+		testWaitServer(ctx, t, log, ds)
+	})
+}
 
-	err = ds.Connect()
+func testWaitServer(ctx context.Context, t *testing.T, log *slog.Logger, ds datastore.Store) {
+	err := ds.Connect()
 	require.NoError(t, err)
 	err = ds.Initialize()
 	require.NoError(t, err)
@@ -191,7 +206,7 @@ func (te *test) startApiInstances(ctx context.Context) {
 				Logger:     te.log.WithGroup("grpc-server"),
 				Listener:   listener,
 				Publisher:  te.publisher,
-				Consumer:   te.consumer,
+				Consumer:   &eventbus.NSQClient{Consumer: te.consumer},
 				TlsEnabled: false,
 			},
 		}
@@ -11,6 +11,7 @@ import (
 	"github.com/avast/retry-go/v4"
 
 	"github.com/metal-stack/metal-api/cmd/metal-api/internal/datastore"
+	"github.com/metal-stack/metal-api/cmd/metal-api/internal/eventbus"
 	"github.com/metal-stack/metal-api/cmd/metal-api/internal/metal"
 	v1 "github.com/metal-stack/metal-api/pkg/api/v1"
 	"github.com/metal-stack/metal-lib/bus"
@@ -18,10 +19,10 @@ import (
 
 type BootService struct {
 	log              *slog.Logger
-	ds               *datastore.RethinkStore
+	ds               datastore.Store
 	ipmiSuperUser    metal.MachineIPMISuperUser
 	publisher        bus.Publisher
-	consumer         *bus.Consumer
+	consumer         eventbus.Consumer
 	eventService     *EventService
 	queue            sync.Map
 	responseInterval time.Duration
@@ -67,10 +68,28 @@ func (b *BootService) Boot(ctx context.Context, req *v1.BootServiceBootRequest)
 		return nil, fmt.Errorf("no partition with id:%q found %w", req.PartitionId, err)
 	}
 
+	// the machine is only known if it has already registered before, otherwise the defaults of the partition are used
+	var m *metal.Machine
+	if req.Mac != "" {
+		var ms metal.Machines
+		err = b.ds.SearchMachines(&datastore.MachineSearchQuery{PartitionID: &p.ID, NicsMacAddresses: []string{req.Mac}}, &ms)
+		if err != nil {
+			return nil, err
+		}
+		if len(ms) == 1 {
+			m = &ms[0]
+		}
+	}
+
+	bc, override := p.ResolveBootConfiguration(m)
+
 	resp := &v1.BootServiceBootResponse{
-		Kernel:       p.BootConfiguration.KernelURL,
-		InitRamDisks: []string{p.BootConfiguration.ImageURL},
-		Cmdline:      &p.BootConfiguration.CommandLine,
+		Kernel:       bc.KernelURL,
+		InitRamDisks: []string{bc.ImageURL},
+		Cmdline:      &bc.CommandLine,
+	}
+	if override != nil {
+		resp.BootConfigurationOverride = override.String()
 	}
 	b.log.Info("boot", "resp", resp)
 	return resp, nil
@@ -252,6 +271,12 @@ func (b *BootService) Register(ctx context.Context, req *v1.BootServiceRegisterR
 		}
 	}
 
+	err = b.recordHardwareSnapshot(m)
+	if err != nil {
+		// the hardware history is informational only, it must not prevent the machine from registering
+		b.log.Error("unable to record hardware snapshot", "machineID", m.ID, "error", err)
+	}
+
 	ec, err := b.ds.FindProvisioningEventContainer(m.ID)
 	if err != nil && !metal.IsNotFound(err) {
 		return nil, err
@@ -290,6 +315,14 @@ func (b *BootService) Register(ctx context.Context, req *v1.BootServiceRegisterR
 		return nil, err
 	}
 
+	unknownRack, err := datastore.UnknownRack(b.ds, m.RackID)
+	if err != nil {
+		b.log.Error("unable to check rack of machine", "machineID", m.ID, "rack", m.RackID, "error", err)
+	} else if unknownRack {
+		// the registration is not rejected, such that the machine can still be provisioned until the rack is modeled
+		b.log.Warn("machine registered in a rack without location, it is not attributed to any failure domain", "machineID", m.ID, "rack", m.RackID)
+	}
+
 	return &v1.BootServiceRegisterResponse{
 		Uuid:        req.Uuid,
 		Size:        size.ID,
@@ -297,6 +330,55 @@ func (b *BootService) Register(ctx context.Context, req *v1.BootServiceRegisterR
 	}, nil
 }
 
+// recordHardwareSnapshot stores the registered hardware of the machine as a new revision of its hardware history
+// if it differs from the latest revision and removes revisions exceeding the history limit.
+func (b *BootService) recordHardwareSnapshot(m *metal.Machine) error {
+	var snapshots metal.HardwareSnapshots
+	err := b.ds.SearchHardwareSnapshots(&datastore.HardwareSnapshotSearchQuery{MachineID: &m.ID}, &snapshots)
+	if err != nil {
+		return err
+	}
+
+	snapshot := &metal.HardwareSnapshot{
+		MachineID: m.ID,
+		Revision:  1,
+		SizeID:    m.SizeID,
+		Hardware:  m.Hardware,
+		BIOS:      m.BIOS,
+		Fru:       m.IPMI.Fru,
+	}
+
+	if previous := snapshots.Latest(); previous != nil {
+		snapshot.Revision = previous.Revision + 1
+		snapshot.Changes = metal.DiffHardware(previous, snapshot)
+
+		if len(snapshot.Changes) == 0 {
+			return nil
+		}
+	}
+
+	err = b.ds.CreateHardwareSnapshot(snapshot)
+	if err != nil {
+		return err
+	}
+
+	if snapshot.Revision > 1 {
+		b.log.Warn("hardware of machine changed", "machineID", m.ID, "revision", snapshot.Revision, "changes", snapshot.Changes)
+	}
+
+	for _, s := range snapshots {
+		if s.Revision > snapshot.Revision-metal.MaxHardwareSnapshots {
+			continue
+		}
+		err = b.ds.DeleteHardwareSnapshot(&s)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
 func (b *BootService) SuperUserPassword(ctx context.Context, req *v1.BootServiceSuperUserPasswordRequest) (*v1.BootServiceSuperUserPasswordResponse, error) {
 	b.log.Info("superuserpassword", "req", req)
 	defer ctx.Done()
@@ -9,10 +9,10 @@ import (
 	"testing"
 
 	"github.com/metal-stack/metal-api/cmd/metal-api/internal/datastore"
+	"github.com/metal-stack/metal-api/cmd/metal-api/internal/eventbus"
 	"github.com/metal-stack/metal-api/cmd/metal-api/internal/metal"
 	"github.com/metal-stack/metal-api/cmd/metal-api/internal/testdata"
 	v1 "github.com/metal-stack/metal-api/pkg/api/v1"
-	"github.com/metal-stack/metal-lib/bus"
 	"github.com/stretchr/testify/require"
 	r "gopkg.in/rethinkdb/rethinkdb-go.v6"
 )
@@ -116,6 +116,8 @@ func TestBootService_Register(t *testing.T) {
 			mock.On(r.DB("mockdb").Table("switch").Filter(r.MockAnything(), r.FilterOpts{})).Return([]metal.Switch{testdata.Switch1, testdata.Switch2}, nil)
 			mock.On(r.DB("mockdb").Table("event").Filter(r.MockAnything(), r.FilterOpts{})).Return([]metal.ProvisioningEventContainer{}, nil)
 			mock.On(r.DB("mockdb").Table("event").Insert(r.MockAnything(), r.InsertOpts{})).Return(testdata.EmptyResult, nil)
+			mock.On(r.DB("mockdb").Table("hardwaresnapshot").Filter(r.MockAnything(), r.FilterOpts{})).Return([]metal.HardwareSnapshot{}, nil)
+			mock.On(r.DB("mockdb").Table("hardwaresnapshot").Insert(r.MockAnything(), r.InsertOpts{})).Return(testdata.EmptyResult, nil)
 			testdata.InitMockDBData(mock)
 
 			req := &v1.BootServiceRegisterRequest{
@@ -170,7 +172,7 @@ func TestBootService_Register(t *testing.T) {
 				ds:               ds,
 				ipmiSuperUser:    metal.DisabledIPMISuperUser(),
 				publisher:        &emptyPublisher{},
-				consumer:         &bus.Consumer{},
+				consumer:         eventbus.NewMemory(slog.Default()),
 				eventService:     &EventService{},
 				queue:            sync.Map{},
 				responseInterval: 0,
@@ -196,6 +198,121 @@ func TestBootService_Register(t *testing.T) {
 	}
 }
 
+func TestBootService_Boot(t *testing.T) {
+	var (
+		ctx = context.Background()
+		log = slog.New(slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelError}))
+		ds  = datastore.NewMemory(log)
+	)
+
+	require.NoError(t, ds.CreatePartition(&metal.Partition{
+		Base: metal.Base{ID: "p1"},
+		BootConfiguration: metal.BootConfiguration{
+			ImageURL:    "http://images/metal-hammer.tgz",
+			KernelURL:   "http://images/kernel",
+			CommandLine: "console=ttyS0",
+		},
+		BootConfigurationOverrides: metal.BootConfigurationOverrides{
+			{Scope: metal.BootConfigurationOverrideScopeSize, Selector: "s1", BootConfiguration: metal.BootConfiguration{ImageURL: "http://images/metal-hammer-next.tgz"}},
+		},
+	}))
+	require.NoError(t, ds.CreateMachine(&metal.Machine{
+		Base:        metal.Base{ID: "m1"},
+		PartitionID: "p1",
+		SizeID:      "s1",
+		Hardware:    metal.MachineHardware{Nics: metal.Nics{{Name: "lan0", MacAddress: "aa:aa:aa:aa:aa:aa"}}},
+	}))
+
+	bootService := &BootService{
+		log: log,
+		ds:  ds,
+	}
+
+	resp, err := bootService.Boot(ctx, &v1.BootServiceBootRequest{Mac: "aa:aa:aa:aa:aa:aa", PartitionId: "p1"})
+	require.NoError(t, err)
+	require.Equal(t, "http://images/kernel", resp.Kernel)
+	require.Equal(t, []string{"http://images/metal-hammer-next.tgz"}, resp.InitRamDisks)
+	require.Equal(t, "console=ttyS0", resp.GetCmdline())
+	require.Equal(t, "size:s1", resp.BootConfigurationOverride)
+
+	// machines which have not registered yet get the partition defaults
+	resp, err = bootService.Boot(ctx, &v1.BootServiceBootRequest{Mac: "bb:bb:bb:bb:bb:bb", PartitionId: "p1"})
+	require.NoError(t, err)
+	require.Equal(t, []string{"http://images/metal-hammer.tgz"}, resp.InitRamDisks)
+	require.Empty(t, resp.BootConfigurationOverride)
+
+	_, err = bootService.Boot(ctx, &v1.BootServiceBootRequest{Mac: "aa:aa:aa:aa:aa:aa", PartitionId: "p2"})
+	require.Error(t, err)
+}
+
+func TestBootService_RecordHardwareSnapshot(t *testing.T) {
+	var (
+		log = slog.New(slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelError}))
+		ds  = datastore.NewMemory(log)
+	)
+
+	bootService := &BootService{
+		log: log,
+		ds:  ds,
+	}
+
+	register := func(sizeID string, memory uint64, disks ...string) {
+		m := &metal.Machine{
+			Base:   metal.Base{ID: "m1"},
+			SizeID: sizeID,
+			Hardware: metal.MachineHardware{
+				Memory:    memory,
+				MetalCPUs: []metal.MetalCPU{{Model: "Intel Xeon Silver", Cores: 8, Threads: 16}},
+			},
+			BIOS: metal.BIOS{Version: "3.3", Vendor: "Supermicro"},
+		}
+		for _, d := range disks {
+			m.Hardware.Disks = append(m.Hardware.Disks, metal.BlockDevice{Name: d, Size: 1000})
+		}
+
+		require.NoError(t, bootService.recordHardwareSnapshot(m))
+	}
+
+	register("s1", 1024, "/dev/sda", "/dev/sdb")
+	register("s1", 1024, "/dev/sda", "/dev/sdb")
+	register("unknown", 512, "/dev/sda")
+
+	var snapshots metal.HardwareSnapshots
+	require.NoError(t, ds.SearchHardwareSnapshots(&datastore.HardwareSnapshotSearchQuery{MachineID: new("m1")}, &snapshots))
+	snapshots.SortByRevision()
+
+	// unchanged hardware does not create a new revision
+	require.Len(t, snapshots, 2)
+	require.Equal(t, []int{1, 2}, []int{snapshots[0].Revision, snapshots[1].Revision})
+	require.Equal(t, "s1", snapshots[0].SizeID)
+	require.Empty(t, snapshots[0].Changes)
+	require.Equal(t, []metal.HardwareChange{
+		{Component: "size", Type: metal.HardwareChangeModified, Previous: "s1", Current: "unknown"},
+		{Component: "memory", Type: metal.HardwareChangeModified, Previous: "1024", Current: "512"},
+		{Component: "disk", Type: metal.HardwareChangeRemoved, Name: "/dev/sdb", Previous: "1000"},
+	}, snapshots[1].Changes)
+
+	// the changes are kept until they are acknowledged, no matter how often the machine registers with the same hardware
+	register("unknown", 512, "/dev/sda")
+
+	latest, err := ds.FindHardwareSnapshot("m1", 2)
+	require.NoError(t, err)
+	require.Nil(t, latest.Acknowledged)
+	require.NotEmpty(t, latest.Changes)
+
+	for i := range metal.MaxHardwareSnapshots {
+		register("unknown", uint64(2048+i), "/dev/sda")
+	}
+
+	require.NoError(t, ds.SearchHardwareSnapshots(&datastore.HardwareSnapshotSearchQuery{MachineID: new("m1")}, &snapshots))
+	require.Len(t, snapshots, metal.MaxHardwareSnapshots)
+
+	latestSnapshots, err := ds.ListLatestHardwareSnapshots()
+	require.NoError(t, err)
+	require.Len(t, latestSnapshots, 1)
+	require.Equal(t, metal.MaxHardwareSnapshots+2, latestSnapshots[0].Revision)
+}
+
 func TestBootService_Report(t *testing.T) {
 	log := slog.New(slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelError}))
 
@@ -237,7 +354,7 @@ func TestBootService_Report(t *testing.T) {
 				ds:               ds,
 				ipmiSuperUser:    metal.DisabledIPMISuperUser(),
 				publisher:        &emptyPublisher{},
-				consumer:         &bus.Consumer{},
+				consumer:         eventbus.NewMemory(slog.Default()),
 				eventService:     &EventService{},
 				queue:            sync.Map{},
 				responseInterval: 0,
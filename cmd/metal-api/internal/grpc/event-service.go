@@ -9,12 +9,13 @@ import (
 
 	"github.com/metal-stack/metal-api/cmd/metal-api/internal/datastore"
 	"github.com/metal-stack/metal-api/cmd/metal-api/internal/metal"
+	"github.com/metal-stack/metal-api/cmd/metal-api/internal/metrics"
 	v1 "github.com/metal-stack/metal-api/pkg/api/v1"
 )
 
 type EventService struct {
 	log *slog.Logger
-	ds  *datastore.RethinkStore
+	ds  datastore.Store
 }
 
 func NewEventService(cfg *ServerConfig) *EventService {
@@ -70,12 +71,13 @@ func (e *EventService) Send(ctx context.Context, req *v1.EventServiceSendRequest
 			Message: event.Message,
 		}
 
-		_, err = e.ds.ProvisioningEventForMachine(ctx, e.log, &ev, machineID)
+		ec, err := e.ds.ProvisioningEventForMachine(ctx, e.log, &ev, machineID)
 		if err != nil {
 			processErrs = append(processErrs, err)
 			failed = append(failed, machineID)
 			continue
 		}
+		metrics.ObserveProvisioningEvent(m, ec, &ev)
 		processed++
 	}
 
@@ -26,6 +26,7 @@ import (
 	"google.golang.org/grpc/status"
 
 	"github.com/metal-stack/metal-api/cmd/metal-api/internal/datastore"
+	"github.com/metal-stack/metal-api/cmd/metal-api/internal/eventbus"
 	"github.com/metal-stack/metal-api/cmd/metal-api/internal/metal"
 	"github.com/metal-stack/metal-api/cmd/metal-api/internal/metrics"
 	v1 "github.com/metal-stack/metal-api/pkg/api/v1"
@@ -42,8 +43,8 @@ const (
 type ServerConfig struct {
 	Context                  context.Context
 	Publisher                bus.Publisher
-	Consumer                 *bus.Consumer
-	Store                    *datastore.RethinkStore
+	Consumer                 eventbus.Consumer
+	Store                    datastore.Store
 	Logger                   *slog.Logger
 	Listener                 net.Listener
 	TlsEnabled               bool
@@ -55,6 +56,7 @@ type ServerConfig struct {
 	BMCSuperUserPasswordFile string
 	Auditing                 []auditing.Auditing
 	IPMISuperUser            metal.MachineIPMISuperUser
+	WatchHistorySize         int
 }
 
 func Run(cfg *ServerConfig) error {
@@ -65,7 +67,7 @@ func Run(cfg *ServerConfig) error {
 		cfg.CheckInterval = defaultCheckInterval
 	}
 	if cfg.Publisher == nil || cfg.Consumer == nil {
-		return fmt.Errorf("nsq publisher and consumer must be specified")
+		return fmt.Errorf("event bus publisher and consumer must be specified")
 	}
 
 	kaep := keepalive.EnforcementPolicy{
@@ -161,13 +163,21 @@ func Run(cfg *ServerConfig) error {
 	eventService := NewEventService(cfg)
 	bootService := NewBootService(cfg, eventService)
 
+	watchService := NewWatchService(cfg)
+
 	err := bootService.initWaitEndpoint()
 	if err != nil {
 		return err
 	}
 
+	err = watchService.start(cfg.Context)
+	if err != nil {
+		return err
+	}
+
 	v1.RegisterEventServiceServer(grpcServer, eventService)
 	v1.RegisterBootServiceServer(grpcServer, bootService)
+	v1.RegisterWatchServiceServer(grpcServer, watchService)
 
 	listener := cfg.Listener
 
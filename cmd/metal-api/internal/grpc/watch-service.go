@@ -0,0 +1,592 @@
+package grpc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/metal-stack/metal-api/cmd/metal-api/internal/datastore"
+	"github.com/metal-stack/metal-api/cmd/metal-api/internal/eventbus"
+	"github.com/metal-stack/metal-api/cmd/metal-api/internal/metal"
+	v1 "github.com/metal-stack/metal-api/pkg/api/v1"
+	"github.com/metal-stack/metal-lib/bus"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+const (
+	defaultWatchHistorySize = 10000
+	watchRestartInterval    = 5 * time.Second
+	// watchPollInterval bounds the delay of a watch in case a notification about new entries of the entity change log got lost
+	watchPollInterval = 5 * time.Second
+	watchBatchSize    = 1000
+	// the writer of the entity change log renews its claim in this interval, other instances take over once the claim expired
+	watchWriterRenewInterval = 10 * time.Second
+	watchWriterClaimDuration = 30 * time.Second
+	projectTopicTTL          = time.Duration(30) * time.Second
+
+	projectKind = "project"
+)
+
+var watchedTables = map[string]v1.EntityKind{
+	"machine": v1.EntityKind_ENTITY_KIND_MACHINE,
+	"network": v1.EntityKind_ENTITY_KIND_NETWORK,
+	"ip":      v1.EntityKind_ENTITY_KIND_IP,
+	"switch":  v1.EntityKind_ENTITY_KIND_SWITCH,
+}
+
+var changeTypes = map[metal.EventType]v1.EntityChangeType{
+	metal.CREATE: v1.EntityChangeType_ENTITY_CHANGE_TYPE_CREATED,
+	metal.UPDATE: v1.EntityChangeType_ENTITY_CHANGE_TYPE_UPDATED,
+	metal.DELETE: v1.EntityChangeType_ENTITY_CHANGE_TYPE_DELETED,
+}
+
+var (
+	errResumeTokenExpired = errors.New("resume token is expired, entities must be listed again")
+	errResumeTokenUnknown = errors.New("resume token is unknown, entities must be listed again")
+)
+
+// WatchService streams entity changes to its clients.
+//
+// The changes are kept in the entity change log of the datastore, which is shared by all metal-api instances.
+// Only one instance, the writer, observes the changes in the database and appends them to the log, all instances
+// stream the changes from the log to their clients. Therefore a client can resume its watch with any instance.
+//
+// Every change is identified by a resume token consisting of the epoch of the log and the sequence number of the change.
+// A new epoch is started whenever changes may have been lost, e.g. when another instance took over as writer
+// or the changefeed of the database broke, so clients have to list all entities again.
+//
+// Secrets of the entities like passwords and user data are never streamed to the clients.
+type WatchService struct {
+	log         *slog.Logger
+	ds          datastore.Store
+	consumer    eventbus.Consumer
+	historySize int
+	// instance identifies this metal-api instance as writer of the entity change log
+	instance string
+
+	mtx sync.Mutex
+	// writer is the claim of this instance as writer of the entity change log, it is nil if this instance is not the writer
+	writer *metal.EntityChangeLogWriter
+	seq    uint64
+	// stopWriting stops observing the changes in the database once this instance is not the writer anymore
+	stopWriting context.CancelFunc
+
+	changedMtx sync.Mutex
+	// changed is closed and replaced whenever an entry was appended to the entity change log
+	changed chan struct{}
+}
+
+func NewWatchService(cfg *ServerConfig) *WatchService {
+	historySize := cfg.WatchHistorySize
+	if historySize <= 0 {
+		historySize = defaultWatchHistorySize
+	}
+	return &WatchService{
+		log:         cfg.Logger.WithGroup("watch-service"),
+		ds:          cfg.Store,
+		consumer:    cfg.Consumer,
+		historySize: historySize,
+		instance:    uuid.NewString(),
+		changed:     make(chan struct{}),
+	}
+}
+
+func (w *WatchService) Watch(req *v1.WatchServiceWatchRequest, srv v1.WatchService_WatchServer) error {
+	kinds := map[v1.EntityKind]bool{}
+	for _, k := range req.Kinds {
+		if k == v1.EntityKind_ENTITY_KIND_UNSPECIFIED {
+			return status.Error(codes.InvalidArgument, "entity kind must be specified")
+		}
+		kinds[k] = true
+	}
+
+	epoch, seq, err := w.head()
+	if err != nil {
+		return status.Error(codes.Unavailable, err.Error())
+	}
+
+	if req.ResumeToken != nil {
+		resumeEpoch, resumeSeq, err := parseResumeToken(*req.ResumeToken)
+		if err != nil {
+			return status.Error(codes.InvalidArgument, err.Error())
+		}
+		if resumeEpoch != epoch {
+			return status.Error(codes.OutOfRange, errResumeTokenExpired.Error())
+		}
+		if resumeSeq > seq {
+			return status.Error(codes.OutOfRange, errResumeTokenUnknown.Error())
+		}
+		seq = resumeSeq
+	}
+
+	w.log.Info("watch called", "kinds", req.Kinds, "resumeToken", req.GetResumeToken())
+
+	ctx := srv.Context()
+	for {
+		changed := w.changedChannel()
+
+		entries, err := w.since(epoch, seq)
+		switch {
+		case errors.Is(err, errResumeTokenExpired):
+			return status.Error(codes.OutOfRange, err.Error())
+		case err != nil:
+			return status.Error(codes.Unavailable, err.Error())
+		}
+
+		for _, e := range entries {
+			epoch, seq = e.Epoch, e.Sequence
+
+			resp, ok := watchResponse(&e)
+			if !ok {
+				continue
+			}
+			if len(kinds) > 0 && !kinds[resp.Kind] {
+				continue
+			}
+			err := srv.Send(resp)
+			if err != nil {
+				return err
+			}
+		}
+
+		if len(entries) == watchBatchSize {
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-changed:
+		case <-time.After(watchPollInterval):
+		}
+	}
+}
+
+// start starts to observe the changes in the database and the changes of projects published by other metal-api instances
+// as long as this instance is the writer of the entity change log, and to follow the entries appended to the log.
+func (w *WatchService) start(ctx context.Context) error {
+	channel := fmt.Sprintf("watch-%s#ephemeral", uuid.NewString())
+	err := w.consumer.Consume(metal.TopicProject.Name, channel, metal.ProjectEvent{}, func(message any) error {
+		evt := message.(*metal.ProjectEvent)
+		w.log.Debug("got message", "topic", metal.TopicProject.Name, "channel", channel, "projectID", evt.ProjectID)
+		w.handleProjectEvent(evt)
+		return nil
+	}, 5, eventbus.Timeout(receiverHandlerTimeout, w.timeoutHandler), eventbus.TTL(projectTopicTTL))
+	if err != nil {
+		return err
+	}
+
+	go w.write(ctx)
+	go w.follow(ctx)
+
+	return nil
+}
+
+// write regularly claims to be the writer of the entity change log until the context is canceled.
+func (w *WatchService) write(ctx context.Context) {
+	ticker := time.NewTicker(watchWriterRenewInterval)
+	defer ticker.Stop()
+
+	for {
+		err := w.claim(ctx, time.Now())
+		if err != nil {
+			w.log.Error("unable to claim writer of entity change log", "error", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			w.resign()
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// claim makes this instance the writer of the entity change log if there is no writer or the claim of the writer expired,
+// the claim is renewed if this instance is already the writer.
+func (w *WatchService) claim(ctx context.Context, now time.Time) error {
+	w.mtx.Lock()
+	defer w.mtx.Unlock()
+
+	current, err := w.ds.FindEntityChangeLogWriter()
+	if err != nil && !metal.IsNotFound(err) {
+		return err
+	}
+
+	var claimed *metal.EntityChangeLogWriter
+	switch {
+	case current == nil:
+		claimed = &metal.EntityChangeLogWriter{
+			Instance: w.instance,
+			Epoch:    newWatchEpoch(),
+			Expires:  now.Add(watchWriterClaimDuration),
+		}
+		err = w.ds.CreateEntityChangeLogWriter(claimed)
+	case w.writer != nil && current.Instance == w.instance && current.Epoch == w.writer.Epoch:
+		renewed := *current
+		renewed.Expires = now.Add(watchWriterClaimDuration)
+		claimed = &renewed
+		err = w.ds.UpdateEntityChangeLogWriter(current, claimed)
+	case current.Expired(now):
+		// the changes since the previous writer stopped are lost, so a new epoch is started
+		taken := *current
+		taken.Instance = w.instance
+		taken.Epoch = newWatchEpoch()
+		taken.Expires = now.Add(watchWriterClaimDuration)
+		claimed = &taken
+		err = w.ds.UpdateEntityChangeLogWriter(current, claimed)
+	default:
+		w.stopWritingLocked()
+		return nil
+	}
+	if metal.IsConflict(err) {
+		// another instance claimed the writer in the meantime
+		w.stopWritingLocked()
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if w.writer == nil || w.writer.Epoch != claimed.Epoch {
+		w.log.Info("writing entity change log", "epoch", claimed.Epoch)
+		w.seq = 0
+	}
+	w.writer = claimed
+
+	if w.stopWriting == nil {
+		var writeCtx context.Context
+		writeCtx, w.stopWriting = context.WithCancel(ctx)
+		go w.watchDatastore(writeCtx)
+	}
+
+	if w.seq > uint64(w.historySize) {
+		_, err = w.ds.DeleteEntityChangesBefore(w.writer.Epoch, w.seq-uint64(w.historySize)+1)
+		if err != nil {
+			w.log.Error("unable to trim entity change log", "error", err)
+		}
+	}
+
+	return nil
+}
+
+// resign gives up the claim as writer, such that another instance can take over immediately.
+func (w *WatchService) resign() {
+	w.mtx.Lock()
+	defer w.mtx.Unlock()
+
+	if w.writer == nil {
+		return
+	}
+
+	current, err := w.ds.FindEntityChangeLogWriter()
+	if err == nil && current.Instance == w.instance && current.Epoch == w.writer.Epoch {
+		resigned := *current
+		resigned.Expires = time.Now()
+		err = w.ds.UpdateEntityChangeLogWriter(current, &resigned)
+	}
+	if err != nil && !metal.IsConflict(err) {
+		w.log.Error("unable to resign as writer of entity change log", "error", err)
+	}
+
+	w.stopWritingLocked()
+}
+
+// stopWritingLocked stops appending changes to the entity change log, the caller must hold the lock.
+func (w *WatchService) stopWritingLocked() {
+	if w.writer != nil {
+		w.log.Info("stopped writing entity change log", "epoch", w.writer.Epoch)
+	}
+	if w.stopWriting != nil {
+		w.stopWriting()
+		w.stopWriting = nil
+	}
+	w.writer = nil
+	w.seq = 0
+}
+
+// restartLocked starts a new epoch of the entity change log, the caller must hold the lock.
+func (w *WatchService) restartLocked() {
+	if w.writer == nil {
+		return
+	}
+
+	current, err := w.ds.FindEntityChangeLogWriter()
+	if err != nil {
+		w.log.Error("unable to start new epoch of entity change log", "error", err)
+		w.stopWritingLocked()
+		return
+	}
+	if current.Instance != w.instance || current.Epoch != w.writer.Epoch {
+		w.stopWritingLocked()
+		return
+	}
+
+	restarted := *current
+	restarted.Epoch = newWatchEpoch()
+	err = w.ds.UpdateEntityChangeLogWriter(current, &restarted)
+	if err != nil {
+		if !metal.IsConflict(err) {
+			w.log.Error("unable to start new epoch of entity change log", "error", err)
+		}
+		w.stopWritingLocked()
+		return
+	}
+
+	w.log.Info("started new epoch of entity change log", "epoch", restarted.Epoch)
+	w.writer = &restarted
+	w.seq = 0
+}
+
+func (w *WatchService) watchDatastore(ctx context.Context) {
+	tables := make([]string, 0, len(watchedTables))
+	for t := range watchedTables {
+		tables = append(tables, t)
+	}
+	slices.Sort(tables)
+
+	for {
+		changes, err := w.ds.Watch(ctx, tables...)
+		if err != nil {
+			w.log.Error("unable to watch datastore", "retry after", watchRestartInterval, "error", err)
+		} else {
+			for change := range changes {
+				w.handleEntityChange(change)
+			}
+		}
+
+		if ctx.Err() != nil {
+			return
+		}
+
+		// changes might have been lost, clients need to start over
+		w.mtx.Lock()
+		w.restartLocked()
+		w.mtx.Unlock()
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(watchRestartInterval):
+		}
+	}
+}
+
+// follow notifies the watchers whenever an entry was appended to the entity change log until the context is canceled.
+func (w *WatchService) follow(ctx context.Context) {
+	for {
+		entries, err := w.ds.Watch(ctx, "entitychange")
+		if err != nil {
+			w.log.Error("unable to follow entity change log", "retry after", watchRestartInterval, "error", err)
+		} else {
+			for range entries {
+				w.notify()
+			}
+		}
+
+		if ctx.Err() != nil {
+			return
+		}
+
+		// entries might have been appended in the meantime
+		w.notify()
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(watchRestartInterval):
+		}
+	}
+}
+
+func (w *WatchService) handleEntityChange(change datastore.EntityChange) {
+	if _, ok := watchedTables[change.Table]; !ok {
+		return
+	}
+
+	entity := change.New
+	if entity == nil {
+		entity = change.Old
+	}
+
+	raw, err := json.Marshal(withoutSecrets(entity))
+	if err != nil {
+		w.log.Error("unable to marshal changed entity", "table", change.Table, "id", change.ID(), "error", err)
+		return
+	}
+
+	w.append(&metal.EntityChangeLogEntry{
+		Kind:     change.Table,
+		Type:     change.Type,
+		EntityID: change.ID(),
+		Entity:   raw,
+	})
+}
+
+// withoutSecrets returns a copy of the given entity in which all secrets are removed.
+func withoutSecrets(entity metal.Entity) metal.Entity {
+	m, ok := entity.(*metal.Machine)
+	if !ok {
+		return entity
+	}
+
+	return m.WithoutSecrets()
+}
+
+func (w *WatchService) handleProjectEvent(evt *metal.ProjectEvent) {
+	w.append(&metal.EntityChangeLogEntry{
+		Kind:     projectKind,
+		Type:     evt.Type,
+		EntityID: evt.ProjectID,
+		Entity:   evt.Project,
+	})
+}
+
+func (w *WatchService) timeoutHandler(err bus.TimeoutError) error {
+	w.log.Error("Timeout processing event", "event", err.Event())
+	return nil
+}
+
+// append appends a change to the entity change log if this instance is the writer.
+func (w *WatchService) append(e *metal.EntityChangeLogEntry) {
+	if _, ok := changeTypes[e.Type]; !ok {
+		return
+	}
+
+	w.mtx.Lock()
+	defer w.mtx.Unlock()
+
+	now := time.Now()
+	if w.writer == nil || w.writer.Expired(now) {
+		return
+	}
+
+	e.Epoch = w.writer.Epoch
+	e.Sequence = w.seq + 1
+	e.Time = now
+
+	err := w.ds.CreateEntityChange(e)
+	if err != nil {
+		w.log.Error("unable to append change to entity change log", "kind", e.Kind, "id", e.EntityID, "error", err)
+		// the change is lost, clients need to start over
+		w.restartLocked()
+		return
+	}
+
+	w.seq = e.Sequence
+}
+
+// notify wakes up all watchers.
+func (w *WatchService) notify() {
+	w.changedMtx.Lock()
+	defer w.changedMtx.Unlock()
+
+	close(w.changed)
+	w.changed = make(chan struct{})
+}
+
+func (w *WatchService) changedChannel() <-chan struct{} {
+	w.changedMtx.Lock()
+	defer w.changedMtx.Unlock()
+	return w.changed
+}
+
+// head returns the position of the latest change in the entity change log, the epoch is empty if there was never a writer.
+func (w *WatchService) head() (string, uint64, error) {
+	writer, err := w.ds.FindEntityChangeLogWriter()
+	if err != nil {
+		if metal.IsNotFound(err) {
+			return "", 0, nil
+		}
+		return "", 0, err
+	}
+
+	latest, err := w.ds.FindLatestEntityChange(writer.Epoch)
+	if err != nil {
+		if metal.IsNotFound(err) {
+			return writer.Epoch, 0, nil
+		}
+		return "", 0, err
+	}
+
+	return writer.Epoch, latest.Sequence, nil
+}
+
+// since returns the changes after the given position, it fails if changes after this position are not contained in the log anymore.
+func (w *WatchService) since(epoch string, seq uint64) (metal.EntityChangeLogEntries, error) {
+	writer, err := w.ds.FindEntityChangeLogWriter()
+	if err != nil {
+		if metal.IsNotFound(err) && epoch == "" {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	if epoch == "" {
+		// there was no writer when the watch started, so all changes of the first epoch are new
+		epoch = writer.Epoch
+	}
+	if writer.Epoch != epoch {
+		return nil, errResumeTokenExpired
+	}
+
+	entries, err := w.ds.ListEntityChanges(epoch, seq, watchBatchSize)
+	if err != nil {
+		return nil, err
+	}
+	if len(entries) > 0 && entries[0].Sequence != seq+1 {
+		return nil, errResumeTokenExpired
+	}
+
+	return entries, nil
+}
+
+// watchResponse converts an entry of the entity change log, it returns false if the entry is of an unknown kind.
+func watchResponse(e *metal.EntityChangeLogEntry) (*v1.WatchServiceWatchResponse, bool) {
+	kind, ok := watchedTables[e.Kind]
+	if e.Kind == projectKind {
+		kind, ok = v1.EntityKind_ENTITY_KIND_PROJECT, true
+	}
+	if !ok {
+		return nil, false
+	}
+
+	return &v1.WatchServiceWatchResponse{
+		ResumeToken: resumeToken(e.Epoch, e.Sequence),
+		Kind:        kind,
+		Type:        changeTypes[e.Type],
+		Id:          e.EntityID,
+		Entity:      e.Entity,
+		Time:        timestamppb.New(e.Time),
+	}, true
+}
+
+func newWatchEpoch() string {
+	return strconv.FormatInt(time.Now().UnixNano(), 36)
+}
+
+func resumeToken(epoch string, seq uint64) string {
+	return metal.EntityChangeLogEntryID(epoch, seq)
+}
+
+func parseResumeToken(token string) (string, uint64, error) {
+	epoch, rawSeq, ok := strings.Cut(token, "-")
+	if !ok || epoch == "" {
+		return "", 0, fmt.Errorf("invalid resume token:%s", token)
+	}
+	seq, err := strconv.ParseUint(rawSeq, 10, 64)
+	if err != nil {
+		return "", 0, fmt.Errorf("invalid resume token:%s", token)
+	}
+	return epoch, seq, nil
+}
@@ -0,0 +1,209 @@
+package grpc
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/metal-stack/metal-api/cmd/metal-api/internal/datastore"
+	"github.com/metal-stack/metal-api/cmd/metal-api/internal/metal"
+	v1 "github.com/metal-stack/metal-api/pkg/api/v1"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+type testWatchServer struct {
+	grpc.ServerStream
+	ctx       context.Context
+	responses chan *v1.WatchServiceWatchResponse
+}
+
+func (s *testWatchServer) Context() context.Context {
+	return s.ctx
+}
+
+func (s *testWatchServer) Send(resp *v1.WatchServiceWatchResponse) error {
+	s.responses <- resp
+	return nil
+}
+
+func newTestWatchServer(ctx context.Context) *testWatchServer {
+	return &testWatchServer{
+		ctx:       ctx,
+		responses: make(chan *v1.WatchServiceWatchResponse, 10),
+	}
+}
+
+func (s *testWatchServer) next(t *testing.T) *v1.WatchServiceWatchResponse {
+	select {
+	case resp := <-s.responses:
+		return resp
+	case <-time.After(5 * time.Second):
+		t.Fatal("no watch response received")
+		return nil
+	}
+}
+
+func TestWatchService_Watch(t *testing.T) {
+	log := slog.New(slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelError}))
+	ds := datastore.NewMemory(log)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// two metal-api instances sharing the same datastore
+	newInstance := func() *WatchService {
+		w := NewWatchService(&ServerConfig{
+			Logger:           log,
+			Store:            ds,
+			WatchHistorySize: 2,
+		})
+		go w.follow(ctx)
+		return w
+	}
+	a, b := newInstance(), newInstance()
+
+	now := time.Now()
+	require.NoError(t, a.claim(ctx, now))
+	require.NoError(t, b.claim(ctx, now))
+	require.NotNil(t, a.writer)
+	require.Nil(t, b.writer, "only one instance writes the entity change log")
+
+	// wait until the datastore is watched by the writer
+	require.Eventually(t, func() bool {
+		require.NoError(t, ds.CreateNetwork(&metal.Network{}))
+		_, seq, err := a.head()
+		require.NoError(t, err)
+		return seq > 0
+	}, 5*time.Second, 10*time.Millisecond)
+
+	epoch, seq, err := b.head()
+	require.NoError(t, err)
+	startToken := resumeToken(epoch, seq)
+
+	srv := newTestWatchServer(ctx)
+	errs := make(chan error, 1)
+	go func() {
+		errs <- b.Watch(&v1.WatchServiceWatchRequest{Kinds: []v1.EntityKind{v1.EntityKind_ENTITY_KIND_MACHINE, v1.EntityKind_ENTITY_KIND_PROJECT}, ResumeToken: &startToken}, srv)
+	}()
+
+	m := &metal.Machine{Base: metal.Base{ID: "m1", Name: "machine"}}
+	require.NoError(t, ds.CreateMachine(m))
+
+	resp := srv.next(t)
+	require.Equal(t, v1.EntityKind_ENTITY_KIND_MACHINE, resp.Kind)
+	require.Equal(t, v1.EntityChangeType_ENTITY_CHANGE_TYPE_CREATED, resp.Type)
+	require.Equal(t, "m1", resp.Id)
+	var got metal.Machine
+	require.NoError(t, json.Unmarshal(resp.Entity, &got))
+	require.Equal(t, "machine", got.Name)
+
+	allocated := *m
+	allocated.IPMI.Password = "ipmi-secret"
+	allocated.Allocation = &metal.MachineAllocation{Project: "p1", ConsolePassword: "console-secret", UserData: "userdata", SSHPubKeys: []string{"ssh-ed25519 key"}}
+	require.NoError(t, ds.UpdateMachine(m, &allocated))
+
+	resp = srv.next(t)
+	require.Equal(t, v1.EntityChangeType_ENTITY_CHANGE_TYPE_UPDATED, resp.Type)
+	require.NotContains(t, string(resp.Entity), "secret")
+	got = metal.Machine{}
+	require.NoError(t, json.Unmarshal(resp.Entity, &got))
+	require.Equal(t, "p1", got.Allocation.Project)
+	require.Empty(t, got.IPMI.Password)
+	require.Empty(t, got.Allocation.ConsolePassword)
+	require.Empty(t, got.Allocation.UserData)
+	require.Empty(t, got.Allocation.SSHPubKeys)
+
+	// project events are received by all instances, but only the writer appends them
+	b.handleProjectEvent(&metal.ProjectEvent{Type: metal.DELETE, ProjectID: "p1"})
+	a.handleProjectEvent(&metal.ProjectEvent{Type: metal.DELETE, ProjectID: "p1"})
+
+	resp = srv.next(t)
+	require.Equal(t, v1.EntityKind_ENTITY_KIND_PROJECT, resp.Kind)
+	require.Equal(t, v1.EntityChangeType_ENTITY_CHANGE_TYPE_DELETED, resp.Type)
+	require.Equal(t, "p1", resp.Id)
+	projectToken := resp.ResumeToken
+
+	cancel()
+	require.ErrorIs(t, <-errs, context.Canceled)
+
+	// resume after the machine changes with the other instance
+	epoch, seq, err = parseResumeToken(projectToken)
+	require.NoError(t, err)
+	machineToken := resumeToken(epoch, seq-1)
+
+	srvCtx, srvCancel := context.WithCancel(context.Background())
+	srv = newTestWatchServer(srvCtx)
+	go func() {
+		errs <- a.Watch(&v1.WatchServiceWatchRequest{ResumeToken: &machineToken}, srv)
+	}()
+	resp = srv.next(t)
+	require.Equal(t, "p1", resp.Id)
+	require.Equal(t, projectToken, resp.ResumeToken)
+	srvCancel()
+	require.ErrorIs(t, <-errs, context.Canceled)
+
+	// the log is trimmed when the writer renews its claim, so old resume tokens expire
+	for range 4 {
+		a.handleProjectEvent(&metal.ProjectEvent{Type: metal.UPDATE, ProjectID: "p2"})
+	}
+	require.NoError(t, a.claim(context.Background(), now))
+	err = b.Watch(&v1.WatchServiceWatchRequest{ResumeToken: &machineToken}, newTestWatchServer(context.Background()))
+	require.Equal(t, codes.OutOfRange, status.Code(err))
+
+	// another instance takes over once the claim of the writer expired and starts a new epoch
+	latest, err := ds.FindLatestEntityChange(epoch)
+	require.NoError(t, err)
+	latestToken := latest.ID
+
+	expired := now.Add(watchWriterClaimDuration + time.Second)
+	require.NoError(t, b.claim(context.Background(), expired))
+	require.NotNil(t, b.writer)
+	require.NotEqual(t, epoch, b.writer.Epoch)
+	require.NoError(t, a.claim(context.Background(), expired))
+	require.Nil(t, a.writer)
+
+	err = a.Watch(&v1.WatchServiceWatchRequest{ResumeToken: &latestToken}, newTestWatchServer(context.Background()))
+	require.Equal(t, codes.OutOfRange, status.Code(err))
+
+	unknown := resumeToken(b.writer.Epoch, 1)
+	err = a.Watch(&v1.WatchServiceWatchRequest{ResumeToken: &unknown}, newTestWatchServer(context.Background()))
+	require.Equal(t, codes.OutOfRange, status.Code(err))
+
+	invalid := "abc"
+	err = a.Watch(&v1.WatchServiceWatchRequest{ResumeToken: &invalid}, newTestWatchServer(context.Background()))
+	require.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	err = a.Watch(&v1.WatchServiceWatchRequest{Kinds: []v1.EntityKind{v1.EntityKind_ENTITY_KIND_UNSPECIFIED}}, newTestWatchServer(context.Background()))
+	require.Equal(t, codes.InvalidArgument, status.Code(err))
+}
+
+func TestWatchService_restart(t *testing.T) {
+	ds := datastore.NewMemory(slog.Default())
+	w := NewWatchService(&ServerConfig{Logger: slog.Default(), Store: ds})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	require.NoError(t, w.claim(ctx, time.Now()))
+
+	w.handleProjectEvent(&metal.ProjectEvent{Type: metal.CREATE, ProjectID: "p1"})
+	epoch, seq, err := w.head()
+	require.NoError(t, err)
+
+	changes, err := w.since(epoch, seq-1)
+	require.NoError(t, err)
+	require.Len(t, changes, 1)
+
+	w.mtx.Lock()
+	w.restartLocked()
+	w.mtx.Unlock()
+
+	_, err = w.since(epoch, seq)
+	require.EqualError(t, err, "resume token is expired, entities must be listed again")
+}
@@ -0,0 +1,69 @@
+package issues
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/metal-stack/metal-api/cmd/metal-api/internal/metal"
+)
+
+const (
+	TypeHardwareDrift Type = "hardware-drift"
+)
+
+type (
+	issueHardwareDrift struct {
+		details string
+	}
+)
+
+func (i *issueHardwareDrift) Spec() *spec {
+	return &spec{
+		Type:        TypeHardwareDrift,
+		Severity:    SeverityMajor,
+		Description: "The hardware of the machine changed or does not match its size anymore",
+	}
+}
+
+func (i *issueHardwareDrift) Evaluate(m metal.Machine, ec metal.ProvisioningEventContainer, c *Config) bool {
+	var details []string
+
+	// the changes are reported until an operator acknowledged them
+	if s, ok := c.hardwareSnapshots[m.ID]; ok && s.Acknowledged == nil {
+		for _, change := range s.Changes {
+			details = append(details, fmt.Sprintf("- %s", hardwareChangeString(change)))
+		}
+	}
+
+	if size, ok := c.sizes[m.SizeID]; ok && !size.Matches(m.Hardware) {
+		details = append(details, fmt.Sprintf("- hardware does not match the constraints of size %s anymore", m.SizeID))
+	}
+
+	if len(details) == 0 {
+		return false
+	}
+
+	i.details = strings.Join(details, "\n")
+
+	return true
+}
+
+func (i *issueHardwareDrift) Details() string {
+	return i.details
+}
+
+func hardwareChangeString(c metal.HardwareChange) string {
+	component := c.Component
+	if c.Name != "" {
+		component = fmt.Sprintf("%s %s", c.Component, c.Name)
+	}
+
+	switch c.Type {
+	case metal.HardwareChangeAdded:
+		return fmt.Sprintf("%s was added (%s)", component, c.Current)
+	case metal.HardwareChangeRemoved:
+		return fmt.Sprintf("%s was removed (%s)", component, c.Previous)
+	default:
+		return fmt.Sprintf("%s changed from %s to %s", component, c.Previous, c.Current)
+	}
+}
@@ -24,6 +24,15 @@ type (
 		Omit []Type
 		// LastErrorThreshold specifies for how long in the past the last event error is counted as an error
 		LastErrorThreshold time.Duration
+		// Sizes are used to check whether the hardware of the machines still matches their size,
+		// if not provided this check is skipped
+		Sizes metal.Sizes
+		// HardwareSnapshots contains the latest hardware snapshots of the machines,
+		// if not provided hardware changes are not detected
+		HardwareSnapshots metal.HardwareSnapshots
+
+		sizes             metal.SizeMap
+		hardwareSnapshots map[string]metal.HardwareSnapshot
 	}
 
 	// Issue formulates an issue of a machine
@@ -106,6 +115,8 @@ func Find(c *Config) (MachineIssuesMap, error) {
 	res := MachineIssuesMap{}
 
 	ecs := c.EventContainers.ByID()
+	c.sizes = c.Sizes.ByID()
+	c.hardwareSnapshots = c.HardwareSnapshots.ByMachineID()
 
 	for _, m := range c.Machines {
 
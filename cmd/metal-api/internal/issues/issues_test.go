@@ -37,8 +37,10 @@ func TestFindIssues(t *testing.T) {
 		name string
 		only []Type
 
-		machines        func() metal.Machines
-		eventContainers func() metal.ProvisioningEventContainers
+		machines          func() metal.Machines
+		eventContainers   func() metal.ProvisioningEventContainers
+		sizes             metal.Sizes
+		hardwareSnapshots metal.HardwareSnapshots
 
 		want func(machines metal.Machines) MachineIssues
 	}{
@@ -490,6 +492,116 @@ func TestFindIssues(t *testing.T) {
 				}
 			},
 		},
+		{
+			name: "hardware drift",
+			only: []Type{TypeHardwareDrift},
+			machines: func() metal.Machines {
+				changed := machineTemplate("changed")
+
+				mismatch := machineTemplate("mismatch")
+				mismatch.SizeID = "s1"
+				mismatch.Hardware.Memory = 512
+
+				good := machineTemplate("good")
+				good.SizeID = "s1"
+				good.Hardware.Memory = 1024
+
+				acknowledged := machineTemplate("acknowledged")
+
+				return metal.Machines{
+					changed,
+					mismatch,
+					good,
+					acknowledged,
+				}
+			},
+			eventContainers: func() metal.ProvisioningEventContainers {
+				return metal.ProvisioningEventContainers{
+					eventContainerTemplate("changed"),
+					eventContainerTemplate("mismatch"),
+					eventContainerTemplate("good"),
+					eventContainerTemplate("acknowledged"),
+				}
+			},
+			sizes: metal.Sizes{
+				{Base: metal.Base{ID: "s1"}, Constraints: []metal.Constraint{{Type: metal.MemoryConstraint, Min: 1024, Max: 1024}}},
+			},
+			hardwareSnapshots: metal.HardwareSnapshots{
+				{MachineID: "changed", Revision: 1},
+				{MachineID: "changed", Revision: 2, Changes: []metal.HardwareChange{
+					{Component: "disk", Type: metal.HardwareChangeRemoved, Name: "/dev/sdb", Previous: "100"},
+					{Component: "memory", Type: metal.HardwareChangeModified, Previous: "1024", Current: "512"},
+				}},
+				{MachineID: "good", Revision: 1, Changes: []metal.HardwareChange{
+					{Component: "memory", Type: metal.HardwareChangeModified, Previous: "512", Current: "1024"},
+				}},
+				{MachineID: "good", Revision: 2},
+				{MachineID: "acknowledged", Revision: 1},
+				{MachineID: "acknowledged", Revision: 2, Acknowledged: new(time.Now()), AcknowledgedBy: "operator", Changes: []metal.HardwareChange{
+					{Component: "memory", Type: metal.HardwareChangeModified, Previous: "1024", Current: "512"},
+				}},
+			},
+			want: func(machines metal.Machines) MachineIssues {
+				return MachineIssues{
+					{
+						Machine: &machines[0],
+						Issues: Issues{
+							toIssue(&issueHardwareDrift{
+								details: "- disk /dev/sdb was removed (100)\n- memory changed from 1024 to 512",
+							}),
+						},
+					},
+					{
+						Machine: &machines[1],
+						Issues: Issues{
+							toIssue(&issueHardwareDrift{
+								details: "- hardware does not match the constraints of size s1 anymore",
+							}),
+						},
+					},
+				}
+			},
+		},
+		{
+			name: "power supply degraded",
+			only: []Type{TypePowerSupplyDegraded},
+			machines: func() metal.Machines {
+				degraded := machineTemplate("degraded")
+				degraded.IPMI.PowerSupplies = metal.PowerSupplies{
+					{Status: metal.PowerSupplyStatus{Health: "OK", State: "Enabled"}},
+					{Status: metal.PowerSupplyStatus{Health: "Critical", State: "Enabled"}},
+				}
+
+				absent := machineTemplate("absent")
+				absent.IPMI.PowerSupplies = metal.PowerSupplies{
+					{Status: metal.PowerSupplyStatus{Health: "OK", State: "Enabled"}},
+					{Status: metal.PowerSupplyStatus{Health: "Warning", State: "Absent"}},
+				}
+
+				return metal.Machines{
+					degraded,
+					absent,
+				}
+			},
+			eventContainers: func() metal.ProvisioningEventContainers {
+				return metal.ProvisioningEventContainers{
+					eventContainerTemplate("degraded"),
+					eventContainerTemplate("absent"),
+				}
+			},
+			want: func(machines metal.Machines) MachineIssues {
+				return MachineIssues{
+					{
+						Machine: &machines[0],
+						Issues: Issues{
+							toIssue(&issuePowerSupplyDegraded{
+								details: "1 of 2 power supplies are degraded:\n- health Critical, state Enabled",
+							}),
+						},
+					},
+				}
+			},
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -500,6 +612,8 @@ func TestFindIssues(t *testing.T) {
 				EventContainers:    tt.eventContainers(),
 				Only:               tt.only,
 				LastErrorThreshold: DefaultLastErrorThreshold(),
+				Sizes:              tt.sizes,
+				HardwareSnapshots:  tt.hardwareSnapshots,
 			})
 			require.NoError(t, err)
 
@@ -508,7 +622,7 @@ func TestFindIssues(t *testing.T) {
 				want = tt.want(ms)
 			}
 
-			if diff := cmp.Diff(want, got.ToList(), cmp.AllowUnexported(issueLastEventError{}, issueASNUniqueness{}, issueNonDistinctBMCIP{})); diff != "" {
+			if diff := cmp.Diff(want, got.ToList(), cmp.AllowUnexported(issueLastEventError{}, issueASNUniqueness{}, issueNonDistinctBMCIP{}, issueHardwareDrift{}, issuePowerSupplyDegraded{})); diff != "" {
 				t.Errorf("diff (+got -want):\n %s", diff)
 			}
 		})
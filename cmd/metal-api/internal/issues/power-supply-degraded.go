@@ -0,0 +1,46 @@
+package issues
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/metal-stack/metal-api/cmd/metal-api/internal/metal"
+)
+
+const (
+	TypePowerSupplyDegraded Type = "power-supply-degraded"
+)
+
+type (
+	issuePowerSupplyDegraded struct {
+		details string
+	}
+)
+
+func (i *issuePowerSupplyDegraded) Spec() *spec {
+	return &spec{
+		Type:        TypePowerSupplyDegraded,
+		Severity:    SeverityMajor,
+		Description: "The BMC reports power supplies which are not healthy",
+	}
+}
+
+func (i *issuePowerSupplyDegraded) Evaluate(m metal.Machine, ec metal.ProvisioningEventContainer, c *Config) bool {
+	degraded := m.IPMI.PowerSupplies.Degraded()
+	if len(degraded) == 0 {
+		return false
+	}
+
+	var details []string
+	for _, ps := range degraded {
+		details = append(details, fmt.Sprintf("- health %s, state %s", ps.Status.Health, ps.Status.State))
+	}
+
+	i.details = fmt.Sprintf("%d of %d power supplies are degraded:\n%s", len(degraded), len(m.IPMI.PowerSupplies), strings.Join(details, "\n"))
+
+	return true
+}
+
+func (i *issuePowerSupplyDegraded) Details() string {
+	return i.details
+}
@@ -21,6 +21,8 @@ func AllIssueTypes() []Type {
 		TypeASNUniqueness,
 		TypeNonDistinctBMCIP,
 		TypeNoEventContainer,
+		TypeHardwareDrift,
+		TypePowerSupplyDegraded,
 	}
 }
 
@@ -52,6 +54,10 @@ func NewIssueFromType(t Type) (issue, error) {
 		return &issueNonDistinctBMCIP{}, nil
 	case TypeNoEventContainer:
 		return &issueNoEventContainer{}, nil
+	case TypeHardwareDrift:
+		return &issueHardwareDrift{}, nil
+	case TypePowerSupplyDegraded:
+		return &issuePowerSupplyDegraded{}, nil
 	default:
 		return nil, fmt.Errorf("unknown issue type: %s", t)
 	}
@@ -0,0 +1,65 @@
+package metal
+
+import (
+	"cmp"
+	"encoding/json"
+	"slices"
+	"time"
+)
+
+// PendingAllocationState describes the state of a queued machine allocation.
+type PendingAllocationState string
+
+const (
+	// PendingAllocationStatePending means the allocation waits for a machine
+	PendingAllocationStatePending PendingAllocationState = "pending"
+	// PendingAllocationStateAllocating means a machine is currently being allocated for the allocation
+	PendingAllocationStateAllocating PendingAllocationState = "allocating"
+	// PendingAllocationStateFulfilled means a machine was allocated
+	PendingAllocationStateFulfilled PendingAllocationState = "fulfilled"
+	// PendingAllocationStateFailed means the allocation failed for another reason than a missing machine
+	PendingAllocationStateFailed PendingAllocationState = "failed"
+	// PendingAllocationStateExpired means no machine became available before the allocation expired
+	PendingAllocationStateExpired PendingAllocationState = "expired"
+)
+
+// A PendingAllocation is a machine allocation which is queued until a machine of the requested size
+// becomes available in the requested partition.
+type PendingAllocation struct {
+	Base
+	ProjectID   string                 `rethinkdb:"projectid" json:"projectid"`
+	PartitionID string                 `rethinkdb:"partitionid" json:"partitionid"`
+	SizeID      string                 `rethinkdb:"sizeid" json:"sizeid"`
+	Priority    int                    `rethinkdb:"priority" json:"priority"`
+	Creator     string                 `rethinkdb:"creator" json:"creator"`
+	Expires     time.Time              `rethinkdb:"expires" json:"expires"`
+	State       PendingAllocationState `rethinkdb:"state" json:"state"`
+	MachineID   string                 `rethinkdb:"machineid" json:"machineid"`
+	Message     string                 `rethinkdb:"message" json:"message"`
+	// Request contains the machine allocate request as it was given by the client
+	Request json.RawMessage `rethinkdb:"request" json:"request"`
+}
+
+// PendingAllocations is a slice of PendingAllocation
+type PendingAllocations []PendingAllocation
+
+// IsFinished returns true if the allocation will not change anymore.
+func (a *PendingAllocation) IsFinished() bool {
+	switch a.State {
+	case PendingAllocationStateFulfilled, PendingAllocationStateFailed, PendingAllocationStateExpired:
+		return true
+	default:
+		return false
+	}
+}
+
+// SortByPriority sorts the allocations in the order they are fulfilled, higher priorities first and
+// allocations with the same priority in the order they were queued.
+func (as PendingAllocations) SortByPriority() {
+	slices.SortStableFunc(as, func(a, b PendingAllocation) int {
+		if c := cmp.Compare(b.Priority, a.Priority); c != 0 {
+			return c
+		}
+		return a.Created.Compare(b.Created)
+	})
+}
@@ -0,0 +1,28 @@
+package metal
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestPendingAllocations_SortByPriority(t *testing.T) {
+	now := time.Now()
+
+	as := PendingAllocations{
+		{Base: Base{ID: "low-old", Created: now.Add(-time.Hour)}, Priority: 0},
+		{Base: Base{ID: "high-new", Created: now}, Priority: 10},
+		{Base: Base{ID: "low-new", Created: now}, Priority: 0},
+		{Base: Base{ID: "high-old", Created: now.Add(-time.Minute)}, Priority: 10},
+	}
+
+	as.SortByPriority()
+
+	var ids []string
+	for _, a := range as {
+		ids = append(ids, a.ID)
+	}
+
+	require.Equal(t, []string{"high-old", "high-new", "low-old", "low-new"}, ids)
+}
@@ -0,0 +1,140 @@
+package metal
+
+import (
+	"cmp"
+	"fmt"
+	"hash/fnv"
+	"slices"
+	"time"
+)
+
+// BootConfigurationRolloutState is the state of a boot configuration rollout.
+type BootConfigurationRolloutState string
+
+const (
+	// BootConfigurationRolloutStateRunning means the canaries boot with the new boot configuration
+	BootConfigurationRolloutStateRunning BootConfigurationRolloutState = "running"
+	// BootConfigurationRolloutStatePromoted means the new boot configuration became the boot configuration of the partition
+	BootConfigurationRolloutStatePromoted BootConfigurationRolloutState = "promoted"
+	// BootConfigurationRolloutStateRolledBack means the failure threshold was exceeded and the canaries boot with the previous configuration again
+	BootConfigurationRolloutStateRolledBack BootConfigurationRolloutState = "rolled-back"
+	// BootConfigurationRolloutStateAborted means the rollout was stopped by a user
+	BootConfigurationRolloutStateAborted BootConfigurationRolloutState = "aborted"
+)
+
+// DefaultBootConfigurationRolloutStuckTimeout is the duration after which a canary is considered stuck in preparing or registering.
+const DefaultBootConfigurationRolloutStuckTimeout = 30 * time.Minute
+
+// A BootConfigurationRollout rolls out a new boot configuration to a subset of the machines of a partition first.
+// Canaries which fail after the rollout has started are counted and the rollout is rolled back
+// if the failure rate exceeds the threshold.
+type BootConfigurationRollout struct {
+	// BootConfiguration contains the new values, empty values are taken from the boot configuration of the partition
+	BootConfiguration BootConfiguration `rethinkdb:"bootconfig" json:"bootconfig"`
+	// Canaries are the ids of the machines which boot with the new boot configuration
+	Canaries []string `rethinkdb:"canaries" json:"canaries"`
+	// FailureThreshold is the percentage of failed canaries at which the rollout is rolled back
+	FailureThreshold int `rethinkdb:"failurethreshold" json:"failurethreshold"`
+	// StuckTimeout is the duration after which a canary which is still preparing or registering counts as failed
+	StuckTimeout time.Duration                     `rethinkdb:"stucktimeout" json:"stucktimeout"`
+	State        BootConfigurationRolloutState     `rethinkdb:"state" json:"state"`
+	Failures     []BootConfigurationRolloutFailure `rethinkdb:"failures" json:"failures"`
+	Message      string                            `rethinkdb:"message" json:"message"`
+	Creator      string                            `rethinkdb:"creator" json:"creator"`
+	Started      time.Time                         `rethinkdb:"started" json:"started"`
+	Finished     *time.Time                        `rethinkdb:"finished" json:"finished"`
+}
+
+// A BootConfigurationRolloutFailure describes why a canary counts as failed.
+type BootConfigurationRolloutFailure struct {
+	MachineID string    `rethinkdb:"machineid" json:"machineid"`
+	Reason    string    `rethinkdb:"reason" json:"reason"`
+	Time      time.Time `rethinkdb:"time" json:"time"`
+}
+
+// IsCanary returns true if the machine with the given id boots with the new boot configuration.
+func (r *BootConfigurationRollout) IsCanary(machineID string) bool {
+	return slices.Contains(r.Canaries, machineID)
+}
+
+// FailureRate returns the percentage of failed canaries.
+func (r *BootConfigurationRollout) FailureRate() float64 {
+	if len(r.Canaries) == 0 {
+		return 0
+	}
+	return float64(len(r.Failures)) * 100 / float64(len(r.Canaries))
+}
+
+// ThresholdExceeded returns true if the failure rate reached the failure threshold.
+func (r *BootConfigurationRollout) ThresholdExceeded() bool {
+	return len(r.Failures) > 0 && r.FailureRate() >= float64(r.FailureThreshold)
+}
+
+// Finish ends the rollout with the given state.
+func (r *BootConfigurationRollout) Finish(state BootConfigurationRolloutState, message string, now time.Time) {
+	r.State = state
+	r.Message = message
+	r.Finished = &now
+}
+
+// EvaluateFailures returns the canaries which failed since the rollout has started.
+// A canary fails if it is in a crash loop, emitted an error event or is preparing or registering for longer than the stuck timeout.
+func (r *BootConfigurationRollout) EvaluateFailures(ecs ProvisioningEventContainerMap, now time.Time) []BootConfigurationRolloutFailure {
+	var failures []BootConfigurationRolloutFailure
+
+	for _, id := range r.Canaries {
+		ec, ok := ecs[id]
+		if !ok || len(ec.Events) == 0 {
+			continue
+		}
+
+		var (
+			latest = ec.Events[0]
+			reason string
+			at     time.Time
+		)
+
+		switch {
+		case ec.CrashLoop && ec.LastEventTime != nil && ec.LastEventTime.After(r.Started):
+			reason, at = "machine is in a provisioning crash loop", *ec.LastEventTime
+		case ec.LastErrorEvent != nil && ec.LastErrorEvent.Time.After(r.Started):
+			reason, at = fmt.Sprintf("machine emitted an error event: %s", ec.LastErrorEvent.Message), ec.LastErrorEvent.Time
+		case (latest.Event == ProvisioningEventPreparing || latest.Event == ProvisioningEventRegistering) &&
+			latest.Time.After(r.Started) && now.Sub(latest.Time) > r.StuckTimeout:
+			reason, at = fmt.Sprintf("machine is stuck in %s since %s", latest.Event, latest.Time.Format(time.RFC3339)), latest.Time
+		default:
+			continue
+		}
+
+		failures = append(failures, BootConfigurationRolloutFailure{MachineID: id, Reason: reason, Time: at})
+	}
+
+	return failures
+}
+
+// SelectCanaries returns the given percentage of the machines, rounded up.
+// The selection is stable for the same seed, such that the canaries are spread across the machines instead of
+// picking the machines with the lowest ids.
+func SelectCanaries(machineIDs []string, percentage int, seed string) []string {
+	if percentage <= 0 || len(machineIDs) == 0 {
+		return nil
+	}
+
+	count := min((len(machineIDs)*percentage+99)/100, len(machineIDs))
+
+	hash := func(id string) uint64 {
+		h := fnv.New64a()
+		_, _ = h.Write([]byte(seed + id))
+		return h.Sum64()
+	}
+
+	ids := slices.Clone(machineIDs)
+	slices.SortFunc(ids, func(a, b string) int {
+		return cmp.Compare(hash(a), hash(b))
+	})
+
+	canaries := ids[:count]
+	slices.Sort(canaries)
+
+	return canaries
+}
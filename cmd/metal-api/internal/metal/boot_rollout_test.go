@@ -0,0 +1,78 @@
+package metal
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSelectCanaries(t *testing.T) {
+	ids := []string{"m1", "m2", "m3", "m4", "m5", "m6", "m7", "m8", "m9", "m10"}
+
+	require.Empty(t, SelectCanaries(ids, 0, "seed"))
+	require.Empty(t, SelectCanaries(nil, 50, "seed"))
+	require.Equal(t, []string{"m1", "m10", "m2", "m3", "m4", "m5", "m6", "m7", "m8", "m9"}, SelectCanaries(ids, 100, "seed"))
+
+	canaries := SelectCanaries(ids, 25, "seed")
+	require.Len(t, canaries, 3, "percentage must be rounded up")
+	require.Equal(t, canaries, SelectCanaries(ids, 25, "seed"), "selection must be stable for the same seed")
+	require.Subset(t, ids, canaries)
+}
+
+func TestBootConfigurationRollout_EvaluateFailures(t *testing.T) {
+	var (
+		started = time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+		now     = started.Add(time.Hour)
+		before  = started.Add(-time.Minute)
+	)
+
+	r := &BootConfigurationRollout{
+		Canaries:         []string{"crashing", "error", "old-error", "stuck", "preparing", "waiting", "unknown"},
+		FailureThreshold: 50,
+		StuckTimeout:     30 * time.Minute,
+		State:            BootConfigurationRolloutStateRunning,
+		Started:          started,
+	}
+
+	ecs := ProvisioningEventContainerMap{
+		"crashing": {
+			Events:        ProvisioningEvents{{Time: started.Add(time.Minute), Event: ProvisioningEventPXEBooting}},
+			LastEventTime: new(started.Add(time.Minute)),
+			CrashLoop:     true,
+		},
+		"error": {
+			Events:         ProvisioningEvents{{Time: started.Add(2 * time.Minute), Event: ProvisioningEventWaiting}},
+			LastErrorEvent: &ProvisioningEvent{Time: started.Add(time.Minute), Event: ProvisioningEventCrashed, Message: "kernel panic"},
+		},
+		"old-error": {
+			Events:         ProvisioningEvents{{Time: started.Add(time.Minute), Event: ProvisioningEventWaiting}},
+			LastErrorEvent: &ProvisioningEvent{Time: before, Event: ProvisioningEventCrashed, Message: "kernel panic"},
+		},
+		"stuck": {
+			Events: ProvisioningEvents{{Time: started.Add(time.Minute), Event: ProvisioningEventRegistering}},
+		},
+		"preparing": {
+			Events: ProvisioningEvents{{Time: now.Add(-time.Minute), Event: ProvisioningEventPreparing}},
+		},
+		"waiting": {
+			Events: ProvisioningEvents{{Time: started.Add(time.Minute), Event: ProvisioningEventWaiting}},
+		},
+	}
+
+	failures := r.EvaluateFailures(ecs, now)
+	require.Len(t, failures, 3)
+	require.Equal(t, "crashing", failures[0].MachineID)
+	require.Equal(t, "error", failures[1].MachineID)
+	require.Equal(t, "machine emitted an error event: kernel panic", failures[1].Reason)
+	require.Equal(t, "stuck", failures[2].MachineID)
+	require.Equal(t, started.Add(time.Minute), failures[2].Time)
+
+	require.False(t, r.ThresholdExceeded())
+	r.Failures = failures
+	require.InDelta(t, 42.86, r.FailureRate(), 0.01)
+	require.False(t, r.ThresholdExceeded())
+
+	r.FailureThreshold = 40
+	require.True(t, r.ThresholdExceeded())
+}
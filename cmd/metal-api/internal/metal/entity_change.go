@@ -0,0 +1,46 @@
+package metal
+
+import (
+	"fmt"
+	"time"
+)
+
+// EntityChangeLogWriterID is the id of the only entity change log writer.
+const EntityChangeLogWriterID = "writer"
+
+// An EntityChangeLogEntry is a change of an entity in the entity change log, which is shared by all metal-api instances.
+// The entries of an epoch are numbered without gaps, a new epoch is started whenever changes may have been lost.
+type EntityChangeLogEntry struct {
+	Base
+	Epoch    string `rethinkdb:"epoch" json:"epoch"`
+	Sequence uint64 `rethinkdb:"sequence" json:"sequence"`
+	// Kind is the table of the changed entity or project for changes of projects
+	Kind     string    `rethinkdb:"kind" json:"kind"`
+	Type     EventType `rethinkdb:"type" json:"type"`
+	EntityID string    `rethinkdb:"entityid" json:"entityid"`
+	// Entity is the json representation of the entity after the change without its secrets
+	Entity []byte    `rethinkdb:"entity" json:"entity"`
+	Time   time.Time `rethinkdb:"time" json:"time"`
+}
+
+// EntityChangeLogEntries is a slice of EntityChangeLogEntry
+type EntityChangeLogEntries []EntityChangeLogEntry
+
+// EntityChangeLogEntryID returns the id of an entity change log entry, the ids of an epoch sort in the order of their sequence.
+func EntityChangeLogEntryID(epoch string, sequence uint64) string {
+	return fmt.Sprintf("%s-%020d", epoch, sequence)
+}
+
+// The EntityChangeLogWriter is the metal-api instance which appends the changes to the entity change log.
+// There is only one writer at a time, another instance takes over once the writer did not renew its claim until it expired.
+type EntityChangeLogWriter struct {
+	Base
+	Instance string    `rethinkdb:"instance" json:"instance"`
+	Epoch    string    `rethinkdb:"epoch" json:"epoch"`
+	Expires  time.Time `rethinkdb:"expires" json:"expires"`
+}
+
+// Expired returns true if the claim of the writer expired at the given time.
+func (w *EntityChangeLogWriter) Expired(now time.Time) bool {
+	return !now.Before(w.Expires)
+}
@@ -0,0 +1,144 @@
+package metal
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// A FirewallRuleRevision is a numbered version of the firewall rules of a firewall allocation.
+// The first revision contains the rules which were given at allocation time.
+type FirewallRuleRevision struct {
+	Base
+	MachineID      string        `rethinkdb:"machineid" json:"machineid"`
+	AllocationUUID string        `rethinkdb:"allocationuuid" json:"allocationuuid"`
+	Revision       int           `rethinkdb:"revision" json:"revision"`
+	Rules          FirewallRules `rethinkdb:"rules" json:"rules"`
+	Creator        string        `rethinkdb:"creator" json:"creator"`
+}
+
+// FirewallRuleRevisions is a slice of FirewallRuleRevision
+type FirewallRuleRevisions []FirewallRuleRevision
+
+// FirewallRuleRevisionID returns the id of a firewall rule revision, it is unique for every revision of a firewall allocation.
+func FirewallRuleRevisionID(allocationUUID string, revision int) string {
+	return fmt.Sprintf("%s-%d", allocationUUID, revision)
+}
+
+// InitialFirewallRuleRevision returns the first revision of the firewall rules of the given firewall,
+// which contains the rules given at allocation time.
+func InitialFirewallRuleRevision(fw *Machine) *FirewallRuleRevision {
+	rev := &FirewallRuleRevision{
+		MachineID:      fw.ID,
+		AllocationUUID: fw.Allocation.UUID,
+		Revision:       1,
+		Creator:        fw.Allocation.Creator,
+	}
+	if fw.Allocation.FirewallRules != nil {
+		rev.Rules = *fw.Allocation.FirewallRules
+	}
+	return rev
+}
+
+// Latest returns the revision with the highest number or nil if there are no revisions.
+func (rs FirewallRuleRevisions) Latest() *FirewallRuleRevision {
+	var latest *FirewallRuleRevision
+	for i := range rs {
+		if latest == nil || rs[i].Revision > latest.Revision {
+			latest = &rs[i]
+		}
+	}
+	return latest
+}
+
+// Get returns the revision with the given number or nil if it does not exist.
+func (rs FirewallRuleRevisions) Get(revision int) *FirewallRuleRevision {
+	for i := range rs {
+		if rs[i].Revision == revision {
+			return &rs[i]
+		}
+	}
+	return nil
+}
+
+// FirewallRulesDiff contains the rules which were added or removed between two sets of firewall rules.
+type FirewallRulesDiff struct {
+	AddedEgress    []EgressRule
+	RemovedEgress  []EgressRule
+	AddedIngress   []IngressRule
+	RemovedIngress []IngressRule
+}
+
+// Diff returns the rules which need to be added to and removed from the rules to get to the given rules.
+// Rules are compared by their content, so a modified rule shows up as removed and added.
+func (r *FirewallRules) Diff(to *FirewallRules) FirewallRulesDiff {
+	var from FirewallRules
+	if r != nil {
+		from = *r
+	}
+	var target FirewallRules
+	if to != nil {
+		target = *to
+	}
+
+	var diff FirewallRulesDiff
+	diff.AddedEgress, diff.RemovedEgress = diffRules(from.Egress, target.Egress, EgressRule.key)
+	diff.AddedIngress, diff.RemovedIngress = diffRules(from.Ingress, target.Ingress, IngressRule.key)
+	return diff
+}
+
+// IsEmpty returns true if there are no differences.
+func (d FirewallRulesDiff) IsEmpty() bool {
+	return len(d.AddedEgress) == 0 && len(d.RemovedEgress) == 0 && len(d.AddedIngress) == 0 && len(d.RemovedIngress) == 0
+}
+
+func diffRules[R any](from, to []R, key func(R) string) (added, removed []R) {
+	remaining := map[string]int{}
+	for _, r := range from {
+		remaining[key(r)]++
+	}
+
+	for _, r := range to {
+		k := key(r)
+		if remaining[k] > 0 {
+			remaining[k]--
+			continue
+		}
+		added = append(added, r)
+	}
+
+	for _, r := range from {
+		k := key(r)
+		if remaining[k] > 0 {
+			remaining[k]--
+			removed = append(removed, r)
+		}
+	}
+
+	return added, removed
+}
+
+func (r EgressRule) key() string {
+	return strings.Join([]string{string(r.Protocol), portsKey(r.Ports), cidrsKey(r.To), "", r.Comment}, "|")
+}
+
+func (r IngressRule) key() string {
+	return strings.Join([]string{string(r.Protocol), portsKey(r.Ports), cidrsKey(r.To), cidrsKey(r.From), r.Comment}, "|")
+}
+
+func portsKey(ports []int) string {
+	sorted := slices.Clone(ports)
+	slices.Sort(sorted)
+	var res []string
+	for _, p := range sorted {
+		res = append(res, strconv.Itoa(p))
+	}
+	return strings.Join(res, ",")
+}
+
+func cidrsKey(cidrs []string) string {
+	sorted := slices.Clone(cidrs)
+	slices.Sort(sorted)
+	return strings.Join(sorted, ",")
+}
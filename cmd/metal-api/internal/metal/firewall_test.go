@@ -0,0 +1,70 @@
+package metal
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestFirewallRules_Diff(t *testing.T) {
+	var (
+		https = EgressRule{Protocol: ProtocolTCP, Ports: []int{443}, To: []string{"0.0.0.0/0"}, Comment: "https"}
+		dns   = EgressRule{Protocol: ProtocolUDP, Ports: []int{53}, To: []string{"1.1.1.1/32", "8.8.8.8/32"}, Comment: "dns"}
+		ssh   = IngressRule{Protocol: ProtocolTCP, Ports: []int{22}, To: []string{"10.0.0.1/32"}, From: []string{"192.168.0.0/16"}, Comment: "ssh"}
+	)
+
+	tests := []struct {
+		name string
+		from *FirewallRules
+		to   *FirewallRules
+		want FirewallRulesDiff
+	}{
+		{
+			name: "both empty",
+			want: FirewallRulesDiff{},
+		},
+		{
+			name: "rules added",
+			to:   &FirewallRules{Egress: []EgressRule{https}, Ingress: []IngressRule{ssh}},
+			want: FirewallRulesDiff{AddedEgress: []EgressRule{https}, AddedIngress: []IngressRule{ssh}},
+		},
+		{
+			name: "rules removed",
+			from: &FirewallRules{Egress: []EgressRule{https, dns}, Ingress: []IngressRule{ssh}},
+			to:   &FirewallRules{Egress: []EgressRule{dns}},
+			want: FirewallRulesDiff{RemovedEgress: []EgressRule{https}, RemovedIngress: []IngressRule{ssh}},
+		},
+		{
+			name: "order of rules, ports and cidrs does not matter",
+			from: &FirewallRules{Egress: []EgressRule{https, dns}},
+			to: &FirewallRules{Egress: []EgressRule{
+				{Protocol: ProtocolUDP, Ports: []int{53}, To: []string{"8.8.8.8/32", "1.1.1.1/32"}, Comment: "dns"},
+				https,
+			}},
+			want: FirewallRulesDiff{},
+		},
+		{
+			name: "modified rule is removed and added",
+			from: &FirewallRules{Egress: []EgressRule{https}},
+			to:   &FirewallRules{Egress: []EgressRule{{Protocol: ProtocolTCP, Ports: []int{443, 8443}, To: []string{"0.0.0.0/0"}, Comment: "https"}}},
+			want: FirewallRulesDiff{
+				AddedEgress:   []EgressRule{{Protocol: ProtocolTCP, Ports: []int{443, 8443}, To: []string{"0.0.0.0/0"}, Comment: "https"}},
+				RemovedEgress: []EgressRule{https},
+			},
+		},
+		{
+			name: "duplicate rules are counted",
+			from: &FirewallRules{Egress: []EgressRule{https, https}},
+			to:   &FirewallRules{Egress: []EgressRule{https}},
+			want: FirewallRulesDiff{RemovedEgress: []EgressRule{https}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tt.from.Diff(tt.to)
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Errorf("FirewallRules.Diff() diff = %s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,180 @@
+package metal
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+// HardwareChangeType describes how a hardware component changed between two snapshots.
+type HardwareChangeType string
+
+const (
+	// HardwareChangeAdded means the component was not present in the previous snapshot
+	HardwareChangeAdded HardwareChangeType = "added"
+	// HardwareChangeRemoved means the component is not present anymore
+	HardwareChangeRemoved HardwareChangeType = "removed"
+	// HardwareChangeModified means the component is still present but its properties changed
+	HardwareChangeModified HardwareChangeType = "modified"
+)
+
+// MaxHardwareSnapshots limits the amount of hardware snapshots which are kept per machine.
+const MaxHardwareSnapshots = 100
+
+// A HardwareChange describes the change of a single hardware component.
+type HardwareChange struct {
+	// Component is the kind of the changed component, e.g. memory, disk, nic, cpu, gpu, bios, fru or size
+	Component string             `rethinkdb:"component" json:"component"`
+	Type      HardwareChangeType `rethinkdb:"type" json:"type"`
+	// Name identifies the component, e.g. the name of a disk or nic
+	Name     string `rethinkdb:"name" json:"name"`
+	Previous string `rethinkdb:"previous" json:"previous"`
+	Current  string `rethinkdb:"current" json:"current"`
+}
+
+// A HardwareSnapshot is the hardware of a machine as it was reported on a registration which changed the hardware.
+type HardwareSnapshot struct {
+	Base
+	MachineID string          `rethinkdb:"machineid" json:"machineid"`
+	Revision  int             `rethinkdb:"revision" json:"revision"`
+	SizeID    string          `rethinkdb:"sizeid" json:"sizeid"`
+	Hardware  MachineHardware `rethinkdb:"hardware" json:"hardware"`
+	BIOS      BIOS            `rethinkdb:"bios" json:"bios"`
+	Fru       Fru             `rethinkdb:"fru" json:"fru"`
+	// Changes contains the differences to the previous snapshot
+	Changes []HardwareChange `rethinkdb:"changes" json:"changes"`
+	// Acknowledged is set once an operator acknowledged the changes, they are reported as hardware drift until then
+	Acknowledged   *time.Time `rethinkdb:"acknowledged" json:"acknowledged"`
+	AcknowledgedBy string     `rethinkdb:"acknowledgedby" json:"acknowledgedby"`
+}
+
+// HardwareSnapshots is a slice of HardwareSnapshot
+type HardwareSnapshots []HardwareSnapshot
+
+// HardwareSnapshotID returns the id of a hardware snapshot, it is unique for every revision of a machine.
+func HardwareSnapshotID(machineID string, revision int) string {
+	return fmt.Sprintf("%s-%d", machineID, revision)
+}
+
+// Latest returns the snapshot with the highest revision or nil if there are no snapshots.
+func (hs HardwareSnapshots) Latest() *HardwareSnapshot {
+	var latest *HardwareSnapshot
+	for i := range hs {
+		if latest == nil || hs[i].Revision > latest.Revision {
+			latest = &hs[i]
+		}
+	}
+	return latest
+}
+
+// ByMachineID returns the latest snapshot of every machine with the machine id as the index.
+func (hs HardwareSnapshots) ByMachineID() map[string]HardwareSnapshot {
+	res := map[string]HardwareSnapshot{}
+	for _, s := range hs {
+		if latest, ok := res[s.MachineID]; !ok || s.Revision > latest.Revision {
+			res[s.MachineID] = s
+		}
+	}
+	return res
+}
+
+// SortByRevision sorts the snapshots by their revision in ascending order.
+func (hs HardwareSnapshots) SortByRevision() {
+	slices.SortFunc(hs, func(a, b HardwareSnapshot) int {
+		return a.Revision - b.Revision
+	})
+}
+
+// DiffHardware returns the changes from the previous to the current snapshot.
+func DiffHardware(previous, current *HardwareSnapshot) []HardwareChange {
+	var changes []HardwareChange
+
+	modified := func(component, name, prev, cur string) {
+		if prev != cur {
+			changes = append(changes, HardwareChange{Component: component, Type: HardwareChangeModified, Name: name, Previous: prev, Current: cur})
+		}
+	}
+
+	modified("size", "", previous.SizeID, current.SizeID)
+	modified("memory", "", fmt.Sprintf("%d", previous.Hardware.Memory), fmt.Sprintf("%d", current.Hardware.Memory))
+
+	prevDisks := map[string]BlockDevice{}
+	for _, d := range previous.Hardware.Disks {
+		prevDisks[d.Name] = d
+	}
+	curDisks := map[string]BlockDevice{}
+	for _, d := range current.Hardware.Disks {
+		curDisks[d.Name] = d
+	}
+	changes = append(changes, diffComponents("disk", prevDisks, curDisks, func(d BlockDevice) string { return fmt.Sprintf("%d", d.Size) })...)
+
+	prevNics := map[string]Nic{}
+	for _, n := range previous.Hardware.Nics {
+		prevNics[n.Name] = n
+	}
+	curNics := map[string]Nic{}
+	for _, n := range current.Hardware.Nics {
+		curNics[n.Name] = n
+	}
+	changes = append(changes, diffComponents("nic", prevNics, curNics, func(n Nic) string { return string(n.MacAddress) })...)
+
+	modified("cpu", "", cpuSummary(previous.Hardware.MetalCPUs), cpuSummary(current.Hardware.MetalCPUs))
+	modified("gpu", "", gpuSummary(previous.Hardware.MetalGPUs), gpuSummary(current.Hardware.MetalGPUs))
+
+	modified("bios", "version", previous.BIOS.Version, current.BIOS.Version)
+	modified("fru", "board_mfg_serial", previous.Fru.BoardMfgSerial, current.Fru.BoardMfgSerial)
+	modified("fru", "product_serial", previous.Fru.ProductSerial, current.Fru.ProductSerial)
+
+	return changes
+}
+
+func diffComponents[T any](component string, previous, current map[string]T, value func(T) string) []HardwareChange {
+	var changes []HardwareChange
+
+	for _, name := range sortedKeys(previous) {
+		prev := value(previous[name])
+		cur, ok := current[name]
+		switch {
+		case !ok:
+			changes = append(changes, HardwareChange{Component: component, Type: HardwareChangeRemoved, Name: name, Previous: prev})
+		case prev != value(cur):
+			changes = append(changes, HardwareChange{Component: component, Type: HardwareChangeModified, Name: name, Previous: prev, Current: value(cur)})
+		}
+	}
+
+	for _, name := range sortedKeys(current) {
+		if _, ok := previous[name]; !ok {
+			changes = append(changes, HardwareChange{Component: component, Type: HardwareChangeAdded, Name: name, Current: value(current[name])})
+		}
+	}
+
+	return changes
+}
+
+func sortedKeys[T any](m map[string]T) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	return keys
+}
+
+func cpuSummary(cpus []MetalCPU) string {
+	var res []string
+	for _, c := range cpus {
+		res = append(res, fmt.Sprintf("%s %s (%d cores, %d threads)", c.Vendor, c.Model, c.Cores, c.Threads))
+	}
+	slices.Sort(res)
+	return strings.Join(res, ", ")
+}
+
+func gpuSummary(gpus []MetalGPU) string {
+	var res []string
+	for _, g := range gpus {
+		res = append(res, fmt.Sprintf("%s %s", g.Vendor, g.Model))
+	}
+	slices.Sort(res)
+	return strings.Join(res, ", ")
+}
@@ -0,0 +1,66 @@
+package metal
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDiffHardware(t *testing.T) {
+	previous := &HardwareSnapshot{
+		SizeID: "c1-large-x86",
+		Hardware: MachineHardware{
+			Memory: 1024,
+			Disks:  []BlockDevice{{Name: "/dev/sda", Size: 100}, {Name: "/dev/sdb", Size: 100}},
+			Nics:   Nics{{Name: "lan0", MacAddress: "aa:aa"}, {Name: "lan1", MacAddress: "bb:bb"}},
+			MetalCPUs: []MetalCPU{
+				{Vendor: "Intel", Model: "Xeon", Cores: 8, Threads: 16},
+			},
+		},
+		BIOS: BIOS{Version: "1.0"},
+		Fru:  Fru{ProductSerial: "123"},
+	}
+
+	require.Empty(t, DiffHardware(previous, previous))
+
+	current := &HardwareSnapshot{
+		SizeID: "c1-large-x86",
+		Hardware: MachineHardware{
+			Memory: 1024,
+			Disks:  []BlockDevice{{Name: "/dev/sda", Size: 50}, {Name: "/dev/sdc", Size: 100}},
+			Nics:   Nics{{Name: "lan1", MacAddress: "cc:cc"}, {Name: "lan0", MacAddress: "aa:aa"}},
+			MetalCPUs: []MetalCPU{
+				{Vendor: "Intel", Model: "Xeon", Cores: 8, Threads: 16},
+			},
+			MetalGPUs: []MetalGPU{{Vendor: "NVIDIA", Model: "H100"}},
+		},
+		BIOS: BIOS{Version: "1.1"},
+		Fru:  Fru{ProductSerial: "123"},
+	}
+
+	require.Equal(t, []HardwareChange{
+		{Component: "disk", Type: HardwareChangeModified, Name: "/dev/sda", Previous: "100", Current: "50"},
+		{Component: "disk", Type: HardwareChangeRemoved, Name: "/dev/sdb", Previous: "100"},
+		{Component: "disk", Type: HardwareChangeAdded, Name: "/dev/sdc", Current: "100"},
+		{Component: "nic", Type: HardwareChangeModified, Name: "lan1", Previous: "bb:bb", Current: "cc:cc"},
+		{Component: "gpu", Type: HardwareChangeModified, Current: "NVIDIA H100"},
+		{Component: "bios", Type: HardwareChangeModified, Name: "version", Previous: "1.0", Current: "1.1"},
+	}, DiffHardware(previous, current))
+}
+
+func TestHardwareSnapshots_Latest(t *testing.T) {
+	require.Nil(t, HardwareSnapshots{}.Latest())
+
+	hs := HardwareSnapshots{
+		{MachineID: "m1", Revision: 2},
+		{MachineID: "m1", Revision: 3},
+		{MachineID: "m1", Revision: 1},
+	}
+	require.Equal(t, 3, hs.Latest().Revision)
+
+	hs = append(hs, HardwareSnapshot{MachineID: "m2", Revision: 1})
+	byMachine := hs.ByMachineID()
+	require.Len(t, byMachine, 2)
+	require.Equal(t, 3, byMachine["m1"].Revision)
+	require.Equal(t, 1, byMachine["m2"].Revision)
+}
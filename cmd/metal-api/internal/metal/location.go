@@ -0,0 +1,154 @@
+package metal
+
+import (
+	"fmt"
+	"slices"
+)
+
+// LocationLevel is the level of a location within the topology of the data centers.
+type LocationLevel string
+
+const (
+	// LocationLevelRegion is the top level of the location hierarchy
+	LocationLevelRegion LocationLevel = "region"
+	// LocationLevelDatacenter is a data center within a region
+	LocationLevelDatacenter LocationLevel = "datacenter"
+	// LocationLevelRow is a room or row of racks within a data center
+	LocationLevelRow LocationLevel = "row"
+	// LocationLevelRack is a rack within a row, machines and switches reference their rack by its id
+	LocationLevelRack LocationLevel = "rack"
+	// LocationLevelPowerFeed is a power feed which supplies racks, it is orthogonal to the location hierarchy
+	LocationLevelPowerFeed LocationLevel = "power-feed"
+)
+
+// LocationHierarchy contains the levels of the location hierarchy, the outermost level first.
+var LocationHierarchy = []LocationLevel{
+	LocationLevelRegion,
+	LocationLevelDatacenter,
+	LocationLevelRow,
+	LocationLevelRack,
+}
+
+// AllLocationLevels contains all location levels.
+var AllLocationLevels = append(slices.Clone(LocationHierarchy), LocationLevelPowerFeed)
+
+// LocationLevelFrom returns the location level for the given name.
+func LocationLevelFrom(name string) (LocationLevel, error) {
+	for _, l := range AllLocationLevels {
+		if string(l) == name {
+			return l, nil
+		}
+	}
+	return "", fmt.Errorf("unknown location level: %q", name)
+}
+
+// parentLevel returns the level the parent of a location with this level must have.
+// power feeds can optionally belong to a data center.
+func (l LocationLevel) parentLevel() (LocationLevel, bool) {
+	if l == LocationLevelPowerFeed {
+		return LocationLevelDatacenter, false
+	}
+	i := slices.Index(LocationHierarchy, l)
+	if i <= 0 {
+		return "", false
+	}
+	return LocationHierarchy[i-1], true
+}
+
+// Location is a failure domain within the topology of the data centers. Locations form a hierarchy from regions
+// down to racks, power feeds are an orthogonal failure domain which is referenced by the racks they supply.
+type Location struct {
+	Base
+	Level    LocationLevel `rethinkdb:"level" json:"level"`
+	ParentID string        `rethinkdb:"parentid" json:"parentid"`
+	// PowerFeedID is the power feed which supplies the rack, it can only be set for racks
+	PowerFeedID string `rethinkdb:"powerfeedid" json:"powerfeedid"`
+}
+
+// Locations is a list of locations.
+type Locations []Location
+
+// LocationMap is an indexed map of locations.
+type LocationMap map[string]Location
+
+// ByID creates a map of locations with the id as the index.
+func (ls Locations) ByID() LocationMap {
+	res := make(LocationMap)
+	for i, l := range ls {
+		res[l.ID] = ls[i]
+	}
+	return res
+}
+
+// Validate checks that the location fits into the hierarchy of the given locations.
+func (lm LocationMap) Validate(l *Location) error {
+	if l.ID == "" {
+		return fmt.Errorf("location id must not be empty")
+	}
+
+	if _, err := LocationLevelFrom(string(l.Level)); err != nil {
+		return err
+	}
+
+	parentLevel, parentRequired := l.Level.parentLevel()
+	switch {
+	case l.ParentID != "":
+		if parentLevel == "" {
+			return fmt.Errorf("a location of level %s cannot have a parent", l.Level)
+		}
+		parent, ok := lm[l.ParentID]
+		if !ok {
+			return NotFound("parent location %q does not exist", l.ParentID)
+		}
+		if parent.Level != parentLevel {
+			return fmt.Errorf("the parent of a location of level %s must be of level %s, but %q is of level %s", l.Level, parentLevel, parent.ID, parent.Level)
+		}
+	case parentRequired:
+		return fmt.Errorf("a location of level %s requires a parent of level %s", l.Level, parentLevel)
+	}
+
+	if l.PowerFeedID != "" {
+		if l.Level != LocationLevelRack {
+			return fmt.Errorf("only locations of level %s can be supplied by a power feed", LocationLevelRack)
+		}
+		feed, ok := lm[l.PowerFeedID]
+		if !ok {
+			return NotFound("power feed %q does not exist", l.PowerFeedID)
+		}
+		if feed.Level != LocationLevelPowerFeed {
+			return fmt.Errorf("location %q is not a power feed", feed.ID)
+		}
+	}
+
+	return nil
+}
+
+// Domain returns the failure domain of the given rack at the given level. Racks without a location are their own failure domain
+// at rack level, at all other levels the domain is empty if the rack or one of its ancestors has no location.
+func (lm LocationMap) Domain(rackID string, level LocationLevel) string {
+	if level == "" || level == LocationLevelRack {
+		return rackID
+	}
+
+	l, ok := lm[rackID]
+	if !ok || l.Level != LocationLevelRack {
+		return ""
+	}
+
+	if level == LocationLevelPowerFeed {
+		return l.PowerFeedID
+	}
+
+	// the levels of the ancestors are validated to be ascending, so the walk is bounded by the depth of the hierarchy
+	for range LocationHierarchy {
+		l, ok = lm[l.ParentID]
+		if !ok {
+			return ""
+		}
+		if l.Level == level {
+			return l.ID
+		}
+	}
+
+	return ""
+}
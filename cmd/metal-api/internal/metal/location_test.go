@@ -0,0 +1,66 @@
+package metal
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testLocations() LocationMap {
+	return Locations{
+		{Base: Base{ID: "eu"}, Level: LocationLevelRegion},
+		{Base: Base{ID: "fra1"}, Level: LocationLevelDatacenter, ParentID: "eu"},
+		{Base: Base{ID: "fra1-a"}, Level: LocationLevelRow, ParentID: "fra1"},
+		{Base: Base{ID: "feed-a"}, Level: LocationLevelPowerFeed, ParentID: "fra1"},
+		{Base: Base{ID: "feed-b"}, Level: LocationLevelPowerFeed},
+		{Base: Base{ID: "rack-1"}, Level: LocationLevelRack, ParentID: "fra1-a", PowerFeedID: "feed-a"},
+		{Base: Base{ID: "rack-2"}, Level: LocationLevelRack, ParentID: "fra1-a"},
+	}.ByID()
+}
+
+func TestLocationMap_Validate(t *testing.T) {
+	tests := []struct {
+		name     string
+		location Location
+		wantErr  string
+	}{
+		{name: "region", location: Location{Base: Base{ID: "us"}, Level: LocationLevelRegion}},
+		{name: "rack", location: Location{Base: Base{ID: "rack-3"}, Level: LocationLevelRack, ParentID: "fra1-a", PowerFeedID: "feed-b"}},
+		{name: "power feed without data center", location: Location{Base: Base{ID: "feed-c"}, Level: LocationLevelPowerFeed}},
+		{name: "missing id", location: Location{Level: LocationLevelRegion}, wantErr: "location id must not be empty"},
+		{name: "unknown level", location: Location{Base: Base{ID: "x"}, Level: "room"}, wantErr: `unknown location level: "room"`},
+		{name: "region with parent", location: Location{Base: Base{ID: "x"}, Level: LocationLevelRegion, ParentID: "eu"}, wantErr: "a location of level region cannot have a parent"},
+		{name: "row without parent", location: Location{Base: Base{ID: "x"}, Level: LocationLevelRow}, wantErr: "a location of level row requires a parent of level datacenter"},
+		{name: "rack in data center", location: Location{Base: Base{ID: "x"}, Level: LocationLevelRack, ParentID: "fra1"}, wantErr: `the parent of a location of level rack must be of level row, but "fra1" is of level datacenter`},
+		{name: "missing parent", location: Location{Base: Base{ID: "x"}, Level: LocationLevelRack, ParentID: "fra1-b"}, wantErr: `NotFound parent location "fra1-b" does not exist`},
+		{name: "row with power feed", location: Location{Base: Base{ID: "x"}, Level: LocationLevelRow, ParentID: "fra1", PowerFeedID: "feed-a"}, wantErr: "only locations of level rack can be supplied by a power feed"},
+		{name: "power feed is no power feed", location: Location{Base: Base{ID: "x"}, Level: LocationLevelRack, ParentID: "fra1-a", PowerFeedID: "rack-2"}, wantErr: `location "rack-2" is not a power feed`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := testLocations().Validate(&tt.location)
+			if tt.wantErr == "" {
+				require.NoError(t, err)
+				return
+			}
+			require.EqualError(t, err, tt.wantErr)
+		})
+	}
+}
+
+func TestLocationMap_Domain(t *testing.T) {
+	lm := testLocations()
+
+	assert.Equal(t, "rack-1", lm.Domain("rack-1", LocationLevelRack))
+	assert.Equal(t, "fra1-a", lm.Domain("rack-1", LocationLevelRow))
+	assert.Equal(t, "fra1", lm.Domain("rack-1", LocationLevelDatacenter))
+	assert.Equal(t, "eu", lm.Domain("rack-1", LocationLevelRegion))
+	assert.Equal(t, "feed-a", lm.Domain("rack-1", LocationLevelPowerFeed))
+	assert.Empty(t, lm.Domain("rack-2", LocationLevelPowerFeed))
+
+	// racks without a location are only known at rack level
+	assert.Equal(t, "rack-9", lm.Domain("rack-9", LocationLevelRack))
+	assert.Equal(t, "rack-9", LocationMap(nil).Domain("rack-9", ""))
+	assert.Empty(t, lm.Domain("rack-9", LocationLevelDatacenter))
+}
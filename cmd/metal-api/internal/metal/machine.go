@@ -125,6 +125,7 @@ type Machine struct {
 	Tags         []string                `rethinkdb:"tags" json:"tags"`
 	IPMI         IPMI                    `rethinkdb:"ipmi" json:"ipmi"`
 	BIOS         BIOS                    `rethinkdb:"bios" json:"bios"`
+	Maintenance  *MachineMaintenance     `rethinkdb:"maintenance" json:"maintenance"`
 }
 
 // Machines is a slice of Machine
@@ -164,6 +165,8 @@ type MachineAllocation struct {
 	FirewallRules    *FirewallRules    `rethinkdb:"firewall_rules" json:"firewall_rules"`
 	DNSServers       DNSServers        `rethinkdb:"dns_servers" json:"dns_servers"`
 	NTPServers       NTPServers        `rethinkdb:"ntp_servers" json:"ntp_servers"`
+	// Lease is set if the machine is only allocated for a limited duration
+	Lease *MachineLease `rethinkdb:"lease" json:"lease"`
 }
 
 type FirewallRules struct {
@@ -668,6 +671,21 @@ func (m *Machine) HasMAC(mac string) bool {
 	return false
 }
 
+// WithoutSecrets returns a copy of the machine in which the ipmi password and the secrets of the allocation are removed.
+func (m *Machine) WithoutSecrets() *Machine {
+	redacted := *m
+	redacted.IPMI.Password = ""
+	if m.Allocation != nil {
+		allocation := *m.Allocation
+		allocation.ConsolePassword = ""
+		allocation.UserData = ""
+		allocation.SSHPubKeys = nil
+		redacted.Allocation = &allocation
+	}
+
+	return &redacted
+}
+
 // A MachineCommand is an alias of a string
 type MachineCommand string
 
@@ -692,6 +710,8 @@ const (
 // is an optional array of strings which are implementation specific
 // and dependent of the command.
 type MachineExecCommand struct {
+	// ID identifies the execution of the command, the metal-bmc reports the status of the execution with it
+	ID              string          `json:"id,omitempty"`
 	TargetMachineID string          `json:"target,omitempty"`
 	Command         MachineCommand  `json:"cmd,omitempty"`
 	IPMI            *IPMI           `json:"ipmi,omitempty"`
@@ -704,6 +724,38 @@ type MachineEvent struct {
 	OldMachineID string              `json:"old,omitempty"`
 	NewMachineID string              `json:"new,omitempty"`
 	Cmd          *MachineExecCommand `json:"cmd,omitempty"`
+	// DeduplicationID is set if the event is published through the outbox, an event with the same id may be delivered more than once
+	DeduplicationID string `json:"deduplicationid,omitempty"`
+}
+
+// WithDeduplicationID returns a copy of the event with the given deduplication id.
+func (e MachineEvent) WithDeduplicationID(id string) any {
+	e.DeduplicationID = id
+	return e
+}
+
+// WithoutSecrets returns a copy of the event in which the ipmi password of the command is removed,
+// along with the id of the machine whose ipmi credentials need to be resolved when the event is delivered.
+func (e MachineEvent) WithoutSecrets() (any, string) {
+	if e.Cmd == nil || e.Cmd.IPMI == nil {
+		return e, ""
+	}
+
+	cmd := *e.Cmd
+	ipmi := *e.Cmd.IPMI
+	ipmi.Password = ""
+	cmd.IPMI = &ipmi
+	e.Cmd = &cmd
+
+	return e, cmd.TargetMachineID
+}
+
+// MachineCommandID returns the id of the command execution, which is empty if the event does not carry a command.
+func (e MachineEvent) MachineCommandID() string {
+	if e.Cmd == nil {
+		return ""
+	}
+	return e.Cmd.ID
 }
 
 // AllocationEvent is propagated when a machine is allocated.
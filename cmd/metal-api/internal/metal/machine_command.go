@@ -0,0 +1,164 @@
+package metal
+
+import (
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// MachineCommandStatus is the execution status of a machine command.
+type MachineCommandStatus string
+
+const (
+	// MachineCommandStatusQueued is the status of commands which were published but not yet received by the metal-bmc
+	MachineCommandStatusQueued MachineCommandStatus = "queued"
+	// MachineCommandStatusDelivered is the status of commands which were received by the metal-bmc
+	MachineCommandStatusDelivered MachineCommandStatus = "delivered"
+	// MachineCommandStatusSucceeded is the status of commands which were executed successfully
+	MachineCommandStatusSucceeded MachineCommandStatus = "succeeded"
+	// MachineCommandStatusFailed is the status of commands whose execution failed or which could not be published
+	MachineCommandStatusFailed MachineCommandStatus = "failed"
+	// MachineCommandStatusTimedOut is the status of commands whose result was not reported before their deadline
+	MachineCommandStatusTimedOut MachineCommandStatus = "timed-out"
+)
+
+// AllMachineCommandStatuses contains all machine command statuses.
+var AllMachineCommandStatuses = []MachineCommandStatus{
+	MachineCommandStatusQueued,
+	MachineCommandStatusDelivered,
+	MachineCommandStatusSucceeded,
+	MachineCommandStatusFailed,
+	MachineCommandStatusTimedOut,
+}
+
+// MachineCommandStatusFrom returns the machine command status for the given name.
+func MachineCommandStatusFrom(name string) (MachineCommandStatus, error) {
+	for _, s := range AllMachineCommandStatuses {
+		if string(s) == name {
+			return s, nil
+		}
+	}
+	return "", fmt.Errorf("unknown machine command status: %q", name)
+}
+
+// Final returns true if no further status is expected for the command.
+func (s MachineCommandStatus) Final() bool {
+	return s == MachineCommandStatusSucceeded || s == MachineCommandStatusFailed || s == MachineCommandStatusTimedOut
+}
+
+const (
+	// DefaultMachineCommandTimeout is the duration in which the metal-bmc is expected to report the result of a command
+	DefaultMachineCommandTimeout = 5 * time.Minute
+	// FirmwareUpdateMachineCommandTimeout is the duration in which the metal-bmc is expected to report the result of a firmware update
+	FirmwareUpdateMachineCommandTimeout = time.Hour
+)
+
+// Timeout returns the duration in which the metal-bmc is expected to report the result of the command.
+func (c MachineCommand) Timeout() time.Duration {
+	if c == UpdateFirmwareCmd {
+		return FirmwareUpdateMachineCommandTimeout
+	}
+	return DefaultMachineCommandTimeout
+}
+
+// A MachineCommandExecution tracks the execution of a command which was sent to the metal-bmc,
+// its id is sent along with the command, such that the metal-bmc can report the status back.
+type MachineCommandExecution struct {
+	Base
+	MachineID string               `rethinkdb:"machineid" json:"machineid"`
+	Command   MachineCommand       `rethinkdb:"command" json:"command"`
+	Status    MachineCommandStatus `rethinkdb:"status" json:"status"`
+	Message   string               `rethinkdb:"message" json:"message"`
+	// Deadline is the time until which a final status is expected, the command times out afterwards.
+	// It is restarted when the command is delivered to the event bus by the outbox.
+	Deadline  time.Time  `rethinkdb:"deadline" json:"deadline"`
+	Delivered *time.Time `rethinkdb:"delivered" json:"delivered"`
+	Finished  *time.Time `rethinkdb:"finished" json:"finished"`
+}
+
+// MachineCommandExecutions is a list of machine command executions.
+type MachineCommandExecutions []MachineCommandExecution
+
+// NewMachineCommandExecution returns a queued execution of the given command.
+func NewMachineCommandExecution(machineID string, cmd MachineCommand, now time.Time) *MachineCommandExecution {
+	return &MachineCommandExecution{
+		Base:      Base{ID: uuid.NewString()},
+		MachineID: machineID,
+		Command:   cmd,
+		Status:    MachineCommandStatusQueued,
+		Deadline:  now.Add(cmd.Timeout()),
+	}
+}
+
+// Published restarts the deadline of a queued command at the time it was published to the event bus,
+// such that the time the command waited in the outbox is not counted. It returns true if the deadline changed.
+func (e *MachineCommandExecution) Published(now time.Time) bool {
+	if e.Status != MachineCommandStatusQueued {
+		return false
+	}
+	deadline := now.Add(e.Command.Timeout())
+	if !deadline.After(e.Deadline) {
+		return false
+	}
+	e.Deadline = deadline
+	return true
+}
+
+// Report applies the status reported by the metal-bmc. Results which arrive after the command timed out are still accepted,
+// but a command which already succeeded or failed cannot change anymore.
+func (e *MachineCommandExecution) Report(status MachineCommandStatus, message string, now time.Time) error {
+	switch status {
+	case MachineCommandStatusDelivered, MachineCommandStatusSucceeded, MachineCommandStatusFailed:
+	case MachineCommandStatusQueued, MachineCommandStatusTimedOut:
+		return fmt.Errorf("status %q cannot be reported", status)
+	default:
+		return fmt.Errorf("unknown machine command status: %q", status)
+	}
+
+	if e.Status == MachineCommandStatusSucceeded || e.Status == MachineCommandStatusFailed {
+		if e.Status == status || status == MachineCommandStatusDelivered {
+			// the report was repeated or the delivery was reported after the result
+			return nil
+		}
+		return Conflict("command %s already %s", e.ID, e.Status)
+	}
+
+	if status == MachineCommandStatusDelivered {
+		if e.Delivered == nil {
+			e.Delivered = &now
+		}
+		if e.Status == MachineCommandStatusQueued {
+			e.Status = status
+			e.Message = message
+		}
+		return nil
+	}
+
+	if e.Delivered == nil {
+		e.Delivered = &now
+	}
+	e.Status = status
+	e.Message = message
+	e.Finished = &now
+	return nil
+}
+
+// Expire marks the command as timed out if no final status was reported before its deadline, it returns true if it timed out.
+func (e *MachineCommandExecution) Expire(now time.Time) bool {
+	if e.Status.Final() || !now.After(e.Deadline) {
+		return false
+	}
+	e.Status = MachineCommandStatusTimedOut
+	e.Message = fmt.Sprintf("no result was reported within %s", e.Command.Timeout())
+	e.Finished = &now
+	return true
+}
+
+// SortNewestFirst sorts the executions by their creation, the latest first.
+func (es MachineCommandExecutions) SortNewestFirst() {
+	slices.SortStableFunc(es, func(a, b MachineCommandExecution) int {
+		return b.Created.Compare(a.Created)
+	})
+}
@@ -0,0 +1,79 @@
+package metal
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMachineCommandExecution_Report(t *testing.T) {
+	now := time.Now()
+
+	e := NewMachineCommandExecution("m1", MachineOnCmd, now)
+	require.NotEmpty(t, e.ID)
+	assert.Equal(t, MachineCommandStatusQueued, e.Status)
+	assert.Equal(t, now.Add(DefaultMachineCommandTimeout), e.Deadline)
+
+	require.Error(t, e.Report(MachineCommandStatusQueued, "", now))
+	require.Error(t, e.Report(MachineCommandStatusTimedOut, "", now))
+
+	require.NoError(t, e.Report(MachineCommandStatusDelivered, "", now))
+	assert.Equal(t, MachineCommandStatusDelivered, e.Status)
+	assert.Equal(t, &now, e.Delivered)
+	assert.Nil(t, e.Finished)
+
+	later := now.Add(time.Minute)
+	require.NoError(t, e.Report(MachineCommandStatusSucceeded, "ok", later))
+	assert.Equal(t, MachineCommandStatusSucceeded, e.Status)
+	assert.Equal(t, "ok", e.Message)
+	assert.Equal(t, &now, e.Delivered)
+	assert.Equal(t, &later, e.Finished)
+
+	// a delivery report which arrives late does not reset the result
+	require.NoError(t, e.Report(MachineCommandStatusDelivered, "", later))
+	assert.Equal(t, MachineCommandStatusSucceeded, e.Status)
+
+	require.NoError(t, e.Report(MachineCommandStatusSucceeded, "ok", later))
+	err := e.Report(MachineCommandStatusFailed, "ipmi unreachable", later)
+	require.Error(t, err)
+	assert.True(t, IsConflict(err))
+}
+
+func TestMachineCommandExecution_Published(t *testing.T) {
+	now := time.Now()
+
+	e := NewMachineCommandExecution("m1", MachineOnCmd, now)
+
+	// the time the command waited in the outbox is not counted
+	published := now.Add(10 * time.Minute)
+	assert.True(t, e.Published(published))
+	assert.Equal(t, published.Add(DefaultMachineCommandTimeout), e.Deadline)
+	assert.False(t, e.Expire(now.Add(DefaultMachineCommandTimeout+time.Minute)))
+
+	// a repeated delivery does not shorten the deadline
+	assert.False(t, e.Published(now))
+	assert.Equal(t, published.Add(DefaultMachineCommandTimeout), e.Deadline)
+
+	require.NoError(t, e.Report(MachineCommandStatusDelivered, "", published))
+	assert.False(t, e.Published(published.Add(time.Minute)))
+}
+
+func TestMachineCommandExecution_Expire(t *testing.T) {
+	now := time.Now()
+
+	e := NewMachineCommandExecution("m1", UpdateFirmwareCmd, now)
+	assert.Equal(t, now.Add(FirmwareUpdateMachineCommandTimeout), e.Deadline)
+
+	assert.False(t, e.Expire(now.Add(30*time.Minute)))
+	assert.True(t, e.Expire(now.Add(2*time.Hour)))
+	assert.Equal(t, MachineCommandStatusTimedOut, e.Status)
+	assert.NotNil(t, e.Finished)
+	assert.False(t, e.Expire(now.Add(3*time.Hour)))
+
+	// results which arrive after the timeout are still accepted
+	require.NoError(t, e.Report(MachineCommandStatusFailed, "bmc reset", now.Add(3*time.Hour)))
+	assert.Equal(t, MachineCommandStatusFailed, e.Status)
+	assert.Equal(t, "bmc reset", e.Message)
+}
@@ -0,0 +1,102 @@
+package metal
+
+import (
+	"fmt"
+	"time"
+)
+
+const (
+	// MachineLeaseMinDuration is the shortest duration a machine can be leased for
+	MachineLeaseMinDuration = 10 * time.Minute
+	// MachineLeaseMaxDuration is the longest duration a machine can be leased for at once, leases can be renewed though
+	MachineLeaseMaxDuration = 90 * 24 * time.Hour
+	// MachineLeaseReapTimeout is the duration after which the claim to free a machine with an expired lease can be taken over,
+	// in case the metal-api instance which claimed it stopped before the machine was freed
+	MachineLeaseReapTimeout = 10 * time.Minute
+)
+
+// A MachineLease limits the duration of a machine allocation, the machine is freed when the lease expires unless it is renewed before.
+type MachineLease struct {
+	Duration time.Duration `rethinkdb:"duration" json:"duration"`
+	Expires  time.Time     `rethinkdb:"expires" json:"expires"`
+	// Warned is set when the warning about the upcoming expiry was published, it is reset on renewal
+	Warned *time.Time `rethinkdb:"warned" json:"warned"`
+	// Reaping is set when a metal-api instance claimed the expired lease to free the machine, the lease cannot be renewed anymore
+	Reaping *time.Time `rethinkdb:"reaping" json:"reaping"`
+}
+
+// NewMachineLease returns a lease of the given duration which starts now.
+func NewMachineLease(duration time.Duration, now time.Time) (*MachineLease, error) {
+	l := &MachineLease{}
+	err := l.Renew(duration, now)
+	if err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+// Renew extends the lease by the given duration starting from now.
+func (l *MachineLease) Renew(duration time.Duration, now time.Time) error {
+	if duration < MachineLeaseMinDuration || duration > MachineLeaseMaxDuration {
+		return fmt.Errorf("lease duration must be between %s and %s", MachineLeaseMinDuration, MachineLeaseMaxDuration)
+	}
+	l.Duration = duration
+	l.Expires = now.Add(duration)
+	l.Warned = nil
+	return nil
+}
+
+// Expired returns true if the lease expired.
+func (l *MachineLease) Expired(now time.Time) bool {
+	return !now.Before(l.Expires)
+}
+
+// Expiring returns true if the lease expires within the given duration and no warning was published yet.
+func (l *MachineLease) Expiring(within time.Duration, now time.Time) bool {
+	return l.Warned == nil && !l.Expired(now) && now.Add(within).After(l.Expires)
+}
+
+// Reapable returns true if the lease expired and the machine is not being freed by another metal-api instance.
+func (l *MachineLease) Reapable(now time.Time) bool {
+	return l.Expired(now) && (l.Reaping == nil || !now.Before(l.Reaping.Add(MachineLeaseReapTimeout)))
+}
+
+// MachineLeaseEventType is the type of a machine lease event.
+type MachineLeaseEventType string
+
+const (
+	// MachineLeaseExpiring is published once when a lease is about to expire
+	MachineLeaseExpiring MachineLeaseEventType = "expiring"
+	// MachineLeaseExpired is published when the machine of an expired lease is freed
+	MachineLeaseExpired MachineLeaseEventType = "expired"
+)
+
+// A MachineLeaseEvent notifies about the upcoming or past expiry of a machine lease.
+type MachineLeaseEvent struct {
+	Type           MachineLeaseEventType `json:"type"`
+	MachineID      string                `json:"machineid"`
+	ProjectID      string                `json:"projectid"`
+	AllocationUUID string                `json:"allocationuuid"`
+	Hostname       string                `json:"hostname"`
+	Expires        time.Time             `json:"expires"`
+	// DeduplicationID is set if the event is published through the outbox, an event with the same id may be delivered more than once
+	DeduplicationID string `json:"deduplicationid,omitempty"`
+}
+
+// NewMachineLeaseEvent returns a lease event for the given leased machine.
+func NewMachineLeaseEvent(t MachineLeaseEventType, m *Machine) MachineLeaseEvent {
+	return MachineLeaseEvent{
+		Type:           t,
+		MachineID:      m.ID,
+		ProjectID:      m.Allocation.Project,
+		AllocationUUID: m.Allocation.UUID,
+		Hostname:       m.Allocation.Hostname,
+		Expires:        m.Allocation.Lease.Expires,
+	}
+}
+
+// WithDeduplicationID returns a copy of the event with the given deduplication id.
+func (e MachineLeaseEvent) WithDeduplicationID(id string) any {
+	e.DeduplicationID = id
+	return e
+}
@@ -0,0 +1,44 @@
+package metal
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMachineLease(t *testing.T) {
+	now := time.Now()
+
+	_, err := NewMachineLease(time.Minute, now)
+	require.EqualError(t, err, "lease duration must be between 10m0s and 2160h0m0s")
+
+	l, err := NewMachineLease(2*time.Hour, now)
+	require.NoError(t, err)
+	assert.Equal(t, now.Add(2*time.Hour), l.Expires)
+
+	assert.False(t, l.Expiring(time.Hour, now))
+	assert.True(t, l.Expiring(time.Hour, now.Add(90*time.Minute)))
+	assert.False(t, l.Expired(now.Add(90*time.Minute)))
+	assert.True(t, l.Expired(now.Add(2*time.Hour)))
+	assert.False(t, l.Expiring(time.Hour, now.Add(2*time.Hour)), "expired leases are not expiring anymore")
+
+	l.Warned = new(now.Add(90 * time.Minute))
+	assert.False(t, l.Expiring(time.Hour, now.Add(90*time.Minute)), "the warning is only sent once")
+
+	require.NoError(t, l.Renew(3*time.Hour, now.Add(90*time.Minute)))
+	assert.Nil(t, l.Warned)
+	assert.Equal(t, 3*time.Hour, l.Duration)
+	assert.Equal(t, now.Add(270*time.Minute), l.Expires)
+
+	require.Error(t, l.Renew(100*24*time.Hour, now))
+
+	expired := now.Add(270 * time.Minute)
+	assert.False(t, l.Reapable(expired.Add(-time.Second)))
+	assert.True(t, l.Reapable(expired))
+
+	l.Reaping = &expired
+	assert.False(t, l.Reapable(expired.Add(time.Minute)), "another instance frees the machine")
+	assert.True(t, l.Reapable(expired.Add(MachineLeaseReapTimeout)), "the claim of a stopped instance is taken over")
+}
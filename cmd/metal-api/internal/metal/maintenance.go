@@ -0,0 +1,132 @@
+package metal
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// MaintenanceScope defines which machines are targeted by a maintenance window.
+type MaintenanceScope string
+
+const (
+	// MaintenanceScopeMachine targets a single machine
+	MaintenanceScopeMachine MaintenanceScope = "machine"
+	// MaintenanceScopeRack targets all machines of a rack in a partition
+	MaintenanceScopeRack MaintenanceScope = "rack"
+	// MaintenanceScopePartition targets all machines of a partition
+	MaintenanceScopePartition MaintenanceScope = "partition"
+)
+
+// A MaintenanceWindow is a period of time in which the targeted machines are tainted,
+// such that they are not considered for allocation.
+type MaintenanceWindow struct {
+	Base
+	Scope       MaintenanceScope `rethinkdb:"scope" json:"scope"`
+	PartitionID string           `rethinkdb:"partitionid" json:"partitionid"`
+	RackID      string           `rethinkdb:"rackid" json:"rackid"`
+	MachineID   string           `rethinkdb:"machineid" json:"machineid"`
+	Start       time.Time        `rethinkdb:"start" json:"start"`
+	End         time.Time        `rethinkdb:"end" json:"end"`
+	Reason      string           `rethinkdb:"reason" json:"reason"`
+	Issuer      string           `rethinkdb:"issuer" json:"issuer"`
+}
+
+// MaintenanceWindows is a slice of MaintenanceWindow
+type MaintenanceWindows []MaintenanceWindow
+
+// MachineMaintenance is set on a machine while it is tainted by a maintenance window.
+type MachineMaintenance struct {
+	WindowID      string       `rethinkdb:"windowid" json:"windowid"`
+	PreviousState MachineState `rethinkdb:"previousstate" json:"previousstate"`
+}
+
+// MaintenanceScopeFrom converts a maintenance scope string to the type
+func MaintenanceScopeFrom(name string) (MaintenanceScope, error) {
+	switch name {
+	case string(MaintenanceScopeMachine):
+		return MaintenanceScopeMachine, nil
+	case string(MaintenanceScopeRack):
+		return MaintenanceScopeRack, nil
+	case string(MaintenanceScopePartition):
+		return MaintenanceScopePartition, nil
+	default:
+		return "", fmt.Errorf("unknown maintenance scope:%s", name)
+	}
+}
+
+// Validate checks if the maintenance window is well-formed.
+func (w *MaintenanceWindow) Validate() error {
+	switch w.Scope {
+	case MaintenanceScopeMachine:
+		if w.MachineID == "" {
+			return errors.New("machine id must be specified for a maintenance window with machine scope")
+		}
+	case MaintenanceScopeRack:
+		if w.PartitionID == "" || w.RackID == "" {
+			return errors.New("partition id and rack id must be specified for a maintenance window with rack scope")
+		}
+	case MaintenanceScopePartition:
+		if w.PartitionID == "" {
+			return errors.New("partition id must be specified for a maintenance window with partition scope")
+		}
+	default:
+		return fmt.Errorf("unknown maintenance scope:%s", w.Scope)
+	}
+
+	if w.Start.IsZero() || w.End.IsZero() {
+		return errors.New("start and end of a maintenance window must be specified")
+	}
+	if !w.End.After(w.Start) {
+		return errors.New("end of a maintenance window must be after its start")
+	}
+	if w.Reason == "" {
+		return errors.New("a reason must be specified for a maintenance window")
+	}
+
+	return nil
+}
+
+// IsActive returns true if the maintenance window is active at the given point in time.
+func (w *MaintenanceWindow) IsActive(now time.Time) bool {
+	return !now.Before(w.Start) && now.Before(w.End)
+}
+
+// Targets returns true if the given machine is in the scope of the maintenance window.
+func (w *MaintenanceWindow) Targets(m *Machine) bool {
+	switch w.Scope {
+	case MaintenanceScopeMachine:
+		return m.ID == w.MachineID
+	case MaintenanceScopeRack:
+		return m.PartitionID == w.PartitionID && m.RackID == w.RackID
+	case MaintenanceScopePartition:
+		return m.PartitionID == w.PartitionID
+	default:
+		return false
+	}
+}
+
+// ActiveFor returns the maintenance window which is active for the given machine at the given point in time.
+// If multiple windows are active, the one ending last is returned. If no window is active, nil is returned.
+func (ws MaintenanceWindows) ActiveFor(m *Machine, now time.Time) *MaintenanceWindow {
+	var active *MaintenanceWindow
+	for i := range ws {
+		w := &ws[i]
+		if !w.IsActive(now) || !w.Targets(m) {
+			continue
+		}
+		if active == nil || w.End.After(active.End) {
+			active = w
+		}
+	}
+	return active
+}
+
+// ByID creates a map of maintenance windows with the id as the index.
+func (ws MaintenanceWindows) ByID() map[string]*MaintenanceWindow {
+	res := make(map[string]*MaintenanceWindow)
+	for i, w := range ws {
+		res[w.ID] = &ws[i]
+	}
+	return res
+}
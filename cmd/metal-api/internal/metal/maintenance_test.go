@@ -0,0 +1,136 @@
+package metal
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/metal-stack/metal-lib/pkg/testcommon"
+)
+
+func TestMaintenanceWindow_Validate(t *testing.T) {
+	var (
+		start = time.Date(2026, 1, 1, 8, 0, 0, 0, time.UTC)
+		end   = start.Add(4 * time.Hour)
+	)
+
+	tests := []struct {
+		name    string
+		w       *MaintenanceWindow
+		wantErr error
+	}{
+		{
+			name: "valid machine window",
+			w:    &MaintenanceWindow{Scope: MaintenanceScopeMachine, MachineID: "m1", Start: start, End: end, Reason: "firmware"},
+		},
+		{
+			name: "valid rack window",
+			w:    &MaintenanceWindow{Scope: MaintenanceScopeRack, PartitionID: "p1", RackID: "r1", Start: start, End: end, Reason: "firmware"},
+		},
+		{
+			name:    "unknown scope",
+			w:       &MaintenanceWindow{Scope: "region", Start: start, End: end, Reason: "firmware"},
+			wantErr: errors.New("unknown maintenance scope:region"),
+		},
+		{
+			name:    "machine scope without machine",
+			w:       &MaintenanceWindow{Scope: MaintenanceScopeMachine, Start: start, End: end, Reason: "firmware"},
+			wantErr: errors.New("machine id must be specified for a maintenance window with machine scope"),
+		},
+		{
+			name:    "rack scope without partition",
+			w:       &MaintenanceWindow{Scope: MaintenanceScopeRack, RackID: "r1", Start: start, End: end, Reason: "firmware"},
+			wantErr: errors.New("partition id and rack id must be specified for a maintenance window with rack scope"),
+		},
+		{
+			name:    "partition scope without partition",
+			w:       &MaintenanceWindow{Scope: MaintenanceScopePartition, Start: start, End: end, Reason: "firmware"},
+			wantErr: errors.New("partition id must be specified for a maintenance window with partition scope"),
+		},
+		{
+			name:    "end before start",
+			w:       &MaintenanceWindow{Scope: MaintenanceScopePartition, PartitionID: "p1", Start: end, End: start, Reason: "firmware"},
+			wantErr: errors.New("end of a maintenance window must be after its start"),
+		},
+		{
+			name:    "no reason",
+			w:       &MaintenanceWindow{Scope: MaintenanceScopePartition, PartitionID: "p1", Start: start, End: end},
+			wantErr: errors.New("a reason must be specified for a maintenance window"),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.w.Validate()
+			if diff := cmp.Diff(tt.wantErr, err, testcommon.ErrorStringComparer()); diff != "" {
+				t.Errorf("error diff (+got -want):\n %s", diff)
+			}
+		})
+	}
+}
+
+func TestMaintenanceWindows_ActiveFor(t *testing.T) {
+	var (
+		now = time.Date(2026, 1, 1, 8, 0, 0, 0, time.UTC)
+		m   = &Machine{Base: Base{ID: "m1"}, PartitionID: "p1", RackID: "r1"}
+	)
+
+	tests := []struct {
+		name string
+		ws   MaintenanceWindows
+		want string
+	}{
+		{
+			name: "no windows",
+			want: "",
+		},
+		{
+			name: "window in the past",
+			ws: MaintenanceWindows{
+				{Base: Base{ID: "w1"}, Scope: MaintenanceScopeMachine, MachineID: "m1", Start: now.Add(-2 * time.Hour), End: now},
+			},
+			want: "",
+		},
+		{
+			name: "window in the future",
+			ws: MaintenanceWindows{
+				{Base: Base{ID: "w1"}, Scope: MaintenanceScopeMachine, MachineID: "m1", Start: now.Add(time.Minute), End: now.Add(time.Hour)},
+			},
+			want: "",
+		},
+		{
+			name: "window for another rack",
+			ws: MaintenanceWindows{
+				{Base: Base{ID: "w1"}, Scope: MaintenanceScopeRack, PartitionID: "p1", RackID: "r2", Start: now, End: now.Add(time.Hour)},
+			},
+			want: "",
+		},
+		{
+			name: "active rack window",
+			ws: MaintenanceWindows{
+				{Base: Base{ID: "w1"}, Scope: MaintenanceScopeRack, PartitionID: "p1", RackID: "r1", Start: now, End: now.Add(time.Hour)},
+			},
+			want: "w1",
+		},
+		{
+			name: "overlapping windows, the one ending last wins",
+			ws: MaintenanceWindows{
+				{Base: Base{ID: "w1"}, Scope: MaintenanceScopeMachine, MachineID: "m1", Start: now.Add(-time.Hour), End: now.Add(time.Hour)},
+				{Base: Base{ID: "w2"}, Scope: MaintenanceScopePartition, PartitionID: "p1", Start: now.Add(-time.Hour), End: now.Add(2 * time.Hour)},
+			},
+			want: "w2",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tt.ws.ActiveFor(m, now)
+			var id string
+			if got != nil {
+				id = got.ID
+			}
+			if id != tt.want {
+				t.Errorf("MaintenanceWindows.ActiveFor() = %q, want %q", id, tt.want)
+			}
+		})
+	}
+}
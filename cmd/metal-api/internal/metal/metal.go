@@ -55,8 +55,10 @@ const (
 )
 
 var (
-	TopicMachine    = NSQTopic{Name: "machine", PartitionAgnostic: true}
-	TopicAllocation = NSQTopic{Name: "allocation", PartitionAgnostic: false}
+	TopicMachine      = NSQTopic{Name: "machine", PartitionAgnostic: true}
+	TopicAllocation   = NSQTopic{Name: "allocation", PartitionAgnostic: false}
+	TopicProject      = NSQTopic{Name: "project", PartitionAgnostic: false}
+	TopicMachineLease = NSQTopic{Name: "machine-lease", PartitionAgnostic: false}
 )
 
 // Topics is a list of topics of which the metal-api is a producer.
@@ -64,6 +66,8 @@ var (
 var Topics = []NSQTopic{
 	TopicMachine,
 	TopicAllocation,
+	TopicProject,
+	TopicMachineLease,
 }
 
 // GetFQN gets the fully qualified name of a NSQTopic
@@ -0,0 +1,153 @@
+package metal
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// OutboxEventState is the delivery state of an outbox event.
+type OutboxEventState string
+
+const (
+	// OutboxEventStatePending is the state of events which are not yet published to the event bus
+	OutboxEventStatePending OutboxEventState = "pending"
+	// OutboxEventStatePublished is the state of events which were published to the event bus
+	OutboxEventStatePublished OutboxEventState = "published"
+	// OutboxEventStateFailed is the state of events which could not be published within the maximum attempts, they need to be replayed
+	OutboxEventStateFailed OutboxEventState = "failed"
+)
+
+// AllOutboxEventStates contains all outbox event states.
+var AllOutboxEventStates = []OutboxEventState{OutboxEventStatePending, OutboxEventStatePublished, OutboxEventStateFailed}
+
+const (
+	outboxInitialBackoff = 5 * time.Second
+	outboxMaxBackoff     = 5 * time.Minute
+)
+
+// An OutboxEvent is an event which is stored in the datastore before it is published to the event bus,
+// such that it is not lost when the event bus is unavailable or the metal-api stops before the event was published.
+// Its id is used as deduplication id, an event may be published more than once.
+type OutboxEvent struct {
+	Base
+	Topic string `rethinkdb:"topic" json:"topic"`
+	// Payload is the json representation of the event
+	Payload     string           `rethinkdb:"payload" json:"payload"`
+	State       OutboxEventState `rethinkdb:"state" json:"state"`
+	Attempts    int              `rethinkdb:"attempts" json:"attempts"`
+	NextAttempt time.Time        `rethinkdb:"nextattempt" json:"nextattempt"`
+	LastError   string           `rethinkdb:"lasterror" json:"lasterror"`
+	Published   *time.Time       `rethinkdb:"published" json:"published"`
+	// IPMIMachineID is the id of the machine whose ipmi credentials are added to the payload on delivery, credentials are never stored in the outbox
+	IPMIMachineID string `rethinkdb:"ipmimachineid" json:"ipmimachineid"`
+	// MachineCommandID is the id of the machine command execution carried by the event, its deadline starts when the event is delivered
+	MachineCommandID string `rethinkdb:"machinecommandid" json:"machinecommandid"`
+}
+
+// OutboxEvents is a list of outbox events.
+type OutboxEvents []OutboxEvent
+
+// A DeduplicatedEvent carries the deduplication id of its outbox event, consumers can use it to drop events which were delivered more than once.
+type DeduplicatedEvent interface {
+	WithDeduplicationID(id string) any
+}
+
+// A SecretEvent contains credentials which must not be stored in the outbox.
+type SecretEvent interface {
+	// WithoutSecrets returns a copy of the event without credentials and the id of the machine
+	// whose ipmi credentials need to be resolved on delivery, which is empty if they are not needed.
+	WithoutSecrets() (any, string)
+}
+
+// A MachineCommandEvent carries the execution of a machine command.
+type MachineCommandEvent interface {
+	// MachineCommandID returns the id of the machine command execution, which is empty if the event does not carry a command.
+	MachineCommandID() string
+}
+
+// NewOutboxEvent returns a pending outbox event for the given data, which is due immediately.
+// Secrets of the data are removed before it is stored.
+func NewOutboxEvent(topic string, data any, now time.Time) (*OutboxEvent, error) {
+	id := uuid.NewString()
+
+	if e, ok := data.(DeduplicatedEvent); ok {
+		data = e.WithDeduplicationID(id)
+	}
+
+	var machineCommandID string
+	if e, ok := data.(MachineCommandEvent); ok {
+		machineCommandID = e.MachineCommandID()
+	}
+
+	var ipmiMachineID string
+	if e, ok := data.(SecretEvent); ok {
+		data, ipmiMachineID = e.WithoutSecrets()
+	}
+
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return nil, fmt.Errorf("cannot marshal data to json: %w", err)
+	}
+
+	return &OutboxEvent{
+		Base:             Base{ID: id},
+		Topic:            topic,
+		Payload:          string(payload),
+		State:            OutboxEventStatePending,
+		NextAttempt:      now,
+		IPMIMachineID:    ipmiMachineID,
+		MachineCommandID: machineCommandID,
+	}, nil
+}
+
+// Due returns true if the event is pending and its next attempt is due.
+func (e *OutboxEvent) Due(now time.Time) bool {
+	return e.State == OutboxEventStatePending && !e.NextAttempt.After(now)
+}
+
+// Stuck returns true if the event failed or if it is pending although it was attempted to be published before.
+func (e *OutboxEvent) Stuck() bool {
+	return e.State == OutboxEventStateFailed || (e.State == OutboxEventStatePending && e.Attempts > 0)
+}
+
+// Attempt counts a delivery attempt and schedules the next attempt with an exponential backoff,
+// in case the current attempt does not succeed.
+func (e *OutboxEvent) Attempt(now time.Time) {
+	e.Attempts++
+	e.NextAttempt = now.Add(OutboxBackoff(e.Attempts))
+}
+
+// Delivered marks the event as published.
+func (e *OutboxEvent) Delivered(now time.Time) {
+	e.State = OutboxEventStatePublished
+	e.Published = &now
+	e.LastError = ""
+}
+
+// DeliveryFailed records the error of the last attempt, the event fails once the maximum attempts are reached.
+func (e *OutboxEvent) DeliveryFailed(err error, maxAttempts int) {
+	e.LastError = err.Error()
+	if maxAttempts > 0 && e.Attempts >= maxAttempts {
+		e.State = OutboxEventStateFailed
+	}
+}
+
+// Replay resets the event, such that it is published again immediately.
+func (e *OutboxEvent) Replay(now time.Time) {
+	e.State = OutboxEventStatePending
+	e.Attempts = 0
+	e.NextAttempt = now
+	e.Published = nil
+}
+
+// OutboxBackoff returns the delay after the given amount of attempts, it doubles with every attempt up to five minutes.
+func OutboxBackoff(attempts int) time.Duration {
+	backoff := outboxInitialBackoff
+	for i := 1; i < attempts && backoff < outboxMaxBackoff; i++ {
+		backoff *= 2
+	}
+	return min(backoff, outboxMaxBackoff)
+}
@@ -0,0 +1,85 @@
+package metal
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewOutboxEvent(t *testing.T) {
+	now := time.Now()
+
+	e, err := NewOutboxEvent("p1-machine", &MachineEvent{Type: COMMAND, Cmd: &MachineExecCommand{Command: MachineDiskCmd, TargetMachineID: "m1"}}, now)
+	require.NoError(t, err)
+
+	require.NotEmpty(t, e.ID)
+	assert.Equal(t, "p1-machine", e.Topic)
+	assert.Equal(t, OutboxEventStatePending, e.State)
+	assert.True(t, e.Due(now))
+
+	var evt MachineEvent
+	require.NoError(t, json.Unmarshal([]byte(e.Payload), &evt))
+	assert.Equal(t, e.ID, evt.DeduplicationID)
+	assert.Equal(t, MachineDiskCmd, evt.Cmd.Command)
+
+	e, err = NewOutboxEvent("allocation", &AllocationEvent{MachineID: "m1"}, now)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"old":"m1"}`, e.Payload)
+}
+
+func TestOutboxEvent_Delivery(t *testing.T) {
+	now := time.Now()
+
+	e, err := NewOutboxEvent("allocation", AllocationEvent{MachineID: "m1"}, now)
+	require.NoError(t, err)
+	assert.False(t, e.Stuck())
+
+	e.Attempt(now)
+	assert.Equal(t, 1, e.Attempts)
+	assert.False(t, e.Due(now))
+	assert.True(t, e.Due(now.Add(5*time.Second)))
+
+	e.DeliveryFailed(errors.New("nsqd unavailable"), 2)
+	assert.Equal(t, OutboxEventStatePending, e.State)
+	assert.True(t, e.Stuck())
+
+	e.Attempt(now)
+	e.DeliveryFailed(errors.New("nsqd unavailable"), 2)
+	assert.Equal(t, OutboxEventStateFailed, e.State)
+	assert.Equal(t, "nsqd unavailable", e.LastError)
+	assert.False(t, e.Due(now.Add(time.Hour)))
+	assert.True(t, e.Stuck())
+
+	e.Replay(now)
+	assert.Equal(t, OutboxEventStatePending, e.State)
+	assert.Equal(t, 0, e.Attempts)
+	assert.True(t, e.Due(now))
+
+	e.Attempt(now)
+	e.Delivered(now)
+	assert.Equal(t, OutboxEventStatePublished, e.State)
+	assert.Empty(t, e.LastError)
+	assert.Equal(t, &now, e.Published)
+	assert.False(t, e.Stuck())
+}
+
+func TestOutboxBackoff(t *testing.T) {
+	tests := []struct {
+		attempts int
+		want     time.Duration
+	}{
+		{attempts: 0, want: 5 * time.Second},
+		{attempts: 1, want: 5 * time.Second},
+		{attempts: 2, want: 10 * time.Second},
+		{attempts: 4, want: 40 * time.Second},
+		{attempts: 7, want: 5 * time.Minute},
+		{attempts: 100, want: 5 * time.Minute},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, OutboxBackoff(tt.attempts), "attempts %d", tt.attempts)
+	}
+}
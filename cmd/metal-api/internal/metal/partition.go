@@ -1,5 +1,11 @@
 package metal
 
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
 // A Partition represents a location.
 type Partition struct {
 	Base
@@ -8,6 +14,16 @@ type Partition struct {
 	Labels             map[string]string `rethinkdb:"labels" json:"labels"`
 	DNSServers         DNSServers        `rethinkdb:"dns_servers" json:"dns_servers"`
 	NTPServers         NTPServers        `rethinkdb:"ntp_servers" json:"ntp_servers"`
+	// BootConfigurationOverrides replace the boot configuration for specific machines, sizes or tags
+	BootConfigurationOverrides BootConfigurationOverrides `rethinkdb:"bootconfigoverrides" json:"bootconfigoverrides"`
+	// BootConfigurationRollout is the current or last staged rollout of a new boot configuration
+	BootConfigurationRollout *BootConfigurationRollout `rethinkdb:"bootconfigrollout" json:"bootconfigrollout"`
+	// RackPowerBudget limits the power consumption of the racks, no new machines are allocated in racks which would exceed it
+	RackPowerBudget *RackPowerBudget `rethinkdb:"rackpowerbudget" json:"rackpowerbudget"`
+	// PlacementStrategy is used for allocations which do not define a placement strategy on their own
+	PlacementStrategy PlacementStrategyType `rethinkdb:"placementstrategy" json:"placementstrategy"`
+	// PlacementSpreadLevel is the failure domain level for allocations which do not define one on their own
+	PlacementSpreadLevel LocationLevel `rethinkdb:"placementspreadlevel" json:"placementspreadlevel"`
 }
 
 // BootConfiguration defines the metal-hammer initrd, kernel and commandline
@@ -17,6 +33,129 @@ type BootConfiguration struct {
 	CommandLine string `rethinkdb:"commandline" json:"commandline"`
 }
 
+// BootConfigurationOverrideScope defines to which machines a boot configuration override applies.
+type BootConfigurationOverrideScope string
+
+const (
+	// BootConfigurationOverrideScopeMachine applies to the machine with the id given as selector
+	BootConfigurationOverrideScopeMachine BootConfigurationOverrideScope = "machine"
+	// BootConfigurationOverrideScopeTag applies to all machines which have the tag given as selector
+	BootConfigurationOverrideScopeTag BootConfigurationOverrideScope = "tag"
+	// BootConfigurationOverrideScopeSize applies to all machines of the size given as selector
+	BootConfigurationOverrideScopeSize BootConfigurationOverrideScope = "size"
+	// BootConfigurationOverrideScopeRollout is not configurable, it marks canary machines of a running boot configuration rollout
+	BootConfigurationOverrideScopeRollout BootConfigurationOverrideScope = "rollout"
+)
+
+// BootConfigurationOverrideScopes contains all scopes in the order of their precedence, the first one wins.
+var BootConfigurationOverrideScopes = []BootConfigurationOverrideScope{
+	BootConfigurationOverrideScopeMachine,
+	BootConfigurationOverrideScopeTag,
+	BootConfigurationOverrideScopeSize,
+}
+
+// A BootConfigurationOverride replaces the boot configuration of a partition for the machines matching its scope and selector.
+// Empty fields are taken from the boot configuration of the partition.
+type BootConfigurationOverride struct {
+	Scope    BootConfigurationOverrideScope `rethinkdb:"scope" json:"scope"`
+	Selector string                         `rethinkdb:"selector" json:"selector"`
+	// BootConfiguration contains the values which replace the ones of the partition
+	BootConfiguration BootConfiguration `rethinkdb:"bootconfig" json:"bootconfig"`
+	// CommandLineAppend is appended to the resulting kernel commandline
+	CommandLineAppend string `rethinkdb:"commandlineappend" json:"commandlineappend"`
+}
+
+// BootConfigurationOverrides is a list of boot configuration overrides.
+type BootConfigurationOverrides []BootConfigurationOverride
+
+// String returns the scope and the selector of the override.
+func (o *BootConfigurationOverride) String() string {
+	return fmt.Sprintf("%s:%s", o.Scope, o.Selector)
+}
+
+// Validate checks the scopes of the overrides and that every scope and selector is only used once.
+func (os BootConfigurationOverrides) Validate() error {
+	seen := map[string]bool{}
+	for _, o := range os {
+		if !slices.Contains(BootConfigurationOverrideScopes, o.Scope) {
+			return fmt.Errorf("boot configuration override scope %q is invalid, must be one of %v", o.Scope, BootConfigurationOverrideScopes)
+		}
+		if o.Selector == "" {
+			return fmt.Errorf("boot configuration override with scope %q requires a selector", o.Scope)
+		}
+		if seen[o.String()] {
+			return fmt.Errorf("boot configuration override %q is defined more than once", o.String())
+		}
+		seen[o.String()] = true
+	}
+	return nil
+}
+
+// matches returns true if the override applies to the given machine.
+func (o *BootConfigurationOverride) matches(m *Machine) bool {
+	switch o.Scope {
+	case BootConfigurationOverrideScopeMachine:
+		return m.ID == o.Selector
+	case BootConfigurationOverrideScopeTag:
+		return slices.Contains(m.Tags, o.Selector)
+	case BootConfigurationOverrideScopeSize:
+		return m.SizeID == o.Selector
+	default:
+		return false
+	}
+}
+
+// ResolveBootConfiguration returns the boot configuration for the given machine and the override which was applied.
+// Overrides with a machine scope take precedence over tag scopes which take precedence over size scopes,
+// within a scope the first matching override wins. Canaries of a running rollout which are not matched by
+// any override get the boot configuration of the rollout with an override of the rollout scope.
+// If nothing matches or the machine is nil, the boot configuration of the partition is returned without an override.
+func (p *Partition) ResolveBootConfiguration(m *Machine) (BootConfiguration, *BootConfigurationOverride) {
+	if m == nil {
+		return p.BootConfiguration, nil
+	}
+
+	for _, scope := range BootConfigurationOverrideScopes {
+		for i := range p.BootConfigurationOverrides {
+			o := &p.BootConfigurationOverrides[i]
+			if o.Scope != scope || !o.matches(m) {
+				continue
+			}
+
+			bc := p.BootConfiguration.Merge(o.BootConfiguration)
+			if o.CommandLineAppend != "" {
+				bc.CommandLine = strings.TrimSpace(bc.CommandLine + " " + o.CommandLineAppend)
+			}
+
+			return bc, o
+		}
+	}
+
+	if r := p.BootConfigurationRollout; r != nil && r.State == BootConfigurationRolloutStateRunning && r.IsCanary(m.ID) {
+		return p.BootConfiguration.Merge(r.BootConfiguration), &BootConfigurationOverride{
+			Scope:             BootConfigurationOverrideScopeRollout,
+			Selector:          m.ID,
+			BootConfiguration: r.BootConfiguration,
+		}
+	}
+
+	return p.BootConfiguration, nil
+}
+
+// Merge returns the boot configuration with all non-empty values of the given one applied.
+func (bc BootConfiguration) Merge(o BootConfiguration) BootConfiguration {
+	if o.ImageURL != "" {
+		bc.ImageURL = o.ImageURL
+	}
+	if o.KernelURL != "" {
+		bc.KernelURL = o.KernelURL
+	}
+	if o.CommandLine != "" {
+		bc.CommandLine = o.CommandLine
+	}
+	return bc
+}
+
 // Partitions is a list of partitions.
 type Partitions []Partition
 
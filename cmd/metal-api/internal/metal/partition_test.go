@@ -3,6 +3,8 @@ package metal
 import (
 	"reflect"
 	"testing"
+
+	"github.com/stretchr/testify/require"
 )
 
 func TestPartitions_ByID(t *testing.T) {
@@ -50,3 +52,92 @@ func TestPartitions_ByID(t *testing.T) {
 		})
 	}
 }
+
+func TestPartition_ResolveBootConfiguration(t *testing.T) {
+	p := &Partition{
+		BootConfiguration: BootConfiguration{
+			ImageURL:    "http://images/metal-hammer.tgz",
+			KernelURL:   "http://images/kernel",
+			CommandLine: "console=ttyS0",
+		},
+		BootConfigurationOverrides: BootConfigurationOverrides{
+			{Scope: BootConfigurationOverrideScopeSize, Selector: "s1", BootConfiguration: BootConfiguration{ImageURL: "http://images/metal-hammer-next.tgz"}},
+			{Scope: BootConfigurationOverrideScopeTag, Selector: "needs-flags", CommandLineAppend: "pci=nomsi"},
+			{Scope: BootConfigurationOverrideScopeMachine, Selector: "m1", BootConfiguration: BootConfiguration{CommandLine: "console=ttyS1"}},
+		},
+		BootConfigurationRollout: &BootConfigurationRollout{
+			BootConfiguration: BootConfiguration{KernelURL: "http://images/kernel-next"},
+			Canaries:          []string{"m1", "m3"},
+			State:             BootConfigurationRolloutStateRunning,
+		},
+	}
+
+	tests := []struct {
+		name         string
+		machine      *Machine
+		want         BootConfiguration
+		wantOverride string
+	}{
+		{
+			name:    "unknown machine gets the partition defaults",
+			machine: nil,
+			want:    p.BootConfiguration,
+		},
+		{
+			name:    "no matching override",
+			machine: &Machine{Base: Base{ID: "m2"}, SizeID: "s2"},
+			want:    p.BootConfiguration,
+		},
+		{
+			name:         "size override",
+			machine:      &Machine{Base: Base{ID: "m2"}, SizeID: "s1"},
+			want:         BootConfiguration{ImageURL: "http://images/metal-hammer-next.tgz", KernelURL: "http://images/kernel", CommandLine: "console=ttyS0"},
+			wantOverride: "size:s1",
+		},
+		{
+			name:         "tag override takes precedence over size override",
+			machine:      &Machine{Base: Base{ID: "m2"}, SizeID: "s1", Tags: []string{"needs-flags"}},
+			want:         BootConfiguration{ImageURL: "http://images/metal-hammer.tgz", KernelURL: "http://images/kernel", CommandLine: "console=ttyS0 pci=nomsi"},
+			wantOverride: "tag:needs-flags",
+		},
+		{
+			name:         "machine override takes precedence over all others",
+			machine:      &Machine{Base: Base{ID: "m1"}, SizeID: "s1", Tags: []string{"needs-flags"}},
+			want:         BootConfiguration{ImageURL: "http://images/metal-hammer.tgz", KernelURL: "http://images/kernel", CommandLine: "console=ttyS1"},
+			wantOverride: "machine:m1",
+		},
+		{
+			name:         "canary of a running rollout",
+			machine:      &Machine{Base: Base{ID: "m3"}, SizeID: "s2"},
+			want:         BootConfiguration{ImageURL: "http://images/metal-hammer.tgz", KernelURL: "http://images/kernel-next", CommandLine: "console=ttyS0"},
+			wantOverride: "rollout:m3",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, override := p.ResolveBootConfiguration(tt.machine)
+			require.Equal(t, tt.want, got)
+
+			if tt.wantOverride == "" {
+				require.Nil(t, override)
+				return
+			}
+			require.NotNil(t, override)
+			require.Equal(t, tt.wantOverride, override.String())
+		})
+	}
+}
+
+func TestBootConfigurationOverrides_Validate(t *testing.T) {
+	require.NoError(t, BootConfigurationOverrides{
+		{Scope: BootConfigurationOverrideScopeMachine, Selector: "m1"},
+		{Scope: BootConfigurationOverrideScopeSize, Selector: "m1"},
+	}.Validate())
+
+	require.EqualError(t, BootConfigurationOverrides{{Scope: "rack", Selector: "r1"}}.Validate(), `boot configuration override scope "rack" is invalid, must be one of [machine tag size]`)
+	require.EqualError(t, BootConfigurationOverrides{{Scope: BootConfigurationOverrideScopeTag}}.Validate(), `boot configuration override with scope "tag" requires a selector`)
+	require.EqualError(t, BootConfigurationOverrides{
+		{Scope: BootConfigurationOverrideScopeSize, Selector: "s1"},
+		{Scope: BootConfigurationOverrideScopeSize, Selector: "s1"},
+	}.Validate(), `boot configuration override "size:s1" is defined more than once`)
+}
@@ -0,0 +1,104 @@
+package metal
+
+import (
+	"fmt"
+)
+
+// PlacementStrategyType determines which of the electable machines are preferred for an allocation.
+type PlacementStrategyType string
+
+const (
+	// PlacementStrategyRackSpreading prefers the racks which are least occupied by the project and the placement tags
+	PlacementStrategyRackSpreading PlacementStrategyType = "rack-spreading"
+	// PlacementStrategyBinPacking prefers the racks which are most occupied, such that allocations end up in the fewest racks
+	PlacementStrategyBinPacking PlacementStrategyType = "bin-packing"
+	// PlacementStrategySwitchAffinity only allows machines which are connected to the given switches
+	PlacementStrategySwitchAffinity PlacementStrategyType = "switch-affinity"
+	// PlacementStrategyLeastRecentlyReinstalled prefers the machines which were installed least recently, the oldest hardware first
+	PlacementStrategyLeastRecentlyReinstalled PlacementStrategyType = "least-recently-reinstalled"
+
+	// DefaultPlacementStrategy is used if neither the allocation nor the partition define a placement strategy
+	DefaultPlacementStrategy = PlacementStrategyRackSpreading
+)
+
+// AllPlacementStrategies contains all placement strategies.
+var AllPlacementStrategies = []PlacementStrategyType{
+	PlacementStrategyRackSpreading,
+	PlacementStrategyBinPacking,
+	PlacementStrategySwitchAffinity,
+	PlacementStrategyLeastRecentlyReinstalled,
+}
+
+// PlacementStrategyFrom returns the placement strategy for the given name.
+func PlacementStrategyFrom(name string) (PlacementStrategyType, error) {
+	for _, s := range AllPlacementStrategies {
+		if string(s) == name {
+			return s, nil
+		}
+	}
+	return "", fmt.Errorf("unknown placement strategy: %q", name)
+}
+
+// OrDefault returns the default placement strategy if the strategy is empty.
+func (s PlacementStrategyType) OrDefault() PlacementStrategyType {
+	if s == "" {
+		return DefaultPlacementStrategy
+	}
+	return s
+}
+
+// Placement describes where the machine of an allocation should be placed.
+type Placement struct {
+	// Strategy is the placement strategy of the allocation, the strategy of the partition is used if it is empty
+	Strategy PlacementStrategyType
+	// Tags are the placement tags the machine should be spread across
+	Tags []string
+	// Switches are the switches the machine must be connected to when using the switch affinity strategy
+	Switches []string
+	// SpreadLevel is the level of the failure domains the rack spreading and bin packing strategies spread or pack across,
+	// the level of the partition is used if it is empty
+	SpreadLevel LocationLevel
+}
+
+// Validate returns an error if the placement cannot be fulfilled by its strategy.
+func (p *Placement) Validate() error {
+	if p.Strategy != "" {
+		if _, err := PlacementStrategyFrom(string(p.Strategy)); err != nil {
+			return err
+		}
+	}
+	if p.Strategy == PlacementStrategySwitchAffinity && len(p.Switches) == 0 {
+		return fmt.Errorf("placement strategy %s requires at least one switch", p.Strategy)
+	}
+	if p.Strategy != PlacementStrategySwitchAffinity && len(p.Switches) > 0 {
+		return fmt.Errorf("placement switches can only be given for placement strategy %s", PlacementStrategySwitchAffinity)
+	}
+	if p.SpreadLevel != "" {
+		if _, err := LocationLevelFrom(string(p.SpreadLevel)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// StrategyOf returns the placement strategy of the allocation, falling back to the strategy of the partition and the default strategy.
+func (p *Placement) StrategyOf(partition *Partition) PlacementStrategyType {
+	if p.Strategy != "" {
+		return p.Strategy
+	}
+	if partition != nil {
+		return partition.PlacementStrategy.OrDefault()
+	}
+	return DefaultPlacementStrategy
+}
+
+// SpreadLevelOf returns the failure domain level of the allocation, falling back to the level of the partition and rack level.
+func (p *Placement) SpreadLevelOf(partition *Partition) LocationLevel {
+	if p.SpreadLevel != "" {
+		return p.SpreadLevel
+	}
+	if partition != nil && partition.PlacementSpreadLevel != "" {
+		return partition.PlacementSpreadLevel
+	}
+	return LocationLevelRack
+}
@@ -0,0 +1,42 @@
+package metal
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPlacement_Validate(t *testing.T) {
+	tests := []struct {
+		name      string
+		placement Placement
+		wantErr   string
+	}{
+		{name: "default", placement: Placement{Tags: []string{"a"}}},
+		{name: "bin packing", placement: Placement{Strategy: PlacementStrategyBinPacking}},
+		{name: "switch affinity", placement: Placement{Strategy: PlacementStrategySwitchAffinity, Switches: []string{"leaf01", "leaf02"}}},
+		{name: "unknown strategy", placement: Placement{Strategy: "random"}, wantErr: `unknown placement strategy: "random"`},
+		{name: "switch affinity without switches", placement: Placement{Strategy: PlacementStrategySwitchAffinity}, wantErr: "placement strategy switch-affinity requires at least one switch"},
+		{name: "switches without switch affinity", placement: Placement{Switches: []string{"leaf01"}}, wantErr: "placement switches can only be given for placement strategy switch-affinity"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.placement.Validate()
+			if tt.wantErr == "" {
+				require.NoError(t, err)
+				return
+			}
+			require.EqualError(t, err, tt.wantErr)
+		})
+	}
+}
+
+func TestPlacement_StrategyOf(t *testing.T) {
+	p := &Partition{PlacementStrategy: PlacementStrategyBinPacking}
+
+	assert.Equal(t, PlacementStrategyRackSpreading, (&Placement{}).StrategyOf(nil))
+	assert.Equal(t, PlacementStrategyRackSpreading, (&Placement{}).StrategyOf(&Partition{}))
+	assert.Equal(t, PlacementStrategyBinPacking, (&Placement{}).StrategyOf(p))
+	assert.Equal(t, PlacementStrategyLeastRecentlyReinstalled, (&Placement{Strategy: PlacementStrategyLeastRecentlyReinstalled}).StrategyOf(p))
+}
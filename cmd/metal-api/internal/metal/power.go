@@ -0,0 +1,292 @@
+package metal
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+const (
+	// PowerSampleResolutionRaw is the resolution of the power samples as they are reported by the metal-bmc
+	PowerSampleResolutionRaw time.Duration = 0
+	// PowerSampleResolutionHourly is the resolution raw power samples are downsampled to
+	PowerSampleResolutionHourly = time.Hour
+
+	// MaxPowerSampleDuration is the longest duration a raw power sample accounts for. If the metal-bmc did not report
+	// for a longer time, the gap is not considered when calculating the consumed energy.
+	MaxPowerSampleDuration = 20 * time.Minute
+)
+
+// A PowerSample is a power reading of a machine. The partition, rack, size and project are captured at the time
+// of the reading, such that the consumption can be attributed correctly after the machine was moved or freed.
+type PowerSample struct {
+	Base
+	MachineID   string `rethinkdb:"machineid" json:"machineid"`
+	PartitionID string `rethinkdb:"partitionid" json:"partitionid"`
+	RackID      string `rethinkdb:"rackid" json:"rackid"`
+	SizeID      string `rethinkdb:"sizeid" json:"sizeid"`
+	ProjectID   string `rethinkdb:"projectid" json:"projectid"`
+	// Time is the time of the reading, for downsampled samples it is the start of the bucket
+	Time time.Time `rethinkdb:"time" json:"time"`
+	// Resolution is the length of the bucket of a downsampled sample and 0 for raw samples
+	Resolution time.Duration `rethinkdb:"resolution" json:"resolution"`
+	// Duration is the time the sample accounts for when calculating the consumed energy
+	Duration             time.Duration `rethinkdb:"duration" json:"duration"`
+	Samples              int           `rethinkdb:"samples" json:"samples"`
+	AverageConsumedWatts float64       `rethinkdb:"averageconsumedwatts" json:"averageconsumedwatts"`
+	MinConsumedWatts     float64       `rethinkdb:"minconsumedwatts" json:"minconsumedwatts"`
+	MaxConsumedWatts     float64       `rethinkdb:"maxconsumedwatts" json:"maxconsumedwatts"`
+	// PowerSupplies is the amount of power supplies and DegradedPowerSupplies the highest amount of degraded ones within the sample
+	PowerSupplies         int `rethinkdb:"powersupplies" json:"powersupplies"`
+	DegradedPowerSupplies int `rethinkdb:"degradedpowersupplies" json:"degradedpowersupplies"`
+}
+
+// PowerSamples is a list of power samples.
+type PowerSamples []PowerSample
+
+// NewPowerSample returns a raw power sample of the current power metric of the machine, it returns nil if no power metric was reported.
+// The previous time is the time of the preceding report of the metal-bmc, it determines the duration of the sample.
+func NewPowerSample(m *Machine, previous time.Time, now time.Time) *PowerSample {
+	if m.IPMI.PowerMetric == nil {
+		return nil
+	}
+
+	var duration time.Duration
+	if !previous.IsZero() && now.After(previous) {
+		duration = min(now.Sub(previous), MaxPowerSampleDuration)
+	}
+
+	var project string
+	if m.Allocation != nil {
+		project = m.Allocation.Project
+	}
+
+	return &PowerSample{
+		MachineID:             m.ID,
+		PartitionID:           m.PartitionID,
+		RackID:                m.RackID,
+		SizeID:                m.SizeID,
+		ProjectID:             project,
+		Time:                  now,
+		Resolution:            PowerSampleResolutionRaw,
+		Duration:              duration,
+		Samples:               1,
+		AverageConsumedWatts:  float64(m.IPMI.PowerMetric.AverageConsumedWatts),
+		MinConsumedWatts:      float64(m.IPMI.PowerMetric.MinConsumedWatts),
+		MaxConsumedWatts:      float64(m.IPMI.PowerMetric.MaxConsumedWatts),
+		PowerSupplies:         len(m.IPMI.PowerSupplies),
+		DegradedPowerSupplies: len(m.IPMI.PowerSupplies.Degraded()),
+	}
+}
+
+// EnergyWattHours returns the energy consumed within the duration of the sample.
+func (s *PowerSample) EnergyWattHours() float64 {
+	return s.AverageConsumedWatts * s.Duration.Hours()
+}
+
+// PowerSampleBucketID returns the id of the downsampled power sample of a machine and project for the bucket starting at the given time.
+func PowerSampleBucketID(machineID, projectID string, bucket time.Time, resolution time.Duration) string {
+	return fmt.Sprintf("%s/%d/%d/%s", machineID, resolution/time.Second, bucket.Unix(), projectID)
+}
+
+// Merge adds the readings of the other sample to this sample. The average is weighted by the amount of samples.
+func (s *PowerSample) Merge(other *PowerSample) {
+	if s.Samples == 0 {
+		s.MinConsumedWatts = other.MinConsumedWatts
+		s.MaxConsumedWatts = other.MaxConsumedWatts
+	} else {
+		s.MinConsumedWatts = min(s.MinConsumedWatts, other.MinConsumedWatts)
+		s.MaxConsumedWatts = max(s.MaxConsumedWatts, other.MaxConsumedWatts)
+	}
+
+	total := s.Samples + other.Samples
+	if total > 0 {
+		s.AverageConsumedWatts = (s.AverageConsumedWatts*float64(s.Samples) + other.AverageConsumedWatts*float64(other.Samples)) / float64(total)
+	}
+	s.Samples = total
+	s.Duration += other.Duration
+	s.PowerSupplies = max(s.PowerSupplies, other.PowerSupplies)
+	s.DegradedPowerSupplies = max(s.DegradedPowerSupplies, other.DegradedPowerSupplies)
+}
+
+// Downsample merges the samples into buckets of the given resolution per machine and project.
+func (ss PowerSamples) Downsample(resolution time.Duration) PowerSamples {
+	var (
+		order   []string
+		buckets = map[string]*PowerSample{}
+	)
+
+	for i := range ss {
+		s := &ss[i]
+		bucket := s.Time.Truncate(resolution)
+		id := PowerSampleBucketID(s.MachineID, s.ProjectID, bucket, resolution)
+
+		b, ok := buckets[id]
+		if !ok {
+			b = &PowerSample{
+				Base:        Base{ID: id},
+				MachineID:   s.MachineID,
+				PartitionID: s.PartitionID,
+				RackID:      s.RackID,
+				SizeID:      s.SizeID,
+				ProjectID:   s.ProjectID,
+				Time:        bucket,
+				Resolution:  resolution,
+			}
+			buckets[id] = b
+			order = append(order, id)
+		}
+
+		b.Merge(s)
+	}
+
+	result := make(PowerSamples, 0, len(order))
+	for _, id := range order {
+		result = append(result, *buckets[id])
+	}
+	return result
+}
+
+// A PowerAggregate is the power consumption of a group of machines within a time window.
+type PowerAggregate struct {
+	Group    string
+	Machines int
+	// AverageWatts is the consumed energy divided by the length of the window
+	AverageWatts float64
+	// PeakWatts is the sum of the highest consumption of each machine, so it is an upper bound of the peak of the group
+	PeakWatts       float64
+	EnergyWattHours float64
+}
+
+// AggregatePower sums up the consumption of the samples per group within the window of the given length.
+func AggregatePower(ss PowerSamples, window time.Duration, group func(s *PowerSample) string) map[string]*PowerAggregate {
+	type key struct {
+		group   string
+		machine string
+	}
+
+	var (
+		result = map[string]*PowerAggregate{}
+		peaks  = map[key]float64{}
+	)
+
+	for i := range ss {
+		s := &ss[i]
+		g := group(s)
+
+		a, ok := result[g]
+		if !ok {
+			a = &PowerAggregate{Group: g}
+			result[g] = a
+		}
+		a.EnergyWattHours += s.EnergyWattHours()
+
+		k := key{group: g, machine: s.MachineID}
+		peak, ok := peaks[k]
+		if !ok {
+			a.Machines++
+		}
+		peaks[k] = max(peak, s.MaxConsumedWatts)
+	}
+
+	for k, peak := range peaks {
+		result[k.group].PeakWatts += peak
+	}
+
+	if window > 0 {
+		for _, a := range result {
+			a.AverageWatts = a.EnergyWattHours / window.Hours()
+		}
+	}
+
+	return result
+}
+
+// Degraded returns the power supplies which are present but whose health is not ok.
+func (ps PowerSupplies) Degraded() PowerSupplies {
+	var degraded PowerSupplies
+	for _, p := range ps {
+		if p.Status.Health == "" || strings.EqualFold(p.Status.Health, "OK") {
+			continue
+		}
+		if strings.EqualFold(p.Status.State, "Absent") {
+			continue
+		}
+		degraded = append(degraded, p)
+	}
+	return degraded
+}
+
+// RackPowerBudget limits the power consumption of the racks of a partition.
+type RackPowerBudget struct {
+	// DefaultWatts is the budget of all racks without an own budget, 0 means unlimited
+	DefaultWatts uint `rethinkdb:"defaultwatts" json:"defaultwatts"`
+	// RackWatts contains the budgets of individual racks by rack id, 0 means unlimited
+	RackWatts map[string]uint `rethinkdb:"rackwatts" json:"rackwatts"`
+}
+
+// Watts returns the power budget of the given rack, it returns false if the consumption of the rack is not limited.
+func (b *RackPowerBudget) Watts(rackID string) (uint, bool) {
+	if b == nil {
+		return 0, false
+	}
+	if w, ok := b.RackWatts[rackID]; ok {
+		return w, w > 0
+	}
+	return b.DefaultWatts, b.DefaultWatts > 0
+}
+
+// Limited returns true if the consumption of any rack is limited.
+func (b *RackPowerBudget) Limited() bool {
+	if b == nil {
+		return false
+	}
+	if b.DefaultWatts > 0 {
+		return true
+	}
+	for _, w := range b.RackWatts {
+		if w > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// MeasuredPowerDraw returns the average consumption last reported by the metal-bmc, it is 0 for machines which are powered off.
+func (m *Machine) MeasuredPowerDraw() float64 {
+	if m.IPMI.PowerMetric == nil || !strings.EqualFold(m.IPMI.PowerState, "ON") {
+		return 0
+	}
+	return float64(m.IPMI.PowerMetric.AverageConsumedWatts)
+}
+
+// EstimatedPowerDraw returns the consumption of the machine which is accounted to the power budget of its rack.
+// Allocated machines account for at least the expected power draw of their size, as they can be powered on at any time.
+func (m *Machine) EstimatedPowerDraw(expectedWatts uint) float64 {
+	if m.Allocation == nil {
+		return m.MeasuredPowerDraw()
+	}
+	return max(m.MeasuredPowerDraw(), float64(expectedWatts))
+}
+
+// AllocationPowerDraw returns the additional consumption which is accounted to the power budget of the rack when the machine gets allocated.
+func (m *Machine) AllocationPowerDraw(expectedWatts uint) float64 {
+	if m.Allocation != nil {
+		return 0
+	}
+	return max(float64(expectedWatts)-m.MeasuredPowerDraw(), 0)
+}
+
+// RackPowerConsumption returns the estimated consumption of the given machines per rack.
+func RackPowerConsumption(ms Machines, sizes SizeMap) map[string]float64 {
+	result := map[string]float64{}
+	for i := range ms {
+		m := &ms[i]
+		var expected uint
+		if s, ok := sizes[m.SizeID]; ok {
+			expected = s.ExpectedPowerWatts
+		}
+		result[m.RackID] += m.EstimatedPowerDraw(expected)
+	}
+	return result
+}
@@ -0,0 +1,144 @@
+package metal
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewPowerSample(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	m := &Machine{
+		Base:        Base{ID: "m1"},
+		PartitionID: "p1",
+		RackID:      "r1",
+		SizeID:      "s1",
+		Allocation:  &MachineAllocation{Project: "pr1"},
+	}
+	require.Nil(t, NewPowerSample(m, now.Add(-time.Minute), now))
+
+	m.IPMI.PowerMetric = &PowerMetric{AverageConsumedWatts: 200, MinConsumedWatts: 150, MaxConsumedWatts: 300}
+	m.IPMI.PowerSupplies = PowerSupplies{
+		{Status: PowerSupplyStatus{Health: "OK", State: "Enabled"}},
+		{Status: PowerSupplyStatus{Health: "Warning", State: "Enabled"}},
+	}
+
+	s := NewPowerSample(m, now.Add(-time.Minute), now)
+	require.NotNil(t, s)
+	assert.Equal(t, "pr1", s.ProjectID)
+	assert.Equal(t, "r1", s.RackID)
+	assert.Equal(t, time.Minute, s.Duration)
+	assert.Equal(t, 2, s.PowerSupplies)
+	assert.Equal(t, 1, s.DegradedPowerSupplies)
+	assert.InDelta(t, 200.0/60, s.EnergyWattHours(), 0.0001)
+
+	// gaps in the reports are not accounted for
+	s = NewPowerSample(m, now.Add(-5*time.Hour), now)
+	assert.Equal(t, MaxPowerSampleDuration, s.Duration)
+
+	s = NewPowerSample(m, time.Time{}, now)
+	assert.Zero(t, s.Duration)
+}
+
+func TestPowerSamples_Downsample(t *testing.T) {
+	hour := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	ss := PowerSamples{
+		{MachineID: "m1", ProjectID: "pr1", Time: hour.Add(10 * time.Minute), Duration: 10 * time.Minute, Samples: 1, AverageConsumedWatts: 100, MinConsumedWatts: 90, MaxConsumedWatts: 110},
+		{MachineID: "m1", ProjectID: "pr1", Time: hour.Add(20 * time.Minute), Duration: 10 * time.Minute, Samples: 1, AverageConsumedWatts: 200, MinConsumedWatts: 180, MaxConsumedWatts: 250, DegradedPowerSupplies: 1},
+		{MachineID: "m1", ProjectID: "pr1", Time: hour.Add(70 * time.Minute), Duration: 10 * time.Minute, Samples: 1, AverageConsumedWatts: 300, MinConsumedWatts: 300, MaxConsumedWatts: 300},
+		{MachineID: "m2", Time: hour.Add(10 * time.Minute), Duration: 10 * time.Minute, Samples: 1, AverageConsumedWatts: 50, MinConsumedWatts: 50, MaxConsumedWatts: 50},
+	}
+
+	got := ss.Downsample(time.Hour)
+	require.Len(t, got, 3)
+
+	assert.Equal(t, PowerSampleBucketID("m1", "pr1", hour, time.Hour), got[0].ID)
+	assert.Equal(t, hour, got[0].Time)
+	assert.Equal(t, time.Hour, got[0].Resolution)
+	assert.Equal(t, 2, got[0].Samples)
+	assert.InDelta(t, 150, got[0].AverageConsumedWatts, 0.0001)
+	assert.InDelta(t, 90, got[0].MinConsumedWatts, 0.0001)
+	assert.InDelta(t, 250, got[0].MaxConsumedWatts, 0.0001)
+	assert.Equal(t, 20*time.Minute, got[0].Duration)
+	assert.Equal(t, 1, got[0].DegradedPowerSupplies)
+
+	assert.Equal(t, hour.Add(time.Hour), got[1].Time)
+	assert.Equal(t, "m2", got[2].MachineID)
+
+	// the energy is preserved by downsampling
+	var before, after float64
+	for i := range ss {
+		before += ss[i].EnergyWattHours()
+	}
+	for i := range got {
+		after += got[i].EnergyWattHours()
+	}
+	assert.InDelta(t, before, after, 0.0001)
+}
+
+func TestAggregatePower(t *testing.T) {
+	now := time.Now()
+
+	ss := PowerSamples{
+		{MachineID: "m1", RackID: "r1", Time: now, Duration: time.Hour, AverageConsumedWatts: 100, MaxConsumedWatts: 150},
+		{MachineID: "m1", RackID: "r1", Time: now, Duration: time.Hour, AverageConsumedWatts: 200, MaxConsumedWatts: 250},
+		{MachineID: "m2", RackID: "r1", Time: now, Duration: 2 * time.Hour, AverageConsumedWatts: 50, MaxConsumedWatts: 60},
+		{MachineID: "m3", RackID: "r2", Time: now, Duration: time.Hour, AverageConsumedWatts: 400, MaxConsumedWatts: 400},
+	}
+
+	got := AggregatePower(ss, 2*time.Hour, func(s *PowerSample) string { return s.RackID })
+	require.Len(t, got, 2)
+
+	assert.Equal(t, 2, got["r1"].Machines)
+	assert.InDelta(t, 400, got["r1"].EnergyWattHours, 0.0001)
+	assert.InDelta(t, 200, got["r1"].AverageWatts, 0.0001)
+	assert.InDelta(t, 310, got["r1"].PeakWatts, 0.0001)
+
+	assert.Equal(t, 1, got["r2"].Machines)
+	assert.InDelta(t, 200, got["r2"].AverageWatts, 0.0001)
+}
+
+func TestRackPowerBudget_Watts(t *testing.T) {
+	var b *RackPowerBudget
+	assert.False(t, b.Limited())
+	_, ok := b.Watts("r1")
+	assert.False(t, ok)
+
+	b = &RackPowerBudget{RackWatts: map[string]uint{"r1": 5000, "r2": 0}}
+	assert.True(t, b.Limited())
+
+	w, ok := b.Watts("r1")
+	assert.True(t, ok)
+	assert.Equal(t, uint(5000), w)
+	_, ok = b.Watts("r3")
+	assert.False(t, ok)
+
+	b.DefaultWatts = 8000
+	w, ok = b.Watts("r3")
+	assert.True(t, ok)
+	assert.Equal(t, uint(8000), w)
+	_, ok = b.Watts("r2")
+	assert.False(t, ok)
+}
+
+func TestMachine_PowerDraw(t *testing.T) {
+	m := &Machine{IPMI: IPMI{PowerState: "ON", PowerMetric: &PowerMetric{AverageConsumedWatts: 150}}}
+	assert.InDelta(t, 150, m.EstimatedPowerDraw(400), 0.0001)
+	assert.InDelta(t, 250, m.AllocationPowerDraw(400), 0.0001)
+
+	m.IPMI.PowerState = "OFF"
+	assert.Zero(t, m.EstimatedPowerDraw(400))
+	assert.InDelta(t, 400, m.AllocationPowerDraw(400), 0.0001)
+
+	m.Allocation = &MachineAllocation{}
+	assert.InDelta(t, 400, m.EstimatedPowerDraw(400), 0.0001)
+	assert.Zero(t, m.AllocationPowerDraw(400))
+
+	m.IPMI.PowerState = "ON"
+	m.IPMI.PowerMetric.AverageConsumedWatts = 500
+	assert.InDelta(t, 500, m.EstimatedPowerDraw(400), 0.0001)
+}
@@ -0,0 +1,13 @@
+package metal
+
+import "encoding/json"
+
+// ProjectEvent is published when a project was created, updated or deleted.
+//
+// Projects are stored in the masterdata-api, so changes cannot be observed in the database.
+type ProjectEvent struct {
+	Type      EventType `json:"type"`
+	ProjectID string    `json:"projectid"`
+	// Project contains the project as returned by the project endpoints of the metal-api
+	Project json.RawMessage `json:"project,omitempty"`
+}
@@ -0,0 +1,26 @@
+package metal
+
+import "time"
+
+// A ProvisioningEventLogEntry is a provisioning event of a machine in the append-only provisioning event log.
+// Unlike the events of a provisioning event container, the entries are not trimmed but only removed after the retention.
+type ProvisioningEventLogEntry struct {
+	Base
+	MachineID string                `rethinkdb:"machineid" json:"machineid"`
+	Time      time.Time             `rethinkdb:"time" json:"time"`
+	Event     ProvisioningEventType `rethinkdb:"event" json:"event"`
+	Message   string                `rethinkdb:"message" json:"message"`
+}
+
+// ProvisioningEventLogEntries is a slice of ProvisioningEventLogEntry
+type ProvisioningEventLogEntries []ProvisioningEventLogEntry
+
+// NewProvisioningEventLogEntry returns the log entry of a provisioning event of the given machine.
+func NewProvisioningEventLogEntry(machineID string, event *ProvisioningEvent) *ProvisioningEventLogEntry {
+	return &ProvisioningEventLogEntry{
+		MachineID: machineID,
+		Time:      event.Time,
+		Event:     event.Event,
+		Message:   event.Message,
+	}
+}
@@ -0,0 +1,127 @@
+package metal
+
+import (
+	"slices"
+	"time"
+)
+
+// A ProvisioningPhase is the time span a machine spent in a provisioning state,
+// it starts with the event which entered the state and ends with the event which entered the next one.
+type ProvisioningPhase struct {
+	Event ProvisioningEventType
+	Start time.Time
+	// End is nil for the phase the machine is currently in
+	End *time.Time
+	// Unexpected is true if the phase was entered by a transition which does not follow the expected machine lifecycle
+	Unexpected bool
+}
+
+// Duration returns the duration of the phase, the current phase lasts until now.
+func (p *ProvisioningPhase) Duration(now time.Time) time.Duration {
+	if p.End == nil {
+		return now.Sub(p.Start)
+	}
+	return p.End.Sub(p.Start)
+}
+
+// ProvisioningTimeline contains the provisioning phases of a machine in chronological order.
+type ProvisioningTimeline []ProvisioningPhase
+
+// A ProvisioningTransition is a span between two provisioning events whose duration is measured.
+type ProvisioningTransition struct {
+	From ProvisioningEventType
+	To   ProvisioningEventType
+}
+
+func (t ProvisioningTransition) String() string {
+	return string(t.From) + " -> " + string(t.To)
+}
+
+// ProvisioningTransitions are the transitions whose durations are measured.
+var ProvisioningTransitions = []ProvisioningTransition{
+	{From: ProvisioningEventPXEBooting, To: ProvisioningEventPreparing},
+	{From: ProvisioningEventPreparing, To: ProvisioningEventRegistering},
+	{From: ProvisioningEventRegistering, To: ProvisioningEventWaiting},
+	{From: ProvisioningEventInstalling, To: ProvisioningEventBootingNewKernel},
+	{From: ProvisioningEventBootingNewKernel, To: ProvisioningEventPhonedHome},
+	{From: ProvisioningEventInstalling, To: ProvisioningEventPhonedHome},
+}
+
+// A ProvisioningDuration is the measured duration of a transition.
+type ProvisioningDuration struct {
+	ProvisioningTransition
+	Start    time.Time
+	Duration time.Duration
+}
+
+// Durations returns the durations of all provisioning transitions in the timeline in chronological order.
+// A transition is only measured within the same provisioning cycle, reboots, crashes, reclaims and
+// unexpected transitions abort all pending measurements.
+func (t ProvisioningTimeline) Durations() []ProvisioningDuration {
+	var (
+		durations []ProvisioningDuration
+		pending   = map[int]time.Time{}
+	)
+
+	for _, phase := range t {
+		if !phase.Unexpected {
+			for i, tr := range ProvisioningTransitions {
+				start, ok := pending[i]
+				if !ok || tr.To != phase.Event {
+					continue
+				}
+				durations = append(durations, ProvisioningDuration{ProvisioningTransition: tr, Start: start, Duration: phase.Start.Sub(start)})
+				delete(pending, i)
+			}
+		}
+
+		switch phase.Event { //nolint:exhaustive
+		case ProvisioningEventPXEBooting, ProvisioningEventPlannedReboot, ProvisioningEventMachineReclaim, ProvisioningEventCrashed:
+			clear(pending)
+		default:
+			if phase.Unexpected {
+				clear(pending)
+			}
+		}
+
+		for i, tr := range ProvisioningTransitions {
+			if tr.From == phase.Event {
+				pending[i] = phase.Start
+			}
+		}
+	}
+
+	return durations
+}
+
+// A ProvisioningDurationStatistic summarizes the measured durations of a transition.
+type ProvisioningDurationStatistic struct {
+	ProvisioningTransition
+	Count int
+	P50   time.Duration
+	P95   time.Duration
+	Max   time.Duration
+}
+
+// NewProvisioningDurationStatistic calculates the statistic of the given durations of a transition.
+func NewProvisioningDurationStatistic(tr ProvisioningTransition, durations []time.Duration) ProvisioningDurationStatistic {
+	sorted := slices.Clone(durations)
+	slices.Sort(sorted)
+
+	return ProvisioningDurationStatistic{
+		ProvisioningTransition: tr,
+		Count:                  len(sorted),
+		P50:                    percentile(sorted, 50),
+		P95:                    percentile(sorted, 95),
+		Max:                    percentile(sorted, 100),
+	}
+}
+
+// percentile returns the nearest-rank percentile of the sorted durations.
+func percentile(sorted []time.Duration, p int) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	rank := (len(sorted)*p + 99) / 100
+	return sorted[max(rank, 1)-1]
+}
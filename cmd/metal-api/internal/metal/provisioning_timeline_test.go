@@ -0,0 +1,57 @@
+package metal
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestProvisioningTimeline_Durations(t *testing.T) {
+	start := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	at := func(minutes int) time.Time {
+		return start.Add(time.Duration(minutes) * time.Minute)
+	}
+
+	timeline := ProvisioningTimeline{
+		{Event: ProvisioningEventPXEBooting, Start: at(0)},
+		{Event: ProvisioningEventPreparing, Start: at(2)},
+		{Event: ProvisioningEventRegistering, Start: at(3)},
+		{Event: ProvisioningEventWaiting, Start: at(13)},
+		{Event: ProvisioningEventInstalling, Start: at(60)},
+		{Event: ProvisioningEventBootingNewKernel, Start: at(65)},
+		{Event: ProvisioningEventPhonedHome, Start: at(67)},
+		{Event: ProvisioningEventPlannedReboot, Start: at(100)},
+		{Event: ProvisioningEventPreparing, Start: at(102)},
+		{Event: ProvisioningEventCrashed, Start: at(103)},
+		{Event: ProvisioningEventRegistering, Start: at(104), Unexpected: true},
+	}
+
+	require.Equal(t, []ProvisioningDuration{
+		{ProvisioningTransition: ProvisioningTransition{From: ProvisioningEventPXEBooting, To: ProvisioningEventPreparing}, Start: at(0), Duration: 2 * time.Minute},
+		{ProvisioningTransition: ProvisioningTransition{From: ProvisioningEventPreparing, To: ProvisioningEventRegistering}, Start: at(2), Duration: time.Minute},
+		{ProvisioningTransition: ProvisioningTransition{From: ProvisioningEventRegistering, To: ProvisioningEventWaiting}, Start: at(3), Duration: 10 * time.Minute},
+		{ProvisioningTransition: ProvisioningTransition{From: ProvisioningEventInstalling, To: ProvisioningEventBootingNewKernel}, Start: at(60), Duration: 5 * time.Minute},
+		{ProvisioningTransition: ProvisioningTransition{From: ProvisioningEventBootingNewKernel, To: ProvisioningEventPhonedHome}, Start: at(65), Duration: 2 * time.Minute},
+		{ProvisioningTransition: ProvisioningTransition{From: ProvisioningEventInstalling, To: ProvisioningEventPhonedHome}, Start: at(60), Duration: 7 * time.Minute},
+	}, timeline.Durations())
+}
+
+func TestNewProvisioningDurationStatistic(t *testing.T) {
+	tr := ProvisioningTransition{From: ProvisioningEventPXEBooting, To: ProvisioningEventPreparing}
+
+	require.Equal(t, ProvisioningDurationStatistic{ProvisioningTransition: tr}, NewProvisioningDurationStatistic(tr, nil))
+
+	var durations []time.Duration
+	for i := 20; i > 0; i-- {
+		durations = append(durations, time.Duration(i)*time.Minute)
+	}
+
+	require.Equal(t, ProvisioningDurationStatistic{
+		ProvisioningTransition: tr,
+		Count:                  20,
+		P50:                    10 * time.Minute,
+		P95:                    19 * time.Minute,
+		Max:                    20 * time.Minute,
+	}, NewProvisioningDurationStatistic(tr, durations))
+}
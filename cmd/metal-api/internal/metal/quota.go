@@ -0,0 +1,49 @@
+package metal
+
+import (
+	"errors"
+	"fmt"
+)
+
+// QuotaKind names a project quota which is enforced by the metal-api.
+type QuotaKind string
+
+const (
+	// QuotaKindMachine is the machine quota of the masterdata quota set, it limits the amount of allocated firewalls of a project.
+	QuotaKindMachine QuotaKind = "machine"
+	// QuotaKindFirewall limits the amount of allocated firewalls of a project, it is configured with a project annotation.
+	QuotaKindFirewall QuotaKind = "firewall"
+	// QuotaKindSize limits the amount of allocated machines of a size of a project, it is configured with a project annotation.
+	QuotaKindSize QuotaKind = "size"
+	// QuotaKindNetwork limits the amount of child networks of a project, it is configured with a project annotation.
+	QuotaKindNetwork QuotaKind = "network"
+	// QuotaKindIP is the ip quota of the masterdata quota set, it limits the amount of ips of a project.
+	QuotaKindIP QuotaKind = "ip"
+)
+
+// QuotaExceededError is returned if an allocation is rejected because it would exceed a project quota.
+type QuotaExceededError struct {
+	Kind      QuotaKind
+	ProjectID string
+	// Scope is the size the quota is limited to, empty for project-wide quotas
+	Scope string
+	Max   int
+	Used  int
+}
+
+func (e *QuotaExceededError) Error() string {
+	kind := string(e.Kind)
+	if e.Scope != "" {
+		kind += " " + e.Scope
+	}
+	return fmt.Sprintf("project quota for %s reached max:%d used:%d", kind, e.Max, e.Used)
+}
+
+// IsQuotaExceeded returns the quota exceeded error if the given error was caused by an exceeded quota.
+func IsQuotaExceeded(err error) (*QuotaExceededError, bool) {
+	var qe *QuotaExceededError
+	if errors.As(err, &qe) {
+		return qe, true
+	}
+	return nil, false
+}
@@ -0,0 +1,105 @@
+package metal
+
+import (
+	"fmt"
+	"time"
+)
+
+// RemediationAction is an action which is executed automatically on machines with an issue.
+type RemediationAction string
+
+const (
+	// RemediationActionPowerCycle power cycles the machine
+	RemediationActionPowerCycle RemediationAction = "power-cycle"
+	// RemediationActionPXEReboot boots the machine from PXE
+	RemediationActionPXEReboot RemediationAction = "pxe-reboot"
+	// RemediationActionTaint taints the machine, such that it is not considered for allocations anymore
+	RemediationActionTaint RemediationAction = "taint"
+)
+
+// AllRemediationActions contains all remediation actions.
+var AllRemediationActions = []RemediationAction{RemediationActionPowerCycle, RemediationActionPXEReboot, RemediationActionTaint}
+
+// RemediationActionFrom returns the remediation action for the given name.
+func RemediationActionFrom(name string) (RemediationAction, error) {
+	for _, a := range AllRemediationActions {
+		if string(a) == name {
+			return a, nil
+		}
+	}
+	return "", fmt.Errorf("unknown remediation action: %q", name)
+}
+
+// MachineCommands returns the machine commands which are published to execute the action,
+// it is empty for actions which do not require the bmc.
+func (a RemediationAction) MachineCommands() []MachineCommand {
+	switch a {
+	case RemediationActionPowerCycle:
+		return []MachineCommand{MachineCycleCmd}
+	case RemediationActionPXEReboot:
+		return []MachineCommand{MachinePxeCmd, MachineResetCmd}
+	case RemediationActionTaint:
+		return nil
+	default:
+		return nil
+	}
+}
+
+// maxRemediationHistory limits the amount of remediations which are kept per machine.
+const maxRemediationHistory = 20
+
+// A MachineRemediation contains the automated remediations which were executed on a machine,
+// its id is the id of the machine.
+type MachineRemediation struct {
+	Base
+	Remediations []Remediation `rethinkdb:"remediations" json:"remediations"`
+}
+
+// A Remediation is a remediation action that was executed on a machine because of an issue.
+type Remediation struct {
+	Time      time.Time         `rethinkdb:"time" json:"time"`
+	IssueType string            `rethinkdb:"issuetype" json:"issuetype"`
+	Action    RemediationAction `rethinkdb:"action" json:"action"`
+	Error     string            `rethinkdb:"error" json:"error"`
+}
+
+// MachineRemediations is a slice of MachineRemediation
+type MachineRemediations []MachineRemediation
+
+// Last returns the latest remediation of the machine, or nil if there was none.
+func (mr *MachineRemediation) Last() *Remediation {
+	if len(mr.Remediations) == 0 {
+		return nil
+	}
+	return &mr.Remediations[len(mr.Remediations)-1]
+}
+
+// Add appends the given remediation and drops the oldest ones if the history is full.
+func (mr *MachineRemediation) Add(r Remediation) {
+	mr.Remediations = append(mr.Remediations, r)
+	if len(mr.Remediations) > maxRemediationHistory {
+		mr.Remediations = mr.Remediations[len(mr.Remediations)-maxRemediationHistory:]
+	}
+}
+
+// CountSince returns the amount of remediations of all machines which were executed after the given point in time.
+func (mrs MachineRemediations) CountSince(t time.Time) int {
+	count := 0
+	for _, mr := range mrs {
+		for _, r := range mr.Remediations {
+			if r.Time.After(t) {
+				count++
+			}
+		}
+	}
+	return count
+}
+
+// ByID creates a map of machine remediations with the machine id as the index.
+func (mrs MachineRemediations) ByID() map[string]MachineRemediation {
+	res := make(map[string]MachineRemediation)
+	for _, mr := range mrs {
+		res[mr.ID] = mr
+	}
+	return res
+}
@@ -13,6 +13,9 @@ type Size struct {
 	Base
 	Constraints []Constraint      `rethinkdb:"constraints" json:"constraints"`
 	Labels      map[string]string `rethinkdb:"labels" json:"labels"`
+	// ExpectedPowerWatts is the power draw of an allocated machine of this size, it is used to estimate the consumption
+	// of racks for machines which are powered off
+	ExpectedPowerWatts uint `rethinkdb:"expectedpowerwatts" json:"expectedpowerwatts"`
 }
 
 // ConstraintType ...
@@ -120,6 +123,23 @@ func (hw *MachineHardware) matches(constraints []Constraint, constraintType Cons
 	}
 }
 
+// Matches returns true if the given hardware matches all constraints of the size.
+func (s *Size) Matches(hardware MachineHardware) bool {
+	for _, c := range s.Constraints {
+		if !c.matches(hardware) {
+			return false
+		}
+	}
+
+	for _, ct := range allConstraintTypes {
+		if !hardware.matches(s.Constraints, ct) {
+			return false
+		}
+	}
+
+	return true
+}
+
 // FromHardware searches a Size for given hardware specs. It will search
 // for a size where the constraints matches the given hardware.
 func (sz Sizes) FromHardware(hardware MachineHardware) (*Size, error) {
@@ -127,18 +147,9 @@ func (sz Sizes) FromHardware(hardware MachineHardware) (*Size, error) {
 		matchedSizes []Size
 	)
 
-nextsize:
 	for _, s := range sz {
-		for _, c := range s.Constraints {
-			if !c.matches(hardware) {
-				continue nextsize
-			}
-		}
-
-		for _, ct := range allConstraintTypes {
-			if !hardware.matches(s.Constraints, ct) {
-				continue nextsize
-			}
+		if !s.Matches(hardware) {
+			continue
 		}
 
 		matchedSizes = append(matchedSizes, s)
@@ -3,6 +3,7 @@ package metal
 import (
 	"fmt"
 	"slices"
+	"time"
 
 	mdmv1 "github.com/metal-stack/masterdata-api/api/v1"
 )
@@ -15,6 +16,10 @@ type SizeReservation struct {
 	ProjectID    string            `rethinkdb:"projectid" json:"projectid"`
 	PartitionIDs []string          `rethinkdb:"partitionids" json:"partitionids"`
 	Labels       map[string]string `rethinkdb:"labels" json:"labels"`
+	// Start is the point in time from which on the reservation is honored, if not set it is honored immediately
+	Start *time.Time `rethinkdb:"start" json:"start"`
+	// End is the point in time from which on the reservation is not honored anymore, if not set it is honored forever
+	End *time.Time `rethinkdb:"end" json:"end"`
 }
 
 type SizeReservations []SizeReservation
@@ -47,6 +52,65 @@ func (rs *SizeReservations) ForPartition(partitionID string) SizeReservations {
 	return result
 }
 
+// Active returns the reservations which are honored at the given point in time.
+func (rs *SizeReservations) Active(now time.Time) SizeReservations {
+	if rs == nil {
+		return nil
+	}
+
+	var result SizeReservations
+	for _, r := range *rs {
+		if r.IsActive(now) {
+			result = append(result, r)
+		}
+	}
+
+	return result
+}
+
+// PeakAmount returns the highest sum of the amounts of the reservations which are active at the same time
+// between from and until, together with the first point in time at which it is reached. If until is nil,
+// the peak is searched without an upper bound.
+func (rs *SizeReservations) PeakAmount(from time.Time, until *time.Time) (int, time.Time) {
+	if rs == nil {
+		return 0, from
+	}
+
+	// the sum only changes when a reservation starts, so it is sufficient to look at these points in time
+	candidates := []time.Time{from}
+	for _, r := range *rs {
+		if r.Start == nil || !r.Start.After(from) {
+			continue
+		}
+		if until != nil && !r.Start.Before(*until) {
+			continue
+		}
+		candidates = append(candidates, *r.Start)
+	}
+	slices.SortFunc(candidates, func(a, b time.Time) int {
+		return a.Compare(b)
+	})
+
+	var (
+		peak   int
+		peakAt = from
+	)
+	for _, t := range candidates {
+		amount := 0
+		for _, r := range *rs {
+			if r.IsActive(t) {
+				amount += r.Amount
+			}
+		}
+		if amount > peak {
+			peak = amount
+			peakAt = t
+		}
+	}
+
+	return peak, peakAt
+}
+
 func (rs *SizeReservations) Validate(sizes SizeMap, partitions PartitionMap, projects map[string]*mdmv1.Project) error {
 	if rs == nil {
 		return nil
@@ -92,5 +156,22 @@ func (r *SizeReservation) Validate(sizes SizeMap, partitions PartitionMap, proje
 		return fmt.Errorf("project must exist before creating a size reservation")
 	}
 
+	if r.Start != nil && r.End != nil && !r.End.After(*r.Start) {
+		return fmt.Errorf("end of a size reservation must be after its start")
+	}
+
 	return nil
 }
+
+// IsActive returns true if the reservation is honored at the given point in time.
+func (r *SizeReservation) IsActive(now time.Time) bool {
+	if r.Start != nil && now.Before(*r.Start) {
+		return false
+	}
+	return !r.Expired(now)
+}
+
+// Expired returns true if the reservation ended before the given point in time and will never be honored again.
+func (r *SizeReservation) Expired(now time.Time) bool {
+	return r.End != nil && !now.Before(*r.End)
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"reflect"
 	"testing"
+	"time"
 
 	"github.com/google/go-cmp/cmp"
 	mdmv1 "github.com/metal-stack/masterdata-api/api/v1"
@@ -246,6 +247,29 @@ func TestReservations_Validate(t *testing.T) {
 			},
 			wantErr: fmt.Errorf("project must exist before creating a size reservation"),
 		},
+		{
+			name: "end before start",
+			sizes: SizeMap{
+				"c1": Size{},
+			},
+			partitions: PartitionMap{
+				"a": Partition{},
+			},
+			projects: map[string]*mdmv1.Project{
+				"1": {},
+			},
+			rs: &SizeReservations{
+				{
+					SizeID:       "c1",
+					Amount:       3,
+					ProjectID:    "1",
+					PartitionIDs: []string{"a"},
+					Start:        new(time.Date(2026, 11, 2, 0, 0, 0, 0, time.UTC)),
+					End:          new(time.Date(2026, 11, 1, 0, 0, 0, 0, time.UTC)),
+				},
+			},
+			wantErr: fmt.Errorf("end of a size reservation must be after its start"),
+		},
 		{
 			name: "valid reservation",
 			sizes: SizeMap{
@@ -280,3 +304,98 @@ func TestReservations_Validate(t *testing.T) {
 		})
 	}
 }
+
+func TestReservations_Active(t *testing.T) {
+	var (
+		day = func(d int) *time.Time {
+			return new(time.Date(2026, 11, d, 0, 0, 0, 0, time.UTC))
+		}
+		rs = SizeReservations{
+			{Base: Base{ID: "permanent"}},
+			{Base: Base{ID: "event"}, Start: day(10), End: day(12)},
+			{Base: Base{ID: "from"}, Start: day(11)},
+			{Base: Base{ID: "until"}, End: day(11)},
+		}
+		ids = func(rs SizeReservations) []string {
+			var res []string
+			for _, r := range rs {
+				res = append(res, r.ID)
+			}
+			return res
+		}
+	)
+
+	if diff := cmp.Diff([]string{"permanent", "until"}, ids(rs.Active(*day(1)))); diff != "" {
+		t.Errorf("diff (-want +got):\n%s", diff)
+	}
+	if diff := cmp.Diff([]string{"permanent", "event", "until"}, ids(rs.Active(*day(10)))); diff != "" {
+		t.Errorf("diff (-want +got):\n%s", diff)
+	}
+	if diff := cmp.Diff([]string{"permanent", "event", "from"}, ids(rs.Active(*day(11)))); diff != "" {
+		t.Errorf("diff (-want +got):\n%s", diff)
+	}
+	if diff := cmp.Diff([]string{"permanent", "from"}, ids(rs.Active(*day(12)))); diff != "" {
+		t.Errorf("diff (-want +got):\n%s", diff)
+	}
+	if !rs[3].Expired(*day(11)) || rs[2].Expired(*day(30)) {
+		t.Errorf("unexpected expiry")
+	}
+}
+
+func TestReservations_PeakAmount(t *testing.T) {
+	day := func(d int) *time.Time {
+		return new(time.Date(2026, 11, d, 0, 0, 0, 0, time.UTC))
+	}
+	rs := SizeReservations{
+		{Amount: 2},
+		{Amount: 3, Start: day(10), End: day(12)},
+		{Amount: 4, Start: day(11), End: day(13)},
+		{Amount: 5, Start: day(12)},
+	}
+
+	tests := []struct {
+		name       string
+		from       time.Time
+		until      *time.Time
+		wantAmount int
+		wantAt     time.Time
+	}{
+		{
+			name:       "before all windows",
+			from:       *day(1),
+			until:      day(10),
+			wantAmount: 2,
+			wantAt:     *day(1),
+		},
+		{
+			name:       "overlapping windows",
+			from:       *day(1),
+			until:      day(12),
+			wantAmount: 9,
+			wantAt:     *day(11),
+		},
+		{
+			name:       "unbounded",
+			from:       *day(1),
+			wantAmount: 11,
+			wantAt:     *day(12),
+		},
+		{
+			name:       "after the end of the windows",
+			from:       *day(20),
+			wantAmount: 7,
+			wantAt:     *day(20),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			amount, at := rs.PeakAmount(tt.from, tt.until)
+			if amount != tt.wantAmount {
+				t.Errorf("PeakAmount() amount = %d, want %d", amount, tt.wantAmount)
+			}
+			if !at.Equal(tt.wantAt) {
+				t.Errorf("PeakAmount() at = %s, want %s", at, tt.wantAt)
+			}
+		})
+	}
+}
@@ -66,6 +66,20 @@ type SwitchEvent struct {
 	Type     EventType `json:"type"`
 	Machine  Machine   `json:"machine"`
 	Switches []Switch  `json:"switches"`
+	// DeduplicationID is set if the event is published through the outbox, an event with the same id may be delivered more than once
+	DeduplicationID string `json:"deduplicationid,omitempty"`
+}
+
+// WithDeduplicationID returns a copy of the event with the given deduplication id.
+func (e SwitchEvent) WithDeduplicationID(id string) any {
+	e.DeduplicationID = id
+	return e
+}
+
+// WithoutSecrets returns a copy of the event in which the secrets of the machine are removed, they are not needed to configure the switches.
+func (e SwitchEvent) WithoutSecrets() (any, string) {
+	e.Machine = *e.Machine.WithoutSecrets()
+	return e, ""
 }
 
 // SwitchStatus stores the received switch notifications in a separate table
@@ -0,0 +1,299 @@
+package metrics
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/metal-stack/metal-api/cmd/metal-api/internal/datastore"
+	"github.com/metal-stack/metal-api/cmd/metal-api/internal/ipam"
+	"github.com/metal-stack/metal-api/cmd/metal-api/internal/issues"
+	"github.com/metal-stack/metal-api/cmd/metal-api/internal/metal"
+	v1 "github.com/metal-stack/metal-api/cmd/metal-api/internal/service/v1"
+	"github.com/metal-stack/metal-lib/pkg/pointer"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// CapacityFunc calculates the machine capacities of all partitions.
+type CapacityFunc func() ([]v1.PartitionCapacity, error)
+
+// DomainCollector exports metrics on the state of partitions, machines, integer pools and networks.
+//
+// Gathering these metrics is too expensive to be done on every scrape, so they are updated periodically
+// and the last state is returned on scrape.
+type DomainCollector struct {
+	log      *slog.Logger
+	ds       datastore.Store
+	ipamer   ipam.IPAMer
+	capacity CapacityFunc
+
+	mtx sync.Mutex
+
+	partitionCapacity        *prometheus.GaugeVec
+	machineLiveliness        *prometheus.GaugeVec
+	machineProvisioningState *prometheus.GaugeVec
+	machineIssues            *prometheus.GaugeVec
+	integerPool              *prometheus.GaugeVec
+	networkIPs               *prometheus.GaugeVec
+	networkPrefixes          *prometheus.GaugeVec
+	lastUpdate               prometheus.Gauge
+}
+
+// NewDomainCollector returns a new domain collector, it needs to be registered and started with Run.
+func NewDomainCollector(log *slog.Logger, ds datastore.Store, ipamer ipam.IPAMer, capacity CapacityFunc) *DomainCollector {
+	return &DomainCollector{
+		log:      log,
+		ds:       ds,
+		ipamer:   ipamer,
+		capacity: capacity,
+		partitionCapacity: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: "metal",
+			Subsystem: "partition",
+			Name:      "capacity",
+			Help:      "The machine capacity of a partition per size, the type corresponds to the fields of the partition capacity endpoint.",
+		}, []string{"partition", "size", "type"}),
+		machineLiveliness: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: "metal",
+			Subsystem: "machine",
+			Name:      "liveliness",
+			Help:      "The number of machines per partition and liveliness.",
+		}, []string{"partition", "liveliness"}),
+		machineProvisioningState: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: "metal",
+			Subsystem: "machine",
+			Name:      "provisioning_state",
+			Help:      "The number of machines per partition and last provisioning event.",
+		}, []string{"partition", "state"}),
+		machineIssues: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: "metal",
+			Subsystem: "machine",
+			Name:      "issues",
+			Help:      "The number of machines which have an issue of the given type.",
+		}, []string{"type", "severity"}),
+		integerPool: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: "metal",
+			Subsystem: "integer_pool",
+			Name:      "integers",
+			Help:      "The number of free and used integers of the asn and vrf pools.",
+		}, []string{"pool", "state"}),
+		networkIPs: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: "metal",
+			Subsystem: "network",
+			Name:      "prefix_ips",
+			Help:      "The number of available and used ips of a network prefix, child networks are omitted.",
+		}, []string{"network", "partition", "prefix", "state"}),
+		networkPrefixes: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: "metal",
+			Subsystem: "network",
+			Name:      "prefix_prefixes",
+			Help:      "The number of available and used child prefixes of a network prefix, child networks are omitted.",
+		}, []string{"network", "partition", "prefix", "state"}),
+		lastUpdate: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: "metal",
+			Subsystem: "api",
+			Name:      "domain_metrics_last_update_timestamp_seconds",
+			Help:      "The point in time when the domain metrics were updated successfully for the last time.",
+		}),
+	}
+}
+
+func (c *DomainCollector) collectors() []prometheus.Collector {
+	return []prometheus.Collector{
+		c.partitionCapacity,
+		c.machineLiveliness,
+		c.machineProvisioningState,
+		c.machineIssues,
+		c.integerPool,
+		c.networkIPs,
+		c.networkPrefixes,
+		c.lastUpdate,
+	}
+}
+
+// Describe implements prometheus.Collector.
+func (c *DomainCollector) Describe(ch chan<- *prometheus.Desc) {
+	for _, collector := range c.collectors() {
+		collector.Describe(ch)
+	}
+}
+
+// Collect implements prometheus.Collector.
+func (c *DomainCollector) Collect(ch chan<- prometheus.Metric) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	for _, collector := range c.collectors() {
+		collector.Collect(ch)
+	}
+}
+
+// Run updates the metrics in the given interval until the context is done.
+func (c *DomainCollector) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		err := c.Update(ctx)
+		if err != nil {
+			c.log.Error("unable to update domain metrics", "error", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Update gathers the current state and replaces the exported metrics with it.
+func (c *DomainCollector) Update(ctx context.Context) error {
+	capacities, err := c.capacity()
+	if err != nil {
+		return err
+	}
+
+	ms, err := c.ds.ListMachines()
+	if err != nil {
+		return err
+	}
+
+	ecs, err := c.ds.ListProvisioningEventContainers()
+	if err != nil {
+		return err
+	}
+
+	sizes, err := c.ds.ListSizes()
+	if err != nil {
+		return err
+	}
+
+	snapshots, err := c.ds.ListLatestHardwareSnapshots()
+	if err != nil {
+		return err
+	}
+
+	machinesWithIssues, err := issues.Find(&issues.Config{
+		Machines:          ms,
+		EventContainers:   ecs,
+		Sizes:             sizes,
+		HardwareSnapshots: snapshots,
+	})
+	if err != nil {
+		return err
+	}
+
+	pools := map[datastore.IntegerPoolType]*datastore.IntegerPoolUsage{}
+	for _, pool := range []datastore.IntegerPooler{c.ds.GetASNPool(), c.ds.GetVRFPool()} {
+		usage, err := pool.Usage()
+		if err != nil {
+			return err
+		}
+		pools[datastore.IntegerPoolType(pool.String())] = usage
+	}
+
+	nws, err := c.ds.ListNetworks()
+	if err != nil {
+		return err
+	}
+
+	type prefixUsage struct {
+		network metal.Network
+		prefix  string
+		usage   *metal.NetworkUsage
+	}
+	var usages []prefixUsage
+	for _, nw := range nws {
+		if nw.ParentNetworkID != "" {
+			continue
+		}
+		for _, prefix := range nw.Prefixes {
+			usage, err := c.ipamer.PrefixUsage(ctx, prefix.String())
+			if err != nil {
+				return err
+			}
+			usages = append(usages, prefixUsage{network: nw, prefix: prefix.String(), usage: usage})
+		}
+	}
+
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	c.partitionCapacity.Reset()
+	for _, pc := range capacities {
+		for _, sc := range pc.ServerCapacities {
+			for typ, value := range map[string]int{
+				"total":                  sc.Total,
+				"allocated":              sc.Allocated,
+				"allocatable":            sc.Allocatable,
+				"free":                   sc.Free,
+				"unavailable":            sc.Unavailable,
+				"faulty":                 sc.Faulty,
+				"phoned_home":            sc.PhonedHome,
+				"waiting":                sc.Waiting,
+				"other":                  sc.Other,
+				"reservations":           sc.Reservations,
+				"used_reservations":      sc.UsedReservations,
+				"remaining_reservations": sc.RemainingReservations,
+			} {
+				c.partitionCapacity.WithLabelValues(pc.ID, sc.Size, typ).Set(float64(value))
+			}
+		}
+	}
+
+	ecsByID := ecs.ByID()
+
+	c.machineLiveliness.Reset()
+	c.machineProvisioningState.Reset()
+	for _, m := range ms {
+		var (
+			liveliness = metal.MachineLivelinessUnknown
+			state      = "none"
+		)
+		if ec, ok := ecsByID[m.ID]; ok {
+			if ec.Liveliness != "" {
+				liveliness = ec.Liveliness
+			}
+			if len(ec.Events) > 0 {
+				state = string(pointer.FirstOrZero(ec.Events).Event)
+			}
+		}
+
+		c.machineLiveliness.WithLabelValues(m.PartitionID, string(liveliness)).Inc()
+		c.machineProvisioningState.WithLabelValues(m.PartitionID, state).Inc()
+	}
+
+	c.machineIssues.Reset()
+	for _, issue := range issues.All() {
+		c.machineIssues.WithLabelValues(string(issue.Type), string(issue.Severity)).Set(0)
+	}
+	for _, m := range machinesWithIssues {
+		for _, issue := range m.Issues {
+			c.machineIssues.WithLabelValues(string(issue.Type), string(issue.Severity)).Inc()
+		}
+	}
+
+	c.integerPool.Reset()
+	for pool, usage := range pools {
+		name := "vrf"
+		if pool == datastore.ASNIntegerPool {
+			name = "asn"
+		}
+		c.integerPool.WithLabelValues(name, "free").Set(float64(usage.Free))
+		c.integerPool.WithLabelValues(name, "used").Set(float64(usage.Used))
+	}
+
+	c.networkIPs.Reset()
+	c.networkPrefixes.Reset()
+	for _, u := range usages {
+		c.networkIPs.WithLabelValues(u.network.ID, u.network.PartitionID, u.prefix, "available").Set(float64(u.usage.AvailableIPs))
+		c.networkIPs.WithLabelValues(u.network.ID, u.network.PartitionID, u.prefix, "used").Set(float64(u.usage.UsedIPs))
+		c.networkPrefixes.WithLabelValues(u.network.ID, u.network.PartitionID, u.prefix, "available").Set(float64(u.usage.AvailablePrefixes))
+		c.networkPrefixes.WithLabelValues(u.network.ID, u.network.PartitionID, u.prefix, "used").Set(float64(u.usage.UsedPrefixes))
+	}
+
+	c.lastUpdate.SetToCurrentTime()
+
+	return nil
+}
@@ -0,0 +1,79 @@
+package metrics
+
+import (
+	"context"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/metal-stack/metal-api/cmd/metal-api/internal/datastore"
+	"github.com/metal-stack/metal-api/cmd/metal-api/internal/ipam"
+	"github.com/metal-stack/metal-api/cmd/metal-api/internal/issues"
+	"github.com/metal-stack/metal-api/cmd/metal-api/internal/metal"
+	v1 "github.com/metal-stack/metal-api/cmd/metal-api/internal/service/v1"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDomainCollector(t *testing.T) {
+	var (
+		ctx    = context.Background()
+		ds     = datastore.NewMemory(slog.Default())
+		ipamer = ipam.InitTestIpam(t)
+		now    = time.Now()
+	)
+
+	require.NoError(t, ds.CreateMachine(&metal.Machine{Base: metal.Base{ID: "m1"}, PartitionID: "p1", SizeID: "s1"}))
+	require.NoError(t, ds.CreateMachine(&metal.Machine{Base: metal.Base{ID: "m2"}, PartitionID: "p1", SizeID: "s1"}))
+	require.NoError(t, ds.CreateProvisioningEventContainer(&metal.ProvisioningEventContainer{
+		Base:       metal.Base{ID: "m1"},
+		Liveliness: metal.MachineLivelinessAlive,
+		Events: metal.ProvisioningEvents{
+			{Time: now.Add(-time.Minute), Event: metal.ProvisioningEventWaiting},
+			{Time: now.Add(-3 * time.Minute), Event: metal.ProvisioningEventRegistering},
+		},
+	}))
+
+	prefix, _, err := metal.NewPrefixFromCIDR("10.0.0.0/24")
+	require.NoError(t, err)
+	require.NoError(t, ipamer.CreatePrefix(ctx, *prefix))
+	_, err = ipamer.AllocateIP(ctx, *prefix)
+	require.NoError(t, err)
+	require.NoError(t, ds.CreateNetwork(&metal.Network{Base: metal.Base{ID: "internet"}, PartitionID: "p1", Prefixes: metal.Prefixes{*prefix}}))
+
+	_, err = ds.GetASNPool().AcquireRandomUniqueInteger()
+	require.NoError(t, err)
+
+	c := NewDomainCollector(slog.Default(), ds, ipamer, func() ([]v1.PartitionCapacity, error) {
+		return []v1.PartitionCapacity{
+			{
+				Common:           v1.Common{Identifiable: v1.Identifiable{ID: "p1"}},
+				ServerCapacities: v1.ServerCapacities{{Size: "s1", Total: 2, Free: 1}},
+			},
+		}, nil
+	})
+
+	require.NoError(t, c.Update(ctx))
+
+	require.Equal(t, float64(2), testutil.ToFloat64(c.partitionCapacity.WithLabelValues("p1", "s1", "total")))
+	require.Equal(t, float64(1), testutil.ToFloat64(c.partitionCapacity.WithLabelValues("p1", "s1", "free")))
+
+	require.Equal(t, float64(1), testutil.ToFloat64(c.machineLiveliness.WithLabelValues("p1", "Alive")))
+	require.Equal(t, float64(1), testutil.ToFloat64(c.machineLiveliness.WithLabelValues("p1", "Unknown")))
+	require.Equal(t, float64(1), testutil.ToFloat64(c.machineProvisioningState.WithLabelValues("p1", "Waiting")))
+	require.Equal(t, float64(1), testutil.ToFloat64(c.machineProvisioningState.WithLabelValues("p1", "none")))
+
+	require.Equal(t, float64(1), testutil.ToFloat64(c.machineIssues.WithLabelValues(string(issues.TypeNoEventContainer), string(issues.SeverityMajor))))
+	require.Equal(t, float64(0), testutil.ToFloat64(c.machineIssues.WithLabelValues(string(issues.TypeCrashLoop), string(issues.SeverityMajor))))
+
+	require.Equal(t, float64(1), testutil.ToFloat64(c.integerPool.WithLabelValues("asn", "used")))
+	require.Equal(t, float64(0), testutil.ToFloat64(c.integerPool.WithLabelValues("vrf", "used")))
+
+	// the network and broadcast addresses are counted as used by the ipam
+	require.Equal(t, float64(3), testutil.ToFloat64(c.networkIPs.WithLabelValues("internet", "p1", "10.0.0.0/24", "used")))
+	require.Equal(t, float64(256), testutil.ToFloat64(c.networkIPs.WithLabelValues("internet", "p1", "10.0.0.0/24", "available")))
+
+	problems, err := testutil.CollectAndLint(c)
+	require.NoError(t, err)
+	require.Empty(t, problems)
+}
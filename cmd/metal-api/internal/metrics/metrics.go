@@ -6,6 +6,8 @@ import (
 	"time"
 
 	"github.com/emicklei/go-restful/v3"
+	"github.com/metal-stack/metal-api/cmd/metal-api/internal/fsm"
+	"github.com/metal-stack/metal-api/cmd/metal-api/internal/metal"
 	"github.com/prometheus/client_golang/prometheus"
 	"google.golang.org/grpc"
 )
@@ -41,10 +43,20 @@ var (
 		},
 		[]string{"method"},
 	)
+	provisioningDuration = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Namespace: "metal",
+			Subsystem: "machine",
+			Name:      "provisioning_duration_seconds",
+			Help:      "A histogram of the durations of provisioning transitions, observed when the event completing the transition arrives.",
+			Buckets:   []float64{30, 60, 120, 300, 600, 900, 1800, 3600},
+		},
+		[]string{"partition", "size", "image", "transition"},
+	)
 )
 
 func init() {
-	prometheus.MustRegister(counter, duration, grpcDuration)
+	prometheus.MustRegister(counter, duration, grpcDuration, provisioningDuration)
 }
 
 func RestfulMetrics(req *restful.Request, resp *restful.Response, chain *restful.FilterChain) {
@@ -60,3 +72,19 @@ func GrpcMetrics(ctx context.Context, req any, info *grpc.UnaryServerInfo, handl
 	grpcDuration.WithLabelValues(info.FullMethod).Observe(time.Since(n).Seconds())
 	return resp, err
 }
+
+// ObserveProvisioningEvent observes the durations of the provisioning transitions which were completed by the given event
+// that was just applied to the event container of the machine.
+func ObserveProvisioningEvent(m *metal.Machine, ec *metal.ProvisioningEventContainer, event *metal.ProvisioningEvent) {
+	var image string
+	if m.Allocation != nil {
+		image = m.Allocation.ImageID
+	}
+
+	for _, d := range fsm.ProvisioningTimeline(ec).Durations() {
+		if !d.Start.Add(d.Duration).Equal(event.Time) {
+			continue
+		}
+		provisioningDuration.WithLabelValues(m.PartitionID, m.SizeID, image, d.String()).Observe(d.Duration.Seconds())
+	}
+}
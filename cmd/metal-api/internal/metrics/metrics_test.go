@@ -0,0 +1,66 @@
+package metrics
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/metal-stack/metal-api/cmd/metal-api/internal/datastore"
+	"github.com/metal-stack/metal-api/cmd/metal-api/internal/metal"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func TestObserveProvisioningEvent(t *testing.T) {
+	var (
+		ctx = context.Background()
+		ds  = datastore.NewMemory(slog.Default())
+		m   = &metal.Machine{Base: metal.Base{ID: "m1"}, PartitionID: "p1", SizeID: "s1"}
+		now = time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC)
+	)
+
+	require.NoError(t, ds.CreateMachine(m))
+
+	for _, ev := range []metal.ProvisioningEvent{
+		{Time: now, Event: metal.ProvisioningEventPXEBooting},
+		// subsequent pxe boot events extend the phase and must not complete a transition
+		{Time: now.Add(10 * time.Second), Event: metal.ProvisioningEventPXEBooting},
+		{Time: now.Add(40 * time.Second), Event: metal.ProvisioningEventPreparing},
+		{Time: now.Add(100 * time.Second), Event: metal.ProvisioningEventRegistering},
+	} {
+		ec, err := ds.ProvisioningEventForMachine(ctx, slog.Default(), &ev, m.ID)
+		require.NoError(t, err)
+		ObserveProvisioningEvent(m, ec, &ev)
+	}
+
+	expected := `
+# HELP metal_machine_provisioning_duration_seconds A histogram of the durations of provisioning transitions, observed when the event completing the transition arrives.
+# TYPE metal_machine_provisioning_duration_seconds histogram
+metal_machine_provisioning_duration_seconds_bucket{image="",partition="p1",size="s1",transition="PXE Booting -> Preparing",le="30"} 0
+metal_machine_provisioning_duration_seconds_bucket{image="",partition="p1",size="s1",transition="PXE Booting -> Preparing",le="60"} 1
+metal_machine_provisioning_duration_seconds_bucket{image="",partition="p1",size="s1",transition="PXE Booting -> Preparing",le="120"} 1
+metal_machine_provisioning_duration_seconds_bucket{image="",partition="p1",size="s1",transition="PXE Booting -> Preparing",le="300"} 1
+metal_machine_provisioning_duration_seconds_bucket{image="",partition="p1",size="s1",transition="PXE Booting -> Preparing",le="600"} 1
+metal_machine_provisioning_duration_seconds_bucket{image="",partition="p1",size="s1",transition="PXE Booting -> Preparing",le="900"} 1
+metal_machine_provisioning_duration_seconds_bucket{image="",partition="p1",size="s1",transition="PXE Booting -> Preparing",le="1800"} 1
+metal_machine_provisioning_duration_seconds_bucket{image="",partition="p1",size="s1",transition="PXE Booting -> Preparing",le="3600"} 1
+metal_machine_provisioning_duration_seconds_bucket{image="",partition="p1",size="s1",transition="PXE Booting -> Preparing",le="+Inf"} 1
+metal_machine_provisioning_duration_seconds_sum{image="",partition="p1",size="s1",transition="PXE Booting -> Preparing"} 40
+metal_machine_provisioning_duration_seconds_count{image="",partition="p1",size="s1",transition="PXE Booting -> Preparing"} 1
+metal_machine_provisioning_duration_seconds_bucket{image="",partition="p1",size="s1",transition="Preparing -> Registering",le="30"} 0
+metal_machine_provisioning_duration_seconds_bucket{image="",partition="p1",size="s1",transition="Preparing -> Registering",le="60"} 1
+metal_machine_provisioning_duration_seconds_bucket{image="",partition="p1",size="s1",transition="Preparing -> Registering",le="120"} 1
+metal_machine_provisioning_duration_seconds_bucket{image="",partition="p1",size="s1",transition="Preparing -> Registering",le="300"} 1
+metal_machine_provisioning_duration_seconds_bucket{image="",partition="p1",size="s1",transition="Preparing -> Registering",le="600"} 1
+metal_machine_provisioning_duration_seconds_bucket{image="",partition="p1",size="s1",transition="Preparing -> Registering",le="900"} 1
+metal_machine_provisioning_duration_seconds_bucket{image="",partition="p1",size="s1",transition="Preparing -> Registering",le="1800"} 1
+metal_machine_provisioning_duration_seconds_bucket{image="",partition="p1",size="s1",transition="Preparing -> Registering",le="3600"} 1
+metal_machine_provisioning_duration_seconds_bucket{image="",partition="p1",size="s1",transition="Preparing -> Registering",le="+Inf"} 1
+metal_machine_provisioning_duration_seconds_sum{image="",partition="p1",size="s1",transition="Preparing -> Registering"} 60
+metal_machine_provisioning_duration_seconds_count{image="",partition="p1",size="s1",transition="Preparing -> Registering"} 1
+`
+
+	require.NoError(t, testutil.CollectAndCompare(provisioningDuration, strings.NewReader(expected)))
+}
@@ -0,0 +1,493 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/emicklei/go-restful/v3"
+	"github.com/google/uuid"
+	mdmv1 "github.com/metal-stack/masterdata-api/api/v1"
+	mdm "github.com/metal-stack/masterdata-api/pkg/client"
+	"github.com/metal-stack/metal-api/cmd/metal-api/internal/datastore"
+	"github.com/metal-stack/metal-api/cmd/metal-api/internal/ipam"
+	"github.com/metal-stack/metal-api/cmd/metal-api/internal/metal"
+	v1 "github.com/metal-stack/metal-api/cmd/metal-api/internal/service/v1"
+	"github.com/metal-stack/metal-lib/bus"
+	"github.com/metal-stack/metal-lib/httperrors"
+	"github.com/metal-stack/metal-lib/pkg/pointer"
+	"github.com/metal-stack/security"
+)
+
+const (
+	pendingAllocationDefaultTTL = 30 * time.Minute
+	pendingAllocationMaxTTL     = 24 * time.Hour
+	// finished allocations are kept for this duration after they expired, such that clients can still poll the result
+	pendingAllocationRetention = time.Hour
+	// allocations which are in allocating state for longer than this timeout were aborted, e.g. by a restart of the metal-api
+	pendingAllocationStaleTimeout = 5 * time.Minute
+	allocationQueueInterval       = 30 * time.Second
+)
+
+// errInvalidQueuedAllocation marks queued allocations which can never be fulfilled, all other errors are retried until the allocation expires.
+var errInvalidQueuedAllocation = errors.New("invalid queued allocation")
+
+// AllocationQueue fulfills queued machine allocations as soon as machines of the requested size become waiting.
+type AllocationQueue struct {
+	log       *slog.Logger
+	ds        datastore.Store
+	ipamer    ipam.IPAMer
+	mdc       mdm.Client
+	actor     *asyncActor
+	publisher bus.Publisher
+}
+
+type allocationQueueKey struct {
+	partitionID string
+	sizeID      string
+}
+
+// NewAllocationQueue returns a new allocation queue.
+func NewAllocationQueue(log *slog.Logger, ds datastore.Store, publisher bus.Publisher, ep *bus.Endpoints, ipamer ipam.IPAMer, mdc mdm.Client) (*AllocationQueue, error) {
+	actor, err := newAsyncActor(log, ep, ds, ipamer)
+	if err != nil {
+		return nil, fmt.Errorf("cannot create async actor: %w", err)
+	}
+
+	return &AllocationQueue{
+		log:       log,
+		ds:        ds,
+		ipamer:    ipamer,
+		mdc:       mdc,
+		actor:     actor,
+		publisher: publisher,
+	}, nil
+}
+
+// Run processes the queue until the given context is done.
+//
+// Machines which become waiting are observed through the datastore, additionally the whole queue is processed periodically
+// to expire allocations and to catch up on changes which were missed.
+func (q *AllocationQueue) Run(ctx context.Context) {
+	ticker := time.NewTicker(allocationQueueInterval)
+	defer ticker.Stop()
+
+	var changes <-chan datastore.EntityChange
+	watch := func() {
+		var err error
+		changes, err = q.ds.Watch(ctx, "machine")
+		if err != nil {
+			q.log.Error("unable to watch machines, queued allocations are only processed periodically", "error", err)
+		}
+	}
+	watch()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case change, ok := <-changes:
+			if !ok {
+				changes = nil
+				continue
+			}
+			m, ok := change.New.(*metal.Machine)
+			if !ok || !becameWaiting(change.Old, m) {
+				continue
+			}
+			q.process(ctx, allocationQueueKey{partitionID: m.PartitionID, sizeID: m.SizeID})
+		case <-ticker.C:
+			if changes == nil {
+				watch()
+			}
+			keys, err := maintainPendingAllocations(q.log, q.ds, time.Now())
+			if err != nil {
+				q.log.Error("unable to maintain queued allocations", "error", err)
+				continue
+			}
+			for key := range keys {
+				q.process(ctx, key)
+			}
+		}
+	}
+}
+
+func (q *AllocationQueue) process(ctx context.Context, key allocationQueueKey) {
+	err := fulfillPendingAllocations(ctx, q.log, q.ds, q.ipamer, q.mdc, q.actor, q.publisher, key.partitionID, key.sizeID)
+	if err != nil {
+		q.log.Error("unable to fulfill queued allocations", "partition", key.partitionID, "size", key.sizeID, "error", err)
+	}
+}
+
+func becameWaiting(old metal.Entity, m *metal.Machine) bool {
+	if !m.Waiting || m.PreAllocated || m.Allocation != nil {
+		return false
+	}
+	o, ok := old.(*metal.Machine)
+	return !ok || !o.Waiting || o.PreAllocated || o.Allocation != nil
+}
+
+// fulfillPendingAllocations allocates machines for the pending allocations of the given partition and size in the order
+// of their priority until no more machines are available for any project.
+func fulfillPendingAllocations(ctx context.Context, log *slog.Logger, ds datastore.Store, ipamer ipam.IPAMer, mdc mdm.Client, actor *asyncActor, publisher bus.Publisher, partitionID, sizeID string) error {
+	var pending metal.PendingAllocations
+	err := ds.SearchPendingAllocations(&datastore.PendingAllocationSearchQuery{
+		PartitionID: &partitionID,
+		SizeID:      &sizeID,
+		State:       new(string(metal.PendingAllocationStatePending)),
+	}, &pending)
+	if err != nil {
+		return err
+	}
+
+	pending.SortByPriority()
+
+	now := time.Now()
+	for i := range pending {
+		if now.After(pending[i].Expires) {
+			continue
+		}
+
+		available, err := fulfillPendingAllocation(ctx, log, ds, ipamer, mdc, actor, publisher, &pending[i])
+		if err != nil {
+			return err
+		}
+		if !available {
+			return nil
+		}
+	}
+
+	return nil
+}
+
+// fulfillPendingAllocation tries to allocate a machine for the given pending allocation,
+// it returns false if there is no machine available for any allocation.
+func fulfillPendingAllocation(ctx context.Context, log *slog.Logger, ds datastore.Store, ipamer ipam.IPAMer, mdc mdm.Client, actor *asyncActor, publisher bus.Publisher, a *metal.PendingAllocation) (bool, error) {
+	// claiming the allocation prevents that it is fulfilled by another metal-api instance at the same time
+	claimed := *a
+	claimed.State = metal.PendingAllocationStateAllocating
+	err := ds.UpdatePendingAllocation(a, &claimed)
+	if err != nil {
+		if metal.IsConflict(err) {
+			return true, nil
+		}
+		return false, err
+	}
+
+	var (
+		finished  = claimed
+		available = true
+	)
+	finished.Message = ""
+
+	m, err := allocatePendingAllocation(ctx, log, ds, ipamer, mdc, actor, publisher, &claimed)
+	switch {
+	case err == nil:
+		finished.State = metal.PendingAllocationStateFulfilled
+		finished.MachineID = m.ID
+		log.Info("fulfilled queued allocation", "id", a.ID, "machineID", m.ID)
+	case errors.Is(err, datastore.ErrNoMachineAvailableForProject):
+		// the machines which are reserved for other projects can still be allocated by their pending allocations
+		finished.State = metal.PendingAllocationStatePending
+	case errors.Is(err, datastore.ErrNoMachineAvailable), errors.Is(err, datastore.ErrTooManyParallelAllocations):
+		finished.State = metal.PendingAllocationStatePending
+		available = false
+	case isPermanentAllocationError(err):
+		finished.State = metal.PendingAllocationStateFailed
+		finished.Message = err.Error()
+		log.Error("queued allocation failed", "id", a.ID, "error", err)
+	default:
+		// errors like an unavailable ipam, masterdata-api or datastore may be gone with the next attempt
+		finished.State = metal.PendingAllocationStatePending
+		finished.Message = err.Error()
+		log.Warn("queued allocation failed, retrying", "id", a.ID, "error", err)
+	}
+
+	err = ds.UpdatePendingAllocation(&claimed, &finished)
+	if err != nil {
+		return false, err
+	}
+
+	return available, nil
+}
+
+func allocatePendingAllocation(ctx context.Context, log *slog.Logger, ds datastore.Store, ipamer ipam.IPAMer, mdc mdm.Client, actor *asyncActor, publisher bus.Publisher, a *metal.PendingAllocation) (*metal.Machine, error) {
+	var req v1.MachineAllocateRequest
+	err := json.Unmarshal(a.Request, &req)
+	if err != nil {
+		return nil, fmt.Errorf("%w, unable to decode queued allocate request: %w", errInvalidQueuedAllocation, err)
+	}
+
+	spec, err := createMachineAllocationSpec(ds, req, nil, &security.User{EMail: a.Creator})
+	if err != nil {
+		if metal.IsNotFound(err) {
+			// e.g. the image or network of the request was deleted in the meantime
+			return nil, fmt.Errorf("%w, %w", errInvalidQueuedAllocation, err)
+		}
+		return nil, err
+	}
+
+	err = validateAllocationSpec(spec)
+	if err != nil {
+		return nil, fmt.Errorf("%w, %w", errInvalidQueuedAllocation, err)
+	}
+
+	return allocateMachine(ctx, log, ds, ipamer, spec, mdc, actor, publisher)
+}
+
+// isPermanentAllocationError returns true if the given error of a queued allocation would occur again on the next attempt.
+func isPermanentAllocationError(err error) bool {
+	if _, ok := metal.IsQuotaExceeded(err); ok {
+		return true
+	}
+	return errors.Is(err, errInvalidQueuedAllocation)
+}
+
+// maintainPendingAllocations expires pending allocations which reached their ttl, requeues allocations whose allocation
+// was aborted and deletes finished allocations after their retention. it returns the partitions and sizes which have
+// pending allocations.
+func maintainPendingAllocations(log *slog.Logger, ds datastore.Store, now time.Time) (map[allocationQueueKey]bool, error) {
+	all, err := ds.ListPendingAllocations()
+	if err != nil {
+		return nil, err
+	}
+
+	keys := map[allocationQueueKey]bool{}
+	for i := range all {
+		var (
+			a   = all[i]
+			err error
+		)
+
+		switch {
+		case a.IsFinished():
+			if now.After(a.Expires.Add(pendingAllocationRetention)) {
+				err = ds.DeletePendingAllocation(&a)
+			}
+		case a.State == metal.PendingAllocationStateAllocating:
+			if now.After(a.Changed.Add(pendingAllocationStaleTimeout)) {
+				requeued := a
+				requeued.State = metal.PendingAllocationStatePending
+				err = ds.UpdatePendingAllocation(&a, &requeued)
+			}
+		case now.After(a.Expires):
+			expired := a
+			expired.State = metal.PendingAllocationStateExpired
+			expired.Message = "no machine became available before the allocation expired"
+			err = ds.UpdatePendingAllocation(&a, &expired)
+		default:
+			keys[allocationQueueKey{partitionID: a.PartitionID, sizeID: a.SizeID}] = true
+		}
+
+		if err != nil && !metal.IsConflict(err) {
+			log.Error("unable to maintain queued allocation", "id", a.ID, "error", err)
+		}
+	}
+
+	return keys, nil
+}
+
+func (r *machineResource) queueMachineAllocation(request *restful.Request, response *restful.Response) {
+	var requestPayload v1.MachineQueuedAllocateRequest
+	err := request.ReadEntity(&requestPayload)
+	if err != nil {
+		r.sendError(request, response, httperrors.BadRequest(err))
+		return
+	}
+
+	if requestPayload.UUID != nil {
+		r.sendError(request, response, httperrors.BadRequest(errors.New("allocations of a specific machine cannot be queued")))
+		return
+	}
+
+	ttl := pendingAllocationDefaultTTL
+	if requestPayload.TTL != nil {
+		ttl = *requestPayload.TTL
+	}
+	if ttl <= 0 || ttl > pendingAllocationMaxTTL {
+		r.sendError(request, response, httperrors.BadRequest(fmt.Errorf("ttl must be positive and must not exceed %s", pendingAllocationMaxTTL)))
+		return
+	}
+
+	user, err := r.userGetter.User(request.Request)
+	if err != nil {
+		r.sendError(request, response, defaultError(err))
+		return
+	}
+
+	spec, err := createMachineAllocationSpec(r.ds, requestPayload.MachineAllocateRequest, nil, user)
+	if err != nil {
+		r.sendError(request, response, httperrors.BadRequest(err))
+		return
+	}
+
+	ctx := request.Request.Context()
+
+	err = validateQueuedAllocation(ctx, r.ds, spec, r.mdc)
+	if err != nil {
+		r.sendQuotaOrDefaultError(request, response, err)
+		return
+	}
+
+	raw, err := json.Marshal(requestPayload.MachineAllocateRequest)
+	if err != nil {
+		r.sendError(request, response, defaultError(err))
+		return
+	}
+
+	a := &metal.PendingAllocation{
+		Base:        metal.Base{ID: uuid.NewString()},
+		ProjectID:   spec.ProjectID,
+		PartitionID: spec.PartitionID,
+		SizeID:      spec.Size.ID,
+		Priority:    pointer.SafeDeref(requestPayload.Priority),
+		Creator:     user.EMail,
+		Expires:     time.Now().Add(ttl),
+		State:       metal.PendingAllocationStatePending,
+		Request:     raw,
+	}
+
+	err = r.ds.CreatePendingAllocation(a)
+	if err != nil {
+		r.sendError(request, response, defaultError(err))
+		return
+	}
+
+	// a machine might be available already, in this case the allocation gets fulfilled right away
+	err = fulfillPendingAllocations(ctx, r.logger(request), r.ds, r.ipamer, r.mdc, r.actor, r.Publisher, a.PartitionID, a.SizeID)
+	if err != nil {
+		r.logger(request).Error("unable to fulfill queued allocations", "partition", a.PartitionID, "size", a.SizeID, "error", err)
+	}
+
+	resp, err := makePendingAllocationResponse(r.ds, a.ID)
+	if err != nil {
+		r.sendError(request, response, defaultError(err))
+		return
+	}
+
+	r.send(request, response, http.StatusAccepted, resp)
+}
+
+func (r *machineResource) listPendingMachineAllocations(request *restful.Request, response *restful.Response) {
+	as, err := r.ds.ListPendingAllocations()
+	if err != nil {
+		r.sendError(request, response, defaultError(err))
+		return
+	}
+
+	positions := pendingAllocationPositions(as)
+
+	result := []*v1.PendingMachineAllocationResponse{}
+	for i := range as {
+		result = append(result, v1.NewPendingMachineAllocationResponse(&as[i], positions[as[i].ID]))
+	}
+
+	r.send(request, response, http.StatusOK, result)
+}
+
+func (r *machineResource) findPendingMachineAllocation(request *restful.Request, response *restful.Response) {
+	resp, err := makePendingAllocationResponse(r.ds, request.PathParameter("id"))
+	if err != nil {
+		r.sendError(request, response, defaultError(err))
+		return
+	}
+
+	r.send(request, response, http.StatusOK, resp)
+}
+
+func (r *machineResource) cancelPendingMachineAllocation(request *restful.Request, response *restful.Response) {
+	a, err := r.ds.FindPendingAllocation(request.PathParameter("id"))
+	if err != nil {
+		r.sendError(request, response, defaultError(err))
+		return
+	}
+
+	if a.State == metal.PendingAllocationStateAllocating {
+		r.sendError(request, response, defaultError(metal.Conflict("a machine is currently being allocated for queued allocation %s", a.ID)))
+		return
+	}
+
+	err = r.ds.DeletePendingAllocation(a)
+	if err != nil {
+		r.sendError(request, response, defaultError(err))
+		return
+	}
+
+	r.send(request, response, http.StatusOK, v1.NewPendingMachineAllocationResponse(a, nil))
+}
+
+// validateQueuedAllocation rejects allocations which would never succeed, such that they are not queued in the first place.
+func validateQueuedAllocation(ctx context.Context, ds datastore.Store, spec *machineAllocationSpec, mdc mdm.Client) error {
+	err := validateAllocationSpec(spec)
+	if err != nil {
+		return err
+	}
+
+	err = isSizeAndImageCompatible(ds, *spec.Size, *spec.Image)
+	if err != nil {
+		return err
+	}
+
+	p, err := mdc.Project().Get(ctx, &mdmv1.ProjectGetRequest{Id: spec.ProjectID})
+	if err != nil {
+		return err
+	}
+
+	err = checkMachineQuotas(ds, p.GetProject(), spec.Role, spec.Size.ID)
+	if err != nil {
+		return err
+	}
+
+	_, err = allocationFilesystemLayout(ds, spec)
+	return err
+}
+
+func makePendingAllocationResponse(ds datastore.Store, id string) (*v1.PendingMachineAllocationResponse, error) {
+	a, err := ds.FindPendingAllocation(id)
+	if err != nil {
+		return nil, err
+	}
+
+	if a.State != metal.PendingAllocationStatePending {
+		return v1.NewPendingMachineAllocationResponse(a, nil), nil
+	}
+
+	var queue metal.PendingAllocations
+	err = ds.SearchPendingAllocations(&datastore.PendingAllocationSearchQuery{
+		PartitionID: &a.PartitionID,
+		SizeID:      &a.SizeID,
+		State:       new(string(metal.PendingAllocationStatePending)),
+	}, &queue)
+	if err != nil {
+		return nil, err
+	}
+
+	return v1.NewPendingMachineAllocationResponse(a, pendingAllocationPositions(queue)[a.ID]), nil
+}
+
+// pendingAllocationPositions returns the position of every pending allocation in the queue of its partition and size, starting at 1.
+func pendingAllocationPositions(as metal.PendingAllocations) map[string]*int {
+	pending := metal.PendingAllocations{}
+	for _, a := range as {
+		if a.State == metal.PendingAllocationStatePending {
+			pending = append(pending, a)
+		}
+	}
+	pending.SortByPriority()
+
+	var (
+		positions = map[string]*int{}
+		counts    = map[allocationQueueKey]int{}
+	)
+	for _, a := range pending {
+		key := allocationQueueKey{partitionID: a.PartitionID, sizeID: a.SizeID}
+		counts[key]++
+		positions[a.ID] = new(counts[key])
+	}
+
+	return positions
+}
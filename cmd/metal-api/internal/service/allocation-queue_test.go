@@ -0,0 +1,254 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"testing"
+	"time"
+
+	mdmv1 "github.com/metal-stack/masterdata-api/api/v1"
+	mdmock "github.com/metal-stack/masterdata-api/api/v1/mocks"
+	mdm "github.com/metal-stack/masterdata-api/pkg/client"
+	"github.com/metal-stack/metal-api/cmd/metal-api/internal/datastore"
+	"github.com/metal-stack/metal-api/cmd/metal-api/internal/ipam"
+	"github.com/metal-stack/metal-api/cmd/metal-api/internal/metal"
+	v1 "github.com/metal-stack/metal-api/cmd/metal-api/internal/service/v1"
+	"github.com/metal-stack/metal-lib/bus"
+	testifymock "github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMaintainPendingAllocations(t *testing.T) {
+	var (
+		ds  = datastore.NewMemory(slog.Default())
+		now = time.Now()
+	)
+
+	for _, a := range []metal.PendingAllocation{
+		{Base: metal.Base{ID: "pending"}, PartitionID: "p1", SizeID: "s1", State: metal.PendingAllocationStatePending, Expires: now.Add(time.Minute)},
+		{Base: metal.Base{ID: "expired"}, PartitionID: "p1", SizeID: "s2", State: metal.PendingAllocationStatePending, Expires: now.Add(-time.Minute)},
+		{Base: metal.Base{ID: "fulfilled"}, PartitionID: "p1", SizeID: "s1", State: metal.PendingAllocationStateFulfilled, Expires: now.Add(-time.Minute)},
+		{Base: metal.Base{ID: "retention-over"}, PartitionID: "p1", SizeID: "s1", State: metal.PendingAllocationStateFailed, Expires: now.Add(-pendingAllocationRetention - time.Minute)},
+		{Base: metal.Base{ID: "allocating"}, PartitionID: "p2", SizeID: "s1", State: metal.PendingAllocationStateAllocating, Expires: now.Add(time.Minute)},
+	} {
+		require.NoError(t, ds.CreatePendingAllocation(&a))
+	}
+
+	keys, err := maintainPendingAllocations(slog.Default(), ds, now)
+	require.NoError(t, err)
+	require.Equal(t, map[allocationQueueKey]bool{{partitionID: "p1", sizeID: "s1"}: true}, keys)
+
+	state := func(id string) metal.PendingAllocationState {
+		a, err := ds.FindPendingAllocation(id)
+		require.NoError(t, err)
+		return a.State
+	}
+
+	require.Equal(t, metal.PendingAllocationStatePending, state("pending"))
+	require.Equal(t, metal.PendingAllocationStateExpired, state("expired"))
+	require.Equal(t, metal.PendingAllocationStateFulfilled, state("fulfilled"))
+	require.Equal(t, metal.PendingAllocationStateAllocating, state("allocating"))
+
+	_, err = ds.FindPendingAllocation("retention-over")
+	require.True(t, metal.IsNotFound(err))
+
+	// allocations which are allocating for too long were aborted and get requeued
+	keys, err = maintainPendingAllocations(slog.Default(), ds, now.Add(pendingAllocationStaleTimeout+time.Second))
+	require.NoError(t, err)
+	require.Equal(t, metal.PendingAllocationStatePending, state("allocating"))
+	require.NotContains(t, keys, allocationQueueKey{partitionID: "p2", sizeID: "s1"}, "requeued allocations are processed in the next run")
+}
+
+func TestPendingAllocationPositions(t *testing.T) {
+	now := time.Now()
+
+	positions := pendingAllocationPositions(metal.PendingAllocations{
+		{Base: metal.Base{ID: "a", Created: now.Add(-time.Hour)}, PartitionID: "p1", SizeID: "s1", State: metal.PendingAllocationStatePending},
+		{Base: metal.Base{ID: "b", Created: now}, PartitionID: "p1", SizeID: "s1", State: metal.PendingAllocationStatePending, Priority: 1},
+		{Base: metal.Base{ID: "c", Created: now}, PartitionID: "p1", SizeID: "s2", State: metal.PendingAllocationStatePending},
+		{Base: metal.Base{ID: "d", Created: now}, PartitionID: "p1", SizeID: "s1", State: metal.PendingAllocationStateFulfilled},
+	})
+
+	require.Len(t, positions, 3)
+	require.Equal(t, 2, *positions["a"])
+	require.Equal(t, 1, *positions["b"])
+	require.Equal(t, 1, *positions["c"])
+}
+
+func TestFulfillPendingAllocations_SizeReservationBlocksHead(t *testing.T) {
+	var (
+		log    = slog.Default()
+		ds     = datastore.NewMemory(log)
+		ipamer = ipam.InitTestIpam(t)
+		ctx    = context.Background()
+		now    = time.Now()
+	)
+
+	size := metal.Size{Base: metal.Base{ID: "c1-large"}}
+	require.NoError(t, ds.CreateSize(&size))
+	require.NoError(t, ds.CreateImage(&metal.Image{Base: metal.Base{ID: "ubuntu-24.04"}, OS: "ubuntu", Version: "24.04", Features: map[metal.ImageFeatureType]bool{metal.ImageFeatureMachine: true}}))
+	require.NoError(t, ds.CreatePartition(&metal.Partition{Base: metal.Base{ID: "partition"}}))
+	require.NoError(t, ds.CreateFilesystemLayout(&metal.FilesystemLayout{
+		Base:        metal.Base{ID: "default"},
+		Constraints: metal.FilesystemLayoutConstraints{Sizes: []string{size.ID}, Images: map[string]string{"ubuntu": "*"}},
+	}))
+
+	superPrefix := metal.Prefix{IP: "10.0.0.0", Length: "16"}
+	childPrefix := metal.Prefix{IP: "10.0.1.0", Length: "24"}
+	require.NoError(t, ipamer.CreatePrefix(ctx, childPrefix))
+	require.NoError(t, ds.CreateNetwork(&metal.Network{Base: metal.Base{ID: "super"}, PartitionID: "partition", PrivateSuper: true, Prefixes: metal.Prefixes{superPrefix}}))
+	for _, project := range []string{"p1", "p2"} {
+		require.NoError(t, ds.CreateNetwork(&metal.Network{Base: metal.Base{ID: project}, PartitionID: "partition", ProjectID: project, ParentNetworkID: "super", Prefixes: metal.Prefixes{childPrefix}}))
+	}
+
+	require.NoError(t, ds.CreateMachine(&metal.Machine{Base: metal.Base{ID: "m1"}, PartitionID: "partition", SizeID: size.ID, Waiting: true}))
+	require.NoError(t, ds.CreateProvisioningEventContainer(&metal.ProvisioningEventContainer{Base: metal.Base{ID: "m1"}, Liveliness: metal.MachineLivelinessAlive}))
+
+	// the only machine is reserved for p1, so the higher prioritized allocation of p2 cannot get it
+	require.NoError(t, ds.CreateSizeReservation(&metal.SizeReservation{Base: metal.Base{ID: "reservation"}, SizeID: size.ID, Amount: 1, ProjectID: "p1", PartitionIDs: []string{"partition"}}))
+
+	psc := &mdmock.ProjectServiceClient{}
+	for _, project := range []string{"p1", "p2"} {
+		psc.On("Get", testifymock.Anything, &mdmv1.ProjectGetRequest{Id: project}).Return(&mdmv1.ProjectResponse{
+			Project: &mdmv1.Project{Meta: &mdmv1.Meta{Id: project}},
+		}, nil)
+	}
+	mdc := mdm.NewMock(psc, nil, nil, nil, nil)
+
+	actor, err := newAsyncActor(log, bus.DirectEndpoints(), ds, ipamer)
+	require.NoError(t, err)
+
+	for _, a := range []struct {
+		id       string
+		project  string
+		priority int
+	}{
+		{id: "head", project: "p2", priority: 1},
+		{id: "reserved", project: "p1"},
+	} {
+		raw, err := json.Marshal(v1.MachineAllocateRequest{
+			ImageID:     "ubuntu-24.04",
+			PartitionID: "partition",
+			SizeID:      size.ID,
+			ProjectID:   a.project,
+			Networks:    v1.MachineAllocationNetworks{{NetworkID: a.project}},
+		})
+		require.NoError(t, err)
+
+		require.NoError(t, ds.CreatePendingAllocation(&metal.PendingAllocation{
+			Base:        metal.Base{ID: a.id},
+			ProjectID:   a.project,
+			PartitionID: "partition",
+			SizeID:      size.ID,
+			Priority:    a.priority,
+			Creator:     "creator",
+			Expires:     now.Add(time.Hour),
+			State:       metal.PendingAllocationStatePending,
+			Request:     raw,
+		}))
+	}
+
+	require.NoError(t, fulfillPendingAllocations(ctx, log, ds, ipamer, mdc, actor, &emptyPublisher{}, "partition", size.ID))
+
+	head, err := ds.FindPendingAllocation("head")
+	require.NoError(t, err)
+	require.Equal(t, metal.PendingAllocationStatePending, head.State)
+
+	reserved, err := ds.FindPendingAllocation("reserved")
+	require.NoError(t, err)
+	require.Equal(t, metal.PendingAllocationStateFulfilled, reserved.State, reserved.Message)
+	require.Equal(t, "m1", reserved.MachineID)
+}
+
+func TestFulfillPendingAllocation_Errors(t *testing.T) {
+	var (
+		log    = slog.Default()
+		ds     = datastore.NewMemory(log)
+		ipamer = ipam.InitTestIpam(t)
+		ctx    = context.Background()
+		now    = time.Now()
+	)
+
+	size := metal.Size{Base: metal.Base{ID: "c1-large"}}
+	require.NoError(t, ds.CreateSize(&size))
+	require.NoError(t, ds.CreateImage(&metal.Image{Base: metal.Base{ID: "ubuntu-24.04"}, OS: "ubuntu", Version: "24.04", Features: map[metal.ImageFeatureType]bool{metal.ImageFeatureMachine: true}}))
+	require.NoError(t, ds.CreatePartition(&metal.Partition{Base: metal.Base{ID: "partition"}}))
+	require.NoError(t, ds.CreateFilesystemLayout(&metal.FilesystemLayout{
+		Base:        metal.Base{ID: "default"},
+		Constraints: metal.FilesystemLayoutConstraints{Sizes: []string{size.ID}, Images: map[string]string{"ubuntu": "*"}},
+	}))
+	require.NoError(t, ds.CreateMachine(&metal.Machine{Base: metal.Base{ID: "m1"}, PartitionID: "partition", SizeID: size.ID, Waiting: true}))
+	require.NoError(t, ds.CreateProvisioningEventContainer(&metal.ProvisioningEventContainer{Base: metal.Base{ID: "m1"}, Liveliness: metal.MachineLivelinessAlive}))
+
+	psc := &mdmock.ProjectServiceClient{}
+	psc.On("Get", testifymock.Anything, &mdmv1.ProjectGetRequest{Id: "unavailable"}).Return(nil, errors.New("masterdata-api is unavailable"))
+	psc.On("Get", testifymock.Anything, &mdmv1.ProjectGetRequest{Id: "exhausted"}).Return(&mdmv1.ProjectResponse{
+		Project: &mdmv1.Project{Meta: &mdmv1.Meta{Id: "exhausted"}, Quotas: &mdmv1.QuotaSet{Machine: &mdmv1.Quota{Max: new(int32(0))}}},
+	}, nil)
+	mdc := mdm.NewMock(psc, nil, nil, nil, nil)
+
+	actor, err := newAsyncActor(log, bus.DirectEndpoints(), ds, ipamer)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name      string
+		project   string
+		imageID   string
+		wantState metal.PendingAllocationState
+	}{
+		{
+			name:      "transient errors are retried",
+			project:   "unavailable",
+			imageID:   "ubuntu-24.04",
+			wantState: metal.PendingAllocationStatePending,
+		},
+		{
+			name:      "exceeded quota fails the allocation",
+			project:   "exhausted",
+			imageID:   "ubuntu-24.04",
+			wantState: metal.PendingAllocationStateFailed,
+		},
+		{
+			name:      "deleted image fails the allocation",
+			project:   "unavailable",
+			imageID:   "debian-12.0",
+			wantState: metal.PendingAllocationStateFailed,
+		},
+	}
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			raw, err := json.Marshal(v1.MachineAllocateRequest{
+				ImageID:     tt.imageID,
+				PartitionID: "partition",
+				SizeID:      size.ID,
+				ProjectID:   tt.project,
+				Networks:    v1.MachineAllocationNetworks{{NetworkID: "internet"}},
+			})
+			require.NoError(t, err)
+
+			a := &metal.PendingAllocation{
+				Base:        metal.Base{ID: fmt.Sprintf("a%d", i)},
+				ProjectID:   tt.project,
+				PartitionID: "partition",
+				SizeID:      size.ID,
+				Creator:     "creator",
+				Expires:     now.Add(time.Hour),
+				State:       metal.PendingAllocationStatePending,
+				Request:     raw,
+			}
+			require.NoError(t, ds.CreatePendingAllocation(a))
+
+			available, err := fulfillPendingAllocation(ctx, log, ds, ipamer, mdc, actor, &emptyPublisher{}, a)
+			require.NoError(t, err)
+			require.True(t, available)
+
+			got, err := ds.FindPendingAllocation(a.ID)
+			require.NoError(t, err)
+			require.Equal(t, tt.wantState, got.State, got.Message)
+			require.NotEmpty(t, got.Message)
+		})
+	}
+}
@@ -20,7 +20,7 @@ const (
 )
 
 // acquireASN fetches a unique integer by using the existing integer pool and adding to ASNBase
-func acquireASN(ds *datastore.RethinkStore) (*uint32, error) {
+func acquireASN(ds datastore.Store) (*uint32, error) {
 	i, err := ds.GetASNPool().AcquireRandomUniqueInteger()
 	if err != nil {
 		return nil, err
@@ -33,7 +33,7 @@ func acquireASN(ds *datastore.RethinkStore) (*uint32, error) {
 }
 
 // releaseASN will release the asn from the integerpool
-func releaseASN(ds *datastore.RethinkStore, asn uint32) error {
+func releaseASN(ds datastore.Store, asn uint32) error {
 	if asn < ASNBase || asn > ASNMax {
 		return fmt.Errorf("asn %d might not be smaller than:%d or larger than %d", asn, ASNBase, ASNMax)
 	}
@@ -2,6 +2,7 @@ package service
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"log/slog"
@@ -19,16 +20,16 @@ import (
 type asyncActor struct {
 	log *slog.Logger
 	ipam.IPAMer
-	*datastore.RethinkStore
+	datastore.Store
 	machineNetworkReleaser bus.Func
 	ipReleaser             bus.Func
 }
 
-func newAsyncActor(l *slog.Logger, ep *bus.Endpoints, ds *datastore.RethinkStore, ip ipam.IPAMer) (*asyncActor, error) {
+func newAsyncActor(l *slog.Logger, ep *bus.Endpoints, ds datastore.Store, ip ipam.IPAMer) (*asyncActor, error) {
 	actor := &asyncActor{
-		log:          l,
-		IPAMer:       ip,
-		RethinkStore: ds,
+		log:    l,
+		IPAMer: ip,
+		Store:  ds,
 	}
 	var err error
 	_, actor.machineNetworkReleaser, err = ep.Function("releaseMachineNetworks", actor.releaseMachineNetworks)
@@ -54,7 +55,7 @@ func (a *asyncActor) freeMachine(ctx context.Context, pub bus.Publisher, m *meta
 		}
 	}
 
-	err := deleteVRFSwitches(a.RethinkStore, m, a.log)
+	err := deleteVRFSwitches(a.Store, m, a.log)
 	if err != nil {
 		return err
 	}
@@ -64,8 +65,12 @@ func (a *asyncActor) freeMachine(ctx context.Context, pub bus.Publisher, m *meta
 		return err
 	}
 
-	// call the releaser async
-	err = a.machineNetworkReleaser(m)
+	// call the releaser async, it gets a copy because the machine is modified below
+	// while the releaser may still read it when the function is invoked in-process
+	released, err := deepCopyMachine(m)
+	if err == nil {
+		err = a.machineNetworkReleaser(released)
+	}
 	if err != nil {
 		// log error, but what should we do here? we already called
 		// deleteVRFSwitches and publishDeleteEvent, so should we return
@@ -85,9 +90,44 @@ func (a *asyncActor) freeMachine(ctx context.Context, pub bus.Publisher, m *meta
 	}
 	a.log.Info("freed machine", "machineID", m.ID)
 
+	if old.IsFirewall() {
+		a.deleteFirewallRuleRevisions(&old)
+	}
+
 	return nil
 }
 
+// deepCopyMachine copies the machine like it is done when passing it through the event bus.
+func deepCopyMachine(m *metal.Machine) (*metal.Machine, error) {
+	raw, err := json.Marshal(m)
+	if err != nil {
+		return nil, err
+	}
+	var c metal.Machine
+	if err := json.Unmarshal(raw, &c); err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+// deleteFirewallRuleRevisions removes the firewall rule revisions of a released firewall allocation,
+// failures are only logged because the revisions of different allocations never collide.
+func (a *asyncActor) deleteFirewallRuleRevisions(fw *metal.Machine) {
+	var revs metal.FirewallRuleRevisions
+	err := a.SearchFirewallRuleRevisions(&datastore.FirewallRuleRevisionSearchQuery{AllocationUUID: &fw.Allocation.UUID}, &revs)
+	if err != nil {
+		a.log.Error("unable to search firewall rule revisions of released firewall", "machineID", fw.ID, "error", err)
+		return
+	}
+
+	for i := range revs {
+		err := a.DeleteFirewallRuleRevision(&revs[i])
+		if err != nil {
+			a.log.Error("unable to delete firewall rule revision of released firewall", "machineID", fw.ID, "revision", revs[i].Revision, "error", err)
+		}
+	}
+}
+
 func (a *asyncActor) releaseMachineNetworks(machine *metal.Machine) error {
 	if machine.Allocation == nil {
 		return nil
@@ -121,7 +161,7 @@ func (a *asyncActor) releaseMachineNetworks(machine *metal.Machine) error {
 		}
 	}
 	if asn >= ASNBase {
-		err := releaseASN(a.RethinkStore, asn)
+		err := releaseASN(a.Store, asn)
 		if err != nil {
 			return err
 		}
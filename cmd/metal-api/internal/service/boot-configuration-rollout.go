@@ -0,0 +1,311 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/metal-stack/metal-api/cmd/metal-api/internal/datastore"
+	"github.com/metal-stack/metal-api/cmd/metal-api/internal/metal"
+	v1 "github.com/metal-stack/metal-api/cmd/metal-api/internal/service/v1"
+	"github.com/metal-stack/metal-lib/httperrors"
+	"github.com/metal-stack/metal-lib/pkg/pointer"
+	"github.com/metal-stack/security"
+
+	restful "github.com/emicklei/go-restful/v3"
+)
+
+// BootConfigurationRolloutController evaluates the provisioning events of the canaries of running boot configuration rollouts
+// and rolls back a rollout if too many canaries failed.
+type BootConfigurationRolloutController struct {
+	log *slog.Logger
+	ds  datastore.Store
+}
+
+// NewBootConfigurationRolloutController returns a new boot configuration rollout controller.
+func NewBootConfigurationRolloutController(log *slog.Logger, ds datastore.Store) *BootConfigurationRolloutController {
+	return &BootConfigurationRolloutController{
+		log: log,
+		ds:  ds,
+	}
+}
+
+// Run evaluates the running rollouts in the given interval until the context is done.
+func (c *BootConfigurationRolloutController) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			err := c.Evaluate(time.Now())
+			if err != nil {
+				c.log.Error("unable to evaluate boot configuration rollouts", "error", err)
+			}
+		}
+	}
+}
+
+// Evaluate updates the failures of all running rollouts and rolls back the ones whose failure rate reached the threshold.
+// Once rolled back, the canaries boot with the boot configuration of the partition again.
+func (c *BootConfigurationRolloutController) Evaluate(now time.Time) error {
+	ps, err := c.ds.ListPartitions()
+	if err != nil {
+		return err
+	}
+
+	running := slices.ContainsFunc(ps, func(p metal.Partition) bool {
+		return p.BootConfigurationRollout != nil && p.BootConfigurationRollout.State == metal.BootConfigurationRolloutStateRunning
+	})
+	if !running {
+		return nil
+	}
+
+	ecs, err := c.ds.ListProvisioningEventContainers()
+	if err != nil {
+		return fmt.Errorf("unable to fetch provisioning event containers: %w", err)
+	}
+	ecsByID := ecs.ByID()
+
+	var errs []error
+	for i := range ps {
+		old := &ps[i]
+		if old.BootConfigurationRollout == nil || old.BootConfigurationRollout.State != metal.BootConfigurationRolloutStateRunning {
+			continue
+		}
+
+		rollout := *old.BootConfigurationRollout
+		rollout.Failures = rollout.EvaluateFailures(ecsByID, now)
+
+		if rollout.ThresholdExceeded() {
+			rollout.Finish(metal.BootConfigurationRolloutStateRolledBack, fmt.Sprintf("%d of %d canaries failed, the failure rate of %.0f%% reached the threshold of %d%%",
+				len(rollout.Failures), len(rollout.Canaries), rollout.FailureRate(), rollout.FailureThreshold), now)
+		}
+
+		sameFailures := slices.EqualFunc(rollout.Failures, old.BootConfigurationRollout.Failures, func(a, b metal.BootConfigurationRolloutFailure) bool {
+			return a.MachineID == b.MachineID && a.Reason == b.Reason
+		})
+		if sameFailures && rollout.State == old.BootConfigurationRollout.State {
+			continue
+		}
+
+		updated := *old
+		updated.BootConfigurationRollout = &rollout
+
+		err := c.ds.UpdatePartition(old, &updated)
+		if err != nil {
+			if metal.IsConflict(err) {
+				// the partition was changed in the meantime, it gets evaluated again in the next interval
+				continue
+			}
+			errs = append(errs, err)
+			continue
+		}
+
+		if rollout.State == metal.BootConfigurationRolloutStateRolledBack {
+			c.log.Warn("rolled back boot configuration rollout", "partition", old.ID, "failures", len(rollout.Failures), "canaries", len(rollout.Canaries))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+func (r *partitionResource) findBootConfigurationRollout(request *restful.Request, response *restful.Response) {
+	id := request.PathParameter("id")
+
+	p, err := r.ds.FindPartition(id)
+	if err != nil {
+		r.sendError(request, response, defaultError(err))
+		return
+	}
+
+	if p.BootConfigurationRollout == nil {
+		r.sendError(request, response, defaultError(metal.NotFound("partition %s has no boot configuration rollout", id)))
+		return
+	}
+
+	r.send(request, response, http.StatusOK, v1.NewPartitionBootConfigurationRolloutResponse(p))
+}
+
+func (r *partitionResource) startBootConfigurationRollout(request *restful.Request, response *restful.Response) {
+	var requestPayload v1.PartitionBootConfigurationRolloutRequest
+	err := request.ReadEntity(&requestPayload)
+	if err != nil {
+		r.sendError(request, response, httperrors.BadRequest(err))
+		return
+	}
+
+	id := request.PathParameter("id")
+
+	old, err := r.ds.FindPartition(id)
+	if err != nil {
+		r.sendError(request, response, defaultError(err))
+		return
+	}
+
+	if old.BootConfigurationRollout != nil && old.BootConfigurationRollout.State == metal.BootConfigurationRolloutStateRunning {
+		r.sendError(request, response, defaultError(metal.Conflict("a boot configuration rollout is already running in partition %s", id)))
+		return
+	}
+
+	percentage := pointer.SafeDeref(requestPayload.Percentage)
+	if percentage < 0 || percentage > 100 {
+		r.sendError(request, response, httperrors.BadRequest(errors.New("percentage must be between 0 and 100")))
+		return
+	}
+	if percentage == 0 && len(requestPayload.MachineIDs) == 0 {
+		r.sendError(request, response, httperrors.BadRequest(errors.New("either a percentage or machine ids must be given")))
+		return
+	}
+	if requestPayload.FailureThreshold < 1 || requestPayload.FailureThreshold > 100 {
+		r.sendError(request, response, httperrors.BadRequest(errors.New("failure threshold must be between 1 and 100")))
+		return
+	}
+
+	stuckTimeout := metal.DefaultBootConfigurationRolloutStuckTimeout
+	if requestPayload.StuckTimeout != nil {
+		if *requestPayload.StuckTimeout <= 0 {
+			r.sendError(request, response, httperrors.BadRequest(errors.New("stuck timeout must be positive")))
+			return
+		}
+		stuckTimeout = *requestPayload.StuckTimeout
+	}
+
+	bc := metal.BootConfiguration{
+		ImageURL:    pointer.SafeDeref(requestPayload.PartitionBootConfiguration.ImageURL),
+		KernelURL:   pointer.SafeDeref(requestPayload.PartitionBootConfiguration.KernelURL),
+		CommandLine: pointer.SafeDeref(requestPayload.PartitionBootConfiguration.CommandLine),
+	}
+	if bc == (metal.BootConfiguration{}) {
+		r.sendError(request, response, httperrors.BadRequest(errors.New("boot configuration of the rollout must not be empty")))
+		return
+	}
+	if bc.ImageURL != "" {
+		err = checkImageURL("image", bc.ImageURL)
+		if err != nil {
+			r.sendError(request, response, httperrors.BadRequest(err))
+			return
+		}
+	}
+	if bc.KernelURL != "" {
+		err = checkImageURL("kernel", bc.KernelURL)
+		if err != nil {
+			r.sendError(request, response, httperrors.BadRequest(err))
+			return
+		}
+	}
+
+	var ms metal.Machines
+	err = r.ds.SearchMachines(&datastore.MachineSearchQuery{PartitionID: &old.ID}, &ms)
+	if err != nil {
+		r.sendError(request, response, defaultError(err))
+		return
+	}
+
+	var candidates []string
+	for _, m := range ms {
+		// machines with an override would not boot with the new configuration anyway
+		if _, override := old.ResolveBootConfiguration(&m); override != nil {
+			continue
+		}
+		candidates = append(candidates, m.ID)
+	}
+
+	now := time.Now()
+	canaries := metal.SelectCanaries(candidates, percentage, old.ID+now.Format(time.RFC3339Nano))
+
+	for _, machineID := range requestPayload.MachineIDs {
+		if !slices.ContainsFunc(ms, func(m metal.Machine) bool { return m.ID == machineID }) {
+			r.sendError(request, response, httperrors.BadRequest(fmt.Errorf("machine %s is not part of partition %s", machineID, old.ID)))
+			return
+		}
+		if !slices.Contains(candidates, machineID) {
+			r.sendError(request, response, httperrors.BadRequest(fmt.Errorf("machine %s has a boot configuration override and would not boot with the boot configuration of the rollout", machineID)))
+			return
+		}
+		if !slices.Contains(canaries, machineID) {
+			canaries = append(canaries, machineID)
+		}
+	}
+	slices.Sort(canaries)
+
+	if len(canaries) == 0 {
+		r.sendError(request, response, httperrors.BadRequest(fmt.Errorf("partition %s has no machines to roll out the boot configuration to", old.ID)))
+		return
+	}
+
+	var creator string
+	if user := security.GetUser(request.Request); user != nil {
+		creator = user.EMail
+	}
+
+	p := *old
+	p.BootConfigurationRollout = &metal.BootConfigurationRollout{
+		BootConfiguration: bc,
+		Canaries:          canaries,
+		FailureThreshold:  requestPayload.FailureThreshold,
+		StuckTimeout:      stuckTimeout,
+		State:             metal.BootConfigurationRolloutStateRunning,
+		Creator:           creator,
+		Started:           now,
+	}
+
+	err = r.ds.UpdatePartition(old, &p)
+	if err != nil {
+		r.sendError(request, response, defaultError(err))
+		return
+	}
+
+	r.send(request, response, http.StatusCreated, v1.NewPartitionBootConfigurationRolloutResponse(&p))
+}
+
+func (r *partitionResource) promoteBootConfigurationRollout(request *restful.Request, response *restful.Response) {
+	r.finishBootConfigurationRollout(request, response, metal.BootConfigurationRolloutStatePromoted)
+}
+
+func (r *partitionResource) abortBootConfigurationRollout(request *restful.Request, response *restful.Response) {
+	r.finishBootConfigurationRollout(request, response, metal.BootConfigurationRolloutStateAborted)
+}
+
+func (r *partitionResource) finishBootConfigurationRollout(request *restful.Request, response *restful.Response, state metal.BootConfigurationRolloutState) {
+	id := request.PathParameter("id")
+
+	old, err := r.ds.FindPartition(id)
+	if err != nil {
+		r.sendError(request, response, defaultError(err))
+		return
+	}
+
+	if old.BootConfigurationRollout == nil || old.BootConfigurationRollout.State != metal.BootConfigurationRolloutStateRunning {
+		r.sendError(request, response, defaultError(metal.NotFound("partition %s has no running boot configuration rollout", id)))
+		return
+	}
+
+	var by string
+	if user := security.GetUser(request.Request); user != nil {
+		by = " by " + user.EMail
+	}
+
+	rollout := *old.BootConfigurationRollout
+	rollout.Finish(state, fmt.Sprintf("%s%s", state, by), time.Now())
+
+	p := *old
+	p.BootConfigurationRollout = &rollout
+	if state == metal.BootConfigurationRolloutStatePromoted {
+		p.BootConfiguration = p.BootConfiguration.Merge(rollout.BootConfiguration)
+	}
+
+	err = r.ds.UpdatePartition(old, &p)
+	if err != nil {
+		r.sendError(request, response, defaultError(err))
+		return
+	}
+
+	r.send(request, response, http.StatusOK, v1.NewPartitionBootConfigurationRolloutResponse(&p))
+}
@@ -0,0 +1,126 @@
+package service
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	restful "github.com/emicklei/go-restful/v3"
+	"github.com/metal-stack/metal-api/cmd/metal-api/internal/datastore"
+	"github.com/metal-stack/metal-api/cmd/metal-api/internal/metal"
+	v1 "github.com/metal-stack/metal-api/cmd/metal-api/internal/service/v1"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBootConfigurationRollout(t *testing.T) {
+	var (
+		log = slog.Default()
+		ds  = datastore.NewMemory(log)
+	)
+
+	require.NoError(t, ds.CreatePartition(&metal.Partition{
+		Base:              metal.Base{ID: "p1"},
+		BootConfiguration: metal.BootConfiguration{CommandLine: "console=ttyS0"},
+		BootConfigurationOverrides: metal.BootConfigurationOverrides{
+			{Scope: metal.BootConfigurationOverrideScopeMachine, Selector: "m4", CommandLineAppend: "debug"},
+		},
+	}))
+	for _, id := range []string{"m1", "m2", "m3", "m4"} {
+		require.NoError(t, ds.CreateMachine(&metal.Machine{Base: metal.Base{ID: id}, PartitionID: "p1"}))
+	}
+	require.NoError(t, ds.CreateMachine(&metal.Machine{Base: metal.Base{ID: "other"}, PartitionID: "p2"}))
+
+	container := restful.NewContainer().Add(NewPartition(log, ds, &nopTopicCreator{}))
+
+	call := func(method, path string, payload any, result any) int {
+		js, err := json.Marshal(payload)
+		require.NoError(t, err)
+		req := httptest.NewRequest(method, path, bytes.NewBuffer(js))
+		req.Header.Add("Content-Type", "application/json")
+		container := injectAdmin(log, container, req)
+		w := httptest.NewRecorder()
+		container.ServeHTTP(w, req)
+
+		resp := w.Result()
+		defer resp.Body.Close()
+		if result != nil && resp.StatusCode < 300 {
+			require.NoError(t, json.NewDecoder(resp.Body).Decode(result))
+		}
+		return resp.StatusCode
+	}
+
+	start := v1.PartitionBootConfigurationRolloutRequest{
+		PartitionBootConfiguration: v1.PartitionBootConfiguration{CommandLine: new("console=ttyS1")},
+		Percentage:                 new(100),
+		FailureThreshold:           50,
+	}
+
+	var rollout v1.PartitionBootConfigurationRolloutResponse
+	require.Equal(t, http.StatusCreated, call(http.MethodPost, "/v1/partition/p1/bootconfig-rollout", start, &rollout))
+	require.Equal(t, []string{"m1", "m2", "m3"}, rollout.Canaries, "machines with an override must not be canaries")
+	require.Equal(t, "running", rollout.State)
+	require.Equal(t, metal.DefaultBootConfigurationRolloutStuckTimeout, rollout.StuckTimeout)
+
+	require.Equal(t, http.StatusConflict, call(http.MethodPost, "/v1/partition/p1/bootconfig-rollout", start, nil))
+	require.Equal(t, http.StatusConflict, call(http.MethodPost, "/v1/partition", v1.PartitionUpdateRequest{
+		Common:                     v1.Common{Identifiable: v1.Identifiable{ID: "p1"}},
+		PartitionBootConfiguration: &v1.PartitionBootConfiguration{CommandLine: new("console=ttyS2")},
+	}, nil))
+
+	c := NewBootConfigurationRolloutController(log, ds)
+	now := rollout.Started.Add(time.Minute)
+
+	require.NoError(t, ds.CreateProvisioningEventContainer(&metal.ProvisioningEventContainer{
+		Base:          metal.Base{ID: "m1"},
+		Events:        metal.ProvisioningEvents{{Time: now, Event: metal.ProvisioningEventPXEBooting}},
+		LastEventTime: &now,
+		CrashLoop:     true,
+	}))
+	require.NoError(t, c.Evaluate(now))
+
+	require.Equal(t, http.StatusOK, call(http.MethodGet, "/v1/partition/p1/bootconfig-rollout", nil, &rollout))
+	require.Equal(t, "running", rollout.State, "one of three canaries is below the threshold")
+	require.Len(t, rollout.Failures, 1)
+
+	require.NoError(t, ds.CreateProvisioningEventContainer(&metal.ProvisioningEventContainer{
+		Base:           metal.Base{ID: "m2"},
+		Events:         metal.ProvisioningEvents{{Time: now, Event: metal.ProvisioningEventWaiting}},
+		LastErrorEvent: &metal.ProvisioningEvent{Time: now, Event: metal.ProvisioningEventCrashed, Message: "kernel panic"},
+	}))
+	require.NoError(t, c.Evaluate(now))
+
+	require.Equal(t, http.StatusOK, call(http.MethodGet, "/v1/partition/p1/bootconfig-rollout", nil, &rollout))
+	require.Equal(t, "rolled-back", rollout.State)
+	require.Len(t, rollout.Failures, 2)
+	require.NotNil(t, rollout.Finished)
+
+	p, err := ds.FindPartition("p1")
+	require.NoError(t, err)
+	bc, override := p.ResolveBootConfiguration(&metal.Machine{Base: metal.Base{ID: "m1"}})
+	require.Nil(t, override, "canaries must boot with the previous boot configuration after a rollback")
+	require.Equal(t, "console=ttyS0", bc.CommandLine)
+
+	require.Equal(t, http.StatusNotFound, call(http.MethodPost, "/v1/partition/p1/bootconfig-rollout/promote", nil, nil))
+
+	start.Percentage = nil
+	start.MachineIDs = []string{"other"}
+	require.Equal(t, http.StatusBadRequest, call(http.MethodPost, "/v1/partition/p1/bootconfig-rollout", start, nil))
+
+	start.MachineIDs = []string{"m3", "m4"}
+	require.Equal(t, http.StatusBadRequest, call(http.MethodPost, "/v1/partition/p1/bootconfig-rollout", start, nil), "machines with an override must not be canaries")
+
+	start.MachineIDs = []string{"m3"}
+	require.Equal(t, http.StatusCreated, call(http.MethodPost, "/v1/partition/p1/bootconfig-rollout", start, &rollout))
+	require.Equal(t, []string{"m3"}, rollout.Canaries)
+
+	require.Equal(t, http.StatusOK, call(http.MethodPost, "/v1/partition/p1/bootconfig-rollout/promote", nil, &rollout))
+	require.Equal(t, "promoted", rollout.State)
+
+	p, err = ds.FindPartition("p1")
+	require.NoError(t, err)
+	require.Equal(t, "console=ttyS1", p.BootConfiguration.CommandLine)
+}
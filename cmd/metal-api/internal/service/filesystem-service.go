@@ -20,7 +20,7 @@ type filesystemResource struct {
 }
 
 // NewFilesystemLayout returns a webservice for filesystem specific endpoints.
-func NewFilesystemLayout(log *slog.Logger, ds *datastore.RethinkStore) *restful.WebService {
+func NewFilesystemLayout(log *slog.Logger, ds datastore.Store) *restful.WebService {
 	r := filesystemResource{
 		webResource: webResource{
 			log: log,
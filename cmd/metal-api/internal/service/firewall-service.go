@@ -5,6 +5,8 @@ import (
 	"errors"
 	"fmt"
 	"log/slog"
+	"slices"
+	"strconv"
 	"time"
 
 	"net/http"
@@ -42,7 +44,7 @@ type firewallResource struct {
 // NewFirewall returns a webservice for firewall specific endpoints.
 func NewFirewall(
 	log *slog.Logger,
-	ds *datastore.RethinkStore,
+	ds datastore.Store,
 	pub bus.Publisher,
 	ipamer ipam.IPAMer,
 	ep *bus.Endpoints,
@@ -94,12 +96,13 @@ func (r *firewallResource) webService() *restful.WebService {
 	ws.Route(ws.POST("/find").
 		To(viewer(r.findFirewalls)).
 		Operation("findFirewalls").
-		Doc("find firewalls by multiple criteria").
+		Doc("find firewalls by multiple criteria, the result can be paged").
+		Param(fieldsParameter(ws)).
 		Reads(v1.FirewallFindRequest{}).
 		Metadata(restfulspec.KeyOpenAPITags, tags).
 		Metadata(auditinghttp.Exclude, true).
 		Writes([]v1.FirewallResponse{}).
-		Returns(http.StatusOK, "OK", []v1.FirewallResponse{}).
+		ReturnsWithHeaders(http.StatusOK, "OK", []v1.FirewallResponse{}, pageHeaders).
 		DefaultReturns("Error", httperrors.HTTPErrorResponse{}))
 
 	ws.Route(ws.GET("/").
@@ -118,6 +121,62 @@ func (r *firewallResource) webService() *restful.WebService {
 		Metadata(restfulspec.KeyOpenAPITags, tags).
 		Reads(v1.FirewallCreateRequest{}).
 		Returns(http.StatusOK, "OK", v1.FirewallResponse{}).
+		Returns(http.StatusUnprocessableEntity, "Quota Exceeded", v1.QuotaExceededResponse{}).
+		DefaultReturns("Error", httperrors.HTTPErrorResponse{}))
+
+	ws.Route(ws.POST("/{id}/rules").
+		To(editor(r.updateFirewallRules)).
+		Operation("updateFirewallRules").
+		Doc("replaces the firewall rules of a firewall and stores them as a new revision").
+		Param(ws.PathParameter("id", "identifier of the firewall").DataType("string")).
+		Metadata(restfulspec.KeyOpenAPITags, tags).
+		Reads(v1.FirewallRulesUpdateRequest{}).
+		Returns(http.StatusOK, "OK", v1.FirewallRuleRevisionResponse{}).
+		Returns(http.StatusConflict, "Conflict", httperrors.HTTPErrorResponse{}).
+		DefaultReturns("Error", httperrors.HTTPErrorResponse{}))
+
+	ws.Route(ws.GET("/{id}/rules/revisions").
+		To(viewer(r.listFirewallRuleRevisions)).
+		Operation("listFirewallRuleRevisions").
+		Doc("get all firewall rule revisions of the current allocation of a firewall").
+		Param(ws.PathParameter("id", "identifier of the firewall").DataType("string")).
+		Metadata(restfulspec.KeyOpenAPITags, tags).
+		Writes([]v1.FirewallRuleRevisionResponse{}).
+		Returns(http.StatusOK, "OK", []v1.FirewallRuleRevisionResponse{}).
+		DefaultReturns("Error", httperrors.HTTPErrorResponse{}))
+
+	ws.Route(ws.GET("/{id}/rules/revisions/{revision}").
+		To(viewer(r.findFirewallRuleRevision)).
+		Operation("findFirewallRuleRevision").
+		Doc("get a firewall rule revision of the current allocation of a firewall").
+		Param(ws.PathParameter("id", "identifier of the firewall").DataType("string")).
+		Param(ws.PathParameter("revision", "number of the revision").DataType("integer")).
+		Metadata(restfulspec.KeyOpenAPITags, tags).
+		Writes(v1.FirewallRuleRevisionResponse{}).
+		Returns(http.StatusOK, "OK", v1.FirewallRuleRevisionResponse{}).
+		DefaultReturns("Error", httperrors.HTTPErrorResponse{}))
+
+	ws.Route(ws.POST("/{id}/rules/revisions/{revision}/rollback").
+		To(editor(r.rollbackFirewallRules)).
+		Operation("rollbackFirewallRules").
+		Doc("replaces the firewall rules of a firewall with the rules of the given revision and stores them as a new revision").
+		Param(ws.PathParameter("id", "identifier of the firewall").DataType("string")).
+		Param(ws.PathParameter("revision", "number of the revision to roll back to").DataType("integer")).
+		Metadata(restfulspec.KeyOpenAPITags, tags).
+		Returns(http.StatusOK, "OK", v1.FirewallRuleRevisionResponse{}).
+		Returns(http.StatusConflict, "Conflict", httperrors.HTTPErrorResponse{}).
+		DefaultReturns("Error", httperrors.HTTPErrorResponse{}))
+
+	ws.Route(ws.GET("/{id}/rules/diff").
+		To(viewer(r.diffFirewallRules)).
+		Operation("diffFirewallRules").
+		Doc("get the differences between two firewall rule revisions of the current allocation of a firewall").
+		Param(ws.PathParameter("id", "identifier of the firewall").DataType("string")).
+		Param(ws.QueryParameter("from", "the revision to start from, defaults to the revision before the target revision").DataType("integer")).
+		Param(ws.QueryParameter("to", "the target revision, defaults to the latest revision").DataType("integer")).
+		Metadata(restfulspec.KeyOpenAPITags, tags).
+		Writes(v1.FirewallRulesDiffResponse{}).
+		Returns(http.StatusOK, "OK", v1.FirewallRulesDiffResponse{}).
 		DefaultReturns("Error", httperrors.HTTPErrorResponse{}))
 
 	return ws
@@ -156,6 +215,12 @@ func (r *firewallResource) findFirewalls(request *restful.Request, response *res
 
 	requestPayload.AllocationRole = &metal.RoleFirewall
 
+	err = requestPayload.Validate()
+	if err != nil {
+		r.sendError(request, response, httperrors.BadRequest(err))
+		return
+	}
+
 	var fws metal.Machines
 	err = r.ds.SearchMachines(&requestPayload, &fws)
 	if err != nil {
@@ -169,7 +234,7 @@ func (r *firewallResource) findFirewalls(request *restful.Request, response *res
 		return
 	}
 
-	r.send(request, response, http.StatusOK, resp)
+	r.sendPage(request, response, resp, "id", requestPayload.NextCursor(fws))
 }
 
 func (r *firewallResource) listFirewalls(request *restful.Request, response *restful.Response) {
@@ -218,7 +283,7 @@ func (r *firewallResource) allocateFirewall(request *restful.Request, response *
 
 	m, err := allocateMachine(request.Request.Context(), r.logger(request), r.ds, r.ipamer, spec, r.mdc, r.actor, r.Publisher)
 	if err != nil {
-		r.sendError(request, response, defaultError(err))
+		r.sendQuotaOrDefaultError(request, response, err)
 		return
 	}
 
@@ -231,6 +296,188 @@ func (r *firewallResource) allocateFirewall(request *restful.Request, response *
 	r.send(request, response, http.StatusOK, resp)
 }
 
+func (r *firewallResource) updateFirewallRules(request *restful.Request, response *restful.Response) {
+	var requestPayload v1.FirewallRulesUpdateRequest
+	err := request.ReadEntity(&requestPayload)
+	if err != nil {
+		r.sendError(request, response, httperrors.BadRequest(err))
+		return
+	}
+
+	egress, ingress, err := toFirewallRules(&requestPayload.FirewallRules)
+	if err != nil {
+		r.sendError(request, response, httperrors.BadRequest(err))
+		return
+	}
+
+	fw, err := r.findAllocatedFirewall(request.PathParameter("id"))
+	if err != nil {
+		r.sendError(request, response, defaultError(err))
+		return
+	}
+
+	user, err := r.userGetter.User(request.Request)
+	if err != nil {
+		r.sendError(request, response, defaultError(err))
+		return
+	}
+
+	rev, err := updateFirewallRules(r.ds, fw, &metal.FirewallRules{Egress: egress, Ingress: ingress}, user.EMail)
+	if err != nil {
+		r.sendError(request, response, defaultError(err))
+		return
+	}
+
+	r.send(request, response, http.StatusOK, v1.NewFirewallRuleRevisionResponse(rev))
+}
+
+func (r *firewallResource) listFirewallRuleRevisions(request *restful.Request, response *restful.Response) {
+	fw, err := r.findAllocatedFirewall(request.PathParameter("id"))
+	if err != nil {
+		r.sendError(request, response, defaultError(err))
+		return
+	}
+
+	revs, err := firewallRuleRevisions(r.ds, fw)
+	if err != nil {
+		r.sendError(request, response, defaultError(err))
+		return
+	}
+
+	result := []*v1.FirewallRuleRevisionResponse{}
+	for i := range revs {
+		result = append(result, v1.NewFirewallRuleRevisionResponse(&revs[i]))
+	}
+
+	r.send(request, response, http.StatusOK, result)
+}
+
+func (r *firewallResource) findFirewallRuleRevision(request *restful.Request, response *restful.Response) {
+	revision, err := strconv.Atoi(request.PathParameter("revision"))
+	if err != nil {
+		r.sendError(request, response, httperrors.BadRequest(fmt.Errorf("invalid revision: %w", err)))
+		return
+	}
+
+	fw, err := r.findAllocatedFirewall(request.PathParameter("id"))
+	if err != nil {
+		r.sendError(request, response, defaultError(err))
+		return
+	}
+
+	rev, err := findFirewallRuleRevision(r.ds, fw, revision)
+	if err != nil {
+		r.sendError(request, response, defaultError(err))
+		return
+	}
+
+	r.send(request, response, http.StatusOK, v1.NewFirewallRuleRevisionResponse(rev))
+}
+
+func (r *firewallResource) rollbackFirewallRules(request *restful.Request, response *restful.Response) {
+	revision, err := strconv.Atoi(request.PathParameter("revision"))
+	if err != nil {
+		r.sendError(request, response, httperrors.BadRequest(fmt.Errorf("invalid revision: %w", err)))
+		return
+	}
+
+	fw, err := r.findAllocatedFirewall(request.PathParameter("id"))
+	if err != nil {
+		r.sendError(request, response, defaultError(err))
+		return
+	}
+
+	user, err := r.userGetter.User(request.Request)
+	if err != nil {
+		r.sendError(request, response, defaultError(err))
+		return
+	}
+
+	target, err := findFirewallRuleRevision(r.ds, fw, revision)
+	if err != nil {
+		r.sendError(request, response, defaultError(err))
+		return
+	}
+
+	rev, err := updateFirewallRules(r.ds, fw, &target.Rules, user.EMail)
+	if err != nil {
+		r.sendError(request, response, defaultError(err))
+		return
+	}
+
+	r.send(request, response, http.StatusOK, v1.NewFirewallRuleRevisionResponse(rev))
+}
+
+func (r *firewallResource) diffFirewallRules(request *restful.Request, response *restful.Response) {
+	from, err := revisionQueryParameter(request, "from")
+	if err != nil {
+		r.sendError(request, response, httperrors.BadRequest(err))
+		return
+	}
+	to, err := revisionQueryParameter(request, "to")
+	if err != nil {
+		r.sendError(request, response, httperrors.BadRequest(err))
+		return
+	}
+
+	fw, err := r.findAllocatedFirewall(request.PathParameter("id"))
+	if err != nil {
+		r.sendError(request, response, defaultError(err))
+		return
+	}
+
+	revs, err := firewallRuleRevisions(r.ds, fw)
+	if err != nil {
+		r.sendError(request, response, defaultError(err))
+		return
+	}
+
+	if to == nil {
+		to = &revs.Latest().Revision
+	}
+	if from == nil {
+		from = new(max(*to-1, 1))
+	}
+
+	fromRev := revs.Get(*from)
+	if fromRev == nil {
+		r.sendError(request, response, httperrors.NotFound(fmt.Errorf("firewall rule revision %d not found", *from)))
+		return
+	}
+	toRev := revs.Get(*to)
+	if toRev == nil {
+		r.sendError(request, response, httperrors.NotFound(fmt.Errorf("firewall rule revision %d not found", *to)))
+		return
+	}
+
+	r.send(request, response, http.StatusOK, v1.NewFirewallRulesDiffResponse(*from, *to, fromRev.Rules.Diff(&toRev.Rules)))
+}
+
+func revisionQueryParameter(request *restful.Request, name string) (*int, error) {
+	param := request.QueryParameter(name)
+	if param == "" {
+		return nil, nil
+	}
+	revision, err := strconv.Atoi(param)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s revision: %w", name, err)
+	}
+	return &revision, nil
+}
+
+func (r *firewallResource) findAllocatedFirewall(id string) (*metal.Machine, error) {
+	fw, err := r.ds.FindMachineByID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	if !fw.IsFirewall() {
+		return nil, metal.NotFound("machine is not a firewall")
+	}
+
+	return fw, nil
+}
+
 func (r *firewallResource) setVPNConfigInSpec(ctx context.Context, allocationSpec *machineAllocationSpec) error {
 	if r.headscaleClient == nil {
 		return nil
@@ -256,7 +503,175 @@ func (r *firewallResource) setVPNConfigInSpec(ctx context.Context, allocationSpe
 	return nil
 }
 
-func makeFirewallResponse(fw *metal.Machine, ds *datastore.RethinkStore) (*v1.FirewallResponse, error) {
+// firewallRuleRevisions returns the firewall rule revisions of the current allocation of the firewall ordered by their number.
+// The first revision is stored at allocation time, if it is missing it is returned from the rules given at allocation time without storing it.
+func firewallRuleRevisions(ds datastore.Store, fw *metal.Machine) (metal.FirewallRuleRevisions, error) {
+	revs, err := storedFirewallRuleRevisions(ds, fw)
+	if err != nil {
+		return nil, err
+	}
+
+	if revs.Get(1) == nil {
+		revs = append(metal.FirewallRuleRevisions{*metal.InitialFirewallRuleRevision(fw)}, revs...)
+	}
+
+	return revs, nil
+}
+
+// storedFirewallRuleRevisions returns the stored firewall rule revisions of the current allocation of the firewall ordered by their number.
+func storedFirewallRuleRevisions(ds datastore.Store, fw *metal.Machine) (metal.FirewallRuleRevisions, error) {
+	if fw.Allocation.UUID == "" {
+		return nil, fmt.Errorf("firewall %q has no allocation uuid", fw.ID)
+	}
+
+	var revs metal.FirewallRuleRevisions
+	err := ds.SearchFirewallRuleRevisions(&datastore.FirewallRuleRevisionSearchQuery{AllocationUUID: &fw.Allocation.UUID}, &revs)
+	if err != nil {
+		return nil, err
+	}
+
+	slices.SortFunc(revs, func(a, b metal.FirewallRuleRevision) int {
+		return a.Revision - b.Revision
+	})
+
+	return revs, nil
+}
+
+// createInitialFirewallRuleRevision stores the rules given at allocation time as the first revision of the firewall rules.
+func createInitialFirewallRuleRevision(ds datastore.Store, fw *metal.Machine) error {
+	err := ds.CreateFirewallRuleRevision(metal.InitialFirewallRuleRevision(fw))
+	if err != nil && !metal.IsConflict(err) {
+		return err
+	}
+	return nil
+}
+
+func findFirewallRuleRevision(ds datastore.Store, fw *metal.Machine, revision int) (*metal.FirewallRuleRevision, error) {
+	revs, err := firewallRuleRevisions(ds, fw)
+	if err != nil {
+		return nil, err
+	}
+
+	rev := revs.Get(revision)
+	if rev == nil {
+		return nil, metal.NotFound("firewall rule revision %d not found", revision)
+	}
+
+	return rev, nil
+}
+
+// updateFirewallRules replaces the firewall rules of the firewall and stores them as a new revision.
+// Parallel updates of the same firewall fail with a conflict.
+func updateFirewallRules(ds datastore.Store, fw *metal.Machine, rules *metal.FirewallRules, creator string) (*metal.FirewallRuleRevision, error) {
+	revs, err := storedFirewallRuleRevisions(ds, fw)
+	if err != nil {
+		return nil, err
+	}
+
+	if revs.Get(1) == nil {
+		// the first revision is missing for firewalls which were allocated before it was stored at allocation time, it must be kept for rollbacks
+		err = createInitialFirewallRuleRevision(ds, fw)
+		if err != nil {
+			return nil, err
+		}
+		revs = append(revs, *metal.InitialFirewallRuleRevision(fw))
+	}
+
+	rev := &metal.FirewallRuleRevision{
+		MachineID:      fw.ID,
+		AllocationUUID: fw.Allocation.UUID,
+		Revision:       revs.Latest().Revision + 1,
+		Rules:          *rules,
+		Creator:        creator,
+	}
+
+	err = ds.CreateFirewallRuleRevision(rev)
+	if err != nil {
+		return nil, err
+	}
+
+	newFw := *fw
+	allocation := *fw.Allocation
+	allocation.FirewallRules = rules
+	newFw.Allocation = &allocation
+
+	err = ds.UpdateMachine(fw, &newFw)
+	if err != nil {
+		if deleteErr := ds.DeleteFirewallRuleRevision(rev); deleteErr != nil {
+			return nil, errors.Join(err, deleteErr)
+		}
+		return nil, err
+	}
+
+	return rev, nil
+}
+
+// toFirewallRules converts the firewall rules of a request and validates them, the protocol defaults to tcp.
+func toFirewallRules(rules *v1.FirewallRules) ([]metal.EgressRule, []metal.IngressRule, error) {
+	var (
+		egress  []metal.EgressRule
+		ingress []metal.IngressRule
+	)
+
+	if rules == nil {
+		return nil, nil, nil
+	}
+
+	for _, ruleSpec := range rules.Egress {
+
+		if ruleSpec.Protocol == "" {
+			ruleSpec.Protocol = string(metal.ProtocolTCP)
+		}
+
+		protocol, err := metal.ProtocolFromString(ruleSpec.Protocol)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		rule := metal.EgressRule{
+			Protocol: protocol,
+			Ports:    ruleSpec.Ports,
+			To:       ruleSpec.To,
+			Comment:  ruleSpec.Comment,
+		}
+
+		if err := rule.Validate(); err != nil {
+			return nil, nil, err
+		}
+
+		egress = append(egress, rule)
+	}
+
+	for _, ruleSpec := range rules.Ingress {
+
+		if ruleSpec.Protocol == "" {
+			ruleSpec.Protocol = string(metal.ProtocolTCP)
+		}
+
+		protocol, err := metal.ProtocolFromString(ruleSpec.Protocol)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		rule := metal.IngressRule{
+			Protocol: protocol,
+			Ports:    ruleSpec.Ports,
+			To:       ruleSpec.To,
+			From:     ruleSpec.From,
+			Comment:  ruleSpec.Comment,
+		}
+
+		if err := rule.Validate(); err != nil {
+			return nil, nil, err
+		}
+
+		ingress = append(ingress, rule)
+	}
+
+	return egress, ingress, nil
+}
+
+func makeFirewallResponse(fw *metal.Machine, ds datastore.Store) (*v1.FirewallResponse, error) {
 	ms, err := makeMachineResponse(fw, ds)
 	if err != nil {
 		return nil, err
@@ -264,7 +679,7 @@ func makeFirewallResponse(fw *metal.Machine, ds *datastore.RethinkStore) (*v1.Fi
 	return &v1.FirewallResponse{MachineResponse: *ms}, nil
 }
 
-func makeFirewallResponseList(fws metal.Machines, ds *datastore.RethinkStore) ([]*v1.FirewallResponse, error) {
+func makeFirewallResponseList(fws metal.Machines, ds datastore.Store) ([]*v1.FirewallResponse, error) {
 	machineResponseList, err := makeMachineResponseList(fws, ds)
 	if err != nil {
 		return nil, err
@@ -0,0 +1,118 @@
+package service
+
+import (
+	"log/slog"
+	"net/http"
+	"testing"
+
+	"github.com/metal-stack/metal-api/cmd/metal-api/internal/datastore"
+	"github.com/metal-stack/metal-api/cmd/metal-api/internal/metal"
+	v1 "github.com/metal-stack/metal-api/cmd/metal-api/internal/service/v1"
+	"github.com/metal-stack/metal-lib/bus"
+	"github.com/metal-stack/metal-lib/httperrors"
+	"github.com/metal-stack/security"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFirewallRuleRevisions(t *testing.T) {
+	log := slog.Default()
+	ds := datastore.NewMemory(log)
+
+	https := metal.EgressRule{Protocol: metal.ProtocolTCP, Ports: []int{443}, To: []string{"0.0.0.0/0"}, Comment: "https"}
+	require.NoError(t, ds.CreateMachine(&metal.Machine{Base: metal.Base{ID: "fw1"}}))
+	fw, err := ds.FindMachineByID("fw1")
+	require.NoError(t, err)
+	allocated := *fw
+	allocated.Allocation = &metal.MachineAllocation{
+		UUID:          "alloc-1",
+		Creator:       "creator@metal-stack.io",
+		Role:          metal.RoleFirewall,
+		FirewallRules: &metal.FirewallRules{Egress: []metal.EgressRule{https}},
+	}
+	require.NoError(t, ds.UpdateMachine(fw, &allocated))
+
+	ws, err := NewFirewall(log, ds, &emptyPublisher{}, nil, bus.DirectEndpoints(), nil, mockUserGetter{&security.User{EMail: "operator@metal-stack.io"}}, nil)
+	require.NoError(t, err)
+
+	// reading the revisions must not store the first revision
+	code, revs := genericWebRequest[[]v1.FirewallRuleRevisionResponse](t, ws, testViewUser, nil, http.MethodGet, "/v1/firewall/fw1/rules/revisions")
+	require.Equal(t, http.StatusOK, code)
+	require.Len(t, revs, 1)
+	require.Equal(t, 1, revs[0].Revision)
+	code, _ = genericWebRequest[v1.FirewallRuleRevisionResponse](t, ws, testViewUser, nil, http.MethodGet, "/v1/firewall/fw1/rules/revisions/1")
+	require.Equal(t, http.StatusOK, code)
+	var stored metal.FirewallRuleRevisions
+	require.NoError(t, ds.SearchFirewallRuleRevisions(&datastore.FirewallRuleRevisionSearchQuery{AllocationUUID: new("alloc-1")}, &stored))
+	require.Empty(t, stored)
+
+	update := v1.FirewallRulesUpdateRequest{
+		FirewallRules: v1.FirewallRules{
+			Egress: []v1.FirewallEgressRule{
+				{Ports: []int{443}, To: []string{"0.0.0.0/0"}, Comment: "https"},
+				{Protocol: "udp", Ports: []int{53}, To: []string{"1.1.1.1/32"}, Comment: "dns"},
+			},
+			Ingress: []v1.FirewallIngressRule{
+				{Ports: []int{22}, From: []string{"192.168.0.0/16"}, Comment: "ssh"},
+			},
+		},
+	}
+
+	code, rev := genericWebRequest[v1.FirewallRuleRevisionResponse](t, ws, testAdminUser, update, http.MethodPost, "/v1/firewall/fw1/rules")
+	require.Equal(t, http.StatusOK, code)
+	require.Equal(t, 2, rev.Revision)
+	require.Equal(t, "operator@metal-stack.io", rev.Creator)
+	require.Len(t, rev.FirewallRules.Egress, 2)
+
+	fw, err = ds.FindMachineByID("fw1")
+	require.NoError(t, err)
+	require.Len(t, fw.Allocation.FirewallRules.Egress, 2)
+	require.Len(t, fw.Allocation.FirewallRules.Ingress, 1)
+
+	code, revs = genericWebRequest[[]v1.FirewallRuleRevisionResponse](t, ws, testViewUser, nil, http.MethodGet, "/v1/firewall/fw1/rules/revisions")
+	require.Equal(t, http.StatusOK, code)
+	require.Len(t, revs, 2)
+	require.Equal(t, 1, revs[0].Revision)
+	require.Equal(t, "creator@metal-stack.io", revs[0].Creator)
+	require.Equal(t, []v1.FirewallEgressRule{{Protocol: "tcp", Ports: []int{443}, To: []string{"0.0.0.0/0"}, Comment: "https"}}, revs[0].FirewallRules.Egress)
+
+	require.NoError(t, ds.SearchFirewallRuleRevisions(&datastore.FirewallRuleRevisionSearchQuery{AllocationUUID: new("alloc-1")}, &stored))
+	require.Len(t, stored, 2, "the first revision must be stored with the first update")
+
+	code, diff := genericWebRequest[v1.FirewallRulesDiffResponse](t, ws, testViewUser, nil, http.MethodGet, "/v1/firewall/fw1/rules/diff")
+	require.Equal(t, http.StatusOK, code)
+	require.Equal(t, 1, diff.From)
+	require.Equal(t, 2, diff.To)
+	require.Equal(t, []v1.FirewallEgressRule{{Protocol: "udp", Ports: []int{53}, To: []string{"1.1.1.1/32"}, Comment: "dns"}}, diff.AddedEgress)
+	require.Equal(t, []v1.FirewallIngressRule{{Protocol: "tcp", Ports: []int{22}, From: []string{"192.168.0.0/16"}, Comment: "ssh"}}, diff.AddedIngress)
+	require.Empty(t, diff.RemovedEgress)
+	require.Empty(t, diff.RemovedIngress)
+
+	code, rev = genericWebRequest[v1.FirewallRuleRevisionResponse](t, ws, testAdminUser, nil, http.MethodPost, "/v1/firewall/fw1/rules/revisions/1/rollback")
+	require.Equal(t, http.StatusOK, code)
+	require.Equal(t, 3, rev.Revision)
+
+	fw, err = ds.FindMachineByID("fw1")
+	require.NoError(t, err)
+	require.Equal(t, []metal.EgressRule{https}, fw.Allocation.FirewallRules.Egress)
+	require.Empty(t, fw.Allocation.FirewallRules.Ingress)
+
+	code, diff = genericWebRequest[v1.FirewallRulesDiffResponse](t, ws, testViewUser, nil, http.MethodGet, "/v1/firewall/fw1/rules/diff?from=1&to=3")
+	require.Equal(t, http.StatusOK, code)
+	require.Empty(t, diff.AddedEgress)
+	require.Empty(t, diff.RemovedEgress)
+
+	code, httpErr := genericWebRequest[httperrors.HTTPErrorResponse](t, ws, testViewUser, nil, http.MethodGet, "/v1/firewall/fw1/rules/revisions/4")
+	require.Equal(t, http.StatusNotFound, code, httpErr.Message)
+
+	invalid := v1.FirewallRulesUpdateRequest{
+		FirewallRules: v1.FirewallRules{
+			Egress: []v1.FirewallEgressRule{{Protocol: "icmp", To: []string{"0.0.0.0/0"}}},
+		},
+	}
+	code, httpErr = genericWebRequest[httperrors.HTTPErrorResponse](t, ws, testAdminUser, invalid, http.MethodPost, "/v1/firewall/fw1/rules")
+	require.Equal(t, http.StatusBadRequest, code, httpErr.Message)
+
+	require.NoError(t, ds.CreateMachine(&metal.Machine{Base: metal.Base{ID: "m1"}}))
+	code, httpErr = genericWebRequest[httperrors.HTTPErrorResponse](t, ws, testViewUser, nil, http.MethodGet, "/v1/firewall/m1/rules/revisions")
+	require.Equal(t, http.StatusNotFound, code, httpErr.Message)
+}
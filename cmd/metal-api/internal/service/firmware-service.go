@@ -31,7 +31,7 @@ type firmwareResource struct {
 }
 
 // NewFirmware returns a webservice for firmware specific endpoints.
-func NewFirmware(log *slog.Logger, ds *datastore.RethinkStore, s3Client *s3server.Client) (*restful.WebService, error) {
+func NewFirmware(log *slog.Logger, ds datastore.Store, s3Client *s3server.Client) (*restful.WebService, error) {
 	r := firmwareResource{
 		webResource: webResource{
 			log: log,
@@ -240,7 +240,7 @@ func (r *firmwareResource) listFirmwares(request *restful.Request, response *res
 	r.send(request, response, http.StatusOK, mapToFirmwareResponse(rr))
 }
 
-func getFirmware(ds *datastore.RethinkStore, machineID string) (*metal.Machine, *v1.Firmware, error) {
+func getFirmware(ds datastore.Store, machineID string) (*metal.Machine, *v1.Firmware, error) {
 	m, err := ds.FindMachineByID(machineID)
 	if err != nil {
 		return nil, nil, err
@@ -24,7 +24,7 @@ type imageResource struct {
 }
 
 // NewImage returns a webservice for image specific endpoints.
-func NewImage(log *slog.Logger, ds *datastore.RethinkStore) *restful.WebService {
+func NewImage(log *slog.Logger, ds datastore.Store) *restful.WebService {
 	ir := imageResource{
 		webResource: webResource{
 			log: log,
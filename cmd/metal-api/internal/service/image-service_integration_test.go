@@ -3,7 +3,6 @@
 package service
 
 import (
-	"context"
 	"encoding/json"
 	"io"
 	"log/slog"
@@ -17,25 +16,20 @@ import (
 	"github.com/metal-stack/metal-api/cmd/metal-api/internal/datastore"
 	"github.com/metal-stack/metal-api/cmd/metal-api/internal/metal"
 	v1 "github.com/metal-stack/metal-api/cmd/metal-api/internal/service/v1"
-	"github.com/metal-stack/metal-api/test"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
 
 func TestGetImagesIntegration(t *testing.T) {
-	rethinkContainer, c, err := test.StartRethink(t)
-	require.NoError(t, err)
-	defer func() {
-		_ = rethinkContainer.Terminate(context.Background())
-	}()
-
 	log := slog.New(slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelError}))
 
-	ds := datastore.New(log, c.IP+":"+c.Port, c.DB, c.User, c.Password)
-	ds.VRFPoolRangeMax = 1000
-	ds.ASNPoolRangeMax = 1000
+	forEachStore(t, log, func(t *testing.T, ds datastore.Store) {
+		testGetImages(t, log, ds)
+	})
+}
 
-	err = ds.Connect()
+func testGetImages(t *testing.T, log *slog.Logger, ds datastore.Store) {
+	err := ds.Connect()
 	require.NoError(t, err)
 	err = ds.Initialize()
 	require.NoError(t, err)
@@ -27,10 +27,12 @@ import (
 	restful "github.com/emicklei/go-restful/v3"
 
 	"github.com/metal-stack/metal-api/cmd/metal-api/internal/datastore"
+	"github.com/metal-stack/metal-api/cmd/metal-api/internal/eventbus"
 	"github.com/metal-stack/metal-api/cmd/metal-api/internal/ipam"
 	"github.com/metal-stack/metal-api/cmd/metal-api/internal/metal"
 	v1 "github.com/metal-stack/metal-api/cmd/metal-api/internal/service/v1"
 	grpcv1 "github.com/metal-stack/metal-api/pkg/api/v1"
+	"github.com/metal-stack/metal-api/test"
 
 	testifymock "github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/require"
@@ -45,14 +47,42 @@ type testEnv struct {
 	partitionService           *restful.WebService
 	machineService             *restful.WebService
 	ipService                  *restful.WebService
-	ds                         *datastore.RethinkStore
+	ds                         datastore.Store
 	privateSuperNetwork        *v1.NetworkResponse
 	privateNetwork             *v1.NetworkResponse
 	ctx                        context.Context
 	listener                   net.Listener
 }
 
-func createTestEnvironment(t *testing.T, log *slog.Logger, ds *datastore.RethinkStore, publisher bus.Publisher, consumer *bus.Consumer) testEnv {
+// testPoolRangeMax is the upper bound of the vrf and asn pools of the stores the integration tests are run against.
+const testPoolRangeMax = uint(1000)
+
+// forEachStore runs the given test once for every store implementation, the store is neither connected nor initialized.
+func forEachStore(t *testing.T, log *slog.Logger, fn func(t *testing.T, ds datastore.Store)) {
+	t.Run("rethinkdb", func(t *testing.T) {
+		rethinkContainer, c, err := test.StartRethink(t)
+		require.NoError(t, err)
+		defer func() {
+			_ = rethinkContainer.Terminate(context.Background())
+		}()
+
+		ds := datastore.New(log, c.IP+":"+c.Port, c.DB, c.User, c.Password)
+		ds.VRFPoolRangeMax = testPoolRangeMax
+		ds.ASNPoolRangeMax = testPoolRangeMax
+
+		fn(t, ds)
+	})
+
+	t.Run("memory", func(t *testing.T) {
+		ds := datastore.NewMemory(log)
+		ds.VRFPoolRangeMax = testPoolRangeMax
+		ds.ASNPoolRangeMax = testPoolRangeMax
+
+		fn(t, ds)
+	})
+}
+
+func createTestEnvironment(t *testing.T, log *slog.Logger, ds datastore.Store, publisher bus.Publisher, consumer *bus.Consumer) testEnv {
 	ipamer := ipam.InitTestIpam(t)
 
 	err := ds.Connect()
@@ -79,7 +109,7 @@ func createTestEnvironment(t *testing.T, log *slog.Logger, ds *datastore.Rethink
 			Context:          context.Background(),
 			Store:            ds,
 			Publisher:        publisher,
-			Consumer:         consumer,
+			Consumer:         &eventbus.NSQClient{Consumer: consumer},
 			Logger:           log,
 			Listener:         listener,
 			TlsEnabled:       false,
@@ -93,7 +123,7 @@ func createTestEnvironment(t *testing.T, log *slog.Logger, ds *datastore.Rethink
 
 	hma := security.NewHMACAuth(testUserDirectory.admin.Name, []byte{1, 2, 3}, security.WithUser(testUserDirectory.admin))
 	usergetter := security.NewCreds(security.WithHMAC(hma))
-	machineService, err := NewMachine(log, ds, publisher, bus.NewEndpoints(consumer, publisher), ipamer, mdc, nil, usergetter, 0, nil, metal.DisabledIPMISuperUser())
+	machineService, err := NewMachine(log, ds, publisher, bus.NewEndpoints(consumer, publisher), ipamer, mdc, nil, usergetter, 0, nil, metal.DisabledIPMISuperUser(), 100)
 	require.NoError(t, err)
 	imageService := NewImage(log, ds)
 	switchService := NewSwitch(log, ds)
@@ -37,7 +37,7 @@ type ipResource struct {
 }
 
 // NewIP returns a webservice for ip specific endpoints.
-func NewIP(log *slog.Logger, ds *datastore.RethinkStore, ep *bus.Endpoints, ipamer ipam.IPAMer, mdc mdm.Client) (*restful.WebService, error) {
+func NewIP(log *slog.Logger, ds datastore.Store, ep *bus.Endpoints, ipamer ipam.IPAMer, mdc mdm.Client) (*restful.WebService, error) {
 	ir := ipResource{
 		webResource: webResource{
 			log: log,
@@ -85,12 +85,13 @@ func (r *ipResource) webService() *restful.WebService {
 	ws.Route(ws.POST("/find").
 		To(viewer(r.findIPs)).
 		Operation("findIPs").
-		Doc("get all ips that match given properties").
+		Doc("get all ips that match given properties, the result can be paged").
+		Param(fieldsParameter(ws)).
 		Metadata(restfulspec.KeyOpenAPITags, tags).
 		Metadata(auditinghttp.Exclude, true).
 		Reads(v1.IPFindRequest{}).
 		Writes([]v1.IPResponse{}).
-		Returns(http.StatusOK, "OK", []v1.IPResponse{}).
+		ReturnsWithHeaders(http.StatusOK, "OK", []v1.IPResponse{}, pageHeaders).
 		DefaultReturns("Error", httperrors.HTTPErrorResponse{}))
 
 	ws.Route(ws.DELETE("/free/{id}").
@@ -123,6 +124,7 @@ func (r *ipResource) webService() *restful.WebService {
 		Writes(v1.IPResponse{}).
 		Returns(http.StatusCreated, "Created", v1.IPResponse{}).
 		Returns(http.StatusConflict, "Conflict", httperrors.HTTPErrorResponse{}).
+		Returns(http.StatusUnprocessableEntity, "Quota Exceeded", v1.QuotaExceededResponse{}).
 		DefaultReturns("Error", httperrors.HTTPErrorResponse{}))
 
 	ws.Route(ws.POST("/allocate/{ip}").
@@ -135,6 +137,7 @@ func (r *ipResource) webService() *restful.WebService {
 		Writes(v1.IPResponse{}).
 		Returns(http.StatusCreated, "Created", v1.IPResponse{}).
 		Returns(http.StatusConflict, "Conflict", httperrors.HTTPErrorResponse{}).
+		Returns(http.StatusUnprocessableEntity, "Quota Exceeded", v1.QuotaExceededResponse{}).
 		DefaultReturns("Error", httperrors.HTTPErrorResponse{}))
 
 	return ws
@@ -175,6 +178,12 @@ func (r *ipResource) findIPs(request *restful.Request, response *restful.Respons
 		return
 	}
 
+	err = requestPayload.Validate()
+	if err != nil {
+		r.sendError(request, response, httperrors.BadRequest(err))
+		return
+	}
+
 	var ips metal.IPs
 	err = r.ds.SearchIPs(&requestPayload, &ips)
 	if err != nil {
@@ -187,7 +196,7 @@ func (r *ipResource) findIPs(request *restful.Request, response *restful.Respons
 		result = append(result, v1.NewIPResponse(&ips[i]))
 	}
 
-	r.send(request, response, http.StatusOK, result)
+	r.sendPage(request, response, result, "ipaddress", requestPayload.NextCursor(ips))
 }
 
 func (r *ipResource) freeIP(request *restful.Request, response *restful.Response) {
@@ -327,6 +336,12 @@ func (r *ipResource) allocateIP(request *restful.Request, response *restful.Resp
 		return
 	}
 
+	err = checkIPQuota(r.ds, p.Project)
+	if err != nil {
+		r.sendQuotaOrDefaultError(request, response, err)
+		return
+	}
+
 	tags := requestPayload.Tags
 	if requestPayload.MachineID != nil {
 		tags = append(tags, metal.IpTag(tag.MachineID, *requestPayload.MachineID))
@@ -381,9 +396,29 @@ func (r *ipResource) allocateIP(request *restful.Request, response *restful.Resp
 		return
 	}
 
+	err = recheckIPQuota(r.ds, p.Project, ip.IPAddress)
+	if err != nil {
+		r.rollbackIPAllocation(request, ip)
+		r.sendQuotaOrDefaultError(request, response, err)
+		return
+	}
+
 	r.send(request, response, http.StatusCreated, v1.NewIPResponse(ip))
 }
 
+// rollbackIPAllocation releases an ip which was allocated by allocateIP.
+func (r *ipResource) rollbackIPAllocation(request *restful.Request, ip *metal.IP) {
+	err := r.ds.DeleteIP(ip)
+	if err != nil {
+		r.logger(request).Error("cannot delete ip in datastore", "ip", ip.IPAddress, "error", err)
+		return
+	}
+	err = r.ipamer.ReleaseIP(request.Request.Context(), *ip)
+	if err != nil {
+		r.logger(request).Error("cannot release ip in ipam", "ip", ip.IPAddress, "error", err)
+	}
+}
+
 func (r *ipResource) updateIP(request *restful.Request, response *restful.Response) {
 	var requestPayload v1.IPUpdateRequest
 	err := request.ReadEntity(&requestPayload)
@@ -0,0 +1,311 @@
+package service
+
+import (
+	"log/slog"
+	"net/http"
+
+	"github.com/metal-stack/metal-api/cmd/metal-api/internal/datastore"
+	"github.com/metal-stack/metal-api/cmd/metal-api/internal/metal"
+	v1 "github.com/metal-stack/metal-api/cmd/metal-api/internal/service/v1"
+	"github.com/metal-stack/metal-lib/pkg/pointer"
+
+	restfulspec "github.com/emicklei/go-restful-openapi/v2"
+	restful "github.com/emicklei/go-restful/v3"
+	"github.com/metal-stack/metal-lib/httperrors"
+)
+
+type locationResource struct {
+	webResource
+}
+
+// NewLocation returns a webservice for location specific endpoints.
+func NewLocation(log *slog.Logger, ds datastore.Store) *restful.WebService {
+	r := locationResource{
+		webResource: webResource{
+			log: log,
+			ds:  ds,
+		},
+	}
+	return r.webService()
+}
+
+func (r *locationResource) webService() *restful.WebService {
+	ws := new(restful.WebService)
+	ws.
+		Path(BasePath + "v1/location").
+		Consumes(restful.MIME_JSON).
+		Produces(restful.MIME_JSON)
+
+	tags := []string{"location"}
+
+	ws.Route(ws.GET("/{id}").
+		To(viewer(r.findLocation)).
+		Operation("findLocation").
+		Doc("get location by id").
+		Param(ws.PathParameter("id", "identifier of the location").DataType("string")).
+		Metadata(restfulspec.KeyOpenAPITags, tags).
+		Writes(v1.LocationResponse{}).
+		Returns(http.StatusOK, "OK", v1.LocationResponse{}).
+		DefaultReturns("Error", httperrors.HTTPErrorResponse{}))
+
+	ws.Route(ws.GET("/").
+		To(viewer(r.listLocations)).
+		Operation("listLocations").
+		Doc("get all locations").
+		Metadata(restfulspec.KeyOpenAPITags, tags).
+		Writes([]v1.LocationResponse{}).
+		Returns(http.StatusOK, "OK", []v1.LocationResponse{}).
+		DefaultReturns("Error", httperrors.HTTPErrorResponse{}))
+
+	ws.Route(ws.POST("/find").
+		To(viewer(r.findLocations)).
+		Operation("findLocations").
+		Doc("get all locations that match given properties").
+		Metadata(restfulspec.KeyOpenAPITags, tags).
+		Reads(v1.LocationFindRequest{}).
+		Writes([]v1.LocationResponse{}).
+		Returns(http.StatusOK, "OK", []v1.LocationResponse{}).
+		DefaultReturns("Error", httperrors.HTTPErrorResponse{}))
+
+	ws.Route(ws.DELETE("/{id}").
+		To(admin(r.deleteLocation)).
+		Operation("deleteLocation").
+		Doc("deletes a location and returns the deleted entity, locations which are still referenced cannot be deleted").
+		Param(ws.PathParameter("id", "identifier of the location").DataType("string")).
+		Metadata(restfulspec.KeyOpenAPITags, tags).
+		Writes(v1.LocationResponse{}).
+		Returns(http.StatusOK, "OK", v1.LocationResponse{}).
+		Returns(http.StatusConflict, "Conflict", httperrors.HTTPErrorResponse{}).
+		DefaultReturns("Error", httperrors.HTTPErrorResponse{}))
+
+	ws.Route(ws.PUT("/").
+		To(admin(r.createLocation)).
+		Operation("createLocation").
+		Doc("create a location. racks are referenced by the rack id of machines and switches, so the id of a rack location must match it. if the given ID already exists a conflict is returned").
+		Metadata(restfulspec.KeyOpenAPITags, tags).
+		Reads(v1.LocationCreateRequest{}).
+		Returns(http.StatusCreated, "Created", v1.LocationResponse{}).
+		Returns(http.StatusConflict, "Conflict", httperrors.HTTPErrorResponse{}).
+		DefaultReturns("Error", httperrors.HTTPErrorResponse{}))
+
+	ws.Route(ws.POST("/").
+		To(admin(r.updateLocation)).
+		Operation("updateLocation").
+		Doc("updates a location. if the location was changed since this one was read, a conflict is returned").
+		Metadata(restfulspec.KeyOpenAPITags, tags).
+		Reads(v1.LocationUpdateRequest{}).
+		Returns(http.StatusOK, "OK", v1.LocationResponse{}).
+		Returns(http.StatusConflict, "Conflict", httperrors.HTTPErrorResponse{}).
+		DefaultReturns("Error", httperrors.HTTPErrorResponse{}))
+
+	return ws
+}
+
+func (r *locationResource) findLocation(request *restful.Request, response *restful.Response) {
+	id := request.PathParameter("id")
+
+	l, err := r.ds.FindLocation(id)
+	if err != nil {
+		r.sendError(request, response, defaultError(err))
+		return
+	}
+
+	r.send(request, response, http.StatusOK, v1.NewLocationResponse(l))
+}
+
+func (r *locationResource) listLocations(request *restful.Request, response *restful.Response) {
+	ls, err := r.ds.ListLocations()
+	if err != nil {
+		r.sendError(request, response, defaultError(err))
+		return
+	}
+
+	result := []*v1.LocationResponse{}
+	for i := range ls {
+		result = append(result, v1.NewLocationResponse(&ls[i]))
+	}
+
+	r.send(request, response, http.StatusOK, result)
+}
+
+func (r *locationResource) findLocations(request *restful.Request, response *restful.Response) {
+	var requestPayload v1.LocationFindRequest
+	err := request.ReadEntity(&requestPayload)
+	if err != nil {
+		r.sendError(request, response, httperrors.BadRequest(err))
+		return
+	}
+
+	var ls metal.Locations
+	err = r.ds.SearchLocations(&datastore.LocationSearchQuery{
+		ID:          requestPayload.ID,
+		Level:       requestPayload.Level,
+		ParentID:    requestPayload.ParentID,
+		PowerFeedID: requestPayload.PowerFeedID,
+	}, &ls)
+	if err != nil {
+		r.sendError(request, response, defaultError(err))
+		return
+	}
+
+	result := []*v1.LocationResponse{}
+	for i := range ls {
+		result = append(result, v1.NewLocationResponse(&ls[i]))
+	}
+
+	r.send(request, response, http.StatusOK, result)
+}
+
+func (r *locationResource) createLocation(request *restful.Request, response *restful.Response) {
+	var requestPayload v1.LocationCreateRequest
+	err := request.ReadEntity(&requestPayload)
+	if err != nil {
+		r.sendError(request, response, httperrors.BadRequest(err))
+		return
+	}
+
+	l := &metal.Location{
+		Base: metal.Base{
+			ID:          requestPayload.ID,
+			Name:        pointer.SafeDeref(requestPayload.Name),
+			Description: pointer.SafeDeref(requestPayload.Description),
+		},
+		Level:       metal.LocationLevel(requestPayload.Level),
+		ParentID:    requestPayload.ParentID,
+		PowerFeedID: requestPayload.PowerFeedID,
+	}
+
+	if httperr := r.validateLocation(l); httperr != nil {
+		r.sendError(request, response, httperr)
+		return
+	}
+
+	err = r.ds.CreateLocation(l)
+	if err != nil {
+		r.sendError(request, response, defaultError(err))
+		return
+	}
+
+	r.send(request, response, http.StatusCreated, v1.NewLocationResponse(l))
+}
+
+func (r *locationResource) updateLocation(request *restful.Request, response *restful.Response) {
+	var requestPayload v1.LocationUpdateRequest
+	err := request.ReadEntity(&requestPayload)
+	if err != nil {
+		r.sendError(request, response, httperrors.BadRequest(err))
+		return
+	}
+
+	oldLocation, err := r.ds.FindLocation(requestPayload.ID)
+	if err != nil {
+		r.sendError(request, response, defaultError(err))
+		return
+	}
+
+	newLocation := *oldLocation
+
+	if requestPayload.Name != nil {
+		newLocation.Name = *requestPayload.Name
+	}
+	if requestPayload.Description != nil {
+		newLocation.Description = *requestPayload.Description
+	}
+	if requestPayload.ParentID != nil {
+		newLocation.ParentID = *requestPayload.ParentID
+	}
+	if requestPayload.PowerFeedID != nil {
+		newLocation.PowerFeedID = *requestPayload.PowerFeedID
+	}
+
+	if httperr := r.validateLocation(&newLocation); httperr != nil {
+		r.sendError(request, response, httperr)
+		return
+	}
+
+	err = r.ds.UpdateLocation(oldLocation, &newLocation)
+	if err != nil {
+		r.sendError(request, response, defaultError(err))
+		return
+	}
+
+	r.send(request, response, http.StatusOK, v1.NewLocationResponse(&newLocation))
+}
+
+func (r *locationResource) deleteLocation(request *restful.Request, response *restful.Response) {
+	id := request.PathParameter("id")
+
+	l, err := r.ds.FindLocation(id)
+	if err != nil {
+		r.sendError(request, response, defaultError(err))
+		return
+	}
+
+	var children metal.Locations
+	err = r.ds.SearchLocations(&datastore.LocationSearchQuery{ParentID: &l.ID}, &children)
+	if err != nil {
+		r.sendError(request, response, defaultError(err))
+		return
+	}
+	if len(children) > 0 {
+		r.sendError(request, response, defaultError(metal.Conflict("cannot delete location %s while it still contains %d locations", l.ID, len(children))))
+		return
+	}
+
+	switch l.Level { //nolint:exhaustive
+	case metal.LocationLevelPowerFeed:
+		var racks metal.Locations
+		err = r.ds.SearchLocations(&datastore.LocationSearchQuery{PowerFeedID: &l.ID}, &racks)
+		if err != nil {
+			r.sendError(request, response, defaultError(err))
+			return
+		}
+		if len(racks) > 0 {
+			r.sendError(request, response, defaultError(metal.Conflict("cannot delete power feed %s while it still supplies %d racks", l.ID, len(racks))))
+			return
+		}
+	case metal.LocationLevelRack:
+		var ms metal.Machines
+		err = r.ds.SearchMachines(&datastore.MachineSearchQuery{RackID: &l.ID}, &ms)
+		if err != nil {
+			r.sendError(request, response, defaultError(err))
+			return
+		}
+		var ss metal.Switches
+		err = r.ds.SearchSwitches(&datastore.SwitchSearchQuery{RackID: &l.ID}, &ss)
+		if err != nil {
+			r.sendError(request, response, defaultError(err))
+			return
+		}
+		if len(ms) > 0 || len(ss) > 0 {
+			r.sendError(request, response, defaultError(metal.Conflict("cannot delete rack %s while it still contains %d machines and %d switches", l.ID, len(ms), len(ss))))
+			return
+		}
+	}
+
+	err = r.ds.DeleteLocation(l)
+	if err != nil {
+		r.sendError(request, response, defaultError(err))
+		return
+	}
+
+	r.send(request, response, http.StatusOK, v1.NewLocationResponse(l))
+}
+
+// validateLocation checks that the location fits into the existing hierarchy, references to missing locations are reported as not found.
+func (r *locationResource) validateLocation(l *metal.Location) *httperrors.HTTPErrorResponse {
+	ls, err := r.ds.ListLocations()
+	if err != nil {
+		return defaultError(err)
+	}
+
+	err = ls.ByID().Validate(l)
+	if err != nil {
+		if metal.IsNotFound(err) {
+			return defaultError(err)
+		}
+		return httperrors.BadRequest(err)
+	}
+
+	return nil
+}
@@ -0,0 +1,139 @@
+package service
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/emicklei/go-restful/v3"
+	"github.com/metal-stack/metal-api/cmd/metal-api/internal/datastore"
+	"github.com/metal-stack/metal-api/cmd/metal-api/internal/metal"
+	v1 "github.com/metal-stack/metal-api/cmd/metal-api/internal/service/v1"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLocationHierarchy(t *testing.T) {
+	log := slog.Default()
+	ds := datastore.NewMemory(log)
+
+	container := restful.NewContainer().Add(NewLocation(log, ds))
+
+	send := func(method, path string, payload any) *httptest.ResponseRecorder {
+		var body bytes.Buffer
+		if payload != nil {
+			require.NoError(t, json.NewEncoder(&body).Encode(payload))
+		}
+		req := httptest.NewRequest(method, path, &body)
+		req.Header.Add("Content-Type", "application/json")
+		container = injectAdmin(log, container, req)
+		w := httptest.NewRecorder()
+		container.ServeHTTP(w, req)
+		return w
+	}
+	create := func(id string, level metal.LocationLevel, parent, feed string) *httptest.ResponseRecorder {
+		return send("PUT", "/v1/location", v1.LocationCreateRequest{
+			Common:       v1.Common{Identifiable: v1.Identifiable{ID: id}},
+			LocationBase: v1.LocationBase{Level: string(level), ParentID: parent, PowerFeedID: feed},
+		})
+	}
+
+	for _, l := range []struct {
+		id     string
+		level  metal.LocationLevel
+		parent string
+		feed   string
+	}{
+		{id: "eu", level: metal.LocationLevelRegion},
+		{id: "fra1", level: metal.LocationLevelDatacenter, parent: "eu"},
+		{id: "fra1-a", level: metal.LocationLevelRow, parent: "fra1"},
+		{id: "feed-a", level: metal.LocationLevelPowerFeed, parent: "fra1"},
+		{id: "rack-1", level: metal.LocationLevelRack, parent: "fra1-a", feed: "feed-a"},
+	} {
+		w := create(l.id, l.level, l.parent, l.feed)
+		require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	}
+
+	w := create("rack-2", metal.LocationLevelRack, "fra1", "")
+	require.Equal(t, http.StatusBadRequest, w.Code, w.Body.String())
+
+	w = create("rack-2", metal.LocationLevelRack, "fra1-b", "")
+	require.Equal(t, http.StatusNotFound, w.Code, w.Body.String())
+
+	w = send("POST", "/v1/location/find", v1.LocationFindRequest{PowerFeedID: new("feed-a")})
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var found []v1.LocationResponse
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&found))
+	require.Len(t, found, 1)
+	require.Equal(t, "rack-1", found[0].ID)
+	require.Equal(t, "fra1-a", found[0].ParentID)
+
+	// referenced locations cannot be deleted
+	require.NoError(t, ds.CreateMachine(&metal.Machine{Base: metal.Base{ID: "m1"}, RackID: "rack-1"}))
+	for _, id := range []string{"fra1", "feed-a", "rack-1"} {
+		w = send("DELETE", "/v1/location/"+id, nil)
+		require.Equal(t, http.StatusConflict, w.Code, w.Body.String())
+	}
+
+	w = send("POST", "/v1/location", v1.LocationUpdateRequest{
+		Common:      v1.Common{Identifiable: v1.Identifiable{ID: "rack-1"}},
+		PowerFeedID: new(""),
+	})
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	w = send("DELETE", "/v1/location/feed-a", nil)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+}
+
+func TestPartitionCapacityByFailureDomain(t *testing.T) {
+	ds := datastore.NewMemory(slog.Default())
+
+	require.NoError(t, ds.CreatePartition(&metal.Partition{Base: metal.Base{ID: "p1"}}))
+	require.NoError(t, ds.CreateSize(&metal.Size{Base: metal.Base{ID: "s1"}}))
+	locations := metal.Locations{
+		{Base: metal.Base{ID: "rack-1"}, Level: metal.LocationLevelRack, PowerFeedID: "feed-a"},
+		{Base: metal.Base{ID: "rack-2"}, Level: metal.LocationLevelRack, PowerFeedID: "feed-a"},
+		{Base: metal.Base{ID: "rack-3"}, Level: metal.LocationLevelRack, PowerFeedID: "feed-b"},
+	}
+	for _, l := range locations {
+		require.NoError(t, ds.CreateLocation(&l))
+	}
+
+	machines := metal.Machines{
+		{Base: metal.Base{ID: "m1"}, RackID: "rack-1", Waiting: true},
+		{Base: metal.Base{ID: "m2"}, RackID: "rack-2", Waiting: true},
+		{Base: metal.Base{ID: "m3"}, RackID: "rack-3"},
+		{Base: metal.Base{ID: "m4"}, RackID: "rack-4", Waiting: true},
+	}
+	for _, m := range machines {
+		m.PartitionID = "p1"
+		m.SizeID = "s1"
+		m.State = metal.MachineState{Value: metal.AvailableState}
+		require.NoError(t, ds.CreateMachine(&m))
+		require.NoError(t, ds.CreateProvisioningEventContainer(&metal.ProvisioningEventContainer{
+			Base:       metal.Base{ID: m.ID},
+			Liveliness: metal.MachineLivelinessAlive,
+			Events:     metal.ProvisioningEvents{{Event: metal.ProvisioningEventWaiting}},
+		}))
+	}
+
+	pcs, err := PartitionCapacity(ds, &v1.PartitionCapacityRequest{ID: new("p1"), FailureDomainLevel: new(string(metal.LocationLevelPowerFeed))})
+	require.NoError(t, err)
+	require.Len(t, pcs, 1)
+	require.Len(t, pcs[0].ServerCapacities, 1)
+
+	// the test machines report issues, e.g. because of missing bmc details, which are not of interest here
+	for _, fd := range pcs[0].ServerCapacities[0].FailureDomains {
+		fd.Faulty = 0
+	}
+	require.Equal(t, v1.FailureDomainCapacities{
+		{Domain: "", Total: 1, Allocatable: 1},
+		{Domain: "feed-a", Total: 2, Allocatable: 2},
+		{Domain: "feed-b", Total: 1, Unavailable: 1},
+	}, pcs[0].ServerCapacities[0].FailureDomains)
+
+	_, err = PartitionCapacity(ds, &v1.PartitionCapacityRequest{ID: new("p1"), FailureDomainLevel: new("room")})
+	require.Error(t, err)
+}
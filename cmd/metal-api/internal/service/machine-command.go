@@ -0,0 +1,106 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/metal-stack/metal-api/cmd/metal-api/internal/datastore"
+	"github.com/metal-stack/metal-api/cmd/metal-api/internal/metal"
+	"github.com/metal-stack/metal-lib/pkg/pointer"
+)
+
+// MachineCommandTracker marks machine commands as timed out when the metal-bmc did not report
+// their result in time and removes finished commands which are older than the retention.
+type MachineCommandTracker struct {
+	log       *slog.Logger
+	ds        datastore.Store
+	retention time.Duration
+}
+
+// NewMachineCommandTracker returns a new machine command tracker, a retention of 0 keeps finished commands forever.
+func NewMachineCommandTracker(log *slog.Logger, ds datastore.Store, retention time.Duration) *MachineCommandTracker {
+	return &MachineCommandTracker{
+		log:       log,
+		ds:        ds,
+		retention: retention,
+	}
+}
+
+// Run evaluates the machine commands in the given interval until the context is done.
+func (t *MachineCommandTracker) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			err := t.Evaluate(time.Now())
+			if err != nil {
+				t.log.Error("unable to evaluate machine commands", "error", err)
+			}
+		}
+	}
+}
+
+// Evaluate expires the commands which passed their deadline and prunes the finished commands.
+// Commands which are reported in the meantime or which are not yet delivered by the outbox are skipped.
+func (t *MachineCommandTracker) Evaluate(now time.Time) error {
+	var es metal.MachineCommandExecutions
+	err := t.ds.SearchMachineCommandExecutions(&datastore.MachineCommandExecutionSearchQuery{}, &es)
+	if err != nil {
+		return err
+	}
+
+	var pending metal.OutboxEvents
+	err = t.ds.SearchOutboxEvents(&datastore.OutboxEventSearchQuery{State: pointer.Pointer(string(metal.OutboxEventStatePending))}, &pending)
+	if err != nil {
+		return err
+	}
+	undelivered := map[string]bool{}
+	for _, e := range pending {
+		if e.MachineCommandID != "" {
+			undelivered[e.MachineCommandID] = true
+		}
+	}
+
+	var errs []error
+	for i := range es {
+		old := &es[i]
+
+		if old.Status.Final() {
+			if t.retention > 0 && old.Finished != nil && old.Finished.Before(now.Add(-t.retention)) {
+				err := t.ds.DeleteMachineCommandExecution(old)
+				if err != nil && !metal.IsNotFound(err) {
+					errs = append(errs, err)
+				}
+			}
+			continue
+		}
+
+		if undelivered[old.ID] {
+			continue
+		}
+
+		expired := *old
+		if !expired.Expire(now) {
+			continue
+		}
+
+		err := t.ds.UpdateMachineCommandExecution(old, &expired)
+		if err != nil {
+			if metal.IsConflict(err) {
+				continue
+			}
+			errs = append(errs, err)
+			continue
+		}
+
+		t.log.Warn("machine command timed out", "machineID", expired.MachineID, "command", expired.Command, "id", expired.ID, "previous status", old.Status)
+	}
+
+	return errors.Join(errs...)
+}
@@ -0,0 +1,159 @@
+package service
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/emicklei/go-restful/v3"
+	"github.com/metal-stack/metal-api/cmd/metal-api/internal/datastore"
+	"github.com/metal-stack/metal-api/cmd/metal-api/internal/metal"
+	v1 "github.com/metal-stack/metal-api/cmd/metal-api/internal/service/v1"
+	"github.com/metal-stack/metal-lib/bus"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMachineCommandTracking(t *testing.T) {
+	var (
+		log       = slog.Default()
+		ds        = datastore.NewMemory(log)
+		published []metal.MachineEvent
+	)
+
+	m := &metal.Machine{Base: metal.Base{ID: "m1"}, PartitionID: "p1"}
+	require.NoError(t, ds.CreateMachine(m))
+
+	pub := &emptyPublisher{doPublish: func(topic string, data any) error {
+		evt, ok := data.(metal.MachineEvent)
+		require.True(t, ok)
+		published = append(published, evt)
+		return nil
+	}}
+
+	require.NoError(t, publishMachineCmd(log, ds, m, pub, metal.MachineOnCmd))
+	require.Len(t, published, 1)
+	commandID := published[0].Cmd.ID
+	require.NotEmpty(t, commandID)
+
+	e, err := ds.FindMachineCommandExecution(commandID)
+	require.NoError(t, err)
+	require.Equal(t, metal.MachineCommandStatusQueued, e.Status)
+	require.Equal(t, metal.MachineOnCmd, e.Command)
+
+	ms, err := NewMachine(log, ds, pub, bus.DirectEndpoints(), nil, nil, nil, nil, 0, nil, metal.DisabledIPMISuperUser(), 100)
+	require.NoError(t, err)
+	container := restful.NewContainer().Add(ms)
+
+	report := func(machineID string, report v1.MachineCommandReportRequest) *httptest.ResponseRecorder {
+		js, err := json.Marshal(report)
+		require.NoError(t, err)
+		req := httptest.NewRequest("POST", "/v1/machine/"+machineID+"/commands/"+commandID+"/report", bytes.NewBuffer(js))
+		req.Header.Add("Content-Type", "application/json")
+		container = injectEditor(log, container, req)
+		w := httptest.NewRecorder()
+		container.ServeHTTP(w, req)
+		return w
+	}
+
+	w := report("m2", v1.MachineCommandReportRequest{Status: "succeeded"})
+	require.Equal(t, http.StatusNotFound, w.Code, w.Body.String())
+
+	w = report("m1", v1.MachineCommandReportRequest{Status: "timed-out"})
+	require.Equal(t, http.StatusBadRequest, w.Code, w.Body.String())
+
+	w = report("m1", v1.MachineCommandReportRequest{Status: "failed", Message: "ipmi unreachable"})
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var result v1.MachineCommandResponse
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&result))
+	require.Equal(t, "failed", result.Status)
+	require.Equal(t, "ipmi unreachable", result.Message)
+	require.NotNil(t, result.Finished)
+
+	w = report("m1", v1.MachineCommandReportRequest{Status: "succeeded"})
+	require.Equal(t, http.StatusConflict, w.Code, w.Body.String())
+
+	// a command which cannot be published is failed immediately
+	pub.doPublish = func(topic string, data any) error {
+		return errors.New("nsqd unavailable")
+	}
+	require.Error(t, publishMachineCmd(log, ds, m, pub, metal.MachineResetCmd))
+
+	req := httptest.NewRequest("GET", "/v1/machine/m1/commands", nil)
+	container = injectViewer(log, container, req)
+	w = httptest.NewRecorder()
+	container.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	var commands []v1.MachineCommandResponse
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&commands))
+	require.Len(t, commands, 2)
+	require.Equal(t, string(metal.MachineResetCmd), commands[0].Command)
+	require.Equal(t, "failed", commands[0].Status)
+	require.Contains(t, commands[0].Message, "nsqd unavailable")
+	require.Equal(t, commandID, commands[1].ID)
+}
+
+func TestMachineCommandTracker(t *testing.T) {
+	var (
+		log = slog.Default()
+		ds  = datastore.NewMemory(log)
+		now = time.Now()
+	)
+
+	pending := metal.NewMachineCommandExecution("m1", metal.MachineOnCmd, now)
+	require.NoError(t, ds.CreateMachineCommandExecution(pending))
+	finished := metal.NewMachineCommandExecution("m1", metal.MachineOffCmd, now)
+	require.NoError(t, finished.Report(metal.MachineCommandStatusSucceeded, "", now))
+	require.NoError(t, ds.CreateMachineCommandExecution(finished))
+
+	tracker := NewMachineCommandTracker(log, ds, time.Hour)
+
+	require.NoError(t, tracker.Evaluate(now.Add(time.Minute)))
+	e, err := ds.FindMachineCommandExecution(pending.ID)
+	require.NoError(t, err)
+	require.Equal(t, metal.MachineCommandStatusQueued, e.Status)
+
+	require.NoError(t, tracker.Evaluate(now.Add(10*time.Minute)))
+	e, err = ds.FindMachineCommandExecution(pending.ID)
+	require.NoError(t, err)
+	require.Equal(t, metal.MachineCommandStatusTimedOut, e.Status)
+	_, err = ds.FindMachineCommandExecution(finished.ID)
+	require.NoError(t, err)
+
+	require.NoError(t, tracker.Evaluate(now.Add(2*time.Hour)))
+	_, err = ds.FindMachineCommandExecution(finished.ID)
+	require.True(t, metal.IsNotFound(err))
+	_, err = ds.FindMachineCommandExecution(pending.ID)
+	require.True(t, metal.IsNotFound(err))
+}
+
+func TestMachineCommandTracker_Undelivered(t *testing.T) {
+	var (
+		log = slog.Default()
+		ds  = datastore.NewMemory(log)
+		pub = &emptyPublisher{doPublish: func(topic string, data any) error {
+			return errors.New("nsqd unavailable")
+		}}
+		o = NewOutboxPublisher(log, ds, pub, OutboxConfig{})
+	)
+
+	m := &metal.Machine{Base: metal.Base{ID: "m1"}, PartitionID: "p1"}
+	require.NoError(t, ds.CreateMachine(m))
+	require.NoError(t, publishMachineCmd(log, ds, m, o, metal.MachineOnCmd))
+
+	var es metal.MachineCommandExecutions
+	require.NoError(t, ds.SearchMachineCommandExecutions(&datastore.MachineCommandExecutionSearchQuery{}, &es))
+	require.Len(t, es, 1)
+
+	// the command does not time out as long as it waits in the outbox
+	tracker := NewMachineCommandTracker(log, ds, 0)
+	require.NoError(t, tracker.Evaluate(time.Now().Add(time.Hour)))
+	e, err := ds.FindMachineCommandExecution(es[0].ID)
+	require.NoError(t, err)
+	require.Equal(t, metal.MachineCommandStatusQueued, e.Status)
+}
@@ -0,0 +1,196 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/metal-stack/metal-api/cmd/metal-api/internal/datastore"
+	"github.com/metal-stack/metal-api/cmd/metal-api/internal/headscale"
+	"github.com/metal-stack/metal-api/cmd/metal-api/internal/ipam"
+	"github.com/metal-stack/metal-api/cmd/metal-api/internal/metal"
+	"github.com/metal-stack/metal-lib/bus"
+)
+
+// MachineLeaseReaper frees the machines whose lease expired. Before a lease expires, a warning is published once
+// to the machine lease topic of the event bus, such that the owner of the machine can renew the lease.
+type MachineLeaseReaper struct {
+	log             *slog.Logger
+	ds              datastore.Store
+	publisher       bus.Publisher
+	actor           *asyncActor
+	headscaleClient *headscale.HeadscaleClient
+	warnBefore      time.Duration
+}
+
+// NewMachineLeaseReaper returns a new machine lease reaper which publishes a warning the given duration before a lease expires.
+func NewMachineLeaseReaper(log *slog.Logger, ds datastore.Store, publisher bus.Publisher, ep *bus.Endpoints, ipamer ipam.IPAMer, headscaleClient *headscale.HeadscaleClient, warnBefore time.Duration) (*MachineLeaseReaper, error) {
+	actor, err := newAsyncActor(log, ep, ds, ipamer)
+	if err != nil {
+		return nil, err
+	}
+
+	return &MachineLeaseReaper{
+		log:             log,
+		ds:              ds,
+		publisher:       publisher,
+		actor:           actor,
+		headscaleClient: headscaleClient,
+		warnBefore:      warnBefore,
+	}, nil
+}
+
+// Run reaps the expired leases in the given interval until the context is done.
+func (l *MachineLeaseReaper) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			err := l.Reap(ctx, time.Now())
+			if err != nil {
+				l.log.Error("unable to reap machine leases", "error", err)
+			}
+		}
+	}
+}
+
+// Reap frees the machines whose lease expired and warns about the leases which expire soon.
+func (l *MachineLeaseReaper) Reap(ctx context.Context, now time.Time) error {
+	var ms metal.Machines
+	err := l.ds.SearchMachines(&datastore.MachineSearchQuery{
+		AllocationLeased:             new(true),
+		AllocationLeaseExpiresBefore: new(now.Add(l.warnBefore)),
+	}, &ms)
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	for i := range ms {
+		m := &ms[i]
+		lease := m.Allocation.Lease
+
+		switch {
+		case lease.Expired(now):
+			err = l.free(ctx, m, now)
+		case lease.Expiring(l.warnBefore, now):
+			err = l.warn(m, now)
+		default:
+			continue
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("machine %s: %w", m.ID, err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// free releases the machine the same way as the free machine endpoint does. The lease is claimed before,
+// such that the machine is only freed by one metal-api instance and the lease cannot be renewed anymore.
+func (l *MachineLeaseReaper) free(ctx context.Context, m *metal.Machine, now time.Time) error {
+	logger := l.log.With("machineID", m.ID, "project", m.Allocation.Project, "expires", m.Allocation.Lease.Expires)
+
+	if m.State.Value == metal.LockedState {
+		// locked machines must not be freed, the lease is reaped as soon as the machine gets unlocked
+		logger.Debug("not freeing locked machine with expired lease")
+		return nil
+	}
+
+	if !m.Allocation.Lease.Reapable(now) {
+		logger.Debug("machine with expired lease is freed by another metal-api instance")
+		return nil
+	}
+
+	m, err := l.claim(m, now)
+	if err != nil {
+		if metal.IsConflict(err) {
+			// the lease was renewed or claimed by another metal-api instance in the meantime
+			return nil
+		}
+		return err
+	}
+
+	evt := metal.NewMachineLeaseEvent(metal.MachineLeaseExpired, m)
+
+	err = publishMachineCmd(logger, l.ds, m, l.publisher, metal.ChassisIdentifyLEDOffCmd)
+	if err != nil {
+		logger.Error("unable to publish machine command", "command", string(metal.ChassisIdentifyLEDOffCmd), "error", err)
+	}
+
+	err = l.actor.freeMachine(ctx, l.publisher, m, l.headscaleClient, logger)
+	if err != nil {
+		return err
+	}
+
+	logger.Info("freed machine with expired lease")
+
+	err = l.publisher.Publish(metal.TopicMachineLease.Name, evt)
+	if err != nil {
+		logger.Error("unable to publish machine lease event", "type", evt.Type, "error", err)
+	}
+
+	ev := metal.ProvisioningEvent{
+		Time:    time.Now(),
+		Event:   metal.ProvisioningEventMachineReclaim,
+		Message: "machine lease expired",
+	}
+	_, err = l.ds.ProvisioningEventForMachine(ctx, logger, &ev, m.ID)
+	if err != nil {
+		logger.Error("error sending provisioning event after machine free", "error", err)
+	}
+
+	return nil
+}
+
+// claim marks the lease as reaping, it fails with a conflict if the machine was changed in the meantime.
+func (l *MachineLeaseReaper) claim(m *metal.Machine, now time.Time) (*metal.Machine, error) {
+	lease := *m.Allocation.Lease
+	lease.Reaping = &now
+	allocation := *m.Allocation
+	allocation.Lease = &lease
+	claimed := *m
+	claimed.Allocation = &allocation
+
+	err := l.ds.UpdateMachine(m, &claimed)
+	if err != nil {
+		return nil, err
+	}
+
+	return &claimed, nil
+}
+
+// warn publishes the warning about the upcoming expiry and remembers it in the lease, such that it is only sent once.
+// the warning is sent after the machine was updated, so it is not sent again if the lease was renewed in the meantime.
+func (l *MachineLeaseReaper) warn(m *metal.Machine, now time.Time) error {
+	lease := *m.Allocation.Lease
+	lease.Warned = &now
+	allocation := *m.Allocation
+	allocation.Lease = &lease
+	warned := *m
+	warned.Allocation = &allocation
+
+	err := l.ds.UpdateMachine(m, &warned)
+	if err != nil {
+		if metal.IsConflict(err) {
+			return nil
+		}
+		return err
+	}
+
+	evt := metal.NewMachineLeaseEvent(metal.MachineLeaseExpiring, &warned)
+	err = l.publisher.Publish(metal.TopicMachineLease.Name, evt)
+	if err != nil {
+		return fmt.Errorf("unable to publish machine lease event: %w", err)
+	}
+
+	l.log.Info("machine lease expires soon", "machineID", m.ID, "project", m.Allocation.Project, "expires", lease.Expires)
+
+	return nil
+}
@@ -0,0 +1,178 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/emicklei/go-restful/v3"
+	"github.com/metal-stack/metal-api/cmd/metal-api/internal/datastore"
+	"github.com/metal-stack/metal-api/cmd/metal-api/internal/metal"
+	v1 "github.com/metal-stack/metal-api/cmd/metal-api/internal/service/v1"
+	"github.com/metal-stack/metal-lib/bus"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMachineLeaseReaper(t *testing.T) {
+	var (
+		log       = slog.Default()
+		ds        = datastore.NewMemory(log)
+		now       = time.Now()
+		published []metal.MachineLeaseEvent
+	)
+
+	leased := func(id string, expires time.Time) *metal.Machine {
+		return &metal.Machine{
+			Base:        metal.Base{ID: id},
+			PartitionID: "p1",
+			Allocation: &metal.MachineAllocation{
+				UUID:     "uuid-" + id,
+				Project:  "project-1",
+				Hostname: "host-" + id,
+				Lease:    &metal.MachineLease{Duration: time.Hour, Expires: expires},
+			},
+		}
+	}
+	createAllocatedMachine(t, ds, leased("expired", now.Add(-time.Minute)))
+	createAllocatedMachine(t, ds, leased("expiring", now.Add(30*time.Minute)))
+	createAllocatedMachine(t, ds, leased("running", now.Add(3*time.Hour)))
+	createAllocatedMachine(t, ds, &metal.Machine{Base: metal.Base{ID: "unleased"}, Allocation: &metal.MachineAllocation{Project: "project-1"}})
+	locked := leased("locked", now.Add(-time.Minute))
+	locked.State = metal.MachineState{Value: metal.LockedState}
+	createAllocatedMachine(t, ds, locked)
+	claimed := leased("claimed", now.Add(-time.Minute))
+	claimed.Allocation.Lease.Reaping = new(now.Add(-time.Minute))
+	createAllocatedMachine(t, ds, claimed)
+
+	pub := &emptyPublisher{doPublish: func(topic string, data any) error {
+		if topic != metal.TopicMachineLease.Name {
+			return nil
+		}
+		evt, ok := data.(metal.MachineLeaseEvent)
+		require.True(t, ok)
+		published = append(published, evt)
+		return nil
+	}}
+
+	reaper, err := NewMachineLeaseReaper(log, ds, pub, bus.DirectEndpoints(), nil, nil, time.Hour)
+	require.NoError(t, err)
+
+	require.NoError(t, reaper.Reap(context.Background(), now))
+
+	require.Len(t, published, 2)
+	require.Equal(t, metal.MachineLeaseExpired, published[0].Type)
+	require.Equal(t, "expired", published[0].MachineID)
+	require.Equal(t, "uuid-expired", published[0].AllocationUUID)
+	require.Equal(t, metal.MachineLeaseExpiring, published[1].Type)
+	require.Equal(t, "expiring", published[1].MachineID)
+
+	m, err := ds.FindMachineByID("expired")
+	require.NoError(t, err)
+	require.Nil(t, m.Allocation)
+
+	ec, err := ds.FindProvisioningEventContainer("expired")
+	require.NoError(t, err)
+	require.NotEmpty(t, ec.Events)
+	require.Equal(t, metal.ProvisioningEventMachineReclaim, ec.Events[len(ec.Events)-1].Event)
+
+	m, err = ds.FindMachineByID("expiring")
+	require.NoError(t, err)
+	require.NotNil(t, m.Allocation.Lease.Warned)
+
+	// the machine is freed by the metal-api instance which claimed the lease
+	for _, id := range []string{"running", "unleased", "locked", "claimed"} {
+		m, err = ds.FindMachineByID(id)
+		require.NoError(t, err)
+		require.NotNil(t, m.Allocation, id)
+	}
+
+	// the warning is only published once
+	published = nil
+	require.NoError(t, reaper.Reap(context.Background(), now.Add(time.Minute)))
+	require.Empty(t, published)
+
+	// the claim is taken over if the machine was not freed in time
+	require.NoError(t, reaper.Reap(context.Background(), now.Add(metal.MachineLeaseReapTimeout)))
+	m, err = ds.FindMachineByID("claimed")
+	require.NoError(t, err)
+	require.Nil(t, m.Allocation)
+}
+
+func TestRenewMachineLease(t *testing.T) {
+	log := slog.Default()
+	ds := datastore.NewMemory(log)
+
+	warned := time.Now().Add(-time.Minute)
+	createAllocatedMachine(t, ds, &metal.Machine{
+		Base: metal.Base{ID: "leased"},
+		Allocation: &metal.MachineAllocation{
+			Project: "project-1",
+			Lease:   &metal.MachineLease{Duration: time.Hour, Expires: time.Now().Add(10 * time.Minute), Warned: &warned},
+		},
+	})
+	createAllocatedMachine(t, ds, &metal.Machine{Base: metal.Base{ID: "unleased"}, Allocation: &metal.MachineAllocation{Project: "project-1"}})
+	createAllocatedMachine(t, ds, &metal.Machine{
+		Base: metal.Base{ID: "reaping"},
+		Allocation: &metal.MachineAllocation{
+			Project: "project-1",
+			Lease:   &metal.MachineLease{Duration: time.Hour, Expires: time.Now().Add(-time.Minute), Reaping: new(time.Now())},
+		},
+	})
+	require.NoError(t, ds.CreateProvisioningEventContainer(&metal.ProvisioningEventContainer{Base: metal.Base{ID: "leased"}}))
+
+	ws, err := NewMachine(log, ds, &emptyPublisher{}, bus.DirectEndpoints(), nil, nil, nil, nil, 0, nil, metal.DisabledIPMISuperUser(), 100)
+	require.NoError(t, err)
+	container := restful.NewContainer().Add(ws)
+
+	renew := func(machineID string, renew v1.MachineLeaseRenewRequest) *httptest.ResponseRecorder {
+		js, err := json.Marshal(renew)
+		require.NoError(t, err)
+		req := httptest.NewRequest("POST", "/v1/machine/"+machineID+"/lease/renew", bytes.NewBuffer(js))
+		req.Header.Add("Content-Type", "application/json")
+		container = injectEditor(log, container, req)
+		w := httptest.NewRecorder()
+		container.ServeHTTP(w, req)
+		return w
+	}
+
+	w := renew("unleased", v1.MachineLeaseRenewRequest{})
+	require.Equal(t, http.StatusBadRequest, w.Code, w.Body.String())
+
+	w = renew("reaping", v1.MachineLeaseRenewRequest{})
+	require.Equal(t, http.StatusConflict, w.Code, w.Body.String())
+
+	w = renew("leased", v1.MachineLeaseRenewRequest{Duration: new(time.Minute)})
+	require.Equal(t, http.StatusBadRequest, w.Code, w.Body.String())
+
+	w = renew("leased", v1.MachineLeaseRenewRequest{})
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var result v1.MachineResponse
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&result))
+	require.NotNil(t, result.Allocation.Lease)
+	require.Equal(t, time.Hour, result.Allocation.Lease.Duration)
+	require.Nil(t, result.Allocation.Lease.Warned)
+	require.WithinDuration(t, time.Now().Add(time.Hour), result.Allocation.Lease.Expires, time.Minute)
+
+	w = renew("leased", v1.MachineLeaseRenewRequest{Duration: new(48 * time.Hour)})
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	m, err := ds.FindMachineByID("leased")
+	require.NoError(t, err)
+	require.Equal(t, 48*time.Hour, m.Allocation.Lease.Duration)
+}
+
+// createAllocatedMachine creates the given machine and allocates it afterwards because allocated machines cannot be created.
+func createAllocatedMachine(t *testing.T, ds datastore.Store, m *metal.Machine) {
+	allocation := m.Allocation
+	m.Allocation = nil
+	require.NoError(t, ds.CreateMachine(m))
+	old, err := ds.FindMachineByID(m.ID)
+	require.NoError(t, err)
+	allocated := *old
+	allocated.Allocation = allocation
+	require.NoError(t, ds.UpdateMachine(old, &allocated))
+}
@@ -1,6 +1,7 @@
 package service
 
 import (
+	"cmp"
 	"context"
 	"errors"
 	"fmt"
@@ -14,6 +15,7 @@ import (
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/metal-stack/metal-api/cmd/metal-api/internal/fsm"
 	"github.com/metal-stack/metal-api/cmd/metal-api/internal/headscale"
 	"github.com/metal-stack/metal-api/cmd/metal-api/internal/issues"
 	auditinghttp "github.com/metal-stack/metal-lib/auditing/http"
@@ -55,6 +57,9 @@ type machineResource struct {
 	reasonMinLength uint
 	headscaleClient *headscale.HeadscaleClient
 	ipmiSuperUser   metal.MachineIPMISuperUser
+
+	// bulkAllocationLimit is the maximum amount of machines which can be allocated with a single bulk allocation
+	bulkAllocationLimit uint
 }
 
 // machineAllocationSpec is a specification for a machine allocation
@@ -77,11 +82,12 @@ type machineAllocationSpec struct {
 	IPs                []string
 	Role               metal.Role
 	VPN                *metal.MachineVPN
-	PlacementTags      []string
+	Placement          metal.Placement
 	EgressRules        []metal.EgressRule
 	IngressRules       []metal.IngressRule
 	DNSServers         metal.DNSServers
 	NTPServers         metal.NTPServers
+	LeaseDuration      *time.Duration
 }
 
 // allocationNetwork is intermediate struct to create machine networks from regular networks during machine allocation
@@ -113,7 +119,7 @@ type Allocator func(Allocation) error
 // NewMachine returns a webservice for machine specific endpoints.
 func NewMachine(
 	log *slog.Logger,
-	ds *datastore.RethinkStore,
+	ds datastore.Store,
 	pub bus.Publisher,
 	ep *bus.Endpoints,
 	ipamer ipam.IPAMer,
@@ -123,6 +129,7 @@ func NewMachine(
 	reasonMinLength uint,
 	headscaleClient *headscale.HeadscaleClient,
 	ipmiSuperUser metal.MachineIPMISuperUser,
+	bulkAllocationLimit uint,
 ) (*restful.WebService, error) {
 	r := machineResource{
 		webResource: webResource{
@@ -137,6 +144,8 @@ func NewMachine(
 		reasonMinLength: reasonMinLength,
 		headscaleClient: headscaleClient,
 		ipmiSuperUser:   ipmiSuperUser,
+
+		bulkAllocationLimit: bulkAllocationLimit,
 	}
 
 	var err error
@@ -182,21 +191,28 @@ func (r *machineResource) webService() *restful.WebService {
 	ws.Route(ws.GET("/").
 		To(viewer(r.listMachines)).
 		Operation("listMachines").
-		Doc("get all known machines").
+		Doc("get all known machines, the result can be paged").
+		Param(ws.QueryParameter("limit", "the maximum number of machines to return").DataType("integer")).
+		Param(ws.QueryParameter("offset", "the number of machines to skip, prefer the cursor to iterate over pages").DataType("integer")).
+		Param(ws.QueryParameter("sort_by", "the key to sort the machines by, the id is used if not set").DataType("string")).
+		Param(ws.QueryParameter("sort_descending", "sort the machines in descending order").DataType("boolean")).
+		Param(ws.QueryParameter("cursor", "continue after the machine this cursor points to, it is returned in the "+NextCursorHeader+" header of the previous page").DataType("string")).
+		Param(fieldsParameter(ws)).
 		Metadata(restfulspec.KeyOpenAPITags, tags).
 		Writes([]v1.MachineResponse{}).
-		Returns(http.StatusOK, "OK", []v1.MachineResponse{}).
+		ReturnsWithHeaders(http.StatusOK, "OK", []v1.MachineResponse{}, pageHeaders).
 		DefaultReturns("Error", httperrors.HTTPErrorResponse{}))
 
 	ws.Route(ws.POST("/find").
 		To(viewer(r.findMachines)).
 		Operation("findMachines").
-		Doc("find machines by multiple criteria").
+		Doc("find machines by multiple criteria, the result can be paged").
+		Param(fieldsParameter(ws)).
 		Metadata(restfulspec.KeyOpenAPITags, tags).
 		Metadata(auditinghttp.Exclude, true).
 		Reads(v1.MachineFindRequest{}).
 		Writes([]v1.MachineResponse{}).
-		Returns(http.StatusOK, "OK", []v1.MachineResponse{}).
+		ReturnsWithHeaders(http.StatusOK, "OK", []v1.MachineResponse{}, pageHeaders).
 		DefaultReturns("Error", httperrors.HTTPErrorResponse{}))
 
 	ws.Route(ws.POST("/").
@@ -218,6 +234,69 @@ func (r *machineResource) webService() *restful.WebService {
 		Reads(v1.MachineAllocateRequest{}).
 		Writes(v1.MachineResponse{}).
 		Returns(http.StatusOK, "OK", v1.MachineResponse{}).
+		Returns(http.StatusUnprocessableEntity, "Quota Exceeded", v1.QuotaExceededResponse{}).
+		DefaultReturns("Error", httperrors.HTTPErrorResponse{}))
+
+	ws.Route(ws.POST("/allocate/bulk").
+		To(editor(r.allocateMachines)).
+		Operation("allocateMachines").
+		Doc("allocates multiple machines at once, either all machines get allocated or none of them").
+		Metadata(restfulspec.KeyOpenAPITags, tags).
+		Reads(v1.MachineBulkAllocateRequest{}).
+		Writes(v1.MachineBulkAllocateResponse{}).
+		Returns(http.StatusOK, "OK", v1.MachineBulkAllocateResponse{}).
+		Returns(http.StatusUnprocessableEntity, "Quota Exceeded", v1.QuotaExceededResponse{}).
+		DefaultReturns("Error", httperrors.HTTPErrorResponse{}))
+
+	ws.Route(ws.POST("/allocate/explain").
+		To(editor(r.explainMachineAllocation)).
+		Operation("explainMachineAllocation").
+		Doc("explains whether a machine allocation would succeed and why machines are not considered for it, nothing gets allocated").
+		Metadata(restfulspec.KeyOpenAPITags, tags).
+		Reads(v1.MachineAllocateRequest{}).
+		Writes(v1.MachineAllocationExplainResponse{}).
+		Returns(http.StatusOK, "OK", v1.MachineAllocationExplainResponse{}).
+		DefaultReturns("Error", httperrors.HTTPErrorResponse{}))
+
+	ws.Route(ws.POST("/allocate/queue").
+		To(editor(r.queueMachineAllocation)).
+		Operation("queueMachineAllocation").
+		Doc("queues a machine allocation which is fulfilled as soon as a machine of the requested size becomes available in the partition, if a machine is available already it gets allocated immediately").
+		Metadata(restfulspec.KeyOpenAPITags, tags).
+		Reads(v1.MachineQueuedAllocateRequest{}).
+		Writes(v1.PendingMachineAllocationResponse{}).
+		Returns(http.StatusAccepted, "Accepted", v1.PendingMachineAllocationResponse{}).
+		Returns(http.StatusUnprocessableEntity, "Quota Exceeded", v1.QuotaExceededResponse{}).
+		DefaultReturns("Error", httperrors.HTTPErrorResponse{}))
+
+	ws.Route(ws.GET("/allocate/queue").
+		To(viewer(r.listPendingMachineAllocations)).
+		Operation("listPendingMachineAllocations").
+		Doc("get all queued machine allocations").
+		Metadata(restfulspec.KeyOpenAPITags, tags).
+		Writes([]v1.PendingMachineAllocationResponse{}).
+		Returns(http.StatusOK, "OK", []v1.PendingMachineAllocationResponse{}).
+		DefaultReturns("Error", httperrors.HTTPErrorResponse{}))
+
+	ws.Route(ws.GET("/allocate/queue/{id}").
+		To(viewer(r.findPendingMachineAllocation)).
+		Operation("findPendingMachineAllocation").
+		Doc("get a queued machine allocation by id").
+		Param(ws.PathParameter("id", "identifier of the queued allocation").DataType("string")).
+		Metadata(restfulspec.KeyOpenAPITags, tags).
+		Writes(v1.PendingMachineAllocationResponse{}).
+		Returns(http.StatusOK, "OK", v1.PendingMachineAllocationResponse{}).
+		DefaultReturns("Error", httperrors.HTTPErrorResponse{}))
+
+	ws.Route(ws.DELETE("/allocate/queue/{id}").
+		To(editor(r.cancelPendingMachineAllocation)).
+		Operation("cancelPendingMachineAllocation").
+		Doc("cancels a queued machine allocation, allocations which are currently being allocated cannot be cancelled").
+		Param(ws.PathParameter("id", "identifier of the queued allocation").DataType("string")).
+		Metadata(restfulspec.KeyOpenAPITags, tags).
+		Writes(v1.PendingMachineAllocationResponse{}).
+		Returns(http.StatusOK, "OK", v1.PendingMachineAllocationResponse{}).
+		Returns(http.StatusConflict, "Conflict", httperrors.HTTPErrorResponse{}).
 		DefaultReturns("Error", httperrors.HTTPErrorResponse{}))
 
 	ws.Route(ws.POST("/{id}/state").
@@ -231,6 +310,18 @@ func (r *machineResource) webService() *restful.WebService {
 		Returns(http.StatusOK, "OK", v1.MachineResponse{}).
 		DefaultReturns("Error", httperrors.HTTPErrorResponse{}))
 
+	ws.Route(ws.POST("/{id}/lease/renew").
+		To(editor(r.renewMachineLease)).
+		Operation("renewMachineLease").
+		Doc("renews the lease of an allocated machine, the lease is extended by the given duration starting from now").
+		Param(ws.PathParameter("id", "identifier of the machine").DataType("string")).
+		Metadata(restfulspec.KeyOpenAPITags, tags).
+		Reads(v1.MachineLeaseRenewRequest{}).
+		Writes(v1.MachineResponse{}).
+		Returns(http.StatusOK, "OK", v1.MachineResponse{}).
+		Returns(http.StatusConflict, "Conflict", httperrors.HTTPErrorResponse{}).
+		DefaultReturns("Error", httperrors.HTTPErrorResponse{}))
+
 	ws.Route(ws.DELETE("/{id}/free").
 		To(editor(r.freeMachine)).
 		Operation("freeMachine").
@@ -292,6 +383,111 @@ func (r *machineResource) webService() *restful.WebService {
 		Returns(http.StatusOK, "OK", v1.MachineIPMIResponse{}).
 		DefaultReturns("Error", httperrors.HTTPErrorResponse{}))
 
+	ws.Route(ws.GET("/{id}/bootconfig").
+		To(viewer(r.findMachineBootConfiguration)).
+		Operation("findMachineBootConfiguration").
+		Doc("returns the boot configuration of the machine including the applied partition boot configuration override").
+		Param(ws.PathParameter("id", "identifier of the machine").DataType("string")).
+		Metadata(restfulspec.KeyOpenAPITags, tags).
+		Writes(v1.MachineBootConfigurationResponse{}).
+		Returns(http.StatusOK, "OK", v1.MachineBootConfigurationResponse{}).
+		DefaultReturns("Error", httperrors.HTTPErrorResponse{}))
+
+	ws.Route(ws.GET("/{id}/hardware-history").
+		To(viewer(r.listMachineHardwareHistory)).
+		Operation("listMachineHardwareHistory").
+		Doc("returns the hardware snapshots which were recorded on the registrations of the machine").
+		Param(ws.PathParameter("id", "identifier of the machine").DataType("string")).
+		Metadata(restfulspec.KeyOpenAPITags, tags).
+		Writes([]v1.MachineHardwareSnapshotResponse{}).
+		Returns(http.StatusOK, "OK", []v1.MachineHardwareSnapshotResponse{}).
+		DefaultReturns("Error", httperrors.HTTPErrorResponse{}))
+
+	ws.Route(ws.POST("/{id}/hardware-history/acknowledge").
+		To(admin(r.acknowledgeMachineHardwareChanges)).
+		Operation("acknowledgeMachineHardwareChanges").
+		Doc("acknowledges the hardware changes of the latest hardware snapshot of the machine, such that they are not reported as hardware drift issue anymore").
+		Param(ws.PathParameter("id", "identifier of the machine").DataType("string")).
+		Metadata(restfulspec.KeyOpenAPITags, tags).
+		Writes(v1.MachineHardwareSnapshotResponse{}).
+		Returns(http.StatusOK, "OK", v1.MachineHardwareSnapshotResponse{}).
+		DefaultReturns("Error", httperrors.HTTPErrorResponse{}))
+
+	ws.Route(ws.GET("/{id}/power-history").
+		To(viewer(r.listMachinePowerHistory)).
+		Operation("listMachinePowerHistory").
+		Doc("returns the retained power samples of the machine in chronological order, older samples are downsampled to hourly samples").
+		Param(ws.PathParameter("id", "identifier of the machine").DataType("string")).
+		Metadata(restfulspec.KeyOpenAPITags, tags).
+		Writes([]v1.MachinePowerSampleResponse{}).
+		Returns(http.StatusOK, "OK", []v1.MachinePowerSampleResponse{}).
+		DefaultReturns("Error", httperrors.HTTPErrorResponse{}))
+
+	ws.Route(ws.GET("/{id}/commands").
+		To(viewer(r.listMachineCommands)).
+		Operation("listMachineCommands").
+		Doc("returns the recent commands which were sent to the machine together with their execution status, the latest first").
+		Param(ws.PathParameter("id", "identifier of the machine").DataType("string")).
+		Metadata(restfulspec.KeyOpenAPITags, tags).
+		Writes([]v1.MachineCommandResponse{}).
+		Returns(http.StatusOK, "OK", []v1.MachineCommandResponse{}).
+		DefaultReturns("Error", httperrors.HTTPErrorResponse{}))
+
+	ws.Route(ws.POST("/{id}/commands/{commandid}/report").
+		To(editor(r.reportMachineCommand)).
+		Operation("reportMachineCommand").
+		Doc("reports the execution status of a command, this is called by the metal-bmc").
+		Param(ws.PathParameter("id", "identifier of the machine").DataType("string")).
+		Param(ws.PathParameter("commandid", "identifier of the command execution").DataType("string")).
+		Metadata(restfulspec.KeyOpenAPITags, tags).
+		Reads(v1.MachineCommandReportRequest{}).
+		Writes(v1.MachineCommandResponse{}).
+		Returns(http.StatusOK, "OK", v1.MachineCommandResponse{}).
+		Returns(http.StatusConflict, "Conflict", httperrors.HTTPErrorResponse{}).
+		DefaultReturns("Error", httperrors.HTTPErrorResponse{}))
+
+	ws.Route(ws.GET("/{id}/provisioning-timeline").
+		To(viewer(r.findMachineProvisioningTimeline)).
+		Operation("findMachineProvisioningTimeline").
+		Doc("returns the provisioning phases of the machine and the durations of its provisioning transitions computed from the provisioning events").
+		Param(ws.PathParameter("id", "identifier of the machine").DataType("string")).
+		Metadata(restfulspec.KeyOpenAPITags, tags).
+		Writes(v1.MachineProvisioningTimelineResponse{}).
+		Returns(http.StatusOK, "OK", v1.MachineProvisioningTimelineResponse{}).
+		DefaultReturns("Error", httperrors.HTTPErrorResponse{}))
+
+	ws.Route(ws.POST("/event-log/find").
+		To(viewer(r.findMachineProvisioningEventLog)).
+		Operation("findMachineProvisioningEventLog").
+		Doc("searches the provisioning event log, which keeps the provisioning events of all machines until the retention expires").
+		Metadata(restfulspec.KeyOpenAPITags, tags).
+		Reads(datastore.ProvisioningEventLogSearchQuery{}).
+		Writes(v1.MachineProvisioningEventLogResponse{}).
+		Returns(http.StatusOK, "OK", v1.MachineProvisioningEventLogResponse{}).
+		DefaultReturns("Error", httperrors.HTTPErrorResponse{}))
+
+	ws.Route(ws.POST("/provisioning-statistics").
+		To(viewer(r.machineProvisioningStatistics)).
+		Operation("machineProvisioningStatistics").
+		Doc("returns the durations of the provisioning transitions of all machines grouped by size, partition or image").
+		Metadata(restfulspec.KeyOpenAPITags, tags).
+		Metadata(auditinghttp.Exclude, true).
+		Reads(v1.MachineProvisioningStatisticsRequest{}).
+		Writes([]v1.MachineProvisioningStatistic{}).
+		Returns(http.StatusOK, "OK", []v1.MachineProvisioningStatistic{}).
+		DefaultReturns("Error", httperrors.HTTPErrorResponse{}))
+
+	ws.Route(ws.POST("/power-aggregation").
+		To(viewer(r.machinePowerAggregation)).
+		Operation("machinePowerAggregation").
+		Doc("returns the power consumption of the machines within a time window grouped by rack, partition, project or size").
+		Metadata(restfulspec.KeyOpenAPITags, tags).
+		Metadata(auditinghttp.Exclude, true).
+		Reads(v1.MachinePowerAggregationRequest{}).
+		Writes(v1.MachinePowerAggregationResponse{}).
+		Returns(http.StatusOK, "OK", v1.MachinePowerAggregationResponse{}).
+		DefaultReturns("Error", httperrors.HTTPErrorResponse{}))
+
 	ws.Route(ws.POST("/ipmi/find").
 		To(viewer(r.findIPMIMachines)).
 		Operation("findIPMIMachines").
@@ -431,7 +627,21 @@ func (r *machineResource) webService() *restful.WebService {
 }
 
 func (r *machineResource) listMachines(request *restful.Request, response *restful.Response) {
-	ms, err := r.ds.ListMachines()
+	paging, err := pagingFromQuery(request)
+	if err != nil {
+		r.sendError(request, response, httperrors.BadRequest(err))
+		return
+	}
+
+	q := datastore.MachineSearchQuery{Paging: paging}
+	err = q.Validate()
+	if err != nil {
+		r.sendError(request, response, httperrors.BadRequest(err))
+		return
+	}
+
+	ms := metal.Machines{}
+	err = r.ds.SearchMachines(&q, &ms)
 	if err != nil {
 		r.sendError(request, response, defaultError(err))
 		return
@@ -443,7 +653,7 @@ func (r *machineResource) listMachines(request *restful.Request, response *restf
 		return
 	}
 
-	r.send(request, response, http.StatusOK, resp)
+	r.sendPage(request, response, resp, "id", q.NextCursor(ms))
 }
 
 func (r *machineResource) findMachine(request *restful.Request, response *restful.Response) {
@@ -597,6 +807,18 @@ func (r *machineResource) issues(request *restful.Request, response *restful.Res
 		return
 	}
 
+	sizes, err := r.ds.ListSizes()
+	if err != nil {
+		r.sendError(request, response, defaultError(err))
+		return
+	}
+
+	snapshots, err := r.ds.ListLatestHardwareSnapshots()
+	if err != nil {
+		r.sendError(request, response, defaultError(err))
+		return
+	}
+
 	machinesWithIssues, err := issues.Find(&issues.Config{
 		Machines:           ms,
 		EventContainers:    ecs,
@@ -604,6 +826,8 @@ func (r *machineResource) issues(request *restful.Request, response *restful.Res
 		Only:               only,
 		Omit:               omit,
 		LastErrorThreshold: lastErrorThreshold,
+		Sizes:              sizes,
+		HardwareSnapshots:  snapshots,
 	})
 	if err != nil {
 		r.sendError(request, response, defaultError(err))
@@ -676,6 +900,12 @@ func (r *machineResource) findMachines(request *restful.Request, response *restf
 		return
 	}
 
+	err = requestPayload.Validate()
+	if err != nil {
+		r.sendError(request, response, httperrors.BadRequest(err))
+		return
+	}
+
 	ms := metal.Machines{}
 	err = r.ds.SearchMachines(&requestPayload, &ms)
 	if err != nil {
@@ -689,6 +919,67 @@ func (r *machineResource) findMachines(request *restful.Request, response *restf
 		return
 	}
 
+	r.sendPage(request, response, resp, "id", requestPayload.NextCursor(ms))
+}
+
+func (r *machineResource) renewMachineLease(request *restful.Request, response *restful.Response) {
+	var requestPayload v1.MachineLeaseRenewRequest
+	err := request.ReadEntity(&requestPayload)
+	if err != nil {
+		r.sendError(request, response, httperrors.BadRequest(err))
+		return
+	}
+
+	id := request.PathParameter("id")
+
+	oldMachine, err := r.ds.FindMachineByID(id)
+	if err != nil {
+		r.sendError(request, response, defaultError(err))
+		return
+	}
+
+	if oldMachine.Allocation == nil || oldMachine.Allocation.Lease == nil {
+		r.sendError(request, response, httperrors.BadRequest(fmt.Errorf("machine %s is not leased", id)))
+		return
+	}
+
+	if oldMachine.Allocation.Lease.Reaping != nil {
+		r.sendError(request, response, defaultError(metal.Conflict("lease of machine %s expired and the machine is being freed", id)))
+		return
+	}
+
+	duration := oldMachine.Allocation.Lease.Duration
+	if requestPayload.Duration != nil {
+		duration = *requestPayload.Duration
+	}
+
+	lease := *oldMachine.Allocation.Lease
+	err = lease.Renew(duration, time.Now())
+	if err != nil {
+		r.sendError(request, response, httperrors.BadRequest(err))
+		return
+	}
+
+	allocation := *oldMachine.Allocation
+	allocation.Lease = &lease
+	newMachine := *oldMachine
+	newMachine.Allocation = &allocation
+
+	// a conflict is returned if the machine was freed by the lease reaper in the meantime
+	err = r.ds.UpdateMachine(oldMachine, &newMachine)
+	if err != nil {
+		r.sendError(request, response, defaultError(err))
+		return
+	}
+
+	r.logger(request).Info("renewed machine lease", "machineID", id, "expires", lease.Expires)
+
+	resp, err := makeMachineResponse(&newMachine, r.ds)
+	if err != nil {
+		r.sendError(request, response, defaultError(err))
+		return
+	}
+
 	r.send(request, response, http.StatusOK, resp)
 }
 
@@ -770,6 +1061,366 @@ func (r *machineResource) findIPMIMachine(request *restful.Request, response *re
 	r.send(request, response, http.StatusOK, resp)
 }
 
+func (r *machineResource) findMachineBootConfiguration(request *restful.Request, response *restful.Response) {
+	id := request.PathParameter("id")
+
+	m, err := r.ds.FindMachineByID(id)
+	if err != nil {
+		r.sendError(request, response, defaultError(err))
+		return
+	}
+
+	p, err := r.ds.FindPartition(m.PartitionID)
+	if err != nil {
+		r.sendError(request, response, defaultError(err))
+		return
+	}
+
+	r.send(request, response, http.StatusOK, v1.NewMachineBootConfigurationResponse(m, p))
+}
+
+func (r *machineResource) listMachineHardwareHistory(request *restful.Request, response *restful.Response) {
+	id := request.PathParameter("id")
+
+	m, err := r.ds.FindMachineByID(id)
+	if err != nil {
+		r.sendError(request, response, defaultError(err))
+		return
+	}
+
+	var snapshots metal.HardwareSnapshots
+	err = r.ds.SearchHardwareSnapshots(&datastore.HardwareSnapshotSearchQuery{MachineID: &m.ID}, &snapshots)
+	if err != nil {
+		r.sendError(request, response, defaultError(err))
+		return
+	}
+
+	snapshots.SortByRevision()
+
+	resp := []*v1.MachineHardwareSnapshotResponse{}
+	for i := range snapshots {
+		resp = append(resp, v1.NewMachineHardwareSnapshotResponse(&snapshots[i]))
+	}
+
+	r.send(request, response, http.StatusOK, resp)
+}
+
+func (r *machineResource) acknowledgeMachineHardwareChanges(request *restful.Request, response *restful.Response) {
+	id := request.PathParameter("id")
+
+	user, err := r.userGetter.User(request.Request)
+	if err != nil {
+		r.sendError(request, response, defaultError(err))
+		return
+	}
+
+	var snapshots metal.HardwareSnapshots
+	err = r.ds.SearchHardwareSnapshots(&datastore.HardwareSnapshotSearchQuery{MachineID: &id}, &snapshots)
+	if err != nil {
+		r.sendError(request, response, defaultError(err))
+		return
+	}
+
+	latest := snapshots.Latest()
+	if latest == nil || len(latest.Changes) == 0 {
+		r.sendError(request, response, httperrors.UnprocessableEntity(fmt.Errorf("no hardware changes of machine %q to acknowledge", id)))
+		return
+	}
+
+	if latest.Acknowledged == nil {
+		acknowledged := *latest
+		acknowledged.Acknowledged = new(time.Now())
+		acknowledged.AcknowledgedBy = user.EMail
+
+		err = r.ds.UpdateHardwareSnapshot(latest, &acknowledged)
+		if err != nil {
+			r.sendError(request, response, defaultError(err))
+			return
+		}
+
+		r.logger(request).Info("acknowledged hardware changes", "machineID", id, "revision", acknowledged.Revision, "user", user.EMail)
+		latest = &acknowledged
+	}
+
+	r.send(request, response, http.StatusOK, v1.NewMachineHardwareSnapshotResponse(latest))
+}
+
+func (r *machineResource) listMachinePowerHistory(request *restful.Request, response *restful.Response) {
+	id := request.PathParameter("id")
+
+	m, err := r.ds.FindMachineByID(id)
+	if err != nil {
+		r.sendError(request, response, defaultError(err))
+		return
+	}
+
+	var ss metal.PowerSamples
+	err = r.ds.SearchPowerSamples(&datastore.PowerSampleSearchQuery{MachineID: &m.ID}, &ss)
+	if err != nil {
+		r.sendError(request, response, defaultError(err))
+		return
+	}
+
+	slices.SortStableFunc(ss, func(a, b metal.PowerSample) int {
+		return a.Time.Compare(b.Time)
+	})
+
+	resp := []*v1.MachinePowerSampleResponse{}
+	for i := range ss {
+		resp = append(resp, v1.NewMachinePowerSampleResponse(&ss[i]))
+	}
+
+	r.send(request, response, http.StatusOK, resp)
+}
+
+func (r *machineResource) machinePowerAggregation(request *restful.Request, response *restful.Response) {
+	var requestPayload v1.MachinePowerAggregationRequest
+	err := request.ReadEntity(&requestPayload)
+	if err != nil {
+		r.sendError(request, response, httperrors.BadRequest(err))
+		return
+	}
+
+	now := time.Now()
+
+	_, err = powerAggregationGroup(requestPayload.GroupBy)
+	if err != nil {
+		r.sendError(request, response, httperrors.BadRequest(err))
+		return
+	}
+	_, err = powerAggregationEnd(&requestPayload, now)
+	if err != nil {
+		r.sendError(request, response, httperrors.BadRequest(err))
+		return
+	}
+
+	resp, err := MachinePowerAggregation(r.ds, &requestPayload, now)
+	if err != nil {
+		r.sendError(request, response, defaultError(err))
+		return
+	}
+
+	r.send(request, response, http.StatusOK, resp)
+}
+
+func (r *machineResource) listMachineCommands(request *restful.Request, response *restful.Response) {
+	id := request.PathParameter("id")
+
+	m, err := r.ds.FindMachineByID(id)
+	if err != nil {
+		r.sendError(request, response, defaultError(err))
+		return
+	}
+
+	var es metal.MachineCommandExecutions
+	err = r.ds.SearchMachineCommandExecutions(&datastore.MachineCommandExecutionSearchQuery{MachineID: &m.ID}, &es)
+	if err != nil {
+		r.sendError(request, response, defaultError(err))
+		return
+	}
+
+	es.SortNewestFirst()
+
+	resp := []*v1.MachineCommandResponse{}
+	for i := range es {
+		resp = append(resp, v1.NewMachineCommandResponse(&es[i]))
+	}
+
+	r.send(request, response, http.StatusOK, resp)
+}
+
+func (r *machineResource) reportMachineCommand(request *restful.Request, response *restful.Response) {
+	var requestPayload v1.MachineCommandReportRequest
+	err := request.ReadEntity(&requestPayload)
+	if err != nil {
+		r.sendError(request, response, httperrors.BadRequest(err))
+		return
+	}
+
+	status, err := metal.MachineCommandStatusFrom(requestPayload.Status)
+	if err != nil {
+		r.sendError(request, response, httperrors.BadRequest(err))
+		return
+	}
+
+	id := request.PathParameter("id")
+	commandID := request.PathParameter("commandid")
+
+	old, err := r.ds.FindMachineCommandExecution(commandID)
+	if err != nil {
+		r.sendError(request, response, defaultError(err))
+		return
+	}
+	if old.MachineID != id {
+		r.sendError(request, response, defaultError(metal.NotFound("command %s was not sent to machine %s", commandID, id)))
+		return
+	}
+
+	e := *old
+	err = e.Report(status, requestPayload.Message, time.Now())
+	if err != nil {
+		if metal.IsConflict(err) {
+			r.sendError(request, response, defaultError(err))
+			return
+		}
+		r.sendError(request, response, httperrors.BadRequest(err))
+		return
+	}
+
+	err = r.ds.UpdateMachineCommandExecution(old, &e)
+	if err != nil {
+		r.sendError(request, response, defaultError(err))
+		return
+	}
+
+	r.logger(request).Info("machine command reported", "machineID", id, "command", e.Command, "id", e.ID, "status", e.Status)
+
+	r.send(request, response, http.StatusOK, v1.NewMachineCommandResponse(&e))
+}
+
+func (r *machineResource) findMachineProvisioningTimeline(request *restful.Request, response *restful.Response) {
+	id := request.PathParameter("id")
+
+	m, err := r.ds.FindMachineByID(id)
+	if err != nil {
+		r.sendError(request, response, defaultError(err))
+		return
+	}
+
+	ec, err := r.ds.FindProvisioningEventContainer(m.ID)
+	if err != nil && !metal.IsNotFound(err) {
+		r.sendError(request, response, defaultError(err))
+		return
+	}
+
+	r.send(request, response, http.StatusOK, v1.NewMachineProvisioningTimelineResponse(m.ID, fsm.ProvisioningTimeline(ec), time.Now()))
+}
+
+func (r *machineResource) findMachineProvisioningEventLog(request *restful.Request, response *restful.Response) {
+	var requestPayload datastore.ProvisioningEventLogSearchQuery
+	err := request.ReadEntity(&requestPayload)
+	if err != nil {
+		r.sendError(request, response, httperrors.BadRequest(err))
+		return
+	}
+
+	entries, next, err := r.ds.SearchProvisioningEventLog(&requestPayload)
+	if err != nil {
+		r.sendError(request, response, defaultError(err))
+		return
+	}
+
+	r.send(request, response, http.StatusOK, v1.NewMachineProvisioningEventLogResponse(entries, next))
+}
+
+func (r *machineResource) machineProvisioningStatistics(request *restful.Request, response *restful.Response) {
+	var requestPayload v1.MachineProvisioningStatisticsRequest
+	err := request.ReadEntity(&requestPayload)
+	if err != nil {
+		r.sendError(request, response, httperrors.BadRequest(err))
+		return
+	}
+
+	_, err = provisioningStatisticsGroup(requestPayload.GroupBy)
+	if err != nil {
+		r.sendError(request, response, httperrors.BadRequest(err))
+		return
+	}
+
+	stats, err := MachineProvisioningStatistics(r.ds, &requestPayload)
+	if err != nil {
+		r.sendError(request, response, defaultError(err))
+		return
+	}
+
+	r.send(request, response, http.StatusOK, stats)
+}
+
+// MachineProvisioningStatistics calculates the durations of the provisioning transitions of all machines matching the request,
+// grouped by size, partition or image. Only the provisioning events which are still retained in the event containers are considered.
+func MachineProvisioningStatistics(ds datastore.Store, req *v1.MachineProvisioningStatisticsRequest) ([]v1.MachineProvisioningStatistic, error) {
+	group, err := provisioningStatisticsGroup(req.GroupBy)
+	if err != nil {
+		return nil, err
+	}
+
+	query := datastore.MachineSearchQuery{
+		PartitionID:       req.PartitionID,
+		SizeID:            req.SizeID,
+		AllocationImageID: req.ImageID,
+	}
+
+	var ms metal.Machines
+	err = ds.SearchMachines(&query, &ms)
+	if err != nil {
+		return nil, err
+	}
+
+	ecs, err := ds.ListProvisioningEventContainers()
+	if err != nil {
+		return nil, fmt.Errorf("unable to fetch provisioning event containers: %w", err)
+	}
+	ecsByID := ecs.ByID()
+
+	type key struct {
+		group      string
+		transition metal.ProvisioningTransition
+	}
+	durations := map[key][]time.Duration{}
+
+	for _, m := range ms {
+		ec, ok := ecsByID[m.ID]
+		if !ok {
+			continue
+		}
+
+		for _, d := range fsm.ProvisioningTimeline(&ec).Durations() {
+			k := key{group: group(&m), transition: d.ProvisioningTransition}
+			durations[k] = append(durations[k], d.Duration)
+		}
+	}
+
+	res := []v1.MachineProvisioningStatistic{}
+	for k, d := range durations {
+		res = append(res, v1.NewMachineProvisioningStatistic(k.group, metal.NewProvisioningDurationStatistic(k.transition, d)))
+	}
+
+	order := map[metal.ProvisioningTransition]int{}
+	for i, tr := range metal.ProvisioningTransitions {
+		order[tr] = i
+	}
+	slices.SortFunc(res, func(a, b v1.MachineProvisioningStatistic) int {
+		if c := cmp.Compare(a.Group, b.Group); c != 0 {
+			return c
+		}
+		return cmp.Compare(
+			order[metal.ProvisioningTransition{From: metal.ProvisioningEventType(a.From), To: metal.ProvisioningEventType(a.To)}],
+			order[metal.ProvisioningTransition{From: metal.ProvisioningEventType(b.From), To: metal.ProvisioningEventType(b.To)}],
+		)
+	})
+
+	return res, nil
+}
+
+// provisioningStatisticsGroup returns the function which determines the group of a machine in the provisioning statistics.
+func provisioningStatisticsGroup(groupBy *string) (func(m *metal.Machine) string, error) {
+	switch g := pointer.SafeDerefOrDefault(groupBy, "size"); g {
+	case "size":
+		return func(m *metal.Machine) string { return m.SizeID }, nil
+	case "partition":
+		return func(m *metal.Machine) string { return m.PartitionID }, nil
+	case "image":
+		return func(m *metal.Machine) string {
+			if m.Allocation == nil {
+				return ""
+			}
+			return m.Allocation.ImageID
+		}, nil
+	default:
+		return nil, fmt.Errorf("unable to group by %q, must be one of size, partition or image", g)
+	}
+}
+
 func (r *machineResource) findIPMIMachines(request *restful.Request, response *restful.Response) {
 	var requestPayload datastore.MachineSearchQuery
 	err := request.ReadEntity(&requestPayload)
@@ -954,27 +1605,69 @@ func (r *machineResource) ipmiReport(request *restful.Request, response *restful
 			continue
 		}
 		resp.Updated = append(resp.Updated, uuid)
+
+		if report.PowerMetric != nil {
+			err = r.ds.CreatePowerSample(metal.NewPowerSample(&newMachine, oldMachine.IPMI.LastUpdated, newMachine.IPMI.LastUpdated))
+			if err != nil {
+				logger.Error("could not store power sample", "id", uuid, "err", err)
+			}
+		}
+	}
+
+	r.send(request, response, http.StatusOK, resp)
+}
+
+func updateFru(m *metal.Machine, fru *v1.MachineFru) {
+	if fru == nil {
+		return
+	}
+
+	m.IPMI.Fru.ChassisPartSerial = pointer.SafeDerefOrDefault(fru.ChassisPartSerial, m.IPMI.Fru.ChassisPartSerial)
+	m.IPMI.Fru.ChassisPartNumber = pointer.SafeDerefOrDefault(fru.ChassisPartNumber, m.IPMI.Fru.ChassisPartNumber)
+	m.IPMI.Fru.BoardMfg = pointer.SafeDerefOrDefault(fru.BoardMfg, m.IPMI.Fru.BoardMfg)
+	m.IPMI.Fru.BoardMfgSerial = pointer.SafeDerefOrDefault(fru.BoardMfgSerial, m.IPMI.Fru.BoardMfgSerial)
+	m.IPMI.Fru.BoardPartNumber = pointer.SafeDerefOrDefault(fru.BoardPartNumber, m.IPMI.Fru.BoardPartNumber)
+	m.IPMI.Fru.ProductManufacturer = pointer.SafeDerefOrDefault(fru.ProductManufacturer, m.IPMI.Fru.ProductManufacturer)
+	m.IPMI.Fru.ProductSerial = pointer.SafeDerefOrDefault(fru.ProductSerial, m.IPMI.Fru.ProductSerial)
+	m.IPMI.Fru.ProductPartNumber = pointer.SafeDerefOrDefault(fru.ProductPartNumber, m.IPMI.Fru.ProductPartNumber)
+}
+
+func (r *machineResource) allocateMachine(request *restful.Request, response *restful.Response) {
+	var requestPayload v1.MachineAllocateRequest
+	err := request.ReadEntity(&requestPayload)
+	if err != nil {
+		r.sendError(request, response, httperrors.BadRequest(err))
+		return
+	}
+
+	user, err := r.userGetter.User(request.Request)
+	if err != nil {
+		r.sendError(request, response, defaultError(err))
+		return
+	}
+
+	spec, err := createMachineAllocationSpec(r.ds, requestPayload, nil, user)
+	if err != nil {
+		r.sendError(request, response, httperrors.BadRequest(err))
+		return
+	}
+
+	m, err := allocateMachine(request.Request.Context(), r.logger(request), r.ds, r.ipamer, spec, r.mdc, r.actor, r.Publisher)
+	if err != nil {
+		r.sendQuotaOrDefaultError(request, response, err)
+		return
 	}
 
-	r.send(request, response, http.StatusOK, resp)
-}
-
-func updateFru(m *metal.Machine, fru *v1.MachineFru) {
-	if fru == nil {
+	resp, err := makeMachineResponse(m, r.ds)
+	if err != nil {
+		r.sendError(request, response, defaultError(err))
 		return
 	}
 
-	m.IPMI.Fru.ChassisPartSerial = pointer.SafeDerefOrDefault(fru.ChassisPartSerial, m.IPMI.Fru.ChassisPartSerial)
-	m.IPMI.Fru.ChassisPartNumber = pointer.SafeDerefOrDefault(fru.ChassisPartNumber, m.IPMI.Fru.ChassisPartNumber)
-	m.IPMI.Fru.BoardMfg = pointer.SafeDerefOrDefault(fru.BoardMfg, m.IPMI.Fru.BoardMfg)
-	m.IPMI.Fru.BoardMfgSerial = pointer.SafeDerefOrDefault(fru.BoardMfgSerial, m.IPMI.Fru.BoardMfgSerial)
-	m.IPMI.Fru.BoardPartNumber = pointer.SafeDerefOrDefault(fru.BoardPartNumber, m.IPMI.Fru.BoardPartNumber)
-	m.IPMI.Fru.ProductManufacturer = pointer.SafeDerefOrDefault(fru.ProductManufacturer, m.IPMI.Fru.ProductManufacturer)
-	m.IPMI.Fru.ProductSerial = pointer.SafeDerefOrDefault(fru.ProductSerial, m.IPMI.Fru.ProductSerial)
-	m.IPMI.Fru.ProductPartNumber = pointer.SafeDerefOrDefault(fru.ProductPartNumber, m.IPMI.Fru.ProductPartNumber)
+	r.send(request, response, http.StatusOK, resp)
 }
 
-func (r *machineResource) allocateMachine(request *restful.Request, response *restful.Response) {
+func (r *machineResource) explainMachineAllocation(request *restful.Request, response *restful.Response) {
 	var requestPayload v1.MachineAllocateRequest
 	err := request.ReadEntity(&requestPayload)
 	if err != nil {
@@ -994,22 +1687,113 @@ func (r *machineResource) allocateMachine(request *restful.Request, response *re
 		return
 	}
 
-	m, err := allocateMachine(request.Request.Context(), r.logger(request), r.ds, r.ipamer, spec, r.mdc, r.actor, r.Publisher)
+	resp, err := explainMachineAllocation(request.Request.Context(), r.logger(request), r.ds, spec, r.mdc)
 	if err != nil {
 		r.sendError(request, response, defaultError(err))
 		return
 	}
 
-	resp, err := makeMachineResponse(m, r.ds)
+	r.send(request, response, http.StatusOK, resp)
+}
+
+func (r *machineResource) allocateMachines(request *restful.Request, response *restful.Response) {
+	var requestPayload v1.MachineBulkAllocateRequest
+	err := request.ReadEntity(&requestPayload)
+	if err != nil {
+		r.sendError(request, response, httperrors.BadRequest(err))
+		return
+	}
+
+	machineRequests, err := bulkAllocateRequests(requestPayload, r.bulkAllocationLimit)
+	if err != nil {
+		r.sendError(request, response, httperrors.BadRequest(err))
+		return
+	}
+
+	user, err := r.userGetter.User(request.Request)
 	if err != nil {
 		r.sendError(request, response, defaultError(err))
 		return
 	}
 
+	var specs []*machineAllocationSpec
+	for _, machineRequest := range machineRequests {
+		spec, err := createMachineAllocationSpec(r.ds, machineRequest, nil, user)
+		if err != nil {
+			r.sendError(request, response, httperrors.BadRequest(err))
+			return
+		}
+		specs = append(specs, spec)
+	}
+
+	ms, err := allocateMachines(request.Request.Context(), r.logger(request), r.ds, r.ipamer, specs, r.mdc, r.actor, r.Publisher)
+	if err != nil {
+		r.sendQuotaOrDefaultError(request, response, err)
+		return
+	}
+
+	resp := v1.MachineBulkAllocateResponse{
+		Allocations: []v1.MachineBulkAllocation{},
+	}
+	for i := range ms {
+		m := ms[i]
+		machineResponse, err := makeMachineResponse(&m, r.ds)
+		if err != nil {
+			r.sendError(request, response, defaultError(err))
+			return
+		}
+		resp.Allocations = append(resp.Allocations, v1.MachineBulkAllocation{
+			AllocationUUID: m.Allocation.UUID,
+			Machine:        *machineResponse,
+		})
+	}
+
 	r.send(request, response, http.StatusOK, resp)
 }
 
-func createMachineAllocationSpec(ds *datastore.RethinkStore, machineRequest v1.MachineAllocateRequest, firewallRequest *v1.FirewallAllocateRequest, user *security.User) (*machineAllocationSpec, error) {
+// bulkAllocateRequests returns the single allocate requests contained in a bulk allocate request,
+// at most limit machines can be requested at once.
+func bulkAllocateRequests(bulkRequest v1.MachineBulkAllocateRequest, limit uint) ([]v1.MachineAllocateRequest, error) {
+	if bulkRequest.Count == nil {
+		if bulkRequest.Template != nil {
+			return nil, errors.New("a template can only be used in combination with a count")
+		}
+		if len(bulkRequest.Requests) == 0 {
+			return nil, errors.New("either count and template or requests must be specified")
+		}
+		if uint(len(bulkRequest.Requests)) > limit {
+			return nil, fmt.Errorf("at most %d machines can be allocated at once", limit)
+		}
+		return bulkRequest.Requests, nil
+	}
+
+	if len(bulkRequest.Requests) > 0 {
+		return nil, errors.New("count and requests are mutually exclusive")
+	}
+	if bulkRequest.Template == nil {
+		return nil, errors.New("a template must be specified when a count is given")
+	}
+
+	count := *bulkRequest.Count
+	if count <= 0 {
+		return nil, errors.New("count must be greater than zero")
+	}
+	if uint(count) > limit {
+		return nil, fmt.Errorf("at most %d machines can be allocated at once", limit)
+	}
+	if count > 1 && bulkRequest.Template.UUID != nil {
+		return nil, errors.New("a machine uuid cannot be used in a template for more than one machine")
+	}
+
+	var requests []v1.MachineAllocateRequest
+	for range count {
+		requests = append(requests, *bulkRequest.Template)
+	}
+
+	return requests, nil
+}
+
+func createMachineAllocationSpec(ds datastore.Store, machineRequest v1.MachineAllocateRequest, firewallRequest *v1.FirewallAllocateRequest, user *security.User) (*machineAllocationSpec, error) {
 	var uuid string
 	if machineRequest.UUID != nil {
 		uuid = *machineRequest.UUID
@@ -1051,57 +1835,9 @@ func createMachineAllocationSpec(ds *datastore.RethinkStore, machineRequest v1.M
 	if firewallRequest != nil {
 		role = metal.RoleFirewall
 
-		if firewallRequest.FirewallRules != nil {
-			for _, ruleSpec := range firewallRequest.FirewallRules.Egress {
-
-				if ruleSpec.Protocol == "" {
-					ruleSpec.Protocol = string(metal.ProtocolTCP)
-				}
-
-				protocol, err := metal.ProtocolFromString(ruleSpec.Protocol)
-				if err != nil {
-					return nil, err
-				}
-
-				rule := metal.EgressRule{
-					Protocol: protocol,
-					Ports:    ruleSpec.Ports,
-					To:       ruleSpec.To,
-					Comment:  ruleSpec.Comment,
-				}
-
-				if err := rule.Validate(); err != nil {
-					return nil, err
-				}
-
-				egress = append(egress, rule)
-			}
-
-			for _, ruleSpec := range firewallRequest.FirewallRules.Ingress {
-
-				if ruleSpec.Protocol == "" {
-					ruleSpec.Protocol = string(metal.ProtocolTCP)
-				}
-
-				protocol, err := metal.ProtocolFromString(ruleSpec.Protocol)
-				if err != nil {
-					return nil, err
-				}
-
-				rule := metal.IngressRule{
-					Protocol: protocol,
-					Ports:    ruleSpec.Ports,
-					To:       ruleSpec.To,
-					From:     ruleSpec.From,
-					Comment:  ruleSpec.Comment,
-				}
-
-				if err := rule.Validate(); err != nil {
-					return nil, err
-				}
-
-				ingress = append(ingress, rule)
-			}
+		egress, ingress, err = toFirewallRules(firewallRequest.FirewallRules)
+		if err != nil {
+			return nil, err
 		}
 	}
 
@@ -1175,6 +1911,26 @@ func createMachineAllocationSpec(ds *datastore.RethinkStore, machineRequest v1.M
 		return nil, err
 	}
 
+	placement := metal.Placement{
+		Tags:     machineRequest.PlacementTags,
+		Switches: machineRequest.PlacementSwitches,
+	}
+	if machineRequest.PlacementStrategy != nil {
+		placement.Strategy = metal.PlacementStrategyType(*machineRequest.PlacementStrategy)
+	}
+	if machineRequest.PlacementSpreadLevel != nil {
+		placement.SpreadLevel = metal.LocationLevel(*machineRequest.PlacementSpreadLevel)
+	}
+	if err := placement.Validate(); err != nil {
+		return nil, err
+	}
+
+	if machineRequest.LeaseDuration != nil {
+		if _, err := metal.NewMachineLease(*machineRequest.LeaseDuration, time.Now()); err != nil {
+			return nil, err
+		}
+	}
+
 	return &machineAllocationSpec{
 		Creator:            user.EMail,
 		UUID:               uuid,
@@ -1193,15 +1949,133 @@ func createMachineAllocationSpec(ds *datastore.RethinkStore, machineRequest v1.M
 		IPs:                machineRequest.IPs,
 		Role:               role,
 		FilesystemLayoutID: machineRequest.FilesystemLayoutID,
-		PlacementTags:      machineRequest.PlacementTags,
+		Placement:          placement,
 		EgressRules:        egress,
 		IngressRules:       ingress,
 		DNSServers:         dnsServers,
 		NTPServers:         ntpServers,
+		LeaseDuration:      machineRequest.LeaseDuration,
 	}, nil
 }
 
-func allocateMachine(ctx context.Context, logger *slog.Logger, ds *datastore.RethinkStore, ipamer ipam.IPAMer, allocationSpec *machineAllocationSpec, mdc mdm.Client, actor *asyncActor, publisher bus.Publisher) (*metal.Machine, error) {
+func allocateMachine(ctx context.Context, logger *slog.Logger, ds datastore.Store, ipamer ipam.IPAMer, allocationSpec *machineAllocationSpec, mdc mdm.Client, actor *asyncActor, publisher bus.Publisher) (*metal.Machine, error) {
+	machine, err := reserveMachine(ctx, logger, ds, ipamer, allocationSpec, mdc, actor)
+	if err != nil {
+		return nil, err
+	}
+
+	publishAllocationEvent(logger, publisher, machine)
+
+	return machine, nil
+}
+
+// allocateMachines allocates a machine for every given allocation spec. If one of the allocations fails,
+// the machines which were already allocated are freed again, such that either all or none of the machines are allocated.
+func allocateMachines(ctx context.Context, logger *slog.Logger, ds datastore.Store, ipamer ipam.IPAMer, allocationSpecs []*machineAllocationSpec, mdc mdm.Client, actor *asyncActor, publisher bus.Publisher) (metal.Machines, error) {
+	var machines metal.Machines
+
+	for i, allocationSpec := range allocationSpecs {
+		machine, err := reserveMachine(ctx, logger, ds, ipamer, allocationSpec, mdc, actor)
+		if err != nil {
+			for _, m := range machines {
+				// the metal-hammer may already have picked up the allocation without an allocation event,
+				// so the machines have to run through the regular free path
+				rollbackError := actor.freeMachine(ctx, publisher, &m, nil, logger)
+				if rollbackError != nil {
+					logger.Error("cannot free machine during rollback of bulk allocation", "machineID", m.ID, "error", rollbackError)
+				}
+			}
+			return nil, fmt.Errorf("allocation %d of %d failed, all allocations were rolled back: %w", i+1, len(allocationSpecs), err)
+		}
+
+		machines = append(machines, *machine)
+	}
+
+	for i := range machines {
+		publishAllocationEvent(logger, publisher, &machines[i])
+	}
+
+	return machines, nil
+}
+
+// explainMachineAllocation evaluates the same checks and machine candidate election as reserveMachine, but without any side effects.
+// in contrast to the allocation, all checks are evaluated even if one of them fails.
+func explainMachineAllocation(ctx context.Context, logger *slog.Logger, ds datastore.Store, allocationSpec *machineAllocationSpec, mdc mdm.Client) (*v1.MachineAllocationExplainResponse, error) {
+	resp := &v1.MachineAllocationExplainResponse{
+		Checks:     []v1.MachineAllocationCheck{},
+		Stages:     []v1.MachineAllocationStage{},
+		Candidates: []string{},
+	}
+
+	passed := true
+	check := func(name string, err error) bool {
+		c := v1.MachineAllocationCheck{Name: name, Passed: err == nil}
+		if err != nil {
+			c.Message = new(err.Error())
+			passed = false
+		}
+		resp.Checks = append(resp.Checks, c)
+		return err == nil
+	}
+
+	if !check("allocation-spec", validateAllocationSpec(allocationSpec)) {
+		return resp, nil
+	}
+
+	check("size-image-constraint", isSizeAndImageCompatible(ds, *allocationSpec.Size, *allocationSpec.Image))
+
+	p, err := mdc.Project().Get(ctx, &mdmv1.ProjectGetRequest{Id: allocationSpec.ProjectID})
+	if err == nil {
+		err = checkMachineQuotas(ds, p.GetProject(), allocationSpec.Role, allocationSpec.Size.ID)
+	}
+	check("project-quota", err)
+
+	_, err = allocationFilesystemLayout(ds, allocationSpec)
+	check("filesystem-layout", err)
+
+	if allocationSpec.Machine != nil {
+		machine, err := findMachineCandidate(ctx, ds, allocationSpec)
+		if check("machine", err) {
+			resp.Candidates = append(resp.Candidates, machine.ID)
+		}
+		resp.Possible = passed
+		return resp, nil
+	}
+
+	_, err = ds.FindPartition(allocationSpec.PartitionID)
+	if !check("partition", err) {
+		return resp, nil
+	}
+
+	stages, candidates, err := datastore.ExplainWaitingMachine(logger, ds, allocationSpec.ProjectID, allocationSpec.PartitionID, *allocationSpec.Size, allocationSpec.Placement, allocationSpec.Role)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, stage := range stages {
+		s := v1.MachineAllocationStage{
+			Name:      stage.Name,
+			Remaining: stage.Remaining,
+			Dropped:   []v1.DroppedMachineCandidate{},
+		}
+		for _, d := range stage.Dropped {
+			s.Dropped = append(s.Dropped, v1.DroppedMachineCandidate{MachineID: d.MachineID, Reason: d.Reason})
+		}
+		resp.Stages = append(resp.Stages, s)
+	}
+
+	for _, m := range candidates {
+		resp.Candidates = append(resp.Candidates, m.ID)
+	}
+
+	resp.Possible = passed && len(candidates) > 0
+
+	return resp, nil
+}
+
+// reserveMachine allocates a machine candidate for the given allocation spec including its networks and ips.
+// in case of an error, all resources reserved for the allocation are released again.
+func reserveMachine(ctx context.Context, logger *slog.Logger, ds datastore.Store, ipamer ipam.IPAMer, allocationSpec *machineAllocationSpec, mdc mdm.Client, actor *asyncActor) (*metal.Machine, error) {
 	err := validateAllocationSpec(allocationSpec)
 	if err != nil {
 		return nil, err
@@ -1218,35 +2092,19 @@ func allocateMachine(ctx context.Context, logger *slog.Logger, ds *datastore.Ret
 		return nil, err
 	}
 
-	// Check if more machine would be allocated than project quota permits
-	if p.GetProject() != nil && p.GetProject().GetQuotas() != nil && p.GetProject().GetQuotas().GetMachine() != nil {
-		mq := p.GetProject().GetQuotas().GetMachine()
-		if mq.GetQuota() != nil {
-			maxMachines := mq.GetQuota().GetValue()
-			var actualMachines metal.Machines
-			err := ds.SearchMachines(&datastore.MachineSearchQuery{AllocationProject: &projectID, AllocationRole: &metal.RoleFirewall}, &actualMachines)
-			if err != nil {
-				return nil, err
-			}
-			if len(actualMachines) >= int(maxMachines) {
-				return nil, fmt.Errorf("project quota for machines reached max:%d", maxMachines)
-			}
-		}
+	err = checkMachineQuotas(ds, p.GetProject(), allocationSpec.Role, allocationSpec.Size.ID)
+	if err != nil {
+		return nil, err
 	}
 
-	var fsl *metal.FilesystemLayout
-	if allocationSpec.FilesystemLayoutID == nil {
-		fsls, err := ds.ListFilesystemLayouts()
-		if err != nil {
-			return nil, err
-		}
+	fsl, err := allocationFilesystemLayout(ds, allocationSpec)
+	if err != nil {
+		return nil, err
+	}
 
-		fsl, err = fsls.From(allocationSpec.Size.ID, allocationSpec.Image.ID)
-		if err != nil {
-			return nil, err
-		}
-	} else {
-		fsl, err = ds.FindFilesystemLayout(*allocationSpec.FilesystemLayoutID)
+	var lease *metal.MachineLease
+	if allocationSpec.LeaseDuration != nil {
+		lease, err = metal.NewMachineLease(*allocationSpec.LeaseDuration, time.Now())
 		if err != nil {
 			return nil, err
 		}
@@ -1285,6 +2143,7 @@ func allocateMachine(ctx context.Context, logger *slog.Logger, ds *datastore.Ret
 		UUID:            uuid.New().String(),
 		DNSServers:      allocationSpec.DNSServers,
 		NTPServers:      allocationSpec.NTPServers,
+		Lease:           lease,
 	}
 	rollbackOnError := func(err error) error {
 		if err != nil {
@@ -1321,7 +2180,7 @@ func allocateMachine(ctx context.Context, logger *slog.Logger, ds *datastore.Ret
 	if err != nil {
 		return nil, rollbackOnError(fmt.Errorf("unable to gather networks:%w", err))
 	}
-	err = makeNetworks(ctx, ds, ipamer, allocationSpec, networks, alloc)
+	err = makeNetworks(ctx, ds, ipamer, p.GetProject(), allocationSpec, networks, alloc)
 	if err != nil {
 		return nil, rollbackOnError(fmt.Errorf("unable to make networks:%w", err))
 	}
@@ -1345,15 +2204,46 @@ func allocateMachine(ctx context.Context, logger *slog.Logger, ds *datastore.Ret
 		return nil, rollbackOnError(fmt.Errorf("error when allocating machine %q, %w", machine.ID, err))
 	}
 
+	err = recheckMachineQuotas(ds, p.GetProject(), machine)
+	if err != nil {
+		// the allocation was persisted already, so the allocated machine has to be rolled back
+		machineCandidate = machine
+		return nil, rollbackOnError(err)
+	}
+
+	if allocationSpec.Role == metal.RoleFirewall {
+		err = createInitialFirewallRuleRevision(ds, machine)
+		if err != nil {
+			machineCandidate = machine
+			return nil, rollbackOnError(fmt.Errorf("unable to store firewall rules of firewall %q: %w", machine.ID, err))
+		}
+	}
+
+	return machine, nil
+}
+
+func publishAllocationEvent(logger *slog.Logger, publisher bus.Publisher, machine *metal.Machine) {
 	// TODO: can be removed after metal-core refactoring
-	err = publisher.Publish(metal.TopicAllocation.Name, &metal.AllocationEvent{MachineID: machine.ID})
+	err := publisher.Publish(metal.TopicAllocation.Name, &metal.AllocationEvent{MachineID: machine.ID})
 	if err != nil {
 		logger.Error("failed to publish machine allocation event, fallback should trigger on metal-hammer", "topic", metal.TopicAllocation.Name, "machineID", machine.ID, "error", err)
 	} else {
 		logger.Debug("published machine allocation event", "topic", metal.TopicAllocation.Name, "machineID", machine.ID)
 	}
+}
 
-	return machine, nil
+// allocationFilesystemLayout returns the requested filesystem layout or the one matching the size and image of the allocation.
+func allocationFilesystemLayout(ds datastore.Store, allocationSpec *machineAllocationSpec) (*metal.FilesystemLayout, error) {
+	if allocationSpec.FilesystemLayoutID != nil {
+		return ds.FindFilesystemLayout(*allocationSpec.FilesystemLayoutID)
+	}
+
+	fsls, err := ds.ListFilesystemLayouts()
+	if err != nil {
+		return nil, err
+	}
+
+	return fsls.From(allocationSpec.Size.ID, allocationSpec.Image.ID)
 }
 
 func validateAllocationSpec(allocationSpec *machineAllocationSpec) error {
@@ -1396,7 +2286,7 @@ func validateAllocationSpec(allocationSpec *machineAllocationSpec) error {
 	return nil
 }
 
-func findMachineCandidate(ctx context.Context, ds *datastore.RethinkStore, allocationSpec *machineAllocationSpec) (*metal.Machine, error) {
+func findMachineCandidate(ctx context.Context, ds datastore.Store, allocationSpec *machineAllocationSpec) (*metal.Machine, error) {
 	var err error
 	var machine *metal.Machine
 	if allocationSpec.Machine == nil {
@@ -1422,7 +2312,7 @@ func findMachineCandidate(ctx context.Context, ds *datastore.RethinkStore, alloc
 	return machine, err
 }
 
-func findWaitingMachine(ctx context.Context, ds *datastore.RethinkStore, allocationSpec *machineAllocationSpec) (*metal.Machine, error) {
+func findWaitingMachine(ctx context.Context, ds datastore.Store, allocationSpec *machineAllocationSpec) (*metal.Machine, error) {
 	size, err := ds.FindSize(allocationSpec.Size.ID)
 	if err != nil {
 		return nil, fmt.Errorf("size cannot be found: %w", err)
@@ -1432,7 +2322,7 @@ func findWaitingMachine(ctx context.Context, ds *datastore.RethinkStore, allocat
 		return nil, fmt.Errorf("partition cannot be found: %w", err)
 	}
 
-	machine, err := ds.FindWaitingMachine(ctx, allocationSpec.ProjectID, partition.ID, *size, allocationSpec.PlacementTags, allocationSpec.Role)
+	machine, err := ds.FindWaitingMachine(ctx, allocationSpec.ProjectID, partition.ID, *size, allocationSpec.Placement, allocationSpec.Role)
 	if err != nil {
 		return nil, err
 	}
@@ -1442,12 +2332,12 @@ func findWaitingMachine(ctx context.Context, ds *datastore.RethinkStore, allocat
 // makeNetworks creates network entities and ip addresses as specified in the allocation network map.
 // created networks are added to the machine allocation directly after their creation. This way, the rollback mechanism
 // is enabled to clean up networks that were already created.
-func makeNetworks(ctx context.Context, ds *datastore.RethinkStore, ipamer ipam.IPAMer, allocationSpec *machineAllocationSpec, networks allocationNetworkMap, alloc *metal.MachineAllocation) error {
+func makeNetworks(ctx context.Context, ds datastore.Store, ipamer ipam.IPAMer, p *mdmv1.Project, allocationSpec *machineAllocationSpec, networks allocationNetworkMap, alloc *metal.MachineAllocation) error {
 	for _, n := range networks {
 		if n == nil || n.network == nil {
 			continue
 		}
-		machineNetwork, err := makeMachineNetwork(ctx, ds, ipamer, allocationSpec, n)
+		machineNetwork, err := makeMachineNetwork(ctx, ds, ipamer, p, allocationSpec, n)
 		if err != nil {
 			return err
 		}
@@ -1466,7 +2356,7 @@ func makeNetworks(ctx context.Context, ds *datastore.RethinkStore, ipamer ipam.I
 	return nil
 }
 
-func gatherNetworks(ds *datastore.RethinkStore, allocationSpec *machineAllocationSpec) (allocationNetworkMap, error) {
+func gatherNetworks(ds datastore.Store, allocationSpec *machineAllocationSpec) (allocationNetworkMap, error) {
 	partition, err := ds.FindPartition(allocationSpec.PartitionID)
 	if err != nil {
 		return nil, fmt.Errorf("partition cannot be found: %w", err)
@@ -1501,7 +2391,7 @@ func gatherNetworks(ds *datastore.RethinkStore, allocationSpec *machineAllocatio
 	return result, nil
 }
 
-func gatherNetworksFromSpec(ds *datastore.RethinkStore, allocationSpec *machineAllocationSpec, partition *metal.Partition, privateSuperNetworks metal.Networks) (allocationNetworkMap, error) {
+func gatherNetworksFromSpec(ds datastore.Store, allocationSpec *machineAllocationSpec, partition *metal.Partition, privateSuperNetworks metal.Networks) (allocationNetworkMap, error) {
 	var partitionPrivateSuperNetwork *metal.Network
 	for i := range privateSuperNetworks {
 		psn := privateSuperNetworks[i]
@@ -1641,7 +2531,7 @@ func gatherNetworksFromSpec(ds *datastore.RethinkStore, allocationSpec *machineA
 	return specNetworks, nil
 }
 
-func gatherUnderlayNetwork(ds *datastore.RethinkStore, partition *metal.Partition) (*allocationNetwork, error) {
+func gatherUnderlayNetwork(ds datastore.Store, partition *metal.Partition) (*allocationNetwork, error) {
 	boolTrue := true
 	var underlays metal.Networks
 	err := ds.SearchNetworks(&datastore.NetworkSearchQuery{PartitionID: &partition.ID, Underlay: &boolTrue}, &underlays)
@@ -1663,32 +2553,21 @@ func gatherUnderlayNetwork(ds *datastore.RethinkStore, partition *metal.Partitio
 	}, nil
 }
 
-func makeMachineNetwork(ctx context.Context, ds *datastore.RethinkStore, ipamer ipam.IPAMer, allocationSpec *machineAllocationSpec, n *allocationNetwork) (*metal.MachineNetwork, error) {
+func makeMachineNetwork(ctx context.Context, ds datastore.Store, ipamer ipam.IPAMer, p *mdmv1.Project, allocationSpec *machineAllocationSpec, n *allocationNetwork) (*metal.MachineNetwork, error) {
 	if n.auto {
 		if len(n.network.Prefixes) == 0 {
 			return nil, fmt.Errorf("given network %s does not have prefixes configured", n.network.ID)
 		}
+		var created metal.IPs
 		for _, af := range n.network.Prefixes.AddressFamilies() {
-			ipAddress, ipParentCidr, err := allocateRandomIP(ctx, n.network, ipamer, &af)
-			if err != nil {
-				return nil, fmt.Errorf("unable to allocate an ip in network: %s %w", n.network.ID, err)
-			}
-			ip := &metal.IP{
-				IPAddress:        ipAddress,
-				ParentPrefixCidr: ipParentCidr,
-				Name:             allocationSpec.Name,
-				Description:      "autoassigned",
-				NetworkID:        n.network.ID,
-				Type:             metal.Ephemeral,
-				ProjectID:        allocationSpec.ProjectID,
-			}
-			ip.AddMachineId(allocationSpec.UUID)
-			err = ds.CreateIP(ip)
+			ip, err := makeMachineIP(ctx, ds, ipamer, p, allocationSpec, n.network, af)
 			if err != nil {
-				return nil, err
+				// the ips of the other address families are not part of the allocation yet and would not be released by its rollback
+				return nil, errors.Join(err, releaseMachineIPs(ctx, ds, ipamer, created))
 			}
-			n.ips = append(n.ips, *ip)
+			created = append(created, *ip)
 		}
+		n.ips = append(n.ips, created...)
 	}
 
 	// from the makeNetworks call, a lot of ips might be set in this network
@@ -1724,6 +2603,58 @@ func makeMachineNetwork(ctx context.Context, ds *datastore.RethinkStore, ipamer
 	return &machineNetwork, nil
 }
 
+// makeMachineIP acquires an ephemeral ip of the given address family in the network for the machine within the ip quota of the project.
+func makeMachineIP(ctx context.Context, ds datastore.Store, ipamer ipam.IPAMer, p *mdmv1.Project, allocationSpec *machineAllocationSpec, nw *metal.Network, af metal.AddressFamily) (*metal.IP, error) {
+	err := checkIPQuota(ds, p)
+	if err != nil {
+		return nil, err
+	}
+
+	ipAddress, ipParentCidr, err := allocateRandomIP(ctx, nw, ipamer, &af)
+	if err != nil {
+		return nil, fmt.Errorf("unable to allocate an ip in network: %s %w", nw.ID, err)
+	}
+	ip := &metal.IP{
+		IPAddress:        ipAddress,
+		ParentPrefixCidr: ipParentCidr,
+		Name:             allocationSpec.Name,
+		Description:      "autoassigned",
+		NetworkID:        nw.ID,
+		Type:             metal.Ephemeral,
+		ProjectID:        allocationSpec.ProjectID,
+	}
+	ip.AddMachineId(allocationSpec.UUID)
+	err = ds.CreateIP(ip)
+	if err != nil {
+		return nil, errors.Join(err, ipamer.ReleaseIP(ctx, *ip))
+	}
+
+	err = recheckIPQuota(ds, p, ip.IPAddress)
+	if err != nil {
+		return nil, errors.Join(err, releaseMachineIPs(ctx, ds, ipamer, metal.IPs{*ip}))
+	}
+
+	return ip, nil
+}
+
+// releaseMachineIPs deletes the given ips which were acquired for a machine allocation that failed and releases them in the ipam.
+func releaseMachineIPs(ctx context.Context, ds datastore.Store, ipamer ipam.IPAMer, ips metal.IPs) error {
+	var errs []error
+	for i := range ips {
+		ip := ips[i]
+		err := ds.DeleteIP(&ip)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("cannot delete ip %q: %w", ip.IPAddress, err))
+			continue
+		}
+		err = ipamer.ReleaseIP(ctx, ip)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("cannot release ip %q: %w", ip.IPAddress, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
 // makeMachineTags constructs the tags of the machine.
 // following tags are added in the following precedence (from lowest to highest in case of duplication):
 // - user given tags (from allocation spec)
@@ -1799,7 +2730,7 @@ func (r *machineResource) freeMachine(request *restful.Request, response *restfu
 
 	logger := r.logger(request)
 
-	err = publishMachineCmd(logger, m, r.Publisher, metal.ChassisIdentifyLEDOffCmd)
+	err = publishMachineCmd(logger, r.ds, m, r.Publisher, metal.ChassisIdentifyLEDOffCmd)
 	if err != nil {
 		logger.Error("unable to publish machine command", "command", string(metal.ChassisIdentifyLEDOffCmd), "machineID", m.ID, "error", err)
 	}
@@ -1972,7 +2903,7 @@ func (r *machineResource) reinstallMachine(request *restful.Request, response *r
 				return
 			}
 
-			err = publishMachineCmd(logger, m, r.Publisher, metal.MachineReinstallCmd)
+			err = publishMachineCmd(logger, r.ds, m, r.Publisher, metal.MachineReinstallCmd)
 			if err != nil {
 				logger.Error("unable to publish machine command", "command", string(metal.MachineReinstallCmd), "machineID", m.ID, "error", err)
 			}
@@ -1986,7 +2917,7 @@ func (r *machineResource) reinstallMachine(request *restful.Request, response *r
 	r.sendError(request, response, httperrors.BadRequest(errors.New("machine either locked, not allocated yet or invalid image ID specified")))
 }
 
-func deleteVRFSwitches(ds *datastore.RethinkStore, m *metal.Machine, logger *slog.Logger) error {
+func deleteVRFSwitches(ds datastore.Store, m *metal.Machine, logger *slog.Logger) error {
 	logger.Info("set VRF at switch", "machineID", m.ID)
 	err := retry.Do(
 		func() error {
@@ -2019,7 +2950,7 @@ func publishDeleteEvent(publisher bus.Publisher, m *metal.Machine, logger *slog.
 }
 
 // MachineLiveliness evaluates whether machines are still alive or if they have died
-func MachineLiveliness(ds *datastore.RethinkStore, logger *slog.Logger) error {
+func MachineLiveliness(ds datastore.Store, logger *slog.Logger) error {
 	logger.Info("machine liveliness was requested")
 
 	machines, err := ds.ListMachines()
@@ -2053,7 +2984,7 @@ func MachineLiveliness(ds *datastore.RethinkStore, logger *slog.Logger) error {
 	return nil
 }
 
-func evaluateMachineLiveliness(ds *datastore.RethinkStore, m metal.Machine) (metal.MachineLiveliness, error) {
+func evaluateMachineLiveliness(ds datastore.Store, m metal.Machine) (metal.MachineLiveliness, error) {
 	provisioningEvents, err := ds.FindProvisioningEventContainer(m.ID)
 	if err != nil {
 		// we have no provisioning events... we cannot tell
@@ -2085,7 +3016,7 @@ func evaluateMachineLiveliness(ds *datastore.RethinkStore, m metal.Machine) (met
 }
 
 // ResurrectMachines attempts to resurrect machines that are obviously dead
-func ResurrectMachines(ctx context.Context, ds *datastore.RethinkStore, publisher bus.Publisher, ep *bus.Endpoints, ipamer ipam.IPAMer, headscaleClient *headscale.HeadscaleClient, logger *slog.Logger) error {
+func ResurrectMachines(ctx context.Context, ds datastore.Store, publisher bus.Publisher, ep *bus.Endpoints, ipamer ipam.IPAMer, headscaleClient *headscale.HeadscaleClient, logger *slog.Logger) error {
 	logger.Info("machine resurrection was requested")
 
 	machines, err := ds.ListMachines()
@@ -2230,21 +3161,15 @@ func (r *machineResource) updateFirmware(request *restful.Request, response *res
 		return
 	}
 
-	evt := metal.MachineEvent{
-		Type: metal.COMMAND,
-		Cmd: &metal.MachineExecCommand{
-			Command:         metal.UpdateFirmwareCmd,
-			TargetMachineID: m.ID,
-			IPMI:            &m.IPMI,
-			FirmwareUpdate: &metal.FirmwareUpdate{
-				Kind: p.Kind,
-				URL:  downloadableURL,
-			},
+	err = publishMachineExecCmd(r.logger(request), r.ds, m.PartitionID, r.Publisher, &metal.MachineExecCommand{
+		Command:         metal.UpdateFirmwareCmd,
+		TargetMachineID: m.ID,
+		IPMI:            &m.IPMI,
+		FirmwareUpdate: &metal.FirmwareUpdate{
+			Kind: p.Kind,
+			URL:  downloadableURL,
 		},
-	}
-
-	r.logger(request).Info("publish event", "event", evt, "command", *evt.Cmd)
-	err = r.Publish(metal.TopicMachine.GetFQN(m.PartitionID), evt)
+	})
 	if err != nil {
 		r.sendError(request, response, httperrors.InternalServerError(err))
 		return
@@ -2307,16 +3232,9 @@ func (r *machineResource) machineCmd(ctx context.Context, cmd metal.MachineComma
 		}
 	}
 
-	if newMachine.IPMI.User == "" && r.ipmiSuperUser.IsEnabled() {
-		// when removing a machine from the database, the metal-bmc will loose the ability
-		// to manage the machine after it reported it back to API.
-		//
-		// to mitigate this scenario, we use the super user as a fallback.
-		newMachine.IPMI.User = r.ipmiSuperUser.User()
-		newMachine.IPMI.Password = r.ipmiSuperUser.Password()
-	}
+	withIPMISuperUserFallback(newMachine, r.ipmiSuperUser)
 
-	err = publishMachineCmd(logger, newMachine, r.Publisher, cmd)
+	err = publishMachineCmd(logger, r.ds, newMachine, r.Publisher, cmd)
 	if err != nil {
 		r.sendError(request, response, defaultError(err))
 		return
@@ -2331,39 +3249,89 @@ func (r *machineResource) machineCmd(ctx context.Context, cmd metal.MachineComma
 	r.send(request, response, http.StatusOK, resp)
 }
 
-func publishMachineCmd(logger *slog.Logger, m *metal.Machine, publisher bus.Publisher, cmd metal.MachineCommand) error {
+func withIPMISuperUserFallback(m *metal.Machine, ipmiSuperUser metal.MachineIPMISuperUser) {
+	if m.IPMI.User == "" && ipmiSuperUser.IsEnabled() {
+		// when removing a machine from the database, the metal-bmc will loose the ability
+		// to manage the machine after it reported it back to API.
+		//
+		// to mitigate this scenario, we use the super user as a fallback.
+		m.IPMI.User = ipmiSuperUser.User()
+		m.IPMI.Password = ipmiSuperUser.Password()
+	}
+}
+
+func publishMachineCmd(logger *slog.Logger, ds datastore.MachineCommandExecutionStore, m *metal.Machine, publisher bus.Publisher, cmd metal.MachineCommand) error {
+	return publishMachineExecCmd(logger, ds, m.PartitionID, publisher, &metal.MachineExecCommand{
+		Command:         cmd,
+		TargetMachineID: m.ID,
+		IPMI:            &m.IPMI,
+	})
+}
+
+// publishMachineExecCmd records the execution of the command before it is published, such that
+// the metal-bmc can report its status back with the id of the execution.
+func publishMachineExecCmd(logger *slog.Logger, ds datastore.MachineCommandExecutionStore, partitionID string, publisher bus.Publisher, cmd *metal.MachineExecCommand) error {
+	e := metal.NewMachineCommandExecution(cmd.TargetMachineID, cmd.Command, time.Now())
+	err := ds.CreateMachineCommandExecution(e)
+	if err != nil {
+		return err
+	}
+	cmd.ID = e.ID
+
 	evt := metal.MachineEvent{
 		Type: metal.COMMAND,
-		Cmd: &metal.MachineExecCommand{
-			Command:         cmd,
-			TargetMachineID: m.ID,
-			IPMI:            &m.IPMI,
-		},
+		Cmd:  cmd,
 	}
 
 	logger.Info("publish event", "event", evt, "command", *evt.Cmd)
-	err := publisher.Publish(metal.TopicMachine.GetFQN(m.PartitionID), evt)
+	err = publisher.Publish(metal.TopicMachine.GetFQN(partitionID), evt)
 	if err != nil {
+		failed := *e
+		_ = failed.Report(metal.MachineCommandStatusFailed, fmt.Sprintf("unable to publish command: %s", err), time.Now())
+		if updateErr := ds.UpdateMachineCommandExecution(e, &failed); updateErr != nil {
+			logger.Error("unable to update machine command status", "id", e.ID, "error", updateErr)
+		}
 		return err
 	}
 
 	return nil
 }
 
-func makeMachineResponse(m *metal.Machine, ds *datastore.RethinkStore) (*v1.MachineResponse, error) {
+func makeMachineResponse(m *metal.Machine, ds datastore.Store) (*v1.MachineResponse, error) {
 	s, p, i, ec, err := findMachineReferencedEntities(m, ds)
 	if err != nil {
 		return nil, err
 	}
-	return v1.NewMachineResponse(m, s, p, i, ec), nil
+	resp := v1.NewMachineResponse(m, s, p, i, ec)
+
+	if m.Maintenance != nil {
+		w, err := ds.FindMaintenanceWindow(m.Maintenance.WindowID)
+		if err != nil && !metal.IsNotFound(err) {
+			return nil, err
+		}
+		resp.MaintenanceWindow = v1.NewMaintenanceWindowResponse(w, time.Now())
+	}
+
+	return resp, nil
 }
 
-func makeMachineResponseList(ms metal.Machines, ds *datastore.RethinkStore) ([]*v1.MachineResponse, error) {
-	sMap, pMap, iMap, ecMap, err := getMachineReferencedEntityMaps(ds)
+func makeMachineResponseList(ms metal.Machines, ds datastore.Store) ([]*v1.MachineResponse, error) {
+	sMap, pMap, iMap, ecMap, err := getMachineReferencedEntityMaps(ds, ms)
 	if err != nil {
 		return nil, err
 	}
 
+	var windows map[string]*metal.MaintenanceWindow
+	if slices.ContainsFunc(ms, func(m metal.Machine) bool { return m.Maintenance != nil }) {
+		ws, err := ds.ListMaintenanceWindows()
+		if err != nil {
+			return nil, err
+		}
+		windows = ws.ByID()
+	}
+
+	now := time.Now()
+
 	result := []*v1.MachineResponse{}
 
 	for index := range ms {
@@ -2385,13 +3353,17 @@ func makeMachineResponseList(ms metal.Machines, ds *datastore.RethinkStore) ([]*
 			}
 		}
 		ec := ecMap[ms[index].ID]
-		result = append(result, v1.NewMachineResponse(&ms[index], s, p, i, &ec))
+		resp := v1.NewMachineResponse(&ms[index], s, p, i, &ec)
+		if ms[index].Maintenance != nil {
+			resp.MaintenanceWindow = v1.NewMaintenanceWindowResponse(windows[ms[index].Maintenance.WindowID], now)
+		}
+		result = append(result, resp)
 	}
 
 	return result, nil
 }
 
-func makeMachineIPMIResponse(m *metal.Machine, ds *datastore.RethinkStore) (*v1.MachineIPMIResponse, error) {
+func makeMachineIPMIResponse(m *metal.Machine, ds datastore.Store) (*v1.MachineIPMIResponse, error) {
 	s, p, i, ec, err := findMachineReferencedEntities(m, ds)
 	if err != nil {
 		return nil, err
@@ -2399,8 +3371,8 @@ func makeMachineIPMIResponse(m *metal.Machine, ds *datastore.RethinkStore) (*v1.
 	return v1.NewMachineIPMIResponse(m, s, p, i, ec), nil
 }
 
-func makeMachineIPMIResponseList(ms metal.Machines, ds *datastore.RethinkStore) ([]*v1.MachineIPMIResponse, error) {
-	sMap, pMap, iMap, ecMap, err := getMachineReferencedEntityMaps(ds)
+func makeMachineIPMIResponseList(ms metal.Machines, ds datastore.Store) ([]*v1.MachineIPMIResponse, error) {
+	sMap, pMap, iMap, ecMap, err := getMachineReferencedEntityMaps(ds, ms)
 	if err != nil {
 		return nil, err
 	}
@@ -2432,7 +3404,7 @@ func makeMachineIPMIResponseList(ms metal.Machines, ds *datastore.RethinkStore)
 	return result, nil
 }
 
-func findMachineReferencedEntities(m *metal.Machine, ds *datastore.RethinkStore) (*metal.Size, *metal.Partition, *metal.Image, *metal.ProvisioningEventContainer, error) {
+func findMachineReferencedEntities(m *metal.Machine, ds datastore.Store) (*metal.Size, *metal.Partition, *metal.Image, *metal.ProvisioningEventContainer, error) {
 	var err error
 
 	var s *metal.Size
@@ -2476,7 +3448,9 @@ func findMachineReferencedEntities(m *metal.Machine, ds *datastore.RethinkStore)
 	return s, p, i, ec, nil
 }
 
-func getMachineReferencedEntityMaps(ds *datastore.RethinkStore) (metal.SizeMap, metal.PartitionMap, metal.ImageMap, metal.ProvisioningEventContainerMap, error) {
+// getMachineReferencedEntityMaps returns the entities referenced by the given machines. sizes, partitions and images are
+// few, so they are listed entirely, whereas only the event containers of the given machines are fetched.
+func getMachineReferencedEntityMaps(ds datastore.Store, ms metal.Machines) (metal.SizeMap, metal.PartitionMap, metal.ImageMap, metal.ProvisioningEventContainerMap, error) {
 	s, err := ds.ListSizes()
 	if err != nil {
 		return nil, nil, nil, nil, fmt.Errorf("sizes could not be listed: %w", err)
@@ -2492,9 +3466,13 @@ func getMachineReferencedEntityMaps(ds *datastore.RethinkStore) (metal.SizeMap,
 		return nil, nil, nil, nil, fmt.Errorf("images could not be listed: %w", err)
 	}
 
-	ec, err := ds.ListProvisioningEventContainers()
+	ids := make([]string, 0, len(ms))
+	for _, m := range ms {
+		ids = append(ids, m.ID)
+	}
+	ec, err := ds.FindProvisioningEventContainers(ids)
 	if err != nil {
-		return nil, nil, nil, nil, fmt.Errorf("provisioning event containers could not be listed: %w", err)
+		return nil, nil, nil, nil, fmt.Errorf("provisioning event containers could not be found: %w", err)
 	}
 
 	return s.ByID(), p.ByID(), i.ByID(), ec.ByID(), nil
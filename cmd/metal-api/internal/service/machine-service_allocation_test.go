@@ -36,6 +36,7 @@ import (
 	mdmv1mock "github.com/metal-stack/masterdata-api/api/v1/mocks"
 	mdm "github.com/metal-stack/masterdata-api/pkg/client"
 	"github.com/metal-stack/metal-api/cmd/metal-api/internal/datastore"
+	"github.com/metal-stack/metal-api/cmd/metal-api/internal/eventbus"
 	metalgrpc "github.com/metal-stack/metal-api/cmd/metal-api/internal/grpc"
 	"github.com/metal-stack/metal-api/cmd/metal-api/internal/ipam"
 	"github.com/metal-stack/metal-api/cmd/metal-api/internal/metal"
@@ -207,6 +208,27 @@ func TestMachineAllocationIntegration(t *testing.T) {
 	err = f.Wait()
 	require.NoError(t, err)
 
+	// Bulk allocate more machines than available, no machine must remain allocated
+	_, err = allocMachines(webContainer, v1.MachineBulkAllocateRequest{Count: new(machineCount + 1), Template: &ar})
+	require.Error(t, err)
+
+	var allocated metal.Machines
+	err = rs.SearchMachines(&datastore.MachineSearchQuery{AllocationProject: new("pr1")}, &allocated)
+	require.NoError(t, err)
+	require.Empty(t, allocated)
+
+	// Bulk allocate all machines
+	bulk, err := allocMachines(webContainer, v1.MachineBulkAllocateRequest{Count: new(machineCount), Template: &ar})
+	require.NoError(t, err)
+	require.Len(t, bulk.Allocations, machineCount)
+
+	allocationUUIDs := make(map[string]bool)
+	for _, a := range bulk.Allocations {
+		require.NotEmpty(t, a.AllocationUUID)
+		require.Equal(t, a.AllocationUUID, a.Machine.Allocation.AllocationUUID)
+		allocationUUIDs[a.AllocationUUID] = true
+	}
+	require.Len(t, allocationUUIDs, machineCount)
 }
 
 // Methods under Test ---------------------------------------------------------------------------------------
@@ -233,6 +255,28 @@ func allocMachine(container *restful.Container, ar v1.MachineAllocateRequest) (v
 	return result, err
 }
 
+func allocMachines(container *restful.Container, ar v1.MachineBulkAllocateRequest) (v1.MachineBulkAllocateResponse, error) {
+	js, err := json.Marshal(ar)
+	if err != nil {
+		return v1.MachineBulkAllocateResponse{}, err
+	}
+	body := bytes.NewBuffer(js)
+	req := httptest.NewRequest("POST", "/v1/machine/allocate/bulk", body)
+	req.Header.Add("Content-Type", "application/json")
+	hma.AddAuth(req, time.Now(), js)
+	w := httptest.NewRecorder()
+	container.ServeHTTP(w, req)
+
+	resp := w.Result()
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return v1.MachineBulkAllocateResponse{}, errors.New(w.Body.String())
+	}
+	var result v1.MachineBulkAllocateResponse
+	err = json.NewDecoder(resp.Body).Decode(&result)
+	return result, err
+}
+
 func freeMachine(container *restful.Container, id string) (v1.MachineResponse, error) {
 	js, err := json.Marshal("")
 	if err != nil {
@@ -346,7 +390,7 @@ func setupTestEnvironment(machineCount int, t *testing.T, ds *datastore.RethinkS
 			Context:          context.Background(),
 			Store:            ds,
 			Publisher:        publisher,
-			Consumer:         consumer,
+			Consumer:         &eventbus.NSQClient{Consumer: consumer},
 			Listener:         listener,
 			Logger:           log,
 			TlsEnabled:       false,
@@ -359,7 +403,7 @@ func setupTestEnvironment(machineCount int, t *testing.T, ds *datastore.RethinkS
 	}()
 
 	usergetter := security.NewCreds(security.WithHMAC(hma))
-	ms, err := NewMachine(log, ds, publisher, bus.NewEndpoints(consumer, publisher), metalIPAMer, mdc, nil, usergetter, 0, nil, metal.DisabledIPMISuperUser())
+	ms, err := NewMachine(log, ds, publisher, bus.NewEndpoints(consumer, publisher), metalIPAMer, mdc, nil, usergetter, 0, nil, metal.DisabledIPMISuperUser(), 100)
 	require.NoError(t, err)
 	container := restful.NewContainer().Add(ms)
 	container.Filter(rest.UserAuth(usergetter, log))
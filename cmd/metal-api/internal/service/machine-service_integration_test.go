@@ -29,19 +29,17 @@ import (
 func TestMachineAllocationIntegrationFullCycle(t *testing.T) {
 	log := slog.New(slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelError}))
 
-	rethinkContainer, cd, err := test.StartRethink(t)
-	require.NoError(t, err)
-	nsqContainer, publisher, consumer := test.StartNsqd(t, log)
+	forEachStore(t, log, func(t *testing.T, ds datastore.Store) {
+		testMachineAllocationFullCycle(t, log, ds)
+	})
+}
 
+func testMachineAllocationFullCycle(t *testing.T, log *slog.Logger, ds datastore.Store) {
+	nsqContainer, publisher, consumer := test.StartNsqd(t, log)
 	defer func() {
-		_ = rethinkContainer.Terminate(context.Background())
 		_ = nsqContainer.Terminate(context.Background())
 	}()
 
-	ds := datastore.New(log, cd.IP+":"+cd.Port, cd.DB, cd.User, cd.Password)
-	ds.VRFPoolRangeMax = 1000
-	ds.ASNPoolRangeMax = 1000
-
 	te := createTestEnvironment(t, log, ds, publisher, consumer)
 
 	// Register a machine
@@ -139,8 +137,8 @@ func TestMachineAllocationIntegrationFullCycle(t *testing.T) {
 	assert.Len(t, allocatedMachine.Allocation.MachineNetworks, 1)
 	assert.Equal(t, allocatedMachine.Allocation.MachineNetworks[0].NetworkType, metal.PrivatePrimaryUnshared.String())
 	assert.NotEmpty(t, allocatedMachine.Allocation.MachineNetworks[0].Vrf)
-	assert.GreaterOrEqual(t, allocatedMachine.Allocation.MachineNetworks[0].Vrf, te.ds.VRFPoolRangeMin)
-	assert.LessOrEqual(t, allocatedMachine.Allocation.MachineNetworks[0].Vrf, te.ds.VRFPoolRangeMax)
+	assert.GreaterOrEqual(t, allocatedMachine.Allocation.MachineNetworks[0].Vrf, datastore.DefaultVRFPoolRangeMin)
+	assert.LessOrEqual(t, allocatedMachine.Allocation.MachineNetworks[0].Vrf, testPoolRangeMax)
 	assert.GreaterOrEqual(t, allocatedMachine.Allocation.MachineNetworks[0].ASN, int64(ASNBase))
 	assert.Len(t, allocatedMachine.Allocation.MachineNetworks[0].IPs, 1)
 	_, ipnet, _ := net.ParseCIDR(te.privateNetwork.Prefixes[0])
@@ -180,8 +178,8 @@ func TestMachineAllocationIntegrationFullCycle(t *testing.T) {
 	assert.Len(t, allocatedMachine.Allocation.MachineNetworks, 1)
 	assert.Equal(t, allocatedMachine.Allocation.MachineNetworks[0].NetworkType, metal.PrivatePrimaryUnshared.String())
 	assert.NotEmpty(t, allocatedMachine.Allocation.MachineNetworks[0].Vrf)
-	assert.GreaterOrEqual(t, allocatedMachine.Allocation.MachineNetworks[0].Vrf, te.ds.VRFPoolRangeMin)
-	assert.LessOrEqual(t, allocatedMachine.Allocation.MachineNetworks[0].Vrf, te.ds.VRFPoolRangeMax)
+	assert.GreaterOrEqual(t, allocatedMachine.Allocation.MachineNetworks[0].Vrf, datastore.DefaultVRFPoolRangeMin)
+	assert.LessOrEqual(t, allocatedMachine.Allocation.MachineNetworks[0].Vrf, testPoolRangeMax)
 	assert.GreaterOrEqual(t, allocatedMachine.Allocation.MachineNetworks[0].ASN, int64(ASNBase))
 	assert.Len(t, allocatedMachine.Allocation.MachineNetworks[0].IPs, 1)
 	_, ipnet, _ = net.ParseCIDR(te.privateNetwork.Prefixes[0])
@@ -295,7 +293,7 @@ func BenchmarkMachineList(b *testing.B) {
 		require.NoError(b, err)
 	}
 
-	machineService, err := NewMachine(log, ds, &emptyPublisher{}, bus.DirectEndpoints(), nil, nil, nil, nil, 0, nil, metal.DisabledIPMISuperUser())
+	machineService, err := NewMachine(log, ds, &emptyPublisher{}, bus.DirectEndpoints(), nil, nil, nil, nil, 0, nil, metal.DisabledIPMISuperUser(), 100)
 	require.NoError(b, err)
 
 	b.ResetTimer()
@@ -2,6 +2,7 @@ package service
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"log/slog"
@@ -9,8 +10,12 @@ import (
 	"net/http/httptest"
 	"os"
 	"testing"
+	"time"
 
 	"github.com/emicklei/go-restful/v3"
+	mdmv1 "github.com/metal-stack/masterdata-api/api/v1"
+	mdmock "github.com/metal-stack/masterdata-api/api/v1/mocks"
+	mdm "github.com/metal-stack/masterdata-api/pkg/client"
 	"github.com/metal-stack/metal-api/cmd/metal-api/internal/datastore"
 	"github.com/metal-stack/metal-api/cmd/metal-api/internal/ipam"
 	"github.com/metal-stack/metal-api/cmd/metal-api/internal/metal"
@@ -18,6 +23,7 @@ import (
 	"github.com/metal-stack/metal-api/cmd/metal-api/internal/testdata"
 	"github.com/metal-stack/metal-lib/bus"
 	"github.com/metal-stack/security"
+	testifymock "github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/require"
 	"golang.org/x/crypto/ssh"
 	r "gopkg.in/rethinkdb/rethinkdb-go.v6"
@@ -57,7 +63,7 @@ func TestGetMachines(t *testing.T) {
 	testdata.InitMockDBData(mock)
 	log := slog.Default()
 
-	machineservice, err := NewMachine(log, ds, &emptyPublisher{}, bus.DirectEndpoints(), ipam.InitTestIpam(t), nil, nil, nil, 0, nil, metal.DisabledIPMISuperUser())
+	machineservice, err := NewMachine(log, ds, &emptyPublisher{}, bus.DirectEndpoints(), ipam.InitTestIpam(t), nil, nil, nil, 0, nil, metal.DisabledIPMISuperUser(), 100)
 	require.NoError(t, err)
 	container := restful.NewContainer().Add(machineservice)
 	req := httptest.NewRequest("GET", "/v1/machine", nil)
@@ -120,7 +126,7 @@ func TestMachineIPMIReport(t *testing.T) {
 	for i := range tests {
 		tt := tests[i]
 		t.Run(tt.name, func(t *testing.T) {
-			machineservice, err := NewMachine(log, ds, &emptyPublisher{}, bus.DirectEndpoints(), ipam.InitTestIpam(t), nil, nil, nil, 0, nil, metal.DisabledIPMISuperUser())
+			machineservice, err := NewMachine(log, ds, &emptyPublisher{}, bus.DirectEndpoints(), ipam.InitTestIpam(t), nil, nil, nil, 0, nil, metal.DisabledIPMISuperUser(), 100)
 			require.NoError(t, err)
 			container := restful.NewContainer().Add(machineservice)
 			js, err := json.Marshal(tt.input)
@@ -171,7 +177,7 @@ func TestMachineFindIPMI(t *testing.T) {
 			mock.On(r.DB("mockdb").Table("machine").Filter(r.MockAnything())).Return([]any{*tt.machine}, nil)
 			testdata.InitMockDBData(mock)
 
-			machineservice, err := NewMachine(log, ds, &emptyPublisher{}, bus.DirectEndpoints(), ipam.InitTestIpam(t), nil, nil, nil, 0, nil, metal.DisabledIPMISuperUser())
+			machineservice, err := NewMachine(log, ds, &emptyPublisher{}, bus.DirectEndpoints(), ipam.InitTestIpam(t), nil, nil, nil, 0, nil, metal.DisabledIPMISuperUser(), 100)
 			require.NoError(t, err)
 			container := restful.NewContainer().Add(machineservice)
 
@@ -227,7 +233,7 @@ func TestSetMachineState(t *testing.T) {
 		Name:  "anonymous",
 	}}
 
-	machineservice, err := NewMachine(log, ds, &emptyPublisher{}, bus.DirectEndpoints(), ipam.InitTestIpam(t), nil, nil, userGetter, 0, nil, metal.DisabledIPMISuperUser())
+	machineservice, err := NewMachine(log, ds, &emptyPublisher{}, bus.DirectEndpoints(), ipam.InitTestIpam(t), nil, nil, userGetter, 0, nil, metal.DisabledIPMISuperUser(), 100)
 	require.NoError(t, err)
 
 	container := restful.NewContainer().Add(machineservice)
@@ -268,7 +274,7 @@ func TestSetMachineStateIssuerResetWhenAvailable(t *testing.T) {
 		Name:  "anonymous",
 	}}
 
-	machineservice, err := NewMachine(log, ds, &emptyPublisher{}, bus.DirectEndpoints(), ipam.InitTestIpam(t), nil, nil, userGetter, 0, nil, metal.DisabledIPMISuperUser())
+	machineservice, err := NewMachine(log, ds, &emptyPublisher{}, bus.DirectEndpoints(), ipam.InitTestIpam(t), nil, nil, userGetter, 0, nil, metal.DisabledIPMISuperUser(), 100)
 	require.NoError(t, err)
 
 	container := restful.NewContainer().Add(machineservice)
@@ -304,7 +310,7 @@ func TestGetMachine(t *testing.T) {
 	testdata.InitMockDBData(mock)
 	log := slog.Default()
 
-	machineservice, err := NewMachine(log, ds, &emptyPublisher{}, bus.DirectEndpoints(), ipam.InitTestIpam(t), nil, nil, nil, 0, nil, metal.DisabledIPMISuperUser())
+	machineservice, err := NewMachine(log, ds, &emptyPublisher{}, bus.DirectEndpoints(), ipam.InitTestIpam(t), nil, nil, nil, 0, nil, metal.DisabledIPMISuperUser(), 100)
 	require.NoError(t, err)
 
 	container := restful.NewContainer().Add(machineservice)
@@ -332,7 +338,7 @@ func TestGetMachineNotFound(t *testing.T) {
 	testdata.InitMockDBData(mock)
 	log := slog.Default()
 
-	machineservice, err := NewMachine(log, ds, &emptyPublisher{}, bus.DirectEndpoints(), ipam.InitTestIpam(t), nil, nil, nil, 0, nil, metal.DisabledIPMISuperUser())
+	machineservice, err := NewMachine(log, ds, &emptyPublisher{}, bus.DirectEndpoints(), ipam.InitTestIpam(t), nil, nil, nil, 0, nil, metal.DisabledIPMISuperUser(), 100)
 	require.NoError(t, err)
 
 	container := restful.NewContainer().Add(machineservice)
@@ -368,7 +374,7 @@ func TestFreeMachine(t *testing.T) {
 		return nil
 	}
 
-	machineservice, err := NewMachine(log, ds, pub, bus.NewEndpoints(nil, pub), ipam.InitTestIpam(t), nil, nil, nil, 0, nil, metal.DisabledIPMISuperUser())
+	machineservice, err := NewMachine(log, ds, pub, bus.NewEndpoints(nil, pub), ipam.InitTestIpam(t), nil, nil, nil, 0, nil, metal.DisabledIPMISuperUser(), 100)
 	require.NoError(t, err)
 
 	container := restful.NewContainer().Add(machineservice)
@@ -395,7 +401,7 @@ func TestSearchMachine(t *testing.T) {
 	testdata.InitMockDBData(mock)
 	log := slog.Default()
 
-	machineservice, err := NewMachine(log, ds, &emptyPublisher{}, bus.DirectEndpoints(), ipam.InitTestIpam(t), nil, nil, nil, 0, nil, metal.DisabledIPMISuperUser())
+	machineservice, err := NewMachine(log, ds, &emptyPublisher{}, bus.DirectEndpoints(), ipam.InitTestIpam(t), nil, nil, nil, 0, nil, metal.DisabledIPMISuperUser(), 100)
 	require.NoError(t, err)
 
 	container := restful.NewContainer().Add(machineservice)
@@ -487,7 +493,7 @@ func TestOnMachine(t *testing.T) {
 				return nil
 			}
 
-			machineservice, err := NewMachine(log, ds, pub, bus.DirectEndpoints(), ipam.InitTestIpam(t), nil, nil, nil, 0, nil, metal.DisabledIPMISuperUser())
+			machineservice, err := NewMachine(log, ds, pub, bus.DirectEndpoints(), ipam.InitTestIpam(t), nil, nil, nil, 0, nil, metal.DisabledIPMISuperUser(), 100)
 			require.NoError(t, err)
 
 			js, err := json.Marshal([]string{tt.param})
@@ -689,6 +695,79 @@ func Test_validateAllocationSpec(t *testing.T) {
 	}
 }
 
+func Test_bulkAllocateRequests(t *testing.T) {
+	template := v1.MachineAllocateRequest{ProjectID: "p1", SizeID: "s1", PartitionID: "a"}
+
+	tests := []struct {
+		name    string
+		request v1.MachineBulkAllocateRequest
+		want    []v1.MachineAllocateRequest
+		wantErr string
+	}{
+		{
+			name:    "count with template",
+			request: v1.MachineBulkAllocateRequest{Count: new(2), Template: &template},
+			want:    []v1.MachineAllocateRequest{template, template},
+		},
+		{
+			name:    "list of requests",
+			request: v1.MachineBulkAllocateRequest{Requests: []v1.MachineAllocateRequest{template, {ProjectID: "p2"}}},
+			want:    []v1.MachineAllocateRequest{template, {ProjectID: "p2"}},
+		},
+		{
+			name:    "nothing given",
+			request: v1.MachineBulkAllocateRequest{},
+			wantErr: "either count and template or requests must be specified",
+		},
+		{
+			name:    "template without count",
+			request: v1.MachineBulkAllocateRequest{Template: &template},
+			wantErr: "a template can only be used in combination with a count",
+		},
+		{
+			name:    "count without template",
+			request: v1.MachineBulkAllocateRequest{Count: new(2)},
+			wantErr: "a template must be specified when a count is given",
+		},
+		{
+			name:    "count and requests",
+			request: v1.MachineBulkAllocateRequest{Count: new(2), Template: &template, Requests: []v1.MachineAllocateRequest{template}},
+			wantErr: "count and requests are mutually exclusive",
+		},
+		{
+			name:    "zero count",
+			request: v1.MachineBulkAllocateRequest{Count: new(0), Template: &template},
+			wantErr: "count must be greater than zero",
+		},
+		{
+			name:    "machine uuid for multiple machines",
+			request: v1.MachineBulkAllocateRequest{Count: new(2), Template: &v1.MachineAllocateRequest{UUID: new("m1")}},
+			wantErr: "a machine uuid cannot be used in a template for more than one machine",
+		},
+		{
+			name:    "count above the limit",
+			request: v1.MachineBulkAllocateRequest{Count: new(4), Template: &template},
+			wantErr: "at most 3 machines can be allocated at once",
+		},
+		{
+			name:    "requests above the limit",
+			request: v1.MachineBulkAllocateRequest{Requests: []v1.MachineAllocateRequest{template, template, template, template}},
+			wantErr: "at most 3 machines can be allocated at once",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := bulkAllocateRequests(tt.request, 3)
+			if tt.wantErr != "" {
+				require.EqualError(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.want, got)
+		})
+	}
+}
+
 func Test_makeMachineTags(t *testing.T) {
 	type args struct {
 		m        *metal.Machine
@@ -1350,3 +1429,329 @@ func Test_gatherNetworksFromSpec(t *testing.T) {
 		})
 	}
 }
+
+func Test_explainMachineAllocation(t *testing.T) {
+	log := slog.Default()
+	ds := datastore.NewMemory(log)
+
+	size := metal.Size{Base: metal.Base{ID: "c1-large"}}
+	image := metal.Image{Base: metal.Base{ID: "ubuntu-24.04"}, OS: "ubuntu", Version: "24.04"}
+	require.NoError(t, ds.CreateSize(&size))
+	require.NoError(t, ds.CreatePartition(&metal.Partition{Base: metal.Base{ID: "partition"}}))
+	require.NoError(t, ds.CreateFilesystemLayout(&metal.FilesystemLayout{
+		Base:        metal.Base{ID: "default"},
+		Constraints: metal.FilesystemLayoutConstraints{Sizes: []string{size.ID}, Images: map[string]string{"ubuntu": "*"}},
+	}))
+	for _, id := range []string{"m1", "m2"} {
+		require.NoError(t, ds.CreateMachine(&metal.Machine{Base: metal.Base{ID: id}, PartitionID: "partition", SizeID: size.ID, Waiting: true}))
+		require.NoError(t, ds.CreateProvisioningEventContainer(&metal.ProvisioningEventContainer{Base: metal.Base{ID: id}, Liveliness: metal.MachineLivelinessAlive}))
+	}
+
+	psc := &mdmock.ProjectServiceClient{}
+	psc.On("Get", testifymock.Anything, &mdmv1.ProjectGetRequest{Id: "p1"}).Return(&mdmv1.ProjectResponse{
+		Project: &mdmv1.Project{
+			Meta:   &mdmv1.Meta{Id: "p1"},
+			Quotas: &mdmv1.QuotaSet{Machine: &mdmv1.Quota{Max: new(int32(0))}},
+		},
+	}, nil)
+	mdc := mdm.NewMock(psc, nil, nil, nil, nil)
+
+	spec := &machineAllocationSpec{
+		Creator:     "creator",
+		ProjectID:   "p1",
+		PartitionID: "partition",
+		Size:        &size,
+		Image:       &image,
+		Role:        metal.RoleMachine,
+	}
+
+	resp, err := explainMachineAllocation(context.Background(), log, ds, spec, mdc)
+	require.NoError(t, err)
+
+	require.False(t, resp.Possible)
+	require.Equal(t, []v1.MachineAllocationCheck{
+		{Name: "allocation-spec", Passed: true},
+		{Name: "size-image-constraint", Passed: true},
+		{Name: "project-quota", Passed: false, Message: new("project quota for machine reached max:0 used:0")},
+		{Name: "filesystem-layout", Passed: true},
+		{Name: "partition", Passed: true},
+	}, resp.Checks)
+	require.ElementsMatch(t, []string{"m1", "m2"}, resp.Candidates)
+	require.Equal(t, "rack-spreading", resp.Stages[len(resp.Stages)-1].Name)
+
+	// nothing was preallocated
+	m, err := ds.FindMachineByID("m1")
+	require.NoError(t, err)
+	require.False(t, m.PreAllocated)
+}
+
+func Test_makeMachineNetwork_IPQuota(t *testing.T) {
+	var (
+		ctx    = context.Background()
+		ds     = datastore.NewMemory(slog.Default())
+		ipamer = ipam.InitTestIpam(t)
+		nw     = &metal.Network{Base: metal.Base{ID: "internet"}, Prefixes: metal.Prefixes{{IP: "212.34.83.0", Length: "27"}, {IP: "2001:db8::", Length: "64"}}}
+		spec   = &machineAllocationSpec{UUID: "m1", ProjectID: "p1"}
+	)
+
+	project := func(maxIPs int32) *mdmv1.Project {
+		return &mdmv1.Project{Meta: &mdmv1.Meta{Id: "p1"}, Quotas: &mdmv1.QuotaSet{Ip: &mdmv1.Quota{Max: &maxIPs}}}
+	}
+
+	for _, prefix := range nw.Prefixes {
+		require.NoError(t, ipamer.CreatePrefix(ctx, prefix))
+	}
+	require.NoError(t, ds.CreateNetwork(nw))
+
+	// only one of the ips of the dual stack network fits into the quota
+	_, err := makeMachineNetwork(ctx, ds, ipamer, project(1), spec, &allocationNetwork{network: nw, auto: true})
+	qe, ok := metal.IsQuotaExceeded(err)
+	require.True(t, ok, "expected quota error, got %v", err)
+	require.Equal(t, &metal.QuotaExceededError{Kind: metal.QuotaKindIP, ProjectID: "p1", Max: 1, Used: 1}, qe)
+
+	// the ip acquired for the other address family was released again
+	var ips metal.IPs
+	require.NoError(t, ds.SearchIPs(&datastore.IPSearchQuery{ProjectID: new("p1")}, &ips))
+	require.Empty(t, ips)
+
+	got, err := makeMachineNetwork(ctx, ds, ipamer, project(2), spec, &allocationNetwork{network: nw, auto: true})
+	require.NoError(t, err)
+	require.Len(t, got.IPs, 2)
+}
+
+func TestListMachineHardwareHistory(t *testing.T) {
+	log := slog.Default()
+	ds := datastore.NewMemory(log)
+
+	require.NoError(t, ds.CreateMachine(&metal.Machine{Base: metal.Base{ID: "m1"}}))
+	require.NoError(t, ds.CreateHardwareSnapshot(&metal.HardwareSnapshot{MachineID: "m1", Revision: 2, Hardware: metal.MachineHardware{Memory: 512}, Changes: []metal.HardwareChange{
+		{Component: "memory", Type: metal.HardwareChangeModified, Previous: "1024", Current: "512"},
+	}}))
+	require.NoError(t, ds.CreateHardwareSnapshot(&metal.HardwareSnapshot{MachineID: "m1", Revision: 1, Hardware: metal.MachineHardware{Memory: 1024}}))
+	require.NoError(t, ds.CreateHardwareSnapshot(&metal.HardwareSnapshot{MachineID: "m2", Revision: 1}))
+
+	machineService, err := NewMachine(log, ds, &emptyPublisher{}, bus.DirectEndpoints(), nil, nil, nil, nil, 0, nil, metal.DisabledIPMISuperUser(), 100)
+	require.NoError(t, err)
+	container := restful.NewContainer().Add(machineService)
+
+	req := httptest.NewRequest("GET", "/v1/machine/m1/hardware-history", nil)
+	container = injectViewer(log, container, req)
+	w := httptest.NewRecorder()
+	container.ServeHTTP(w, req)
+
+	resp := w.Result()
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode, w.Body.String())
+	var result []v1.MachineHardwareSnapshotResponse
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&result))
+
+	require.Len(t, result, 2)
+	require.Equal(t, 1, result[0].Revision)
+	require.Equal(t, uint64(1024), result[0].Hardware.Memory)
+	require.Empty(t, result[0].Changes)
+	require.Equal(t, 2, result[1].Revision)
+	require.Equal(t, []v1.MachineHardwareChange{{Component: "memory", Type: "modified", Previous: "1024", Current: "512"}}, result[1].Changes)
+
+	req = httptest.NewRequest("GET", "/v1/machine/unknown/hardware-history", nil)
+	container = injectViewer(log, container, req)
+	w = httptest.NewRecorder()
+	container.ServeHTTP(w, req)
+	require.Equal(t, http.StatusNotFound, w.Code, w.Body.String())
+}
+
+func TestAcknowledgeMachineHardwareChanges(t *testing.T) {
+	log := slog.Default()
+	ds := datastore.NewMemory(log)
+
+	require.NoError(t, ds.CreateHardwareSnapshot(&metal.HardwareSnapshot{MachineID: "m1", Revision: 1, Hardware: metal.MachineHardware{Memory: 1024}}))
+	require.NoError(t, ds.CreateHardwareSnapshot(&metal.HardwareSnapshot{MachineID: "m1", Revision: 2, Hardware: metal.MachineHardware{Memory: 512}, Changes: []metal.HardwareChange{
+		{Component: "memory", Type: metal.HardwareChangeModified, Previous: "1024", Current: "512"},
+	}}))
+	require.NoError(t, ds.CreateHardwareSnapshot(&metal.HardwareSnapshot{MachineID: "m2", Revision: 1}))
+
+	userGetter := mockUserGetter{&security.User{
+		EMail: "operator@metal-stack.io",
+		Name:  "operator",
+	}}
+
+	machineService, err := NewMachine(log, ds, &emptyPublisher{}, bus.DirectEndpoints(), nil, nil, nil, userGetter, 0, nil, metal.DisabledIPMISuperUser(), 100)
+	require.NoError(t, err)
+	container := restful.NewContainer().Add(machineService)
+
+	acknowledge := func(id string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/v1/machine/"+id+"/hardware-history/acknowledge", nil)
+		req.Header.Add("Content-Type", "application/json")
+		container = injectAdmin(log, container, req)
+		w := httptest.NewRecorder()
+		container.ServeHTTP(w, req)
+		return w
+	}
+
+	w := acknowledge("m1")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var result v1.MachineHardwareSnapshotResponse
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&result))
+	require.Equal(t, 2, result.Revision)
+	require.NotNil(t, result.Acknowledged)
+	require.Equal(t, "operator@metal-stack.io", result.AcknowledgedBy)
+
+	latest, err := ds.FindHardwareSnapshot("m1", 2)
+	require.NoError(t, err)
+	require.NotNil(t, latest.Acknowledged)
+
+	// acknowledging again keeps the first acknowledgement
+	w = acknowledge("m1")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var again v1.MachineHardwareSnapshotResponse
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&again))
+	require.WithinDuration(t, *result.Acknowledged, *again.Acknowledged, time.Millisecond)
+
+	// the first snapshot of a machine has no changes
+	w = acknowledge("m2")
+	require.Equal(t, http.StatusUnprocessableEntity, w.Code, w.Body.String())
+}
+
+func TestFindMachineBootConfiguration(t *testing.T) {
+	log := slog.Default()
+	ds := datastore.NewMemory(log)
+
+	require.NoError(t, ds.CreatePartition(&metal.Partition{
+		Base:              metal.Base{ID: "p1"},
+		BootConfiguration: metal.BootConfiguration{ImageURL: "http://images/metal-hammer.tgz", KernelURL: "http://images/kernel", CommandLine: "console=ttyS0"},
+		BootConfigurationOverrides: metal.BootConfigurationOverrides{
+			{Scope: metal.BootConfigurationOverrideScopeTag, Selector: "needs-flags", CommandLineAppend: "pci=nomsi"},
+		},
+	}))
+	require.NoError(t, ds.CreateMachine(&metal.Machine{Base: metal.Base{ID: "m1"}, PartitionID: "p1", Tags: []string{"needs-flags"}}))
+	require.NoError(t, ds.CreateMachine(&metal.Machine{Base: metal.Base{ID: "m2"}, PartitionID: "p1"}))
+
+	machineService, err := NewMachine(log, ds, &emptyPublisher{}, bus.DirectEndpoints(), nil, nil, nil, nil, 0, nil, metal.DisabledIPMISuperUser(), 100)
+	require.NoError(t, err)
+	container := restful.NewContainer().Add(machineService)
+
+	get := func(id string) v1.MachineBootConfigurationResponse {
+		req := httptest.NewRequest("GET", "/v1/machine/"+id+"/bootconfig", nil)
+		container = injectViewer(log, container, req)
+		w := httptest.NewRecorder()
+		container.ServeHTTP(w, req)
+
+		resp := w.Result()
+		defer resp.Body.Close()
+		require.Equal(t, http.StatusOK, resp.StatusCode, w.Body.String())
+		var result v1.MachineBootConfigurationResponse
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&result))
+		return result
+	}
+
+	result := get("m1")
+	require.Equal(t, "console=ttyS0 pci=nomsi", *result.PartitionBootConfiguration.CommandLine)
+	require.NotNil(t, result.Override)
+	require.Equal(t, "tag", result.Override.Scope)
+	require.Equal(t, "needs-flags", result.Override.Selector)
+
+	result = get("m2")
+	require.Equal(t, "console=ttyS0", *result.PartitionBootConfiguration.CommandLine)
+	require.Nil(t, result.Override)
+}
+
+func TestMachineProvisioningStatistics(t *testing.T) {
+	log := slog.Default()
+	ds := datastore.NewMemory(log)
+
+	start := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	for i, id := range []string{"m1", "m2", "m3"} {
+		sizeID := "s1"
+		if id == "m3" {
+			sizeID = "s2"
+		}
+		require.NoError(t, ds.CreateMachine(&metal.Machine{Base: metal.Base{ID: id}, PartitionID: "p1", SizeID: sizeID}))
+		require.NoError(t, ds.CreateProvisioningEventContainer(&metal.ProvisioningEventContainer{
+			Base: metal.Base{ID: id},
+			Events: metal.ProvisioningEvents{
+				{Time: start.Add(time.Duration(i+2) * time.Minute), Event: metal.ProvisioningEventRegistering},
+				{Time: start, Event: metal.ProvisioningEventPreparing},
+			},
+		}))
+	}
+
+	machineService, err := NewMachine(log, ds, &emptyPublisher{}, bus.DirectEndpoints(), nil, nil, nil, nil, 0, nil, metal.DisabledIPMISuperUser(), 100)
+	require.NoError(t, err)
+	container := restful.NewContainer().Add(machineService)
+
+	req := httptest.NewRequest("GET", "/v1/machine/m1/provisioning-timeline", nil)
+	container = injectViewer(log, container, req)
+	w := httptest.NewRecorder()
+	container.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	var timeline v1.MachineProvisioningTimelineResponse
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&timeline))
+	require.Len(t, timeline.Phases, 2)
+	require.Equal(t, "Preparing", timeline.Phases[0].Event)
+	require.Equal(t, 2*time.Minute, timeline.Phases[0].Duration)
+	require.Nil(t, timeline.Phases[1].End)
+	require.Equal(t, []v1.MachineProvisioningDuration{{From: "Preparing", To: "Registering", Start: start, Duration: 2 * time.Minute}}, timeline.Durations)
+
+	js, err := json.Marshal(v1.MachineProvisioningStatisticsRequest{})
+	require.NoError(t, err)
+	req = httptest.NewRequest("POST", "/v1/machine/provisioning-statistics", bytes.NewBuffer(js))
+	req.Header.Add("Content-Type", "application/json")
+	container = injectViewer(log, container, req)
+	w = httptest.NewRecorder()
+	container.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	var stats []v1.MachineProvisioningStatistic
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&stats))
+	require.Equal(t, []v1.MachineProvisioningStatistic{
+		{Group: "s1", From: "Preparing", To: "Registering", Count: 2, P50: 2 * time.Minute, P95: 3 * time.Minute, Max: 3 * time.Minute},
+		{Group: "s2", From: "Preparing", To: "Registering", Count: 1, P50: 4 * time.Minute, P95: 4 * time.Minute, Max: 4 * time.Minute},
+	}, stats)
+
+	_, err = MachineProvisioningStatistics(ds, &v1.MachineProvisioningStatisticsRequest{GroupBy: new("rack")})
+	require.EqualError(t, err, `unable to group by "rack", must be one of size, partition or image`)
+}
+
+func TestFindMachineProvisioningEventLog(t *testing.T) {
+	log := slog.Default()
+	ds := datastore.NewMemory(log)
+
+	start := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	for i := range 3 {
+		require.NoError(t, ds.CreateProvisioningEventLogEntry(&metal.ProvisioningEventLogEntry{
+			MachineID: "m1",
+			Time:      start.Add(time.Duration(i) * time.Minute),
+			Event:     metal.ProvisioningEventPXEBooting,
+			Message:   fmt.Sprintf("boot %d", i),
+		}))
+	}
+
+	machineService, err := NewMachine(log, ds, &emptyPublisher{}, bus.DirectEndpoints(), nil, nil, nil, nil, 0, nil, metal.DisabledIPMISuperUser(), 100)
+	require.NoError(t, err)
+	container := restful.NewContainer().Add(machineService)
+
+	find := func(q datastore.ProvisioningEventLogSearchQuery) *v1.MachineProvisioningEventLogResponse {
+		js, err := json.Marshal(q)
+		require.NoError(t, err)
+		req := httptest.NewRequest("POST", "/v1/machine/event-log/find", bytes.NewBuffer(js))
+		req.Header.Add("Content-Type", "application/json")
+		container = injectViewer(log, container, req)
+		w := httptest.NewRecorder()
+		container.ServeHTTP(w, req)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+		var result v1.MachineProvisioningEventLogResponse
+		require.NoError(t, json.NewDecoder(w.Body).Decode(&result))
+		return &result
+	}
+
+	page := find(datastore.ProvisioningEventLogSearchQuery{MachineID: new("m1"), Limit: new(2)})
+	require.Len(t, page.Entries, 2)
+	require.Equal(t, "boot 0", page.Entries[0].Message)
+	require.NotNil(t, page.NextCursor)
+
+	page = find(datastore.ProvisioningEventLogSearchQuery{MachineID: new("m1"), Limit: new(2), Cursor: page.NextCursor})
+	require.Len(t, page.Entries, 1)
+	require.Equal(t, "boot 2", page.Entries[0].Message)
+	require.Nil(t, page.NextCursor)
+}
@@ -0,0 +1,438 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/metal-stack/metal-api/cmd/metal-api/internal/datastore"
+	"github.com/metal-stack/metal-api/cmd/metal-api/internal/metal"
+	v1 "github.com/metal-stack/metal-api/cmd/metal-api/internal/service/v1"
+	"github.com/metal-stack/metal-lib/pkg/pointer"
+	"github.com/metal-stack/security"
+
+	restfulspec "github.com/emicklei/go-restful-openapi/v2"
+	restful "github.com/emicklei/go-restful/v3"
+	"github.com/metal-stack/metal-lib/httperrors"
+)
+
+type maintenanceWindowResource struct {
+	webResource
+	userGetter security.UserGetter
+}
+
+// NewMaintenanceWindow returns a webservice for maintenance window specific endpoints.
+func NewMaintenanceWindow(log *slog.Logger, ds datastore.Store, userGetter security.UserGetter) *restful.WebService {
+	r := maintenanceWindowResource{
+		webResource: webResource{
+			log: log,
+			ds:  ds,
+		},
+		userGetter: userGetter,
+	}
+	return r.webService()
+}
+
+func (r *maintenanceWindowResource) webService() *restful.WebService {
+	ws := new(restful.WebService)
+	ws.
+		Path(BasePath + "v1/maintenance-window").
+		Consumes(restful.MIME_JSON).
+		Produces(restful.MIME_JSON)
+
+	tags := []string{"maintenancewindow"}
+
+	ws.Route(ws.GET("/{id}").
+		To(viewer(r.findMaintenanceWindow)).
+		Operation("findMaintenanceWindow").
+		Doc("get maintenance window by id").
+		Param(ws.PathParameter("id", "identifier of the maintenance window").DataType("string")).
+		Metadata(restfulspec.KeyOpenAPITags, tags).
+		Writes(v1.MaintenanceWindowResponse{}).
+		Returns(http.StatusOK, "OK", v1.MaintenanceWindowResponse{}).
+		DefaultReturns("Error", httperrors.HTTPErrorResponse{}))
+
+	ws.Route(ws.GET("/").
+		To(viewer(r.listMaintenanceWindows)).
+		Operation("listMaintenanceWindows").
+		Doc("get all maintenance windows").
+		Metadata(restfulspec.KeyOpenAPITags, tags).
+		Writes([]v1.MaintenanceWindowResponse{}).
+		Returns(http.StatusOK, "OK", []v1.MaintenanceWindowResponse{}).
+		DefaultReturns("Error", httperrors.HTTPErrorResponse{}))
+
+	ws.Route(ws.POST("/find").
+		To(viewer(r.findMaintenanceWindows)).
+		Operation("findMaintenanceWindows").
+		Doc("get all maintenance windows that match given properties").
+		Metadata(restfulspec.KeyOpenAPITags, tags).
+		Reads(v1.MaintenanceWindowFindRequest{}).
+		Writes([]v1.MaintenanceWindowResponse{}).
+		Returns(http.StatusOK, "OK", []v1.MaintenanceWindowResponse{}).
+		DefaultReturns("Error", httperrors.HTTPErrorResponse{}))
+
+	ws.Route(ws.DELETE("/{id}").
+		To(admin(r.deleteMaintenanceWindow)).
+		Operation("deleteMaintenanceWindow").
+		Doc("deletes a maintenance window and returns the deleted entity, the targeted machines get their previous state back on the next evaluation").
+		Param(ws.PathParameter("id", "identifier of the maintenance window").DataType("string")).
+		Metadata(restfulspec.KeyOpenAPITags, tags).
+		Writes(v1.MaintenanceWindowResponse{}).
+		Returns(http.StatusOK, "OK", v1.MaintenanceWindowResponse{}).
+		DefaultReturns("Error", httperrors.HTTPErrorResponse{}))
+
+	ws.Route(ws.PUT("/").
+		To(admin(r.createMaintenanceWindow)).
+		Operation("createMaintenanceWindow").
+		Doc("create a maintenance window. if the given ID already exists a conflict is returned").
+		Metadata(restfulspec.KeyOpenAPITags, tags).
+		Reads(v1.MaintenanceWindowCreateRequest{}).
+		Returns(http.StatusCreated, "Created", v1.MaintenanceWindowResponse{}).
+		Returns(http.StatusConflict, "Conflict", httperrors.HTTPErrorResponse{}).
+		DefaultReturns("Error", httperrors.HTTPErrorResponse{}))
+
+	ws.Route(ws.POST("/").
+		To(admin(r.updateMaintenanceWindow)).
+		Operation("updateMaintenanceWindow").
+		Doc("updates a maintenance window. if the maintenance window was changed since this one was read, a conflict is returned").
+		Metadata(restfulspec.KeyOpenAPITags, tags).
+		Reads(v1.MaintenanceWindowUpdateRequest{}).
+		Returns(http.StatusOK, "OK", v1.MaintenanceWindowResponse{}).
+		Returns(http.StatusConflict, "Conflict", httperrors.HTTPErrorResponse{}).
+		DefaultReturns("Error", httperrors.HTTPErrorResponse{}))
+
+	return ws
+}
+
+func (r *maintenanceWindowResource) findMaintenanceWindow(request *restful.Request, response *restful.Response) {
+	id := request.PathParameter("id")
+
+	w, err := r.ds.FindMaintenanceWindow(id)
+	if err != nil {
+		r.sendError(request, response, defaultError(err))
+		return
+	}
+
+	r.send(request, response, http.StatusOK, v1.NewMaintenanceWindowResponse(w, time.Now()))
+}
+
+func (r *maintenanceWindowResource) listMaintenanceWindows(request *restful.Request, response *restful.Response) {
+	ws, err := r.ds.ListMaintenanceWindows()
+	if err != nil {
+		r.sendError(request, response, defaultError(err))
+		return
+	}
+
+	now := time.Now()
+	result := []*v1.MaintenanceWindowResponse{}
+	for i := range ws {
+		result = append(result, v1.NewMaintenanceWindowResponse(&ws[i], now))
+	}
+
+	r.send(request, response, http.StatusOK, result)
+}
+
+func (r *maintenanceWindowResource) findMaintenanceWindows(request *restful.Request, response *restful.Response) {
+	var requestPayload v1.MaintenanceWindowFindRequest
+	err := request.ReadEntity(&requestPayload)
+	if err != nil {
+		r.sendError(request, response, httperrors.BadRequest(err))
+		return
+	}
+
+	var ws metal.MaintenanceWindows
+	err = r.ds.SearchMaintenanceWindows(&datastore.MaintenanceWindowSearchQuery{
+		ID:          requestPayload.ID,
+		Scope:       requestPayload.Scope,
+		PartitionID: requestPayload.PartitionID,
+		RackID:      requestPayload.RackID,
+		MachineID:   requestPayload.MachineID,
+	}, &ws)
+	if err != nil {
+		r.sendError(request, response, defaultError(err))
+		return
+	}
+
+	now := time.Now()
+	result := []*v1.MaintenanceWindowResponse{}
+	for i := range ws {
+		if requestPayload.Active != nil && ws[i].IsActive(now) != *requestPayload.Active {
+			continue
+		}
+		result = append(result, v1.NewMaintenanceWindowResponse(&ws[i], now))
+	}
+
+	r.send(request, response, http.StatusOK, result)
+}
+
+func (r *maintenanceWindowResource) createMaintenanceWindow(request *restful.Request, response *restful.Response) {
+	var requestPayload v1.MaintenanceWindowCreateRequest
+	err := request.ReadEntity(&requestPayload)
+	if err != nil {
+		r.sendError(request, response, httperrors.BadRequest(err))
+		return
+	}
+
+	user, err := r.userGetter.User(request.Request)
+	if err != nil {
+		r.sendError(request, response, defaultError(err))
+		return
+	}
+
+	scope, err := metal.MaintenanceScopeFrom(requestPayload.Scope)
+	if err != nil {
+		r.sendError(request, response, httperrors.BadRequest(err))
+		return
+	}
+
+	w := &metal.MaintenanceWindow{
+		Base: metal.Base{
+			ID:          requestPayload.ID,
+			Name:        pointer.SafeDeref(requestPayload.Name),
+			Description: pointer.SafeDeref(requestPayload.Description),
+		},
+		Scope:       scope,
+		PartitionID: requestPayload.PartitionID,
+		RackID:      requestPayload.RackID,
+		MachineID:   requestPayload.MachineID,
+		Start:       requestPayload.Start,
+		End:         requestPayload.End,
+		Reason:      requestPayload.Reason,
+		Issuer:      user.EMail,
+	}
+
+	err = w.Validate()
+	if err != nil {
+		r.sendError(request, response, httperrors.BadRequest(err))
+		return
+	}
+
+	err = r.validateMaintenanceWindowTarget(w)
+	if err != nil {
+		r.sendError(request, response, defaultError(err))
+		return
+	}
+
+	err = r.ds.CreateMaintenanceWindow(w)
+	if err != nil {
+		r.sendError(request, response, defaultError(err))
+		return
+	}
+
+	r.send(request, response, http.StatusCreated, v1.NewMaintenanceWindowResponse(w, time.Now()))
+}
+
+func (r *maintenanceWindowResource) updateMaintenanceWindow(request *restful.Request, response *restful.Response) {
+	var requestPayload v1.MaintenanceWindowUpdateRequest
+	err := request.ReadEntity(&requestPayload)
+	if err != nil {
+		r.sendError(request, response, httperrors.BadRequest(err))
+		return
+	}
+
+	oldWindow, err := r.ds.FindMaintenanceWindow(requestPayload.ID)
+	if err != nil {
+		r.sendError(request, response, defaultError(err))
+		return
+	}
+
+	newWindow := *oldWindow
+
+	if requestPayload.Name != nil {
+		newWindow.Name = *requestPayload.Name
+	}
+	if requestPayload.Description != nil {
+		newWindow.Description = *requestPayload.Description
+	}
+	if requestPayload.Start != nil {
+		newWindow.Start = *requestPayload.Start
+	}
+	if requestPayload.End != nil {
+		newWindow.End = *requestPayload.End
+	}
+	if requestPayload.Reason != nil {
+		newWindow.Reason = *requestPayload.Reason
+	}
+
+	err = newWindow.Validate()
+	if err != nil {
+		r.sendError(request, response, httperrors.BadRequest(err))
+		return
+	}
+
+	err = r.ds.UpdateMaintenanceWindow(oldWindow, &newWindow)
+	if err != nil {
+		r.sendError(request, response, defaultError(err))
+		return
+	}
+
+	r.send(request, response, http.StatusOK, v1.NewMaintenanceWindowResponse(&newWindow, time.Now()))
+}
+
+func (r *maintenanceWindowResource) deleteMaintenanceWindow(request *restful.Request, response *restful.Response) {
+	id := request.PathParameter("id")
+
+	w, err := r.ds.FindMaintenanceWindow(id)
+	if err != nil {
+		r.sendError(request, response, defaultError(err))
+		return
+	}
+
+	err = r.ds.DeleteMaintenanceWindow(w)
+	if err != nil {
+		r.sendError(request, response, defaultError(err))
+		return
+	}
+
+	r.send(request, response, http.StatusOK, v1.NewMaintenanceWindowResponse(w, time.Now()))
+}
+
+func (r *maintenanceWindowResource) validateMaintenanceWindowTarget(w *metal.MaintenanceWindow) error {
+	if w.MachineID != "" {
+		_, err := r.ds.FindMachineByID(w.MachineID)
+		if err != nil {
+			return err
+		}
+	}
+	if w.PartitionID != "" {
+		_, err := r.ds.FindPartition(w.PartitionID)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// MaintenanceWindowEvaluator taints and restores machines when their maintenance windows start and end.
+type MaintenanceWindowEvaluator struct {
+	log *slog.Logger
+	ds  datastore.Store
+}
+
+// NewMaintenanceWindowEvaluator returns a new maintenance window evaluator.
+func NewMaintenanceWindowEvaluator(log *slog.Logger, ds datastore.Store) *MaintenanceWindowEvaluator {
+	return &MaintenanceWindowEvaluator{
+		log: log,
+		ds:  ds,
+	}
+}
+
+// Run evaluates the maintenance windows in the given interval until the context is done.
+func (e *MaintenanceWindowEvaluator) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			err := EvaluateMaintenanceWindows(e.ds, e.log)
+			if err != nil {
+				e.log.Error("unable to evaluate maintenance windows", "error", err)
+			}
+		}
+	}
+}
+
+// EvaluateMaintenanceWindows taints the machines which are targeted by an active maintenance window
+// and restores the previous state of machines whose maintenance window has ended.
+func EvaluateMaintenanceWindows(ds datastore.Store, logger *slog.Logger) error {
+	logger.Info("maintenance window evaluation was requested")
+
+	windows, err := ds.ListMaintenanceWindows()
+	if err != nil {
+		return err
+	}
+
+	machines, err := ds.ListMachines()
+	if err != nil {
+		return err
+	}
+
+	var (
+		now      = time.Now()
+		tainted  = 0
+		restored = 0
+		errs     = 0
+	)
+	for i := range machines {
+		m := machines[i]
+
+		changed, err := evaluateMachineMaintenance(ds, &m, windows.ActiveFor(&m, now))
+		if err != nil {
+			logger.Error("cannot evaluate maintenance of machine", "machineID", m.ID, "error", err)
+			errs++
+			// fall through, so the rest of the machines is getting evaluated
+			continue
+		}
+		if !changed {
+			continue
+		}
+		if m.Maintenance != nil {
+			tainted++
+		} else {
+			restored++
+		}
+	}
+
+	logger.Info("maintenance windows evaluated", "tainted", tainted, "restored", restored, "errors", errs)
+
+	return nil
+}
+
+// evaluateMachineMaintenance updates the maintenance of a machine according to the given active window
+// and returns true if the machine was updated.
+func evaluateMachineMaintenance(ds datastore.Store, m *metal.Machine, active *metal.MaintenanceWindow) (bool, error) {
+	old := *m
+
+	switch {
+	case active != nil && m.Maintenance == nil:
+		if m.State.Value == metal.LockedState {
+			// a locked machine must remain locked, it is not available for allocation anyway
+			return false, nil
+		}
+		m.Maintenance = &metal.MachineMaintenance{
+			WindowID:      active.ID,
+			PreviousState: m.State,
+		}
+		m.State = maintenanceState(active, m.State.MetalHammerVersion)
+	case active != nil && m.Maintenance.WindowID != active.ID:
+		// another window became responsible, the previous state from before the first window is kept
+		m.Maintenance = &metal.MachineMaintenance{
+			WindowID:      active.ID,
+			PreviousState: m.Maintenance.PreviousState,
+		}
+		if m.State.Value == metal.TaintedState {
+			m.State = maintenanceState(active, m.State.MetalHammerVersion)
+		}
+	case active == nil && m.Maintenance != nil:
+		// the state is only restored if nobody changed it manually during the maintenance
+		if m.State.Value == metal.TaintedState {
+			previous := m.Maintenance.PreviousState
+			previous.MetalHammerVersion = m.State.MetalHammerVersion
+			m.State = previous
+		}
+		m.Maintenance = nil
+	default:
+		return false, nil
+	}
+
+	err := ds.UpdateMachine(&old, m)
+	if err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+func maintenanceState(w *metal.MaintenanceWindow, metalHammerVersion string) metal.MachineState {
+	return metal.MachineState{
+		Value:              metal.TaintedState,
+		Description:        fmt.Sprintf("maintenance window %q until %s: %s", w.ID, w.End.Format(time.RFC3339), w.Reason),
+		Issuer:             w.Issuer,
+		MetalHammerVersion: metalHammerVersion,
+	}
+}
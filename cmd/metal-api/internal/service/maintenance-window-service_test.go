@@ -0,0 +1,202 @@
+package service
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/emicklei/go-restful/v3"
+	"github.com/metal-stack/metal-api/cmd/metal-api/internal/datastore"
+	"github.com/metal-stack/metal-api/cmd/metal-api/internal/metal"
+	v1 "github.com/metal-stack/metal-api/cmd/metal-api/internal/service/v1"
+	"github.com/metal-stack/security"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCreateMaintenanceWindow(t *testing.T) {
+	log := slog.Default()
+	ds := datastore.NewMemory(log)
+
+	require.NoError(t, ds.CreatePartition(&metal.Partition{Base: metal.Base{ID: "p1"}}))
+
+	userGetter := mockUserGetter{&security.User{
+		EMail: "operator@metal-stack.io",
+		Name:  "operator",
+	}}
+	container := restful.NewContainer().Add(NewMaintenanceWindow(log, ds, userGetter))
+
+	start := time.Now().Add(-time.Hour).UTC().Truncate(time.Second)
+	createRequest := v1.MaintenanceWindowCreateRequest{
+		Common: v1.Common{
+			Identifiable: v1.Identifiable{ID: "w1"},
+		},
+		MaintenanceWindowBase: v1.MaintenanceWindowBase{
+			Scope:       string(metal.MaintenanceScopeRack),
+			PartitionID: "p1",
+			RackID:      "r1",
+			Start:       start,
+			End:         start.Add(4 * time.Hour),
+			Reason:      "firmware day",
+		},
+	}
+	js, err := json.Marshal(createRequest)
+	require.NoError(t, err)
+	body := bytes.NewBuffer(js)
+	req := httptest.NewRequest("PUT", "/v1/maintenance-window", body)
+	req.Header.Add("Content-Type", "application/json")
+	container = injectAdmin(log, container, req)
+	w := httptest.NewRecorder()
+	container.ServeHTTP(w, req)
+
+	resp := w.Result()
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusCreated, resp.StatusCode, w.Body.String())
+	var result v1.MaintenanceWindowResponse
+	err = json.NewDecoder(resp.Body).Decode(&result)
+	require.NoError(t, err)
+	require.Equal(t, "w1", result.ID)
+	require.Equal(t, "operator@metal-stack.io", result.Issuer)
+	require.True(t, result.Active)
+
+	stored, err := ds.FindMaintenanceWindow("w1")
+	require.NoError(t, err)
+	require.Equal(t, metal.MaintenanceScopeRack, stored.Scope)
+	require.Equal(t, "firmware day", stored.Reason)
+}
+
+func TestCreateMaintenanceWindowUnknownPartition(t *testing.T) {
+	log := slog.Default()
+	ds := datastore.NewMemory(log)
+
+	container := restful.NewContainer().Add(NewMaintenanceWindow(log, ds, mockUserGetter{&security.User{}}))
+
+	createRequest := v1.MaintenanceWindowCreateRequest{
+		MaintenanceWindowBase: v1.MaintenanceWindowBase{
+			Scope:       string(metal.MaintenanceScopePartition),
+			PartitionID: "unknown",
+			Start:       time.Now(),
+			End:         time.Now().Add(time.Hour),
+			Reason:      "firmware day",
+		},
+	}
+	js, err := json.Marshal(createRequest)
+	require.NoError(t, err)
+	body := bytes.NewBuffer(js)
+	req := httptest.NewRequest("PUT", "/v1/maintenance-window", body)
+	req.Header.Add("Content-Type", "application/json")
+	container = injectAdmin(log, container, req)
+	w := httptest.NewRecorder()
+	container.ServeHTTP(w, req)
+
+	resp := w.Result()
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusNotFound, resp.StatusCode, w.Body.String())
+}
+
+func TestEvaluateMaintenanceWindows(t *testing.T) {
+	log := slog.Default()
+	ds := datastore.NewMemory(log)
+
+	now := time.Now()
+
+	require.NoError(t, ds.CreatePartition(&metal.Partition{Base: metal.Base{ID: "p1"}}))
+
+	for _, w := range []*metal.MaintenanceWindow{
+		{Base: metal.Base{ID: "active"}, Scope: metal.MaintenanceScopeRack, PartitionID: "p1", RackID: "r1", Start: now.Add(-time.Hour), End: now.Add(time.Hour), Reason: "firmware", Issuer: "operator"},
+		{Base: metal.Base{ID: "ended"}, Scope: metal.MaintenanceScopeRack, PartitionID: "p1", RackID: "r2", Start: now.Add(-2 * time.Hour), End: now.Add(-time.Hour), Reason: "firmware", Issuer: "operator"},
+	} {
+		require.NoError(t, ds.CreateMaintenanceWindow(w))
+	}
+
+	for _, m := range []*metal.Machine{
+		// gets tainted
+		{Base: metal.Base{ID: "1"}, PartitionID: "p1", RackID: "r1", State: metal.MachineState{Value: metal.AvailableState, MetalHammerVersion: "v1"}},
+		// locked machines remain locked
+		{Base: metal.Base{ID: "2"}, PartitionID: "p1", RackID: "r1", State: metal.MachineState{Value: metal.LockedState, Description: "broken"}},
+		// gets restored
+		{
+			Base:        metal.Base{ID: "3"},
+			PartitionID: "p1",
+			RackID:      "r2",
+			State:       metal.MachineState{Value: metal.TaintedState, Description: "maintenance", MetalHammerVersion: "v2"},
+			Maintenance: &metal.MachineMaintenance{WindowID: "ended", PreviousState: metal.MachineState{Value: metal.AvailableState, MetalHammerVersion: "v1"}},
+		},
+		// state was changed manually during maintenance, it is kept
+		{
+			Base:        metal.Base{ID: "4"},
+			PartitionID: "p1",
+			RackID:      "r2",
+			State:       metal.MachineState{Value: metal.LockedState, Description: "manual"},
+			Maintenance: &metal.MachineMaintenance{WindowID: "ended", PreviousState: metal.MachineState{Value: metal.AvailableState}},
+		},
+		// not targeted at all
+		{Base: metal.Base{ID: "5"}, PartitionID: "p2", RackID: "r1"},
+	} {
+		require.NoError(t, ds.CreateMachine(m))
+	}
+
+	err := EvaluateMaintenanceWindows(ds, log)
+	require.NoError(t, err)
+
+	tests := []struct {
+		id              string
+		wantState       metal.MachineState
+		wantMaintenance *metal.MachineMaintenance
+	}{
+		{
+			id: "1",
+			wantState: metal.MachineState{
+				Value:              metal.TaintedState,
+				Description:        `maintenance window "active" until ` + now.Add(time.Hour).Format(time.RFC3339) + ": firmware",
+				Issuer:             "operator",
+				MetalHammerVersion: "v1",
+			},
+			wantMaintenance: &metal.MachineMaintenance{WindowID: "active", PreviousState: metal.MachineState{Value: metal.AvailableState, MetalHammerVersion: "v1"}},
+		},
+		{
+			id:        "2",
+			wantState: metal.MachineState{Value: metal.LockedState, Description: "broken"},
+		},
+		{
+			id:        "3",
+			wantState: metal.MachineState{Value: metal.AvailableState, MetalHammerVersion: "v2"},
+		},
+		{
+			id:        "4",
+			wantState: metal.MachineState{Value: metal.LockedState, Description: "manual"},
+		},
+		{
+			id: "5",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.id, func(t *testing.T) {
+			m, err := ds.FindMachineByID(tt.id)
+			require.NoError(t, err)
+			require.Equal(t, tt.wantState, m.State)
+			require.Equal(t, tt.wantMaintenance, m.Maintenance)
+		})
+	}
+
+	// a second evaluation does not change anything
+	before, err := ds.FindMachineByID("1")
+	require.NoError(t, err)
+
+	err = EvaluateMaintenanceWindows(ds, log)
+	require.NoError(t, err)
+
+	after, err := ds.FindMachineByID("1")
+	require.NoError(t, err)
+	require.Equal(t, before.Changed, after.Changed)
+
+	require.NoError(t, ds.CreateProvisioningEventContainer(&metal.ProvisioningEventContainer{Base: metal.Base{ID: "1"}}))
+	resp, err := makeMachineResponse(after, ds)
+	require.NoError(t, err)
+	require.NotNil(t, resp.MaintenanceWindow)
+	require.Equal(t, "active", resp.MaintenanceWindow.ID)
+	require.True(t, resp.MaintenanceWindow.Active)
+}
@@ -32,7 +32,7 @@ type networkResource struct {
 }
 
 // NewNetwork returns a webservice for network specific endpoints.
-func NewNetwork(log *slog.Logger, ds *datastore.RethinkStore, ipamer ipam.IPAMer, mdc mdm.Client) *restful.WebService {
+func NewNetwork(log *slog.Logger, ds datastore.Store, ipamer ipam.IPAMer, mdc mdm.Client) *restful.WebService {
 	r := networkResource{
 		webResource: webResource{
 			log: log,
@@ -76,12 +76,13 @@ func (r *networkResource) webService() *restful.WebService {
 	ws.Route(ws.POST("/find").
 		To(viewer(r.findNetworks)).
 		Operation("findNetworks").
-		Doc("get all networks that match given properties").
+		Doc("get all networks that match given properties, the result can be paged").
+		Param(fieldsParameter(ws)).
 		Metadata(restfulspec.KeyOpenAPITags, tags).
 		Metadata(auditinghttp.Exclude, true).
 		Reads(v1.NetworkFindRequest{}).
 		Writes([]v1.NetworkResponse{}).
-		Returns(http.StatusOK, "OK", []v1.NetworkResponse{}).
+		ReturnsWithHeaders(http.StatusOK, "OK", []v1.NetworkResponse{}, pageHeaders).
 		DefaultReturns("Error", httperrors.HTTPErrorResponse{}))
 
 	ws.Route(ws.DELETE("/{id}").
@@ -123,6 +124,7 @@ func (r *networkResource) webService() *restful.WebService {
 		Reads(v1.NetworkAllocateRequest{}).
 		Returns(http.StatusCreated, "Created", v1.NetworkResponse{}).
 		Returns(http.StatusConflict, "Conflict", httperrors.HTTPErrorResponse{}).
+		Returns(http.StatusUnprocessableEntity, "Quota Exceeded", v1.QuotaExceededResponse{}).
 		DefaultReturns("Error", httperrors.HTTPErrorResponse{}))
 
 	ws.Route(ws.POST("/free/{id}").
@@ -221,7 +223,7 @@ func (r *networkResource) findNetworks(request *restful.Request, response *restf
 		result = append(result, v1.NewNetworkResponse(&nws[i], consumption))
 	}
 
-	r.send(request, response, http.StatusOK, result)
+	r.sendPage(request, response, result, "id", requestPayload.NextCursor(nws))
 }
 
 // TODO allow creation of networks with childprefixlength which are not privatesuper
@@ -581,6 +583,12 @@ func (r *networkResource) allocateNetwork(request *restful.Request, response *re
 		return
 	}
 
+	err = checkNetworkQuota(r.ds, project.GetProject())
+	if err != nil {
+		r.sendQuotaOrDefaultError(request, response, err)
+		return
+	}
+
 	partition, err := r.ds.FindPartition(partitionID)
 	if err != nil {
 		r.sendError(request, response, defaultError(err))
@@ -673,6 +681,13 @@ func (r *networkResource) allocateNetwork(request *restful.Request, response *re
 		return
 	}
 
+	err = recheckNetworkQuota(r.ds, project.GetProject(), nw.ID)
+	if err != nil {
+		r.rollbackNetworkAllocation(request, nw)
+		r.sendQuotaOrDefaultError(request, response, err)
+		return
+	}
+
 	consumption, err := r.getNetworkUsage(ctx, nw)
 	if err != nil {
 		r.sendError(request, response, defaultError(err))
@@ -682,6 +697,25 @@ func (r *networkResource) allocateNetwork(request *restful.Request, response *re
 	r.send(request, response, http.StatusCreated, v1.NewNetworkResponse(nw, consumption))
 }
 
+// rollbackNetworkAllocation releases a child network which was allocated by allocateNetwork.
+func (r *networkResource) rollbackNetworkAllocation(request *restful.Request, nw *metal.Network) {
+	err := r.ds.DeleteNetwork(nw)
+	if err != nil {
+		r.logger(request).Error("cannot delete network in datastore", "network", nw.ID, "error", err)
+		return
+	}
+	for _, prefix := range nw.Prefixes {
+		err = r.ipamer.ReleaseChildPrefix(request.Request.Context(), prefix)
+		if err != nil {
+			r.logger(request).Error("cannot release child prefix in ipam", "network", nw.ID, "prefix", prefix.String(), "error", err)
+		}
+	}
+	err = releaseVRF(r.ds, nw.Vrf)
+	if err != nil {
+		r.logger(request).Error("cannot release vrf", "network", nw.ID, "vrf", nw.Vrf, "error", err)
+	}
+}
+
 func (r *networkResource) createChildNetwork(ctx context.Context, nwSpec *metal.Network, parent *metal.Network, childLengths metal.ChildPrefixLength) (*metal.Network, error) {
 	vrf, err := acquireRandomVRF(r.ds)
 	if err != nil {
@@ -0,0 +1,138 @@
+package service
+
+import (
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/metal-stack/metal-api/cmd/metal-api/internal/datastore"
+	"github.com/metal-stack/metal-api/cmd/metal-api/internal/metal"
+	v1 "github.com/metal-stack/metal-api/cmd/metal-api/internal/service/v1"
+
+	restfulspec "github.com/emicklei/go-restful-openapi/v2"
+	restful "github.com/emicklei/go-restful/v3"
+	"github.com/metal-stack/metal-lib/httperrors"
+)
+
+type outboxResource struct {
+	webResource
+}
+
+// NewOutbox returns a webservice to inspect and replay the events of the outbox.
+func NewOutbox(log *slog.Logger, ds datastore.Store) *restful.WebService {
+	r := outboxResource{
+		webResource: webResource{
+			log: log,
+			ds:  ds,
+		},
+	}
+	return r.webService()
+}
+
+func (r *outboxResource) webService() *restful.WebService {
+	ws := new(restful.WebService)
+	ws.
+		Path(BasePath + "v1/outbox").
+		Consumes(restful.MIME_JSON).
+		Produces(restful.MIME_JSON)
+
+	tags := []string{"outbox"}
+
+	ws.Route(ws.GET("/{id}").
+		To(admin(r.findOutboxEvent)).
+		Operation("findOutboxEvent").
+		Doc("get outbox event by id").
+		Param(ws.PathParameter("id", "identifier of the outbox event").DataType("string")).
+		Metadata(restfulspec.KeyOpenAPITags, tags).
+		Writes(v1.OutboxEventResponse{}).
+		Returns(http.StatusOK, "OK", v1.OutboxEventResponse{}).
+		DefaultReturns("Error", httperrors.HTTPErrorResponse{}))
+
+	ws.Route(ws.POST("/find").
+		To(admin(r.findOutboxEvents)).
+		Operation("findOutboxEvents").
+		Doc("get all outbox events that match given properties").
+		Metadata(restfulspec.KeyOpenAPITags, tags).
+		Reads(v1.OutboxEventFindRequest{}).
+		Writes([]v1.OutboxEventResponse{}).
+		Returns(http.StatusOK, "OK", []v1.OutboxEventResponse{}).
+		DefaultReturns("Error", httperrors.HTTPErrorResponse{}))
+
+	ws.Route(ws.POST("/{id}/replay").
+		To(admin(r.replayOutboxEvent)).
+		Operation("replayOutboxEvent").
+		Doc("resets an outbox event, such that it is published again by the outbox relay. consumers drop the event if they already received it").
+		Param(ws.PathParameter("id", "identifier of the outbox event").DataType("string")).
+		Metadata(restfulspec.KeyOpenAPITags, tags).
+		Writes(v1.OutboxEventResponse{}).
+		Returns(http.StatusOK, "OK", v1.OutboxEventResponse{}).
+		Returns(http.StatusConflict, "Conflict", httperrors.HTTPErrorResponse{}).
+		DefaultReturns("Error", httperrors.HTTPErrorResponse{}))
+
+	return ws
+}
+
+func (r *outboxResource) findOutboxEvent(request *restful.Request, response *restful.Response) {
+	id := request.PathParameter("id")
+
+	e, err := r.ds.FindOutboxEvent(id)
+	if err != nil {
+		r.sendError(request, response, defaultError(err))
+		return
+	}
+
+	r.send(request, response, http.StatusOK, v1.NewOutboxEventResponse(e))
+}
+
+func (r *outboxResource) findOutboxEvents(request *restful.Request, response *restful.Response) {
+	var requestPayload v1.OutboxEventFindRequest
+	err := request.ReadEntity(&requestPayload)
+	if err != nil {
+		r.sendError(request, response, httperrors.BadRequest(err))
+		return
+	}
+
+	var es metal.OutboxEvents
+	err = r.ds.SearchOutboxEvents(&datastore.OutboxEventSearchQuery{
+		ID:    requestPayload.ID,
+		Topic: requestPayload.Topic,
+		State: requestPayload.State,
+	}, &es)
+	if err != nil {
+		r.sendError(request, response, defaultError(err))
+		return
+	}
+
+	result := []*v1.OutboxEventResponse{}
+	for i := range es {
+		if requestPayload.Stuck != nil && es[i].Stuck() != *requestPayload.Stuck {
+			continue
+		}
+		result = append(result, v1.NewOutboxEventResponse(&es[i]))
+	}
+
+	r.send(request, response, http.StatusOK, result)
+}
+
+func (r *outboxResource) replayOutboxEvent(request *restful.Request, response *restful.Response) {
+	id := request.PathParameter("id")
+
+	old, err := r.ds.FindOutboxEvent(id)
+	if err != nil {
+		r.sendError(request, response, defaultError(err))
+		return
+	}
+
+	e := *old
+	e.Replay(time.Now())
+
+	err = r.ds.UpdateOutboxEvent(old, &e)
+	if err != nil {
+		r.sendError(request, response, defaultError(err))
+		return
+	}
+
+	r.logger(request).Info("replaying outbox event", "id", e.ID, "topic", e.Topic, "previous state", old.State)
+
+	r.send(request, response, http.StatusOK, v1.NewOutboxEventResponse(&e))
+}
@@ -0,0 +1,258 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/metal-stack/metal-api/cmd/metal-api/internal/datastore"
+	"github.com/metal-stack/metal-api/cmd/metal-api/internal/metal"
+	"github.com/metal-stack/metal-lib/bus"
+	"github.com/metal-stack/metal-lib/pkg/pointer"
+)
+
+// OutboxConfig configures the delivery of outbox events.
+type OutboxConfig struct {
+	// MaxAttempts is the amount of attempts after which an event fails and needs to be replayed, 0 retries forever
+	MaxAttempts int
+	// Retention is the duration for which published events are kept, 0 keeps them forever
+	Retention time.Duration
+	// IPMISuperUser is used to resolve the ipmi credentials of machines which were deleted before their command was delivered
+	IPMISuperUser metal.MachineIPMISuperUser
+}
+
+// OutboxPublisher is a publisher which stores every event in the outbox of the datastore before it is published to the event bus.
+// Events which cannot be published immediately are retried by the relay with an exponential backoff.
+//
+// As the datastore does not support transactions, the event is stored right after the entity change it belongs to.
+// Events may be published more than once, their outbox id is used as deduplication id.
+// Credentials are not stored in the outbox, the ipmi credentials of machine commands are resolved when they are delivered.
+type OutboxPublisher struct {
+	log       *slog.Logger
+	ds        datastore.Store
+	publisher bus.Publisher
+	config    OutboxConfig
+}
+
+// NewOutboxPublisher returns a new outbox publisher which publishes to the given publisher.
+func NewOutboxPublisher(log *slog.Logger, ds datastore.Store, publisher bus.Publisher, config OutboxConfig) *OutboxPublisher {
+	return &OutboxPublisher{
+		log:       log,
+		ds:        ds,
+		publisher: publisher,
+		config:    config,
+	}
+}
+
+// Publish stores the event in the outbox and tries to publish it immediately, an error is only returned if the event could not be stored.
+func (o *OutboxPublisher) Publish(topic string, data any) error {
+	now := time.Now()
+
+	e, err := metal.NewOutboxEvent(topic, data, now)
+	if err != nil {
+		return err
+	}
+	e.Attempt(now)
+
+	err = o.ds.CreateOutboxEvent(e)
+	if err != nil {
+		return fmt.Errorf("cannot store event in outbox: %w", err)
+	}
+
+	err = o.deliver(e, now)
+	if err != nil {
+		o.log.Warn("unable to publish event, it is retried by the outbox relay", "id", e.ID, "topic", topic, "next attempt", e.NextAttempt, "error", err)
+	}
+
+	return nil
+}
+
+// CreateTopic creates a topic with the given name.
+func (o *OutboxPublisher) CreateTopic(topic string) error {
+	return o.publisher.CreateTopic(topic)
+}
+
+// Stop stops the underlying publisher.
+func (o *OutboxPublisher) Stop() {
+	o.publisher.Stop()
+}
+
+// Run relays the due outbox events in the given interval until the context is done.
+func (o *OutboxPublisher) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			err := o.Relay(time.Now())
+			if err != nil {
+				o.log.Error("unable to relay outbox events", "error", err)
+			}
+		}
+	}
+}
+
+// Relay publishes all pending events which are due and removes the published events which are older than the retention.
+// Events which are claimed by another metal-api instance in the meantime are skipped.
+func (o *OutboxPublisher) Relay(now time.Time) error {
+	var pending metal.OutboxEvents
+	err := o.ds.SearchOutboxEvents(&datastore.OutboxEventSearchQuery{State: pointer.Pointer(string(metal.OutboxEventStatePending))}, &pending)
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	for i := range pending {
+		old := &pending[i]
+		if !old.Due(now) {
+			continue
+		}
+
+		claimed := *old
+		claimed.Attempt(now)
+
+		err := o.ds.UpdateOutboxEvent(old, &claimed)
+		if err != nil {
+			if metal.IsConflict(err) {
+				continue
+			}
+			errs = append(errs, err)
+			continue
+		}
+
+		err = o.deliver(&claimed, now)
+		switch {
+		case err == nil:
+			o.log.Info("relayed outbox event", "id", claimed.ID, "topic", claimed.Topic, "attempts", claimed.Attempts)
+		case claimed.State == metal.OutboxEventStateFailed:
+			o.log.Error("giving up to publish event, it needs to be replayed", "id", claimed.ID, "topic", claimed.Topic, "attempts", claimed.Attempts, "error", err)
+		default:
+			o.log.Warn("unable to publish event", "id", claimed.ID, "topic", claimed.Topic, "next attempt", claimed.NextAttempt, "error", err)
+		}
+	}
+
+	if o.config.Retention > 0 {
+		err = o.prune(now.Add(-o.config.Retention))
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// deliver publishes the claimed event and stores the outcome.
+func (o *OutboxPublisher) deliver(claimed *metal.OutboxEvent, now time.Time) error {
+	result := *claimed
+
+	payload, err := o.payload(claimed)
+	if err == nil {
+		err = o.publisher.Publish(claimed.Topic, json.RawMessage(payload))
+	}
+	if err != nil {
+		result.DeliveryFailed(err, o.config.MaxAttempts)
+	} else {
+		result.Delivered(now)
+	}
+
+	updateErr := o.ds.UpdateOutboxEvent(claimed, &result)
+	if updateErr != nil {
+		// in case the event was published, it is published again once it is due, consumers drop it by its deduplication id
+		o.log.Error("unable to store outcome of outbox event", "id", claimed.ID, "error", updateErr)
+	}
+	*claimed = result
+
+	if err == nil && claimed.MachineCommandID != "" {
+		o.startMachineCommandDeadline(claimed.MachineCommandID, now)
+	}
+
+	return err
+}
+
+// startMachineCommandDeadline restarts the deadline of the delivered machine command, failures are only logged
+// because the command then times out after the deadline which started when it was queued.
+func (o *OutboxPublisher) startMachineCommandDeadline(id string, now time.Time) {
+	old, err := o.ds.FindMachineCommandExecution(id)
+	if err != nil {
+		o.log.Error("unable to find machine command of outbox event", "id", id, "error", err)
+		return
+	}
+
+	published := *old
+	if !published.Published(now) {
+		return
+	}
+
+	err = o.ds.UpdateMachineCommandExecution(old, &published)
+	if err != nil && !metal.IsConflict(err) {
+		o.log.Error("unable to start deadline of machine command", "id", id, "error", err)
+	}
+}
+
+// payload returns the payload of the event to publish, the ipmi credentials of machine commands are added from the current machine.
+func (o *OutboxPublisher) payload(e *metal.OutboxEvent) (string, error) {
+	if e.IPMIMachineID == "" {
+		return e.Payload, nil
+	}
+
+	var evt metal.MachineEvent
+	err := json.Unmarshal([]byte(e.Payload), &evt)
+	if err != nil {
+		return "", fmt.Errorf("cannot unmarshal machine event: %w", err)
+	}
+	if evt.Cmd == nil || evt.Cmd.IPMI == nil {
+		return e.Payload, nil
+	}
+
+	m, err := o.ds.FindMachineByID(e.IPMIMachineID)
+	switch {
+	case err == nil:
+		withIPMISuperUserFallback(m, o.config.IPMISuperUser)
+		evt.Cmd.IPMI.User = m.IPMI.User
+		evt.Cmd.IPMI.Password = m.IPMI.Password
+	case metal.IsNotFound(err):
+		// the machine was deleted in the meantime, it can only be managed with the super user
+		if o.config.IPMISuperUser.IsEnabled() && evt.Cmd.IPMI.User == o.config.IPMISuperUser.User() {
+			evt.Cmd.IPMI.Password = o.config.IPMISuperUser.Password()
+		} else {
+			o.log.Warn("machine of command not found, publishing it without ipmi password", "id", e.ID, "machineID", e.IPMIMachineID)
+		}
+	default:
+		return "", err
+	}
+
+	payload, err := json.Marshal(evt)
+	if err != nil {
+		return "", fmt.Errorf("cannot marshal machine event: %w", err)
+	}
+
+	return string(payload), nil
+}
+
+func (o *OutboxPublisher) prune(before time.Time) error {
+	var published metal.OutboxEvents
+	err := o.ds.SearchOutboxEvents(&datastore.OutboxEventSearchQuery{State: pointer.Pointer(string(metal.OutboxEventStatePublished))}, &published)
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	for i := range published {
+		e := &published[i]
+		if e.Published == nil || !e.Published.Before(before) {
+			continue
+		}
+		err := o.ds.DeleteOutboxEvent(e)
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return errors.Join(errs...)
+}
@@ -0,0 +1,205 @@
+package service
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/emicklei/go-restful/v3"
+	"github.com/metal-stack/metal-api/cmd/metal-api/internal/datastore"
+	"github.com/metal-stack/metal-api/cmd/metal-api/internal/metal"
+	v1 "github.com/metal-stack/metal-api/cmd/metal-api/internal/service/v1"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOutboxPublisher(t *testing.T) {
+	var (
+		log       = slog.Default()
+		ds        = datastore.NewMemory(log)
+		available = false
+		published []metal.MachineEvent
+	)
+
+	pub := &emptyPublisher{doPublish: func(topic string, data any) error {
+		if !available {
+			return errors.New("nsqd unavailable")
+		}
+		require.Equal(t, "p1-machine", topic)
+		raw, ok := data.(json.RawMessage)
+		require.True(t, ok)
+		var evt metal.MachineEvent
+		require.NoError(t, json.Unmarshal(raw, &evt))
+		published = append(published, evt)
+		return nil
+	}}
+
+	o := NewOutboxPublisher(log, ds, pub, OutboxConfig{MaxAttempts: 3, Retention: time.Hour})
+
+	m := &metal.Machine{Base: metal.Base{ID: "m1"}, PartitionID: "p1", IPMI: metal.IPMI{User: "admin", Password: "secret"}}
+	require.NoError(t, ds.CreateMachine(m))
+	require.NoError(t, publishMachineCmd(log, ds, m, o, metal.MachineDiskCmd))
+
+	var es metal.OutboxEvents
+	require.NoError(t, ds.SearchOutboxEvents(&datastore.OutboxEventSearchQuery{}, &es))
+	require.Len(t, es, 1)
+	e := es[0]
+	require.Equal(t, metal.OutboxEventStatePending, e.State)
+	require.Equal(t, 1, e.Attempts)
+	require.Equal(t, "nsqd unavailable", e.LastError)
+	require.Equal(t, "m1", e.IPMIMachineID)
+	require.NotContains(t, e.Payload, "secret")
+
+	now := time.Now()
+
+	// the event is not due before the backoff passed
+	require.NoError(t, o.Relay(now))
+	require.Empty(t, published)
+
+	// the relay publishes the event once the event bus is available again
+	available = true
+	require.NoError(t, o.Relay(now.Add(metal.OutboxBackoff(1))))
+	require.Len(t, published, 1)
+	require.Equal(t, e.ID, published[0].DeduplicationID)
+	require.Equal(t, metal.MachineDiskCmd, published[0].Cmd.Command)
+	require.Equal(t, "admin", published[0].Cmd.IPMI.User)
+	require.Equal(t, "secret", published[0].Cmd.IPMI.Password)
+
+	found, err := ds.FindOutboxEvent(e.ID)
+	require.NoError(t, err)
+	require.Equal(t, metal.OutboxEventStatePublished, found.State)
+	require.Equal(t, 2, found.Attempts)
+
+	// the deadline of the command starts when it is delivered
+	require.Equal(t, published[0].Cmd.ID, found.MachineCommandID)
+	cmd, err := ds.FindMachineCommandExecution(found.MachineCommandID)
+	require.NoError(t, err)
+	require.WithinDuration(t, now.Add(metal.OutboxBackoff(1)+metal.DefaultMachineCommandTimeout), cmd.Deadline, time.Millisecond)
+
+	// published events are not relayed again and are removed after the retention
+	require.NoError(t, o.Relay(now.Add(30*time.Minute)))
+	require.Len(t, published, 1)
+	_, err = ds.FindOutboxEvent(e.ID)
+	require.NoError(t, err)
+
+	require.NoError(t, o.Relay(now.Add(2*time.Hour)))
+	_, err = ds.FindOutboxEvent(e.ID)
+	require.True(t, metal.IsNotFound(err))
+}
+
+func TestOutboxPublisher_DeletedMachine(t *testing.T) {
+	var (
+		log       = slog.Default()
+		ds        = datastore.NewMemory(log)
+		published []metal.MachineEvent
+	)
+
+	pwdFile := filepath.Join(t.TempDir(), "superuser")
+	require.NoError(t, os.WriteFile(pwdFile, []byte("superuser-secret"), 0600))
+
+	pub := &emptyPublisher{doPublish: func(topic string, data any) error {
+		var evt metal.MachineEvent
+		require.NoError(t, json.Unmarshal(data.(json.RawMessage), &evt))
+		published = append(published, evt)
+		return nil
+	}}
+
+	ipmiSuperUser := metal.NewIPMISuperUser(log, pwdFile)
+	o := NewOutboxPublisher(log, ds, pub, OutboxConfig{IPMISuperUser: ipmiSuperUser})
+
+	// the machine was already removed from the database, so only the super user can manage it
+	m := &metal.Machine{Base: metal.Base{ID: "m1"}, PartitionID: "p1"}
+	withIPMISuperUserFallback(m, ipmiSuperUser)
+	require.NoError(t, publishMachineCmd(log, ds, m, o, metal.MachineResetCmd))
+
+	var es metal.OutboxEvents
+	require.NoError(t, ds.SearchOutboxEvents(&datastore.OutboxEventSearchQuery{}, &es))
+	require.Len(t, es, 1)
+	require.NotContains(t, es[0].Payload, "superuser-secret")
+
+	require.Len(t, published, 1)
+	require.Equal(t, "root", published[0].Cmd.IPMI.User)
+	require.Equal(t, "superuser-secret", published[0].Cmd.IPMI.Password)
+}
+
+func TestOutboxPublisher_MaxAttempts(t *testing.T) {
+	var (
+		log = slog.Default()
+		ds  = datastore.NewMemory(log)
+	)
+
+	pub := &emptyPublisher{doPublish: func(topic string, data any) error {
+		return errors.New("nsqd unavailable")
+	}}
+	o := NewOutboxPublisher(log, ds, pub, OutboxConfig{MaxAttempts: 2})
+
+	require.NoError(t, o.Publish(metal.TopicAllocation.Name, &metal.AllocationEvent{MachineID: "m1"}))
+
+	now := time.Now()
+	require.NoError(t, o.Relay(now.Add(time.Hour)))
+
+	var es metal.OutboxEvents
+	require.NoError(t, ds.SearchOutboxEvents(&datastore.OutboxEventSearchQuery{}, &es))
+	require.Len(t, es, 1)
+	require.Equal(t, metal.OutboxEventStateFailed, es[0].State)
+	require.Equal(t, 2, es[0].Attempts)
+
+	// failed events are not relayed anymore
+	require.NoError(t, o.Relay(now.Add(2*time.Hour)))
+	found, err := ds.FindOutboxEvent(es[0].ID)
+	require.NoError(t, err)
+	require.Equal(t, 2, found.Attempts)
+}
+
+func TestReplayOutboxEvent(t *testing.T) {
+	var (
+		log = slog.Default()
+		ds  = datastore.NewMemory(log)
+	)
+
+	require.NoError(t, ds.CreateOutboxEvent(&metal.OutboxEvent{Base: metal.Base{ID: "e1"}, Topic: "allocation", State: metal.OutboxEventStateFailed, Attempts: 10, LastError: "nsqd unavailable"}))
+	require.NoError(t, ds.CreateOutboxEvent(&metal.OutboxEvent{Base: metal.Base{ID: "e2"}, Topic: "allocation", State: metal.OutboxEventStatePublished, Attempts: 1}))
+
+	container := restful.NewContainer().Add(NewOutbox(log, ds))
+
+	js, err := json.Marshal(v1.OutboxEventFindRequest{Stuck: new(true)})
+	require.NoError(t, err)
+	req := httptest.NewRequest("POST", "/v1/outbox/find", bytes.NewBuffer(js))
+	req.Header.Add("Content-Type", "application/json")
+	container = injectAdmin(log, container, req)
+	w := httptest.NewRecorder()
+	container.ServeHTTP(w, req)
+
+	resp := w.Result()
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode, w.Body.String())
+	var stuck []v1.OutboxEventResponse
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&stuck))
+	require.Len(t, stuck, 1)
+	require.Equal(t, "e1", stuck[0].ID)
+	require.Equal(t, "nsqd unavailable", stuck[0].LastError)
+
+	req = httptest.NewRequest("POST", "/v1/outbox/e1/replay", nil)
+	req.Header.Add("Content-Type", "application/json")
+	container = injectAdmin(log, container, req)
+	w = httptest.NewRecorder()
+	container.ServeHTTP(w, req)
+
+	resp = w.Result()
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode, w.Body.String())
+	var replayed v1.OutboxEventResponse
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&replayed))
+	require.Equal(t, string(metal.OutboxEventStatePending), replayed.State)
+	require.Equal(t, 0, replayed.Attempts)
+
+	found, err := ds.FindOutboxEvent("e1")
+	require.NoError(t, err)
+	require.True(t, found.Due(time.Now()))
+}
@@ -0,0 +1,129 @@
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	restful "github.com/emicklei/go-restful/v3"
+	"github.com/metal-stack/metal-api/cmd/metal-api/internal/datastore"
+	"github.com/metal-stack/metal-lib/httperrors"
+)
+
+// NextCursorHeader is the response header which contains the cursor of the next page of a paged search,
+// it is not set on the last page.
+const NextCursorHeader = "X-Next-Cursor"
+
+var pageHeaders = map[string]restful.Header{
+	NextCursorHeader: {
+		Items:       &restful.Items{Type: "string"},
+		Description: "the cursor to continue with the next page, only set if a limit was given and further entities may exist",
+	},
+}
+
+// fieldsParameter allows clients to reduce the response to the fields they are interested in.
+func fieldsParameter(ws *restful.WebService) *restful.Parameter {
+	return ws.QueryParameter("fields", "comma separated list of the top-level fields to return, the identifying field is always returned. all fields are returned if not set").DataType("string")
+}
+
+// pagingFromQuery reads the paging query parameters of a list request.
+func pagingFromQuery(rq *restful.Request) (datastore.Paging, error) {
+	var (
+		p   datastore.Paging
+		err error
+	)
+
+	parseUint := func(name string) (*uint64, error) {
+		v := rq.QueryParameter(name)
+		if v == "" {
+			return nil, nil
+		}
+		n, err := strconv.ParseUint(v, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid query parameter %s: %w", name, err)
+		}
+		return &n, nil
+	}
+
+	p.Limit, err = parseUint("limit")
+	if err != nil {
+		return p, err
+	}
+	p.Offset, err = parseUint("offset")
+	if err != nil {
+		return p, err
+	}
+	if v := rq.QueryParameter("sort_by"); v != "" {
+		p.SortBy = &v
+	}
+	if v := rq.QueryParameter("sort_descending"); v != "" {
+		desc, err := strconv.ParseBool(v)
+		if err != nil {
+			return p, fmt.Errorf("invalid query parameter sort_descending: %w", err)
+		}
+		p.SortDescending = &desc
+	}
+	if v := rq.QueryParameter("cursor"); v != "" {
+		p.Cursor = &v
+	}
+
+	return p, nil
+}
+
+// sendPage sends a page of a search result. The cursor of the next page is sent as header and the entities
+// are reduced to the fields which were requested with the fields query parameter and their identifying field.
+func (w *webResource) sendPage(rq *restful.Request, rsp *restful.Response, entities any, idField, next string) {
+	if next != "" {
+		rsp.AddHeader(NextCursorHeader, next)
+	}
+
+	var fields []string
+	for _, param := range rq.QueryParameters("fields") {
+		for f := range strings.SplitSeq(param, ",") {
+			if f = strings.TrimSpace(f); f != "" {
+				fields = append(fields, f)
+			}
+		}
+	}
+	if len(fields) == 0 {
+		w.send(rq, rsp, http.StatusOK, entities)
+		return
+	}
+
+	selected, err := selectFields(entities, append(fields, idField))
+	if err != nil {
+		w.sendError(rq, rsp, httperrors.InternalServerError(err))
+		return
+	}
+
+	w.send(rq, rsp, http.StatusOK, selected)
+}
+
+// selectFields reduces the json representation of the given entities to the given top-level fields.
+func selectFields(entities any, fields []string) ([]map[string]json.RawMessage, error) {
+	raw, err := json.Marshal(entities)
+	if err != nil {
+		return nil, err
+	}
+
+	var all []map[string]json.RawMessage
+	err = json.Unmarshal(raw, &all)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]map[string]json.RawMessage, 0, len(all))
+	for _, e := range all {
+		selected := map[string]json.RawMessage{}
+		for _, f := range fields {
+			if v, ok := e[f]; ok {
+				selected[f] = v
+			}
+		}
+		result = append(result, selected)
+	}
+
+	return result, nil
+}
@@ -0,0 +1,78 @@
+package service
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/emicklei/go-restful/v3"
+	"github.com/metal-stack/metal-api/cmd/metal-api/internal/datastore"
+	"github.com/metal-stack/metal-api/cmd/metal-api/internal/metal"
+	"github.com/metal-stack/metal-lib/bus"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFindMachinesPaged(t *testing.T) {
+	log := slog.Default()
+	ds := datastore.NewMemory(log)
+
+	for _, id := range []string{"m3", "m1", "m4", "m2"} {
+		require.NoError(t, ds.CreateMachine(&metal.Machine{Base: metal.Base{ID: id, Name: "name-" + id}, PartitionID: "p1"}))
+	}
+
+	ws, err := NewMachine(log, ds, &emptyPublisher{}, bus.DirectEndpoints(), nil, nil, nil, nil, 0, nil, metal.DisabledIPMISuperUser(), 100)
+	require.NoError(t, err)
+	container := restful.NewContainer().Add(ws)
+
+	send := func(method, path string, payload any) *httptest.ResponseRecorder {
+		var body bytes.Buffer
+		if payload != nil {
+			require.NoError(t, json.NewEncoder(&body).Encode(payload))
+		}
+		req := httptest.NewRequest(method, path, &body)
+		req.Header.Add("Content-Type", "application/json")
+		container = injectViewer(log, container, req)
+		w := httptest.NewRecorder()
+		container.ServeHTTP(w, req)
+		return w
+	}
+
+	var pages [][]map[string]any
+	q := datastore.MachineSearchQuery{PartitionID: new("p1"), Paging: datastore.Paging{Limit: new(uint64(3))}}
+	for {
+		w := send("POST", "/v1/machine/find?fields=name", q)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+		var page []map[string]any
+		require.NoError(t, json.NewDecoder(w.Body).Decode(&page))
+		pages = append(pages, page)
+
+		next := w.Header().Get(NextCursorHeader)
+		if next == "" {
+			break
+		}
+		q.Cursor = &next
+	}
+	require.Equal(t, [][]map[string]any{
+		{{"id": "m1", "name": "name-m1"}, {"id": "m2", "name": "name-m2"}, {"id": "m3", "name": "name-m3"}},
+		{{"id": "m4", "name": "name-m4"}},
+	}, pages)
+
+	w := send("GET", "/v1/machine?limit=1&sort_by=name&sort_descending=true", nil)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	require.NotEmpty(t, w.Header().Get(NextCursorHeader))
+	var machines []map[string]any
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&machines))
+	require.Len(t, machines, 1)
+	require.Equal(t, "m4", machines[0]["id"])
+	require.Contains(t, machines[0], "partition")
+
+	w = send("GET", "/v1/machine?sort_by=color", nil)
+	require.Equal(t, http.StatusBadRequest, w.Code, w.Body.String())
+
+	w = send("GET", "/v1/machine?limit=-1", nil)
+	require.Equal(t, http.StatusBadRequest, w.Code, w.Body.String())
+}
@@ -5,6 +5,9 @@ import (
 	"fmt"
 	"log/slog"
 	"net/http"
+	"slices"
+	"strings"
+	"time"
 
 	"github.com/metal-stack/metal-api/cmd/metal-api/internal/datastore"
 	"github.com/metal-stack/metal-api/cmd/metal-api/internal/issues"
@@ -30,7 +33,7 @@ type partitionResource struct {
 }
 
 // NewPartition returns a webservice for partition specific endpoints.
-func NewPartition(log *slog.Logger, ds *datastore.RethinkStore, tc TopicCreator) *restful.WebService {
+func NewPartition(log *slog.Logger, ds datastore.Store, tc TopicCreator) *restful.WebService {
 	r := partitionResource{
 		webResource: webResource{
 			log: log,
@@ -100,6 +103,47 @@ func (r *partitionResource) webService() *restful.WebService {
 		Returns(http.StatusConflict, "Conflict", httperrors.HTTPErrorResponse{}).
 		DefaultReturns("Error", httperrors.HTTPErrorResponse{}))
 
+	ws.Route(ws.GET("/{id}/bootconfig-rollout").
+		To(r.findBootConfigurationRollout).
+		Operation("findPartitionBootConfigurationRollout").
+		Doc("get the current or last boot configuration rollout of a Partition").
+		Param(ws.PathParameter("id", "identifier of the Partition").DataType("string")).
+		Metadata(restfulspec.KeyOpenAPITags, tags).
+		Writes(v1.PartitionBootConfigurationRolloutResponse{}).
+		Returns(http.StatusOK, "OK", v1.PartitionBootConfigurationRolloutResponse{}).
+		DefaultReturns("Error", httperrors.HTTPErrorResponse{}))
+
+	ws.Route(ws.POST("/{id}/bootconfig-rollout").
+		To(admin(r.startBootConfigurationRollout)).
+		Operation("startPartitionBootConfigurationRollout").
+		Doc("starts the rollout of a new boot configuration to a subset of the machines of a Partition, the rollout is rolled back automatically if too many of them fail. if a rollout is already running a conflict is returned").
+		Param(ws.PathParameter("id", "identifier of the Partition").DataType("string")).
+		Metadata(restfulspec.KeyOpenAPITags, tags).
+		Reads(v1.PartitionBootConfigurationRolloutRequest{}).
+		Returns(http.StatusCreated, "Created", v1.PartitionBootConfigurationRolloutResponse{}).
+		Returns(http.StatusConflict, "Conflict", httperrors.HTTPErrorResponse{}).
+		DefaultReturns("Error", httperrors.HTTPErrorResponse{}))
+
+	ws.Route(ws.POST("/{id}/bootconfig-rollout/promote").
+		To(admin(r.promoteBootConfigurationRollout)).
+		Operation("promotePartitionBootConfigurationRollout").
+		Doc("finishes the running boot configuration rollout of a Partition and makes the new boot configuration the one of the Partition").
+		Param(ws.PathParameter("id", "identifier of the Partition").DataType("string")).
+		Metadata(restfulspec.KeyOpenAPITags, tags).
+		Writes(v1.PartitionBootConfigurationRolloutResponse{}).
+		Returns(http.StatusOK, "OK", v1.PartitionBootConfigurationRolloutResponse{}).
+		DefaultReturns("Error", httperrors.HTTPErrorResponse{}))
+
+	ws.Route(ws.DELETE("/{id}/bootconfig-rollout").
+		To(admin(r.abortBootConfigurationRollout)).
+		Operation("abortPartitionBootConfigurationRollout").
+		Doc("aborts the running boot configuration rollout of a Partition, the canaries boot with the boot configuration of the Partition again").
+		Param(ws.PathParameter("id", "identifier of the Partition").DataType("string")).
+		Metadata(restfulspec.KeyOpenAPITags, tags).
+		Writes(v1.PartitionBootConfigurationRolloutResponse{}).
+		Returns(http.StatusOK, "OK", v1.PartitionBootConfigurationRolloutResponse{}).
+		DefaultReturns("Error", httperrors.HTTPErrorResponse{}))
+
 	ws.Route(ws.POST("/capacity").
 		To(r.partitionCapacity).
 		Operation("partitionCapacity").
@@ -197,6 +241,12 @@ func (r *partitionResource) createPartition(request *restful.Request, response *
 		commandLine = *requestPayload.PartitionBootConfiguration.CommandLine
 	}
 
+	overrides, err := toBootConfigurationOverrides(requestPayload.BootConfigurationOverrides)
+	if err != nil {
+		r.sendError(request, response, httperrors.BadRequest(err))
+		return
+	}
+
 	var dnsServers metal.DNSServers
 	if len(requestPayload.DNSServers) != 0 {
 		for _, s := range requestPayload.DNSServers {
@@ -224,6 +274,18 @@ func (r *partitionResource) createPartition(request *restful.Request, response *
 		return
 	}
 
+	placementStrategy, err := toPartitionPlacementStrategy(requestPayload.PlacementStrategy)
+	if err != nil {
+		r.sendError(request, response, httperrors.BadRequest(err))
+		return
+	}
+
+	placementSpreadLevel, err := toLocationLevel(requestPayload.PlacementSpreadLevel)
+	if err != nil {
+		r.sendError(request, response, httperrors.BadRequest(err))
+		return
+	}
+
 	p := &metal.Partition{
 		Base: metal.Base{
 			ID:          requestPayload.ID,
@@ -237,8 +299,12 @@ func (r *partitionResource) createPartition(request *restful.Request, response *
 			KernelURL:   kernelURL,
 			CommandLine: commandLine,
 		},
-		DNSServers: dnsServers,
-		NTPServers: ntpServers,
+		BootConfigurationOverrides: overrides,
+		RackPowerBudget:            toRackPowerBudget(requestPayload.RackPowerBudget),
+		PlacementStrategy:          placementStrategy,
+		PlacementSpreadLevel:       placementSpreadLevel,
+		DNSServers:                 dnsServers,
+		NTPServers:                 ntpServers,
 	}
 
 	fqn := metal.TopicMachine.GetFQN(p.GetID())
@@ -256,6 +322,75 @@ func (r *partitionResource) createPartition(request *restful.Request, response *
 	r.send(request, response, http.StatusCreated, v1.NewPartitionResponse(p))
 }
 
+func toRackPowerBudget(b *v1.PartitionRackPowerBudget) *metal.RackPowerBudget {
+	if b == nil {
+		return nil
+	}
+	return &metal.RackPowerBudget{
+		DefaultWatts: b.DefaultWatts,
+		RackWatts:    b.RackWatts,
+	}
+}
+
+func toPartitionPlacementStrategy(s *string) (metal.PlacementStrategyType, error) {
+	if s == nil || *s == "" {
+		return "", nil
+	}
+	strategy, err := metal.PlacementStrategyFrom(*s)
+	if err != nil {
+		return "", err
+	}
+	if strategy == metal.PlacementStrategySwitchAffinity {
+		return "", fmt.Errorf("placement strategy %s can only be requested by allocations", strategy)
+	}
+	return strategy, nil
+}
+
+func toLocationLevel(s *string) (metal.LocationLevel, error) {
+	if s == nil || *s == "" {
+		return "", nil
+	}
+	return metal.LocationLevelFrom(*s)
+}
+
+func toBootConfigurationOverrides(overrides []v1.PartitionBootConfigurationOverride) (metal.BootConfigurationOverrides, error) {
+	var res metal.BootConfigurationOverrides
+	for _, o := range overrides {
+		override := metal.BootConfigurationOverride{
+			Scope:             metal.BootConfigurationOverrideScope(o.Scope),
+			Selector:          o.Selector,
+			CommandLineAppend: pointer.SafeDeref(o.CommandLineAppend),
+			BootConfiguration: metal.BootConfiguration{
+				ImageURL:    pointer.SafeDeref(o.PartitionBootConfiguration.ImageURL),
+				KernelURL:   pointer.SafeDeref(o.PartitionBootConfiguration.KernelURL),
+				CommandLine: pointer.SafeDeref(o.PartitionBootConfiguration.CommandLine),
+			},
+		}
+
+		if override.BootConfiguration.ImageURL != "" {
+			err := checkImageURL("image", override.BootConfiguration.ImageURL)
+			if err != nil {
+				return nil, err
+			}
+		}
+		if override.BootConfiguration.KernelURL != "" {
+			err := checkImageURL("kernel", override.BootConfiguration.KernelURL)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		res = append(res, override)
+	}
+
+	err := res.Validate()
+	if err != nil {
+		return nil, err
+	}
+
+	return res, nil
+}
+
 func (r *partitionResource) deletePartition(request *restful.Request, response *restful.Response) {
 	id := request.PathParameter("id")
 
@@ -302,26 +437,62 @@ func (r *partitionResource) updatePartition(request *restful.Request, response *
 	if requestPayload.Labels != nil {
 		newPartition.Labels = requestPayload.Labels
 	}
-	if requestPayload.PartitionBootConfiguration.ImageURL != nil {
-		err = checkImageURL("image", *requestPayload.PartitionBootConfiguration.ImageURL)
+	if bc := requestPayload.PartitionBootConfiguration; bc != nil {
+		if rollout := oldPartition.BootConfigurationRollout; rollout != nil && rollout.State == metal.BootConfigurationRolloutStateRunning {
+			r.sendError(request, response, defaultError(metal.Conflict("boot configuration of partition %s can not be changed while a boot configuration rollout is running", oldPartition.ID)))
+			return
+		}
+
+		if bc.ImageURL != nil {
+			err = checkImageURL("image", *bc.ImageURL)
+			if err != nil {
+				r.sendError(request, response, httperrors.BadRequest(err))
+				return
+			}
+
+			newPartition.BootConfiguration.ImageURL = *bc.ImageURL
+		}
+
+		if bc.KernelURL != nil {
+			err = checkImageURL("kernel", *bc.KernelURL)
+			if err != nil {
+				r.sendError(request, response, httperrors.BadRequest(err))
+				return
+			}
+			newPartition.BootConfiguration.KernelURL = *bc.KernelURL
+		}
+		if bc.CommandLine != nil {
+			newPartition.BootConfiguration.CommandLine = *bc.CommandLine
+		}
+	}
+
+	if requestPayload.BootConfigurationOverrides != nil {
+		overrides, err := toBootConfigurationOverrides(requestPayload.BootConfigurationOverrides)
 		if err != nil {
 			r.sendError(request, response, httperrors.BadRequest(err))
 			return
 		}
+		newPartition.BootConfigurationOverrides = overrides
+	}
 
-		newPartition.BootConfiguration.ImageURL = *requestPayload.PartitionBootConfiguration.ImageURL
+	if requestPayload.RackPowerBudget != nil {
+		newPartition.RackPowerBudget = toRackPowerBudget(requestPayload.RackPowerBudget)
 	}
 
-	if requestPayload.PartitionBootConfiguration.KernelURL != nil {
-		err = checkImageURL("kernel", *requestPayload.PartitionBootConfiguration.KernelURL)
+	if requestPayload.PlacementStrategy != nil {
+		newPartition.PlacementStrategy, err = toPartitionPlacementStrategy(requestPayload.PlacementStrategy)
 		if err != nil {
 			r.sendError(request, response, httperrors.BadRequest(err))
 			return
 		}
-		newPartition.BootConfiguration.KernelURL = *requestPayload.PartitionBootConfiguration.KernelURL
 	}
-	if requestPayload.PartitionBootConfiguration.CommandLine != nil {
-		newPartition.BootConfiguration.CommandLine = *requestPayload.PartitionBootConfiguration.CommandLine
+
+	if requestPayload.PlacementSpreadLevel != nil {
+		newPartition.PlacementSpreadLevel, err = toLocationLevel(requestPayload.PlacementSpreadLevel)
+		if err != nil {
+			r.sendError(request, response, httperrors.BadRequest(err))
+			return
+		}
 	}
 
 	if requestPayload.DNSServers != nil {
@@ -369,7 +540,7 @@ func (r *partitionResource) partitionCapacity(request *restful.Request, response
 		return
 	}
 
-	partitionCapacities, err := r.calcPartitionCapacity(&requestPayload)
+	partitionCapacities, err := PartitionCapacity(r.ds, &requestPayload)
 	if err != nil {
 		r.sendError(request, response, httperrors.BadRequest(err))
 		return
@@ -378,7 +549,8 @@ func (r *partitionResource) partitionCapacity(request *restful.Request, response
 	r.send(request, response, http.StatusOK, partitionCapacities)
 }
 
-func (r *partitionResource) calcPartitionCapacity(pcr *v1.PartitionCapacityRequest) ([]v1.PartitionCapacity, error) {
+// PartitionCapacity calculates the machine capacities per partition and size, the request may be nil to calculate the capacities of all partitions.
+func PartitionCapacity(ds datastore.Store, pcr *v1.PartitionCapacityRequest) ([]v1.PartitionCapacity, error) {
 	var (
 		ps    metal.Partitions
 		ms    metal.Machines
@@ -391,7 +563,7 @@ func (r *partitionResource) calcPartitionCapacity(pcr *v1.PartitionCapacityReque
 	)
 
 	if pcr != nil && pcr.ID != nil {
-		p, err := r.ds.FindPartition(*pcr.ID)
+		p, err := ds.FindPartition(*pcr.ID)
 		if err != nil {
 			return nil, err
 		}
@@ -401,7 +573,7 @@ func (r *partitionResource) calcPartitionCapacity(pcr *v1.PartitionCapacityReque
 		allMachineQuery.PartitionID = pcr.ID
 	} else {
 		var err error
-		ps, err = r.ds.ListPartitions()
+		ps, err = ds.ListPartitions()
 		if err != nil {
 			return nil, err
 		}
@@ -411,28 +583,28 @@ func (r *partitionResource) calcPartitionCapacity(pcr *v1.PartitionCapacityReque
 		machineQuery.SizeID = pcr.Size
 	}
 
-	err := r.ds.SearchMachines(&machineQuery, &ms)
+	err := ds.SearchMachines(&machineQuery, &ms)
 	if err != nil {
 		return nil, err
 	}
 
 	// if filtered on partition get all without more filters for issues evaluation
-	err = r.ds.SearchMachines(&allMachineQuery, &allMs)
+	err = ds.SearchMachines(&allMachineQuery, &allMs)
 	if err != nil {
 		return nil, err
 	}
 
-	ecs, err := r.ds.ListProvisioningEventContainers()
+	ecs, err := ds.ListProvisioningEventContainers()
 	if err != nil {
 		return nil, fmt.Errorf("unable to fetch provisioning event containers: %w", err)
 	}
 
-	sizes, err := r.ds.ListSizes()
+	sizes, err := ds.ListSizes()
 	if err != nil {
 		return nil, fmt.Errorf("unable to list sizes: %w", err)
 	}
 
-	sizeReservations, err := r.ds.ListSizeReservations()
+	sizeReservations, err := ds.ListSizeReservations()
 	if err != nil {
 		return nil, fmt.Errorf("unable to list size reservations: %w", err)
 	}
@@ -446,12 +618,30 @@ func (r *partitionResource) calcPartitionCapacity(pcr *v1.PartitionCapacityReque
 		return nil, fmt.Errorf("unable to calculate machine issues: %w", err)
 	}
 
+	var (
+		failureDomainLevel metal.LocationLevel
+		locationsByID      metal.LocationMap
+	)
+	if pcr != nil && pcr.FailureDomainLevel != nil {
+		failureDomainLevel, err = metal.LocationLevelFrom(*pcr.FailureDomainLevel)
+		if err != nil {
+			return nil, err
+		}
+
+		locations, err := ds.ListLocations()
+		if err != nil {
+			return nil, fmt.Errorf("unable to list locations: %w", err)
+		}
+		locationsByID = locations.ByID()
+	}
+
 	var (
 		partitionsByID    = ps.ByID()
 		ecsByID           = ecs.ByID()
 		sizesByID         = sizes.ByID()
 		machinesByProject = ms.ByProjectID()
-		rvsBySize         = sizeReservations.BySize()
+		activeRvs         = sizeReservations.Active(time.Now())
+		rvsBySize         = activeRvs.BySize()
 	)
 
 	for _, m := range ms {
@@ -496,23 +686,39 @@ func (r *partitionResource) calcPartitionCapacity(pcr *v1.PartitionCapacityReque
 			pc.ServerCapacities = append(pc.ServerCapacities, cap)
 		}
 
+		// the failure domain capacity is only counted if a failure domain level was requested
+		fd := &v1.FailureDomainCapacity{}
+		if failureDomainLevel != "" {
+			domain := locationsByID.Domain(m.RackID, failureDomainLevel)
+			fd = cap.FailureDomains.FindByDomain(domain)
+			if fd == nil {
+				fd = &v1.FailureDomainCapacity{Domain: domain}
+				cap.FailureDomains = append(cap.FailureDomains, fd)
+			}
+		}
+
 		cap.Total++
+		fd.Total++
 
 		if _, ok := machinesWithIssues[m.ID]; ok {
 			cap.Faulty++
 			cap.FaultyMachines = append(cap.FaultyMachines, m.ID)
+			fd.Faulty++
 		}
 
 		// allocation dependent counts
 		switch {
 		case m.Allocation != nil:
 			cap.Allocated++
+			fd.Allocated++
 		case m.Waiting && !m.PreAllocated && m.State.Value == metal.AvailableState && ec.Liveliness == metal.MachineLivelinessAlive:
 			// the free and allocatable machine counts consider the same aspects as the query for electing the machine candidate!
 			cap.Allocatable++
 			cap.Free++
+			fd.Allocatable++
 		default:
 			cap.Unavailable++
+			fd.Unavailable++
 		}
 
 		// provisioning state dependent counts
@@ -544,7 +750,7 @@ func (r *partitionResource) calcPartitionCapacity(pcr *v1.PartitionCapacityReque
 				cap.Reservations += reservation.Amount
 				cap.UsedReservations += usedReservations
 
-				if pcr.Project != nil && *pcr.Project == reservation.ProjectID {
+				if pcr != nil && pcr.Project != nil && *pcr.Project == reservation.ProjectID {
 					continue
 				}
 
@@ -555,6 +761,9 @@ func (r *partitionResource) calcPartitionCapacity(pcr *v1.PartitionCapacityReque
 
 		for _, cap := range pc.ServerCapacities {
 			cap.RemainingReservations = cap.Reservations - cap.UsedReservations
+			slices.SortFunc(cap.FailureDomains, func(a, b *v1.FailureDomainCapacity) int {
+				return strings.Compare(a.Domain, b.Domain)
+			})
 		}
 
 		res = append(res, *pc)
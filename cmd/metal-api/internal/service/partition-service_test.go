@@ -223,6 +223,16 @@ func TestUpdatePartition(t *testing.T) {
 		PartitionBootConfiguration: &v1.PartitionBootConfiguration{
 			ImageURL: &downloadableFile,
 		},
+		BootConfigurationOverrides: []v1.PartitionBootConfigurationOverride{
+			{
+				Scope:    "size",
+				Selector: "c1-large-x86",
+				PartitionBootConfiguration: v1.PartitionBootConfiguration{
+					KernelURL: &downloadableFile,
+				},
+				CommandLineAppend: new("console=ttyS1"),
+			},
+		},
 		NTPServers: []v1.NTPServer{
 			{
 				Address: "ntp.address1",
@@ -256,6 +266,11 @@ func TestUpdatePartition(t *testing.T) {
 	require.Equal(t, testdata.Partition2.Description, *result.Description)
 	require.Equal(t, mgmtService, *result.MgmtServiceAddress)
 	require.Equal(t, downloadableFile, *result.PartitionBootConfiguration.ImageURL)
+	require.Len(t, result.BootConfigurationOverrides, 1)
+	require.Equal(t, "size", result.BootConfigurationOverrides[0].Scope)
+	require.Equal(t, "c1-large-x86", result.BootConfigurationOverrides[0].Selector)
+	require.Equal(t, downloadableFile, *result.BootConfigurationOverrides[0].PartitionBootConfiguration.KernelURL)
+	require.Equal(t, "console=ttyS1", *result.BootConfigurationOverrides[0].CommandLineAppend)
 	require.Equal(t, []v1.NTPServer{
 		{
 			Address: "ntp.address1",
@@ -269,6 +284,43 @@ func TestUpdatePartition(t *testing.T) {
 	}, result.NTPServers)
 }
 
+func TestUpdatePartitionInvalidBootConfigurationOverrides(t *testing.T) {
+	ds, mock := datastore.InitMockDB(t)
+	testdata.InitMockDBData(mock)
+	log := slog.Default()
+
+	service := NewPartition(log, ds, &nopTopicCreator{})
+	container := restful.NewContainer().Add(service)
+
+	updateRequest := v1.PartitionUpdateRequest{
+		Common: v1.Common{
+			Identifiable: v1.Identifiable{
+				ID: testdata.Partition1.ID,
+			},
+		},
+		BootConfigurationOverrides: []v1.PartitionBootConfigurationOverride{
+			{Scope: "machine", Selector: "m1", CommandLineAppend: new("debug")},
+			{Scope: "machine", Selector: "m1", CommandLineAppend: new("quiet")},
+		},
+	}
+	js, err := json.Marshal(updateRequest)
+	require.NoError(t, err)
+	body := bytes.NewBuffer(js)
+	req := httptest.NewRequest("POST", "/v1/partition", body)
+	req.Header.Add("Content-Type", "application/json")
+	container = injectAdmin(log, container, req)
+	w := httptest.NewRecorder()
+	container.ServeHTTP(w, req)
+
+	resp := w.Result()
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusBadRequest, resp.StatusCode, w.Body.String())
+	var result httperrors.HTTPErrorResponse
+	err = json.NewDecoder(resp.Body).Decode(&result)
+	require.NoError(t, err)
+	require.Equal(t, `boot configuration override "machine:m1" is defined more than once`, result.Message)
+}
+
 func TestPartitionCapacity(t *testing.T) {
 	var (
 		mockMachines = func(mock *r.Mock, liveliness metal.MachineLiveliness, reservations metal.SizeReservations, ms ...metal.Machine) {
@@ -0,0 +1,221 @@
+package service
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"time"
+
+	"github.com/metal-stack/metal-api/cmd/metal-api/internal/datastore"
+	"github.com/metal-stack/metal-api/cmd/metal-api/internal/metal"
+	v1 "github.com/metal-stack/metal-api/cmd/metal-api/internal/service/v1"
+	"github.com/metal-stack/metal-lib/pkg/pointer"
+)
+
+const powerSampleMergeAttempts = 3
+
+// PowerHistoryCompactor downsamples the raw power samples to hourly samples once they are older than the raw retention
+// and removes all samples which are older than the retention.
+type PowerHistoryCompactor struct {
+	log          *slog.Logger
+	ds           datastore.Store
+	rawRetention time.Duration
+	retention    time.Duration
+}
+
+// NewPowerHistoryCompactor returns a new power history compactor, a retention of 0 keeps the downsampled samples forever.
+func NewPowerHistoryCompactor(log *slog.Logger, ds datastore.Store, rawRetention, retention time.Duration) *PowerHistoryCompactor {
+	return &PowerHistoryCompactor{
+		log:          log,
+		ds:           ds,
+		rawRetention: rawRetention,
+		retention:    retention,
+	}
+}
+
+// Run compacts the power history in the given interval until the context is done.
+func (c *PowerHistoryCompactor) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			err := c.Compact(time.Now())
+			if err != nil {
+				c.log.Error("unable to compact power history", "error", err)
+			}
+		}
+	}
+}
+
+// Compact downsamples the raw samples of all completed hours before the raw retention and prunes the samples older than the retention.
+// Raw samples which arrive late for an hour which was already downsampled are merged into the existing hourly sample.
+//
+// As the compaction runs on every metal-api instance, each raw sample is claimed by deleting it before it is merged,
+// such that it is counted only once. If a hourly sample cannot be stored, its raw samples are restored to be compacted in the next run.
+func (c *PowerHistoryCompactor) Compact(now time.Time) error {
+	cutoff := now.Add(-c.rawRetention).Truncate(metal.PowerSampleResolutionHourly)
+
+	var raw metal.PowerSamples
+	err := c.ds.SearchPowerSamples(&datastore.PowerSampleSearchQuery{
+		Resolution: pointer.Pointer(metal.PowerSampleResolutionRaw),
+		To:         &cutoff,
+	}, &raw)
+	if err != nil {
+		return err
+	}
+
+	var (
+		errs    []error
+		claimed metal.PowerSamples
+	)
+	for i := range raw {
+		err := c.ds.DeletePowerSample(&raw[i])
+		switch {
+		case err == nil:
+			claimed = append(claimed, raw[i])
+		case metal.IsNotFound(err):
+			// claimed by another metal-api instance
+		default:
+			errs = append(errs, err)
+		}
+	}
+
+	for _, bucket := range claimed.Downsample(metal.PowerSampleResolutionHourly) {
+		err := c.merge(&bucket)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("unable to store hourly power sample %s: %w", bucket.ID, err))
+			errs = append(errs, c.restore(claimed, bucket.ID)...)
+		}
+	}
+
+	if len(claimed) > 0 {
+		c.log.Info("downsampled power history", "samples", len(claimed), "before", cutoff)
+	}
+
+	if c.retention > 0 {
+		deleted, err := c.ds.DeletePowerSamplesBefore(now.Add(-c.retention))
+		if err != nil {
+			errs = append(errs, err)
+		}
+		if deleted > 0 {
+			c.log.Info("pruned power history", "deleted", deleted, "retention", c.retention)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// merge stores the hourly sample or merges it into the existing one. It is retried if the existing sample was changed
+// by another metal-api instance in the meantime, which is safe because the raw samples of the bucket are claimed.
+func (c *PowerHistoryCompactor) merge(bucket *metal.PowerSample) error {
+	var err error
+	for range powerSampleMergeAttempts {
+		var existing *metal.PowerSample
+		existing, err = c.ds.FindPowerSample(bucket.ID)
+		switch {
+		case err == nil:
+			merged := *existing
+			merged.Merge(bucket)
+			err = c.ds.UpdatePowerSample(existing, &merged)
+		case metal.IsNotFound(err):
+			created := *bucket
+			err = c.ds.CreatePowerSample(&created)
+		}
+		if !metal.IsConflict(err) {
+			return err
+		}
+	}
+	return err
+}
+
+// restore recreates the claimed raw samples of the given hourly sample.
+func (c *PowerHistoryCompactor) restore(claimed metal.PowerSamples, bucketID string) []error {
+	var errs []error
+	for i := range claimed {
+		s := &claimed[i]
+		if metal.PowerSampleBucketID(s.MachineID, s.ProjectID, s.Time.Truncate(metal.PowerSampleResolutionHourly), metal.PowerSampleResolutionHourly) != bucketID {
+			continue
+		}
+		err := c.ds.CreatePowerSample(s)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("unable to restore power sample %s: %w", s.ID, err))
+		}
+	}
+	return errs
+}
+
+// MachinePowerAggregation sums up the power consumption of all machines matching the request within the time window,
+// grouped by rack, partition, project or size. The machines are attributed to the group they belonged to at the time of the reading,
+// downsampled samples are considered if their hour starts within the window.
+func MachinePowerAggregation(ds datastore.Store, req *v1.MachinePowerAggregationRequest, now time.Time) (*v1.MachinePowerAggregationResponse, error) {
+	group, err := powerAggregationGroup(req.GroupBy)
+	if err != nil {
+		return nil, err
+	}
+
+	to, err := powerAggregationEnd(req, now)
+	if err != nil {
+		return nil, err
+	}
+
+	var ss metal.PowerSamples
+	err = ds.SearchPowerSamples(&datastore.PowerSampleSearchQuery{
+		PartitionID: req.PartitionID,
+		RackID:      req.RackID,
+		ProjectID:   req.ProjectID,
+		SizeID:      req.SizeID,
+		From:        &req.From,
+		To:          &to,
+	}, &ss)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := &v1.MachinePowerAggregationResponse{
+		From:    req.From,
+		To:      to,
+		GroupBy: pointer.SafeDerefOrDefault(req.GroupBy, "partition"),
+		Groups:  []v1.MachinePowerAggregate{},
+	}
+	for _, a := range metal.AggregatePower(ss, to.Sub(req.From), group) {
+		resp.Groups = append(resp.Groups, v1.NewMachinePowerAggregate(a))
+	}
+
+	slices.SortFunc(resp.Groups, func(a, b v1.MachinePowerAggregate) int {
+		return cmp.Compare(a.Group, b.Group)
+	})
+
+	return resp, nil
+}
+
+// powerAggregationGroup returns the function which determines the group of a power sample in the power aggregation.
+func powerAggregationGroup(groupBy *string) (func(s *metal.PowerSample) string, error) {
+	switch g := pointer.SafeDerefOrDefault(groupBy, "partition"); g {
+	case "rack":
+		return func(s *metal.PowerSample) string { return s.RackID }, nil
+	case "partition":
+		return func(s *metal.PowerSample) string { return s.PartitionID }, nil
+	case "project":
+		return func(s *metal.PowerSample) string { return s.ProjectID }, nil
+	case "size":
+		return func(s *metal.PowerSample) string { return s.SizeID }, nil
+	default:
+		return nil, fmt.Errorf("unable to group by %q, must be one of rack, partition, project or size", g)
+	}
+}
+
+// powerAggregationEnd returns the end of the time window of the power aggregation, which defaults to now.
+func powerAggregationEnd(req *v1.MachinePowerAggregationRequest, now time.Time) (time.Time, error) {
+	to := pointer.SafeDerefOrDefault(req.To, now)
+	if !req.From.Before(to) {
+		return time.Time{}, fmt.Errorf("the start of the time window must be before its end")
+	}
+	return to, nil
+}
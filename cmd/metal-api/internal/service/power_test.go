@@ -0,0 +1,143 @@
+package service
+
+import (
+	"log/slog"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/metal-stack/metal-api/cmd/metal-api/internal/datastore"
+	"github.com/metal-stack/metal-api/cmd/metal-api/internal/metal"
+	v1 "github.com/metal-stack/metal-api/cmd/metal-api/internal/service/v1"
+	"github.com/metal-stack/metal-lib/pkg/pointer"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPowerHistoryCompactor_Compact(t *testing.T) {
+	var (
+		log = slog.Default()
+		ds  = datastore.NewMemory(log)
+		now = time.Date(2026, 1, 10, 12, 30, 0, 0, time.UTC)
+	)
+
+	raw := func(age time.Duration, watts float64) {
+		require.NoError(t, ds.CreatePowerSample(&metal.PowerSample{
+			MachineID:            "m1",
+			ProjectID:            "pr1",
+			Time:                 now.Add(-age),
+			Duration:             10 * time.Minute,
+			Samples:              1,
+			AverageConsumedWatts: watts,
+			MinConsumedWatts:     watts,
+			MaxConsumedWatts:     watts,
+		}))
+	}
+
+	raw(time.Hour, 100)
+	raw(25*time.Hour, 100)
+	raw(25*time.Hour+10*time.Minute, 200)
+	raw(100*24*time.Hour, 100)
+
+	c := NewPowerHistoryCompactor(log, ds, 24*time.Hour, 90*24*time.Hour)
+	require.NoError(t, c.Compact(now))
+
+	var ss metal.PowerSamples
+	require.NoError(t, ds.SearchPowerSamples(&datastore.PowerSampleSearchQuery{Resolution: pointer.Pointer(metal.PowerSampleResolutionRaw)}, &ss))
+	require.Len(t, ss, 1)
+
+	ss = nil
+	require.NoError(t, ds.SearchPowerSamples(&datastore.PowerSampleSearchQuery{Resolution: pointer.Pointer(metal.PowerSampleResolutionHourly)}, &ss))
+	require.Len(t, ss, 1)
+	require.Equal(t, 2, ss[0].Samples)
+	require.InDelta(t, 150, ss[0].AverageConsumedWatts, 0.0001)
+	require.Equal(t, now.Add(-25*time.Hour).Truncate(time.Hour), ss[0].Time)
+
+	// late samples are merged into the existing hourly sample
+	raw(25*time.Hour+20*time.Minute, 300)
+	require.NoError(t, c.Compact(now))
+
+	hourly, err := ds.FindPowerSample(ss[0].ID)
+	require.NoError(t, err)
+	require.Equal(t, 3, hourly.Samples)
+	require.InDelta(t, 200, hourly.AverageConsumedWatts, 0.0001)
+	require.Equal(t, 30*time.Minute, hourly.Duration)
+}
+
+func TestPowerHistoryCompactor_Concurrent(t *testing.T) {
+	var (
+		log = slog.Default()
+		ds  = datastore.NewMemory(log)
+		now = time.Date(2026, 1, 10, 12, 30, 0, 0, time.UTC)
+	)
+
+	for i := range 100 {
+		require.NoError(t, ds.CreatePowerSample(&metal.PowerSample{
+			MachineID:            "m1",
+			ProjectID:            "pr1",
+			Time:                 now.Add(-25 * time.Hour).Truncate(time.Hour).Add(time.Duration(i) * 30 * time.Second),
+			Duration:             time.Minute,
+			Samples:              1,
+			AverageConsumedWatts: 100,
+		}))
+	}
+
+	// every metal-api instance runs the compaction, each raw sample must be counted once
+	var wg sync.WaitGroup
+	for range 4 {
+		wg.Go(func() {
+			assert.NoError(t, NewPowerHistoryCompactor(log, ds, 24*time.Hour, 0).Compact(now))
+		})
+	}
+	wg.Wait()
+
+	var ss metal.PowerSamples
+	require.NoError(t, ds.SearchPowerSamples(&datastore.PowerSampleSearchQuery{}, &ss))
+	require.Len(t, ss, 1)
+	require.Equal(t, metal.PowerSampleResolutionHourly, ss[0].Resolution)
+	require.Equal(t, 100, ss[0].Samples)
+	require.Equal(t, 100*time.Minute, ss[0].Duration)
+}
+
+func TestMachinePowerAggregation(t *testing.T) {
+	var (
+		log = slog.Default()
+		ds  = datastore.NewMemory(log)
+		now = time.Date(2026, 1, 10, 12, 0, 0, 0, time.UTC)
+	)
+
+	for _, s := range []metal.PowerSample{
+		{MachineID: "m1", PartitionID: "p1", RackID: "r1", ProjectID: "pr1", Time: now.Add(-30 * time.Minute), Duration: time.Hour, AverageConsumedWatts: 100, MaxConsumedWatts: 120},
+		{MachineID: "m2", PartitionID: "p1", RackID: "r2", ProjectID: "pr1", Time: now.Add(-30 * time.Minute), Duration: time.Hour, AverageConsumedWatts: 300, MaxConsumedWatts: 320},
+		{MachineID: "m3", PartitionID: "p1", RackID: "r2", Time: now.Add(-30 * time.Minute), Duration: time.Hour, AverageConsumedWatts: 200, MaxConsumedWatts: 200},
+		{MachineID: "m1", PartitionID: "p1", RackID: "r1", ProjectID: "pr1", Time: now.Add(-3 * time.Hour), Duration: time.Hour, AverageConsumedWatts: 1000},
+	} {
+		require.NoError(t, ds.CreatePowerSample(&s))
+	}
+
+	got, err := MachinePowerAggregation(ds, &v1.MachinePowerAggregationRequest{
+		From:    now.Add(-time.Hour),
+		GroupBy: pointer.Pointer("project"),
+	}, now)
+	require.NoError(t, err)
+	require.Equal(t, now, got.To)
+	require.Equal(t, []v1.MachinePowerAggregate{
+		{Group: "", Machines: 1, AverageWatts: 200, PeakWatts: 200, EnergyWattHours: 200},
+		{Group: "pr1", Machines: 2, AverageWatts: 400, PeakWatts: 440, EnergyWattHours: 400},
+	}, got.Groups)
+
+	got, err = MachinePowerAggregation(ds, &v1.MachinePowerAggregationRequest{
+		From:    now.Add(-time.Hour),
+		GroupBy: pointer.Pointer("rack"),
+		RackID:  pointer.Pointer("r2"),
+	}, now)
+	require.NoError(t, err)
+	require.Len(t, got.Groups, 1)
+	require.Equal(t, 2, got.Groups[0].Machines)
+	require.InDelta(t, 500, got.Groups[0].EnergyWattHours, 0.0001)
+
+	_, err = MachinePowerAggregation(ds, &v1.MachinePowerAggregationRequest{From: now, GroupBy: pointer.Pointer("image")}, now)
+	require.Error(t, err)
+	_, err = MachinePowerAggregation(ds, &v1.MachinePowerAggregationRequest{From: now}, now)
+	require.Error(t, err)
+}
@@ -2,6 +2,7 @@ package service
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"log/slog"
 	"net/http"
@@ -17,22 +18,25 @@ import (
 	restfulspec "github.com/emicklei/go-restful-openapi/v2"
 	restful "github.com/emicklei/go-restful/v3"
 	auditinghttp "github.com/metal-stack/metal-lib/auditing/http"
+	"github.com/metal-stack/metal-lib/bus"
 	"github.com/metal-stack/metal-lib/httperrors"
 )
 
 type projectResource struct {
 	webResource
-	mdc mdm.Client
+	mdc       mdm.Client
+	publisher bus.Publisher
 }
 
 // NewProject returns a webservice for project specific endpoints.
-func NewProject(log *slog.Logger, ds *datastore.RethinkStore, mdc mdm.Client) *restful.WebService {
+func NewProject(log *slog.Logger, ds datastore.Store, mdc mdm.Client, publisher bus.Publisher) *restful.WebService {
 	r := projectResource{
 		webResource: webResource{
 			log: log,
 			ds:  ds,
 		},
-		mdc: mdc,
+		mdc:       mdc,
+		publisher: publisher,
 	}
 	return r.webService()
 }
@@ -196,6 +200,8 @@ func (r *projectResource) createProject(request *restful.Request, response *rest
 		Project: *v1p,
 	}
 
+	r.publishProjectEvent(metal.CREATE, v1p)
+
 	r.send(request, response, http.StatusCreated, pcres)
 }
 
@@ -273,6 +279,8 @@ func (r *projectResource) deleteProject(request *restful.Request, response *rest
 		Project: *v1p,
 	}
 
+	r.publishProjectEvent(metal.DELETE, v1p)
+
 	r.send(request, response, http.StatusOK, pcres)
 }
 
@@ -310,11 +318,40 @@ func (r *projectResource) updateProject(request *restful.Request, response *rest
 
 	v1p := mapper.ToV1Project(pur.Project)
 
+	r.publishProjectEvent(metal.UPDATE, v1p)
+
 	r.send(request, response, http.StatusOK, &v1.ProjectResponse{
 		Project: *v1p,
 	})
 }
 
+// publishProjectEvent notifies other metal-api instances about changed projects, such that they can be passed to watchers.
+func (r *projectResource) publishProjectEvent(eventType metal.EventType, p *v1.Project) {
+	if p.Meta == nil {
+		return
+	}
+
+	evt := &metal.ProjectEvent{
+		Type:      eventType,
+		ProjectID: p.Meta.Id,
+	}
+
+	raw, err := json.Marshal(p)
+	if err != nil {
+		r.log.Error("unable to marshal project for project event", "projectID", p.Meta.Id, "error", err)
+	} else {
+		evt.Project = raw
+	}
+
+	err = r.publisher.Publish(metal.TopicProject.Name, evt)
+	if err != nil {
+		r.log.Error("failed to publish project event", "topic", metal.TopicProject.Name, "projectID", p.Meta.Id, "error", err)
+		return
+	}
+
+	r.log.Debug("published project event", "topic", metal.TopicProject.Name, "projectID", p.Meta.Id, "type", eventType)
+}
+
 func (r *projectResource) setProjectQuota(project *mdmv1.Project) (*v1.Project, error) {
 	if project.Meta == nil {
 		return nil, errors.New("project does not have a projectID")
@@ -50,7 +50,7 @@ func NewMockedProjectService(t *testing.T, projectServiceMock func(mock *mdmv1mo
 	if dsmock != nil {
 		dsmock(mock)
 	}
-	ws := NewProject(slog.Default(), ds, mdc)
+	ws := NewProject(slog.Default(), ds, mdc, &emptyPublisher{})
 	return &MockedProjectService{
 		t:  t,
 		ws: ws,
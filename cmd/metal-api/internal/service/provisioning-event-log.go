@@ -0,0 +1,57 @@
+package service
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/metal-stack/metal-api/cmd/metal-api/internal/datastore"
+)
+
+// ProvisioningEventLogPruner removes the entries of the provisioning event log which are older than the retention.
+type ProvisioningEventLogPruner struct {
+	log       *slog.Logger
+	ds        datastore.Store
+	retention time.Duration
+}
+
+// NewProvisioningEventLogPruner returns a new provisioning event log pruner.
+func NewProvisioningEventLogPruner(log *slog.Logger, ds datastore.Store, retention time.Duration) *ProvisioningEventLogPruner {
+	return &ProvisioningEventLogPruner{
+		log:       log,
+		ds:        ds,
+		retention: retention,
+	}
+}
+
+// Run prunes the provisioning event log in the given interval until the context is done.
+func (p *ProvisioningEventLogPruner) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			err := p.Prune(time.Now())
+			if err != nil {
+				p.log.Error("unable to prune provisioning event log", "error", err)
+			}
+		}
+	}
+}
+
+// Prune removes all entries which were received before the retention.
+func (p *ProvisioningEventLogPruner) Prune(now time.Time) error {
+	deleted, err := p.ds.DeleteProvisioningEventLogBefore(now.Add(-p.retention))
+	if err != nil {
+		return err
+	}
+
+	if deleted > 0 {
+		p.log.Info("pruned provisioning event log", "deleted", deleted, "retention", p.retention)
+	}
+
+	return nil
+}
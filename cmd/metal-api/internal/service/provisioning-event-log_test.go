@@ -0,0 +1,36 @@
+package service
+
+import (
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/metal-stack/metal-api/cmd/metal-api/internal/datastore"
+	"github.com/metal-stack/metal-api/cmd/metal-api/internal/metal"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProvisioningEventLogPruner_Prune(t *testing.T) {
+	var (
+		log = slog.Default()
+		ds  = datastore.NewMemory(log)
+		now = time.Now()
+	)
+
+	for _, age := range []time.Duration{time.Hour, 47 * time.Hour, 49 * time.Hour, 100 * time.Hour} {
+		require.NoError(t, ds.CreateProvisioningEventLogEntry(&metal.ProvisioningEventLogEntry{
+			MachineID: "m1",
+			Time:      now.Add(-age),
+			Event:     metal.ProvisioningEventPreparing,
+		}))
+	}
+
+	p := NewProvisioningEventLogPruner(log, ds, 48*time.Hour)
+	require.NoError(t, p.Prune(now))
+
+	entries, next, err := ds.SearchProvisioningEventLog(&datastore.ProvisioningEventLogSearchQuery{})
+	require.NoError(t, err)
+	require.Nil(t, next)
+	require.Len(t, entries, 2)
+	require.Equal(t, now.Add(-47*time.Hour).Unix(), entries[0].Time.Unix())
+}
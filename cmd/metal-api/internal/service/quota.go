@@ -0,0 +1,319 @@
+package service
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	mdmv1 "github.com/metal-stack/masterdata-api/api/v1"
+	"github.com/metal-stack/metal-api/cmd/metal-api/internal/datastore"
+	"github.com/metal-stack/metal-api/cmd/metal-api/internal/metal"
+)
+
+// The masterdata quota set only provides the machine and ip quota of a project.
+// The firewall, network and size quotas are configured with project annotations whose values must be non-negative integers.
+const (
+	// firewallQuotaAnnotation limits the amount of allocated firewalls of a project.
+	firewallQuotaAnnotation = "metal-stack.io/quota-firewalls"
+	// networkQuotaAnnotation limits the amount of child networks of a project.
+	networkQuotaAnnotation = "metal-stack.io/quota-networks"
+	// sizeQuotaAnnotationPrefix followed by the id of a size limits the amount of allocated machines of this size of a project.
+	sizeQuotaAnnotationPrefix = "metal-stack.io/quota-size-"
+)
+
+// machineQuota is a quota which limits the allocated machines of a project.
+type machineQuota struct {
+	kind  metal.QuotaKind
+	scope string
+	max   *int
+	// counts returns true if the given machine counts towards the quota
+	counts func(m *metal.Machine) bool
+}
+
+// machineQuotas returns the quotas of the project which are affected by the allocation of a machine with the given role and size.
+func machineQuotas(p *mdmv1.Project, role metal.Role, sizeID string) ([]machineQuota, error) {
+	isFirewall := func(m *metal.Machine) bool {
+		return m.Allocation.Role == metal.RoleFirewall
+	}
+
+	var quotas []machineQuota
+
+	// the machine quota is checked against the allocated firewalls of the project for every allocation
+	if maxMachines := quotaMax(p.GetQuotas().GetMachine()); maxMachines != nil {
+		quotas = append(quotas, machineQuota{kind: metal.QuotaKindMachine, max: maxMachines, counts: isFirewall})
+	}
+
+	if role == metal.RoleFirewall {
+		maxFirewalls, err := annotationQuota(p, firewallQuotaAnnotation)
+		if err != nil {
+			return nil, err
+		}
+		if maxFirewalls != nil {
+			quotas = append(quotas, machineQuota{kind: metal.QuotaKindFirewall, max: maxFirewalls, counts: isFirewall})
+		}
+	}
+
+	maxSize, err := annotationQuota(p, sizeQuotaAnnotationPrefix+sizeID)
+	if err != nil {
+		return nil, err
+	}
+	if maxSize != nil {
+		quotas = append(quotas, machineQuota{kind: metal.QuotaKindSize, scope: sizeID, max: maxSize, counts: func(m *metal.Machine) bool {
+			return m.SizeID == sizeID
+		}})
+	}
+
+	return quotas, nil
+}
+
+// checkMachineQuotas returns an error if the allocation of another machine with the given role and size
+// would exceed the machine, firewall or size quota of the project.
+func checkMachineQuotas(ds datastore.Store, p *mdmv1.Project, role metal.Role, sizeID string) error {
+	quotas, err := machineQuotas(p, role, sizeID)
+	if err != nil || len(quotas) == 0 {
+		return err
+	}
+
+	projectID := p.GetMeta().GetId()
+
+	ms, err := quotaMachines(ds, projectID)
+	if err != nil {
+		return err
+	}
+
+	for _, q := range quotas {
+		used := 0
+		for i := range ms {
+			if q.counts(&ms[i]) {
+				used++
+			}
+		}
+
+		err = checkQuota(q.kind, projectID, q.scope, q.max, used)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// recheckMachineQuotas returns an error if the just allocated machine exceeds the machine, firewall or size quota of the project.
+// It is called after the allocation was persisted because parallel allocations might have passed checkMachineQuotas as well.
+func recheckMachineQuotas(ds datastore.Store, p *mdmv1.Project, m *metal.Machine) error {
+	quotas, err := machineQuotas(p, m.Allocation.Role, m.SizeID)
+	if err != nil || len(quotas) == 0 {
+		return err
+	}
+
+	projectID := p.GetMeta().GetId()
+
+	ms, err := quotaMachines(ds, projectID)
+	if err != nil {
+		return err
+	}
+
+	for _, q := range quotas {
+		if !q.counts(m) {
+			continue
+		}
+
+		var usages []quotaUsage
+		for i := range ms {
+			if q.counts(&ms[i]) {
+				usages = append(usages, quotaUsage{id: ms[i].ID, since: ms[i].Allocation.Created})
+			}
+		}
+
+		err = recheckQuota(q.kind, projectID, q.scope, q.max, usages, m.ID)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func quotaMachines(ds datastore.Store, projectID string) (metal.Machines, error) {
+	var ms metal.Machines
+	err := ds.SearchMachines(&datastore.MachineSearchQuery{AllocationProject: &projectID}, &ms)
+	if err != nil {
+		return nil, err
+	}
+	return ms, nil
+}
+
+// checkIPQuota returns an error if the allocation of another ip would exceed the ip quota of the project.
+func checkIPQuota(ds datastore.Store, p *mdmv1.Project) error {
+	maxIPs := quotaMax(p.GetQuotas().GetIp())
+	if maxIPs == nil {
+		return nil
+	}
+
+	projectID := p.GetMeta().GetId()
+
+	var ips metal.IPs
+	err := ds.SearchIPs(&datastore.IPSearchQuery{ProjectID: &projectID}, &ips)
+	if err != nil {
+		return err
+	}
+
+	return checkQuota(metal.QuotaKindIP, projectID, "", maxIPs, len(ips))
+}
+
+// recheckIPQuota returns an error if the just allocated ip exceeds the ip quota of the project.
+// It is called after the ip was persisted because parallel allocations might have passed checkIPQuota as well.
+func recheckIPQuota(ds datastore.Store, p *mdmv1.Project, ipAddress string) error {
+	maxIPs := quotaMax(p.GetQuotas().GetIp())
+	if maxIPs == nil {
+		return nil
+	}
+
+	projectID := p.GetMeta().GetId()
+
+	var ips metal.IPs
+	err := ds.SearchIPs(&datastore.IPSearchQuery{ProjectID: &projectID}, &ips)
+	if err != nil {
+		return err
+	}
+
+	var usages []quotaUsage
+	for _, ip := range ips {
+		usages = append(usages, quotaUsage{id: ip.IPAddress, since: ip.Created})
+	}
+
+	return recheckQuota(metal.QuotaKindIP, projectID, "", maxIPs, usages, ipAddress)
+}
+
+// checkNetworkQuota returns an error if the allocation of another child network would exceed the network quota of the project.
+func checkNetworkQuota(ds datastore.Store, p *mdmv1.Project) error {
+	maxNetworks, err := annotationQuota(p, networkQuotaAnnotation)
+	if err != nil || maxNetworks == nil {
+		return err
+	}
+
+	projectID := p.GetMeta().GetId()
+
+	nws, err := quotaChildNetworks(ds, projectID)
+	if err != nil {
+		return err
+	}
+
+	return checkQuota(metal.QuotaKindNetwork, projectID, "", maxNetworks, len(nws))
+}
+
+// recheckNetworkQuota returns an error if the just allocated child network exceeds the network quota of the project.
+// It is called after the network was persisted because parallel allocations might have passed checkNetworkQuota as well.
+func recheckNetworkQuota(ds datastore.Store, p *mdmv1.Project, networkID string) error {
+	maxNetworks, err := annotationQuota(p, networkQuotaAnnotation)
+	if err != nil || maxNetworks == nil {
+		return err
+	}
+
+	projectID := p.GetMeta().GetId()
+
+	nws, err := quotaChildNetworks(ds, projectID)
+	if err != nil {
+		return err
+	}
+
+	var usages []quotaUsage
+	for _, nw := range nws {
+		usages = append(usages, quotaUsage{id: nw.ID, since: nw.Created})
+	}
+
+	return recheckQuota(metal.QuotaKindNetwork, projectID, "", maxNetworks, usages, networkID)
+}
+
+func quotaChildNetworks(ds datastore.Store, projectID string) (metal.Networks, error) {
+	var nws metal.Networks
+	err := ds.SearchNetworks(&datastore.NetworkSearchQuery{ProjectID: &projectID}, &nws)
+	if err != nil {
+		return nil, err
+	}
+
+	var res metal.Networks
+	for _, nw := range nws {
+		if nw.ParentNetworkID != "" {
+			res = append(res, nw)
+		}
+	}
+	return res, nil
+}
+
+func checkQuota(kind metal.QuotaKind, projectID, scope string, maxAmount *int, used int) error {
+	if maxAmount == nil || used < *maxAmount {
+		return nil
+	}
+
+	return &metal.QuotaExceededError{
+		Kind:      kind,
+		ProjectID: projectID,
+		Scope:     scope,
+		Max:       *maxAmount,
+		Used:      used,
+	}
+}
+
+// quotaUsage is an entity counting towards a quota.
+type quotaUsage struct {
+	id    string
+	since time.Time
+}
+
+// recheckQuota returns an error if the entity with the given id is not among the oldest entities permitted by the quota.
+// Like this, only the allocations which exceeded the quota are rolled back when parallel allocations passed the check before.
+func recheckQuota(kind metal.QuotaKind, projectID, scope string, maxAmount *int, usages []quotaUsage, id string) error {
+	if maxAmount == nil {
+		return nil
+	}
+
+	slices.SortFunc(usages, func(a, b quotaUsage) int {
+		return cmp.Or(a.since.Compare(b.since), strings.Compare(a.id, b.id))
+	})
+
+	idx := slices.IndexFunc(usages, func(u quotaUsage) bool {
+		return u.id == id
+	})
+	if idx < *maxAmount {
+		return nil
+	}
+
+	return &metal.QuotaExceededError{
+		Kind:      kind,
+		ProjectID: projectID,
+		Scope:     scope,
+		Max:       *maxAmount,
+		Used:      len(usages),
+	}
+}
+
+func quotaMax(q *mdmv1.Quota) *int {
+	if q == nil {
+		return nil
+	}
+	if q.Max != nil {
+		return new(int(q.GetMax()))
+	}
+	if q.GetQuota() != nil { // nolint:staticcheck
+		return new(int(q.GetQuota().GetValue())) // nolint:staticcheck
+	}
+	return nil
+}
+
+func annotationQuota(p *mdmv1.Project, key string) (*int, error) {
+	value, ok := p.GetMeta().GetAnnotations()[key]
+	if !ok {
+		return nil, nil
+	}
+
+	maxAmount, err := strconv.Atoi(value)
+	if err != nil || maxAmount < 0 {
+		return nil, fmt.Errorf("project %s has an invalid quota annotation %s=%q, must be a non-negative integer", p.GetMeta().GetId(), key, value)
+	}
+
+	return &maxAmount, nil
+}
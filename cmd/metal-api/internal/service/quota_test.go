@@ -0,0 +1,259 @@
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/emicklei/go-restful/v3"
+	mdmv1 "github.com/metal-stack/masterdata-api/api/v1"
+	"github.com/metal-stack/metal-api/cmd/metal-api/internal/datastore"
+	"github.com/metal-stack/metal-api/cmd/metal-api/internal/metal"
+	v1 "github.com/metal-stack/metal-api/cmd/metal-api/internal/service/v1"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+func TestCheckQuotas(t *testing.T) {
+	ds := datastore.NewMemory(slog.Default())
+
+	now := time.Now()
+	for i, m := range []struct {
+		role    metal.Role
+		size    string
+		created time.Time
+	}{
+		{role: metal.RoleMachine, size: "s1", created: now.Add(-3 * time.Minute)},
+		{role: metal.RoleFirewall, size: "s1", created: now.Add(-2 * time.Minute)},
+		{role: metal.RoleFirewall, size: "s2", created: now.Add(-time.Minute)},
+	} {
+		require.NoError(t, ds.CreateMachine(&metal.Machine{Base: metal.Base{ID: fmt.Sprintf("m%d", i)}, SizeID: m.size}))
+		old, err := ds.FindMachineByID(fmt.Sprintf("m%d", i))
+		require.NoError(t, err)
+		allocated := *old
+		allocated.Allocation = &metal.MachineAllocation{Project: "p1", Role: m.role, Created: m.created}
+		require.NoError(t, ds.UpdateMachine(old, &allocated))
+	}
+	require.NoError(t, ds.CreateIP(&metal.IP{IPAddress: "1.2.3.4", ProjectID: "p1", NetworkID: "internet"}))
+	require.NoError(t, ds.CreateIP(&metal.IP{IPAddress: "10.0.0.1", ProjectID: "p1", NetworkID: "private"}))
+	require.NoError(t, ds.CreateNetwork(&metal.Network{Base: metal.Base{ID: "n0"}, ProjectID: "p1"}))
+	require.NoError(t, ds.CreateNetwork(&metal.Network{Base: metal.Base{ID: "n1"}, ProjectID: "p1", ParentNetworkID: "super"}))
+	require.NoError(t, ds.CreateNetwork(&metal.Network{Base: metal.Base{ID: "n2"}, ProjectID: "p1", ParentNetworkID: "super"}))
+
+	project := func(quotas *mdmv1.QuotaSet) *mdmv1.Project {
+		return &mdmv1.Project{
+			Meta:   &mdmv1.Meta{Id: "p1"},
+			Quotas: quotas,
+		}
+	}
+	annotated := func(annotations map[string]string) *mdmv1.Project {
+		return &mdmv1.Project{
+			Meta: &mdmv1.Meta{Id: "p1", Annotations: annotations},
+		}
+	}
+	machine := func(id string) *metal.Machine {
+		m, err := ds.FindMachineByID(id)
+		require.NoError(t, err)
+		return m
+	}
+
+	tests := []struct {
+		name  string
+		check func() error
+		want  *metal.QuotaExceededError
+	}{
+		{
+			name: "no quotas",
+			check: func() error {
+				return checkMachineQuotas(ds, project(nil), metal.RoleMachine, "s1")
+			},
+		},
+		{
+			name: "machine quota not reached",
+			check: func() error {
+				return checkMachineQuotas(ds, project(&mdmv1.QuotaSet{Machine: &mdmv1.Quota{Max: new(int32(3))}}), metal.RoleMachine, "s1")
+			},
+		},
+		{
+			name: "machine quota only counts firewalls",
+			check: func() error {
+				return checkMachineQuotas(ds, project(&mdmv1.QuotaSet{Machine: &mdmv1.Quota{Max: new(int32(2))}}), metal.RoleMachine, "s1")
+			},
+			want: &metal.QuotaExceededError{Kind: metal.QuotaKindMachine, ProjectID: "p1", Max: 2, Used: 2},
+		},
+		{
+			name: "deprecated machine quota",
+			check: func() error {
+				return checkMachineQuotas(ds, project(&mdmv1.QuotaSet{Machine: &mdmv1.Quota{Quota: wrapperspb.Int32(2)}}), metal.RoleMachine, "s1")
+			},
+			want: &metal.QuotaExceededError{Kind: metal.QuotaKindMachine, ProjectID: "p1", Max: 2, Used: 2},
+		},
+		{
+			name: "recheck of a firewall within the machine quota",
+			check: func() error {
+				return recheckMachineQuotas(ds, project(&mdmv1.QuotaSet{Machine: &mdmv1.Quota{Max: new(int32(1))}}), machine("m1"))
+			},
+		},
+		{
+			name: "recheck of a firewall exceeding the machine quota",
+			check: func() error {
+				return recheckMachineQuotas(ds, project(&mdmv1.QuotaSet{Machine: &mdmv1.Quota{Max: new(int32(1))}}), machine("m2"))
+			},
+			want: &metal.QuotaExceededError{Kind: metal.QuotaKindMachine, ProjectID: "p1", Max: 1, Used: 2},
+		},
+		{
+			name: "firewall quota is not checked for machines",
+			check: func() error {
+				return checkMachineQuotas(ds, annotated(map[string]string{firewallQuotaAnnotation: "2"}), metal.RoleMachine, "s1")
+			},
+		},
+		{
+			name: "firewall quota reached",
+			check: func() error {
+				return checkMachineQuotas(ds, annotated(map[string]string{firewallQuotaAnnotation: "2"}), metal.RoleFirewall, "s1")
+			},
+			want: &metal.QuotaExceededError{Kind: metal.QuotaKindFirewall, ProjectID: "p1", Max: 2, Used: 2},
+		},
+		{
+			name: "recheck of a firewall within the firewall quota",
+			check: func() error {
+				return recheckMachineQuotas(ds, annotated(map[string]string{firewallQuotaAnnotation: "1"}), machine("m1"))
+			},
+		},
+		{
+			name: "recheck of a firewall exceeding the firewall quota",
+			check: func() error {
+				return recheckMachineQuotas(ds, annotated(map[string]string{firewallQuotaAnnotation: "1"}), machine("m2"))
+			},
+			want: &metal.QuotaExceededError{Kind: metal.QuotaKindFirewall, ProjectID: "p1", Max: 1, Used: 2},
+		},
+		{
+			name: "size quota of another size",
+			check: func() error {
+				return checkMachineQuotas(ds, annotated(map[string]string{sizeQuotaAnnotationPrefix + "s1": "2"}), metal.RoleMachine, "s2")
+			},
+		},
+		{
+			name: "size quota reached",
+			check: func() error {
+				return checkMachineQuotas(ds, annotated(map[string]string{sizeQuotaAnnotationPrefix + "s1": "2"}), metal.RoleMachine, "s1")
+			},
+			want: &metal.QuotaExceededError{Kind: metal.QuotaKindSize, ProjectID: "p1", Scope: "s1", Max: 2, Used: 2},
+		},
+		{
+			name: "recheck of a machine within the size quota",
+			check: func() error {
+				return recheckMachineQuotas(ds, annotated(map[string]string{sizeQuotaAnnotationPrefix + "s1": "1"}), machine("m0"))
+			},
+		},
+		{
+			name: "recheck of a firewall exceeding the size quota",
+			check: func() error {
+				return recheckMachineQuotas(ds, annotated(map[string]string{sizeQuotaAnnotationPrefix + "s1": "1"}), machine("m1"))
+			},
+			want: &metal.QuotaExceededError{Kind: metal.QuotaKindSize, ProjectID: "p1", Scope: "s1", Max: 1, Used: 2},
+		},
+		{
+			name: "invalid size quota",
+			check: func() error {
+				err := checkMachineQuotas(ds, annotated(map[string]string{sizeQuotaAnnotationPrefix + "s1": "-1"}), metal.RoleMachine, "s1")
+				require.EqualError(t, err, `project p1 has an invalid quota annotation metal-stack.io/quota-size-s1="-1", must be a non-negative integer`)
+				return nil
+			},
+		},
+		{
+			name: "network quota not reached",
+			check: func() error {
+				return checkNetworkQuota(ds, annotated(map[string]string{networkQuotaAnnotation: "3"}))
+			},
+		},
+		{
+			name: "network quota only counts child networks",
+			check: func() error {
+				return checkNetworkQuota(ds, annotated(map[string]string{networkQuotaAnnotation: "2"}))
+			},
+			want: &metal.QuotaExceededError{Kind: metal.QuotaKindNetwork, ProjectID: "p1", Max: 2, Used: 2},
+		},
+		{
+			name: "recheck of a network within the network quota",
+			check: func() error {
+				return recheckNetworkQuota(ds, annotated(map[string]string{networkQuotaAnnotation: "1"}), "n1")
+			},
+		},
+		{
+			name: "recheck of a network exceeding the network quota",
+			check: func() error {
+				return recheckNetworkQuota(ds, annotated(map[string]string{networkQuotaAnnotation: "1"}), "n2")
+			},
+			want: &metal.QuotaExceededError{Kind: metal.QuotaKindNetwork, ProjectID: "p1", Max: 1, Used: 2},
+		},
+		{
+			name: "ip quota not reached",
+			check: func() error {
+				return checkIPQuota(ds, project(&mdmv1.QuotaSet{Ip: &mdmv1.Quota{Max: new(int32(3))}}))
+			},
+		},
+		{
+			name: "ip quota reached",
+			check: func() error {
+				return checkIPQuota(ds, project(&mdmv1.QuotaSet{Ip: &mdmv1.Quota{Max: new(int32(2))}}))
+			},
+			want: &metal.QuotaExceededError{Kind: metal.QuotaKindIP, ProjectID: "p1", Max: 2, Used: 2},
+		},
+		{
+			name: "recheck of ips within the ip quota",
+			check: func() error {
+				return recheckIPQuota(ds, project(&mdmv1.QuotaSet{Ip: &mdmv1.Quota{Max: new(int32(2))}}), "10.0.0.1")
+			},
+		},
+		{
+			name: "recheck of an ip exceeding the ip quota",
+			check: func() error {
+				return recheckIPQuota(ds, project(&mdmv1.QuotaSet{Ip: &mdmv1.Quota{Max: new(int32(1))}}), "10.0.0.1")
+			},
+			want: &metal.QuotaExceededError{Kind: metal.QuotaKindIP, ProjectID: "p1", Max: 1, Used: 2},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.check()
+
+			if tt.want == nil {
+				require.NoError(t, err)
+				return
+			}
+			qe, ok := metal.IsQuotaExceeded(err)
+			require.True(t, ok, "expected quota exceeded error, got %v", err)
+			require.Equal(t, tt.want, qe)
+		})
+	}
+}
+
+func TestSendQuotaOrDefaultError(t *testing.T) {
+	w := &webResource{log: slog.Default()}
+
+	ws := new(restful.WebService).Path("/v1/test").Produces(restful.MIME_JSON)
+	ws.Route(ws.GET("/").To(func(request *restful.Request, response *restful.Response) {
+		err := &metal.QuotaExceededError{Kind: metal.QuotaKindIP, ProjectID: "p1", Max: 2, Used: 2}
+		w.sendQuotaOrDefaultError(request, response, fmt.Errorf("allocation 1 of 2 failed: %w", err))
+	}))
+	container := restful.NewContainer().Add(ws)
+
+	rec := httptest.NewRecorder()
+	container.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/v1/test/", nil))
+
+	require.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+
+	var resp v1.QuotaExceededResponse
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&resp))
+	require.Equal(t, http.StatusUnprocessableEntity, resp.StatusCode)
+	require.Equal(t, "allocation 1 of 2 failed: project quota for ip reached max:2 used:2", resp.Message)
+	require.Equal(t, "ip", resp.Quota)
+	require.Equal(t, "p1", resp.ProjectID)
+	require.Equal(t, 2, resp.Max)
+	require.Equal(t, 2, resp.Used)
+}
@@ -0,0 +1,389 @@
+package service
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/metal-stack/metal-api/cmd/metal-api/internal/datastore"
+	"github.com/metal-stack/metal-api/cmd/metal-api/internal/issues"
+	"github.com/metal-stack/metal-api/cmd/metal-api/internal/metal"
+	"github.com/metal-stack/metal-lib/auditing"
+	"github.com/metal-stack/metal-lib/bus"
+)
+
+// remediationRateLimitWindow is the window in which at most RemediatorConfig.RateLimit remediations are executed.
+const remediationRateLimitWindow = time.Hour
+
+// RemediationPolicy defines the action which is executed automatically on machines having an issue of the given type.
+type RemediationPolicy struct {
+	IssueType issues.Type
+	Action    metal.RemediationAction
+}
+
+// ParseRemediationPolicies parses remediation policies in the form <issue-type>=<action>.
+func ParseRemediationPolicies(policies []string) ([]RemediationPolicy, error) {
+	var res []RemediationPolicy
+	for _, p := range policies {
+		issueType, actionName, ok := strings.Cut(p, "=")
+		if !ok {
+			return nil, fmt.Errorf("remediation policy %q must be in the form <issue-type>=<action>", p)
+		}
+
+		_, err := issues.NewIssueFromType(issues.Type(issueType))
+		if err != nil {
+			return nil, err
+		}
+
+		action, err := metal.RemediationActionFrom(actionName)
+		if err != nil {
+			return nil, err
+		}
+
+		if slices.ContainsFunc(res, func(existing RemediationPolicy) bool { return existing.IssueType == issues.Type(issueType) }) {
+			return nil, fmt.Errorf("remediation policy for issue type %q is defined more than once", issueType)
+		}
+
+		res = append(res, RemediationPolicy{IssueType: issues.Type(issueType), Action: action})
+	}
+	return res, nil
+}
+
+// RemediatorConfig configures the automated remediation of machine issues.
+type RemediatorConfig struct {
+	// Policies define which action is executed for which issue type, issues without a policy are not remediated
+	Policies []RemediationPolicy
+	// Cooldown is the minimum duration between two remediations of the same machine
+	Cooldown time.Duration
+	// RateLimit is the maximum amount of remediations within an hour across all machines
+	RateLimit int
+}
+
+// Remediator executes remediation actions on machines which have issues.
+type Remediator struct {
+	log           *slog.Logger
+	ds            datastore.Store
+	publisher     bus.Publisher
+	audits        []auditing.Auditing
+	ipmiSuperUser metal.MachineIPMISuperUser
+	config        RemediatorConfig
+}
+
+// NewRemediator returns a new remediator.
+func NewRemediator(log *slog.Logger, ds datastore.Store, publisher bus.Publisher, audits []auditing.Auditing, ipmiSuperUser metal.MachineIPMISuperUser, config RemediatorConfig) *Remediator {
+	return &Remediator{
+		log:           log,
+		ds:            ds,
+		publisher:     publisher,
+		audits:        audits,
+		ipmiSuperUser: ipmiSuperUser,
+		config:        config,
+	}
+}
+
+// Run remediates machine issues in the given interval until the context is done.
+func (r *Remediator) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			err := r.Remediate(ctx, time.Now())
+			if err != nil {
+				r.log.Error("unable to remediate machine issues", "error", err)
+			}
+		}
+	}
+}
+
+// Remediate executes the remediation actions for all machines whose issues have a policy,
+// machines which were remediated within the cooldown are skipped.
+func (r *Remediator) Remediate(ctx context.Context, now time.Time) error {
+	if len(r.config.Policies) == 0 {
+		return nil
+	}
+
+	var only []issues.Type
+	for _, p := range r.config.Policies {
+		only = append(only, p.IssueType)
+	}
+
+	ms, err := r.ds.ListMachines()
+	if err != nil {
+		return err
+	}
+
+	ecs, err := r.ds.ListProvisioningEventContainers()
+	if err != nil {
+		return err
+	}
+
+	sizes, err := r.ds.ListSizes()
+	if err != nil {
+		return err
+	}
+
+	snapshots, err := r.ds.ListLatestHardwareSnapshots()
+	if err != nil {
+		return err
+	}
+
+	machinesWithIssues, err := issues.Find(&issues.Config{
+		Machines:          ms,
+		EventContainers:   ecs,
+		Sizes:             sizes,
+		HardwareSnapshots: snapshots,
+		Only:              only,
+	})
+	if err != nil {
+		return err
+	}
+
+	mrs, err := r.ds.ListMachineRemediations()
+	if err != nil {
+		return err
+	}
+
+	var (
+		remediationsByID = mrs.ByID()
+		budget           = r.config.RateLimit - mrs.CountSince(now.Add(-remediationRateLimitWindow))
+		remediated       = 0
+		errs             []error
+	)
+
+	for _, mwi := range machinesWithIssues.ToList() {
+		m := mwi.Machine
+
+		if m.State.Value == metal.LockedState || m.Maintenance != nil {
+			continue
+		}
+
+		policy := r.policyFor(mwi.Issues)
+		if policy == nil {
+			continue
+		}
+		if policy.Action == metal.RemediationActionTaint && m.State.Value == metal.TaintedState {
+			continue
+		}
+
+		var existing *metal.MachineRemediation
+		if mr, ok := remediationsByID[m.ID]; ok {
+			existing = &mr
+			if last := mr.Last(); last != nil && now.Before(last.Time.Add(r.config.Cooldown)) {
+				continue
+			}
+		}
+
+		if budget <= 0 {
+			r.log.Warn("remediation rate limit reached, skipping remaining machines", "rateLimit", r.config.RateLimit)
+			break
+		}
+
+		claimed, err := r.claim(existing, m.ID, metal.Remediation{Time: now, IssueType: string(policy.IssueType), Action: policy.Action})
+		if err != nil {
+			if metal.IsConflict(err) {
+				// another metal-api instance remediates this machine
+				continue
+			}
+			errs = append(errs, err)
+			continue
+		}
+
+		budget--
+
+		within, err := r.withinRateLimit(m.ID, now)
+		if err == nil && !within {
+			err = r.release(existing, claimed)
+			if err == nil {
+				r.log.Warn("remediation rate limit reached by parallel remediations, skipping remaining machines", "rateLimit", r.config.RateLimit)
+				break
+			}
+		}
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+
+		remediated++
+
+		err = r.execute(ctx, m, *policy)
+		if err != nil {
+			r.log.Error("remediation failed", "machineID", m.ID, "issue", policy.IssueType, "action", policy.Action, "error", err)
+
+			failed := *claimed
+			failed.Remediations = slices.Clone(claimed.Remediations)
+			failed.Last().Error = err.Error()
+			if updateErr := r.ds.UpdateMachineRemediation(claimed, &failed); updateErr != nil {
+				errs = append(errs, updateErr)
+			}
+		} else {
+			r.log.Info("remediated machine", "machineID", m.ID, "issue", policy.IssueType, "action", policy.Action)
+		}
+
+		r.audit(m, *policy, err)
+	}
+
+	r.log.Info("machine issues remediated", "remediated", remediated, "errors", len(errs))
+
+	return errors.Join(errs...)
+}
+
+func (r *Remediator) policyFor(is issues.Issues) *RemediationPolicy {
+	for _, p := range r.config.Policies {
+		for _, i := range is {
+			if i.Type == p.IssueType {
+				return &p
+			}
+		}
+	}
+	return nil
+}
+
+// claim records the remediation before it is executed, such that it is not executed by another metal-api instance at the same time.
+func (r *Remediator) claim(existing *metal.MachineRemediation, machineID string, remediation metal.Remediation) (*metal.MachineRemediation, error) {
+	if existing == nil {
+		mr := &metal.MachineRemediation{Base: metal.Base{ID: machineID}}
+		mr.Add(remediation)
+		return mr, r.ds.CreateMachineRemediation(mr)
+	}
+
+	mr := *existing
+	mr.Remediations = slices.Clone(existing.Remediations)
+	mr.Add(remediation)
+
+	return &mr, r.ds.UpdateMachineRemediation(existing, &mr)
+}
+
+// withinRateLimit returns true if the just claimed remediation of the given machine is among the oldest remediations
+// permitted by the rate limit. It is checked after the claim was persisted because other metal-api instances
+// might have claimed remediations in parallel.
+func (r *Remediator) withinRateLimit(machineID string, now time.Time) (bool, error) {
+	mrs, err := r.ds.ListMachineRemediations()
+	if err != nil {
+		return false, err
+	}
+
+	type claim struct {
+		machineID string
+		time      time.Time
+	}
+
+	var (
+		since  = now.Add(-remediationRateLimitWindow)
+		claims []claim
+		own    *claim
+	)
+	for _, mr := range mrs {
+		for _, rm := range mr.Remediations {
+			if rm.Time.After(since) {
+				claims = append(claims, claim{machineID: mr.ID, time: rm.Time})
+			}
+		}
+		if mr.ID == machineID {
+			if last := mr.Last(); last != nil {
+				own = &claim{machineID: mr.ID, time: last.Time}
+			}
+		}
+	}
+	if own == nil {
+		return false, fmt.Errorf("claimed remediation of machine %s not found", machineID)
+	}
+
+	slices.SortFunc(claims, func(a, b claim) int {
+		return cmp.Or(a.time.Compare(b.time), strings.Compare(a.machineID, b.machineID))
+	})
+
+	idx := slices.IndexFunc(claims, func(c claim) bool {
+		return c.machineID == own.machineID && c.time.Equal(own.time)
+	})
+
+	return idx < r.config.RateLimit, nil
+}
+
+// release reverts the given claim, such that the remediation is not counted by the rate limit.
+func (r *Remediator) release(existing, claimed *metal.MachineRemediation) error {
+	if existing == nil {
+		return r.ds.DeleteMachineRemediation(claimed)
+	}
+
+	restored := *existing
+	return r.ds.UpdateMachineRemediation(claimed, &restored)
+}
+
+func (r *Remediator) execute(ctx context.Context, m *metal.Machine, policy RemediationPolicy) error {
+	if policy.Action == metal.RemediationActionTaint {
+		old := *m
+		tainted := *m
+		tainted.State = metal.MachineState{
+			Value:              metal.TaintedState,
+			Description:        fmt.Sprintf("tainted by automated remediation of issue %s", policy.IssueType),
+			Issuer:             "metal-api",
+			MetalHammerVersion: m.State.MetalHammerVersion,
+		}
+
+		return r.ds.UpdateMachine(&old, &tainted)
+	}
+
+	target := *m
+	withIPMISuperUserFallback(&target, r.ipmiSuperUser)
+
+	for _, cmd := range policy.Action.MachineCommands() {
+		if cmd == metal.MachineResetCmd || cmd == metal.MachineCycleCmd {
+			ev := metal.ProvisioningEvent{
+				Time:    time.Now(),
+				Event:   metal.ProvisioningEventPlannedReboot,
+				Message: fmt.Sprintf("%s by automated remediation of issue %s", cmd, policy.IssueType),
+			}
+			_, err := r.ds.ProvisioningEventForMachine(ctx, r.log, &ev, m.ID)
+			if err != nil {
+				return err
+			}
+		}
+
+		err := publishMachineCmd(r.log, r.ds, &target, r.publisher, cmd)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (r *Remediator) audit(m *metal.Machine, policy RemediationPolicy, remediationErr error) {
+	entry := auditing.Entry{
+		Type:      auditing.EntryTypeEvent,
+		Timestamp: time.Now(),
+		User:      "metal-api",
+		Detail:    auditing.EntryDetail("remediation"),
+		Phase:     auditing.EntryPhaseSingle,
+		Path:      fmt.Sprintf("/v1/machine/%s/remediation/%s", m.ID, policy.Action),
+		Body: map[string]any{
+			"machineid": m.ID,
+			"partition": m.PartitionID,
+			"issue":     string(policy.IssueType),
+			"action":    string(policy.Action),
+		},
+	}
+	if m.Allocation != nil {
+		entry.Project = m.Allocation.Project
+	}
+	if remediationErr != nil {
+		entry.Phase = auditing.EntryPhaseError
+		entry.Error = remediationErr.Error()
+	}
+
+	for _, a := range r.audits {
+		err := a.Index(entry)
+		if err != nil {
+			r.log.Error("unable to audit remediation", "machineID", m.ID, "error", err)
+		}
+	}
+}
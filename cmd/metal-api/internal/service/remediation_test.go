@@ -0,0 +1,206 @@
+package service
+
+import (
+	"context"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/metal-stack/metal-api/cmd/metal-api/internal/datastore"
+	"github.com/metal-stack/metal-api/cmd/metal-api/internal/issues"
+	"github.com/metal-stack/metal-api/cmd/metal-api/internal/metal"
+	"github.com/metal-stack/metal-lib/auditing"
+	auditingmemory "github.com/metal-stack/metal-lib/auditing/memory"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseRemediationPolicies(t *testing.T) {
+	policies, err := ParseRemediationPolicies([]string{"liveliness-dead=power-cycle", "crashloop=taint"})
+	require.NoError(t, err)
+	require.Equal(t, []RemediationPolicy{
+		{IssueType: issues.TypeLivelinessDead, Action: metal.RemediationActionPowerCycle},
+		{IssueType: issues.TypeCrashLoop, Action: metal.RemediationActionTaint},
+	}, policies)
+
+	_, err = ParseRemediationPolicies([]string{"liveliness-dead"})
+	require.EqualError(t, err, `remediation policy "liveliness-dead" must be in the form <issue-type>=<action>`)
+
+	_, err = ParseRemediationPolicies([]string{"liveliness-dead=explode"})
+	require.EqualError(t, err, `unknown remediation action: "explode"`)
+
+	_, err = ParseRemediationPolicies([]string{"liveliness-dead=taint", "liveliness-dead=power-cycle"})
+	require.EqualError(t, err, `remediation policy for issue type "liveliness-dead" is defined more than once`)
+}
+
+func TestRemediator_Remediate(t *testing.T) {
+	var (
+		ctx = context.Background()
+		log = slog.Default()
+		ds  = datastore.NewMemory(log)
+		now = time.Now()
+	)
+
+	for _, m := range []metal.Machine{
+		{Base: metal.Base{ID: "dead"}, PartitionID: "p1", State: metal.MachineState{Value: metal.AvailableState}},
+		{Base: metal.Base{ID: "crashing"}, PartitionID: "p1", State: metal.MachineState{Value: metal.AvailableState}},
+		{Base: metal.Base{ID: "locked"}, PartitionID: "p1", State: metal.MachineState{Value: metal.LockedState}},
+		{Base: metal.Base{ID: "healthy"}, PartitionID: "p1", State: metal.MachineState{Value: metal.AvailableState}},
+	} {
+		require.NoError(t, ds.CreateMachine(&m))
+	}
+	for _, ec := range []metal.ProvisioningEventContainer{
+		{Base: metal.Base{ID: "dead"}, Liveliness: metal.MachineLivelinessDead},
+		{Base: metal.Base{ID: "crashing"}, Liveliness: metal.MachineLivelinessAlive, CrashLoop: true, Events: metal.ProvisioningEvents{{Event: metal.ProvisioningEventPXEBooting}}},
+		{Base: metal.Base{ID: "locked"}, Liveliness: metal.MachineLivelinessDead},
+		{Base: metal.Base{ID: "healthy"}, Liveliness: metal.MachineLivelinessAlive},
+	} {
+		require.NoError(t, ds.CreateProvisioningEventContainer(&ec))
+	}
+
+	var published []metal.MachineEvent
+	publisher := &emptyPublisher{doPublish: func(topic string, data any) error {
+		published = append(published, data.(metal.MachineEvent))
+		return nil
+	}}
+
+	audit, err := auditingmemory.NewMemory(auditing.Config{Component: "metal-api", Log: log}, auditingmemory.MemoryConfig{})
+	require.NoError(t, err)
+
+	r := NewRemediator(log, ds, publisher, []auditing.Auditing{audit}, metal.DisabledIPMISuperUser(), RemediatorConfig{
+		Policies: []RemediationPolicy{
+			{IssueType: issues.TypeLivelinessDead, Action: metal.RemediationActionPowerCycle},
+			{IssueType: issues.TypeCrashLoop, Action: metal.RemediationActionTaint},
+		},
+		Cooldown:  time.Hour,
+		RateLimit: 10,
+	})
+
+	require.NoError(t, r.Remediate(ctx, now))
+
+	require.Len(t, published, 1)
+	require.Equal(t, "dead", published[0].Cmd.TargetMachineID)
+	require.Equal(t, metal.MachineCycleCmd, published[0].Cmd.Command)
+
+	crashing, err := ds.FindMachineByID("crashing")
+	require.NoError(t, err)
+	require.Equal(t, metal.TaintedState, crashing.State.Value)
+
+	mrs, err := ds.ListMachineRemediations()
+	require.NoError(t, err)
+	require.Len(t, mrs, 2)
+
+	entries, err := audit.Search(ctx, auditing.EntryFilter{Type: auditing.EntryTypeEvent})
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+
+	// the machines are still within their cooldown
+	require.NoError(t, r.Remediate(ctx, now.Add(30*time.Minute)))
+	require.Len(t, published, 1)
+
+	// the planned reboot event of the power cycle made the dead machine alive again,
+	// the crashing machine is already tainted
+	require.NoError(t, r.Remediate(ctx, now.Add(2*time.Hour)))
+	require.Len(t, published, 1)
+
+	dead, err := ds.FindMachineRemediation("dead")
+	require.NoError(t, err)
+	require.Len(t, dead.Remediations, 1)
+
+	crashingRemediation, err := ds.FindMachineRemediation("crashing")
+	require.NoError(t, err)
+	require.Len(t, crashingRemediation.Remediations, 1)
+	require.Equal(t, metal.RemediationActionTaint, crashingRemediation.Last().Action)
+}
+
+func TestRemediator_RemediateRateLimit(t *testing.T) {
+	var (
+		ctx = context.Background()
+		log = slog.Default()
+		ds  = datastore.NewMemory(log)
+		now = time.Now()
+	)
+
+	for _, id := range []string{"m1", "m2", "m3"} {
+		require.NoError(t, ds.CreateMachine(&metal.Machine{Base: metal.Base{ID: id}, PartitionID: "p1"}))
+		require.NoError(t, ds.CreateProvisioningEventContainer(&metal.ProvisioningEventContainer{Base: metal.Base{ID: id}, Liveliness: metal.MachineLivelinessDead}))
+	}
+
+	published := 0
+	publisher := &emptyPublisher{doPublish: func(topic string, data any) error {
+		published++
+		return nil
+	}}
+
+	r := NewRemediator(log, ds, publisher, nil, metal.DisabledIPMISuperUser(), RemediatorConfig{
+		Policies:  []RemediationPolicy{{IssueType: issues.TypeLivelinessDead, Action: metal.RemediationActionPXEReboot}},
+		Cooldown:  time.Minute,
+		RateLimit: 2,
+	})
+
+	require.NoError(t, r.Remediate(ctx, now))
+	// a pxe reboot consists of two commands
+	require.Equal(t, 4, published)
+
+	require.NoError(t, r.Remediate(ctx, now.Add(10*time.Minute)))
+	require.Equal(t, 4, published, "rate limit must be reached")
+
+	// the remediated machines are alive again because of the planned reboot, only the remaining one gets remediated
+	require.NoError(t, r.Remediate(ctx, now.Add(remediationRateLimitWindow+time.Minute)))
+	require.Equal(t, 6, published)
+}
+
+// claimHookStore calls the hook once before the first machine remediation is created.
+type claimHookStore struct {
+	datastore.Store
+	hook func()
+}
+
+func (s *claimHookStore) CreateMachineRemediation(mr *metal.MachineRemediation) error {
+	if s.hook != nil {
+		hook := s.hook
+		s.hook = nil
+		hook()
+	}
+	return s.Store.CreateMachineRemediation(mr)
+}
+
+func TestRemediator_RemediateRateLimitParallel(t *testing.T) {
+	var (
+		ctx = context.Background()
+		log = slog.Default()
+		ds  = datastore.NewMemory(log)
+		now = time.Now()
+	)
+
+	for _, id := range []string{"m1", "m2", "m3"} {
+		require.NoError(t, ds.CreateMachine(&metal.Machine{Base: metal.Base{ID: id}, PartitionID: "p1"}))
+		require.NoError(t, ds.CreateProvisioningEventContainer(&metal.ProvisioningEventContainer{Base: metal.Base{ID: id}, Liveliness: metal.MachineLivelinessDead}))
+	}
+
+	var remediated []string
+	publisher := &emptyPublisher{doPublish: func(topic string, data any) error {
+		remediated = append(remediated, data.(metal.MachineEvent).Cmd.TargetMachineID)
+		return nil
+	}}
+
+	config := RemediatorConfig{
+		Policies:  []RemediationPolicy{{IssueType: issues.TypeLivelinessDead, Action: metal.RemediationActionPowerCycle}},
+		Cooldown:  time.Minute,
+		RateLimit: 1,
+	}
+
+	// the second remediator runs after the first one computed its budget, but before it claimed its first remediation
+	second := NewRemediator(log, ds, publisher, nil, metal.DisabledIPMISuperUser(), config)
+	first := NewRemediator(log, &claimHookStore{Store: ds, hook: func() {
+		require.NoError(t, second.Remediate(ctx, now))
+	}}, publisher, nil, metal.DisabledIPMISuperUser(), config)
+
+	require.NoError(t, first.Remediate(ctx, now))
+
+	require.Equal(t, []string{"m1"}, remediated, "the rate limit must hold across both remediators")
+
+	mrs, err := ds.ListMachineRemediations()
+	require.NoError(t, err)
+	require.Len(t, mrs, 1)
+	require.Equal(t, "m1", mrs[0].ID)
+}
@@ -11,6 +11,7 @@ import (
 
 	"github.com/metal-stack/metal-api/cmd/metal-api/internal/datastore"
 	"github.com/metal-stack/metal-api/cmd/metal-api/internal/metal"
+	v1 "github.com/metal-stack/metal-api/cmd/metal-api/internal/service/v1"
 	"github.com/metal-stack/metal-lib/httperrors"
 
 	"github.com/emicklei/go-restful/v3"
@@ -28,7 +29,7 @@ var BasePath = "/"
 
 type webResource struct {
 	log *slog.Logger
-	ds  *datastore.RethinkStore
+	ds  datastore.Store
 }
 
 // logger returns the request logger from the request.
@@ -42,6 +43,21 @@ func (w *webResource) sendError(rq *restful.Request, rsp *restful.Response, http
 	w.send(rq, rsp, httperr.StatusCode, httperr)
 }
 
+// sendQuotaOrDefaultError sends the details of the exceeded quota if the error was caused by a project quota,
+// all other errors are sent as mapped by defaultError.
+func (w *webResource) sendQuotaOrDefaultError(rq *restful.Request, rsp *restful.Response, err error) {
+	qe, ok := metal.IsQuotaExceeded(err)
+	if !ok {
+		w.sendError(rq, rsp, defaultError(err))
+		return
+	}
+
+	resp := v1.NewQuotaExceededResponse(qe)
+	resp.Message = err.Error()
+	w.logger(rq).Error("service error", "status", resp.StatusCode, "error", resp.Message, "quota", resp.Quota, "max", resp.Max, "used", resp.Used)
+	w.send(rq, rsp, resp.StatusCode, resp)
+}
+
 func (w *webResource) send(rq *restful.Request, rsp *restful.Response, status int, value any) {
 	send(w.logger(rq), rsp, status, value)
 }
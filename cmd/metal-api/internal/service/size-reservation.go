@@ -0,0 +1,69 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/metal-stack/metal-api/cmd/metal-api/internal/datastore"
+	"github.com/metal-stack/metal-api/cmd/metal-api/internal/metal"
+)
+
+// SizeReservationPruner removes the size reservations whose end has passed.
+type SizeReservationPruner struct {
+	log *slog.Logger
+	ds  datastore.Store
+}
+
+// NewSizeReservationPruner returns a new size reservation pruner.
+func NewSizeReservationPruner(log *slog.Logger, ds datastore.Store) *SizeReservationPruner {
+	return &SizeReservationPruner{
+		log: log,
+		ds:  ds,
+	}
+}
+
+// Run prunes the expired size reservations in the given interval until the context is done.
+func (p *SizeReservationPruner) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			err := p.Prune(time.Now())
+			if err != nil {
+				p.log.Error("unable to prune size reservations", "error", err)
+			}
+		}
+	}
+}
+
+// Prune removes all size reservations which ended before now.
+func (p *SizeReservationPruner) Prune(now time.Time) error {
+	rvs, err := p.ds.ListSizeReservations()
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	for i := range rvs {
+		rv := &rvs[i]
+		if !rv.Expired(now) {
+			continue
+		}
+
+		err := p.ds.DeleteSizeReservation(rv)
+		if err != nil && !metal.IsNotFound(err) {
+			errs = append(errs, err)
+			continue
+		}
+
+		p.log.Info("pruned expired size reservation", "id", rv.ID, "size", rv.SizeID, "project", rv.ProjectID, "end", rv.End)
+	}
+
+	return errors.Join(errs...)
+}
@@ -0,0 +1,90 @@
+package service
+
+import (
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/metal-stack/metal-api/cmd/metal-api/internal/datastore"
+	"github.com/metal-stack/metal-api/cmd/metal-api/internal/metal"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateSizeReservationCapacity(t *testing.T) {
+	var (
+		ds  = datastore.NewMemory(slog.Default())
+		now = time.Date(2026, 11, 1, 0, 0, 0, 0, time.UTC)
+		day = func(d int) *time.Time {
+			return new(now.AddDate(0, 0, d))
+		}
+	)
+
+	for _, id := range []string{"m1", "m2", "m3", "m4"} {
+		require.NoError(t, ds.CreateMachine(&metal.Machine{Base: metal.Base{ID: id}, PartitionID: "a", SizeID: "c1"}))
+	}
+	require.NoError(t, ds.CreateMachine(&metal.Machine{Base: metal.Base{ID: "m5"}, PartitionID: "b", SizeID: "c1"}))
+
+	require.NoError(t, ds.CreateSizeReservation(&metal.SizeReservation{Base: metal.Base{ID: "permanent"}, SizeID: "c1", Amount: 1, ProjectID: "p1", PartitionIDs: []string{"a"}}))
+	require.NoError(t, ds.CreateSizeReservation(&metal.SizeReservation{Base: metal.Base{ID: "event"}, SizeID: "c1", Amount: 2, ProjectID: "p2", PartitionIDs: []string{"a"}, Start: day(10), End: day(12)}))
+
+	tests := []struct {
+		name    string
+		rv      *metal.SizeReservation
+		wantErr string
+	}{
+		{
+			name: "fits next to the event",
+			rv:   &metal.SizeReservation{Base: metal.Base{ID: "new"}, SizeID: "c1", Amount: 3, ProjectID: "p3", PartitionIDs: []string{"a"}, End: day(10)},
+		},
+		{
+			name: "fits after the event",
+			rv:   &metal.SizeReservation{Base: metal.Base{ID: "new"}, SizeID: "c1", Amount: 3, ProjectID: "p3", PartitionIDs: []string{"a"}, Start: day(12)},
+		},
+		{
+			name:    "overlaps with the event",
+			rv:      &metal.SizeReservation{Base: metal.Base{ID: "new"}, SizeID: "c1", Amount: 2, ProjectID: "p3", PartitionIDs: []string{"a"}, Start: day(11), End: day(20)},
+			wantErr: `size reservations of size "c1" would reserve 5 machines in partition "a" at 2026-11-12T00:00:00Z but the partition only contains 4 machines of this size`,
+		},
+		{
+			name: "update of the event replaces the stored version",
+			rv:   &metal.SizeReservation{Base: metal.Base{ID: "event"}, SizeID: "c1", Amount: 3, ProjectID: "p2", PartitionIDs: []string{"a"}, Start: day(10), End: day(12)},
+		},
+		{
+			name:    "every partition is checked",
+			rv:      &metal.SizeReservation{Base: metal.Base{ID: "new"}, SizeID: "c1", Amount: 2, ProjectID: "p3", PartitionIDs: []string{"a", "b"}, End: day(5)},
+			wantErr: `size reservations of size "c1" would reserve 2 machines in partition "b" at 2026-11-01T00:00:00Z but the partition only contains 1 machines of this size`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateSizeReservationCapacity(ds, tt.rv, now)
+			if tt.wantErr == "" {
+				require.NoError(t, err)
+				return
+			}
+			require.ErrorContains(t, err, tt.wantErr)
+			require.True(t, metal.IsConflict(err))
+		})
+	}
+}
+
+func TestSizeReservationPruner(t *testing.T) {
+	var (
+		ds  = datastore.NewMemory(slog.Default())
+		now = time.Now()
+	)
+
+	require.NoError(t, ds.CreateSizeReservation(&metal.SizeReservation{Base: metal.Base{ID: "permanent"}}))
+	require.NoError(t, ds.CreateSizeReservation(&metal.SizeReservation{Base: metal.Base{ID: "expired"}, End: new(now.Add(-time.Minute))}))
+	require.NoError(t, ds.CreateSizeReservation(&metal.SizeReservation{Base: metal.Base{ID: "scheduled"}, Start: new(now.Add(time.Hour)), End: new(now.Add(2 * time.Hour))}))
+
+	require.NoError(t, NewSizeReservationPruner(slog.Default(), ds).Prune(now))
+
+	rvs, err := ds.ListSizeReservations()
+	require.NoError(t, err)
+	var ids []string
+	for _, rv := range rvs {
+		ids = append(ids, rv.ID)
+	}
+	require.ElementsMatch(t, []string{"permanent", "scheduled"}, ids)
+}
@@ -5,7 +5,9 @@ import (
 	"fmt"
 	"log/slog"
 	"net/http"
+	"slices"
 	"sort"
+	"time"
 
 	mdmv1 "github.com/metal-stack/masterdata-api/api/v1"
 	mdm "github.com/metal-stack/masterdata-api/pkg/client"
@@ -26,7 +28,7 @@ type sizeResource struct {
 }
 
 // NewSize returns a webservice for size specific endpoints.
-func NewSize(log *slog.Logger, ds *datastore.RethinkStore, mdc mdm.Client) *restful.WebService {
+func NewSize(log *slog.Logger, ds datastore.Store, mdc mdm.Client) *restful.WebService {
 	r := sizeResource{
 		webResource: webResource{
 			log: log,
@@ -153,7 +155,7 @@ func (r *sizeResource) webService() *restful.WebService {
 	ws.Route(ws.PUT("/reservations").
 		To(editor(r.createSizeReservation)).
 		Operation("createSizeReservation").
-		Doc("create a size reservation. if the given ID already exists a conflict is returned").
+		Doc("create a size reservation. if the given ID already exists or the reservations of the size would exceed the machines of a partition, a conflict is returned").
 		Metadata(restfulspec.KeyOpenAPITags, tags).
 		Reads(v1.SizeReservationCreateRequest{}).
 		Returns(http.StatusCreated, "Created", v1.SizeReservationResponse{}).
@@ -342,8 +344,9 @@ func (r *sizeResource) createSize(request *restful.Request, response *restful.Re
 			Name:        name,
 			Description: description,
 		},
-		Constraints: constraints,
-		Labels:      labels,
+		Constraints:        constraints,
+		Labels:             labels,
+		ExpectedPowerWatts: pointer.SafeDeref(requestPayload.ExpectedPowerWatts),
 	}
 
 	ss, err := r.ds.ListSizes()
@@ -435,6 +438,9 @@ func (r *sizeResource) updateSize(request *restful.Request, response *restful.Re
 	if requestPayload.Labels != nil {
 		newSize.Labels = requestPayload.Labels
 	}
+	if requestPayload.ExpectedPowerWatts != nil {
+		newSize.ExpectedPowerWatts = *requestPayload.ExpectedPowerWatts
+	}
 	var constraints []metal.Constraint
 	if requestPayload.SizeConstraints != nil {
 		sizeConstraints := *requestPayload.SizeConstraints
@@ -556,6 +562,8 @@ func (r *sizeResource) createSizeReservation(request *restful.Request, response
 		ProjectID:    requestPayload.ProjectID,
 		PartitionIDs: requestPayload.PartitionIDs,
 		Labels:       requestPayload.Labels,
+		Start:        requestPayload.Start,
+		End:          requestPayload.End,
 	}
 
 	size, err := r.ds.FindSize(requestPayload.SizeID)
@@ -582,6 +590,12 @@ func (r *sizeResource) createSizeReservation(request *restful.Request, response
 		return
 	}
 
+	err = validateSizeReservationCapacity(r.ds, rv, time.Now())
+	if err != nil {
+		r.sendError(request, response, defaultError(err))
+		return
+	}
+
 	err = r.ds.CreateSizeReservation(rv)
 	if err != nil {
 		r.sendError(request, response, defaultError(err))
@@ -626,6 +640,20 @@ func (r *sizeResource) updateSizeReservation(request *restful.Request, response
 	if len(requestPayload.PartitionIDs) > 0 {
 		rv.PartitionIDs = requestPayload.PartitionIDs
 	}
+	if requestPayload.Start != nil {
+		if requestPayload.Start.IsZero() {
+			rv.Start = nil
+		} else {
+			rv.Start = requestPayload.Start
+		}
+	}
+	if requestPayload.End != nil {
+		if requestPayload.End.IsZero() {
+			rv.End = nil
+		} else {
+			rv.End = requestPayload.End
+		}
+	}
 
 	size, err := r.ds.FindSize(rv.SizeID)
 	if err != nil {
@@ -651,6 +679,12 @@ func (r *sizeResource) updateSizeReservation(request *restful.Request, response
 		return
 	}
 
+	err = validateSizeReservationCapacity(r.ds, &rv, time.Now())
+	if err != nil {
+		r.sendError(request, response, defaultError(err))
+		return
+	}
+
 	err = r.ds.UpdateSizeReservation(oldRv, &rv)
 	if err != nil {
 		r.sendError(request, response, defaultError(err))
@@ -710,12 +744,20 @@ func (r *sizeResource) sizeReservationsUsage(request *restful.Request, response
 	var (
 		result              []*v1.SizeReservationUsageResponse
 		machinesByProjectID = ms.ByProjectID()
+		now                 = time.Now()
 	)
 
 	for _, reservation := range rvs {
+		active := reservation.IsActive(now)
+
 		for _, partitionID := range reservation.PartitionIDs {
 			allocations := len(machinesByProjectID[reservation.ProjectID].WithPartition(partitionID).WithSize(reservation.SizeID))
 
+			usedAmount := 0
+			if active {
+				usedAmount = min(reservation.Amount, allocations)
+			}
+
 			result = append(result, &v1.SizeReservationUsageResponse{
 				Common: v1.Common{
 					Identifiable: v1.Identifiable{ID: reservation.ID},
@@ -725,9 +767,12 @@ func (r *sizeResource) sizeReservationsUsage(request *restful.Request, response
 				PartitionID:        partitionID,
 				ProjectID:          reservation.ProjectID,
 				Amount:             reservation.Amount,
-				UsedAmount:         min(reservation.Amount, allocations),
+				UsedAmount:         usedAmount,
 				ProjectAllocations: allocations,
 				Labels:             reservation.Labels,
+				Active:             active,
+				Start:              reservation.Start,
+				End:                reservation.End,
 			})
 		}
 	}
@@ -735,6 +780,48 @@ func (r *sizeResource) sizeReservationsUsage(request *restful.Request, response
 	r.send(request, response, http.StatusOK, result)
 }
 
+// validateSizeReservationCapacity returns an error if the given reservation together with the other reservations of its size
+// would reserve more machines than a partition contains at any point in time within the window of the reservation.
+func validateSizeReservationCapacity(ds datastore.Store, rv *metal.SizeReservation, now time.Time) error {
+	from := now
+	if rv.Start != nil && rv.Start.After(now) {
+		from = *rv.Start
+	}
+
+	for _, partitionID := range rv.PartitionIDs {
+		var ms metal.Machines
+		err := ds.SearchMachines(&datastore.MachineSearchQuery{
+			PartitionID: &partitionID,
+			SizeID:      &rv.SizeID,
+		}, &ms)
+		if err != nil {
+			return err
+		}
+
+		var rvs metal.SizeReservations
+		err = ds.SearchSizeReservations(&datastore.SizeReservationSearchQuery{
+			SizeID:    &rv.SizeID,
+			Partition: &partitionID,
+		}, &rvs)
+		if err != nil {
+			return err
+		}
+
+		// on update, the stored version of the reservation is replaced by the given one
+		rvs = slices.DeleteFunc(rvs, func(other metal.SizeReservation) bool {
+			return other.ID == rv.ID
+		})
+		rvs = append(rvs, *rv)
+
+		peak, at := rvs.PeakAmount(from, rv.End)
+		if peak > len(ms) {
+			return metal.Conflict("size reservations of size %q would reserve %d machines in partition %q at %s but the partition only contains %d machines of this size", rv.SizeID, peak, partitionID, at.Format(time.RFC3339), len(ms))
+		}
+	}
+
+	return nil
+}
+
 // longestCommonPrefix finds the longest prefix of a slice of strings.
 func longestCommonPrefix(strs []string) string {
 	longestPrefix := ""
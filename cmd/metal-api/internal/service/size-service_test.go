@@ -7,6 +7,7 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
 	restful "github.com/emicklei/go-restful/v3"
 	"github.com/google/go-cmp/cmp"
@@ -389,6 +390,7 @@ func Test_ListSizeReservationsUsage(t *testing.T) {
 					Amount:             3,
 					UsedAmount:         1,
 					ProjectAllocations: 1,
+					Active:             true,
 				},
 			},
 		},
@@ -716,6 +718,8 @@ func Test_CreateSizeReservationsUsage(t *testing.T) {
 			dbMockFn: func(mock *r.Mock) {
 				mock.On(r.DB("mockdb").Table("size").Get("1")).Return(metal.Size{Base: metal.Base{ID: "1"}}, nil)
 				mock.On(r.DB("mockdb").Table("partition")).Return(metal.Partitions{{Base: metal.Base{ID: "a"}}}, nil)
+				mock.On(r.DB("mockdb").Table("machine").Filter(r.MockAnything()).Filter(r.MockAnything())).Return(metal.Machines{{Base: metal.Base{ID: "m1"}}, {Base: metal.Base{ID: "m2"}}, {Base: metal.Base{ID: "m3"}}}, nil)
+				mock.On(r.DB("mockdb").Table("sizereservation").Filter(r.MockAnything()).Filter(r.MockAnything())).Return(metal.SizeReservations{}, nil)
 				mock.On(r.DB("mockdb").Table("sizereservation").Insert(r.MockAnything())).Return(testdata.EmptyResult, nil)
 			},
 			projectMockFn: func(mock *testifymock.Mock) {
@@ -790,6 +794,8 @@ func Test_UpdateSizeReservationsUsage(t *testing.T) {
 				mock.On(r.DB("mockdb").Table("size").Get("1")).Return(metal.Size{Base: metal.Base{ID: "1"}}, nil)
 				mock.On(r.DB("mockdb").Table("sizereservation").Get("1")).Return(metal.SizeReservation{Base: metal.Base{ID: "1"}, SizeID: "1", ProjectID: "p1"}, nil)
 				mock.On(r.DB("mockdb").Table("partition")).Return(metal.Partitions{{Base: metal.Base{ID: "b"}}}, nil)
+				mock.On(r.DB("mockdb").Table("machine").Filter(r.MockAnything()).Filter(r.MockAnything())).Return(metal.Machines{{Base: metal.Base{ID: "m1"}}, {Base: metal.Base{ID: "m2"}}, {Base: metal.Base{ID: "m3"}}, {Base: metal.Base{ID: "m4"}}}, nil)
+				mock.On(r.DB("mockdb").Table("sizereservation").Filter(r.MockAnything()).Filter(r.MockAnything())).Return(metal.SizeReservations{{Base: metal.Base{ID: "1"}, SizeID: "1", Amount: 1, ProjectID: "p1", PartitionIDs: []string{"b"}}}, nil)
 				mock.On(r.DB("mockdb").Table("sizereservation").Get("1").Replace(r.MockAnything())).Return(testdata.EmptyResult, nil)
 			},
 			projectMockFn: func(mock *testifymock.Mock) {
@@ -814,6 +820,43 @@ func Test_UpdateSizeReservationsUsage(t *testing.T) {
 				},
 			},
 		},
+		{
+			name: "remove the time window",
+			req: &v1.SizeReservationUpdateRequest{
+				Common: v1.Common{
+					Identifiable: v1.Identifiable{ID: "1"},
+				},
+				Start: &time.Time{},
+				End:   &time.Time{},
+			},
+			dbMockFn: func(mock *r.Mock) {
+				start := time.Date(2026, 11, 1, 0, 0, 0, 0, time.UTC)
+				mock.On(r.DB("mockdb").Table("size").Get("1")).Return(metal.Size{Base: metal.Base{ID: "1"}}, nil)
+				mock.On(r.DB("mockdb").Table("sizereservation").Get("1")).Return(metal.SizeReservation{Base: metal.Base{ID: "1"}, SizeID: "1", ProjectID: "p1", Amount: 1, PartitionIDs: []string{"b"}, Start: &start, End: new(start.AddDate(0, 0, 1))}, nil)
+				mock.On(r.DB("mockdb").Table("partition")).Return(metal.Partitions{{Base: metal.Base{ID: "b"}}}, nil)
+				mock.On(r.DB("mockdb").Table("machine").Filter(r.MockAnything()).Filter(r.MockAnything())).Return(metal.Machines{{Base: metal.Base{ID: "m1"}}}, nil)
+				mock.On(r.DB("mockdb").Table("sizereservation").Filter(r.MockAnything()).Filter(r.MockAnything())).Return(metal.SizeReservations{}, nil)
+				mock.On(r.DB("mockdb").Table("sizereservation").Get("1").Replace(r.MockAnything())).Return(testdata.EmptyResult, nil)
+			},
+			projectMockFn: func(mock *testifymock.Mock) {
+				mock.On("Get", testifymock.Anything, &mdmv1.ProjectGetRequest{Id: "p1"}).Return(&mdmv1.ProjectResponse{Project: &mdmv1.Project{Meta: &mdmv1.Meta{Id: "p1"}}}, nil)
+			},
+			want: &v1.SizeReservationResponse{
+				Common: v1.Common{
+					Identifiable: v1.Identifiable{
+						ID: "1",
+					},
+					Describable: v1.Describable{
+						Name:        new(""),
+						Description: new(""),
+					},
+				},
+				SizeID:       "1",
+				PartitionIDs: []string{"b"},
+				ProjectID:    "p1",
+				Amount:       1,
+			},
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -21,7 +21,7 @@ type sizeImageConstraintResource struct {
 }
 
 // NewSize returns a webservice for size specific endpoints.
-func NewSizeImageConstraint(log *slog.Logger, ds *datastore.RethinkStore) *restful.WebService {
+func NewSizeImageConstraint(log *slog.Logger, ds datastore.Store) *restful.WebService {
 	r := sizeImageConstraintResource{
 		webResource: webResource{
 			log: log,
@@ -251,7 +251,7 @@ func (r *sizeImageConstraintResource) trySizeImageConstraint(request *restful.Re
 	r.send(request, response, http.StatusOK, v1.EmptyBody{})
 }
 
-func isSizeAndImageCompatible(ds *datastore.RethinkStore, size metal.Size, image metal.Image) error {
+func isSizeAndImageCompatible(ds datastore.Store, size metal.Size, image metal.Image) error {
 	sic, err := ds.FindSizeImageConstraint(size.ID)
 	if err != nil && !metal.IsNotFound(err) {
 		return err
@@ -28,7 +28,7 @@ type switchResource struct {
 }
 
 // NewSwitch returns a webservice for switch specific endpoints.
-func NewSwitch(log *slog.Logger, ds *datastore.RethinkStore) *restful.WebService {
+func NewSwitch(log *slog.Logger, ds datastore.Store) *restful.WebService {
 	r := switchResource{
 		webResource: webResource{
 			log: log,
@@ -626,6 +626,14 @@ func (r *switchResource) registerSwitch(request *restful.Request, response *rest
 
 	}
 
+	unknownRack, err := datastore.UnknownRack(r.ds, s.RackID)
+	if err != nil {
+		r.log.Error("unable to check rack of switch", "id", s.ID, "rack", s.RackID, "error", err)
+	} else if unknownRack {
+		// the registration is not rejected, such that the switch can still be managed until the rack is modeled
+		r.log.Warn("switch registered in a rack without location, it is not attributed to any failure domain", "id", s.ID, "rack", s.RackID)
+	}
+
 	resp, err := r.makeSwitchResponse(s)
 	if err != nil {
 		r.sendError(request, response, defaultError(err))
@@ -1331,7 +1339,7 @@ func (r *switchResource) makeSwitchResponseList(ss metal.Switches) ([]*v1.Switch
 	return result, nil
 }
 
-func getSwitchReferencedEntityMaps(ds *datastore.RethinkStore) (metal.PartitionMap, metal.NetworkMap, metal.IPsMap, error) {
+func getSwitchReferencedEntityMaps(ds datastore.Store) (metal.PartitionMap, metal.NetworkMap, metal.IPsMap, error) {
 	p, err := ds.ListPartitions()
 	if err != nil {
 		return nil, nil, nil, fmt.Errorf("partitions could not be listed: %w", err)
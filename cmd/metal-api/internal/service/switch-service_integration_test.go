@@ -18,17 +18,18 @@ import (
 	"github.com/metal-stack/metal-api/cmd/metal-api/internal/ipam"
 	"github.com/metal-stack/metal-api/cmd/metal-api/internal/metal"
 	v1 "github.com/metal-stack/metal-api/cmd/metal-api/internal/service/v1"
-	"github.com/metal-stack/metal-api/test"
 	"github.com/metal-stack/metal-lib/bus"
 	"github.com/metal-stack/security"
 	testifymock "github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/require"
-	"github.com/testcontainers/testcontainers-go"
 )
 
 func TestSwitchMigrateIntegration(t *testing.T) {
-	ts := createTestService(t)
-	defer ts.terminate()
+	forEachStore(t, slog.Default(), testSwitchMigrate)
+}
+
+func testSwitchMigrate(t *testing.T, ds datastore.Store) {
+	ts := createTestService(t, ds)
 
 	testPartitionID := "test-partition"
 	testRackID := "test-rack"
@@ -213,8 +214,11 @@ func TestSwitchMigrateIntegration(t *testing.T) {
 }
 
 func TestSwitchReplaceIntegration(t *testing.T) {
-	ts := createTestService(t)
-	defer ts.terminate()
+	forEachStore(t, slog.Default(), testSwitchReplace)
+}
+
+func testSwitchReplace(t *testing.T, ds datastore.Store) {
+	ts := createTestService(t, ds)
 
 	testPartitionID := "test-partition"
 	testRackID := "test-rack"
@@ -434,27 +438,16 @@ type testService struct {
 	partitionService *restful.WebService
 	switchService    *restful.WebService
 	machineService   *restful.WebService
-	ds               *datastore.RethinkStore
-	rethinkContainer testcontainers.Container
+	ds               datastore.Store
 	ctx              context.Context
 	t                *testing.T
 }
 
-func (ts *testService) terminate() {
-	_ = ts.rethinkContainer.Terminate(ts.ctx)
-}
-
-func createTestService(t *testing.T) testService {
+func createTestService(t *testing.T, ds datastore.Store) testService {
 	ipamer := ipam.InitTestIpam(t)
-	rethinkContainer, c, err := test.StartRethink(t)
-	require.NoError(t, err)
-
 	log := slog.Default()
-	ds := datastore.New(log, c.IP+":"+c.Port, c.DB, c.User, c.Password)
-	ds.VRFPoolRangeMax = 1000
-	ds.ASNPoolRangeMax = 1000
 
-	err = ds.Connect()
+	err := ds.Connect()
 	require.NoError(t, err)
 	err = ds.Initialize()
 	require.NoError(t, err)
@@ -472,7 +465,7 @@ func createTestService(t *testing.T) testService {
 
 	hma := security.NewHMACAuth(testUserDirectory.admin.Name, []byte{1, 2, 3}, security.WithUser(testUserDirectory.admin))
 	usergetter := security.NewCreds(security.WithHMAC(hma))
-	machineService, err := NewMachine(log, ds, &emptyPublisher{}, bus.DirectEndpoints(), ipamer, mdc, nil, usergetter, 0, nil, metal.DisabledIPMISuperUser())
+	machineService, err := NewMachine(log, ds, &emptyPublisher{}, bus.DirectEndpoints(), ipamer, mdc, nil, usergetter, 0, nil, metal.DisabledIPMISuperUser(), 100)
 	require.NoError(t, err)
 	switchService := NewSwitch(log, ds)
 	require.NoError(t, err)
@@ -484,7 +477,6 @@ func createTestService(t *testing.T) testService {
 		switchService:    switchService,
 		machineService:   machineService,
 		ds:               ds,
-		rethinkContainer: rethinkContainer,
 		ctx:              context.TODO(),
 		t:                t,
 	}
@@ -1,5 +1,12 @@
 package v1
 
+import (
+	"strings"
+	"time"
+
+	"github.com/metal-stack/metal-api/cmd/metal-api/internal/metal"
+)
+
 type FirewallCreateRequest struct {
 	MachineAllocateRequest
 	FirewallAllocateRequest
@@ -31,3 +38,87 @@ type FirewallResponse struct {
 type FirewallFindRequest struct {
 	MachineFindRequest
 }
+
+type FirewallRulesUpdateRequest struct {
+	FirewallRules FirewallRules `json:"firewall_rules" description:"the egress and ingress firewall rules which replace the current rules of the firewall"`
+}
+
+type FirewallRuleRevisionResponse struct {
+	MachineID      string        `json:"machineid" description:"the id of the firewall"`
+	AllocationUUID string        `json:"allocationuuid" description:"the uuid of the firewall allocation this revision belongs to"`
+	Revision       int           `json:"revision" description:"the number of the revision, the first revision contains the rules given at allocation time"`
+	FirewallRules  FirewallRules `json:"firewall_rules" description:"the egress and ingress firewall rules of this revision"`
+	Creator        string        `json:"creator" description:"the user who created this revision"`
+	Created        time.Time     `json:"created" description:"the time when this revision was created"`
+}
+
+type FirewallRulesDiffResponse struct {
+	From           int                   `json:"from" description:"the revision the diff starts from"`
+	To             int                   `json:"to" description:"the revision the diff leads to"`
+	AddedEgress    []FirewallEgressRule  `json:"added_egress" description:"egress rules which are contained in the target revision only"`
+	RemovedEgress  []FirewallEgressRule  `json:"removed_egress" description:"egress rules which are contained in the source revision only"`
+	AddedIngress   []FirewallIngressRule `json:"added_ingress" description:"ingress rules which are contained in the target revision only"`
+	RemovedIngress []FirewallIngressRule `json:"removed_ingress" description:"ingress rules which are contained in the source revision only"`
+}
+
+func NewFirewallRules(rules *metal.FirewallRules) *FirewallRules {
+	if rules == nil {
+		return nil
+	}
+	return &FirewallRules{
+		Egress:  newFirewallEgressRules(rules.Egress),
+		Ingress: newFirewallIngressRules(rules.Ingress),
+	}
+}
+
+func NewFirewallRuleRevisionResponse(rev *metal.FirewallRuleRevision) *FirewallRuleRevisionResponse {
+	if rev == nil {
+		return nil
+	}
+	return &FirewallRuleRevisionResponse{
+		MachineID:      rev.MachineID,
+		AllocationUUID: rev.AllocationUUID,
+		Revision:       rev.Revision,
+		FirewallRules:  *NewFirewallRules(&rev.Rules),
+		Creator:        rev.Creator,
+		Created:        rev.Created,
+	}
+}
+
+func NewFirewallRulesDiffResponse(from, to int, diff metal.FirewallRulesDiff) *FirewallRulesDiffResponse {
+	return &FirewallRulesDiffResponse{
+		From:           from,
+		To:             to,
+		AddedEgress:    newFirewallEgressRules(diff.AddedEgress),
+		RemovedEgress:  newFirewallEgressRules(diff.RemovedEgress),
+		AddedIngress:   newFirewallIngressRules(diff.AddedIngress),
+		RemovedIngress: newFirewallIngressRules(diff.RemovedIngress),
+	}
+}
+
+func newFirewallEgressRules(rules []metal.EgressRule) []FirewallEgressRule {
+	var res []FirewallEgressRule
+	for _, r := range rules {
+		res = append(res, FirewallEgressRule{
+			Protocol: strings.ToLower(string(r.Protocol)),
+			Ports:    r.Ports,
+			To:       r.To,
+			Comment:  r.Comment,
+		})
+	}
+	return res
+}
+
+func newFirewallIngressRules(rules []metal.IngressRule) []FirewallIngressRule {
+	var res []FirewallIngressRule
+	for _, r := range rules {
+		res = append(res, FirewallIngressRule{
+			Protocol: strings.ToLower(string(r.Protocol)),
+			Ports:    r.Ports,
+			To:       r.To,
+			From:     r.From,
+			Comment:  r.Comment,
+		})
+	}
+	return res
+}
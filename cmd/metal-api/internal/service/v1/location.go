@@ -0,0 +1,62 @@
+package v1
+
+import (
+	"github.com/metal-stack/metal-api/cmd/metal-api/internal/metal"
+)
+
+type LocationBase struct {
+	Level       string `json:"level" enum:"region|datacenter|row|rack|power-feed" description:"the level of this location within the topology, power feeds are orthogonal to the hierarchy"`
+	ParentID    string `json:"parentid,omitempty" description:"the parent location one level above this location, required for data centers, rows and racks and optional for power feeds" optional:"true"`
+	PowerFeedID string `json:"powerfeedid,omitempty" description:"the power feed which supplies this rack, only allowed for racks" optional:"true"`
+}
+
+type LocationCreateRequest struct {
+	Common
+	LocationBase
+}
+
+type LocationUpdateRequest struct {
+	Common
+	ParentID    *string `json:"parentid,omitempty" description:"the parent location one level above this location" optional:"true"`
+	PowerFeedID *string `json:"powerfeedid,omitempty" description:"the power feed which supplies this rack, an empty string removes it" optional:"true"`
+}
+
+type LocationFindRequest struct {
+	ID          *string `json:"id,omitempty" description:"the id of the location" optional:"true"`
+	Level       *string `json:"level,omitempty" description:"the level of the location" optional:"true"`
+	ParentID    *string `json:"parentid,omitempty" description:"the parent of the location" optional:"true"`
+	PowerFeedID *string `json:"powerfeedid,omitempty" description:"the power feed which supplies the location" optional:"true"`
+}
+
+type LocationResponse struct {
+	Common
+	LocationBase
+	Timestamps
+}
+
+func NewLocationResponse(l *metal.Location) *LocationResponse {
+	if l == nil {
+		return nil
+	}
+
+	return &LocationResponse{
+		Common: Common{
+			Identifiable: Identifiable{
+				ID: l.ID,
+			},
+			Describable: Describable{
+				Name:        &l.Name,
+				Description: &l.Description,
+			},
+		},
+		LocationBase: LocationBase{
+			Level:       string(l.Level),
+			ParentID:    l.ParentID,
+			PowerFeedID: l.PowerFeedID,
+		},
+		Timestamps: Timestamps{
+			Created: l.Created,
+			Changed: l.Changed,
+		},
+	}
+}
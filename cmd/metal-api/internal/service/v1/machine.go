@@ -1,7 +1,6 @@
 package v1
 
 import (
-	"strings"
 	"time"
 
 	"github.com/metal-stack/metal-api/cmd/metal-api/internal/datastore"
@@ -47,6 +46,19 @@ type MachineAllocation struct {
 	FirewallRules    *FirewallRules            `json:"firewall_rules,omitempty" description:"a set of firewall rules to apply" optional:"true"`
 	DNSServers       []DNSServer               `json:"dns_servers,omitempty" description:"the dns servers used for the machine" optional:"true"`
 	NTPServers       []NTPServer               `json:"ntp_servers,omitempty" description:"the ntp servers used for the machine" optional:"true"`
+	Lease            *MachineLease             `json:"lease,omitempty" description:"the lease of the allocation, the machine is freed automatically when it expires" optional:"true"`
+}
+
+type MachineLease struct {
+	Duration time.Duration `json:"duration" description:"the duration the machine was leased for on allocation or the last renewal"`
+	Expires  time.Time     `json:"expires" description:"the point in time when the machine is freed unless the lease is renewed"`
+	Warned   *time.Time    `json:"warned,omitempty" description:"the point in time when the warning about the upcoming expiry was published" optional:"true"`
+	Reaping  *time.Time    `json:"reaping,omitempty" description:"the point in time when the expired lease was claimed to free the machine, the lease cannot be renewed anymore" optional:"true"`
+}
+
+// MachineLeaseRenewRequest renews the lease of an allocated machine.
+type MachineLeaseRenewRequest struct {
+	Duration *time.Duration `json:"duration,omitempty" description:"the duration the lease is extended by starting from now, defaults to the current duration of the lease" optional:"true"`
 }
 
 type FirewallRules struct {
@@ -227,20 +239,219 @@ type MachineFru struct {
 type MachineAllocateRequest struct {
 	UUID *string `json:"uuid" description:"if this field is set, this specific machine will be allocated if it is not in available state and not currently allocated. this field overrules size and partition" optional:"true"`
 	Describable
-	Hostname           *string                   `json:"hostname" description:"the hostname for the allocated machine (defaults to metal)" optional:"true"`
-	ProjectID          string                    `json:"projectid" description:"the project id to assign this machine to"`
-	PartitionID        string                    `json:"partitionid" description:"the partition id to assign this machine to"`
-	SizeID             string                    `json:"sizeid" description:"the size id to assign this machine to"`
-	ImageID            string                    `json:"imageid" description:"the image id to assign this machine to"`
-	FilesystemLayoutID *string                   `json:"filesystemlayoutid" description:"the filesystemlayout id to assign to this machine" optional:"true"`
-	SSHPubKeys         []string                  `json:"ssh_pub_keys" description:"the public ssh keys to access the machine with"`
-	UserData           *string                   `json:"user_data" description:"cloud-init.io compatible userdata must be base64 encoded" optional:"true"`
-	Tags               []string                  `json:"tags" description:"tags for this machine" optional:"true"`
-	Networks           MachineAllocationNetworks `json:"networks" description:"the networks that this machine will be placed in." optional:"true"`
-	IPs                []string                  `json:"ips" description:"the ips to attach to this machine additionally" optional:"true"`
-	PlacementTags      []string                  `json:"placement_tags,omitempty" description:"by default machines are spread across the racks inside a partition for every project. if placement tags are provided, the machine candidate has an additional anti-affinity to other machines having the same tags"`
-	DNSServers         []DNSServer               `json:"dns_servers,omitempty" description:"the dns servers used for the machine" optional:"true"`
-	NTPServers         []NTPServer               `json:"ntp_servers,omitempty" description:"the ntp servers used for the machine" optional:"true"`
+	Hostname             *string                   `json:"hostname" description:"the hostname for the allocated machine (defaults to metal)" optional:"true"`
+	ProjectID            string                    `json:"projectid" description:"the project id to assign this machine to"`
+	PartitionID          string                    `json:"partitionid" description:"the partition id to assign this machine to"`
+	SizeID               string                    `json:"sizeid" description:"the size id to assign this machine to"`
+	ImageID              string                    `json:"imageid" description:"the image id to assign this machine to"`
+	FilesystemLayoutID   *string                   `json:"filesystemlayoutid" description:"the filesystemlayout id to assign to this machine" optional:"true"`
+	SSHPubKeys           []string                  `json:"ssh_pub_keys" description:"the public ssh keys to access the machine with"`
+	UserData             *string                   `json:"user_data" description:"cloud-init.io compatible userdata must be base64 encoded" optional:"true"`
+	Tags                 []string                  `json:"tags" description:"tags for this machine" optional:"true"`
+	Networks             MachineAllocationNetworks `json:"networks" description:"the networks that this machine will be placed in." optional:"true"`
+	IPs                  []string                  `json:"ips" description:"the ips to attach to this machine additionally" optional:"true"`
+	PlacementTags        []string                  `json:"placement_tags,omitempty" description:"by default machines are spread across the racks inside a partition for every project. if placement tags are provided, the machine candidate has an additional anti-affinity to other machines having the same tags"`
+	PlacementStrategy    *string                   `json:"placement_strategy,omitempty" description:"the strategy which decides in which rack the machine is placed, defaults to the placement strategy of the partition" enum:"rack-spreading|bin-packing|switch-affinity|least-recently-reinstalled" optional:"true"`
+	PlacementSwitches    []string                  `json:"placement_switches,omitempty" description:"the ids of the switches the machine must be connected to, required for the switch-affinity placement strategy" optional:"true"`
+	PlacementSpreadLevel *string                   `json:"placement_spread_level,omitempty" description:"the failure domain level the machine is spread or packed across by the placement strategy, defaults to the level of the partition" enum:"region|datacenter|row|rack|power-feed" optional:"true"`
+	DNSServers           []DNSServer               `json:"dns_servers,omitempty" description:"the dns servers used for the machine" optional:"true"`
+	NTPServers           []NTPServer               `json:"ntp_servers,omitempty" description:"the ntp servers used for the machine" optional:"true"`
+	LeaseDuration        *time.Duration            `json:"lease_duration,omitempty" description:"if set, the machine is only leased for the given duration and freed automatically when the lease expires unless it is renewed, must be between 10 minutes and 90 days" optional:"true"`
+}
+
+// MachineBulkAllocateRequest allocates multiple machines at once. Either all machines get allocated or none of them.
+type MachineBulkAllocateRequest struct {
+	Count    *int                     `json:"count,omitempty" description:"the amount of machines to allocate from the given template, mutually exclusive with requests" optional:"true"`
+	Template *MachineAllocateRequest  `json:"template,omitempty" description:"the allocate request which is used for every machine when a count is given" optional:"true"`
+	Requests []MachineAllocateRequest `json:"requests,omitempty" description:"the allocate requests for every single machine, mutually exclusive with count and template" optional:"true"`
+}
+
+type MachineBulkAllocateResponse struct {
+	Allocations []MachineBulkAllocation `json:"allocations" description:"the allocations in the order of the requests"`
+}
+
+type MachineBulkAllocation struct {
+	AllocationUUID string          `json:"allocationuuid" description:"the unique identifier of this machine allocation"`
+	Machine        MachineResponse `json:"machine" description:"the allocated machine"`
+}
+
+// MachineQueuedAllocateRequest queues a machine allocation until a machine of the requested size becomes available in the partition.
+type MachineQueuedAllocateRequest struct {
+	MachineAllocateRequest
+	TTL      *time.Duration `json:"ttl,omitempty" description:"the duration after which the allocation expires if no machine became available, defaults to 30 minutes and must not exceed 24 hours" optional:"true"`
+	Priority *int           `json:"priority,omitempty" description:"allocations with a higher priority are fulfilled first, allocations with the same priority in the order they were queued" optional:"true"`
+}
+
+type PendingMachineAllocationResponse struct {
+	ID          string    `json:"id" description:"the unique id of the queued allocation"`
+	ProjectID   string    `json:"projectid" description:"the project the machine gets allocated for"`
+	PartitionID string    `json:"partitionid" description:"the partition the machine gets allocated in"`
+	SizeID      string    `json:"sizeid" description:"the size of the machine"`
+	Priority    int       `json:"priority" description:"the priority of the allocation"`
+	Creator     string    `json:"creator" description:"the user who queued the allocation"`
+	State       string    `json:"state" description:"the state of the allocation" enum:"pending|allocating|fulfilled|failed|expired"`
+	Position    *int      `json:"position,omitempty" description:"the position in the queue of the partition and size, only set for pending allocations" optional:"true"`
+	MachineID   *string   `json:"machineid,omitempty" description:"the id of the allocated machine when the allocation was fulfilled" optional:"true"`
+	Message     *string   `json:"message,omitempty" description:"the reason why the allocation failed or expired, or the error of the last attempt to fulfill a pending allocation" optional:"true"`
+	Expires     time.Time `json:"expires" description:"the point in time when the allocation expires if it is not fulfilled until then"`
+	Timestamps
+}
+
+// MachineHardwareSnapshotResponse is the hardware of a machine as it was reported on a registration.
+type MachineHardwareSnapshotResponse struct {
+	MachineID      string                  `json:"machineid" description:"the id of the machine"`
+	Revision       int                     `json:"revision" description:"the number of the snapshot, it is increased whenever a registration of the machine reports changed hardware"`
+	SizeID         string                  `json:"sizeid" description:"the size the machine was assigned to on registration"`
+	Hardware       MachineHardware         `json:"hardware" description:"the hardware of the machine"`
+	BIOS           MachineBIOS             `json:"bios" description:"the bios of the machine"`
+	Fru            MachineFru              `json:"fru" description:"the field replaceable unit data of the machine"`
+	Changes        []MachineHardwareChange `json:"changes" description:"the changes compared to the previous snapshot"`
+	Acknowledged   *time.Time              `json:"acknowledged,omitempty" description:"the point in time when an operator acknowledged the changes, they are reported as hardware drift issue until then" optional:"true"`
+	AcknowledgedBy string                  `json:"acknowledged_by,omitempty" description:"the user who acknowledged the changes" optional:"true"`
+	Timestamps
+}
+
+type MachineHardwareChange struct {
+	Component string `json:"component" description:"the kind of the changed component" enum:"size|memory|disk|nic|cpu|gpu|bios|fru"`
+	Type      string `json:"type" description:"the type of the change" enum:"added|removed|modified"`
+	Name      string `json:"name" description:"the name of the changed component, e.g. the name of a disk or nic"`
+	Previous  string `json:"previous" description:"the value before the change"`
+	Current   string `json:"current" description:"the value after the change"`
+}
+
+type MachineCommandResponse struct {
+	ID        string     `json:"id" description:"the id of the command execution, it is sent to the metal-bmc along with the command"`
+	MachineID string     `json:"machineid" description:"the id of the machine"`
+	Command   string     `json:"command" description:"the command which was sent to the machine"`
+	Status    string     `json:"status" enum:"queued|delivered|succeeded|failed|timed-out" description:"the execution status of the command"`
+	Message   string     `json:"message,omitempty" description:"the result message reported by the metal-bmc or the reason of a failure" optional:"true"`
+	Deadline  time.Time  `json:"deadline" description:"the time until which the result of the command is expected, it starts when the command is published to the event bus and the command times out afterwards"`
+	Delivered *time.Time `json:"delivered,omitempty" description:"the time when the metal-bmc received the command" optional:"true"`
+	Finished  *time.Time `json:"finished,omitempty" description:"the time when the command finished" optional:"true"`
+	Timestamps
+}
+
+type MachinePowerSampleResponse struct {
+	Time                  time.Time     `json:"time" description:"the time of the reading, for downsampled samples the start of the bucket"`
+	Resolution            time.Duration `json:"resolution" description:"the length of the bucket of a downsampled sample, 0 for raw samples"`
+	Samples               int           `json:"samples" description:"the amount of readings which were merged into this sample"`
+	AverageConsumedWatts  float64       `json:"averageconsumedwatts" description:"the average power consumption in watts"`
+	MinConsumedWatts      float64       `json:"minconsumedwatts" description:"the minimum power consumption in watts"`
+	MaxConsumedWatts      float64       `json:"maxconsumedwatts" description:"the maximum power consumption in watts"`
+	PowerSupplies         int           `json:"powersupplies" description:"the amount of power supplies of the machine"`
+	DegradedPowerSupplies int           `json:"degradedpowersupplies" description:"the amount of power supplies which were not healthy"`
+	PartitionID           string        `json:"partitionid" description:"the partition of the machine at the time of the reading"`
+	RackID                string        `json:"rackid" description:"the rack of the machine at the time of the reading"`
+	SizeID                string        `json:"sizeid" description:"the size of the machine at the time of the reading"`
+	ProjectID             string        `json:"projectid" description:"the project the machine was allocated to at the time of the reading, empty if it was not allocated"`
+}
+
+type MachinePowerAggregationRequest struct {
+	From        time.Time  `json:"from" description:"the start of the time window"`
+	To          *time.Time `json:"to" description:"the end of the time window, defaults to now" optional:"true"`
+	GroupBy     *string    `json:"group_by" description:"the property the consumption is grouped by, defaults to partition" enum:"rack|partition|project|size" optional:"true"`
+	PartitionID *string    `json:"partitionid" description:"only consider machines of this partition" optional:"true"`
+	RackID      *string    `json:"rackid" description:"only consider machines of this rack" optional:"true"`
+	ProjectID   *string    `json:"projectid" description:"only consider machines allocated to this project" optional:"true"`
+	SizeID      *string    `json:"sizeid" description:"only consider machines of this size" optional:"true"`
+}
+
+type MachinePowerAggregationResponse struct {
+	From    time.Time               `json:"from" description:"the start of the time window"`
+	To      time.Time               `json:"to" description:"the end of the time window"`
+	GroupBy string                  `json:"group_by" description:"the property the consumption is grouped by"`
+	Groups  []MachinePowerAggregate `json:"groups" description:"the power consumption per group"`
+}
+
+type MachinePowerAggregate struct {
+	Group           string  `json:"group" description:"the rack, partition, project or size, empty for machines without this property"`
+	Machines        int     `json:"machines" description:"the amount of machines which reported their power consumption within the time window"`
+	AverageWatts    float64 `json:"averagewatts" description:"the consumed energy divided by the length of the time window"`
+	PeakWatts       float64 `json:"peakwatts" description:"the sum of the maximum power consumption of each machine, an upper bound of the peak consumption of the group"`
+	EnergyWattHours float64 `json:"energywatthours" description:"the consumed energy in watt hours"`
+}
+
+type MachineCommandReportRequest struct {
+	Status  string `json:"status" enum:"delivered|succeeded|failed" description:"the status of the command execution"`
+	Message string `json:"message,omitempty" description:"the result message of the command execution, e.g. the error in case it failed" optional:"true"`
+}
+
+// MachineProvisioningTimelineResponse contains the provisioning phases of a machine computed from its provisioning events.
+type MachineProvisioningTimelineResponse struct {
+	MachineID string                        `json:"machineid" description:"the id of the machine"`
+	Phases    []MachineProvisioningPhase    `json:"phases" description:"the provisioning phases of the machine in chronological order"`
+	Durations []MachineProvisioningDuration `json:"durations" description:"the measured durations of provisioning transitions in chronological order"`
+}
+
+type MachineProvisioningPhase struct {
+	Event      string        `json:"event" description:"the provisioning event which started the phase"`
+	Start      time.Time     `json:"start" description:"the time when the phase started"`
+	End        *time.Time    `json:"end,omitempty" description:"the time when the phase ended, not set for the current phase" optional:"true"`
+	Duration   time.Duration `json:"duration" description:"the duration of the phase, the current phase lasts until now"`
+	Unexpected bool          `json:"unexpected" description:"true if the phase was entered by a transition which does not follow the expected machine lifecycle"`
+}
+
+type MachineProvisioningDuration struct {
+	From     string        `json:"from" description:"the provisioning event which started the measurement"`
+	To       string        `json:"to" description:"the provisioning event which ended the measurement"`
+	Start    time.Time     `json:"start" description:"the time when the measurement started"`
+	Duration time.Duration `json:"duration" description:"the duration from one event to the other"`
+}
+
+type MachineProvisioningStatisticsRequest struct {
+	PartitionID *string `json:"partitionid" description:"only consider machines of this partition" optional:"true"`
+	SizeID      *string `json:"sizeid" description:"only consider machines of this size" optional:"true"`
+	ImageID     *string `json:"imageid" description:"only consider machines allocated with this image" optional:"true"`
+	GroupBy     *string `json:"group_by" description:"the property the statistics are grouped by, defaults to size" enum:"size|partition|image" optional:"true"`
+}
+
+// MachineProvisioningStatistic contains the durations of a provisioning transition across all machines of a group.
+type MachineProvisioningStatistic struct {
+	Group string        `json:"group" description:"the size, partition or image the statistic belongs to, empty for machines without this property"`
+	From  string        `json:"from" description:"the provisioning event which started the measurement"`
+	To    string        `json:"to" description:"the provisioning event which ended the measurement"`
+	Count int           `json:"count" description:"the amount of measured durations"`
+	P50   time.Duration `json:"p50" description:"the median of the measured durations"`
+	P95   time.Duration `json:"p95" description:"the 95th percentile of the measured durations"`
+	Max   time.Duration `json:"max" description:"the longest measured duration"`
+}
+
+// MachineProvisioningEventLogResponse is a page of the provisioning event log.
+type MachineProvisioningEventLogResponse struct {
+	Entries    []MachineProvisioningEventLogEntry `json:"entries" description:"the provisioning events in chronological order"`
+	NextCursor *string                            `json:"next_cursor,omitempty" description:"the cursor to request the next page, not set on the last page" optional:"true"`
+}
+
+type MachineProvisioningEventLogEntry struct {
+	MachineID string    `json:"machineid" description:"the id of the machine which emitted the event"`
+	Time      time.Time `json:"time" description:"the time when the event was received"`
+	Event     string    `json:"event" description:"the provisioning event"`
+	Message   string    `json:"message" description:"an additional message of the event"`
+}
+
+// MachineAllocationExplainResponse explains whether a machine allocation would succeed without allocating a machine.
+type MachineAllocationExplainResponse struct {
+	Possible   bool                     `json:"possible" description:"true if the allocation would succeed at the moment"`
+	Checks     []MachineAllocationCheck `json:"checks" description:"the checks of the allocation spec in the order they are evaluated during allocation"`
+	Stages     []MachineAllocationStage `json:"stages" description:"the filter stages of the machine candidate election in the order they are applied, empty when a specific machine was requested"`
+	Candidates []string                 `json:"candidates" description:"the ids of the machines which could be elected for the allocation"`
+}
+
+type MachineAllocationCheck struct {
+	Name    string  `json:"name" description:"the name of the check"`
+	Passed  bool    `json:"passed" description:"true if the check passed"`
+	Message *string `json:"message,omitempty" description:"the reason why the check failed" optional:"true"`
+}
+
+type MachineAllocationStage struct {
+	Name      string                    `json:"name" description:"the name of the filter stage"`
+	Remaining int                       `json:"remaining" description:"the amount of machine candidates left after this stage"`
+	Dropped   []DroppedMachineCandidate `json:"dropped" description:"the machines which were dropped in this stage"`
+}
+
+type DroppedMachineCandidate struct {
+	MachineID string `json:"machineid" description:"the id of the dropped machine"`
+	Reason    string `json:"reason" description:"the reason why the machine was dropped"`
 }
 
 type MachineAllocationNetworks []MachineAllocationNetwork
@@ -265,6 +476,7 @@ type MachineUpdateRequest struct {
 type MachineResponse struct {
 	Common
 	MachineBase
+	MaintenanceWindow *MaintenanceWindowResponse `json:"maintenance_window,omitempty" description:"the maintenance window which currently taints this machine" optional:"true"`
 	Timestamps
 }
 
@@ -464,27 +676,16 @@ func NewMachineIPMIResponse(m *metal.Machine, s *metal.Size, p *metal.Partition,
 			PowerMetric:   powerMetric,
 			PowerSupplies: powerSupplies,
 			LastUpdated:   m.IPMI.LastUpdated,
-			Fru: MachineFru{
-				ChassisPartNumber:   &m.IPMI.Fru.ChassisPartNumber,
-				ChassisPartSerial:   &m.IPMI.Fru.ChassisPartSerial,
-				BoardMfg:            &m.IPMI.Fru.BoardMfg,
-				BoardMfgSerial:      &m.IPMI.Fru.BoardMfgSerial,
-				BoardPartNumber:     &m.IPMI.Fru.BoardPartNumber,
-				ProductManufacturer: &m.IPMI.Fru.ProductManufacturer,
-				ProductPartNumber:   &m.IPMI.Fru.ProductPartNumber,
-				ProductSerial:       &m.IPMI.Fru.ProductSerial,
-			},
+			Fru:           newMachineFru(m.IPMI.Fru),
 		},
 		Timestamps: machineResponse.Timestamps,
 	}
 }
 
-func NewMachineResponse(m *metal.Machine, s *metal.Size, p *metal.Partition, i *metal.Image, ec *metal.ProvisioningEventContainer) *MachineResponse {
-	var hardware MachineHardware
-
+func newMachineHardware(hw metal.MachineHardware) MachineHardware {
 	nics := MachineNics{}
-	for i := range m.Hardware.Nics {
-		n := m.Hardware.Nics[i]
+	for i := range hw.Nics {
+		n := hw.Nics[i]
 		neighs := MachineNics{}
 		for j := range n.Neighbors {
 			neigh := n.Neighbors[j]
@@ -505,16 +706,16 @@ func NewMachineResponse(m *metal.Machine, s *metal.Size, p *metal.Partition, i *
 	}
 
 	disks := []MachineBlockDevice{}
-	for i := range m.Hardware.Disks {
+	for i := range hw.Disks {
 		disk := MachineBlockDevice{
-			Name: m.Hardware.Disks[i].Name,
-			Size: m.Hardware.Disks[i].Size,
+			Name: hw.Disks[i].Name,
+			Size: hw.Disks[i].Size,
 		}
 		disks = append(disks, disk)
 	}
 
 	cpus := []MetalCPU{}
-	for _, cpu := range m.Hardware.MetalCPUs {
+	for _, cpu := range hw.MetalCPUs {
 		cpus = append(cpus, MetalCPU{
 			Vendor:  cpu.Vendor,
 			Model:   cpu.Model,
@@ -524,22 +725,39 @@ func NewMachineResponse(m *metal.Machine, s *metal.Size, p *metal.Partition, i *
 	}
 
 	gpus := []MetalGPU{}
-	for _, gpu := range m.Hardware.MetalGPUs {
+	for _, gpu := range hw.MetalGPUs {
 		gpus = append(gpus, MetalGPU{
 			Vendor: gpu.Vendor,
 			Model:  gpu.Model,
 		})
 	}
 
-	hardware = MachineHardware{
+	return MachineHardware{
 		MachineHardwareBase: MachineHardwareBase{
-			Memory:    m.Hardware.Memory,
+			Memory:    hw.Memory,
 			Disks:     disks,
 			MetalCPUs: cpus,
 			MetalGPUs: gpus,
 		},
 		Nics: nics,
 	}
+}
+
+func newMachineFru(f metal.Fru) MachineFru {
+	return MachineFru{
+		ChassisPartNumber:   &f.ChassisPartNumber,
+		ChassisPartSerial:   &f.ChassisPartSerial,
+		BoardMfg:            &f.BoardMfg,
+		BoardMfgSerial:      &f.BoardMfgSerial,
+		BoardPartNumber:     &f.BoardPartNumber,
+		ProductManufacturer: &f.ProductManufacturer,
+		ProductPartNumber:   &f.ProductPartNumber,
+		ProductSerial:       &f.ProductSerial,
+	}
+}
+
+func NewMachineResponse(m *metal.Machine, s *metal.Size, p *metal.Partition, i *metal.Image, ec *metal.ProvisioningEventContainer) *MachineResponse {
+	hardware := newMachineHardware(m.Hardware)
 
 	var allocation *MachineAllocation
 	if m.Allocation != nil {
@@ -573,33 +791,7 @@ func NewMachineResponse(m *metal.Machine, s *metal.Size, p *metal.Partition, i *
 
 		var firewallRules *FirewallRules
 		if m.Allocation.Role == metal.RoleFirewall && m.Allocation.FirewallRules != nil {
-			var (
-				egressRules  []FirewallEgressRule
-				ingressRules []FirewallIngressRule
-			)
-
-			for _, r := range m.Allocation.FirewallRules.Egress {
-				egressRules = append(egressRules, FirewallEgressRule{
-					Protocol: strings.ToLower(string(r.Protocol)),
-					Ports:    r.Ports,
-					To:       r.To,
-					Comment:  r.Comment,
-				})
-			}
-			for _, r := range m.Allocation.FirewallRules.Ingress {
-				ingressRules = append(ingressRules, FirewallIngressRule{
-					Protocol: strings.ToLower(string(r.Protocol)),
-					Ports:    r.Ports,
-					To:       r.To,
-					From:     r.From,
-					Comment:  r.Comment,
-				})
-			}
-
-			firewallRules = &FirewallRules{
-				Egress:  egressRules,
-				Ingress: ingressRules,
-			}
+			firewallRules = NewFirewallRules(m.Allocation.FirewallRules)
 		}
 
 		var (
@@ -637,6 +829,7 @@ func NewMachineResponse(m *metal.Machine, s *metal.Size, p *metal.Partition, i *
 			FirewallRules:    firewallRules,
 			DNSServers:       dnsServers,
 			NTPServers:       ntpServers,
+			Lease:            NewMachineLease(m.Allocation.Lease),
 		}
 
 		allocation.Reinstall = m.Allocation.Reinstall
@@ -760,3 +953,184 @@ func NewMachineVPN(m *metal.MachineVPN) *MachineVPN {
 		Connected:           m.Connected,
 	}
 }
+
+func NewMachineLease(l *metal.MachineLease) *MachineLease {
+	if l == nil {
+		return nil
+	}
+
+	return &MachineLease{
+		Duration: l.Duration,
+		Expires:  l.Expires,
+		Warned:   l.Warned,
+		Reaping:  l.Reaping,
+	}
+}
+
+func NewPendingMachineAllocationResponse(a *metal.PendingAllocation, position *int) *PendingMachineAllocationResponse {
+	resp := &PendingMachineAllocationResponse{
+		ID:          a.ID,
+		ProjectID:   a.ProjectID,
+		PartitionID: a.PartitionID,
+		SizeID:      a.SizeID,
+		Priority:    a.Priority,
+		Creator:     a.Creator,
+		State:       string(a.State),
+		Position:    position,
+		Expires:     a.Expires,
+		Timestamps: Timestamps{
+			Created: a.Created,
+			Changed: a.Changed,
+		},
+	}
+	if a.MachineID != "" {
+		resp.MachineID = &a.MachineID
+	}
+	if a.Message != "" {
+		resp.Message = &a.Message
+	}
+	return resp
+}
+
+func NewMachineProvisioningTimelineResponse(machineID string, timeline metal.ProvisioningTimeline, now time.Time) *MachineProvisioningTimelineResponse {
+	phases := []MachineProvisioningPhase{}
+	for _, p := range timeline {
+		phases = append(phases, MachineProvisioningPhase{
+			Event:      string(p.Event),
+			Start:      p.Start,
+			End:        p.End,
+			Duration:   p.Duration(now),
+			Unexpected: p.Unexpected,
+		})
+	}
+
+	durations := []MachineProvisioningDuration{}
+	for _, d := range timeline.Durations() {
+		durations = append(durations, MachineProvisioningDuration{
+			From:     string(d.From),
+			To:       string(d.To),
+			Start:    d.Start,
+			Duration: d.Duration,
+		})
+	}
+
+	return &MachineProvisioningTimelineResponse{
+		MachineID: machineID,
+		Phases:    phases,
+		Durations: durations,
+	}
+}
+
+func NewMachineProvisioningEventLogResponse(entries metal.ProvisioningEventLogEntries, next *string) *MachineProvisioningEventLogResponse {
+	res := []MachineProvisioningEventLogEntry{}
+	for _, e := range entries {
+		res = append(res, MachineProvisioningEventLogEntry{
+			MachineID: e.MachineID,
+			Time:      e.Time,
+			Event:     string(e.Event),
+			Message:   e.Message,
+		})
+	}
+
+	return &MachineProvisioningEventLogResponse{
+		Entries:    res,
+		NextCursor: next,
+	}
+}
+
+func NewMachineProvisioningStatistic(group string, s metal.ProvisioningDurationStatistic) MachineProvisioningStatistic {
+	return MachineProvisioningStatistic{
+		Group: group,
+		From:  string(s.From),
+		To:    string(s.To),
+		Count: s.Count,
+		P50:   s.P50,
+		P95:   s.P95,
+		Max:   s.Max,
+	}
+}
+
+func NewMachineHardwareSnapshotResponse(hs *metal.HardwareSnapshot) *MachineHardwareSnapshotResponse {
+	changes := []MachineHardwareChange{}
+	for _, c := range hs.Changes {
+		changes = append(changes, MachineHardwareChange{
+			Component: c.Component,
+			Type:      string(c.Type),
+			Name:      c.Name,
+			Previous:  c.Previous,
+			Current:   c.Current,
+		})
+	}
+
+	return &MachineHardwareSnapshotResponse{
+		MachineID: hs.MachineID,
+		Revision:  hs.Revision,
+		SizeID:    hs.SizeID,
+		Hardware:  newMachineHardware(hs.Hardware),
+		BIOS: MachineBIOS{
+			Version: hs.BIOS.Version,
+			Vendor:  hs.BIOS.Vendor,
+			Date:    hs.BIOS.Date,
+		},
+		Fru:            newMachineFru(hs.Fru),
+		Changes:        changes,
+		Acknowledged:   hs.Acknowledged,
+		AcknowledgedBy: hs.AcknowledgedBy,
+		Timestamps: Timestamps{
+			Created: hs.Created,
+			Changed: hs.Changed,
+		},
+	}
+}
+
+func NewMachineCommandResponse(e *metal.MachineCommandExecution) *MachineCommandResponse {
+	if e == nil {
+		return nil
+	}
+
+	return &MachineCommandResponse{
+		ID:        e.ID,
+		MachineID: e.MachineID,
+		Command:   string(e.Command),
+		Status:    string(e.Status),
+		Message:   e.Message,
+		Deadline:  e.Deadline,
+		Delivered: e.Delivered,
+		Finished:  e.Finished,
+		Timestamps: Timestamps{
+			Created: e.Created,
+			Changed: e.Changed,
+		},
+	}
+}
+
+func NewMachinePowerSampleResponse(s *metal.PowerSample) *MachinePowerSampleResponse {
+	if s == nil {
+		return nil
+	}
+
+	return &MachinePowerSampleResponse{
+		Time:                  s.Time,
+		Resolution:            s.Resolution,
+		Samples:               s.Samples,
+		AverageConsumedWatts:  s.AverageConsumedWatts,
+		MinConsumedWatts:      s.MinConsumedWatts,
+		MaxConsumedWatts:      s.MaxConsumedWatts,
+		PowerSupplies:         s.PowerSupplies,
+		DegradedPowerSupplies: s.DegradedPowerSupplies,
+		PartitionID:           s.PartitionID,
+		RackID:                s.RackID,
+		SizeID:                s.SizeID,
+		ProjectID:             s.ProjectID,
+	}
+}
+
+func NewMachinePowerAggregate(a *metal.PowerAggregate) MachinePowerAggregate {
+	return MachinePowerAggregate{
+		Group:           a.Group,
+		Machines:        a.Machines,
+		AverageWatts:    a.AverageWatts,
+		PeakWatts:       a.PeakWatts,
+		EnergyWattHours: a.EnergyWattHours,
+	}
+}
@@ -0,0 +1,79 @@
+package v1
+
+import (
+	"time"
+
+	"github.com/metal-stack/metal-api/cmd/metal-api/internal/metal"
+)
+
+type MaintenanceWindowBase struct {
+	Scope       string    `json:"scope" enum:"machine|rack|partition" description:"the scope of this maintenance window, defines which machines are targeted"`
+	PartitionID string    `json:"partitionid,omitempty" description:"the partition targeted by this maintenance window, required for rack and partition scope" optional:"true"`
+	RackID      string    `json:"rackid,omitempty" description:"the rack targeted by this maintenance window, required for rack scope" optional:"true"`
+	MachineID   string    `json:"machineid,omitempty" description:"the machine targeted by this maintenance window, required for machine scope" optional:"true"`
+	Start       time.Time `json:"start" description:"the start of this maintenance window"`
+	End         time.Time `json:"end" description:"the end of this maintenance window"`
+	Reason      string    `json:"reason" description:"the reason for this maintenance window"`
+}
+
+type MaintenanceWindowCreateRequest struct {
+	Common
+	MaintenanceWindowBase
+}
+
+type MaintenanceWindowUpdateRequest struct {
+	Common
+	Start  *time.Time `json:"start,omitempty" description:"the start of this maintenance window" optional:"true"`
+	End    *time.Time `json:"end,omitempty" description:"the end of this maintenance window" optional:"true"`
+	Reason *string    `json:"reason,omitempty" description:"the reason for this maintenance window" optional:"true"`
+}
+
+type MaintenanceWindowFindRequest struct {
+	ID          *string `json:"id,omitempty" description:"the id of the maintenance window" optional:"true"`
+	Scope       *string `json:"scope,omitempty" description:"the scope of the maintenance window" optional:"true"`
+	PartitionID *string `json:"partitionid,omitempty" description:"the partition targeted by the maintenance window" optional:"true"`
+	RackID      *string `json:"rackid,omitempty" description:"the rack targeted by the maintenance window" optional:"true"`
+	MachineID   *string `json:"machineid,omitempty" description:"the machine targeted by the maintenance window" optional:"true"`
+	Active      *bool   `json:"active,omitempty" description:"only return maintenance windows which are currently active or inactive" optional:"true"`
+}
+
+type MaintenanceWindowResponse struct {
+	Common
+	MaintenanceWindowBase
+	Issuer string `json:"issuer" description:"the user who created this maintenance window"`
+	Active bool   `json:"active" description:"true if this maintenance window is currently active"`
+	Timestamps
+}
+
+func NewMaintenanceWindowResponse(w *metal.MaintenanceWindow, now time.Time) *MaintenanceWindowResponse {
+	if w == nil {
+		return nil
+	}
+
+	return &MaintenanceWindowResponse{
+		Common: Common{
+			Identifiable: Identifiable{
+				ID: w.ID,
+			},
+			Describable: Describable{
+				Name:        &w.Name,
+				Description: &w.Description,
+			},
+		},
+		MaintenanceWindowBase: MaintenanceWindowBase{
+			Scope:       string(w.Scope),
+			PartitionID: w.PartitionID,
+			RackID:      w.RackID,
+			MachineID:   w.MachineID,
+			Start:       w.Start,
+			End:         w.End,
+			Reason:      w.Reason,
+		},
+		Issuer: w.Issuer,
+		Active: w.IsActive(now),
+		Timestamps: Timestamps{
+			Created: w.Created,
+			Changed: w.Changed,
+		},
+	}
+}
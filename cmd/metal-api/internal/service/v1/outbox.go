@@ -0,0 +1,49 @@
+package v1
+
+import (
+	"time"
+
+	"github.com/metal-stack/metal-api/cmd/metal-api/internal/metal"
+)
+
+type OutboxEventFindRequest struct {
+	ID    *string `json:"id,omitempty" description:"the id of the outbox event" optional:"true"`
+	Topic *string `json:"topic,omitempty" description:"the topic the event is published to" optional:"true"`
+	State *string `json:"state,omitempty" enum:"pending|published|failed" description:"the delivery state of the event" optional:"true"`
+	Stuck *bool   `json:"stuck,omitempty" description:"only return events which failed or are pending after an unsuccessful attempt" optional:"true"`
+}
+
+type OutboxEventResponse struct {
+	ID          string     `json:"id" description:"the id of the outbox event, it is used as deduplication id by consumers"`
+	Topic       string     `json:"topic" description:"the topic the event is published to"`
+	Payload     string     `json:"payload" description:"the json representation of the event"`
+	State       string     `json:"state" enum:"pending|published|failed" description:"the delivery state of the event"`
+	Stuck       bool       `json:"stuck" description:"true if the event failed or is pending after an unsuccessful attempt"`
+	Attempts    int        `json:"attempts" description:"the amount of attempts to publish the event"`
+	NextAttempt time.Time  `json:"next_attempt" description:"the time of the next attempt to publish the event if it is pending"`
+	LastError   string     `json:"last_error,omitempty" description:"the error of the last unsuccessful attempt" optional:"true"`
+	Published   *time.Time `json:"published,omitempty" description:"the time the event was published" optional:"true"`
+	Timestamps
+}
+
+func NewOutboxEventResponse(e *metal.OutboxEvent) *OutboxEventResponse {
+	if e == nil {
+		return nil
+	}
+
+	return &OutboxEventResponse{
+		ID:          e.ID,
+		Topic:       e.Topic,
+		Payload:     e.Payload,
+		State:       string(e.State),
+		Stuck:       e.Stuck(),
+		Attempts:    e.Attempts,
+		NextAttempt: e.NextAttempt,
+		LastError:   e.LastError,
+		Published:   e.Published,
+		Timestamps: Timestamps{
+			Created: e.Created,
+			Changed: e.Changed,
+		},
+	}
+}
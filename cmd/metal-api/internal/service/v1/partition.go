@@ -1,6 +1,8 @@
 package v1
 
 import (
+	"time"
+
 	"github.com/metal-stack/metal-api/cmd/metal-api/internal/metal"
 )
 
@@ -17,33 +19,98 @@ type PartitionBootConfiguration struct {
 	CommandLine *string `json:"commandline" description:"the cmdline to the kernel for the boot image" optional:"true"`
 }
 
+type PartitionBootConfigurationOverride struct {
+	Scope                      string                     `json:"scope" description:"the scope of the override, machine overrides take precedence over tag overrides which take precedence over size overrides, the rollout scope is only set for canaries of a running boot configuration rollout and can not be configured" enum:"machine|tag|size|rollout"`
+	Selector                   string                     `json:"selector" description:"the machine id, tag or size id the override applies to, depending on the scope"`
+	PartitionBootConfiguration PartitionBootConfiguration `json:"bootconfig" description:"the boot configuration values which replace the ones of the partition, empty values are taken from the partition"`
+	CommandLineAppend          *string                    `json:"commandline_append,omitempty" description:"additional flags which are appended to the kernel cmdline" optional:"true"`
+}
+
+type PartitionRackPowerBudget struct {
+	DefaultWatts uint            `json:"default_watts" description:"the power budget in watts of all racks without an own budget, 0 means unlimited"`
+	RackWatts    map[string]uint `json:"rack_watts,omitempty" description:"the power budgets in watts of individual racks by rack id, 0 means unlimited" optional:"true"`
+}
+
 type PartitionCreateRequest struct {
 	Common
 	PartitionBase
-	PartitionBootConfiguration PartitionBootConfiguration `json:"bootconfig" description:"the boot configuration of this partition"`
+	PartitionBootConfiguration PartitionBootConfiguration           `json:"bootconfig" description:"the boot configuration of this partition"`
+	BootConfigurationOverrides []PartitionBootConfigurationOverride `json:"bootconfig_overrides,omitempty" description:"overrides of the boot configuration for specific machines, tags or sizes" optional:"true"`
+	RackPowerBudget            *PartitionRackPowerBudget            `json:"rack_power_budget,omitempty" description:"limits the estimated power consumption of the racks, no machines are allocated in racks which would exceed their budget" optional:"true"`
+	PlacementStrategy          *string                              `json:"placement_strategy,omitempty" description:"the placement strategy of allocations which do not define one, defaults to rack-spreading" enum:"rack-spreading|bin-packing|least-recently-reinstalled" optional:"true"`
+	PlacementSpreadLevel       *string                              `json:"placement_spread_level,omitempty" description:"the failure domain level machines are spread or packed across if the allocation does not define one, defaults to rack" enum:"region|datacenter|row|rack|power-feed" optional:"true"`
 }
 
 type PartitionUpdateRequest struct {
 	Common
-	MgmtServiceAddress         *string                     `json:"mgmtserviceaddress" description:"the address to the management service of this partition" optional:"true"`
-	PartitionBootConfiguration *PartitionBootConfiguration `json:"bootconfig" description:"the boot configuration of this partition" optional:"true"`
-	Labels                     map[string]string           `json:"labels" description:"free labels that you associate with this partition" optional:"true"`
-	DNSServers                 []DNSServer                 `json:"dns_servers" description:"the dns servers for this partition"`
-	NTPServers                 []NTPServer                 `json:"ntp_servers" description:"the ntp servers for this partition"`
+	MgmtServiceAddress         *string                              `json:"mgmtserviceaddress" description:"the address to the management service of this partition" optional:"true"`
+	PartitionBootConfiguration *PartitionBootConfiguration          `json:"bootconfig" description:"the boot configuration of this partition" optional:"true"`
+	BootConfigurationOverrides []PartitionBootConfigurationOverride `json:"bootconfig_overrides" description:"overrides of the boot configuration for specific machines, tags or sizes, replaces all existing overrides if given" optional:"true"`
+	RackPowerBudget            *PartitionRackPowerBudget            `json:"rack_power_budget" description:"limits the estimated power consumption of the racks, replaces the existing budget if given" optional:"true"`
+	PlacementStrategy          *string                              `json:"placement_strategy" description:"the placement strategy of allocations which do not define one, an empty string resets it to the default" enum:"|rack-spreading|bin-packing|least-recently-reinstalled" optional:"true"`
+	PlacementSpreadLevel       *string                              `json:"placement_spread_level" description:"the failure domain level machines are spread or packed across if the allocation does not define one, an empty string resets it to the default" enum:"|region|datacenter|row|rack|power-feed" optional:"true"`
+	Labels                     map[string]string                    `json:"labels" description:"free labels that you associate with this partition" optional:"true"`
+	DNSServers                 []DNSServer                          `json:"dns_servers" description:"the dns servers for this partition"`
+	NTPServers                 []NTPServer                          `json:"ntp_servers" description:"the ntp servers for this partition"`
 }
 
 type PartitionResponse struct {
 	Common
 	PartitionBase
-	PartitionBootConfiguration PartitionBootConfiguration `json:"bootconfig" description:"the boot configuration of this partition"`
+	PartitionBootConfiguration PartitionBootConfiguration           `json:"bootconfig" description:"the boot configuration of this partition"`
+	BootConfigurationOverrides []PartitionBootConfigurationOverride `json:"bootconfig_overrides" description:"overrides of the boot configuration for specific machines, tags or sizes"`
+	RackPowerBudget            *PartitionRackPowerBudget            `json:"rack_power_budget,omitempty" description:"limits the estimated power consumption of the racks, no machines are allocated in racks which would exceed their budget" optional:"true"`
+	PlacementStrategy          string                               `json:"placement_strategy" description:"the placement strategy of allocations which do not define one"`
+	PlacementSpreadLevel       string                               `json:"placement_spread_level" description:"the failure domain level machines are spread or packed across if the allocation does not define one"`
 	Timestamps
 	Labels map[string]string `json:"labels" description:"free labels that you associate with this partition" optional:"true"`
 }
 
+// MachineBootConfigurationResponse is the boot configuration a machine receives from its partition.
+type MachineBootConfigurationResponse struct {
+	MachineID                  string                              `json:"machineid" description:"the id of the machine"`
+	PartitionID                string                              `json:"partitionid" description:"the partition of the machine"`
+	PartitionBootConfiguration PartitionBootConfiguration          `json:"bootconfig" description:"the resolved boot configuration of the machine"`
+	Override                   *PartitionBootConfigurationOverride `json:"override,omitempty" description:"the override which was applied, not set if the boot configuration of the partition is used" optional:"true"`
+}
+
+// PartitionBootConfigurationRolloutRequest starts the rollout of a new boot configuration to a subset of the machines of a partition.
+type PartitionBootConfigurationRolloutRequest struct {
+	PartitionBootConfiguration PartitionBootConfiguration `json:"bootconfig" description:"the new boot configuration, empty values are taken from the partition"`
+	Percentage                 *int                       `json:"percentage" description:"the percentage of machines of the partition which boot with the new boot configuration" optional:"true" minimum:"1" maximum:"100"`
+	MachineIDs                 []string                   `json:"machineids" description:"the ids of machines which boot with the new boot configuration, added to the ones selected by percentage, machines with a boot configuration override cannot be chosen" optional:"true"`
+	FailureThreshold           int                        `json:"failure_threshold" description:"the percentage of failed canaries at which the rollout is rolled back" minimum:"1" maximum:"100"`
+	StuckTimeout               *time.Duration             `json:"stuck_timeout" description:"the duration after which a canary which is still preparing or registering counts as failed, defaults to 30m" optional:"true"`
+}
+
+// PartitionBootConfigurationRolloutResponse is the state of the boot configuration rollout of a partition.
+type PartitionBootConfigurationRolloutResponse struct {
+	PartitionID                string                                     `json:"partitionid" description:"the partition of the rollout"`
+	PartitionBootConfiguration PartitionBootConfiguration                 `json:"bootconfig" description:"the new boot configuration which is rolled out"`
+	Canaries                   []string                                   `json:"canaries" description:"the ids of the machines which boot with the new boot configuration while the rollout is running"`
+	FailureThreshold           int                                        `json:"failure_threshold" description:"the percentage of failed canaries at which the rollout is rolled back"`
+	FailureRate                float64                                    `json:"failure_rate" description:"the percentage of canaries which failed since the rollout was started"`
+	StuckTimeout               time.Duration                              `json:"stuck_timeout" description:"the duration after which a canary which is still preparing or registering counts as failed"`
+	State                      string                                     `json:"state" description:"the state of the rollout" enum:"running|promoted|rolled-back|aborted"`
+	Failures                   []PartitionBootConfigurationRolloutFailure `json:"failures" description:"the canaries which failed"`
+	Message                    string                                     `json:"message" description:"describes why the rollout was finished"`
+	Creator                    string                                     `json:"creator" description:"the user who started the rollout"`
+	Started                    time.Time                                  `json:"started" description:"the time when the rollout was started"`
+	Finished                   *time.Time                                 `json:"finished,omitempty" description:"the time when the rollout was finished" optional:"true"`
+}
+
+type PartitionBootConfigurationRolloutFailure struct {
+	MachineID string    `json:"machineid" description:"the id of the failed canary"`
+	Reason    string    `json:"reason" description:"the reason why the canary counts as failed"`
+	Time      time.Time `json:"time" description:"the time of the failure"`
+}
+
 type PartitionCapacityRequest struct {
 	ID      *string `json:"id" description:"the id of the partition" optional:"true"`
 	Size    *string `json:"sizeid" description:"the size to filter for" optional:"true"`
 	Project *string `json:"projectid" description:"if provided the machine reservations of this project will be respected in the free counts"`
+	// FailureDomainLevel breaks down the capacities into the failure domains of this level.
+	FailureDomainLevel *string `json:"failure_domain_level,omitempty" description:"if provided the capacities are additionally aggregated by the failure domains of this location level" enum:"region|datacenter|row|rack|power-feed" optional:"true"`
 }
 
 type ServerCapacities []*ServerCapacity
@@ -94,6 +161,22 @@ type ServerCapacity struct {
 	UsedReservations int `json:"usedreservations,omitempty" description:"the amount of used reservations for this size"`
 	// RemainingReservations is the amount of reservations remaining for this size.
 	RemainingReservations int `json:"remainingreservations,omitempty" description:"the amount of unused / remaining / open reservations for this size"`
+
+	// FailureDomains contains the capacities per failure domain if a failure domain level was requested.
+	FailureDomains FailureDomainCapacities `json:"failure_domains,omitempty" description:"the capacities of this size per failure domain" optional:"true"`
+}
+
+type FailureDomainCapacities []*FailureDomainCapacity
+
+// FailureDomainCapacity holds the machine capacity of a size within a failure domain of a partition.
+// Machines in racks which are not located at the requested level are aggregated in a failure domain with an empty name.
+type FailureDomainCapacity struct {
+	Domain      string `json:"domain" description:"the failure domain, empty for machines whose location is unknown at the requested level"`
+	Total       int    `json:"total,omitempty" description:"total amount of machines with size in this failure domain"`
+	Allocated   int    `json:"allocated,omitempty" description:"allocated machines in this failure domain"`
+	Allocatable int    `json:"allocatable,omitempty" description:"free machines in this failure domain, size reservations are not considered"`
+	Unavailable int    `json:"unavailable,omitempty" description:"unavailable machines in this failure domain"`
+	Faulty      int    `json:"faulty,omitempty" description:"machines with issues in this failure domain"`
 }
 
 func NewPartitionResponse(p *metal.Partition) *PartitionResponse {
@@ -122,6 +205,11 @@ func NewPartitionResponse(p *metal.Partition) *PartitionResponse {
 		})
 	}
 
+	overrides := []PartitionBootConfigurationOverride{}
+	for _, o := range p.BootConfigurationOverrides {
+		overrides = append(overrides, *NewPartitionBootConfigurationOverride(&o))
+	}
+
 	return &PartitionResponse{
 		Common: Common{
 			Identifiable: Identifiable{
@@ -142,6 +230,10 @@ func NewPartitionResponse(p *metal.Partition) *PartitionResponse {
 			KernelURL:   &p.BootConfiguration.KernelURL,
 			CommandLine: &p.BootConfiguration.CommandLine,
 		},
+		BootConfigurationOverrides: overrides,
+		RackPowerBudget:            NewPartitionRackPowerBudget(p.RackPowerBudget),
+		PlacementStrategy:          string(p.PlacementStrategy.OrDefault()),
+		PlacementSpreadLevel:       string((&metal.Placement{}).SpreadLevelOf(p)),
 		Timestamps: Timestamps{
 			Created: p.Created,
 			Changed: p.Changed,
@@ -150,6 +242,81 @@ func NewPartitionResponse(p *metal.Partition) *PartitionResponse {
 	}
 }
 
+func NewPartitionRackPowerBudget(b *metal.RackPowerBudget) *PartitionRackPowerBudget {
+	if b == nil {
+		return nil
+	}
+	return &PartitionRackPowerBudget{
+		DefaultWatts: b.DefaultWatts,
+		RackWatts:    b.RackWatts,
+	}
+}
+
+func NewPartitionBootConfigurationOverride(o *metal.BootConfigurationOverride) *PartitionBootConfigurationOverride {
+	if o == nil {
+		return nil
+	}
+	return &PartitionBootConfigurationOverride{
+		Scope:    string(o.Scope),
+		Selector: o.Selector,
+		PartitionBootConfiguration: PartitionBootConfiguration{
+			ImageURL:    &o.BootConfiguration.ImageURL,
+			KernelURL:   &o.BootConfiguration.KernelURL,
+			CommandLine: &o.BootConfiguration.CommandLine,
+		},
+		CommandLineAppend: &o.CommandLineAppend,
+	}
+}
+
+func NewMachineBootConfigurationResponse(m *metal.Machine, p *metal.Partition) *MachineBootConfigurationResponse {
+	bc, override := p.ResolveBootConfiguration(m)
+	return &MachineBootConfigurationResponse{
+		MachineID:   m.ID,
+		PartitionID: p.ID,
+		PartitionBootConfiguration: PartitionBootConfiguration{
+			ImageURL:    &bc.ImageURL,
+			KernelURL:   &bc.KernelURL,
+			CommandLine: &bc.CommandLine,
+		},
+		Override: NewPartitionBootConfigurationOverride(override),
+	}
+}
+
+func NewPartitionBootConfigurationRolloutResponse(p *metal.Partition) *PartitionBootConfigurationRolloutResponse {
+	r := p.BootConfigurationRollout
+	if r == nil {
+		return nil
+	}
+
+	failures := []PartitionBootConfigurationRolloutFailure{}
+	for _, f := range r.Failures {
+		failures = append(failures, PartitionBootConfigurationRolloutFailure{
+			MachineID: f.MachineID,
+			Reason:    f.Reason,
+			Time:      f.Time,
+		})
+	}
+
+	return &PartitionBootConfigurationRolloutResponse{
+		PartitionID: p.ID,
+		PartitionBootConfiguration: PartitionBootConfiguration{
+			ImageURL:    &r.BootConfiguration.ImageURL,
+			KernelURL:   &r.BootConfiguration.KernelURL,
+			CommandLine: &r.BootConfiguration.CommandLine,
+		},
+		Canaries:         r.Canaries,
+		FailureThreshold: r.FailureThreshold,
+		FailureRate:      r.FailureRate(),
+		StuckTimeout:     r.StuckTimeout,
+		State:            string(r.State),
+		Failures:         failures,
+		Message:          r.Message,
+		Creator:          r.Creator,
+		Started:          r.Started,
+		Finished:         r.Finished,
+	}
+}
+
 func (s ServerCapacities) FindBySize(size string) *ServerCapacity {
 	for _, sc := range s {
 		if sc.Size == size {
@@ -159,3 +326,13 @@ func (s ServerCapacities) FindBySize(size string) *ServerCapacity {
 
 	return nil
 }
+
+func (s FailureDomainCapacities) FindByDomain(domain string) *FailureDomainCapacity {
+	for _, fc := range s {
+		if fc.Domain == domain {
+			return fc
+		}
+	}
+
+	return nil
+}
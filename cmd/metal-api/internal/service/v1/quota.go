@@ -0,0 +1,32 @@
+package v1
+
+import (
+	"net/http"
+
+	"github.com/metal-stack/metal-api/cmd/metal-api/internal/metal"
+)
+
+// QuotaExceededResponse is returned when an allocation is rejected because of an exceeded project quota.
+//
+// It contains the same fields as the generic error response, so clients which do not know about quotas can still read it.
+type QuotaExceededResponse struct {
+	StatusCode int    `json:"statuscode" description:"http status code"`
+	Message    string `json:"message" description:"error message"`
+	Quota      string `json:"quota" description:"the kind of the quota that was exceeded" enum:"machine|firewall|size|network|ip"`
+	ProjectID  string `json:"projectid" description:"the project the quota belongs to"`
+	Scope      string `json:"scope,omitempty" description:"the size the quota is limited to, empty for project-wide quotas" optional:"true"`
+	Max        int    `json:"max" description:"the maximum amount permitted by the quota"`
+	Used       int    `json:"used" description:"the current usage of the quota"`
+}
+
+func NewQuotaExceededResponse(err *metal.QuotaExceededError) *QuotaExceededResponse {
+	return &QuotaExceededResponse{
+		StatusCode: http.StatusUnprocessableEntity,
+		Message:    err.Error(),
+		Quota:      string(err.Kind),
+		ProjectID:  err.ProjectID,
+		Scope:      err.Scope,
+		Max:        err.Max,
+		Used:       err.Used,
+	}
+}
@@ -1,6 +1,8 @@
 package v1
 
 import (
+	"time"
+
 	"github.com/metal-stack/metal-api/cmd/metal-api/internal/metal"
 )
 
@@ -13,20 +15,23 @@ type SizeConstraint struct {
 
 type SizeCreateRequest struct {
 	Common
-	SizeConstraints []SizeConstraint  `json:"constraints" description:"a list of constraints that defines this size"`
-	Labels          map[string]string `json:"labels" description:"free labels that you associate with this size." optional:"true"`
+	SizeConstraints    []SizeConstraint  `json:"constraints" description:"a list of constraints that defines this size"`
+	Labels             map[string]string `json:"labels" description:"free labels that you associate with this size." optional:"true"`
+	ExpectedPowerWatts *uint             `json:"expected_power_watts,omitempty" description:"the expected power draw of an allocated machine of this size in watts, it is used to estimate the consumption of racks for the rack power budget" optional:"true"`
 }
 
 type SizeUpdateRequest struct {
 	Common
-	SizeConstraints *[]SizeConstraint `json:"constraints" description:"a list of constraints that defines this size" optional:"true"`
-	Labels          map[string]string `json:"labels" description:"free labels that you associate with this size." optional:"true"`
+	SizeConstraints    *[]SizeConstraint `json:"constraints" description:"a list of constraints that defines this size" optional:"true"`
+	Labels             map[string]string `json:"labels" description:"free labels that you associate with this size." optional:"true"`
+	ExpectedPowerWatts *uint             `json:"expected_power_watts" description:"the expected power draw of an allocated machine of this size in watts, it is used to estimate the consumption of racks for the rack power budget" optional:"true"`
 }
 
 type SizeResponse struct {
 	Common
-	SizeConstraints []SizeConstraint  `json:"constraints" description:"a list of constraints that defines this size"`
-	Labels          map[string]string `json:"labels" description:"free labels that you associate with this size."`
+	SizeConstraints    []SizeConstraint  `json:"constraints" description:"a list of constraints that defines this size"`
+	Labels             map[string]string `json:"labels" description:"free labels that you associate with this size."`
+	ExpectedPowerWatts uint              `json:"expected_power_watts" description:"the expected power draw of an allocated machine of this size in watts, 0 if unknown"`
 	Timestamps
 }
 
@@ -37,6 +42,8 @@ type SizeReservationCreateRequest struct {
 	ProjectID    string            `json:"projectid" description:"the project id of this size reservation"`
 	Amount       int               `json:"amount" description:"the amount of reservations of this size reservation"`
 	Labels       map[string]string `json:"labels,omitempty" description:"free labels associated with this size reservation."`
+	Start        *time.Time        `json:"start,omitempty" description:"the point in time from which on this size reservation is honored, immediately if not set" optional:"true"`
+	End          *time.Time        `json:"end,omitempty" description:"the point in time from which on this size reservation is not honored anymore, forever if not set" optional:"true"`
 }
 
 type SizeReservationUpdateRequest struct {
@@ -44,6 +51,8 @@ type SizeReservationUpdateRequest struct {
 	PartitionIDs []string          `json:"partitionids" description:"the partition id of this size reservation"`
 	Amount       *int              `json:"amount" description:"the amount of reservations of this size reservation"`
 	Labels       map[string]string `json:"labels,omitempty" description:"free labels associated with this size reservation."`
+	Start        *time.Time        `json:"start,omitempty" description:"the point in time from which on this size reservation is honored, the zero time removes the start" optional:"true"`
+	End          *time.Time        `json:"end,omitempty" description:"the point in time from which on this size reservation is not honored anymore, the zero time removes the end" optional:"true"`
 }
 
 type SizeReservationResponse struct {
@@ -54,6 +63,8 @@ type SizeReservationResponse struct {
 	ProjectID    string            `json:"projectid" description:"the project id of this size reservation"`
 	Amount       int               `json:"amount" description:"the amount of reservations of this size reservation"`
 	Labels       map[string]string `json:"labels,omitempty" description:"free labels associated with this size reservation."`
+	Start        *time.Time        `json:"start,omitempty" description:"the point in time from which on this size reservation is honored" optional:"true"`
+	End          *time.Time        `json:"end,omitempty" description:"the point in time from which on this size reservation is not honored anymore" optional:"true"`
 }
 
 type SizeReservationUsageResponse struct {
@@ -62,9 +73,12 @@ type SizeReservationUsageResponse struct {
 	PartitionID        string            `json:"partitionid" description:"the partition id of this size reservation"`
 	ProjectID          string            `json:"projectid" description:"the project id of this size reservation"`
 	Amount             int               `json:"amount" description:"the amount of reservations of this size reservation"`
-	UsedAmount         int               `json:"usedamount" description:"the used amount of reservations of this size reservation"`
+	UsedAmount         int               `json:"usedamount" description:"the used amount of reservations of this size reservation, always zero if the reservation is not active"`
 	ProjectAllocations int               `json:"projectallocations" description:"the amount of allocations of this project referenced by this size reservation"`
 	Labels             map[string]string `json:"labels,omitempty" description:"free labels associated with this size reservation."`
+	Active             bool              `json:"active" description:"whether this size reservation is currently honored"`
+	Start              *time.Time        `json:"start,omitempty" description:"the point in time from which on this size reservation is honored" optional:"true"`
+	End                *time.Time        `json:"end,omitempty" description:"the point in time from which on this size reservation is not honored anymore" optional:"true"`
 }
 
 type SizeReservationListRequest struct {
@@ -122,7 +136,8 @@ func NewSizeResponse(s *metal.Size) *SizeResponse {
 				Description: &s.Description,
 			},
 		},
-		SizeConstraints: constraints,
+		SizeConstraints:    constraints,
+		ExpectedPowerWatts: s.ExpectedPowerWatts,
 		Timestamps: Timestamps{
 			Created: s.Created,
 			Changed: s.Changed,
@@ -140,5 +155,7 @@ func NewSizeReservationResponse(rv *metal.SizeReservation) *SizeReservationRespo
 		ProjectID:    rv.ProjectID,
 		Amount:       rv.Amount,
 		Labels:       rv.Labels,
+		Start:        rv.Start,
+		End:          rv.End,
 	}
 }
@@ -123,7 +123,7 @@ type headscaleMachineLister interface {
 	NodesConnected(ctx context.Context) ([]*headscalev1.Node, error)
 }
 
-func EvaluateVPNConnected(log *slog.Logger, ds *datastore.RethinkStore, lister headscaleMachineLister) error {
+func EvaluateVPNConnected(log *slog.Logger, ds datastore.Store, lister headscaleMachineLister) error {
 	ms, err := ds.ListMachines()
 	if err != nil {
 		return err
@@ -5,18 +5,18 @@ import (
 )
 
 // acquireRandomVRF will grab a unique but random vrf out of the vrfintegerpool
-func acquireRandomVRF(ds *datastore.RethinkStore) (*uint, error) {
+func acquireRandomVRF(ds datastore.Store) (*uint, error) {
 	vrf, err := ds.GetVRFPool().AcquireRandomUniqueInteger()
 	return &vrf, err
 }
 
 // acquireVRF will the given vrf out of the vrfintegerpool if not available a error is thrown
-func acquireVRF(ds *datastore.RethinkStore, vrf uint) error {
+func acquireVRF(ds datastore.Store, vrf uint) error {
 	_, err := ds.GetVRFPool().AcquireUniqueInteger(vrf)
 	return err
 }
 
 // releaseVRF will return the given vrf to the vrfintegerpool for reuse
-func releaseVRF(ds *datastore.RethinkStore, vrf uint) error {
+func releaseVRF(ds datastore.Store, vrf uint) error {
 	return ds.GetVRFPool().ReleaseUniqueInteger(vrf)
 }
@@ -916,6 +916,7 @@ func InitMockDBData(mock *r.Mock) {
 
 	// Find
 	mock.On(r.DB("mockdb").Table("sizereservation").Filter(r.MockAnything())).Return(metal.SizeReservations{}, nil)
+	mock.On(r.DB("mockdb").Table("location").Filter(r.MockAnything())).Return(metal.Locations{}, nil)
 
 	// Default: Return Empty result
 	mock.On(r.DB("mockdb").Table("size").Get(r.MockAnything())).Return(EmptyResult, nil)
@@ -929,6 +930,7 @@ func InitMockDBData(mock *r.Mock) {
 	mock.On(r.DB("mockdb").Table("project").Get(r.MockAnything())).Return(EmptyResult, nil)
 
 	mock.On(r.DB("mockdb").Table("event").Get(r.MockAnything())).Return(EmptyResult, nil)
+	mock.On(r.DB("mockdb").Table("event").GetAll(r.MockAnything())).Return(TestEvents, nil)
 
 	// X.GetTable
 	mock.On(r.DB("mockdb").Table("size")).Return(TestSizes, nil)
@@ -1002,6 +1004,10 @@ func InitMockDBData(mock *r.Mock) {
 	mock.On(r.DB("mockdb").Table("event").Insert(r.MockAnything(), r.InsertOpts{
 		Conflict: "replace",
 	})).Return(EmptyResult, nil)
+	mock.On(r.DB("mockdb").Table("provisioningeventlog").Insert(r.MockAnything())).Return(EmptyResult, nil)
+	mock.On(r.DB("mockdb").Table("machinecommand").Insert(r.MockAnything())).Return(EmptyResult, nil)
+	mock.On(r.DB("mockdb").Table("powersample").Insert(r.MockAnything())).Return(EmptyResult, nil)
+	mock.On(r.DB("mockdb").Table("machinecommand").Get(r.MockAnything()).Replace(r.MockAnything())).Return(EmptyResult, nil)
 
 	return
 }
@@ -23,7 +23,9 @@ import (
 	v1 "github.com/metal-stack/masterdata-api/api/v1"
 	"github.com/metal-stack/metal-api/cmd/metal-api/internal/masterdata"
 	"github.com/metal-stack/metal-api/cmd/metal-api/internal/service/s3client"
+	metalv1 "github.com/metal-stack/metal-api/cmd/metal-api/internal/service/v1"
 
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 
 	"github.com/metal-stack/metal-api/cmd/metal-api/internal/grpc"
@@ -81,12 +83,14 @@ const (
 var (
 	logger *slog.Logger
 
-	ds                 *datastore.RethinkStore
+	ds                 datastore.Store
 	ipamer             ipam.IPAMer
 	publisherTLSConfig *bus.TLSConfig
-	nsqer              *eventbus.NSQClient
+	eventBus           eventbus.Bus
+	outbox             *service.OutboxPublisher
 	mdc                mdm.Client
 	headscaleClient    *headscale.HeadscaleClient
+	ipmiSuperUser      metal.MachineIPMISuperUser
 )
 
 var rootCmd = &cobra.Command{
@@ -108,6 +112,7 @@ var rootCmd = &cobra.Command{
 			return err
 		}
 
+		initIPMISuperUser()
 		initEventBus()
 		initIpam()
 		initMasterData()
@@ -182,6 +187,17 @@ var machineLiveliness = &cobra.Command{
 		return evaluateLiveliness()
 	},
 }
+var maintenanceWindows = &cobra.Command{
+	Use:     "maintenance-windows",
+	Short:   "taints machines targeted by active maintenance windows and restores the state of machines whose window has ended",
+	Version: v.V.String(),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		initLogging()
+
+		return evaluateMaintenanceWindows()
+	},
+}
+
 var machineConnectedToVPN = &cobra.Command{
 	Use:     "machines-vpn-connected",
 	Short:   "evaluates whether machines connected to vpn",
@@ -229,6 +245,7 @@ func init() {
 		migrateDatabase,
 		resurrectMachines,
 		machineLiveliness,
+		maintenanceWindows,
 		deleteOrphanImagesCmd,
 		machineConnectedToVPN,
 	)
@@ -241,6 +258,7 @@ func init() {
 	rootCmd.Flags().IntP("grpc-port", "", 50051, "the port to serve gRPC on")
 	rootCmd.Flags().Bool("init-data-store", true, "initializes the data store on start (can be switched off when running the init command before starting instances)")
 	rootCmd.Flags().UintP("password-reason-minlength", "", 0, "if machine console password is requested this defines if and how long the given reason must be")
+	rootCmd.Flags().Uint("bulk-allocation-limit", 100, "the maximum amount of machines which can be allocated with a single bulk allocation")
 
 	rootCmd.Flags().StringP("base-path", "", "/", "the base path of the api server")
 
@@ -249,7 +267,7 @@ func init() {
 	rootCmd.Flags().StringP("s3-secret", "", "", "the secret of the s3 server that provides firmwares")
 	rootCmd.Flags().StringP("s3-firmware-bucket", "", "", "the bucket that contains the firmwares")
 
-	rootCmd.PersistentFlags().StringP("db", "", "rethinkdb", "the database adapter to use")
+	rootCmd.PersistentFlags().StringP("db", "", "rethinkdb", "the database adapter to use, one of rethinkdb|memory (memory is not persistent and only intended for single binary deployments and testing)")
 	rootCmd.PersistentFlags().StringP("db-name", "", "metalapi", "the database name to use")
 	rootCmd.PersistentFlags().StringP("db-addr", "", "", "the database address string to use")
 	rootCmd.PersistentFlags().StringP("db-user", "", "", "the database user to use")
@@ -258,7 +276,24 @@ func init() {
 	rootCmd.Flags().String("ipam-grpc-server-endpoint", "http://ipam:9090", "the ipam grpc server endpoint")
 
 	rootCmd.Flags().StringP("metrics-server-bind-addr", "", ":2112", "the bind addr of the metrics server")
-
+	rootCmd.Flags().StringSlice("remediation-policies", nil, "automated remediation actions for machine issues in the form <issue-type>=<action>, e.g. liveliness-dead=power-cycle, possible actions are power-cycle|pxe-reboot|taint")
+	rootCmd.Flags().Duration("remediation-interval", time.Minute, "the interval in which machine issues are remediated")
+	rootCmd.Flags().Duration("remediation-cooldown", time.Hour, "the minimum duration between two automated remediations of the same machine")
+	rootCmd.Flags().Int("remediation-rate-limit", 10, "the maximum amount of automated remediations per hour across all machines")
+	rootCmd.Flags().Duration("boot-rollout-interval", time.Minute, "the interval in which the canaries of running boot configuration rollouts are evaluated")
+	rootCmd.Flags().Duration("power-history-raw-retention", 24*time.Hour, "the duration for which power samples are kept as reported by the metal-bmc before they are downsampled to hourly samples")
+	rootCmd.Flags().Duration("power-history-retention", 90*24*time.Hour, "the duration for which the downsampled power samples are kept, 0 keeps them forever")
+	rootCmd.Flags().Duration("machine-lease-reaper-interval", time.Minute, "the interval in which machines with an expired lease are freed, 0 disables the reaper")
+	rootCmd.Flags().Duration("machine-lease-expiry-warning", time.Hour, "the duration before the expiry of a machine lease at which a warning is published to the event bus")
+	rootCmd.Flags().Duration("machine-command-retention", 7*24*time.Hour, "the duration for which finished machine commands and their results are kept, 0 keeps them forever")
+	rootCmd.Flags().Duration("provisioning-event-log-retention", 30*24*time.Hour, "the duration for which provisioning events are kept in the provisioning event log, 0 keeps them forever")
+	rootCmd.Flags().Duration("maintenance-window-interval", time.Minute, "the interval in which machines are tainted and restored when their maintenance windows start and end, 0 disables the evaluation")
+	rootCmd.Flags().Duration("domain-metrics-interval", time.Minute, "the interval in which metrics on partition capacities, machines, integer pools and network usage are updated")
+
+	rootCmd.Flags().String("event-bus-backend", eventbus.BackendNSQ, fmt.Sprintf("the backend of the event bus, one of %s, the memory backend does not share events with other processes and is meant for single-binary deployments", strings.Join(eventbus.Backends, "|")))
+	rootCmd.Flags().Duration("outbox-relay-interval", 10*time.Second, "the interval in which events of the outbox which could not be published immediately are retried")
+	rootCmd.Flags().Int("outbox-max-attempts", 10, "the amount of attempts to publish an event of the outbox after which it needs to be replayed, 0 retries forever")
+	rootCmd.Flags().Duration("outbox-retention", 24*time.Hour, "the duration for which published events are kept in the outbox, 0 keeps them forever")
 	rootCmd.Flags().StringP("nsqd-tcp-addr", "", "", "the TCP address of the nsqd")
 	rootCmd.Flags().StringP("nsqd-http-endpoint", "", "nsqd:4151", "the address of the nsqd http endpoint")
 	rootCmd.Flags().StringP("nsqd-ca-cert-file", "", "", "the CA certificate file to verify nsqd certificate")
@@ -428,6 +463,29 @@ func initSignalHandlers() {
 }
 
 func initEventBus() {
+	switch backend := viper.GetString("event-bus-backend"); backend {
+	case eventbus.BackendNSQ:
+		initNSQ()
+	case eventbus.BackendMemory:
+		memory := eventbus.NewMemory(logger.WithGroup("memory-eventbus"))
+		memory.WaitForTopicsCreated(waitForPartitions(), metal.Topics)
+		eventBus = memory
+	default:
+		log.Fatalf("unsupported event bus backend %q, supported backends are %s", backend, strings.Join(eventbus.Backends, "|"))
+	}
+
+	outbox = service.NewOutboxPublisher(logger.WithGroup("outbox"), ds, eventBus, service.OutboxConfig{
+		MaxAttempts:   viper.GetInt("outbox-max-attempts"),
+		Retention:     viper.GetDuration("outbox-retention"),
+		IPMISuperUser: ipmiSuperUser,
+	})
+}
+
+func initIPMISuperUser() {
+	ipmiSuperUser = metal.NewIPMISuperUser(logger, viper.GetString("bmc-superuser-pwd-file"))
+}
+
+func initNSQ() {
 	writeTimeout, err := time.ParseDuration(viper.GetString("nsqd-write-timeout"))
 	if err != nil {
 		writeTimeout = 0
@@ -456,7 +514,7 @@ func initEventBus() {
 	if err := nsq.CreateEndpoints(viper.GetString("nsqlookupd-addr")); err != nil {
 		panic(err)
 	}
-	nsqer = &nsq
+	eventBus = &nsq
 }
 
 func waitForPartitions() metal.Partitions {
@@ -476,7 +534,8 @@ func waitForPartitions() metal.Partitions {
 
 func connectDataStore(opts ...dsConnectOpt) error {
 	dbAdapter := viper.GetString("db")
-	if dbAdapter == "rethinkdb" {
+	switch dbAdapter {
+	case "rethinkdb":
 		ds = datastore.New(
 			logger.WithGroup("datastore"),
 			viper.GetString("db-addr"),
@@ -484,7 +543,10 @@ func connectDataStore(opts ...dsConnectOpt) error {
 			viper.GetString("db-user"),
 			viper.GetString("db-password"),
 		)
-	} else {
+	case "memory":
+		logger.Warn("using in-memory datastore, all data will be lost when the process terminates")
+		ds = datastore.NewMemory(logger.WithGroup("datastore"))
+	default:
 		return fmt.Errorf("database not supported: %v", dbAdapter)
 	}
 
@@ -716,9 +778,9 @@ func initRestServices(searchAuditBackend auditing.Auditing, allAuditBackends []a
 
 	var p bus.Publisher
 	ep := bus.DirectEndpoints()
-	if nsqer != nil {
-		p = nsqer.Publisher
-		ep = nsqer.Endpoints
+	if eventBus != nil {
+		p = outbox
+		ep = eventBus.Endpoints()
 	}
 	ipService, err := service.NewIP(logger.WithGroup("ip-service"), ds, ep, ipamer, mdc)
 	if err != nil {
@@ -749,7 +811,7 @@ func initRestServices(searchAuditBackend auditing.Auditing, allAuditBackends []a
 	}
 	reasonMinLength := viper.GetUint("password-reason-minlength")
 
-	machineService, err := service.NewMachine(logger.WithGroup("machine-service"), ds, p, ep, ipamer, mdc, s3Client, userGetter, reasonMinLength, headscaleClient, ipmiSuperUser)
+	machineService, err := service.NewMachine(logger.WithGroup("machine-service"), ds, p, ep, ipamer, mdc, s3Client, userGetter, reasonMinLength, headscaleClient, ipmiSuperUser, viper.GetUint("bulk-allocation-limit"))
 	if err != nil {
 		log.Fatal(err)
 	}
@@ -776,7 +838,7 @@ func initRestServices(searchAuditBackend auditing.Auditing, allAuditBackends []a
 	}
 
 	restful.DefaultContainer.Add(service.NewAudit(logger.WithGroup("audit-service"), searchAuditBackend))
-	restful.DefaultContainer.Add(service.NewPartition(logger.WithGroup("partition-service"), ds, nsqer))
+	restful.DefaultContainer.Add(service.NewPartition(logger.WithGroup("partition-service"), ds, eventBus))
 	restful.DefaultContainer.Add(service.NewImage(logger.WithGroup("image-service"), ds))
 	restful.DefaultContainer.Add(service.NewSize(logger.WithGroup("size-service"), ds, mdc))
 	restful.DefaultContainer.Add(service.NewSizeImageConstraint(logger.WithGroup("size-image-constraint-service"), ds))
@@ -784,12 +846,15 @@ func initRestServices(searchAuditBackend auditing.Auditing, allAuditBackends []a
 	restful.DefaultContainer.Add(ipService)
 	restful.DefaultContainer.Add(firmwareService)
 	restful.DefaultContainer.Add(machineService)
-	restful.DefaultContainer.Add(service.NewProject(logger.WithGroup("project-service"), ds, mdc))
+	restful.DefaultContainer.Add(service.NewProject(logger.WithGroup("project-service"), ds, mdc, eventBus))
 	restful.DefaultContainer.Add(service.NewTenant(logger.WithGroup("tenant-service"), mdc))
 	restful.DefaultContainer.Add(service.NewUser(logger.WithGroup("user-service"), userGetter))
 	restful.DefaultContainer.Add(firewallService)
 	restful.DefaultContainer.Add(service.NewFilesystemLayout(logger.WithGroup("filesystem-layout-service"), ds))
 	restful.DefaultContainer.Add(service.NewSwitch(logger.WithGroup("switch-service"), ds))
+	restful.DefaultContainer.Add(service.NewLocation(logger.WithGroup("location-service"), ds))
+	restful.DefaultContainer.Add(service.NewMaintenanceWindow(logger.WithGroup("maintenance-window-service"), ds, userGetter))
+	restful.DefaultContainer.Add(service.NewOutbox(logger.WithGroup("outbox-service"), ds))
 	restful.DefaultContainer.Add(healthService)
 	restful.DefaultContainer.Add(service.NewVPN(logger.WithGroup("vpn-service"), headscaleClient, reasonMinLength))
 	restful.DefaultContainer.Add(rest.NewVersion(moduleName, &rest.VersionOpts{
@@ -897,9 +962,9 @@ func resurrectDeadMachines() error {
 
 	var p bus.Publisher
 	ep := bus.DirectEndpoints()
-	if nsqer != nil {
-		p = nsqer.Publisher
-		ep = nsqer.Endpoints
+	if eventBus != nil {
+		p = outbox
+		ep = eventBus.Endpoints()
 	}
 	err = service.ResurrectMachines(context.Background(), ds, p, ep, ipamer, headscaleClient, logger)
 	if err != nil {
@@ -923,6 +988,20 @@ func evaluateLiveliness() error {
 	return nil
 }
 
+func evaluateMaintenanceWindows() error {
+	err := connectDataStore()
+	if err != nil {
+		return err
+	}
+
+	err = service.EvaluateMaintenanceWindows(ds, logger)
+	if err != nil {
+		return fmt.Errorf("unable to evaluate maintenance windows: %w", err)
+	}
+
+	return nil
+}
+
 func evaluateVPNConnected() error {
 	err := connectDataStore()
 	if err != nil {
@@ -1028,14 +1107,18 @@ func createAuditingClient(log *slog.Logger) (searchBackend auditing.Auditing, ba
 }
 
 func run() error {
-	ipmiSuperUser := metal.NewIPMISuperUser(logger, viper.GetString("bmc-superuser-pwd-file"))
-
 	auditSearchBackend, allAuditBackends, err := createAuditingClient(logger)
 	if err != nil {
 		log.Fatalf("cannot create auditing client:%s ", err)
 	}
 	initRestServices(auditSearchBackend, allAuditBackends, true, ipmiSuperUser)
 
+	domainCollector := metrics.NewDomainCollector(logger.WithGroup("domain-metrics"), ds, ipamer, func() ([]metalv1.PartitionCapacity, error) {
+		return service.PartitionCapacity(ds, nil)
+	})
+	prometheus.MustRegister(domainCollector)
+	go domainCollector.Run(context.Background(), viper.GetDuration("domain-metrics-interval"))
+
 	// enable OPTIONS-request so clients can query CORS information
 	restful.DefaultContainer.Filter(restful.DefaultContainer.OPTIONSFilter)
 
@@ -1065,13 +1148,63 @@ func run() error {
 	})
 
 	var p bus.Publisher
-	if nsqer != nil {
-		p = nsqer.Publisher
+	ep := bus.DirectEndpoints()
+	if eventBus != nil {
+		p = outbox
+		ep = eventBus.Endpoints()
+	}
+
+	allocationQueue, err := service.NewAllocationQueue(logger.WithGroup("allocation-queue"), ds, p, ep, ipamer, mdc)
+	if err != nil {
+		return fmt.Errorf("cannot create allocation queue: %w", err)
+	}
+	go allocationQueue.Run(context.Background())
+
+	if outbox != nil {
+		go outbox.Run(context.Background(), viper.GetDuration("outbox-relay-interval"))
+	}
+
+	if retention := viper.GetDuration("provisioning-event-log-retention"); retention > 0 {
+		pruner := service.NewProvisioningEventLogPruner(logger.WithGroup("provisioning-event-log"), ds, retention)
+		go pruner.Run(context.Background(), time.Hour)
 	}
 
-	c, err := bus.NewConsumer(logger, publisherTLSConfig, viper.GetString("nsqlookupd-addr"))
+	sizeReservationPruner := service.NewSizeReservationPruner(logger.WithGroup("size-reservation"), ds)
+	go sizeReservationPruner.Run(context.Background(), time.Hour)
+
+	powerHistoryCompactor := service.NewPowerHistoryCompactor(logger.WithGroup("power-history"), ds, viper.GetDuration("power-history-raw-retention"), viper.GetDuration("power-history-retention"))
+	go powerHistoryCompactor.Run(context.Background(), time.Hour)
+
+	machineCommandTracker := service.NewMachineCommandTracker(logger.WithGroup("machine-command"), ds, viper.GetDuration("machine-command-retention"))
+	go machineCommandTracker.Run(context.Background(), time.Minute)
+
+	if interval := viper.GetDuration("machine-lease-reaper-interval"); interval > 0 {
+		leaseReaper, err := service.NewMachineLeaseReaper(logger.WithGroup("machine-lease"), ds, p, ep, ipamer, headscaleClient, viper.GetDuration("machine-lease-expiry-warning"))
+		if err != nil {
+			return fmt.Errorf("cannot create machine lease reaper: %w", err)
+		}
+		go leaseReaper.Run(context.Background(), interval)
+	}
+
+	if interval := viper.GetDuration("maintenance-window-interval"); interval > 0 {
+		maintenanceWindowEvaluator := service.NewMaintenanceWindowEvaluator(logger.WithGroup("maintenance-window"), ds)
+		go maintenanceWindowEvaluator.Run(context.Background(), interval)
+	}
+
+	bootRolloutController := service.NewBootConfigurationRolloutController(logger.WithGroup("boot-rollout"), ds)
+	go bootRolloutController.Run(context.Background(), viper.GetDuration("boot-rollout-interval"))
+
+	remediationPolicies, err := service.ParseRemediationPolicies(viper.GetStringSlice("remediation-policies"))
 	if err != nil {
-		log.Fatalf("cannot connect to NSQ: %s", err)
+		return fmt.Errorf("invalid remediation policies: %w", err)
+	}
+	if len(remediationPolicies) > 0 {
+		remediator := service.NewRemediator(logger.WithGroup("remediation"), ds, p, allAuditBackends, ipmiSuperUser, service.RemediatorConfig{
+			Policies:  remediationPolicies,
+			Cooldown:  viper.GetDuration("remediation-cooldown"),
+			RateLimit: viper.GetInt("remediation-rate-limit"),
+		})
+		go remediator.Run(context.Background(), viper.GetDuration("remediation-interval"))
 	}
 
 	addr := fmt.Sprintf(":%d", viper.GetInt("grpc-port"))
@@ -1085,7 +1218,7 @@ func run() error {
 		err = grpc.Run(&grpc.ServerConfig{
 			Context:                  context.Background(),
 			Publisher:                p,
-			Consumer:                 c,
+			Consumer:                 eventBus,
 			Store:                    ds,
 			Logger:                   logger,
 			Listener:                 listener,
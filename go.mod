@@ -38,7 +38,10 @@ require (
 	gopkg.in/rethinkdb/rethinkdb-go.v6 v6.2.2
 )
 
-require github.com/valyala/fastjson v1.6.10 // indirect
+require (
+	github.com/kylelemons/godebug v1.1.0 // indirect
+	github.com/valyala/fastjson v1.6.10 // indirect
+)
 
 // Newer versions do not export base entities which are used to composite other entities.
 // This breaks metalctl and friends
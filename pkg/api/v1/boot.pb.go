@@ -154,12 +154,14 @@ func (x *BootServiceBootRequest) GetPartitionId() string {
 }
 
 type BootServiceBootResponse struct {
-	state         protoimpl.MessageState `protogen:"open.v1"`
-	Kernel        string                 `protobuf:"bytes,1,opt,name=kernel,proto3" json:"kernel,omitempty"`
-	InitRamDisks  []string               `protobuf:"bytes,2,rep,name=init_ram_disks,json=initRamDisks,proto3" json:"init_ram_disks,omitempty"`
-	Cmdline       *string                `protobuf:"bytes,3,opt,name=cmdline,proto3,oneof" json:"cmdline,omitempty"`
-	unknownFields protoimpl.UnknownFields
-	sizeCache     protoimpl.SizeCache
+	state        protoimpl.MessageState `protogen:"open.v1"`
+	Kernel       string                 `protobuf:"bytes,1,opt,name=kernel,proto3" json:"kernel,omitempty"`
+	InitRamDisks []string               `protobuf:"bytes,2,rep,name=init_ram_disks,json=initRamDisks,proto3" json:"init_ram_disks,omitempty"`
+	Cmdline      *string                `protobuf:"bytes,3,opt,name=cmdline,proto3,oneof" json:"cmdline,omitempty"`
+	// boot_configuration_override is the partition boot configuration override which was applied in the form scope:selector, empty if the partition defaults were used
+	BootConfigurationOverride string `protobuf:"bytes,4,opt,name=boot_configuration_override,json=bootConfigurationOverride,proto3" json:"boot_configuration_override,omitempty"`
+	unknownFields             protoimpl.UnknownFields
+	sizeCache                 protoimpl.SizeCache
 }
 
 func (x *BootServiceBootResponse) Reset() {
@@ -213,6 +215,13 @@ func (x *BootServiceBootResponse) GetCmdline() string {
 	return ""
 }
 
+func (x *BootServiceBootResponse) GetBootConfigurationOverride() string {
+	if x != nil {
+		return x.BootConfigurationOverride
+	}
+	return ""
+}
+
 type BootServiceRegisterRequest struct {
 	state              protoimpl.MessageState `protogen:"open.v1"`
 	Uuid               string                 `protobuf:"bytes,1,opt,name=uuid,proto3" json:"uuid,omitempty"`
@@ -1427,11 +1436,12 @@ const file_api_v1_boot_proto_rawDesc = "" +
 	"\x17BootServiceDhcpResponse\"M\n" +
 	"\x16BootServiceBootRequest\x12\x10\n" +
 	"\x03mac\x18\x01 \x01(\tR\x03mac\x12!\n" +
-	"\fpartition_id\x18\x02 \x01(\tR\vpartitionId\"\x82\x01\n" +
+	"\fpartition_id\x18\x02 \x01(\tR\vpartitionId\"\xc2\x01\n" +
 	"\x17BootServiceBootResponse\x12\x16\n" +
 	"\x06kernel\x18\x01 \x01(\tR\x06kernel\x12$\n" +
 	"\x0einit_ram_disks\x18\x02 \x03(\tR\finitRamDisks\x12\x1d\n" +
-	"\acmdline\x18\x03 \x01(\tH\x00R\acmdline\x88\x01\x01B\n" +
+	"\acmdline\x18\x03 \x01(\tH\x00R\acmdline\x88\x01\x01\x12>\n" +
+	"\x1bboot_configuration_override\x18\x04 \x01(\tR\x19bootConfigurationOverrideB\n" +
 	"\n" +
 	"\b_cmdline\"\xa0\x02\n" +
 	"\x1aBootServiceRegisterRequest\x12\x12\n" +